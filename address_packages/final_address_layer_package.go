@@ -1,47 +1,134 @@
 package addresspackages
 
 import (
+	"crypto/rand"
+	"encoding/binary"
 	"fmt"
 
 	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/fluffelpuff/RoueX/utils"
 	"github.com/fxamacker/cbor"
 )
 
+// Gibt an, über wieviele Relays ein Paket maximal weitergeleitet wird
+const DEFAULT_HOP_LIMIT uint8 = 32
+
+// Gibt die Länge der Zufallszahl an, welche jedes Paket eindeutig macht
+const PACKAGE_NONCE_SIZE = 16
+
 // Stellt ein Verschlüsseltes Paket dar
 type SendableAddressLayerPackage struct {
-	Reciver btcec.PublicKey // Sender public key
-	Sender  btcec.PublicKey // Reciver public key
-	Plain   bool            // Plain or encrypted
-	Data    []byte          // Data
-	Sig     []byte          // Signature
-	PCI     bool            // (PleaseCheckInstructions) Please check instructions
+	Reciver  btcec.PublicKey // Sender public key
+	Sender   btcec.PublicKey // Reciver public key
+	Plain    bool            // Plain or encrypted
+	Data     []byte          // Data
+	Sig      []byte          // Signature
+	PCI      bool            // (PleaseCheckInstructions) Please check instructions
+	HopLimit uint8           // Hop limit, not signed
+	Nonce    []byte          // Random package nonce
+	Control  bool            // Send as control traffic, signed
+	Time     int64           // Unix time of creation, signed
 }
 
 // Wird verwendet um das Paket final in Bytes umzuwnadeln
 type byted_final_adrl_package struct {
-	Reciver []byte `cbor:"1,keyasint"` // Sender public key
-	Sender  []byte `cbor:"2,keyasint"` // Reciver public key
-	Plain   bool   `cbor:"3,keyasint"` // Plain or encrypted
-	Data    []byte `cbor:"4,keyasint"` // Data
-	Sig     []byte `cbor:"5,keyasint"` // Signature
-	PCI     bool   `cbor:"6,keyasint"` // (PleaseCheckInstructions) Please check instructions
+	Reciver  []byte `cbor:"1,keyasint"`           // Sender public key
+	Sender   []byte `cbor:"2,keyasint"`           // Reciver public key
+	Plain    bool   `cbor:"3,keyasint"`           // Plain or encrypted
+	Data     []byte `cbor:"4,keyasint"`           // Data
+	Sig      []byte `cbor:"5,keyasint"`           // Signature
+	PCI      bool   `cbor:"6,keyasint"`           // (PleaseCheckInstructions) Please check instructions
+	HopLimit *uint8 `cbor:"7,keyasint,omitempty"` // Hop limit, not signed
+	Nonce    []byte `cbor:"8,keyasint"`           // Random package nonce
+	Control  bool   `cbor:"9,keyasint,omitempty"` // Send as control traffic, signed
+	Time     int64  `cbor:"10,keyasint"`          // Unix time of creation, signed
+}
+
+// Erstellt eine neue Zufallszahl für ein Paket
+func NewPackageNonce() ([]byte, error) {
+	nonce := make([]byte, PACKAGE_NONCE_SIZE)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("NewPackageNonce: " + err.Error())
+	}
+	return nonce, nil
+}
+
+// Gibt den Hash zurück, welcher vom Absender Signiert wurde
+func (obj *SendableAddressLayerPackage) GetSignHash() []byte {
+	// Es wird ermittelt ob es sich um ein Verschlüsseltes oder ein nicht Verschlüsseltes Paket handelt
+	mode := []byte("cipher")
+	if obj.Plain {
+		mode = []byte("unciphered")
+	}
+
+	// Es wird ermittelt ob die Anweisungen geprüft werden sollen
+	pci := []byte{0}
+	if obj.PCI {
+		pci = []byte{1}
+	}
+
+	// Es wird ermittelt ob das Paket als Steuerpaket übertragen wird, so kann die Priorität
+	// von den weiterleitenden Relays nicht verändert werden
+	control := []byte{0}
+	if obj.Control {
+		control = []byte{1}
+	}
+
+	// Der Zeitpunkt der Erstellung wird Signiert, so kann ein Paket nach Ablauf des Zeitfensters nicht erneut eingespielt werden
+	timestamp := make([]byte, 8)
+	binary.BigEndian.PutUint64(timestamp, uint64(obj.Time))
+
+	// Der Hash wird erstellt, nur das Hop Limit ist nicht enthalten
+	return utils.ComputeSha3256Hash(
+		obj.Sender.SerializeCompressed(),
+		obj.Reciver.SerializeCompressed(),
+		mode,
+		pci,
+		control,
+		timestamp,
+		obj.Nonce,
+		obj.Data,
+	)
+}
+
+// Gibt den Hash des Paketes zurück, dieser wird aus dem Absender und der Signierten Zufallszahl gebildet, so kann ein Paket
+// welches mehrfach über verschiedene Wege eintrifft wiedererkannt werden, Pakete mit gleichem Inhalt bleiben unterscheidbar
+func (obj *SendableAddressLayerPackage) GetPackageHash() []byte {
+	return utils.ComputeSha3256Hash(obj.Sender.SerializeCompressed(), obj.Nonce)
 }
 
 // Prüft ob die Signatur eines Address Layer Paketes korrekt ist
-func (obi *SendableAddressLayerPackage) ValidateSignature() bool {
-	return true
+func (obj *SendableAddressLayerPackage) ValidateSignature() bool {
+	// Sollte keine Signatur oder keine Zufallszahl vorhanden sein, ist das Paket ungültig
+	if len(obj.Sig) == 0 || len(obj.Nonce) != PACKAGE_NONCE_SIZE {
+		return false
+	}
+
+	// Die Signatur wird mit dem Öffentlichen Schlüssel des Absenders geprüft
+	is_valid, err := utils.VerifyByBytes(&obj.Sender, obj.Sig, obj.GetSignHash())
+	if err != nil {
+		return false
+	}
+
+	// Das Ergebniss wird zurückgegeben
+	return is_valid
 }
 
 // Gibt das Paket als Bytes zurück
 func (obj *SendableAddressLayerPackage) ToBytes() ([]byte, error) {
 	// Die Innerbytes werden vorbereitet
+	hop_limit := obj.HopLimit
 	pre_inner_data := byted_final_adrl_package{
-		Reciver: obj.Reciver.SerializeCompressed(),
-		Sender:  obj.Sender.SerializeCompressed(),
-		Data:    obj.Data,
-		Plain:   obj.Plain,
-		Sig:     obj.Sig,
-		PCI:     obj.PCI,
+		Reciver:  obj.Reciver.SerializeCompressed(),
+		Sender:   obj.Sender.SerializeCompressed(),
+		Data:     obj.Data,
+		Plain:    obj.Plain,
+		Sig:      obj.Sig,
+		PCI:      obj.PCI,
+		HopLimit: &hop_limit,
+		Nonce:    obj.Nonce,
+		Control:  obj.Control,
+		Time:     obj.Time,
 	}
 
 	// Das Paket wird in Bytes umgewandelt
@@ -74,14 +161,24 @@ func ReadSendableAddressLayerPackageFromBytes(dbyte []byte) (*SendableAddressLay
 		return nil, fmt.Errorf("ReadSendableAddressLayerPackageFromBytes: 3: " + err.Error())
 	}
 
+	// Sollte kein Hop Limit vorhanden sein, wird der Standardwert verwendet
+	hop_limit := DEFAULT_HOP_LIMIT
+	if v.HopLimit != nil {
+		hop_limit = *v.HopLimit
+	}
+
 	// Das Paket wird nachgebaut
 	rebuilded := &SendableAddressLayerPackage{
-		Reciver: *reciver_pkey,
-		Sender:  *sender_pkey,
-		Data:    v.Data,
-		Plain:   v.Plain,
-		Sig:     v.Sig,
-		PCI:     v.PCI,
+		Reciver:  *reciver_pkey,
+		Sender:   *sender_pkey,
+		Data:     v.Data,
+		Plain:    v.Plain,
+		Sig:      v.Sig,
+		PCI:      v.PCI,
+		HopLimit: hop_limit,
+		Nonce:    v.Nonce,
+		Control:  v.Control,
+		Time:     v.Time,
 	}
 
 	// Das Paket wird zurückgegeben
@@ -0,0 +1,166 @@
+package addresspackages
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/fluffelpuff/RoueX/utils"
+	"github.com/fxamacker/cbor"
+)
+
+// Erzeugt ein Signiertes Paket für die Tests
+func newSignedTestPackage(t *testing.T) *SendableAddressLayerPackage {
+	t.Helper()
+	sender, err := utils.GeneratePrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	reciver, err := utils.GeneratePrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	nonce, err := NewPackageNonce()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pckge := &SendableAddressLayerPackage{
+		Sender:   *sender.PubKey(),
+		Reciver:  *reciver.PubKey(),
+		Data:     []byte("payload"),
+		Plain:    true,
+		HopLimit: DEFAULT_HOP_LIMIT,
+		Nonce:    nonce,
+		Time:     time.Now().Unix(),
+	}
+	if pckge.Sig, err = utils.Sign(sender, pckge.GetSignHash()); err != nil {
+		t.Fatal(err)
+	}
+	return pckge
+}
+
+func TestValidateSignature(t *testing.T) {
+	pckge := newSignedTestPackage(t)
+	if !pckge.ValidateSignature() {
+		t.Fatal("valid signature rejected")
+	}
+
+	// Das Hop Limit ist nicht Signiert und darf von den Relays verändert werden
+	pckge.HopLimit--
+	if !pckge.ValidateSignature() {
+		t.Error("signature rejected after hop limit change")
+	}
+
+	// Jede Änderung eines Signierten Feldes macht die Signatur ungültig
+	tests := []struct {
+		name   string
+		change func(*SendableAddressLayerPackage)
+	}{
+		{"data", func(p *SendableAddressLayerPackage) { p.Data = []byte("changed") }},
+		{"plain", func(p *SendableAddressLayerPackage) { p.Plain = false }},
+		{"pci", func(p *SendableAddressLayerPackage) { p.PCI = true }},
+		{"control", func(p *SendableAddressLayerPackage) { p.Control = true }},
+		{"time", func(p *SendableAddressLayerPackage) { p.Time++ }},
+		{"nonce", func(p *SendableAddressLayerPackage) { p.Nonce = append([]byte{}, p.Nonce...); p.Nonce[0] ^= 1 }},
+		{"reciver", func(p *SendableAddressLayerPackage) { p.Reciver = p.Sender }},
+		{"missing signature", func(p *SendableAddressLayerPackage) { p.Sig = nil }},
+		{"short nonce", func(p *SendableAddressLayerPackage) { p.Nonce = p.Nonce[:PACKAGE_NONCE_SIZE-1] }},
+	}
+	for _, tt := range tests {
+		changed := newSignedTestPackage(t)
+		tt.change(changed)
+		if changed.ValidateSignature() {
+			t.Errorf("%s: changed package accepted", tt.name)
+		}
+	}
+}
+
+func TestValidateSignatureForeignSender(t *testing.T) {
+	pckge := newSignedTestPackage(t)
+	other, err := utils.GeneratePrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pckge.Sender = *other.PubKey()
+	if pckge.ValidateSignature() {
+		t.Error("package with foreign sender accepted")
+	}
+}
+
+func TestGetPackageHash(t *testing.T) {
+	pckge := newSignedTestPackage(t)
+	hash := pckge.GetPackageHash()
+
+	// Der Hash hängt nicht vom Hop Limit ab, so wird ein Paket auf jedem Weg wiedererkannt
+	pckge.HopLimit = 1
+	if !bytes.Equal(hash, pckge.GetPackageHash()) {
+		t.Error("package hash depends on hop limit")
+	}
+
+	// Pakete mit gleichem Inhalt aber unterschiedlicher Zufallszahl bleiben unterscheidbar
+	other := newSignedTestPackage(t)
+	other.Sender, other.Data = pckge.Sender, pckge.Data
+	if bytes.Equal(hash, other.GetPackageHash()) {
+		t.Error("packages with different nonces have the same hash")
+	}
+}
+
+func TestPackageBytesRoundTrip(t *testing.T) {
+	pckge := newSignedTestPackage(t)
+	pckge.Control = true
+	pckge.HopLimit = 7
+
+	// Das Paket wird in Bytes umgewandelt und wieder eingelesen
+	encoded, err := pckge.ToBytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := ReadSendableAddressLayerPackageFromBytes(encoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !decoded.Sender.IsEqual(&pckge.Sender) || !decoded.Reciver.IsEqual(&pckge.Reciver) {
+		t.Error("keys changed by round trip")
+	}
+	if !bytes.Equal(decoded.Data, pckge.Data) || !bytes.Equal(decoded.Nonce, pckge.Nonce) || !bytes.Equal(decoded.Sig, pckge.Sig) {
+		t.Error("data changed by round trip")
+	}
+	if decoded.HopLimit != 7 || !decoded.Control || decoded.Time != pckge.Time || decoded.Plain != pckge.Plain {
+		t.Errorf("fields changed by round trip: %+v", decoded)
+	}
+}
+
+func TestReadPackageWithoutHopLimit(t *testing.T) {
+	pckge := newSignedTestPackage(t)
+	encoded, err := pckge.ToBytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Ohne Hop Limit wird der Standardwert verwendet
+	var raw byted_final_adrl_package
+	if err := cbor.Unmarshal(encoded, &raw); err != nil {
+		t.Fatal(err)
+	}
+	raw.HopLimit = nil
+	encoded, err = cbor.Marshal(raw, cbor.EncOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := ReadSendableAddressLayerPackageFromBytes(encoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decoded.HopLimit != DEFAULT_HOP_LIMIT {
+		t.Errorf("HopLimit = %d, want %d", decoded.HopLimit, DEFAULT_HOP_LIMIT)
+	}
+	if !decoded.ValidateSignature() {
+		t.Error("signature rejected after decoding")
+	}
+}
+
+func TestReadPackageInvalidBytes(t *testing.T) {
+	if _, err := ReadSendableAddressLayerPackageFromBytes([]byte{0xff, 0x00}); err == nil {
+		t.Error("invalid bytes accepted")
+	}
+}
@@ -87,6 +87,150 @@ func (obj *APIClient) PingAddress(adr *btcec.PublicKey, timeout uint16) (int64,
 	}
 }
 
+// Erzeugt einen neuen Relay Schlüssel, die Übergabe wird nach dem Neustart an die verbundenen Relays verteilt
+func (obj *APIClient) RotateRelayKey() (string, error) {
+	// Aufruf der Methode "rotate_key" auf dem RPC-Server
+	var reply map[string]interface{}
+	err := obj._client.Call("Kf.PassCommandArgsToProtocol", CommandArgs{Id: KEY_HANDOVER_PROTOCOL, Method: "rotate_key"}, &reply)
+	if err != nil {
+		return "", fmt.Errorf("RotateRelayKey: " + err.Error())
+	}
+
+	// Der neue Öffentliche Schlüssel wird eingelesen
+	public_key, ok := reply["public_key"].(string)
+	if !ok {
+		return "", fmt.Errorf("RotateRelayKey: invalid public key type")
+	}
+
+	// Der Schlüssel wird zurückgegeben
+	return public_key, nil
+}
+
+// Fügt ein Vertrauenswürdiges Relay hinzu
+func (obj *APIClient) AddTrustedRelay(pkey *btcec.PublicKey, end_point string, protocol string) error {
+	var reply bool
+	err := obj._client.Call("Kf.AddTrustedRelay", TrustedRelayArgs{PublicKey: pkey.SerializeCompressed(), EndPoint: end_point, Protocol: protocol}, &reply)
+	if err != nil {
+		return fmt.Errorf("AddTrustedRelay: " + err.Error())
+	}
+	return nil
+}
+
+// Entfernt ein Vertrauenswürdiges Relay
+func (obj *APIClient) RemoveTrustedRelay(pkey *btcec.PublicKey) error {
+	var reply bool
+	err := obj._client.Call("Kf.RemoveTrustedRelay", TrustedRelayArgs{PublicKey: pkey.SerializeCompressed()}, &reply)
+	if err != nil {
+		return fmt.Errorf("RemoveTrustedRelay: " + err.Error())
+	}
+	return nil
+}
+
+// Aktiviert oder Deaktiviert ein Vertrauenswürdiges Relay
+func (obj *APIClient) SetTrustedRelayActive(pkey *btcec.PublicKey, active bool) error {
+	var reply bool
+	err := obj._client.Call("Kf.SetTrustedRelayActive", TrustedRelayArgs{PublicKey: pkey.SerializeCompressed(), Active: active}, &reply)
+	if err != nil {
+		return fmt.Errorf("SetTrustedRelayActive: " + err.Error())
+	}
+	return nil
+}
+
+// Aktualisiert den Endpunkt und das Protokoll eines Vertrauenswürdigen Relays, leere Werte bleiben unverändert
+func (obj *APIClient) UpdateTrustedRelay(pkey *btcec.PublicKey, end_point string, protocol string) error {
+	var reply bool
+	err := obj._client.Call("Kf.UpdateTrustedRelay", TrustedRelayArgs{PublicKey: pkey.SerializeCompressed(), EndPoint: end_point, Protocol: protocol}, &reply)
+	if err != nil {
+		return fmt.Errorf("UpdateTrustedRelay: " + err.Error())
+	}
+	return nil
+}
+
+// Markiert ein verbundenes nicht Vertrauenswürdiges Relay als Vertrauenswürdig, das Relay wird anhand seines Schlüssels
+// oder einer Verbindungs ID ausgewählt, leere Werte für Endpunkt und Protokoll werden von der Verbindung übernommen
+func (obj *APIClient) PromoteRelay(pkey *btcec.PublicKey, connection_id string, end_point string, protocol string) (string, error) {
+	args := TrustedRelayArgs{ConnectionId: connection_id, EndPoint: end_point, Protocol: protocol}
+	if pkey != nil {
+		args.PublicKey = pkey.SerializeCompressed()
+	}
+	var reply string
+	err := obj._client.Call("Kf.PromoteRelay", args, &reply)
+	if err != nil {
+		return "", fmt.Errorf("PromoteRelay: " + err.Error())
+	}
+	return reply, nil
+}
+
+// Lädt die Vertrauenswürdigen Relays erneut aus der Datenbank
+func (obj *APIClient) ReloadTrustedRelays() error {
+	var reply bool
+	err := obj._client.Call("Kf.ReloadTrustedRelays", TrustedRelayArgs{}, &reply)
+	if err != nil {
+		return fmt.Errorf("ReloadTrustedRelays: " + err.Error())
+	}
+	return nil
+}
+
+// Fügt eine Firewall Regel hinzu, die ID der Regel wird zurückgegeben
+func (obj *APIClient) AddFirewallRule(rule FirewallRuleArgs) (int64, error) {
+	var reply int64
+	err := obj._client.Call("Kf.AddFirewallRule", rule, &reply)
+	if err != nil {
+		return -1, fmt.Errorf("AddFirewallRule: " + err.Error())
+	}
+	return reply, nil
+}
+
+// Entfernt eine Firewall Regel
+func (obj *APIClient) RemoveFirewallRule(rule_id int64) error {
+	var reply bool
+	err := obj._client.Call("Kf.RemoveFirewallRule", FirewallRuleArgs{RuleId: rule_id}, &reply)
+	if err != nil {
+		return fmt.Errorf("RemoveFirewallRule: " + err.Error())
+	}
+	return nil
+}
+
+// Ruft alle Firewall Regeln sowie die Anzahl der verworfenen Pakete ab
+func (obj *APIClient) FetchFirewallState() (*ApiFirewallState, error) {
+	var reply ApiFirewallState
+	err := obj._client.Call("Kf.FetchFirewallState", EmptyArg{}, &reply)
+	if err != nil {
+		return nil, fmt.Errorf("FetchFirewallState: " + err.Error())
+	}
+	return &reply, nil
+}
+
+// Legt die Ausführlichkeit der Log Ausgabe eines Subsystems fest, bei einem leeren Namen wird der Standardwert geändert
+func (obj *APIClient) SetLogLevel(subsystem string, level string) error {
+	var reply bool
+	err := obj._client.Call("Kf.SetLogLevel", LogLevelArgs{Subsystem: subsystem, Level: level}, &reply)
+	if err != nil {
+		return fmt.Errorf("SetLogLevel: " + err.Error())
+	}
+	return nil
+}
+
+// Gibt die Ausführlichkeit der Log Ausgabe aller Subsysteme zurück, der Standardwert wird unter einem leeren Namen zurückgegeben
+func (obj *APIClient) FetchLogLevels() (map[string]string, error) {
+	var reply map[string]string
+	err := obj._client.Call("Kf.FetchLogLevels", EmptyArg{}, &reply)
+	if err != nil {
+		return nil, fmt.Errorf("FetchLogLevels: " + err.Error())
+	}
+	return reply, nil
+}
+
+// Ruft die Beschreibungen aller Relays aus den Relay Verzeichnissen ab
+func (obj *APIClient) FetchRelayDescriptors() ([]ApiRelayDescriptor, error) {
+	var reply []ApiRelayDescriptor
+	err := obj._client.Call("Kf.FetchRelayDescriptors", EmptyArg{}, &reply)
+	if err != nil {
+		return nil, fmt.Errorf("FetchRelayDescriptors: " + err.Error())
+	}
+	return reply, nil
+}
+
 // Schließt die Verbindung
 func (obj *APIClient) Close() {
 	obj._lock.Lock()
@@ -0,0 +1,142 @@
+package apiclient
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/fluffelpuff/RoueX/utils"
+)
+
+// Stellt ein empfangenes Datagram dar
+type Datagram struct {
+	Address string
+	Port    uint16
+	Data    []byte
+}
+
+// Stellt einen gebundenen Datagram Socket dar
+type DatagramSocket struct {
+	_client *APIClient
+	_id     string
+	_port   uint16
+}
+
+// Gibt den lokalen Port des Sockets zurück
+func (obj *DatagramSocket) LocalPort() uint16 {
+	return obj._port
+}
+
+// Wartet auf das nächste Datagram
+func (obj *DatagramSocket) ReadFrom() (*Datagram, error) {
+	// Aufruf der Methode "receive" auf dem RPC-Server
+	var reply map[string]interface{}
+	err := obj._client._client.Call("Kf.PassCommandArgsToProtocol", CommandArgs{Id: DATAGRAM_PROTOCOL, Method: "receive", Parms: [][]byte{[]byte(obj._id)}}, &reply)
+	if err != nil {
+		return nil, fmt.Errorf("ReadFrom: " + err.Error())
+	}
+
+	// Die Daten werden eingelesen
+	address, ok := reply["address"].(string)
+	if !ok {
+		return nil, fmt.Errorf("ReadFrom: invalid address type")
+	}
+	decoded_address, err := hex.DecodeString(address)
+	if err != nil {
+		return nil, fmt.Errorf("ReadFrom: " + err.Error())
+	}
+	source, err := btcec.ParsePubKey(decoded_address)
+	if err != nil {
+		return nil, fmt.Errorf("ReadFrom: " + err.Error())
+	}
+	port, ok := reply["port"].(uint64)
+	if !ok {
+		return nil, fmt.Errorf("ReadFrom: invalid port type")
+	}
+	data, ok := reply["data"].([]byte)
+	if !ok {
+		data = []byte{}
+	}
+
+	// Das Datagram wird zurückgegeben
+	return &Datagram{Address: utils.ConvertPublicKeyToAddress(source), Port: uint16(port), Data: data}, nil
+}
+
+// Gibt alle eintreffenden Datagramme als Ereignisse aus, der Channel wird geschlossen sobald der Socket geschlossen oder der Context
+// abgebrochen wurde, ein bereits laufender Empfangsvorgang wird erst durch das Eintreffen eines Datagrams oder das Schließen des Sockets beendet
+func (obj *DatagramSocket) Events(ctx context.Context) <-chan *Datagram {
+	events := make(chan *Datagram)
+	go func() {
+		defer close(events)
+		for ctx.Err() == nil {
+			datagram, err := obj.ReadFrom()
+			if err != nil {
+				return
+			}
+			select {
+			case events <- datagram:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return events
+}
+
+// Sendet ein Datagram an eine Adresse
+func (obj *DatagramSocket) WriteTo(address string, port uint16, data []byte) error {
+	// Die Adresse wird eingelesen
+	pkey, err := utils.ConvertAddressToPublicKey(address)
+	if err != nil {
+		return fmt.Errorf("WriteTo: " + err.Error())
+	}
+
+	// Der Port wird umgewandelt
+	port_bytes := make([]byte, 2)
+	binary.BigEndian.PutUint16(port_bytes, port)
+
+	// Aufruf der Methode "send" auf dem RPC-Server
+	var reply map[string]interface{}
+	err = obj._client._client.Call("Kf.PassCommandArgsToProtocol", CommandArgs{Id: DATAGRAM_PROTOCOL, Method: "send", Parms: [][]byte{[]byte(obj._id), pkey.SerializeCompressed(), port_bytes, data}}, &reply)
+	if err != nil {
+		return fmt.Errorf("WriteTo: " + err.Error())
+	}
+	return nil
+}
+
+// Schließt den Socket
+func (obj *DatagramSocket) Close() error {
+	var reply map[string]interface{}
+	err := obj._client._client.Call("Kf.PassCommandArgsToProtocol", CommandArgs{Id: DATAGRAM_PROTOCOL, Method: "close", Parms: [][]byte{[]byte(obj._id)}}, &reply)
+	if err != nil {
+		return fmt.Errorf("Close: " + err.Error())
+	}
+	return nil
+}
+
+// Bindet einen Datagram Socket an einen Port, bei Port 0 wird ein freier Port gewählt
+func (obj *APIClient) BindDatagram(port uint16) (*DatagramSocket, error) {
+	// Der Port wird umgewandelt
+	port_bytes := make([]byte, 2)
+	binary.BigEndian.PutUint16(port_bytes, port)
+
+	// Aufruf der Methode "bind" auf dem RPC-Server
+	var reply map[string]interface{}
+	err := obj._client.Call("Kf.PassCommandArgsToProtocol", CommandArgs{Id: DATAGRAM_PROTOCOL, Method: "bind", Parms: [][]byte{port_bytes}}, &reply)
+	if err != nil {
+		return nil, fmt.Errorf("BindDatagram: " + err.Error())
+	}
+
+	// Die Daten des Sockets werden eingelesen
+	id, ok := reply["socket"].(string)
+	if !ok {
+		return nil, fmt.Errorf("BindDatagram: invalid socket type")
+	}
+	bound_port, ok := reply["port"].(uint64)
+	if !ok {
+		return nil, fmt.Errorf("BindDatagram: invalid port type")
+	}
+	return &DatagramSocket{_client: obj, _id: id, _port: uint16(bound_port)}, nil
+}
@@ -0,0 +1,172 @@
+package apiclient
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+)
+
+// Stellt einen Stream zu einem Relay dar
+type Stream struct {
+	_client  *APIClient
+	_id      string
+	_address string
+	_port    uint16
+}
+
+// Ließt Daten aus dem Stream, am Ende des Streams wird io.EOF zurückgegeben
+func (obj *Stream) Read(b []byte) (int, error) {
+	// Die maximale Größe wird übermittelt
+	size := make([]byte, 4)
+	binary.BigEndian.PutUint32(size, uint32(len(b)))
+
+	// Aufruf der Methode "read" auf dem RPC-Server
+	var reply map[string]interface{}
+	err := obj._client._client.Call("Kf.PassCommandArgsToProtocol", CommandArgs{Id: STREAM_PROTOCOL, Method: "read", Parms: [][]byte{[]byte(obj._id), size}}, &reply)
+	if err != nil {
+		return 0, fmt.Errorf("Read: " + err.Error())
+	}
+
+	// Es wird geprüft ob das Ende erreicht wurde
+	if eof, ok := reply["eof"].(bool); ok && eof {
+		return 0, io.EOF
+	}
+
+	// Die Daten werden übernommen
+	data, ok := reply["data"].([]byte)
+	if !ok {
+		return 0, fmt.Errorf("Read: invalid data type")
+	}
+	return copy(b, data), nil
+}
+
+// Schreibt Daten in den Stream
+func (obj *Stream) Write(b []byte) (int, error) {
+	// Aufruf der Methode "write" auf dem RPC-Server
+	var reply map[string]interface{}
+	err := obj._client._client.Call("Kf.PassCommandArgsToProtocol", CommandArgs{Id: STREAM_PROTOCOL, Method: "write", Parms: [][]byte{[]byte(obj._id), b}}, &reply)
+	if err != nil {
+		return 0, fmt.Errorf("Write: " + err.Error())
+	}
+
+	// Die Anzahl der geschriebenen Bytes wird eingelesen
+	written, ok := reply["written"].(uint64)
+	if !ok {
+		return 0, fmt.Errorf("Write: invalid written type")
+	}
+	return int(written), nil
+}
+
+// Beendet die Sendeseite des Streams, die Gegenseite erhält ein EOF, es kann weiterhin gelesen werden
+func (obj *Stream) CloseWrite() error {
+	var reply map[string]interface{}
+	err := obj._client._client.Call("Kf.PassCommandArgsToProtocol", CommandArgs{Id: STREAM_PROTOCOL, Method: "close_write", Parms: [][]byte{[]byte(obj._id)}}, &reply)
+	if err != nil {
+		return fmt.Errorf("CloseWrite: " + err.Error())
+	}
+	return nil
+}
+
+// Schließt den Stream, bereits geschriebene Daten werden noch übertragen
+func (obj *Stream) Close() error {
+	var reply map[string]interface{}
+	err := obj._client._client.Call("Kf.PassCommandArgsToProtocol", CommandArgs{Id: STREAM_PROTOCOL, Method: "close", Parms: [][]byte{[]byte(obj._id)}}, &reply)
+	if err != nil {
+		return fmt.Errorf("Close: " + err.Error())
+	}
+	return nil
+}
+
+// Gibt die Adresse der Gegenseite als Hex String zurück, ist nur bei angenommenen Streams gesetzt
+func (obj *Stream) RemoteAddress() string {
+	return obj._address
+}
+
+// Gibt den Port des Streams zurück
+func (obj *Stream) Port() uint16 {
+	return obj._port
+}
+
+// Stellt einen Lauschenden Port dar
+type StreamListener struct {
+	_client *APIClient
+	_id     string
+	_port   uint16
+}
+
+// Wartet auf einen eingehenden Stream
+func (obj *StreamListener) Accept() (*Stream, error) {
+	// Aufruf der Methode "accept" auf dem RPC-Server
+	var reply map[string]interface{}
+	err := obj._client._client.Call("Kf.PassCommandArgsToProtocol", CommandArgs{Id: STREAM_PROTOCOL, Method: "accept", Parms: [][]byte{[]byte(obj._id)}}, &reply)
+	if err != nil {
+		return nil, fmt.Errorf("Accept: " + err.Error())
+	}
+
+	// Die Daten des Streams werden eingelesen
+	id, ok := reply["stream"].(string)
+	if !ok {
+		return nil, fmt.Errorf("Accept: invalid stream type")
+	}
+	address, ok := reply["address"].(string)
+	if !ok {
+		return nil, fmt.Errorf("Accept: invalid address type")
+	}
+
+	// Der Stream wird zurückgegeben
+	return &Stream{_client: obj._client, _id: id, _address: address, _port: obj._port}, nil
+}
+
+// Schließt den Listener
+func (obj *StreamListener) Close() error {
+	var reply map[string]interface{}
+	err := obj._client._client.Call("Kf.PassCommandArgsToProtocol", CommandArgs{Id: STREAM_PROTOCOL, Method: "close", Parms: [][]byte{[]byte(obj._id)}}, &reply)
+	if err != nil {
+		return fmt.Errorf("Close: " + err.Error())
+	}
+	return nil
+}
+
+// Lauscht auf einem Stream Port
+func (obj *APIClient) ListenStream(port uint16) (*StreamListener, error) {
+	// Der Port wird umgewandelt
+	port_bytes := make([]byte, 2)
+	binary.BigEndian.PutUint16(port_bytes, port)
+
+	// Aufruf der Methode "listen" auf dem RPC-Server
+	var reply map[string]interface{}
+	err := obj._client.Call("Kf.PassCommandArgsToProtocol", CommandArgs{Id: STREAM_PROTOCOL, Method: "listen", Parms: [][]byte{port_bytes}}, &reply)
+	if err != nil {
+		return nil, fmt.Errorf("ListenStream: " + err.Error())
+	}
+
+	// Die ID des Listeners wird eingelesen
+	id, ok := reply["listener"].(string)
+	if !ok {
+		return nil, fmt.Errorf("ListenStream: invalid listener type")
+	}
+	return &StreamListener{_client: obj, _id: id, _port: port}, nil
+}
+
+// Baut einen Stream zu einem Relay auf
+func (obj *APIClient) ConnectStream(adr *btcec.PublicKey, port uint16) (*Stream, error) {
+	// Der Port wird umgewandelt
+	port_bytes := make([]byte, 2)
+	binary.BigEndian.PutUint16(port_bytes, port)
+
+	// Aufruf der Methode "connect" auf dem RPC-Server
+	var reply map[string]interface{}
+	err := obj._client.Call("Kf.PassCommandArgsToProtocol", CommandArgs{Id: STREAM_PROTOCOL, Method: "connect", Parms: [][]byte{adr.SerializeCompressed(), port_bytes}}, &reply)
+	if err != nil {
+		return nil, fmt.Errorf("ConnectStream: " + err.Error())
+	}
+
+	// Die ID des Streams wird eingelesen
+	id, ok := reply["stream"].(string)
+	if !ok {
+		return nil, fmt.Errorf("ConnectStream: invalid stream type")
+	}
+	return &Stream{_client: obj, _id: id, _port: port}, nil
+}
@@ -23,14 +23,81 @@ type ApiRelayEntry struct {
 	IsConnected       bool
 	PublicKey         string
 	IsTrusted         bool
+	IsActive          bool
+	EndPoint          string
+	Protocol          string
 	TotalConnections  uint64
 	TotalBytesSend    uint64
 	TotalBytesRecived uint64
 	PingMS            uint64
 	BandwithKBs       uint64
+	Reputation        int64
 	Connections       []ApiRelayConnection
 }
 
+type ApiRelayDescriptorEndPoint struct {
+	Protocol uint8
+	Module   string
+	EndPoint string
+}
+
+type ApiRelayDescriptor struct {
+	PublicKey string
+	Version   uint64
+	Seq       uint64
+	Expires   int64
+	EndPoints []ApiRelayDescriptorEndPoint
+}
+
+type TrustedRelayArgs struct {
+	PublicKey    []byte
+	ConnectionId string
+	EndPoint     string
+	Protocol     string
+	Active       bool
+}
+
+type FirewallRuleArgs struct {
+	RuleId      int64
+	Priority    int64
+	Action      string
+	Direction   string
+	SenderPKey  []byte
+	ReciverPKey []byte
+	RelayPKey   []byte
+	Protocol    int16
+	Rate        uint64
+	Burst       uint64
+	Active      bool
+}
+
+type ApiFirewallRule struct {
+	Id          int64
+	Priority    int64
+	Action      string
+	Direction   string
+	SenderPKey  string
+	ReciverPKey string
+	RelayPKey   string
+	Protocol    int16
+	Rate        uint64
+	Burst       uint64
+	Active      bool
+}
+
+type ApiFirewallState struct {
+	TotalDropedPackages uint64
+	Rules               []ApiFirewallRule
+}
+
+type LogLevelArgs struct {
+	Subsystem string
+	Level     string
+}
+
 const (
-	PING_PROTOCOL uint8 = 0
+	PING_PROTOCOL         uint8 = 0
+	KEY_HANDOVER_PROTOCOL uint8 = 2
+	STREAM_PROTOCOL       uint8 = 3
+	DATAGRAM_PROTOCOL     uint8 = 4
 )
@@ -1,9 +1,699 @@
 package main
 
+import (
+	"flag"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/fluffelpuff/RoueX/ipoverlay"
+	"github.com/fluffelpuff/RoueX/kernel"
+	"github.com/fluffelpuff/RoueX/keystore"
+	"github.com/fluffelpuff/RoueX/logging"
+	"github.com/fluffelpuff/RoueX/protocols"
+	"github.com/fluffelpuff/RoueX/static"
+	"github.com/fluffelpuff/RoueX/utils"
+)
+
+// Stellt alle Dateipfade dar, welche über die Einstellungen überschrieben werden können
+type ConfigPaths struct {
+	APISocket       string `toml:"api_socket"`
+	TrustedRelays   string `toml:"trusted_relays"`
+	RoutingTable    string `toml:"routing_table"`
+	FirewallTable   string `toml:"firewall_table"`
+	ExternalModules string `toml:"external_modules"`
+	PrivateKeyFile  string `toml:"private_key_file"`
+	PassphraseFile  string `toml:"keystore_passphrase_file"`
+}
+
+// Stellt alle Puffer Grenzwerte dar
+type ConfigLimits struct {
+	WSMaxPackages           uint32 `toml:"ws_max_packages"`
+	WSMaxBytes              uint64 `toml:"ws_max_bytes"`
+	KernelBufferMaxPackages uint   `toml:"kernel_buffer_max_packages"`
+}
+
+// Stellt einen Lokalen Server Endpunkt dar
+type ConfigListener struct {
+	Address string `toml:"address"`
+	Port    uint64 `toml:"port"`
+}
+
+// Stellt ein zu Registrierendes Kernel Protokoll dar, der Protokolltyp ist durch das Protokoll festgelegt
+type ConfigProtocol struct {
+	Name string `toml:"name"`
+}
+
+// Stellt die Einstellungen des TUN Gerätes dar
+type ConfigTun struct {
+	Name string `toml:"name"`
+	MTU  uint32 `toml:"mtu"`
+}
+
+// Stellt die Freigaberichtlinie eines Exit Relays dar
+type ConfigExit struct {
+	Allow   []string `toml:"allow"`
+	Clients []string `toml:"clients"`
+}
+
+// Stellt einen Lokalen SOCKS5 Server dar, welcher seine Verbindungen über ein Exit Relay aufbaut
+type ConfigSocks5 struct {
+	Address  string `toml:"address"`
+	Port     uint64 `toml:"port"`
+	Exit     string `toml:"exit"`
+	Username string `toml:"username"`
+	Password string `toml:"password"`
+}
+
+// Stellt die Einstellungen der Log Ausgabe dar
+type ConfigLog struct {
+	Format string            `toml:"format"`
+	Level  string            `toml:"level"`
+	Levels map[string]string `toml:"levels"`
+}
+
+// Stellt die Einstellungen für Rückverbindungen zu angekündigten Servern anderer Relays dar
+type ConfigP2P struct {
+	BackDial          bool `toml:"back_dial"`
+	BackDialUntrusted bool `toml:"back_dial_untrusted"`
+}
+
+// Stellt die Einstellungen für die Zulassung nicht Vertrauenswürdiger Relays dar
+type ConfigUntrustedRelays struct {
+	Admission  string   `toml:"admission"`
+	Allowlist  []string `toml:"allowlist"`
+	MaxSlots   uint     `toml:"max_slots"`
+	EvictScore int64    `toml:"evict_score"`
+}
+
+// Stellt die Einstellungen der Relay Verzeichnisse dar
+type ConfigDirectory struct {
+	Advertise  []string `toml:"advertise"`
+	StaticFile string   `toml:"static_file"`
+	TTL        uint64   `toml:"ttl"`
+}
+
+// Stellt die Einstellungen für das Bootstrappen dar, ein Seed wird im Format 'adresse@endpunkt' angegeben
+type ConfigBootstrap struct {
+	Seeds                 []string `toml:"seeds"`
+	TargetOutbound        uint     `toml:"target_outbound"`
+	AllowPrivateEndPoints bool     `toml:"allow_private_endpoints"`
+	ShareTrusted          bool     `toml:"share_trusted"`
+}
+
+// Stellt die Einstellungen des Relays dar
 type Config struct {
+	Paths               ConfigPaths           `toml:"paths"`
+	Limits              ConfigLimits          `toml:"limits"`
+	WebsocketServers    []ConfigListener      `toml:"websocket_server"`
+	TcpServers          []ConfigListener      `toml:"tcp_server"`
+	QuicServers         []ConfigListener      `toml:"quic_server"`
+	ClientModules       []string              `toml:"client_modules"`
+	Protocols           []ConfigProtocol      `toml:"protocol"`
+	Tun                 ConfigTun             `toml:"tun"`
+	Exit                ConfigExit            `toml:"exit"`
+	Socks5Servers       []ConfigSocks5        `toml:"socks5_server"`
+	MetricsServers      []ConfigListener      `toml:"metrics_server"`
+	LoadExternalModules bool                  `toml:"load_external_modules"`
+	DrainTimeout        uint64                `toml:"drain_timeout"`
+	Log                 ConfigLog             `toml:"log"`
+	P2P                 ConfigP2P             `toml:"p2p"`
+	UntrustedRelays     ConfigUntrustedRelays `toml:"untrusted_relays"`
+	Directory           ConfigDirectory       `toml:"directory"`
+	Bootstrap           ConfigBootstrap       `toml:"bootstrap"`
+	_path               string
+}
+
+// Speichert alle Kommandozeilenparameter ab, welche die Einstellungen überschreiben
+type configFlags struct {
+	config_path      *string
+	api_socket       *string
+	trusted_relays   *string
+	routing_table    *string
+	firewall_table   *string
+	external_modules *string
+	private_key_file *string
+	passphrase_file  *string
+	ws_listen        *string
+	tcp_listen       *string
+	quic_listen      *string
+	metrics_listen   *string
+	ws_max_packages  *uint
+	ws_max_bytes     *uint64
+	kernel_buffer    *uint
+	drain_timeout    *uint64
+	log_format       *string
+	log_level        *string
+	log_levels       *string
+	p2p_back_dial    *bool
+	p2p_untrusted    *bool
+	untrusted_mode   *string
+	untrusted_slots  *uint
+	dir_advertise    *string
+	dir_static_file  *string
+	bootstrap_seeds  *string
+	bootstrap_target *uint
 }
 
-func loadConfigs() error {
-	// Es wird geprüft ob Parameter vorhanden sind
+// Die Parameter werden beim Starten des Programmes registriert, damit sie in allen Programmteilen verfügbar sind
+var _config_flags = registerConfigFlags(flag.CommandLine)
+
+// Registriert alle Kommandozeilenparameter welche die Einstellungen überschreiben
+func registerConfigFlags(fs *flag.FlagSet) *configFlags {
+	return &configFlags{
+		config_path:      fs.String("config", "", "path to the config file"),
+		api_socket:       fs.String("api-socket", "", "path to the api unix socket"),
+		trusted_relays:   fs.String("trusted-relays", "", "path to the trusted relays table"),
+		routing_table:    fs.String("routing-table", "", "path to the routing table"),
+		firewall_table:   fs.String("firewall-table", "", "path to the firewall table"),
+		external_modules: fs.String("external-modules", "", "path to the external modules directory"),
+		private_key_file: fs.String("private-key-file", "", "path to the relay private key file"),
+		passphrase_file:  fs.String("keystore-passphrase-file", "", "path to a file containing the keystore passphrase"),
+		ws_listen:        fs.String("ws-listen", "", "comma separated list of websocket listen addresses (host:port)"),
+		tcp_listen:       fs.String("tcp-listen", "", "comma separated list of tcp listen addresses (host:port)"),
+		quic_listen:      fs.String("quic-listen", "", "comma separated list of quic listen addresses (host:port)"),
+		metrics_listen:   fs.String("metrics-listen", "", "comma separated list of prometheus metrics listen addresses (host:port)"),
+		ws_max_packages:  fs.Uint("ws-max-packages", 0, "max packages buffered per websocket connection"),
+		ws_max_bytes:     fs.Uint64("ws-max-bytes", 0, "max bytes buffered per websocket connection"),
+		kernel_buffer:    fs.Uint("kernel-buffer-max-packages", 0, "max packages buffered by the kernel package buffer"),
+		drain_timeout:    fs.Uint64("drain-timeout", 0, "seconds to wait for buffers to flush on SIGTERM"),
+		log_format:       fs.String("log-format", "", "log output format (text or json)"),
+		log_level:        fs.String("log-level", "", "default log level (debug, info, warn, error)"),
+		log_levels:       fs.String("log-levels", "", "comma separated list of per subsystem log levels (subsystem=level)"),
+		p2p_back_dial:    fs.Bool("p2p-back-dial", kernel.DEFAULT_P2P_BACK_DIAL_POLICY.Enabled, "dial back the servers announced by connecting relays"),
+		p2p_untrusted:    fs.Bool("p2p-back-dial-untrusted", kernel.DEFAULT_P2P_BACK_DIAL_POLICY.AllowUntrusted, "also dial back the servers announced by untrusted relays"),
+		untrusted_mode:   fs.String("untrusted-admission", "", "admission of untrusted relays (all, allowlist or none)"),
+		untrusted_slots:  fs.Uint("untrusted-max-slots", 0, "max connected untrusted relays (0 = unlimited)"),
+		dir_advertise:    fs.String("directory-advertise", "", "comma separated list of end points announced in the relay directory"),
+		dir_static_file:  fs.String("directory-static-file", "", "path to a static relay directory file"),
+		bootstrap_seeds:  fs.String("bootstrap-seeds", "", "comma separated list of bootstrap seeds (address@endpoint)"),
+		bootstrap_target: fs.Uint("bootstrap-target-outbound", 0, "number of outbound connections maintained by bootstrapping (0 = disabled)"),
+	}
+}
+
+// Gibt die Standardeinstellungen zurück
+func defaultConfig() Config {
+	return Config{
+		Limits: ConfigLimits{
+			WSMaxPackages:           static.LIMITS.WSMaxPackages,
+			WSMaxBytes:              static.LIMITS.WSMaxBytes,
+			KernelBufferMaxPackages: static.LIMITS.KernelBufferMaxPackages,
+		},
+		WebsocketServers:    []ConfigListener{{Address: "", Port: static.WS_PORT}},
+		ClientModules:       []string{"wstcp", "tcp", "quic"},
+		Protocols:           []ConfigProtocol{{Name: "pingpong"}, {Name: "routeadv"}, {Name: "keyhandover"}, {Name: "stream"}, {Name: "datagram"}, {Name: "directory"}, {Name: "peerexchange"}},
+		Tun:                 ConfigTun{Name: protocols.TUN_DEFAULT_NAME, MTU: protocols.TUN_DEFAULT_MTU},
+		LoadExternalModules: true,
+		DrainTimeout:        uint64(kernel.DEFAULT_DRAIN_TIMEOUT / time.Second),
+		Log:                 ConfigLog{Format: "text", Level: "info", Levels: make(map[string]string)},
+		P2P:                 ConfigP2P{BackDial: kernel.DEFAULT_P2P_BACK_DIAL_POLICY.Enabled, BackDialUntrusted: kernel.DEFAULT_P2P_BACK_DIAL_POLICY.AllowUntrusted},
+		UntrustedRelays: ConfigUntrustedRelays{
+			Admission:  kernel.DEFAULT_UNTRUSTED_RELAY_POLICY.Mode.String(),
+			MaxSlots:   kernel.DEFAULT_UNTRUSTED_RELAY_POLICY.MaxSlots,
+			EvictScore: kernel.DEFAULT_UNTRUSTED_RELAY_POLICY.EvictScore,
+		},
+		Directory: ConfigDirectory{Advertise: make([]string, 0), TTL: uint64(kernel.DEFAULT_RELAY_DESCRIPTOR_TTL / time.Second)},
+		Bootstrap: ConfigBootstrap{Seeds: make([]string, 0), TargetOutbound: kernel.DEFAULT_BOOTSTRAP_POLICY.TargetOutbound},
+	}
+}
+
+// Ließt eine Liste von Endpunkten im Format host:port ein
+func parseListenerList(value string) ([]ConfigListener, error) {
+	result := make([]ConfigListener, 0)
+	for _, item := range strings.Split(value, ",") {
+		// Leere Einträge werden übersprungen
+		item = strings.TrimSpace(item)
+		if len(item) == 0 {
+			continue
+		}
+
+		// Der Host und der Port werden getrennt
+		host, port_str, err := net.SplitHostPort(item)
+		if err != nil {
+			return nil, fmt.Errorf("parseListenerList: 1: " + err.Error())
+		}
+
+		// Der Port wird eingelesen
+		port, err := strconv.ParseUint(port_str, 10, 16)
+		if err != nil {
+			return nil, fmt.Errorf("parseListenerList: 2: " + err.Error())
+		}
+
+		// Der Eintrag wird hinzugefügt
+		result = append(result, ConfigListener{Address: host, Port: port})
+	}
+
+	// Die Daten werden zurückgegeben
+	return result, nil
+}
+
+// Ließt eine durch Kommas getrennte Liste von Endpunkten ein
+func parseEndPointList(value string) []string {
+	result := make([]string, 0)
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); len(item) > 0 {
+			result = append(result, item)
+		}
+	}
+	return result
+}
+
+// Übernimmt eine Liste von Subsystem Ausführlichkeiten (subsystem=level) in die Einstellungen
+func (obj *Config) mergeLogLevels(value string) error {
+	levels, err := logging.ParseLevels(value)
+	if err != nil {
+		return fmt.Errorf("mergeLogLevels: " + err.Error())
+	}
+	if obj.Log.Levels == nil {
+		obj.Log.Levels = make(map[string]string)
+	}
+	for subsystem, level := range levels {
+		obj.Log.Levels[subsystem] = level.String()
+	}
+	return nil
+}
+
+// Ließt die Einstellungen aus einer TOML Datei ein
+func (obj *Config) readFile(path string, required bool) error {
+	// Es wird geprüft ob die Datei vorhanden ist
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		if required {
+			return fmt.Errorf("readFile: config file not found: " + path)
+		}
+		return nil
+	}
+
+	// Die Datei wird eingelesen
+	if _, err := toml.DecodeFile(path, obj); err != nil {
+		return fmt.Errorf("readFile: " + err.Error())
+	}
+
+	// Log
+	fmt.Fprintf(os.Stderr, "Config loaded from %s\n", path)
+
+	// Der Vorgang wurde ohne Fehler durchgeführt
 	return nil
 }
+
+// Überschreibt die Einstellungen mit den Umgebungsvariablen
+func (obj *Config) readEnv() error {
+	// Die Dateipfade werden übernommen
+	string_vars := map[string]*string{
+		"ROUEX_API_SOCKET":               &obj.Paths.APISocket,
+		"ROUEX_TRUSTED_RELAYS":           &obj.Paths.TrustedRelays,
+		"ROUEX_ROUTING_TABLE":            &obj.Paths.RoutingTable,
+		"ROUEX_FIREWALL_TABLE":           &obj.Paths.FirewallTable,
+		"ROUEX_EXTERNAL_MODULES":         &obj.Paths.ExternalModules,
+		"ROUEX_PRIVATE_KEY_FILE":         &obj.Paths.PrivateKeyFile,
+		"ROUEX_KEYSTORE_PASSPHRASE_FILE": &obj.Paths.PassphraseFile,
+		"ROUEX_LOG_FORMAT":               &obj.Log.Format,
+		"ROUEX_LOG_LEVEL":                &obj.Log.Level,
+		"ROUEX_UNTRUSTED_ADMISSION":      &obj.UntrustedRelays.Admission,
+		"ROUEX_DIRECTORY_STATIC_FILE":    &obj.Directory.StaticFile,
+	}
+	for name, target := range string_vars {
+		if value, found := os.LookupEnv(name); found {
+			*target = value
+		}
+	}
+
+	// Die Server Endpunkte werden übernommen
+	if value, found := os.LookupEnv("ROUEX_WS_LISTEN"); found {
+		listeners, err := parseListenerList(value)
+		if err != nil {
+			return fmt.Errorf("readEnv: ROUEX_WS_LISTEN: " + err.Error())
+		}
+		obj.WebsocketServers = listeners
+	}
+	if value, found := os.LookupEnv("ROUEX_TCP_LISTEN"); found {
+		listeners, err := parseListenerList(value)
+		if err != nil {
+			return fmt.Errorf("readEnv: ROUEX_TCP_LISTEN: " + err.Error())
+		}
+		obj.TcpServers = listeners
+	}
+	if value, found := os.LookupEnv("ROUEX_QUIC_LISTEN"); found {
+		listeners, err := parseListenerList(value)
+		if err != nil {
+			return fmt.Errorf("readEnv: ROUEX_QUIC_LISTEN: " + err.Error())
+		}
+		obj.QuicServers = listeners
+	}
+	if value, found := os.LookupEnv("ROUEX_METRICS_LISTEN"); found {
+		listeners, err := parseListenerList(value)
+		if err != nil {
+			return fmt.Errorf("readEnv: ROUEX_METRICS_LISTEN: " + err.Error())
+		}
+		obj.MetricsServers = listeners
+	}
+
+	// Die im Verzeichnis angekündigten Endpunkte werden übernommen
+	if value, found := os.LookupEnv("ROUEX_DIRECTORY_ADVERTISE"); found {
+		obj.Directory.Advertise = parseEndPointList(value)
+	}
+
+	// Die Seeds für das Bootstrappen werden übernommen
+	if value, found := os.LookupEnv("ROUEX_BOOTSTRAP_SEEDS"); found {
+		obj.Bootstrap.Seeds = parseEndPointList(value)
+	}
+
+	// Die Ausführlichkeit der Subsysteme wird übernommen
+	if value, found := os.LookupEnv("ROUEX_LOG_LEVELS"); found {
+		if err := obj.mergeLogLevels(value); err != nil {
+			return fmt.Errorf("readEnv: ROUEX_LOG_LEVELS: " + err.Error())
+		}
+	}
+
+	// Die Grenzwerte werden übernommen
+	if value, found := os.LookupEnv("ROUEX_WS_MAX_PACKAGES"); found {
+		parsed, err := strconv.ParseUint(value, 10, 32)
+		if err != nil {
+			return fmt.Errorf("readEnv: ROUEX_WS_MAX_PACKAGES: " + err.Error())
+		}
+		obj.Limits.WSMaxPackages = uint32(parsed)
+	}
+	if value, found := os.LookupEnv("ROUEX_WS_MAX_BYTES"); found {
+		parsed, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			return fmt.Errorf("readEnv: ROUEX_WS_MAX_BYTES: " + err.Error())
+		}
+		obj.Limits.WSMaxBytes = parsed
+	}
+	if value, found := os.LookupEnv("ROUEX_KERNEL_BUFFER_MAX_PACKAGES"); found {
+		parsed, err := strconv.ParseUint(value, 10, 32)
+		if err != nil {
+			return fmt.Errorf("readEnv: ROUEX_KERNEL_BUFFER_MAX_PACKAGES: " + err.Error())
+		}
+		obj.Limits.KernelBufferMaxPackages = uint(parsed)
+	}
+	if value, found := os.LookupEnv("ROUEX_DRAIN_TIMEOUT"); found {
+		parsed, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			return fmt.Errorf("readEnv: ROUEX_DRAIN_TIMEOUT: " + err.Error())
+		}
+		obj.DrainTimeout = parsed
+	}
+	if value, found := os.LookupEnv("ROUEX_UNTRUSTED_MAX_SLOTS"); found {
+		parsed, err := strconv.ParseUint(value, 10, 32)
+		if err != nil {
+			return fmt.Errorf("readEnv: ROUEX_UNTRUSTED_MAX_SLOTS: " + err.Error())
+		}
+		obj.UntrustedRelays.MaxSlots = uint(parsed)
+	}
+	if value, found := os.LookupEnv("ROUEX_BOOTSTRAP_TARGET_OUTBOUND"); found {
+		parsed, err := strconv.ParseUint(value, 10, 32)
+		if err != nil {
+			return fmt.Errorf("readEnv: ROUEX_BOOTSTRAP_TARGET_OUTBOUND: " + err.Error())
+		}
+		obj.Bootstrap.TargetOutbound = uint(parsed)
+	}
+
+	// Die Einstellungen für Rückverbindungen und das Bootstrappen werden übernommen
+	bool_vars := map[string]*bool{
+		"ROUEX_P2P_BACK_DIAL":           &obj.P2P.BackDial,
+		"ROUEX_P2P_BACK_DIAL_UNTRUSTED": &obj.P2P.BackDialUntrusted,
+		"ROUEX_BOOTSTRAP_ALLOW_PRIVATE": &obj.Bootstrap.AllowPrivateEndPoints,
+		"ROUEX_BOOTSTRAP_SHARE_TRUSTED": &obj.Bootstrap.ShareTrusted,
+	}
+	for name, target := range bool_vars {
+		if value, found := os.LookupEnv(name); found {
+			parsed, err := strconv.ParseBool(value)
+			if err != nil {
+				return fmt.Errorf("readEnv: " + name + ": " + err.Error())
+			}
+			*target = parsed
+		}
+	}
+
+	// Der Vorgang wurde ohne Fehler durchgeführt
+	return nil
+}
+
+// Überschreibt die Einstellungen mit allen gesetzten Kommandozeilenparametern
+func (obj *Config) readFlags(fs *flag.FlagSet, cflags *configFlags) error {
+	var err error
+	fs.Visit(func(f *flag.Flag) {
+		if err != nil {
+			return
+		}
+		switch f.Name {
+		case "api-socket":
+			obj.Paths.APISocket = *cflags.api_socket
+		case "trusted-relays":
+			obj.Paths.TrustedRelays = *cflags.trusted_relays
+		case "routing-table":
+			obj.Paths.RoutingTable = *cflags.routing_table
+		case "firewall-table":
+			obj.Paths.FirewallTable = *cflags.firewall_table
+		case "external-modules":
+			obj.Paths.ExternalModules = *cflags.external_modules
+		case "private-key-file":
+			obj.Paths.PrivateKeyFile = *cflags.private_key_file
+		case "keystore-passphrase-file":
+			obj.Paths.PassphraseFile = *cflags.passphrase_file
+		case "ws-listen":
+			obj.WebsocketServers, err = parseListenerList(*cflags.ws_listen)
+		case "tcp-listen":
+			obj.TcpServers, err = parseListenerList(*cflags.tcp_listen)
+		case "quic-listen":
+			obj.QuicServers, err = parseListenerList(*cflags.quic_listen)
+		case "metrics-listen":
+			obj.MetricsServers, err = parseListenerList(*cflags.metrics_listen)
+		case "ws-max-packages":
+			obj.Limits.WSMaxPackages = uint32(*cflags.ws_max_packages)
+		case "ws-max-bytes":
+			obj.Limits.WSMaxBytes = *cflags.ws_max_bytes
+		case "kernel-buffer-max-packages":
+			obj.Limits.KernelBufferMaxPackages = *cflags.kernel_buffer
+		case "drain-timeout":
+			obj.DrainTimeout = *cflags.drain_timeout
+		case "log-format":
+			obj.Log.Format = *cflags.log_format
+		case "log-level":
+			obj.Log.Level = *cflags.log_level
+		case "log-levels":
+			err = obj.mergeLogLevels(*cflags.log_levels)
+		case "p2p-back-dial":
+			obj.P2P.BackDial = *cflags.p2p_back_dial
+		case "p2p-back-dial-untrusted":
+			obj.P2P.BackDialUntrusted = *cflags.p2p_untrusted
+		case "untrusted-admission":
+			obj.UntrustedRelays.Admission = *cflags.untrusted_mode
+		case "untrusted-max-slots":
+			obj.UntrustedRelays.MaxSlots = *cflags.untrusted_slots
+		case "directory-advertise":
+			obj.Directory.Advertise = parseEndPointList(*cflags.dir_advertise)
+		case "directory-static-file":
+			obj.Directory.StaticFile = *cflags.dir_static_file
+		case "bootstrap-seeds":
+			obj.Bootstrap.Seeds = parseEndPointList(*cflags.bootstrap_seeds)
+		case "bootstrap-target-outbound":
+			obj.Bootstrap.TargetOutbound = *cflags.bootstrap_target
+		}
+	})
+	if err != nil {
+		return fmt.Errorf("readFlags: " + err.Error())
+	}
+	return nil
+}
+
+// Überträgt die Einstellungen in die Statischen Laufzeitwerte
+func (obj *Config) apply() error {
+	// Es wird geprüft ob die Grenzwerte zulässig sind
+	if obj.Limits.WSMaxPackages < 1 || obj.Limits.WSMaxBytes < 1 || obj.Limits.KernelBufferMaxPackages < 1 {
+		return fmt.Errorf("apply: invalid buffer limits, all limits must be greater than zero")
+	}
+
+	// Die Dateipfade werden überschrieben
+	static.SetFilePathFor(static.BASE_CONFIG, obj._path)
+	static.SetFilePathFor(static.API_SOCKET, obj.Paths.APISocket)
+	static.SetFilePathFor(static.TRUSTED_RELAYS, obj.Paths.TrustedRelays)
+	static.SetFilePathFor(static.ROUTING_TABLE, obj.Paths.RoutingTable)
+	static.SetFilePathFor(static.FIREWALL_TABLE, obj.Paths.FirewallTable)
+	static.SetFilePathFor(static.EXTERNAL_MODULES, obj.Paths.ExternalModules)
+	static.SetFilePathFor(static.PRIVATE_KEY_FILE, obj.Paths.PrivateKeyFile)
+	keystore.SetPassphraseFile(obj.Paths.PassphraseFile)
+
+	// Die Grenzwerte werden übernommen
+	static.LIMITS.WSMaxPackages = obj.Limits.WSMaxPackages
+	static.LIMITS.WSMaxBytes = obj.Limits.WSMaxBytes
+	static.LIMITS.KernelBufferMaxPackages = obj.Limits.KernelBufferMaxPackages
+
+	// Der Vorgang wurde ohne Fehler durchgeführt
+	return nil
+}
+
+// Gibt an wie lange beim Herunterfahren (SIGTERM) maximal auf das Leeren der Puffer gewartet wird
+func (obj *Config) getDrainTimeout() time.Duration {
+	return time.Duration(obj.DrainTimeout) * time.Second
+}
+
+// Gibt die Richtlinie für Rückverbindungen zu angekündigten Servern zurück
+func (obj *Config) getP2PBackDialPolicy() kernel.P2PBackDialPolicy {
+	return kernel.P2PBackDialPolicy{Enabled: obj.P2P.BackDial, AllowUntrusted: obj.P2P.BackDialUntrusted}
+}
+
+// Gibt die Richtlinie für die Zulassung nicht Vertrauenswürdiger Relays zurück
+func (obj *Config) getUntrustedRelayPolicy() (kernel.UntrustedRelayPolicy, error) {
+	// Die Zulassungsart wird eingelesen
+	mode, err := kernel.ParseUntrustedAdmissionMode(obj.UntrustedRelays.Admission)
+	if err != nil {
+		return kernel.UntrustedRelayPolicy{}, fmt.Errorf("getUntrustedRelayPolicy: 1: " + err.Error())
+	}
+
+	// Die zugelassenen Relays werden eingelesen
+	allowlist := make([]*btcec.PublicKey, 0, len(obj.UntrustedRelays.Allowlist))
+	for _, item := range obj.UntrustedRelays.Allowlist {
+		pkey, err := utils.ConvertAddressToPublicKey(strings.TrimSpace(item))
+		if err != nil {
+			return kernel.UntrustedRelayPolicy{}, fmt.Errorf("getUntrustedRelayPolicy: 2: " + err.Error())
+		}
+		allowlist = append(allowlist, pkey)
+	}
+
+	// Die Richtlinie wird zurückgegeben
+	return kernel.UntrustedRelayPolicy{Mode: mode, Allowlist: allowlist, MaxSlots: obj.UntrustedRelays.MaxSlots, EvictScore: obj.UntrustedRelays.EvictScore}, nil
+}
+
+// Gibt die Endpunkte zurück, unter welchen das eigene Relay in den Verzeichnissen angekündigt wird
+func (obj *Config) getRelayDescriptorEndPoints() ([]kernel.RelayDescriptorEndPoint, error) {
+	result := make([]kernel.RelayDescriptorEndPoint, 0, len(obj.Directory.Advertise))
+	for _, item := range obj.Directory.Advertise {
+		protocol, module, err := ipoverlay.ParseClientServerP2PEndPoint(strings.TrimSpace(item))
+		if err != nil {
+			return nil, fmt.Errorf("getRelayDescriptorEndPoints: " + err.Error())
+		}
+		result = append(result, kernel.RelayDescriptorEndPoint{Protocol: uint8(protocol), Module: module, EndPoint: strings.TrimSpace(item)})
+	}
+	return result, nil
+}
+
+// Gibt an wie lange die eigene Relay Beschreibung gültig ist
+func (obj *Config) getRelayDescriptorTTL() time.Duration {
+	return time.Duration(obj.Directory.TTL) * time.Second
+}
+
+// Gibt die Richtlinie für das Bootstrappen zurück
+func (obj *Config) getBootstrapPolicy() (kernel.BootstrapPolicy, error) {
+	seeds := make([]kernel.BootstrapPeer, 0, len(obj.Bootstrap.Seeds))
+	for _, item := range obj.Bootstrap.Seeds {
+		// Die Adresse und der Endpunkt werden getrennt
+		address, end_point, found := strings.Cut(strings.TrimSpace(item), "@")
+		if !found {
+			return kernel.BootstrapPolicy{}, fmt.Errorf("getBootstrapPolicy: 1: invalid seed " + item + ", expected address@endpoint")
+		}
+
+		// Die Adresse wird eingelesen
+		pkey, err := utils.ConvertAddressToPublicKey(address)
+		if err != nil {
+			return kernel.BootstrapPolicy{}, fmt.Errorf("getBootstrapPolicy: 2: " + err.Error())
+		}
+
+		// Das Client Modul wird anhand des Endpunktes ermittelt
+		_, module, err := ipoverlay.ParseClientServerP2PEndPoint(end_point)
+		if err != nil {
+			return kernel.BootstrapPolicy{}, fmt.Errorf("getBootstrapPolicy: 3: " + err.Error())
+		}
+		seeds = append(seeds, kernel.BootstrapPeer{PublicKey: pkey, EndPoint: end_point, Protocol: module})
+	}
+	return kernel.BootstrapPolicy{Seeds: seeds, TargetOutbound: obj.Bootstrap.TargetOutbound, AllowPrivateEndPoints: obj.Bootstrap.AllowPrivateEndPoints, ShareTrustedPeers: obj.Bootstrap.ShareTrusted}, nil
+}
+
+// Gibt die Ausführlichkeit der Log Ausgabe zurück
+func (obj *Config) getLogLevels() (slog.Level, map[string]slog.Level, error) {
+	// Der Standardwert wird eingelesen
+	level, err := logging.ParseLevel(obj.Log.Level)
+	if err != nil {
+		return 0, nil, fmt.Errorf("getLogLevels: " + err.Error())
+	}
+
+	// Die Werte der einzelnen Subsysteme werden eingelesen
+	levels := make(map[string]slog.Level)
+	for subsystem, value := range obj.Log.Levels {
+		if !logging.IsSubsystem(subsystem) {
+			return 0, nil, fmt.Errorf("getLogLevels: unkown subsystem " + subsystem)
+		}
+		parsed, err := logging.ParseLevel(value)
+		if err != nil {
+			return 0, nil, fmt.Errorf("getLogLevels: " + err.Error())
+		}
+		levels[subsystem] = parsed
+	}
+
+	// Die Daten werden zurückgegeben
+	return level, levels, nil
+}
+
+// Erstellt die Logging Verwaltung anhand der Einstellungen
+func (obj *Config) newLogManager() (*logging.Manager, error) {
+	// Es wird geprüft ob das Ausgabeformat bekannt ist
+	if obj.Log.Format != "text" && obj.Log.Format != "json" {
+		return nil, fmt.Errorf("newLogManager: unkown log format " + obj.Log.Format)
+	}
+
+	// Die Ausführlichkeit wird eingelesen
+	level, levels, err := obj.getLogLevels()
+	if err != nil {
+		return nil, fmt.Errorf("newLogManager: " + err.Error())
+	}
+
+	// Die Logging Verwaltung wird erstellt
+	return logging.NewManager(logging.Options{Writer: os.Stderr, JSON: obj.Log.Format == "json", Level: level, Levels: levels}), nil
+}
+
+// Ließt die Einstellungen aus der Datei, den Umgebungsvariablen und den Kommandozeilenparametern ein, ohne sie zu übernehmen
+func readConfigs() (*Config, error) {
+	// Es wird geprüft ob die Parameter bereits eingelesen wurden
+	if !flag.Parsed() {
+		flag.Parse()
+	}
+
+	// Es wird ermittelt welche Einstellungsdatei verwendet werden soll,
+	// wurde die Datei ausdrücklich angegeben, muss sie vorhanden sein
+	config_path, required := static.GetFilePathFor(static.BASE_CONFIG), false
+	if value, found := os.LookupEnv("ROUEX_CONFIG"); found && len(value) > 0 {
+		config_path, required = value, true
+	}
+	if len(*_config_flags.config_path) > 0 {
+		config_path, required = *_config_flags.config_path, true
+	}
+
+	// Die Standardeinstellungen werden mit der Datei, den Umgebungsvariablen und den Parametern überschrieben
+	config := defaultConfig()
+	config._path = config_path
+	if err := config.readFile(config_path, required); err != nil {
+		return nil, fmt.Errorf("readConfigs: " + err.Error())
+	}
+	if err := config.readEnv(); err != nil {
+		return nil, fmt.Errorf("readConfigs: " + err.Error())
+	}
+	if err := config.readFlags(flag.CommandLine, _config_flags); err != nil {
+		return nil, fmt.Errorf("readConfigs: " + err.Error())
+	}
+
+	// Die Einstellungen werden zurückgegeben
+	return &config, nil
+}
+
+// Lädt die Einstellungen aus der Datei, den Umgebungsvariablen und den Kommandozeilenparametern
+func loadConfigs() (*Config, error) {
+	// Die Einstellungen werden eingelesen
+	config, err := readConfigs()
+	if err != nil {
+		return nil, fmt.Errorf("loadConfigs: " + err.Error())
+	}
+
+	// Die Einstellungen werden übernommen
+	if err := config.apply(); err != nil {
+		return nil, fmt.Errorf("loadConfigs: " + err.Error())
+	}
+
+	// Die Einstellungen werden zurückgegeben
+	return config, nil
+}
@@ -0,0 +1,110 @@
+package directory
+
+import (
+	"encoding/hex"
+	"fmt"
+	"sync"
+
+	"github.com/BurntSushi/toml"
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/fluffelpuff/RoueX/ipoverlay"
+	"github.com/fluffelpuff/RoueX/kernel"
+	"github.com/fluffelpuff/RoueX/utils"
+)
+
+// Stellt einen Eintrag der Statischen Verzeichnis Datei dar
+type static_directory_entry struct {
+	Address   string   `toml:"address"`
+	EndPoints []string `toml:"endpoints"`
+}
+
+// Stellt den Aufbau der Statischen Verzeichnis Datei dar
+type static_directory_file struct {
+	Relays []static_directory_entry `toml:"relay"`
+}
+
+// Stellt ein Statisches Relay Verzeichnis dar, die Beschreibungen werden aus einer Datei geladen,
+// sie sind nicht Signiert, laufen nicht ab und werden nicht an andere Relays weitergegeben
+type StaticRelayDirectory struct {
+	_path        string
+	_lock        *sync.Mutex
+	_descriptors map[string]*kernel.RelayDescriptor
+}
+
+// Gibt den Namen des Verzeichnisses zurück
+func (obj *StaticRelayDirectory) GetDirectoryName() string {
+	return "static:" + obj._path
+}
+
+// Das Statische Verzeichnis nimmt keine Beschreibungen auf
+func (obj *StaticRelayDirectory) PublishDescriptor(descriptor *kernel.RelayDescriptor) error {
+	return nil
+}
+
+// Gibt die Beschreibung eines Relays zurück
+func (obj *StaticRelayDirectory) LookupDescriptor(pkey *btcec.PublicKey) *kernel.RelayDescriptor {
+	obj._lock.Lock()
+	defer obj._lock.Unlock()
+	return obj._descriptors[hex.EncodeToString(pkey.SerializeCompressed())]
+}
+
+// Gibt alle Beschreibungen des Verzeichnisses zurück
+func (obj *StaticRelayDirectory) ListDescriptors() []*kernel.RelayDescriptor {
+	obj._lock.Lock()
+	defer obj._lock.Unlock()
+	result := make([]*kernel.RelayDescriptor, 0, len(obj._descriptors))
+	for _, descriptor := range obj._descriptors {
+		result = append(result, descriptor)
+	}
+	return result
+}
+
+// Ließt die Verzeichnis Datei erneut ein, bei einem Fehler bleiben die bisherigen Beschreibungen erhalten
+func (obj *StaticRelayDirectory) Reload() error {
+	// Die Datei wird eingelesen
+	var file static_directory_file
+	if _, err := toml.DecodeFile(obj._path, &file); err != nil {
+		return fmt.Errorf("Reload: 1: " + err.Error())
+	}
+
+	// Die Einträge werden in Beschreibungen umgewandelt
+	descriptors := make(map[string]*kernel.RelayDescriptor)
+	for _, entry := range file.Relays {
+		// Die Adresse des Relays wird eingelesen
+		pkey, err := utils.ConvertAddressToPublicKey(entry.Address)
+		if err != nil {
+			return fmt.Errorf("Reload: 2: " + err.Error())
+		}
+
+		// Die Endpunkte werden eingelesen
+		end_points := make([]kernel.RelayDescriptorEndPoint, 0, len(entry.EndPoints))
+		for _, end_point := range entry.EndPoints {
+			protocol, module, err := ipoverlay.ParseClientServerP2PEndPoint(end_point)
+			if err != nil {
+				return fmt.Errorf("Reload: 3: " + entry.Address + ": " + err.Error())
+			}
+			end_points = append(end_points, kernel.RelayDescriptorEndPoint{Protocol: uint8(protocol), Module: module, EndPoint: end_point})
+		}
+
+		// Die Beschreibung wird gespeichert
+		serialized := pkey.SerializeCompressed()
+		descriptors[hex.EncodeToString(serialized)] = &kernel.RelayDescriptor{PublicKey: serialized, EndPoints: end_points, Version: kernel.RELAY_DESCRIPTOR_VERSION}
+	}
+
+	// Die Beschreibungen werden übernommen
+	obj._lock.Lock()
+	obj._descriptors = descriptors
+	obj._lock.Unlock()
+
+	// Der Vorgang wurde ohne Fehler durchgeführt
+	return nil
+}
+
+// Lädt ein Statisches Relay Verzeichnis aus einer Datei
+func LoadStaticRelayDirectory(path string) (*StaticRelayDirectory, error) {
+	result := &StaticRelayDirectory{_path: path, _lock: new(sync.Mutex), _descriptors: make(map[string]*kernel.RelayDescriptor)}
+	if err := result.Reload(); err != nil {
+		return nil, fmt.Errorf("LoadStaticRelayDirectory: " + err.Error())
+	}
+	return result, nil
+}
@@ -1,13 +1,444 @@
 package firewall
 
-import "fmt"
+import (
+	"bytes"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
 
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/fluffelpuff/RoueX/logging"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// Gibt an, was mit einem Paket geschehen soll, auf welches eine Regel zutrifft
+type Action string
+
+// Gibt die Richtung an, in welche ein Paket übertragen wird
+type Direction string
+
+// Definiert alle verfügbaren Aktionen und Richtungen
+const (
+	ALLOW      = Action("allow")
+	DENY       = Action("deny")
+	RATE_LIMIT = Action("rate_limit")
+
+	ANY      = Direction("any")
+	INBOUND  = Direction("in")
+	OUTBOUND = Direction("out")
+)
+
+// Gibt an, dass das Protokoll eines Paketes nicht bekannt ist (z.b. bei Verschlüsselten Paketen)
+const UNKOWN_PROTOCOL int16 = -1
+
+// Stellt eine Firewall Regel dar
+type Rule struct {
+	Id          int64
+	Priority    int64
+	Action      Action
+	Direction   Direction
+	SenderPKey  *btcec.PublicKey
+	ReciverPKey *btcec.PublicKey
+	RelayPKey   *btcec.PublicKey
+	Protocol    int16
+	Rate        uint64
+	Burst       uint64
+	Active      bool
+}
+
+// Stellt die Informationen eines Paketes oder einer Verbindung dar, welche geprüft werden sollen
+type PackageContext struct {
+	Direction Direction
+	Sender    *btcec.PublicKey
+	Reciver   *btcec.PublicKey
+	Relay     *btcec.PublicKey
+	Protocol  int16
+	Handshake bool
+}
+
+// Gibt an, wie lange ein voller Token Bucket unbenutzt bleiben muss, bis er entfernt wird
+const RATE_BUCKET_IDLE_TTL = 10 * time.Minute
+
+// Stellt einen Token Bucket für Regeln mit einer Ratenbegrenzung dar
+type rate_bucket struct {
+	rule_id int64
+	tokens  float64
+	rate    float64
+	burst   float64
+	last    time.Time
+}
+
+// Gibt an ob der Bucket seit mindestens 'ttl' unbenutzt ist und bis jetzt wieder vollständig aufgefüllt wäre,
+// ein solcher Bucket kann entfernt werden, da er beim nächsten Paket wieder voll erzeugt wird
+func (obj *rate_bucket) is_idle(now time.Time, ttl time.Duration) bool {
+	idle := now.Sub(obj.last)
+	return idle >= ttl && obj.tokens+idle.Seconds()*obj.rate >= obj.burst
+}
+
+// Stellt die Firewall dar
 type Firewall struct {
+	_lock       *sync.Mutex
+	_db         *sql.DB
+	_rules      []*Rule
+	_buckets    map[string]*rate_bucket
+	_last_purge time.Time
+	_droped     uint64
+}
+
+// Entfernt alle unbenutzten vollen Token Buckets, muss mit Threadlock aufgerufen werden
+func (obj *Firewall) _purge_buckets(now time.Time) {
+	for key, bucket := range obj._buckets {
+		if bucket.is_idle(now, RATE_BUCKET_IDLE_TTL) {
+			delete(obj._buckets, key)
+		}
+	}
+	obj._last_purge = now
+}
+
+// Gibt an ob ein Öffentlicher Schlüssel einer Regel mit dem Schlüssel eines Paketes übereinstimmt
+func _pkey_matches(rule_key *btcec.PublicKey, package_key *btcec.PublicKey) bool {
+	// Sollte die Regel keinen Schlüssel vorgeben, trifft sie immer zu
+	if rule_key == nil {
+		return true
+	}
+
+	// Sollte das Paket keinen Schlüssel besitzen, trifft die Regel nicht zu
+	if package_key == nil {
+		return false
+	}
+
+	// Die Schlüssel werden verglichen
+	return bytes.Equal(rule_key.SerializeCompressed(), package_key.SerializeCompressed())
+}
+
+// Gibt an ob eine Regel auf ein Paket zutrifft
+func (obj *Rule) matches(ctx *PackageContext) bool {
+	// Deaktivierte Regeln werden übersprungen
+	if !obj.Active {
+		return false
+	}
+
+	// Es wird geprüft ob die Richtung übereinstimmt
+	if obj.Direction != ANY && obj.Direction != ctx.Direction {
+		return false
+	}
+
+	// Bei einem Verbindungsaufbau sind nur Regeln zulässig, welche sich auf das Relay beziehen
+	if ctx.Handshake && (obj.SenderPKey != nil || obj.ReciverPKey != nil || obj.Protocol != UNKOWN_PROTOCOL) {
+		return false
+	}
+
+	// Es wird geprüft ob das Protokoll übereinstimmt
+	if obj.Protocol != UNKOWN_PROTOCOL && obj.Protocol != ctx.Protocol {
+		return false
+	}
+
+	// Es wird geprüft ob die Schlüssel übereinstimmen
+	return _pkey_matches(obj.SenderPKey, ctx.Sender) && _pkey_matches(obj.ReciverPKey, ctx.Reciver) && _pkey_matches(obj.RelayPKey, ctx.Relay)
+}
+
+// Prüft ob eine Ratenbegrenzte Regel ein weiteres Paket zulässt, muss mit Threadlock aufgerufen werden
+func (obj *Firewall) _take_rate_token(rule *Rule, ctx *PackageContext) bool {
+	// Der Bucket wird pro Regel und Absender geführt
+	bucket_key := fmt.Sprintf("%d", rule.Id)
+	if ctx.Sender != nil {
+		bucket_key += ":" + hex.EncodeToString(ctx.Sender.SerializeCompressed())
+	} else if ctx.Relay != nil {
+		bucket_key += ":" + hex.EncodeToString(ctx.Relay.SerializeCompressed())
+	}
+
+	// Die Maximale Anzahl an Token wird ermittelt
+	burst := float64(rule.Burst)
+	if burst < float64(rule.Rate) {
+		burst = float64(rule.Rate)
+	}
+
+	// Unbenutzte Buckets werden in regelmäßigen Abständen entfernt
+	c_time := time.Now()
+	if c_time.Sub(obj._last_purge) >= RATE_BUCKET_IDLE_TTL {
+		obj._purge_buckets(c_time)
+	}
+
+	// Der Bucket wird abgerufen oder erzeugt
+	bucket, found := obj._buckets[bucket_key]
+	if !found {
+		bucket = &rate_bucket{rule_id: rule.Id, tokens: burst, last: c_time}
+		obj._buckets[bucket_key] = bucket
+	}
+	bucket.rate, bucket.burst = float64(rule.Rate), burst
+
+	// Die seit dem letzten Paket hinzugekommenen Token werden gutgeschrieben
+	bucket.tokens += c_time.Sub(bucket.last).Seconds() * float64(rule.Rate)
+	if bucket.tokens > burst {
+		bucket.tokens = burst
+	}
+	bucket.last = c_time
+
+	// Es wird geprüft ob noch ein Token vorhanden ist
+	if bucket.tokens < 1 {
+		return false
+	}
+	bucket.tokens--
+	return true
+}
+
+// Prüft ob ein Paket die Firewall passieren darf
+func (obj *Firewall) CheckPackage(ctx *PackageContext) bool {
+	// Der Threadlock wird verwendet
+	obj._lock.Lock()
+	defer obj._lock.Unlock()
+
+	// Die Regeln werden nach ihrer Priorität abgearbeitet, die erste Passende Regel entscheidet
+	for _, rule := range obj._rules {
+		if !rule.matches(ctx) {
+			continue
+		}
+
+		// Es wird geprüft was mit dem Paket geschehen soll
+		allowed := false
+		switch rule.Action {
+		case ALLOW:
+			allowed = true
+		case RATE_LIMIT:
+			allowed = obj._take_rate_token(rule, ctx)
+		}
+
+		// Sollte das Paket verworfen werden, wird es gezählt
+		if !allowed {
+			obj._droped++
+		}
+
+		// Das Ergebniss wird zurückgegeben
+		return allowed
+	}
+
+	// Sollte keine Regel zutreffen, wird das Paket zugelassen
+	return true
+}
+
+// Prüft ob ein Eingehender Verbindungsaufbau eines Relays zugelassen ist
+func (obj *Firewall) CheckInboundHandshake(relay_pkey *btcec.PublicKey) bool {
+	return obj.CheckPackage(&PackageContext{Direction: INBOUND, Relay: relay_pkey, Protocol: UNKOWN_PROTOCOL, Handshake: true})
+}
+
+// Gibt an, wieviele Pakete durch die Firewall verworfen wurden
+func (obj *Firewall) GetTotalDropedPackages() uint64 {
+	obj._lock.Lock()
+	defer obj._lock.Unlock()
+	return obj._droped
+}
+
+// Gibt alle Regeln der Firewall zurück
+func (obj *Firewall) GetAllRules() []*Rule {
+	obj._lock.Lock()
+	defer obj._lock.Unlock()
+	result := make([]*Rule, len(obj._rules))
+	copy(result, obj._rules)
+	return result
+}
+
+// Wandelt einen Öffentlichen Schlüssel in einen Datenbankwert um
+func _pkey_to_db(pkey *btcec.PublicKey) string {
+	if pkey == nil {
+		return ""
+	}
+	return hex.EncodeToString(pkey.SerializeCompressed())
 }
 
+// Ließt einen Öffentlichen Schlüssel aus einem Datenbankwert ein
+func _pkey_from_db(value string) (*btcec.PublicKey, error) {
+	if len(value) == 0 {
+		return nil, nil
+	}
+	decoded, err := hex.DecodeString(value)
+	if err != nil {
+		return nil, err
+	}
+	return btcec.ParsePubKey(decoded)
+}
+
+// Sortiert die Regeln nach ihrer Priorität, muss mit Threadlock aufgerufen werden
+func (obj *Firewall) _sort_rules() {
+	sort.SliceStable(obj._rules, func(i, j int) bool {
+		if obj._rules[i].Priority == obj._rules[j].Priority {
+			return obj._rules[i].Id < obj._rules[j].Id
+		}
+		return obj._rules[i].Priority < obj._rules[j].Priority
+	})
+}
+
+// Fügt eine neue Regel hinzu und speichert sie in der Datenbank
+func (obj *Firewall) AddRule(rule Rule) (int64, error) {
+	// Es wird geprüft ob es sich um eine zulässige Aktion handelt
+	if rule.Action != ALLOW && rule.Action != DENY && rule.Action != RATE_LIMIT {
+		return -1, fmt.Errorf("AddRule: 1: unkown action")
+	}
+
+	// Es wird geprüft ob es sich um eine zulässige Richtung handelt
+	if rule.Direction != ANY && rule.Direction != INBOUND && rule.Direction != OUTBOUND {
+		return -1, fmt.Errorf("AddRule: 2: unkown direction")
+	}
+
+	// Der Threadlock wird verwendet
+	obj._lock.Lock()
+	defer obj._lock.Unlock()
+
+	// Die Regel wird in die Datenbank geschrieben
+	active := 0
+	if rule.Active {
+		active = 1
+	}
+	res, err := obj._db.Exec(`INSERT INTO rules (priority, action, direction, sender_pkey, reciver_pkey, relay_pkey, protocol, rate, burst, active) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		rule.Priority, string(rule.Action), string(rule.Direction), _pkey_to_db(rule.SenderPKey), _pkey_to_db(rule.ReciverPKey), _pkey_to_db(rule.RelayPKey), rule.Protocol, rule.Rate, rule.Burst, active)
+	if err != nil {
+		return -1, fmt.Errorf("AddRule: 3: " + err.Error())
+	}
+	rule_id, err := res.LastInsertId()
+	if err != nil {
+		return -1, fmt.Errorf("AddRule: 4: " + err.Error())
+	}
+
+	// Die Regel wird zwischengespeichert
+	rule.Id = rule_id
+	obj._rules = append(obj._rules, &rule)
+	obj._sort_rules()
+
+	// Log
+	logging.Logger(logging.FIREWALL).Info("Firewall: new rule added", "rule", rule_id, "action", rule.Action, "direction", rule.Direction)
+
+	// Die ID der Regel wird zurückgegeben
+	return rule_id, nil
+}
+
+// Entfernt eine Regel
+func (obj *Firewall) RemoveRule(rule_id int64) error {
+	// Der Threadlock wird verwendet
+	obj._lock.Lock()
+	defer obj._lock.Unlock()
+
+	// Die Regel wird aus der Datenbank entfernt
+	if _, err := obj._db.Exec("DELETE FROM rules WHERE rule_id = ?", rule_id); err != nil {
+		return fmt.Errorf("RemoveRule: " + err.Error())
+	}
+
+	// Die Regel wird aus dem Zwischenspeicher entfernt
+	for i := range obj._rules {
+		if obj._rules[i].Id == rule_id {
+			obj._rules = append(obj._rules[:i], obj._rules[i+1:]...)
+			break
+		}
+	}
+
+	// Die Token Buckets der Regel werden entfernt
+	for key, bucket := range obj._buckets {
+		if bucket.rule_id == rule_id {
+			delete(obj._buckets, key)
+		}
+	}
+
+	// Log
+	logging.Logger(logging.FIREWALL).Info("Firewall: rule removed", "rule", rule_id)
+	return nil
+}
+
+// Wird verwendet um die Firewall herunterzufahren
+func (obj *Firewall) Shutdown() {
+	logging.Logger(logging.FIREWALL).Info("Firewall: shutingdown firewall table")
+	obj._lock.Lock()
+	obj._db.Close()
+	obj._lock.Unlock()
+}
+
+// Lädt die Firewall Tabelle, sollte die Tabelle nicht vorhanden sein, wird sie erzeugt
 func LoadFirewallTable(path string) (*Firewall, error) {
-	// Es wird geprüft ob die Datei vorhanden ist, wenn nicht wird sie erzeugt mit allen Standard Tabellen
+	// Es wird versucht die SQLite Datei zu laden
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		return nil, err
+	}
+
+	// Log
+	logging.Logger(logging.FIREWALL).Info("Firewall: loading firewall database", "path", path)
+
+	// Die Anzahl der Tabellen mit dem Namen rules wird abgerufen
+	var count int
+	err = db.QueryRow("SELECT COUNT(*) FROM sqlite_master  WHERE type='table' AND name='rules'").Scan(&count)
+	if err != nil {
+		return nil, err
+	}
+
+	// Sollten mehr als eine Tabelle mit diesem Namen vorhanden sein, wird ein Fheler ausgelöst
+	if count != 1 && count != 0 {
+		return nil, fmt.Errorf("LoadFirewallTable: invalid firewall database file, hard panic")
+	}
+
+	// Sollte die Tabelle nicht vorhanden sein, wird sie hinzugefügt
+	// sollte die Tabelle jedoch bereits vorhanden sein, so werden alle Regeln abgerufen
+	rules := make([]*Rule, 0)
+	if count == 0 {
+		_, err = db.Exec(`CREATE TABLE "rules" (
+			"rule_id"	INTEGER UNIQUE,
+			"priority"	INTEGER DEFAULT 100,
+			"action"	TEXT,
+			"direction"	TEXT DEFAULT 'any',
+			"sender_pkey"	TEXT DEFAULT '',
+			"reciver_pkey"	TEXT DEFAULT '',
+			"relay_pkey"	TEXT DEFAULT '',
+			"protocol"	INTEGER DEFAULT -1,
+			"rate"	INTEGER DEFAULT 0,
+			"burst"	INTEGER DEFAULT 0,
+			"active"	INTEGER DEFAULT 1,
+			PRIMARY KEY("rule_id" AUTOINCREMENT)
+		);`)
+		if err != nil {
+			return nil, err
+		}
+		logging.Logger(logging.FIREWALL).Info("Firewall: new firewall database created", "path", path)
+	} else {
+		// Es werden alle Verfügabren Regeln abgerufen
+		rows, err := db.Query("SELECT rule_id, priority, action, direction, sender_pkey, reciver_pkey, relay_pkey, protocol, rate, burst, active FROM rules")
+		if err != nil {
+			return nil, err
+		}
+		defer rows.Close()
+
+		// Die Einzelnen Regeln werden abgearbeitet
+		for rows.Next() {
+			// Die Daten der Aktuellen Regel werden ausgelesen
+			var rule Rule
+			var action, direction, sender_pkey, reciver_pkey, relay_pkey string
+			var active int64
+			err := rows.Scan(&rule.Id, &rule.Priority, &action, &direction, &sender_pkey, &reciver_pkey, &relay_pkey, &rule.Protocol, &rule.Rate, &rule.Burst, &active)
+			if err != nil {
+				return nil, err
+			}
+			rule.Action, rule.Direction, rule.Active = Action(action), Direction(direction), active == 1
+
+			// Es werden die Öffentlichen Schlüssel eingelesen
+			if rule.SenderPKey, err = _pkey_from_db(sender_pkey); err != nil {
+				return nil, fmt.Errorf("LoadFirewallTable: rule %d: %s", rule.Id, err.Error())
+			}
+			if rule.ReciverPKey, err = _pkey_from_db(reciver_pkey); err != nil {
+				return nil, fmt.Errorf("LoadFirewallTable: rule %d: %s", rule.Id, err.Error())
+			}
+			if rule.RelayPKey, err = _pkey_from_db(relay_pkey); err != nil {
+				return nil, fmt.Errorf("LoadFirewallTable: rule %d: %s", rule.Id, err.Error())
+			}
+
+			// Die Regel wird zwischengespeichert
+			rules = append(rules, &rule)
+		}
+
+		logging.Logger(logging.FIREWALL).Info("Firewall: rules from database loaded", "total", len(rules), "path", path)
+	}
+
+	// Das Firewall Objekt wird erstellt und die Regeln werden sortiert
+	result := &Firewall{_lock: new(sync.Mutex), _db: db, _rules: rules, _buckets: make(map[string]*rate_bucket), _last_purge: time.Now()}
+	result._sort_rules()
 
-	fmt.Printf("Loading Firewall database from %s\n", path)
-	return nil, nil
+	// Die Daten werden ohne Fehler zurückgegeben
+	return result, nil
 }
@@ -0,0 +1,204 @@
+package firewall
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+)
+
+// Erzeugt einen neuen Öffentlichen Schlüssel für die Tests
+func newTestKey(t *testing.T) *btcec.PublicKey {
+	t.Helper()
+	priv, err := btcec.NewPrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return priv.PubKey()
+}
+
+// Lädt eine leere Firewall aus einem Temporären Verzeichnis
+func newTestFirewall(t *testing.T) *Firewall {
+	t.Helper()
+	fw, err := LoadFirewallTable(filepath.Join(t.TempDir(), "firewall.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(fw.Shutdown)
+	return fw
+}
+
+func TestRuleMatches(t *testing.T) {
+	sender, reciver, relay := newTestKey(t), newTestKey(t), newTestKey(t)
+	ctx := &PackageContext{Direction: INBOUND, Sender: sender, Reciver: reciver, Relay: relay, Protocol: 3}
+
+	tests := []struct {
+		name string
+		rule Rule
+		want bool
+	}{
+		{"any", Rule{Direction: ANY, Protocol: UNKOWN_PROTOCOL, Active: true}, true},
+		{"inactive", Rule{Direction: ANY, Protocol: UNKOWN_PROTOCOL, Active: false}, false},
+		{"direction", Rule{Direction: OUTBOUND, Protocol: UNKOWN_PROTOCOL, Active: true}, false},
+		{"protocol", Rule{Direction: INBOUND, Protocol: 3, Active: true}, true},
+		{"other protocol", Rule{Direction: INBOUND, Protocol: 4, Active: true}, false},
+		{"sender", Rule{Direction: ANY, SenderPKey: sender, Protocol: UNKOWN_PROTOCOL, Active: true}, true},
+		{"other sender", Rule{Direction: ANY, SenderPKey: reciver, Protocol: UNKOWN_PROTOCOL, Active: true}, false},
+		{"reciver and relay", Rule{Direction: ANY, ReciverPKey: reciver, RelayPKey: relay, Protocol: UNKOWN_PROTOCOL, Active: true}, true},
+		{"other relay", Rule{Direction: ANY, RelayPKey: sender, Protocol: UNKOWN_PROTOCOL, Active: true}, false},
+	}
+	for _, tt := range tests {
+		if got := tt.rule.matches(ctx); got != tt.want {
+			t.Errorf("%s: matches() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+
+	// Ein Schlüssel der Regel trifft nicht zu, wenn das Paket keinen Schlüssel besitzt
+	rule := Rule{Direction: ANY, RelayPKey: relay, Protocol: UNKOWN_PROTOCOL, Active: true}
+	if rule.matches(&PackageContext{Direction: INBOUND, Protocol: UNKOWN_PROTOCOL}) {
+		t.Error("relay rule matched package without relay")
+	}
+}
+
+func TestRuleMatchesHandshake(t *testing.T) {
+	relay := newTestKey(t)
+	ctx := &PackageContext{Direction: INBOUND, Relay: relay, Protocol: UNKOWN_PROTOCOL, Handshake: true}
+
+	// Bei einem Verbindungsaufbau treffen nur Regeln zu, welche sich auf das Relay beziehen
+	if !(&Rule{Direction: INBOUND, RelayPKey: relay, Protocol: UNKOWN_PROTOCOL, Active: true}).matches(ctx) {
+		t.Error("relay rule did not match handshake")
+	}
+	if (&Rule{Direction: INBOUND, SenderPKey: relay, Protocol: UNKOWN_PROTOCOL, Active: true}).matches(ctx) {
+		t.Error("sender rule matched handshake")
+	}
+	if (&Rule{Direction: INBOUND, Protocol: 0, Active: true}).matches(ctx) {
+		t.Error("protocol rule matched handshake")
+	}
+}
+
+func TestCheckPackagePriority(t *testing.T) {
+	fw := newTestFirewall(t)
+	sender := newTestKey(t)
+
+	// Ohne Regeln werden alle Pakete zugelassen
+	ctx := &PackageContext{Direction: INBOUND, Sender: sender, Protocol: 1}
+	if !fw.CheckPackage(ctx) {
+		t.Fatal("package droped without rules")
+	}
+
+	// Die Regel mit der niedrigeren Priorität entscheidet
+	deny_id, err := fw.AddRule(Rule{Priority: 100, Action: DENY, Direction: ANY, Protocol: UNKOWN_PROTOCOL, Active: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fw.AddRule(Rule{Priority: 10, Action: ALLOW, Direction: INBOUND, SenderPKey: sender, Protocol: UNKOWN_PROTOCOL, Active: true}); err != nil {
+		t.Fatal(err)
+	}
+	if !fw.CheckPackage(ctx) {
+		t.Error("allow rule with higher priority was not used")
+	}
+	if fw.CheckPackage(&PackageContext{Direction: INBOUND, Sender: newTestKey(t), Protocol: 1}) {
+		t.Error("package of other sender was not denied")
+	}
+	if got := fw.GetTotalDropedPackages(); got != 1 {
+		t.Errorf("GetTotalDropedPackages() = %d, want 1", got)
+	}
+
+	// Nach dem Entfernen der Regel werden die Pakete wieder zugelassen
+	if err := fw.RemoveRule(deny_id); err != nil {
+		t.Fatal(err)
+	}
+	if !fw.CheckPackage(&PackageContext{Direction: INBOUND, Sender: newTestKey(t), Protocol: 1}) {
+		t.Error("package droped after deny rule was removed")
+	}
+	if got := len(fw.GetAllRules()); got != 1 {
+		t.Errorf("len(GetAllRules()) = %d, want 1", got)
+	}
+}
+
+func TestAddRuleValidation(t *testing.T) {
+	fw := newTestFirewall(t)
+	if _, err := fw.AddRule(Rule{Action: Action("drop"), Direction: ANY, Protocol: UNKOWN_PROTOCOL}); err == nil {
+		t.Error("unkown action accepted")
+	}
+	if _, err := fw.AddRule(Rule{Action: ALLOW, Direction: Direction("both"), Protocol: UNKOWN_PROTOCOL}); err == nil {
+		t.Error("unkown direction accepted")
+	}
+}
+
+func TestRulesPersisted(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "firewall.db")
+	fw, err := LoadFirewallTable(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	relay := newTestKey(t)
+	rule_id, err := fw.AddRule(Rule{Priority: 5, Action: DENY, Direction: INBOUND, RelayPKey: relay, Protocol: 2, Active: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	fw.Shutdown()
+
+	// Die Regel wird beim erneuten Laden wiederhergestellt
+	reloaded, err := LoadFirewallTable(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reloaded.Shutdown()
+	rules := reloaded.GetAllRules()
+	if len(rules) != 1 {
+		t.Fatalf("len(GetAllRules()) = %d, want 1", len(rules))
+	}
+	if rules[0].Id != rule_id || rules[0].Action != DENY || rules[0].Direction != INBOUND || rules[0].Protocol != 2 || !rules[0].Active || !rules[0].RelayPKey.IsEqual(relay) {
+		t.Errorf("reloaded rule = %+v", rules[0])
+	}
+}
+
+func TestRateLimitTokenBucket(t *testing.T) {
+	fw := newTestFirewall(t)
+	rule := &Rule{Id: 1, Action: RATE_LIMIT, Direction: ANY, Protocol: UNKOWN_PROTOCOL, Rate: 1, Burst: 3, Active: true}
+	sender, other := newTestKey(t), newTestKey(t)
+	ctx := &PackageContext{Direction: INBOUND, Sender: sender, Protocol: UNKOWN_PROTOCOL}
+
+	// Der Bucket ist zu Beginn voll, danach ist kein Token mehr vorhanden
+	for i := 0; i < 3; i++ {
+		if !fw._take_rate_token(rule, ctx) {
+			t.Fatalf("token %d was not available", i)
+		}
+	}
+	if fw._take_rate_token(rule, ctx) {
+		t.Fatal("token available after burst was used")
+	}
+
+	// Jeder Absender besitzt einen eigenen Bucket
+	if !fw._take_rate_token(rule, &PackageContext{Direction: INBOUND, Sender: other, Protocol: UNKOWN_PROTOCOL}) {
+		t.Error("bucket of other sender was empty")
+	}
+
+	// Die Token werden mit der Rate wieder aufgefüllt, aber nie über die Burst Größe hinaus
+	bucket := fw._buckets["1:"+_pkey_to_db(sender)]
+	bucket.last = bucket.last.Add(-10 * time.Second)
+	for i := 0; i < 3; i++ {
+		if !fw._take_rate_token(rule, ctx) {
+			t.Fatalf("token %d was not refilled", i)
+		}
+	}
+	if fw._take_rate_token(rule, ctx) {
+		t.Error("bucket was refilled above burst")
+	}
+}
+
+func TestPurgeIdleBuckets(t *testing.T) {
+	fw := newTestFirewall(t)
+	now := time.Now()
+	fw._buckets["idle"] = &rate_bucket{tokens: 1, rate: 1, burst: 5, last: now.Add(-RATE_BUCKET_IDLE_TTL)}
+	fw._buckets["active"] = &rate_bucket{tokens: 0, rate: 1, burst: 5, last: now.Add(-time.Second)}
+	fw._purge_buckets(now)
+	if _, found := fw._buckets["idle"]; found {
+		t.Error("idle bucket was not removed")
+	}
+	if _, found := fw._buckets["active"]; !found {
+		t.Error("active bucket was removed")
+	}
+}
@@ -0,0 +1,393 @@
+package ipoverlay
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/fluffelpuff/RoueX/kernel"
+	"github.com/fluffelpuff/RoueX/logging"
+	"github.com/fluffelpuff/RoueX/static"
+	"github.com/fluffelpuff/RoueX/utils"
+	"github.com/fxamacker/cbor"
+)
+
+// Führt den Serverseitigen Handshake auf einer eingehenden Verbindung durch, die Verbindung wird im Kernel registriert,
+// das empfangene ClientHello Paket wird zurückgegeben, damit das Server Modul die Flags verarbeiten kann
+func acceptIncommingRelayConnection(k *kernel.Kernel, conn packet_conn, protocol string, end_point string) (result *EncryptedClientHelloPackage, err error) {
+	// Ein fehlgeschlagener Handshake wird in den Metriken des Kernels vermerkt
+	defer func() {
+		if err != nil {
+			k.RecordHandshakeFailure(kernel.INBOUND, protocol)
+		}
+	}()
+
+	// Die Aktuelle Zeit wird ermittelt
+	c_time := time.Now()
+
+	// Die Lokale und die Remote Socket Adresse wird ermittelt
+	remote_sock_adr := conn.RemoteAddr()
+	local_sock_adr := conn.LocalAddr()
+
+	// Es wird Maximal 'HELLO_READ_TIMEOUT' auf das ClientHello Paket gewartet, die Größe ist durch 'MAX_HELLO_FRAME_SIZE' begrenzt
+	if err := conn.SetReadDeadline(time.Now().Add(HELLO_READ_TIMEOUT)); err != nil {
+		return nil, fmt.Errorf("acceptIncommingRelayConnection: 1: " + err.Error())
+	}
+
+	// Es wird auf das eintreffende Paket gewartet
+	message, err := conn.ReadPacket()
+	if err != nil {
+		return nil, fmt.Errorf("acceptIncommingRelayConnection: 2: " + err.Error())
+	}
+
+	// Das Reading Timeout wird entfernt
+	if err := conn.SetReadDeadline(time.Time{}); err != nil {
+		return nil, fmt.Errorf("acceptIncommingRelayConnection: 3: " + err.Error())
+	}
+
+	// Es wird versucht das Paket mit dem Lokalen Schlüssel zu entschlüsseln
+	decrypted, err := k.DecryptWithPrivateRelayKey(message)
+	if err != nil {
+		return nil, fmt.Errorf("acceptIncommingRelayConnection: 4: " + err.Error())
+	}
+
+	// Es wird versucht den Datensatz wieder einzulesen
+	var decrypted_chpackage EncryptedClientHelloPackage
+	if err := cbor.Unmarshal(decrypted, &decrypted_chpackage); err != nil {
+		return nil, fmt.Errorf("acceptIncommingRelayConnection: 5: " + err.Error())
+	}
+
+	// Es wird geprüft ob der Schlüssel des Aktuellen Relays mit dem Angeforderten übereinstiemmt
+	if !bytes.Equal(k.GetPublicKey().SerializeCompressed(), decrypted_chpackage.PublicServerKey) {
+		return nil, fmt.Errorf("acceptIncommingRelayConnection: 6: unkown server key requested")
+	}
+
+	// Es wird versucht die Öffentlichen Schlüssel einzulesen
+	pub_client_key, err := utils.ReadPublicKeyFromByteSlice(decrypted_chpackage.PublicClientKey)
+	if err != nil {
+		return nil, fmt.Errorf("acceptIncommingRelayConnection: 7: " + err.Error())
+	}
+	pub_client_otk_key, err := utils.ReadPublicKeyFromByteSlice(decrypted_chpackage.RandClientPKey)
+	if err != nil {
+		return nil, fmt.Errorf("acceptIncommingRelayConnection: 8: " + err.Error())
+	}
+
+	// Der Hash zum überprüfen der Signatur wird erstellt
+	sign_hash := utils.ComputeSha3256Hash(decrypted_chpackage.PublicServerKey, decrypted_chpackage.RandClientPKey)
+
+	// Es wird geprüft ob die Signatur korrekt ist
+	check, err := utils.VerifyByBytes(pub_client_key, decrypted_chpackage.ClientSig, sign_hash)
+	if err != nil {
+		return nil, fmt.Errorf("acceptIncommingRelayConnection: 9: " + err.Error())
+	}
+	if !check {
+		return nil, fmt.Errorf("acceptIncommingRelayConnection: 10: invalid client signature")
+	}
+
+	// Es wird geprüft ob die Firewall den Verbindungsaufbau mit diesem Relay zulässt
+	if !k.FirewallAllowInboundRelay(pub_client_key) {
+		return nil, fmt.Errorf("acceptIncommingRelayConnection: 11: relay blocked by firewall")
+	}
+
+	// Das Relay wurde authentifiziert, ab jetzt sind Frames bis zur vollen Größe zulässig
+	conn.SetPacketSizeLimit(MAX_FRAME_SIZE)
+
+	// Es wird ein Temporäres Schlüsselpaar erstellt
+	key_pair_id, err := k.CreateNewTempKeyPair()
+	if err != nil {
+		return nil, fmt.Errorf("acceptIncommingRelayConnection: 12: " + err.Error())
+	}
+
+	// Der Öffentliche Schlüssel wird abgerufen
+	temp_public_key, err := k.GetPublicTempKeyById(key_pair_id)
+	if err != nil {
+		return nil, fmt.Errorf("acceptIncommingRelayConnection: 13: " + err.Error())
+	}
+
+	// Es wird ein Hash zum signieren erstellt 'SHA3_256(decoded_pkey || temp_public_key)'
+	serve_sign_hash := utils.ComputeSha3256Hash(decrypted_chpackage.PublicClientKey, temp_public_key.SerializeCompressed(), k.GetPublicKey().SerializeCompressed())
+
+	// Der Hash wird mit dem Relay Schlüssel des Aktuellen Relays Signiert
+	relay_signature, err := k.SignWithRelayKey(serve_sign_hash)
+	if err != nil {
+		return nil, fmt.Errorf("acceptIncommingRelayConnection: 14: " + err.Error())
+	}
+
+	// Der Hash wird mit dem Temprären Schlüssel signiert
+	temp_key_signature, err := k.SignWithTempKeyId(key_pair_id, sign_hash)
+	if err != nil {
+		return nil, fmt.Errorf("acceptIncommingRelayConnection: 15: " + err.Error())
+	}
+
+	// Das Antwortpaket wird gebaut
+	plain_server_hello_package := EncryptedServerHelloPackage{
+		PublicServerKey:   k.GetPublicKey().SerializeCompressed(),
+		PublicClientKey:   decrypted_chpackage.PublicClientKey,
+		RandServerPKey:    temp_public_key.SerializeCompressed(),
+		ServerSig:         relay_signature,
+		RandServerPKeySig: temp_key_signature,
+	}
+
+	// Das Paket wird in Bytes umgewandelt
+	byted, err := cbor.Marshal(plain_server_hello_package, cbor.EncOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("acceptIncommingRelayConnection: 16: " + err.Error())
+	}
+
+	// Die Daten werden mit dem Öffentlichen Schlüssel der gegenseite verschlüsselt
+	encrypted_package, err := utils.EncryptECIESPublicKey(pub_client_key, byted)
+	if err != nil {
+		return nil, fmt.Errorf("acceptIncommingRelayConnection: 17: " + err.Error())
+	}
+
+	// Es wird geprüft ob es sich um einen bekannten Relay handelt
+	relay_obj, err := k.GetTrustedRelayByPublicKey(pub_client_key)
+	if err != nil {
+		return nil, fmt.Errorf("acceptIncommingRelayConnection: 18: " + err.Error())
+	}
+
+	// Solte kein Vertrauenswürdiger Relay vorhanden sein, wird das Relay einer bereits bestehenden Verbindung verwendet,
+	// sollte keine Verbindung bestehen wird ein Temporärer Relay erzeugt
+	if relay_obj == nil {
+		relay_obj = k.GetConnectedRelayByPublicKey(pub_client_key)
+	}
+	if relay_obj == nil {
+		relay_obj = kernel.NewUntrustedRelay(pub_client_key, time.Now().Unix(), end_point, protocol)
+	}
+
+	// Es wird ein ECDH Schlüssel für die OTK Schlüssel beider Relays erstellt
+	otk_ecdh_key, err := k.CreateOTKECDHKey(key_pair_id, pub_client_otk_key)
+	if err != nil {
+		return nil, fmt.Errorf("acceptIncommingRelayConnection: 19: " + err.Error())
+	}
+
+	// Die Daten werden übermittelt
+	if err := conn.WritePacket(encrypted_package); err != nil {
+		return nil, fmt.Errorf("acceptIncommingRelayConnection: 20: " + err.Error())
+	}
+
+	// Zeitdifferenz berechnen
+	total_ts_time := time.Until(c_time).Seconds()
+
+	// Bandbreite berechnen
+	bandwith_kbs := float64(float64(len(message))/total_ts_time) / 1024
+
+	// Das Verbindungsobjekt wird erstellt
+	conn_obj, err := createFinallyKernelConnection(conn, protocol, key_pair_id, pub_client_key, pub_client_otk_key, otk_ecdh_key, bandwith_kbs, uint64(total_ts_time), kernel.INBOUND, local_sock_adr, remote_sock_adr)
+	if err != nil {
+		return nil, fmt.Errorf("acceptIncommingRelayConnection: 21: " + err.Error())
+	}
+
+	// Die Verbindung wird registriert
+	if err := k.AddNewConnection(relay_obj, conn_obj); err != nil {
+		return nil, fmt.Errorf("acceptIncommingRelayConnection: 22: " + err.Error())
+	}
+
+	// Die Verbindung wird final fertigestellt
+	if err := conn_obj.FinallyInit(); err != nil {
+		k.RemoveConnection(conn_obj)
+		return nil, fmt.Errorf("acceptIncommingRelayConnection: 23: " + err.Error())
+	}
+
+	// Das ClientHello Paket wird zurückgegeben
+	return &decrypted_chpackage, nil
+}
+
+// Führt den Clientseitigen Handshake auf einer ausgehenden Verbindung durch, die Verbindung wird im Kernel registriert
+func establishOutgoingRelayConnection(k *kernel.Kernel, conn packet_conn, protocol string, end_point string, pub_key *btcec.PublicKey) (result kernel.RelayConnection, err error) {
+	// Ein fehlgeschlagener Handshake wird in den Metriken des Kernels vermerkt
+	defer func() {
+		if err != nil {
+			k.RecordHandshakeFailure(kernel.OUTBOUND, protocol)
+		}
+	}()
+
+	// Die Lokale und die Remote Socket Adresse wird ermittelt
+	remote_sock_adr := conn.RemoteAddr()
+	local_sock_adr := conn.LocalAddr()
+
+	// Es wird ein Temporäres Schlüsselpaar erstellt
+	key_pair_id, err := k.CreateNewTempKeyPair()
+	if err != nil {
+		return nil, fmt.Errorf("establishOutgoingRelayConnection: 1: " + err.Error())
+	}
+
+	// Der Öffentliche Schlüssel wird abgerufen
+	temp_public_key, err := k.GetPublicTempKeyById(key_pair_id)
+	if err != nil {
+		return nil, fmt.Errorf("establishOutgoingRelayConnection: 2: " + err.Error())
+	}
+
+	// Es wird geprüft ob es sich um einen bekannten Relay handelt
+	relay_pkyobj, err := k.GetTrustedRelayByPublicKey(pub_key)
+	if err != nil {
+		return nil, fmt.Errorf("establishOutgoingRelayConnection: 3: " + err.Error())
+	}
+
+	// Es wird ein Hash zum signieren erstellt 'SHA3_256(decoded_pkey || temp_public_key)'
+	sign_hash := utils.ComputeSha3256Hash(pub_key.SerializeCompressed(), temp_public_key.SerializeCompressed())
+
+	// Der Hash wird mit dem Relay Schlüssel des Aktuellen Relays Signiert
+	relay_signature, err := k.SignWithRelayKey(sign_hash)
+	if err != nil {
+		return nil, fmt.Errorf("establishOutgoingRelayConnection: 4: " + err.Error())
+	}
+
+	// Der Hash wird mit dem Temprären Schlüssel signiert
+	temp_key_signature, err := k.SignWithTempKeyId(key_pair_id, sign_hash)
+	if err != nil {
+		return nil, fmt.Errorf("establishOutgoingRelayConnection: 5: " + err.Error())
+	}
+
+	// Es wird ermittelt ob es einen P2P IP-basierenden Server Socket gibt
+	remote_ip, _, err := net.SplitHostPort(remote_sock_adr.String())
+	if err != nil {
+		return nil, fmt.Errorf("establishOutgoingRelayConnection: 6: " + err.Error())
+	}
+	p2p_sockets := k.GetIPBasedServersForP2PConnection(remote_ip)
+
+	// Die Aktuelle Zeit wird ermittelt
+	c_time := time.Now()
+
+	// Das Verschlüsselt HelloClientPackage wird vorbereitet
+	plain_client_hello_package := EncryptedClientHelloPackage{
+		PublicServerKey:   pub_key.SerializeCompressed(),
+		PublicClientKey:   k.GetPublicKey().SerializeCompressed(),
+		RandClientPKey:    temp_public_key.SerializeCompressed(),
+		RandClientPKeySig: temp_key_signature,
+		ClientSig:         relay_signature,
+		Version:           static.VERSION,
+		Flags:             []WSPackageFlag{},
+	}
+
+	// Sollte ein P2P Socket vorhanden sein wird ein Flag eintrag dem Paket hinzugefügt
+	for i := range p2p_sockets {
+		// Der Flagwert wird erstellt
+		f_value := WSPackageFlag{Value: []byte(p2p_sockets[i].Protocol + ":" + strconv.Itoa(int(p2p_sockets[i].Port))), Flag: []byte(P2P_SERVER_FLAG)}
+
+		// Das Protokoll wird als Flag hinzugefügt
+		plain_client_hello_package.Flags = append(plain_client_hello_package.Flags, f_value)
+	}
+
+	// Das Paket wird in Bytes umgewandelt
+	byted, err := cbor.Marshal(plain_client_hello_package, cbor.EncOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("establishOutgoingRelayConnection: 7: " + err.Error())
+	}
+
+	// Die Daten werden mit dem Öffentlichen Schlüssel der gegenseite verschlüsselt
+	encrypted_package, err := utils.EncryptECIESPublicKey(pub_key, byted)
+	if err != nil {
+		return nil, fmt.Errorf("establishOutgoingRelayConnection: 8: " + err.Error())
+	}
+
+	// Die Daten werden übermittelt
+	if err := conn.WritePacket(encrypted_package); err != nil {
+		return nil, fmt.Errorf("establishOutgoingRelayConnection: 9: " + err.Error())
+	}
+
+	// Es wird Maximal 120 Sekunden auf die Antwort gewartet
+	if err := conn.SetReadDeadline(time.Now().Add(120 * time.Second)); err != nil {
+		return nil, fmt.Errorf("establishOutgoingRelayConnection: 10: " + err.Error())
+	}
+
+	// Es wird auf die Antwort gewartet
+	recived_message, err := conn.ReadPacket()
+	if err != nil {
+		return nil, fmt.Errorf("establishOutgoingRelayConnection: 11: " + err.Error())
+	}
+
+	// Es wird versucht den Datensatz mit dem Private Relay Schlüssel zu entschlüsseln
+	decrypted_message, err := k.DecryptWithPrivateRelayKey(recived_message)
+	if err != nil {
+		return nil, fmt.Errorf("establishOutgoingRelayConnection: 12: " + err.Error())
+	}
+
+	// Es wird versucht die Daten mittels CBOR einzulesen
+	var eshp EncryptedServerHelloPackage
+	if err := cbor.Unmarshal(decrypted_message, &eshp); err != nil {
+		return nil, fmt.Errorf("establishOutgoingRelayConnection: 13: " + err.Error())
+	}
+
+	// Es wird versucht den Öffentlicher Schlüssel des Servers einzulesen
+	public_server_key, err := utils.ReadPublicKeyFromByteSlice(eshp.PublicServerKey)
+	if err != nil {
+		return nil, fmt.Errorf("establishOutgoingRelayConnection: 14: " + err.Error())
+	}
+	public_server_otk, err := utils.ReadPublicKeyFromByteSlice(eshp.RandServerPKey)
+	if err != nil {
+		return nil, fmt.Errorf("establishOutgoingRelayConnection: 15: " + err.Error())
+	}
+
+	// Es wird geprüft ob der Handshake mit dem angeforderten Relay durchgeführt wurde
+	if !bytes.Equal(eshp.PublicServerKey, pub_key.SerializeCompressed()) || !bytes.Equal(eshp.PublicClientKey, k.GetPublicKey().SerializeCompressed()) {
+		return nil, fmt.Errorf("establishOutgoingRelayConnection: 16: relay key mismatch")
+	}
+
+	// Es wird geprüft ob die Signatur des Relays korrekt ist 'SHA3_256(client_pkey || temp_server_pkey || server_pkey)'
+	server_sign_hash := utils.ComputeSha3256Hash(eshp.PublicClientKey, eshp.RandServerPKey, eshp.PublicServerKey)
+	check, err := utils.VerifyByBytes(public_server_key, eshp.ServerSig, server_sign_hash)
+	if err != nil {
+		return nil, fmt.Errorf("establishOutgoingRelayConnection: 17: " + err.Error())
+	}
+	if !check {
+		return nil, fmt.Errorf("establishOutgoingRelayConnection: 18: invalid relay signature")
+	}
+
+	// Das Reading Timeout wird entfernt
+	if err := conn.SetReadDeadline(time.Time{}); err != nil {
+		return nil, fmt.Errorf("establishOutgoingRelayConnection: 19: " + err.Error())
+	}
+
+	// Es wird ein ECDH Schlüssel für die OTK Schlüssel beider Relays erstellt
+	otk_ecdh_key, err := k.CreateOTKECDHKey(key_pair_id, public_server_otk)
+	if err != nil {
+		return nil, fmt.Errorf("establishOutgoingRelayConnection: 20: " + err.Error())
+	}
+
+	// Zeitdifferenz berechnen
+	total_ts_time := time.Until(c_time).Seconds()
+
+	// Bandbreite berechnen
+	bandwith_kbs := float64(float64(len(recived_message))/total_ts_time) / 1024
+
+	// Das Finale Sitzungsobjekt wird erstellt
+	finally_kernel_session, err := createFinallyKernelConnection(conn, protocol, key_pair_id, public_server_key, public_server_otk, otk_ecdh_key, bandwith_kbs, uint64(total_ts_time), kernel.OUTBOUND, local_sock_adr, remote_sock_adr)
+	if err != nil {
+		return nil, fmt.Errorf("establishOutgoingRelayConnection: 21: " + err.Error())
+	}
+
+	// Solte kein Vertrauenswürdiger Relay vorhanden sein, wird das Relay einer bereits bestehenden Verbindung verwendet,
+	// sollte keine Verbindung bestehen wird ein Temporärer Relay erzeugt
+	if relay_pkyobj == nil {
+		relay_pkyobj = k.GetConnectedRelayByPublicKey(pub_key)
+	}
+	if relay_pkyobj == nil {
+		relay_pkyobj = kernel.NewUntrustedRelay(pub_key, time.Now().Unix(), end_point, protocol)
+	}
+
+	// Log
+	if relay_pkyobj.IsTrusted() {
+		k.Logger(logging.TRANSPORT).Info("Trusted relay connected", "relay", hex.EncodeToString(pub_key.SerializeCompressed()))
+	} else {
+		k.Logger(logging.TRANSPORT).Info("Unkown relay connected", "relay", hex.EncodeToString(pub_key.SerializeCompressed()))
+	}
+
+	// Die Verbindung wird registriert
+	if err := k.AddNewConnection(relay_pkyobj, finally_kernel_session); err != nil {
+		return nil, fmt.Errorf("establishOutgoingRelayConnection: 22: " + err.Error())
+	}
+
+	// Die Verbindung wird final fertigestellt
+	if err := finally_kernel_session.FinallyInit(); err != nil {
+		k.RemoveConnection(finally_kernel_session)
+		return nil, fmt.Errorf("establishOutgoingRelayConnection: 23: " + err.Error())
+	}
+
+	// Die Verbindung wird zurückgegeben
+	return finally_kernel_session, nil
+}
@@ -0,0 +1,162 @@
+package ipoverlay
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/fluffelpuff/RoueX/kernel"
+	"github.com/fluffelpuff/RoueX/logging"
+	"github.com/fluffelpuff/RoueX/utils"
+)
+
+// Gibt den Namen des Flags an, mit welchem ein Relay seine IP basierten Server ankündigt
+const P2P_SERVER_FLAG = "server"
+
+// Stellt einen angekündigten Server der Gegenseite dar
+type p2p_server_announcement struct {
+	protocol  string
+	end_point string
+}
+
+// Erstellt den Endpunkt für ein Client Modul anhand der IP Adresse der Gegenseite und des angekündigten Ports
+func p2pBackDialEndPoint(protocol string, ip string, port uint64) (string, error) {
+	host_port := net.JoinHostPort(ip, strconv.FormatUint(port, 10))
+	switch protocol {
+	case "wstcp":
+		return "ws://" + host_port, nil
+	case "tcp":
+		return "tcp://" + host_port, nil
+	case "quic":
+		return "quic://" + host_port, nil
+	default:
+		return "", fmt.Errorf("p2pBackDialEndPoint: unkown protocol " + protocol)
+	}
+}
+
+// Ermittelt anhand eines Endpunktes das ClientServerP2P Protokoll sowie das Client Modul, mit welchem der Endpunkt erreicht wird
+func ParseClientServerP2PEndPoint(end_point string) (ClientServerP2PProtocol, string, error) {
+	// Der Endpunkt wird eingelesen
+	parsed, err := url.Parse(end_point)
+	if err != nil {
+		return 0, "", fmt.Errorf("ParseClientServerP2PEndPoint: 1: " + err.Error())
+	}
+	if parsed.Hostname() == "" || parsed.Port() == "" {
+		return 0, "", fmt.Errorf("ParseClientServerP2PEndPoint: 2: invalid end point " + end_point)
+	}
+
+	// Es wird geprüft ob es sich um eine IPv6 Adresse handelt
+	ip := net.ParseIP(parsed.Hostname())
+	is_v6 := ip != nil && ip.To4() == nil
+
+	// Das Protokoll wird anhand des Schemas ermittelt
+	switch parsed.Scheme {
+	case "ws":
+		if is_v6 {
+			return WS_TCP_V6, "wstcp", nil
+		}
+		return WS_TCP_V4, "wstcp", nil
+	case "tcp":
+		if is_v6 {
+			return TCP_V6, "tcp", nil
+		}
+		return TCP_V4, "tcp", nil
+	case "quic":
+		if is_v6 {
+			return QUIC_V6, "quic", nil
+		}
+		return QUIC_V4, "quic", nil
+	default:
+		return 0, "", fmt.Errorf("ParseClientServerP2PEndPoint: 3: unkown scheme " + parsed.Scheme)
+	}
+}
+
+// Ließt ein Server Flag im Format 'protocol:port' ein und erstellt den Endpunkt der Gegenseite
+func readP2PServerFlag(remote_ip string, rflag WSPackageFlag) (*p2p_server_announcement, error) {
+	// Es wird geprüft ob es sich bei dem 'Value' um einen zulässigen UTF8 String handelt
+	if !utf8.Valid(rflag.Value) {
+		return nil, fmt.Errorf("readP2PServerFlag: 1: invalid flag value")
+	}
+
+	// Der Wert wird in das Protokoll und den Port aufgeteilt
+	splited_string := strings.Split(string(rflag.Value), ":")
+	if len(splited_string) != 2 {
+		return nil, fmt.Errorf("readP2PServerFlag: 2: invalid flag value")
+	}
+
+	// Der Port wird eingelesen
+	port, err := strconv.ParseUint(splited_string[1], 10, 16)
+	if err != nil || port == 0 {
+		return nil, fmt.Errorf("readP2PServerFlag: 3: invalid port")
+	}
+
+	// Der Endpunkt wird erstellt
+	end_point, err := p2pBackDialEndPoint(splited_string[0], remote_ip, port)
+	if err != nil {
+		return nil, fmt.Errorf("readP2PServerFlag: 4: " + err.Error())
+	}
+
+	// Die Daten werden zurückgegeben
+	return &p2p_server_announcement{protocol: splited_string[0], end_point: end_point}, nil
+}
+
+// Versucht nacheinander eine Rückverbindung zu den angekündigten Servern aufzubauen, bis eine Verbindung besteht
+func tryP2PBackDial(k *kernel.Kernel, pub_key *btcec.PublicKey, servers []*p2p_server_announcement) {
+	relay_adr := utils.ConvertPublicKeyToAddress(pub_key)
+	for _, server := range servers {
+		// Es wird geprüft ob die Rückverbindung zulässig ist
+		if err := k.AllowP2PBackDial(pub_key); err != nil {
+			k.Logger(logging.TRANSPORT).Debug("P2PBackDial: back dial skipped", "relay", relay_adr, "reason", err.Error())
+			return
+		}
+
+		// Es wird versucht die Verbindung aufzubauen
+		if err := k.BackDialRelay(pub_key, server.protocol, server.end_point); err != nil {
+			k.Logger(logging.TRANSPORT).Warn("P2PBackDial: back dial failed", "relay", relay_adr, "protocol", server.protocol, "endpoint", server.end_point, "error", err.Error())
+			continue
+		}
+
+		// Die Verbindung wurde aufgebaut
+		return
+	}
+}
+
+// Verarbeitet die Flags eines empfangenen ClientHello Paketes, die angekündigten Server der Gegenseite
+// werden über die IP Adresse der eingehenden Verbindung erreicht
+func processClientHelloFlags(k *kernel.Kernel, remote_addr net.Addr, chpackage *EncryptedClientHelloPackage) {
+	// Der Öffentliche Schlüssel der Gegenseite wird eingelesen, er wurde bereits im Handshake geprüft
+	pub_key, err := utils.ReadPublicKeyFromByteSlice(chpackage.PublicClientKey)
+	if err != nil {
+		return
+	}
+
+	// Die IP Adresse der Gegenseite wird ermittelt
+	remote_ip, _, err := net.SplitHostPort(remote_addr.String())
+	if err != nil {
+		return
+	}
+
+	// Die angekündigten Server werden eingelesen, unbekannte Flags werden ignoriert
+	servers := make([]*p2p_server_announcement, 0)
+	for i := range chpackage.Flags {
+		if !bytes.Equal(chpackage.Flags[i].Flag, []byte(P2P_SERVER_FLAG)) {
+			continue
+		}
+		server, err := readP2PServerFlag(remote_ip, chpackage.Flags[i])
+		if err != nil {
+			k.Logger(logging.TRANSPORT).Debug("P2PBackDial: invalid server flag", "error", err.Error())
+			continue
+		}
+		servers = append(servers, server)
+	}
+
+	// Sollten Server angekündigt worden sein, wird die Rückverbindung in einem eigenen Thread aufgebaut
+	if len(servers) > 0 {
+		go tryP2PBackDial(k, pub_key, servers)
+	}
+}
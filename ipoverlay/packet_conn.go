@@ -0,0 +1,141 @@
+package ipoverlay
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// Gibt die maximale Größe eines einzelnen Frames an
+const MAX_FRAME_SIZE uint32 = 16 * 1024 * 1024
+
+// Gibt die maximale Größe eines Frames an, solange die Gegenseite einer eingehenden Verbindung noch nicht authentifiziert wurde
+const MAX_HELLO_FRAME_SIZE uint32 = 8 * 1024
+
+// Gibt an, wie lange auf das ClientHello Paket einer eingehenden Verbindung gewartet wird
+const HELLO_READ_TIMEOUT = 10 * time.Second
+
+// Stellt eine Paketbasierte Verbindung dar, über welche die Hello- und Transportpakete übertragen werden
+type packet_conn interface {
+	ReadPacket() ([]byte, error)
+	WritePacket([]byte) error
+	SetReadDeadline(time.Time) error
+	SetPacketSizeLimit(uint32)
+	LocalAddr() net.Addr
+	RemoteAddr() net.Addr
+	Close() error
+}
+
+// Stellt eine Paketbasierte Verbindung dar, welche Steuerpakete (Ping, Pong, Routing, Schlüsselübergaben, Verzeichnis) getrennt von den Datenpaketen überträgt
+type priority_packet_conn interface {
+	packet_conn
+	WriteControlPacket([]byte) error
+}
+
+// Stellt eine Websocket Verbindung als Paketbasierte Verbindung dar
+type ws_packet_conn struct {
+	*websocket.Conn
+}
+
+// Ließt das nächste Binäre Paket, alle anderen Nachrichtentypen werden übersprungen
+func (obj *ws_packet_conn) ReadPacket() ([]byte, error) {
+	for {
+		message_type, message, err := obj.ReadMessage()
+		if err != nil {
+			return nil, err
+		}
+		if message_type == websocket.BinaryMessage {
+			return message, nil
+		}
+	}
+}
+
+// Schreibt ein Paket als Binäre Websocket Nachricht
+func (obj *ws_packet_conn) WritePacket(data []byte) error {
+	return obj.WriteMessage(websocket.BinaryMessage, data)
+}
+
+// Legt die maximale Größe eines Paketes fest, größere Nachrichten führen zum Schließen der Verbindung
+func (obj *ws_packet_conn) SetPacketSizeLimit(limit uint32) {
+	obj.SetReadLimit(int64(limit))
+}
+
+// Erstellt eine neue Paketbasierte Verbindung aus einer Websocket Verbindung
+func newWsPacketConn(conn *websocket.Conn, limit uint32) *ws_packet_conn {
+	result := &ws_packet_conn{conn}
+	result.SetPacketSizeLimit(limit)
+	return result
+}
+
+// Stellt eine TCP Verbindung dar, die Pakete werden mit einer 4 Byte langen Längenangabe übertragen
+type tcp_packet_conn struct {
+	net.Conn
+	_reader     *bufio.Reader
+	_write_lock *sync.Mutex
+	_max_size   uint32
+}
+
+// Ließt ein Frame mit einer 4 Byte langen Längenangabe ein, das Frame darf die angegebene Größe nicht überschreiten
+func readFrame(reader io.Reader, max_size uint32) ([]byte, error) {
+	// Die Länge des Frames wird eingelesen
+	header := make([]byte, 4)
+	if _, err := io.ReadFull(reader, header); err != nil {
+		return nil, err
+	}
+
+	// Es wird geprüft ob die Größe zulässig ist
+	size := binary.BigEndian.Uint32(header)
+	if size == 0 || size > max_size {
+		return nil, fmt.Errorf("readFrame: invalid frame size %d", size)
+	}
+
+	// Die Daten des Frames werden eingelesen
+	data := make([]byte, size)
+	if _, err := io.ReadFull(reader, data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// Schreibt ein Frame mit einer 4 Byte langen Längenangabe
+func writeFrame(writer io.Writer, data []byte) error {
+	// Es wird geprüft ob die Größe zulässig ist
+	if len(data) == 0 || uint64(len(data)) > uint64(MAX_FRAME_SIZE) {
+		return fmt.Errorf("writeFrame: invalid frame size %d", len(data))
+	}
+
+	// Die Längenangabe und die Daten werden in einem Schreibvorgang übertragen
+	frame := make([]byte, 4+len(data))
+	binary.BigEndian.PutUint32(frame, uint32(len(data)))
+	copy(frame[4:], data)
+	_, err := writer.Write(frame)
+	return err
+}
+
+// Ließt das nächste Frame von der Verbindung
+func (obj *tcp_packet_conn) ReadPacket() ([]byte, error) {
+	return readFrame(obj._reader, obj._max_size)
+}
+
+// Schreibt ein Paket als Frame auf die Verbindung
+func (obj *tcp_packet_conn) WritePacket(data []byte) error {
+	obj._write_lock.Lock()
+	defer obj._write_lock.Unlock()
+	return writeFrame(obj.Conn, data)
+}
+
+// Legt die maximale Größe eines Frames fest, die Frames werden nur von einer Routine gelesen
+func (obj *tcp_packet_conn) SetPacketSizeLimit(limit uint32) {
+	obj._max_size = limit
+}
+
+// Erstellt eine neue Paketbasierte Verbindung aus einer TCP Verbindung
+func newTcpPacketConn(conn net.Conn, limit uint32) *tcp_packet_conn {
+	return &tcp_packet_conn{Conn: conn, _reader: bufio.NewReader(conn), _write_lock: new(sync.Mutex), _max_size: limit}
+}
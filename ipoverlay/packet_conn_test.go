@@ -0,0 +1,125 @@
+package ipoverlay
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net"
+	"testing"
+)
+
+// Erzeugt die Bytes eines Frames mit der angegebenen Längenangabe
+func newTestFrame(size uint32, data []byte) []byte {
+	frame := make([]byte, 4, 4+len(data))
+	binary.BigEndian.PutUint32(frame, size)
+	return append(frame, data...)
+}
+
+func TestFrameRoundTrip(t *testing.T) {
+	var buffer bytes.Buffer
+	for _, data := range [][]byte{{0x01}, []byte("hello"), bytes.Repeat([]byte{0xaa}, 4096)} {
+		if err := writeFrame(&buffer, data); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Die Frames werden in der geschriebenen Reihenfolge eingelesen
+	for _, want := range [][]byte{{0x01}, []byte("hello"), bytes.Repeat([]byte{0xaa}, 4096)} {
+		data, err := readFrame(&buffer, MAX_FRAME_SIZE)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(data, want) {
+			t.Errorf("readFrame() = %d bytes, want %d bytes", len(data), len(want))
+		}
+	}
+	if _, err := readFrame(&buffer, MAX_FRAME_SIZE); err != io.EOF {
+		t.Errorf("readFrame() on empty reader = %v, want EOF", err)
+	}
+}
+
+func TestWriteFrameSizeLimit(t *testing.T) {
+	var buffer bytes.Buffer
+	if err := writeFrame(&buffer, nil); err == nil {
+		t.Error("empty frame written")
+	}
+	if err := writeFrame(&buffer, make([]byte, MAX_FRAME_SIZE+1)); err == nil {
+		t.Error("oversized frame written")
+	}
+	if buffer.Len() != 0 {
+		t.Errorf("%d bytes written for rejected frames", buffer.Len())
+	}
+}
+
+func TestReadFrameSizeLimit(t *testing.T) {
+	tests := []struct {
+		name  string
+		frame []byte
+		ok    bool
+	}{
+		{"at limit", newTestFrame(MAX_HELLO_FRAME_SIZE, make([]byte, MAX_HELLO_FRAME_SIZE)), true},
+		{"above limit", newTestFrame(MAX_HELLO_FRAME_SIZE+1, make([]byte, MAX_HELLO_FRAME_SIZE+1)), false},
+		{"zero size", newTestFrame(0, nil), false},
+		{"huge size", newTestFrame(^uint32(0), nil), false},
+		{"truncated data", newTestFrame(16, []byte("short")), false},
+		{"truncated header", []byte{0x00, 0x00}, false},
+	}
+	for _, tt := range tests {
+		_, err := readFrame(bytes.NewReader(tt.frame), MAX_HELLO_FRAME_SIZE)
+		if tt.ok && err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+		}
+		if !tt.ok && err == nil {
+			t.Errorf("%s: frame accepted", tt.name)
+		}
+	}
+}
+
+func TestTcpPacketConnSizeLimit(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+	client_conn := newTcpPacketConn(client, MAX_FRAME_SIZE)
+	server_conn := newTcpPacketConn(server, MAX_HELLO_FRAME_SIZE)
+
+	// Die Pakete werden in einer eigenen Routine geschrieben, da die Pipe nicht gepuffert ist
+	hello := bytes.Repeat([]byte{0x01}, int(MAX_HELLO_FRAME_SIZE))
+	transport := bytes.Repeat([]byte{0x02}, int(MAX_HELLO_FRAME_SIZE)+1)
+	errs := make(chan error, 1)
+	go func() {
+		for _, data := range [][]byte{hello, transport, transport} {
+			if err := client_conn.WritePacket(data); err != nil {
+				errs <- err
+				return
+			}
+		}
+		errs <- nil
+	}()
+
+	// Vor der Authentifizierung ist nur ein Hello Frame zulässig
+	data, err := server_conn.ReadPacket()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data, hello) {
+		t.Fatal("hello frame changed")
+	}
+
+	// Nach dem Anheben der Grenze werden auch größere Frames gelesen
+	server_conn.SetPacketSizeLimit(MAX_FRAME_SIZE)
+	data, err = server_conn.ReadPacket()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data, transport) {
+		t.Fatal("transport frame changed")
+	}
+
+	// Mit der Hello Grenze wird ein größeres Frame abgelehnt
+	server_conn.SetPacketSizeLimit(MAX_HELLO_FRAME_SIZE)
+	if _, err := server_conn.ReadPacket(); err == nil {
+		t.Error("oversized frame accepted")
+	}
+	server.Close()
+	<-errs
+}
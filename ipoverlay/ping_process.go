@@ -1,13 +1,14 @@
 package ipoverlay
 
 import (
+	"context"
 	"crypto/rand"
 	"encoding/hex"
 	"fmt"
-	"log"
 	"sync"
 	"time"
 
+	"github.com/fluffelpuff/RoueX/logging"
 	"github.com/fluffelpuff/RoueX/utils"
 )
 
@@ -25,8 +26,13 @@ type PingProcess struct {
 	_lock         *sync.Mutex
 }
 
-func (obj *PingProcess) untilWaitOfPong() (uint64, error) {
-	result := <-obj.ResultChannel
+func (obj *PingProcess) untilWaitOfPong(ctx context.Context) (uint64, error) {
+	var result PingResult
+	select {
+	case result = <-obj.ResultChannel:
+	case <-ctx.Done():
+		return 0, fmt.Errorf("PingProcess:untilWaitOfPong: connection closed")
+	}
 	if result.State != 1 {
 		return 0, fmt.Errorf("PingProcess:untilWaitOfPong: invalid ping result")
 	}
@@ -41,14 +47,14 @@ func (obj *PingProcess) _signal_recived_pong() {
 	obj._lock.Lock()
 	if !obj._is_closed {
 		obj._is_closed = true
-		log.Println("PingProcess: close ping process by pong. pingid =", hex.EncodeToString(obj.ProcessId))
+		logging.Logger(logging.TRANSPORT).Debug("PingProcess: close ping process by pong", "pingid", hex.EncodeToString(obj.ProcessId))
 		ntime := time.Until(obj.CreatedAt).Seconds()
 		if ntime < 1 {
 			ntime = 1
 		}
 		obj.ResultChannel <- PingResult{State: 1, TotalTime: ntime}
 	} else {
-		log.Println("PingProcess: alwasy closed. pingid =", hex.EncodeToString(obj.ProcessId))
+		logging.Logger(logging.TRANSPORT).Debug("PingProcess: alwasy closed", "pingid", hex.EncodeToString(obj.ProcessId))
 	}
 	obj._lock.Unlock()
 }
@@ -57,14 +63,14 @@ func (obj *PingProcess) _signal_abort() {
 	obj._lock.Lock()
 	if !obj._is_closed {
 		obj._is_closed = true
-		log.Println("PingProcess: aborted. pingid =", hex.EncodeToString(obj.ProcessId))
+		logging.Logger(logging.TRANSPORT).Debug("PingProcess: aborted", "pingid", hex.EncodeToString(obj.ProcessId))
 		ntime := time.Until(obj.CreatedAt).Seconds()
 		if ntime < 1 {
 			ntime = 1
 		}
 		obj.ResultChannel <- PingResult{State: 0, TotalTime: ntime}
 	} else {
-		log.Println("PingProcess: aborted. pingid =", hex.EncodeToString(obj.ProcessId))
+		logging.Logger(logging.TRANSPORT).Debug("PingProcess: aborted", "pingid", hex.EncodeToString(obj.ProcessId))
 	}
 	obj._lock.Unlock()
 }
@@ -75,7 +81,7 @@ func newPingProcess() (*PingProcess, error) {
 	if err != nil {
 		return nil, err
 	}
-	r := &PingProcess{ProcessId: randomBytes, ResultChannel: make(chan PingResult), CreatedAt: time.Now(), ObjectId: utils.RandStringRunes(16), _lock: new(sync.Mutex)}
-	log.Println("PingProcess: created new process. pingid =", hex.EncodeToString(randomBytes))
+	r := &PingProcess{ProcessId: randomBytes, ResultChannel: make(chan PingResult, 1), CreatedAt: time.Now(), ObjectId: utils.RandStringRunes(16), _lock: new(sync.Mutex)}
+	logging.Logger(logging.TRANSPORT).Debug("PingProcess: created new process", "pingid", hex.EncodeToString(randomBytes))
 	return r, nil
 }
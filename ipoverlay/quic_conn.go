@@ -0,0 +1,253 @@
+package ipoverlay
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/quic-go/quic-go"
+)
+
+// Gibt das ALPN Protokoll an, welches für QUIC Verbindungen verwendet wird
+const QUIC_ALPN = "rouex"
+
+// Gibt die Prioritätsklassen der QUIC Streams an, jede Klasse wird über einen eigenen Stream übertragen
+const (
+	QUIC_CONTROL_STREAM byte = 0
+	QUIC_BULK_STREAM    byte = 1
+)
+
+// Gibt an, wie lange maximal auf das Öffnen der Streams gewartet wird
+const QUIC_STREAM_TIMEOUT = 30 * time.Second
+
+// Gibt die Einstellungen für QUIC Verbindungen an
+func newQuicConfig() *quic.Config {
+	return &quic.Config{
+		Allow0RTT:       true,
+		KeepAlivePeriod: 10 * time.Second,
+		MaxIdleTimeout:  60 * time.Second,
+	}
+}
+
+// Erstellt die TLS Einstellungen für einen QUIC Server, es wird ein selbstsigniertes Zertifikat verwendet,
+// die Authentifizierung der Relays erfolgt anschließend über die Relay Schlüssel
+func newQuicServerTlsConfig() (*tls.Config, error) {
+	// Es wird ein neuer Schlüssel für das Zertifikat erzeugt
+	priv_key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("newQuicServerTlsConfig: 1: " + err.Error())
+	}
+
+	// Das Zertifikat wird erstellt
+	template := x509.Certificate{SerialNumber: big.NewInt(1), NotBefore: time.Now().Add(-time.Hour), NotAfter: time.Now().Add(10 * 365 * 24 * time.Hour)}
+	cert_der, err := x509.CreateCertificate(rand.Reader, &template, &template, &priv_key.PublicKey, priv_key)
+	if err != nil {
+		return nil, fmt.Errorf("newQuicServerTlsConfig: 2: " + err.Error())
+	}
+
+	// Die Einstellungen werden zurückgegeben
+	return &tls.Config{Certificates: []tls.Certificate{{Certificate: [][]byte{cert_der}, PrivateKey: priv_key}}, NextProtos: []string{QUIC_ALPN}}, nil
+}
+
+// Stellt eine QUIC Verbindung als Paketbasierte Verbindung dar, Steuerpakete und Datenpakete werden über getrennte
+// Streams übertragen, so blockieren große Datenpakete keine Ping und Pong Pakete
+type quic_packet_conn struct {
+	_conn          quic.Connection
+	_control       quic.Stream
+	_bulk          quic.Stream
+	_control_lock  *sync.Mutex
+	_bulk_lock     *sync.Mutex
+	_lock          *sync.Mutex
+	_incomming     chan []byte
+	_closed        chan struct{}
+	_close_once    *sync.Once
+	_read_err      error
+	_read_deadline time.Time
+	_max_size      uint32
+}
+
+// Beendet die Verbindung und speichert den Fehler ab, welcher zum beenden geführt hat
+func (obj *quic_packet_conn) _stop(err error) {
+	obj._close_once.Do(func() {
+		obj._lock.Lock()
+		obj._read_err = err
+		obj._lock.Unlock()
+		close(obj._closed)
+	})
+}
+
+// Ließt die Frames eines Streams ein und übergibt sie an den gemeinsamen Eingangspuffer
+func (obj *quic_packet_conn) _read_stream(stream quic.Stream) {
+	for {
+		obj._lock.Lock()
+		max_size := obj._max_size
+		obj._lock.Unlock()
+		data, err := readFrame(stream, max_size)
+		if err != nil {
+			obj._stop(err)
+			return
+		}
+		select {
+		case obj._incomming <- data:
+		case <-obj._closed:
+			return
+		}
+	}
+}
+
+// Ließt das nächste Paket, unabhängig davon über welchen Stream es empfangen wurde
+func (obj *quic_packet_conn) ReadPacket() ([]byte, error) {
+	// Sollte ein Timeout gesetzt sein, wird maximal bis zu diesem gewartet
+	obj._lock.Lock()
+	deadline := obj._read_deadline
+	obj._lock.Unlock()
+	var timeout <-chan time.Time
+	if !deadline.IsZero() {
+		timer := time.NewTimer(time.Until(deadline))
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	// Es wird auf das nächste Paket gewartet
+	select {
+	case data := <-obj._incomming:
+		return data, nil
+	case <-obj._closed:
+		obj._lock.Lock()
+		defer obj._lock.Unlock()
+		return nil, obj._read_err
+	case <-timeout:
+		return nil, fmt.Errorf("quic_packet_conn: read timeout")
+	}
+}
+
+// Schreibt ein Datenpaket auf den Bulk Stream
+func (obj *quic_packet_conn) WritePacket(data []byte) error {
+	obj._bulk_lock.Lock()
+	defer obj._bulk_lock.Unlock()
+	return writeFrame(obj._bulk, data)
+}
+
+// Schreibt ein Steuerpaket auf den Control Stream
+func (obj *quic_packet_conn) WriteControlPacket(data []byte) error {
+	obj._control_lock.Lock()
+	defer obj._control_lock.Unlock()
+	return writeFrame(obj._control, data)
+}
+
+// Legt fest, bis wann ein Paket gelesen werden muss
+func (obj *quic_packet_conn) SetReadDeadline(t time.Time) error {
+	obj._lock.Lock()
+	obj._read_deadline = t
+	obj._lock.Unlock()
+	return nil
+}
+
+// Legt die maximale Größe eines Frames fest, die Größe gilt für alle danach begonnenen Frames
+func (obj *quic_packet_conn) SetPacketSizeLimit(limit uint32) {
+	obj._lock.Lock()
+	obj._max_size = limit
+	obj._lock.Unlock()
+}
+
+// Gibt die Lokale Adresse zurück
+func (obj *quic_packet_conn) LocalAddr() net.Addr {
+	return obj._conn.LocalAddr()
+}
+
+// Gibt die Adresse der Gegenseite zurück
+func (obj *quic_packet_conn) RemoteAddr() net.Addr {
+	return obj._conn.RemoteAddr()
+}
+
+// Schließt die Verbindung
+func (obj *quic_packet_conn) Close() error {
+	obj._stop(net.ErrClosed)
+	return obj._conn.CloseWithError(0, "closed")
+}
+
+// Erstellt eine neue Paketbasierte Verbindung aus einer QUIC Verbindung und den beiden Streams
+func newQuicPacketConn(conn quic.Connection, control quic.Stream, bulk quic.Stream, limit uint32) *quic_packet_conn {
+	result := &quic_packet_conn{
+		_conn:         conn,
+		_control:      control,
+		_bulk:         bulk,
+		_control_lock: new(sync.Mutex),
+		_bulk_lock:    new(sync.Mutex),
+		_lock:         new(sync.Mutex),
+		_incomming:    make(chan []byte),
+		_closed:       make(chan struct{}),
+		_close_once:   new(sync.Once),
+		_max_size:     limit,
+	}
+	go result._read_stream(control)
+	go result._read_stream(bulk)
+	return result
+}
+
+// Öffnet die Streams auf einer ausgehenden QUIC Verbindung, jeder Stream beginnt mit seiner Prioritätsklasse
+func openQuicPacketConn(conn quic.Connection) (*quic_packet_conn, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), QUIC_STREAM_TIMEOUT)
+	defer cancel()
+
+	// Die Streams werden geöffnet und gekennzeichnet
+	streams := make([]quic.Stream, 2)
+	for i, class := range []byte{QUIC_CONTROL_STREAM, QUIC_BULK_STREAM} {
+		stream, err := conn.OpenStreamSync(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("openQuicPacketConn: 1: " + err.Error())
+		}
+		if _, err := stream.Write([]byte{class}); err != nil {
+			return nil, fmt.Errorf("openQuicPacketConn: 2: " + err.Error())
+		}
+		streams[i] = stream
+	}
+
+	// Die Verbindung wird zurückgegeben
+	return newQuicPacketConn(conn, streams[0], streams[1], MAX_FRAME_SIZE), nil
+}
+
+// Nimmt die Streams einer eingehenden QUIC Verbindung entgegen und ordnet sie anhand ihrer Prioritätsklasse zu
+func acceptQuicPacketConn(conn quic.Connection) (*quic_packet_conn, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), QUIC_STREAM_TIMEOUT)
+	defer cancel()
+
+	// Es wird auf beide Streams gewartet
+	var control, bulk quic.Stream
+	for control == nil || bulk == nil {
+		stream, err := conn.AcceptStream(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("acceptQuicPacketConn: 1: " + err.Error())
+		}
+
+		// Die Prioritätsklasse wird eingelesen
+		class := make([]byte, 1)
+		stream.SetReadDeadline(time.Now().Add(QUIC_STREAM_TIMEOUT))
+		if _, err := io.ReadFull(stream, class); err != nil {
+			return nil, fmt.Errorf("acceptQuicPacketConn: 2: " + err.Error())
+		}
+		stream.SetReadDeadline(time.Time{})
+
+		// Der Stream wird zugeordnet
+		switch {
+		case class[0] == QUIC_CONTROL_STREAM && control == nil:
+			control = stream
+		case class[0] == QUIC_BULK_STREAM && bulk == nil:
+			bulk = stream
+		default:
+			return nil, fmt.Errorf("acceptQuicPacketConn: 3: invalid stream class")
+		}
+	}
+
+	// Die Verbindung wird zurückgegeben, bis die Gegenseite authentifiziert wurde, sind nur kleine Frames zulässig
+	return newQuicPacketConn(conn, control, bulk, MAX_HELLO_FRAME_SIZE), nil
+}
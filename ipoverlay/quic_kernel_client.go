@@ -0,0 +1,112 @@
+package ipoverlay
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/fluffelpuff/RoueX/kernel"
+	"github.com/fluffelpuff/RoueX/logging"
+	"github.com/fluffelpuff/RoueX/utils"
+	"github.com/quic-go/quic-go"
+)
+
+// Stellt das QUIC Client Modul dar
+type QuicKernelClient struct {
+	_lock          sync.Mutex
+	_kernel        *kernel.Kernel
+	_obj_id        string
+	_session_cache tls.ClientSessionCache
+}
+
+// Registriert den Kernel im Modul
+func (obj *QuicKernelClient) RegisterKernel(kernel *kernel.Kernel) error {
+	obj._kernel = kernel
+	return nil
+}
+
+// Gibt alle Meta Daten des Moduls aus
+func (obj *QuicKernelClient) GetMetaDataInfo() kernel.ClientModuleMetaData {
+	return kernel.ClientModuleMetaData{}
+}
+
+// Gibt das Protokoll des Moduls aus
+func (obj *QuicKernelClient) GetProtocol() string {
+	return "quic"
+}
+
+// Stellt eine neue QUIC Verbindung her, der Endpunkt wird als 'host:port' oder 'quic://host:port' angegeben
+func (obj *QuicKernelClient) ConnectTo(end_point string, pub_key *btcec.PublicKey, proxy_config *kernel.ProxyConfig) (kernel.RelayConnection, error) {
+	// Proxys werden für QUIC Verbindungen nicht unterstützt
+	if proxy_config != nil {
+		return nil, fmt.Errorf("ConnectTo: 1: proxy not supported by quic client")
+	}
+
+	// Der Host wird ermittelt, dieser wird für die Zuordnung der Sitzungstickets verwendet
+	address := strings.TrimPrefix(end_point, "quic://")
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, fmt.Errorf("ConnectTo: 2: " + err.Error())
+	}
+
+	// Das Zertifikat der Gegenseite wird nicht geprüft, die Authentifizierung erfolgt im Handshake über die Relay Schlüssel,
+	// die Sitzungstickets werden zwischengespeichert, so kann eine getrennte Verbindung per 0-RTT wieder aufgebaut werden
+	tls_config := &tls.Config{
+		InsecureSkipVerify: true,
+		NextProtos:         []string{QUIC_ALPN},
+		ServerName:         host,
+		ClientSessionCache: obj._session_cache,
+	}
+
+	// Log
+	obj._kernel.Logger(logging.TRANSPORT).Info("QuicKernelClient: trying to establish a quic connection", "endpoint", end_point, "relay", hex.EncodeToString(pub_key.SerializeCompressed()))
+
+	// Die Verbindung wird aufgebaut
+	ctx, cancel := context.WithTimeout(context.Background(), QUIC_STREAM_TIMEOUT)
+	defer cancel()
+	conn, err := quic.DialAddrEarly(ctx, address, tls_config, newQuicConfig())
+	if err != nil {
+		return nil, fmt.Errorf("ConnectTo: 3: " + err.Error())
+	}
+
+	// Die Streams werden geöffnet
+	pconn, err := openQuicPacketConn(conn)
+	if err != nil {
+		conn.CloseWithError(0, "invalid streams")
+		return nil, fmt.Errorf("ConnectTo: 4: " + err.Error())
+	}
+
+	// Der Handshake wird durchgeführt und die Verbindung wird registriert
+	finally_kernel_session, err := establishOutgoingRelayConnection(obj._kernel, pconn, obj.GetProtocol(), end_point, pub_key)
+	if err != nil {
+		pconn.Close()
+		return nil, fmt.Errorf("ConnectTo: 5: " + err.Error())
+	}
+
+	// Log
+	obj._kernel.Logger(logging.TRANSPORT).Info("QuicKernelClient: the quic connection has been established", "endpoint", end_point, "local", conn.LocalAddr().String(), "remote", conn.RemoteAddr().String(), "0rtt", conn.ConnectionState().Used0RTT)
+
+	// Die Verbindung wird zurückgegeben
+	return finally_kernel_session, nil
+}
+
+// Gibt die Aktuelle ObjektID aus
+func (obj *QuicKernelClient) GetObjectId() string {
+	return obj._obj_id
+}
+
+// Beendet das Module, verhindert das weitere verwenden
+func (obj *QuicKernelClient) Shutdown() {
+	obj._kernel.Logger(logging.TRANSPORT).Info("Shutdowing quic clients")
+}
+
+// Erstellt ein neues QUIC Client Modul
+func NewQuicClient() *QuicKernelClient {
+	rand_id := utils.RandStringRunes(16)
+	return &QuicKernelClient{_obj_id: rand_id, _lock: sync.Mutex{}, _session_cache: tls.NewLRUClientSessionCache(64)}
+}
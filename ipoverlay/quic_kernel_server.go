@@ -0,0 +1,185 @@
+package ipoverlay
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+
+	"github.com/fluffelpuff/RoueX/kernel"
+	"github.com/fluffelpuff/RoueX/logging"
+	"github.com/fluffelpuff/RoueX/utils"
+	"github.com/quic-go/quic-go"
+)
+
+// Stellt den QUIC Server dar
+type QuicKernelServerEP struct {
+	_kernel          *kernel.Kernel
+	_obj_id          string
+	_shutdown_signal bool
+	_is_running      bool
+	_listener        *quic.EarlyListener
+	_lock            *sync.Mutex
+	_ip_adr          string
+	_port            int
+	_closed          chan struct{}
+}
+
+// Registriert den Kernel im Module
+func (obj *QuicKernelServerEP) RegisterKernel(k *kernel.Kernel) error {
+	k.Logger(logging.TRANSPORT).Info("QuicKernelServerEP: registrated on kernel", "kernel", k.GetKernelID())
+	obj._kernel = k
+	return nil
+}
+
+// Gibt an ob der Server ausgeführt wird
+func (obj *QuicKernelServerEP) _is_rn() bool {
+	obj._lock.Lock()
+	defer obj._lock.Unlock()
+	return obj._is_running
+}
+
+// Wird verwendet um den Serversocket herunterzufahren
+func (obj *QuicKernelServerEP) Shutdown() {
+	// Log
+	obj._kernel.Logger(logging.TRANSPORT).Info("QuicKernelServerEP: shutingdown", "id", obj._obj_id)
+
+	// Es wird signalisiert dass der Server heruntergefahren werden soll
+	obj._lock.Lock()
+	obj._shutdown_signal = true
+	if obj._listener != nil {
+		obj._listener.Close()
+	}
+	closed := obj._closed
+	obj._lock.Unlock()
+
+	// Es wird gewartet bis der Server beendet wurde
+	if closed != nil {
+		<-closed
+	}
+
+	// Log
+	obj._kernel.Logger(logging.TRANSPORT).Info("QuicKernelServerEP: shutingdown complete", "id", obj._obj_id)
+}
+
+// Nimmt eingehende Verbindungen entgegen, bis der Server geschlossen wurde
+func (obj *QuicKernelServerEP) _accept_loop() {
+	for {
+		// Es wird auf eine neue Verbindung gewartet
+		conn, err := obj._listener.Accept(context.Background())
+		if err != nil {
+			obj._lock.Lock()
+			is_shutdown := obj._shutdown_signal
+			obj._lock.Unlock()
+			if !is_shutdown {
+				obj._kernel.Logger(logging.TRANSPORT).Warn("QuicKernelServerEP: error by accepting connection", "id", obj._obj_id, "error", err.Error())
+			}
+			break
+		}
+
+		// Die Verbindung wird in einem eigenen Thread verarbeitet
+		go obj._handle_connection(conn)
+	}
+
+	// Es wird Signalisiert dass der Server beendet wurde
+	obj._lock.Lock()
+	obj._is_running = false
+	close(obj._closed)
+	obj._lock.Unlock()
+
+	// Log
+	obj._kernel.Logger(logging.TRANSPORT).Info("QuicKernelServerEP: closed", "id", obj._obj_id, "endpoint", obj._listener.Addr().String())
+}
+
+// Führt den Handshake für eine eingehende Verbindung durch
+func (obj *QuicKernelServerEP) _handle_connection(conn quic.EarlyConnection) {
+	// Log
+	obj._kernel.Logger(logging.TRANSPORT).Info("QuicKernelServerEP: new incomming connection accepted", "from", conn.RemoteAddr().String(), "local", conn.LocalAddr().String())
+
+	// Die Streams werden entgegengenommen
+	pconn, err := acceptQuicPacketConn(conn)
+	if err != nil {
+		obj._kernel.Logger(logging.TRANSPORT).Warn("QuicKernelServerEP: error by accepting streams", "error", err.Error())
+		conn.CloseWithError(0, "invalid streams")
+		return
+	}
+
+	// Der Handshake wird durchgeführt und die Verbindung wird registriert
+	decrypted_chpackage, err := acceptIncommingRelayConnection(obj._kernel, pconn, obj.GetProtocol(), conn.RemoteAddr().String())
+	if err != nil {
+		obj._kernel.Logger(logging.TRANSPORT).Warn("QuicKernelServerEP: error by accepting connection", "error", err.Error())
+		pconn.Close()
+		return
+	}
+
+	// Die P2P Server Flags werden verarbeitet
+	processClientHelloFlags(obj._kernel, conn.RemoteAddr(), decrypted_chpackage)
+}
+
+// Startet den eigentlichen Server
+func (obj *QuicKernelServerEP) Start() error {
+	// Die TLS Einstellungen werden erstellt
+	tls_config, err := newQuicServerTlsConfig()
+	if err != nil {
+		return fmt.Errorf("QuicKernelServerEP: " + err.Error())
+	}
+
+	// Der Server Socket wird erstellt, 0-RTT wird zugelassen damit getrennte Verbindungen schnell wieder aufgebaut werden können
+	listener, err := quic.ListenAddrEarly(net.JoinHostPort(obj._ip_adr, strconv.Itoa(obj._port)), tls_config, newQuicConfig())
+	if err != nil {
+		return fmt.Errorf("QuicKernelServerEP: " + err.Error())
+	}
+
+	// Es wird Signalisiert dass der Server ausgeführt wird
+	obj._lock.Lock()
+	obj._listener = listener
+	obj._is_running = true
+	obj._closed = make(chan struct{})
+	obj._lock.Unlock()
+
+	// Die Verbindungen werden in einem eigenen Thread angenommen
+	go obj._accept_loop()
+
+	// Log
+	obj._kernel.Logger(logging.TRANSPORT).Info("QuicKernelServerEP: new quic based server started", "id", obj._obj_id, "endpoint", listener.Addr().String())
+	return nil
+}
+
+// Gibt das Aktuelle Protokoll aus
+func (obj *QuicKernelServerEP) GetProtocol() string {
+	return "quic"
+}
+
+// Gibt die Aktuelle Objekt ID aus
+func (obj *QuicKernelServerEP) GetObjectId() string {
+	return obj._obj_id
+}
+
+// Gibt an ob der Server bereits gestartet wurde
+func (obj *QuicKernelServerEP) IsRunning() bool {
+	return obj._is_rn()
+}
+
+// Gibt den Verwendeten Port zurück
+func (obj *QuicKernelServerEP) GetLocalIPBasedPort() uint64 {
+	return uint64(obj._port)
+}
+
+// Gibt an ob es sich um einen IP basierenden Server handelt
+func (obj *QuicKernelServerEP) IsIpBasedServer() bool {
+	return true
+}
+
+// Erstellt einen neuen Lokalen QUIC Server
+func CreateNewLocalQuicServerEP(ip_adr string, port uint64) (*QuicKernelServerEP, error) {
+	// Die Einmalige ObjektID wird erstellt
+	rand_id := utils.RandStringRunes(16)
+
+	// Das Objekt wird vorbereitet
+	result_obj := &QuicKernelServerEP{_obj_id: rand_id, _lock: new(sync.Mutex), _ip_adr: ip_adr, _port: int(port)}
+
+	// Log
+	logging.Logger(logging.TRANSPORT).Info("QuicKernelServerEP: new quic server endpoint created", "address", ip_adr, "port", port)
+	return result_obj, nil
+}
@@ -0,0 +1,87 @@
+package ipoverlay
+
+import (
+	"encoding/hex"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/fluffelpuff/RoueX/kernel"
+	"github.com/fluffelpuff/RoueX/logging"
+	"github.com/fluffelpuff/RoueX/utils"
+)
+
+// Gibt an, wie lange maximal auf den Aufbau einer TCP Verbindung gewartet wird
+const TCP_DIAL_TIMEOUT = 30 * time.Second
+
+// Stellt das TCP Client Modul dar
+type TcpKernelClient struct {
+	_lock   sync.Mutex
+	_kernel *kernel.Kernel
+	_obj_id string
+}
+
+// Registriert den Kernel im Modul
+func (obj *TcpKernelClient) RegisterKernel(kernel *kernel.Kernel) error {
+	obj._kernel = kernel
+	return nil
+}
+
+// Gibt alle Meta Daten des Moduls aus
+func (obj *TcpKernelClient) GetMetaDataInfo() kernel.ClientModuleMetaData {
+	return kernel.ClientModuleMetaData{}
+}
+
+// Gibt das Protokoll des Moduls aus
+func (obj *TcpKernelClient) GetProtocol() string {
+	return "tcp"
+}
+
+// Stellt eine neue TCP Verbindung her, der Endpunkt wird als 'host:port' oder 'tcp://host:port' angegeben
+func (obj *TcpKernelClient) ConnectTo(end_point string, pub_key *btcec.PublicKey, proxy_config *kernel.ProxyConfig) (kernel.RelayConnection, error) {
+	// Proxys werden für TCP Verbindungen nicht unterstützt
+	if proxy_config != nil {
+		return nil, fmt.Errorf("ConnectTo: 1: proxy not supported by tcp client")
+	}
+
+	// Log
+	obj._kernel.Logger(logging.TRANSPORT).Info("TcpKernelClient: trying to establish a tcp connection", "endpoint", end_point, "relay", hex.EncodeToString(pub_key.SerializeCompressed()))
+
+	// Die Verbindung wird aufgebaut
+	conn, err := net.DialTimeout("tcp", strings.TrimPrefix(end_point, "tcp://"), TCP_DIAL_TIMEOUT)
+	if err != nil {
+		return nil, fmt.Errorf("ConnectTo: 2: " + err.Error())
+	}
+
+	// Log
+	obj._kernel.Logger(logging.TRANSPORT).Info("TcpKernelClient: the tcp base connection has been established", "endpoint", end_point, "local", conn.LocalAddr().String(), "remote", conn.RemoteAddr().String())
+
+	// Der Handshake wird durchgeführt und die Verbindung wird registriert
+	finally_kernel_session, err := establishOutgoingRelayConnection(obj._kernel, newTcpPacketConn(conn, MAX_FRAME_SIZE), obj.GetProtocol(), end_point, pub_key)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("ConnectTo: 3: " + err.Error())
+	}
+
+	// Die Verbindung wird zurückgegeben
+	return finally_kernel_session, nil
+}
+
+// Gibt die Aktuelle ObjektID aus
+func (obj *TcpKernelClient) GetObjectId() string {
+	return obj._obj_id
+}
+
+// Beendet das Module, verhindert das weitere verwenden
+func (obj *TcpKernelClient) Shutdown() {
+	obj._kernel.Logger(logging.TRANSPORT).Info("Shutdowing tcp clients")
+}
+
+// Erstellt ein neues TCP Client Modul
+func NewTcpClient() *TcpKernelClient {
+	rand_id := utils.RandStringRunes(16)
+	return &TcpKernelClient{_obj_id: rand_id, _lock: sync.Mutex{}}
+}
@@ -0,0 +1,169 @@
+package ipoverlay
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+
+	"github.com/fluffelpuff/RoueX/kernel"
+	"github.com/fluffelpuff/RoueX/logging"
+	"github.com/fluffelpuff/RoueX/utils"
+)
+
+// Stellt den TCP Server dar, die Pakete werden ohne HTTP bzw. Websocket direkt als Frames übertragen
+type TcpKernelServerEP struct {
+	_kernel          *kernel.Kernel
+	_obj_id          string
+	_shutdown_signal bool
+	_is_running      bool
+	_listener        net.Listener
+	_lock            *sync.Mutex
+	_ip_adr          string
+	_port            int
+	_closed          chan struct{}
+}
+
+// Registriert den Kernel im Module
+func (obj *TcpKernelServerEP) RegisterKernel(k *kernel.Kernel) error {
+	k.Logger(logging.TRANSPORT).Info("TcpKernelServerEP: registrated on kernel", "kernel", k.GetKernelID())
+	obj._kernel = k
+	return nil
+}
+
+// Gibt an ob der Server ausgeführt wird
+func (obj *TcpKernelServerEP) _is_rn() bool {
+	obj._lock.Lock()
+	defer obj._lock.Unlock()
+	return obj._is_running
+}
+
+// Wird verwendet um den Serversocket herunterzufahren
+func (obj *TcpKernelServerEP) Shutdown() {
+	// Log
+	obj._kernel.Logger(logging.TRANSPORT).Info("TcpKernelServerEP: shutingdown", "id", obj._obj_id)
+
+	// Es wird signalisiert dass der Server heruntergefahren werden soll
+	obj._lock.Lock()
+	obj._shutdown_signal = true
+	if obj._listener != nil {
+		obj._listener.Close()
+	}
+	closed := obj._closed
+	obj._lock.Unlock()
+
+	// Es wird gewartet bis der Server beendet wurde
+	if closed != nil {
+		<-closed
+	}
+
+	// Log
+	obj._kernel.Logger(logging.TRANSPORT).Info("TcpKernelServerEP: shutingdown complete", "id", obj._obj_id)
+}
+
+// Nimmt eingehende Verbindungen entgegen, bis der Server geschlossen wurde
+func (obj *TcpKernelServerEP) _accept_loop() {
+	for {
+		// Es wird auf eine neue Verbindung gewartet
+		conn, err := obj._listener.Accept()
+		if err != nil {
+			obj._lock.Lock()
+			is_shutdown := obj._shutdown_signal
+			obj._lock.Unlock()
+			if !is_shutdown {
+				obj._kernel.Logger(logging.TRANSPORT).Warn("TcpKernelServerEP: error by accepting connection", "id", obj._obj_id, "error", err.Error())
+			}
+			break
+		}
+
+		// Die Verbindung wird in einem eigenen Thread verarbeitet
+		go obj._handle_connection(conn)
+	}
+
+	// Es wird Signalisiert dass der Server beendet wurde
+	obj._lock.Lock()
+	obj._is_running = false
+	close(obj._closed)
+	obj._lock.Unlock()
+
+	// Log
+	obj._kernel.Logger(logging.TRANSPORT).Info("TcpKernelServerEP: closed", "id", obj._obj_id, "endpoint", obj._listener.Addr().String())
+}
+
+// Führt den Handshake für eine eingehende Verbindung durch
+func (obj *TcpKernelServerEP) _handle_connection(conn net.Conn) {
+	// Log
+	obj._kernel.Logger(logging.TRANSPORT).Info("TcpKernelServerEP: new incomming connection accepted", "from", conn.RemoteAddr().String(), "local", conn.LocalAddr().String())
+
+	// Der Handshake wird durchgeführt und die Verbindung wird registriert
+	decrypted_chpackage, err := acceptIncommingRelayConnection(obj._kernel, newTcpPacketConn(conn, MAX_HELLO_FRAME_SIZE), obj.GetProtocol(), conn.RemoteAddr().String())
+	if err != nil {
+		obj._kernel.Logger(logging.TRANSPORT).Warn("TcpKernelServerEP: error by accepting connection", "error", err.Error())
+		conn.Close()
+		return
+	}
+
+	// Die P2P Server Flags werden verarbeitet
+	processClientHelloFlags(obj._kernel, conn.RemoteAddr(), decrypted_chpackage)
+}
+
+// Startet den eigentlichen Server
+func (obj *TcpKernelServerEP) Start() error {
+	// Der Server Socket wird erstellt
+	listener, err := net.Listen("tcp", net.JoinHostPort(obj._ip_adr, strconv.Itoa(obj._port)))
+	if err != nil {
+		return fmt.Errorf("TcpKernelServerEP: " + err.Error())
+	}
+
+	// Es wird Signalisiert dass der Server ausgeführt wird
+	obj._lock.Lock()
+	obj._listener = listener
+	obj._is_running = true
+	obj._closed = make(chan struct{})
+	obj._lock.Unlock()
+
+	// Die Verbindungen werden in einem eigenen Thread angenommen
+	go obj._accept_loop()
+
+	// Log
+	obj._kernel.Logger(logging.TRANSPORT).Info("TcpKernelServerEP: new tcp based server started", "id", obj._obj_id, "endpoint", listener.Addr().String())
+	return nil
+}
+
+// Gibt das Aktuelle Protokoll aus
+func (obj *TcpKernelServerEP) GetProtocol() string {
+	return "tcp"
+}
+
+// Gibt die Aktuelle Objekt ID aus
+func (obj *TcpKernelServerEP) GetObjectId() string {
+	return obj._obj_id
+}
+
+// Gibt an ob der Server bereits gestartet wurde
+func (obj *TcpKernelServerEP) IsRunning() bool {
+	return obj._is_rn()
+}
+
+// Gibt den Verwendeten Port zurück
+func (obj *TcpKernelServerEP) GetLocalIPBasedPort() uint64 {
+	return uint64(obj._port)
+}
+
+// Gibt an ob es sich um einen IP basierenden Server handelt
+func (obj *TcpKernelServerEP) IsIpBasedServer() bool {
+	return true
+}
+
+// Erstellt einen neuen Lokalen TCP Server
+func CreateNewLocalTcpServerEP(ip_adr string, port uint64) (*TcpKernelServerEP, error) {
+	// Die Einmalige ObjektID wird erstellt
+	rand_id := utils.RandStringRunes(16)
+
+	// Das Objekt wird vorbereitet
+	result_obj := &TcpKernelServerEP{_obj_id: rand_id, _lock: new(sync.Mutex), _ip_adr: ip_adr, _port: int(port)}
+
+	// Log
+	logging.Logger(logging.TRANSPORT).Info("TcpKernelServerEP: new tcp server endpoint created", "address", ip_adr, "port", port)
+	return result_obj, nil
+}
@@ -28,3 +28,31 @@ const (
 	TORv3      = ClientServerP2PProtocol(8)
 	I2PED      = ClientServerP2PProtocol(9)
 )
+
+// Gibt den Namen des ClientServerP2P Protokolls zurück
+func (obj ClientServerP2PProtocol) String() string {
+	switch obj {
+	case WS_TCP_V4:
+		return "ws_tcp_v4"
+	case WS_TCP_V6:
+		return "ws_tcp_v6"
+	case WS_QUIC_v4:
+		return "ws_quic_v4"
+	case WS_QUIC_v6:
+		return "ws_quic_v6"
+	case QUIC_V4:
+		return "quic_v4"
+	case QUIC_V6:
+		return "quic_v6"
+	case TCP_V4:
+		return "tcp_v4"
+	case TCP_V6:
+		return "tcp_v6"
+	case TORv3:
+		return "torv3"
+	case I2PED:
+		return "i2p"
+	default:
+		return "unkown"
+	}
+}
@@ -2,9 +2,9 @@ package ipoverlay
 
 import (
 	"bytes"
+	"context"
 	"encoding/hex"
 	"fmt"
-	"log"
 	"net"
 	"sync"
 	"time"
@@ -13,43 +13,55 @@ import (
 	addresspackages "github.com/fluffelpuff/RoueX/address_packages"
 	"github.com/fluffelpuff/RoueX/kernel"
 	"github.com/fluffelpuff/RoueX/kernel/extra"
+	"github.com/fluffelpuff/RoueX/logging"
+	"github.com/fluffelpuff/RoueX/static"
 	"github.com/fluffelpuff/RoueX/utils"
-	"github.com/gorilla/websocket"
 )
 
 // Stellt einen write_buffer eintrag dar
 type writer_buffer_entry struct {
-	data   []byte
-	sstate *extra.PackageSendState
-	size   uint64
-	tpe    TransportPackageType
+	data    []byte
+	sstate  *extra.PackageSendState
+	size    uint64
+	tpe     TransportPackageType
+	control bool
 }
 
-// Stellt eine Websocket Verbindung dar
+// Gibt an ob es sich um ein Steuerpaket handelt, Ping und Pong Pakete sind immer Steuerpakete
+func (obj *writer_buffer_entry) is_control() bool {
+	return obj.tpe != Data || obj.control
+}
+
+// Stellt eine Verschlüsselte Kernel Verbindung dar, die Pakete werden über eine Websocket oder TCP Verbindung übertragen
 type WebsocketKernelConnection struct {
-	_object_id               string
-	_local_otk_key_pair      string
-	_total_reader_threads    uint8
-	_total_ping_pong_threads uint8
-	_total_writer_threads    uint8
-	_is_finally              bool
-	_signal_shutdown         bool
-	_disconnected            bool
-	_ping                    []uint64
-	_bandwith                []float64
-	_otk_ecdh_key_id         string
-	_is_connected            bool
-	_destroyed               bool
-	_ping_processes          []*PingProcess
-	_conn                    *websocket.Conn
-	_kernel                  *kernel.Kernel
-	_dest_relay_public_key   *btcec.PublicKey
-	_lock                    *sync.Mutex
-	_write_lock              *sync.Mutex
-	_io_type                 kernel.ConnectionIoType
-	_rx_bytes                uint64
-	_tx_bytes                uint64
-	_write_buffer            chan *writer_buffer_entry
+	_object_id             string
+	_local_otk_key_pair    string
+	_total_reader_threads  uint8
+	_total_writer_threads  uint8
+	_is_finally            bool
+	_signal_shutdown       bool
+	_disconnected          bool
+	_ping                  []uint64
+	_bandwith              []float64
+	_otk_ecdh_key_id       string
+	_is_connected          bool
+	_destroyed             bool
+	_ping_processes        []*PingProcess
+	_conn                  packet_conn
+	_protocol              string
+	_kernel                *kernel.Kernel
+	_dest_relay_public_key *btcec.PublicKey
+	_lock                  *sync.Mutex
+	_write_lock            *sync.Mutex
+	_io_type               kernel.ConnectionIoType
+	_rx_bytes              uint64
+	_tx_bytes              uint64
+	_write_buffer          chan *writer_buffer_entry
+	_write_buffer_bytes    uint64
+	_ctx                   context.Context
+	_cancel                context.CancelFunc
+	_threads               *sync.WaitGroup
+	_closed                chan struct{}
 }
 
 // Registriert einen Kernel in der Verbindung
@@ -61,7 +73,7 @@ func (obj *WebsocketKernelConnection) RegisterKernel(kernel *kernel.Kernel) erro
 	}
 	obj._kernel = kernel
 	obj._lock.Unlock()
-	log.Println("WebsocketKernelConnection: kernel registered. connection =", obj._object_id, "kernel =", obj._kernel.GetKernelID())
+	obj._kernel.Logger(logging.TRANSPORT).Info("WebsocketKernelConnection: kernel registered", "connection", obj._object_id, "kernel", obj._kernel.GetKernelID())
 	return nil
 }
 
@@ -82,7 +94,7 @@ func (obj *WebsocketKernelConnection) _destroy_disconnected() {
 
 		// Der Verbindung wird entfernt
 		if err := obj._kernel.RemoveConnection(obj); err != nil {
-			log.Println("WebsocketKernelConnection: error by destorying conenction. error =", err.Error())
+			obj._kernel.Logger(logging.TRANSPORT).Warn("WebsocketKernelConnection: error by destorying conenction", "error", err.Error())
 		}
 
 		// Das Objekt wird als zerstört Markiert
@@ -113,7 +125,7 @@ func (obj *WebsocketKernelConnection) _add_ping_time(time uint64) {
 		obj._ping = append(obj._ping[:9], obj._ping[1:]...)
 	}
 	obj._lock.Unlock()
-	log.Println("WebsocketKernelConnection: add ping time, connection =", obj._object_id, "time =", time)
+	obj._kernel.Logger(logging.TRANSPORT).Debug("WebsocketKernelConnection: add ping time", "connection", obj._object_id, "time", time)
 }
 
 // Wird ausgeführt um eine neuen Ping Vorgang zu Registrieren
@@ -130,7 +142,7 @@ func (obj *WebsocketKernelConnection) _create_new_ping_session() *PingProcess {
 	obj._lock.Unlock()
 
 	// Die Daten werden zurückgegeben
-	log.Println("WebsocketKernelConnection: new ping process created. connection =", new_proc.ObjectId, "process_id =", hex.EncodeToString(new_proc.ProcessId))
+	obj._kernel.Logger(logging.TRANSPORT).Debug("WebsocketKernelConnection: new ping process created", "connection", new_proc.ObjectId, "process_id", hex.EncodeToString(new_proc.ProcessId))
 	return new_proc
 }
 
@@ -157,9 +169,9 @@ func (obj *WebsocketKernelConnection) _remove_ping_session(psession *PingProcess
 	// Sollte ein passender Eintrag gefunden wurden sein, wird dieser Entfernt
 	if is_found {
 		obj._ping_processes = append(obj._ping_processes[:hight], obj._ping_processes[hight+1:]...)
-		log.Println("WebsocketKernelConnection: ping process removed. connection =", psession.ObjectId, "process_id =", hex.EncodeToString(psession.ProcessId))
+		obj._kernel.Logger(logging.TRANSPORT).Debug("WebsocketKernelConnection: ping process removed", "connection", psession.ObjectId, "process_id", hex.EncodeToString(psession.ProcessId))
 	} else {
-		log.Println("WebsocketKernelConnection: no ping process found to removing. connection =", psession.ObjectId, "process_id =", hex.EncodeToString(psession.ProcessId))
+		obj._kernel.Logger(logging.TRANSPORT).Debug("WebsocketKernelConnection: no ping process found to removing", "connection", psession.ObjectId, "process_id", hex.EncodeToString(psession.ProcessId))
 	}
 
 	// Threadlock freigabe
@@ -170,26 +182,58 @@ func (obj *WebsocketKernelConnection) _remove_ping_session(psession *PingProcess
 func (obj *WebsocketKernelConnection) _enter_incomming_data_package(data []byte) {
 	// Es wird geprüft ob das Paket größer als 30 Bytes ist
 	if len(data) < 30 {
-		log.Println("WebsocketKernelConnection: invalid data package recived. connection =", obj._object_id)
+		obj._kernel.Logger(logging.TRANSPORT).Warn("WebsocketKernelConnection: invalid data package recived", "connection", obj._object_id)
+		obj._kernel.ReportInvalidPackage(obj)
 	}
 
 	// Es wird versucht das Package Frame einzulesen
 	readed_package, err := addresspackages.ReadSendableAddressLayerPackageFromBytes(data)
 	if err != nil {
-		log.Println("WebsocketKernelConnection: error by reading, package droped. error = "+err.Error(), "connection = "+obj._object_id)
+		obj._kernel.Logger(logging.TRANSPORT).Warn("WebsocketKernelConnection: error by reading, package droped", "connection", obj._object_id, "error", err.Error())
+		obj._kernel.ReportInvalidPackage(obj)
 		return
 	}
 
-	// Es wird geprüft ob die Signatur korrekt ist
-	if !readed_package.ValidateSignature() {
-		log.Println("WebsocketKernelConnection: error by reading, package droped. error = invalid package siganture. connection = " + obj._object_id)
+	// Das Paket wird an den Kernel übergeben, dieser prüft die Signatur
+	if err := obj._kernel.EnterL2Package(readed_package, obj); err != nil {
+		obj._kernel.Logger(logging.TRANSPORT).Warn("WebsocketKernelConnection: package rejected by kernel", "connection", obj._object_id, "error", err.Error())
+		return
 	}
+}
 
-	// Das Paket wird an den Kernel übergeben
-	if err := obj._kernel.EnterL2Package(readed_package, obj); err != nil {
-		log.Println(err)
+// Legt einen Eintrag in den Schreibpuffer und rechnet die Größe dem Puffer hinzu
+func (obj *WebsocketKernelConnection) _enqueue_write_buffer(entry *writer_buffer_entry) {
+	// Sollte die Verbindung Steuerpakete getrennt übertragen, werden Ping, Pong und Steuerpakete der Protokolle am Schreibpuffer
+	// vorbei gesendet, so müssen sie nicht hinter großen Datenpaketen warten
+	if _, ok := obj._conn.(priority_packet_conn); ok && entry.is_control() {
+		if err := obj._write_ws_package(entry.data, entry.tpe, true); err != nil {
+			entry.sstate.SetFinallyState(extra.DROPED)
+			return
+		}
+		entry.sstate.SetFinallyState(extra.SEND)
+		return
+	}
+
+	// Sollte die Verbindung bereits getrennt worden sein, wird der Eintrag verworfen
+	if obj._ctx.Err() != nil {
+		entry.sstate.SetFinallyState(extra.DROPED)
 		return
 	}
+
+	// Die Größe des Eintrages wird hinzugerechnet
+	obj._lock.Lock()
+	obj._write_buffer_bytes += entry.size
+	obj._lock.Unlock()
+
+	// Der Eintrag wird in dem Buffer zwischengespeichert, sollte die Verbindung währenddessen getrennt werden, wird der Eintrag verworfen
+	select {
+	case obj._write_buffer <- entry:
+	case <-obj._ctx.Done():
+		obj._lock.Lock()
+		obj._write_buffer_bytes -= entry.size
+		obj._lock.Unlock()
+		entry.sstate.SetFinallyState(extra.DROPED)
+	}
 }
 
 // Wird verwendet um ein Ping abzusenden und auf das Pong zu warten
@@ -210,7 +254,7 @@ func (obj *WebsocketKernelConnection) _send_ping_and_wait_of_pong() (uint64, err
 	}
 
 	// Log
-	log.Println("WebsocketKernelConnection: send ping package. connection =", obj._object_id, "process_id =", hex.EncodeToString(new_ping_session.ProcessId))
+	obj._kernel.Logger(logging.TRANSPORT).Debug("WebsocketKernelConnection: send ping package", "connection", obj._object_id, "process_id", hex.EncodeToString(new_ping_session.ProcessId))
 	/*if err := obj._write_ws_package(package_bytes, Ping); err != nil {
 		return 0, err
 	}
@@ -220,16 +264,17 @@ func (obj *WebsocketKernelConnection) _send_ping_and_wait_of_pong() (uint64, err
 	revobj := extra.NewPackageSendState()
 
 	// Der Eintrag wird in dem Buffer zwischengespeichert
-	obj._write_buffer <- &writer_buffer_entry{data: package_bytes, sstate: revobj, size: uint64(len(package_bytes)), tpe: Ping}
+	obj._enqueue_write_buffer(&writer_buffer_entry{data: package_bytes, sstate: revobj, size: uint64(len(package_bytes)), tpe: Ping})
 
 	// Es wird auf die Antwort des Paketes gewartet
-	r_time, err := new_ping_session.untilWaitOfPong()
+	r_time, err := new_ping_session.untilWaitOfPong(obj._ctx)
 	if err != nil {
+		obj._remove_ping_session(new_ping_session)
 		return 0, err
 	}
 
 	// Log
-	log.Println("WebsocketKernelConnection: ping for connection =", obj._object_id, "process_id =", hex.EncodeToString(new_ping_session.ProcessId), "time =", r_time)
+	obj._kernel.Logger(logging.TRANSPORT).Debug("WebsocketKernelConnection: ping completed", "connection", obj._object_id, "process_id", hex.EncodeToString(new_ping_session.ProcessId), "time", r_time)
 
 	// Der Ping Vorgang wird wieder entfernt
 	obj._remove_ping_session(new_ping_session)
@@ -242,71 +287,64 @@ func (obj *WebsocketKernelConnection) _send_ping_and_wait_of_pong() (uint64, err
 func (obj *WebsocketKernelConnection) __first_ping_io_activated_routes_by_relay_connection() {
 	// Es wird dem Kernel signalisiert dass alle bekannten Routen für die Relay Verbindung geladen werden sollen
 	if complete, was_added := obj._kernel.DumpsRoutesForRelayByConnection(obj); complete && was_added {
-		log.Println("WebsocketKernelConnection: routes initing completed. connection =", obj._object_id)
+		obj._kernel.Logger(logging.TRANSPORT).Info("WebsocketKernelConnection: routes initing completed", "connection", obj._object_id)
 	}
 }
 
 // Gibt an ob der Ping Pong test korrekt ist
 func (obj *WebsocketKernelConnection) __ping_auto_thread_pong() {
-	// Speichert die Zeit ab, wann der Letze Ping durchgeführt wurde
-	last_ping := time.Now()
+	// Signalisiert dass der Ping Pong Thread beendet wurde
+	defer obj._threads.Done()
+
+	// Der erste Ping wird nach einer Sekunde durchgeführt, alle weiteren im Abstand von 10 Sekunden
+	next_ping := time.NewTimer(1 * time.Second)
+	defer next_ping.Stop()
 
 	// Gibt an ob es sich um den ersten Start handelt
 	is_first := true
 
 	// Log
-	log.Println("WebsocketKernelConnection: connection ping pong thread started. connection =", obj._object_id)
-
-	// Signalisiert dass ein Ping Pong Thread vorhanden ist
-	obj._lock.Lock()
-	obj._total_ping_pong_threads++
-	obj._lock.Unlock()
+	obj._kernel.Logger(logging.TRANSPORT).Info("WebsocketKernelConnection: connection ping pong thread started", "connection", obj._object_id)
 
 	// Wird solange ausgeführt, solange die Verbindung verbunden ist
 	for obj._loop_bckg_run() {
-		// Zeitdauer seit last_ping messen
-		elapsed := time.Since(last_ping)
+		// Es wird gewartet bis der nächste Ping fällig ist oder die Verbindung getrennt wurde
+		select {
+		case <-obj._ctx.Done():
+		case <-next_ping.C:
+		}
 
-		// Überprüfen, ob 10 Sekunden vergangen sind
-		if elapsed.Seconds() >= 10 || (elapsed.Seconds() >= 1 && is_first) {
-			// Es wird geprüft ob eine Verbindung mit der gegenseite besteht, wenn nicht wird der Vorgang abgebrochen
-			if !obj.IsConnected() {
-				break
-			}
+		// Es wird geprüft ob eine Verbindung mit der gegenseite besteht, wenn nicht wird der Vorgang abgebrochen
+		if !obj.IsConnected() {
+			break
+		}
 
-			// Es wird ein Ping vorgang durchgeführt
-			w_time, err := obj._send_ping_and_wait_of_pong()
-			if err != nil {
-				log.Println(err)
-				continue
-			}
+		// Es wird ein Ping vorgang durchgeführt
+		w_time, err := obj._send_ping_and_wait_of_pong()
+		if err != nil {
+			obj._kernel.Logger(logging.TRANSPORT).Warn("WebsocketKernelConnection: ping failed", "connection", obj._object_id, "error", err.Error())
+			next_ping.Reset(1 * time.Second)
+			continue
+		}
 
-			// Die Pingzeit wird abgespeichert
-			obj._add_ping_time(w_time)
+		// Die Pingzeit wird abgespeichert
+		obj._add_ping_time(w_time)
 
-			// Es wird geprüft ob es sich um den ersten Ping vorgang handelt
-			if is_first {
-				// Es wird signalisiert dass alle Routen welche für diesen Relay verfügabr sind, geladen werden sollen
-				go obj.__first_ping_io_activated_routes_by_relay_connection()
+		// Es wird geprüft ob es sich um den ersten Ping vorgang handelt
+		if is_first {
+			// Es wird signalisiert dass alle Routen welche für diesen Relay verfügabr sind, geladen werden sollen
+			go obj.__first_ping_io_activated_routes_by_relay_connection()
 
-				// Es wird signalisiert dass es sich nicht mehr um den ersten Vorgang handelt
-				is_first = false
-			}
-
-			// Speichert die Zeit des Pings ab
-			last_ping = time.Now()
-		} else {
-			time.Sleep(1 * time.Millisecond)
+			// Es wird signalisiert dass es sich nicht mehr um den ersten Vorgang handelt
+			is_first = false
 		}
-	}
 
-	// Signalisiert dass ein Ping Pong Thread geschlossen wurde
-	obj._lock.Lock()
-	obj._total_ping_pong_threads--
-	obj._lock.Unlock()
+		// Der nächste Ping wird in 10 Sekunden durchgeführt
+		next_ping.Reset(10 * time.Second)
+	}
 
 	// Log
-	log.Println("WebsocketKernelConnection: connection ping pong thread stoped. connection =", obj._object_id)
+	obj._kernel.Logger(logging.TRANSPORT).Info("WebsocketKernelConnection: connection ping pong thread stoped", "connection", obj._object_id)
 }
 
 // Sendet dem Client ein Pong Paket zu
@@ -329,10 +367,10 @@ func (obj *WebsocketKernelConnection) __send_pong(ping_id []byte) error {
 	revobj := extra.NewPackageSendState()
 
 	// Der Eintrag wird in dem Buffer zwischengespeichert
-	obj._write_buffer <- &writer_buffer_entry{data: pong_package_bytes, sstate: revobj, size: uint64(len(pong_package_bytes)), tpe: Pong}
+	obj._enqueue_write_buffer(&writer_buffer_entry{data: pong_package_bytes, sstate: revobj, size: uint64(len(pong_package_bytes)), tpe: Pong})
 
 	// Der Vorgang wurde ohne fehler durchgeführt
-	log.Println("WebsocketKernelConnection: pong package send. pingid =", hex.EncodeToString(ping_id))
+	obj._kernel.Logger(logging.TRANSPORT).Debug("WebsocketKernelConnection: pong package send", "pingid", hex.EncodeToString(ping_id))
 	return nil
 }
 
@@ -341,13 +379,13 @@ func (obj *WebsocketKernelConnection) __recived_ping_paket(data []byte) {
 	// Es wird versucht das Ping Paket einzulesen
 	ping_package, err := readPingPackageFromBytes(data)
 	if err != nil {
-		log.Println("Pong package sening error. pingid =", hex.EncodeToString(ping_package.PingId))
+		obj._kernel.Logger(logging.TRANSPORT).Warn("Pong package sening error", "pingid", hex.EncodeToString(ping_package.PingId))
 	}
 
 	// Es wird versucht das Pong Paket zu senden
 	if err := obj.__send_pong(ping_package.PingId); err != nil {
 		if obj.IsConnected() {
-			log.Println("__recived_ping_paket:" + err.Error())
+			obj._kernel.Logger(logging.TRANSPORT).Warn("WebsocketKernelConnection: error by sending pong package", "connection", obj._object_id, "error", err.Error())
 		}
 	}
 }
@@ -357,7 +395,7 @@ func (obj *WebsocketKernelConnection) __recived_pong_paket(data []byte) {
 	// Es wird versucht das Ping Paket einzulesen
 	pong_package, err := readPongPackageFromBytes(data)
 	if err != nil {
-		log.Println("WebsocketKernelConnection: pong package reading error, aborted.")
+		obj._kernel.Logger(logging.TRANSPORT).Warn("WebsocketKernelConnection: pong package reading error, aborted")
 		return
 	}
 
@@ -374,21 +412,13 @@ func (obj *WebsocketKernelConnection) __recived_pong_paket(data []byte) {
 
 	// Sollte ein Prozess vorhanden sein, wird diesem Signalisiert dass eine Antwort empfangen wurde
 	if result != nil {
-		log.Println("WebsocketKernelConnection: pong recived", hex.EncodeToString(pong_package.PingId))
+		obj._kernel.Logger(logging.TRANSPORT).Debug("WebsocketKernelConnection: pong recived", "pingid", hex.EncodeToString(pong_package.PingId))
 		result._signal_recived_pong()
 	} else {
-		log.Println("WebsocketKernelConnection: pong recived, unkown pong process", hex.EncodeToString(pong_package.PingId))
+		obj._kernel.Logger(logging.TRANSPORT).Debug("WebsocketKernelConnection: pong recived, unkown pong process", "pingid", hex.EncodeToString(pong_package.PingId))
 	}
 }
 
-// Gibt an, wieviele Ping Ping Processes vorhanden sind
-func (obj *WebsocketKernelConnection) _ping_ponger_is_open() bool {
-	obj._lock.Lock()
-	r := obj._total_ping_pong_threads
-	obj._lock.Unlock()
-	return r > 0
-}
-
 // Signalisiert dass die Verbindung gerennt wurde
 func (obj *WebsocketKernelConnection) _signal_disconnect() {
 	// Signalisiert dass die Verbindung getrennt wurde
@@ -396,10 +426,12 @@ func (obj *WebsocketKernelConnection) _signal_disconnect() {
 	obj._disconnected = true
 	obj._lock.Unlock()
 
-	// Es wird gewartet bis alle Ping Pong Threads geschlossen wurden
-	for obj._ping_ponger_is_open() {
-		time.Sleep(1 * time.Millisecond)
-	}
+	// Alle Threads der Verbindung werden abgebrochen, die Verbindung wird geschlossen damit blockierende Schreibvorgänge enden
+	obj._cancel()
+	_ = obj._conn.Close()
+
+	// Es wird gewartet bis der Ping Pong Thread sowie der Writer geschlossen wurden
+	obj._threads.Wait()
 }
 
 // Nimmt eintreffende Pakete entgegen
@@ -410,18 +442,30 @@ func (obj *WebsocketKernelConnection) _start_thread_reader() error {
 	// Gibt an ob die Lesende Schleife beendet wurde
 	var has_closed_reader_loop error
 
+	// Wird geschlossen sobald der Reader ausgeführt wird
+	reader_ready := make(chan struct{})
+
+	// Wird geschlossen sobald der Reader vollständig beendet wurde
+	obj._lock.Lock()
+	obj._closed = make(chan struct{})
+	obj._lock.Unlock()
+
 	// Der Reader Thread wird gestartet
 	go func() {
+		// Es wird Signalisiert dass der Reader vollständig beendet wurde
+		defer close(obj._closed)
+
 		// Der Thread Signalisiert dass er ausgeführt wird
 		obj._lock.Lock()
 		obj._total_reader_threads++
 		obj._is_connected = true
 		obj._lock.Unlock()
+		close(reader_ready)
 
 		// Diese Schleife wird solange ausgeführt bis die Verbindung getrennt / geschlossen wurde
 		for obj._loop_bckg_run() {
 			// Es wird auf eintreffende Pakete gewartet
-			messageType, message, err := obj._conn.ReadMessage()
+			message, err := obj._conn.ReadPacket()
 			if err != nil {
 				obj._lock.Lock()
 				if obj._signal_shutdown {
@@ -440,11 +484,6 @@ func (obj *WebsocketKernelConnection) _start_thread_reader() error {
 			obj._rx_bytes += uint64(len(message))
 			obj._lock.Unlock()
 
-			// Überprüfen Sie, ob der Nachrichtentyp "binary" ist
-			if messageType != websocket.BinaryMessage {
-				continue
-			}
-
 			// Das Paket wird anahnd des OTK Schlüssels entschlüsselt
 			decrypted_package, err := obj._kernel.DecryptOTKECDHById(utils.CHACHA_2020, obj._otk_ecdh_key_id, message)
 			if err != nil {
@@ -472,6 +511,7 @@ func (obj *WebsocketKernelConnection) _start_thread_reader() error {
 				break
 			}
 			if !is_verify {
+				obj._kernel.ReportInvalidPackage(obj)
 				func_muutx.Lock()
 				has_closed_reader_loop = fmt.Errorf("invalid package signature")
 				func_muutx.Unlock()
@@ -512,7 +552,7 @@ func (obj *WebsocketKernelConnection) _start_thread_reader() error {
 		}
 
 		// Log
-		log.Println("WebsocketKernelConnection: connection closed. connection =", obj._object_id)
+		obj._kernel.Logger(logging.TRANSPORT).Info("WebsocketKernelConnection: connection closed", "connection", obj._object_id)
 
 		// Signalisiert dass die Verbindung getrennt wurde
 		obj._signal_disconnect()
@@ -527,10 +567,8 @@ func (obj *WebsocketKernelConnection) _start_thread_reader() error {
 		obj._destroy_disconnected()
 	}()
 
-	// Es wird auf die Bestätigung durch den Reader gewartet (20ms)
-	for i := 1; i <= 20; i++ {
-		time.Sleep(1 * time.Millisecond)
-	}
+	// Es wird auf die Bestätigung durch den Reader gewartet
+	<-reader_ready
 
 	// Es wird geprüft ob ein Fehler aufgetreten ist
 	func_muutx.Lock()
@@ -550,24 +588,56 @@ func (obj *WebsocketKernelConnection) _start_thread_reader() error {
 
 // Wird als Thread ausgeführt und versendet ausgehende Pakete
 func (obj *WebsocketKernelConnection) _start_thread_writer() error {
-	// Gibt an ob der Writer gestartet wurde
-	was_started := false
+	// Sollte die Verbindung bereits getrennt worden sein, wird kein Writer mehr gestartet
+	obj._lock.Lock()
+	if obj._disconnected {
+		obj._lock.Unlock()
+		return fmt.Errorf("_start_thread_writer: connection was closed by initing")
+	}
+
+	// Es wird Signalisiert dass der Writer ausgeführt wird
+	obj._total_writer_threads++
+	obj._threads.Add(1)
+	obj._lock.Unlock()
 
 	// Der Writer wird als Thread ausgeführt
 	go func() {
-		// Es wird Signalisiert dass der Writer ausgeführt wird
-		obj._lock.Lock()
-		obj._total_writer_threads++
-		was_started = true
-		obj._lock.Unlock()
+		// Es wird Signalisiert dass der Writer nicht mehr ausgeführt wird
+		defer func() {
+			obj._lock.Lock()
+			obj._total_writer_threads--
+			obj._lock.Unlock()
+			obj._threads.Done()
+		}()
 
 		// Die Schleife wird solange ausgeführt, bis die Verbindung getrennt wurde
-		for obj.IsConnected() {
+		for {
 			// Die Aktuellen Daten werden ausgelesen
-			r_data := <-obj._write_buffer
+			var r_data *writer_buffer_entry
+			select {
+			case <-obj._ctx.Done():
+				// Alle noch nicht gesendeten Einträge werden verworfen
+				for {
+					select {
+					case r_data = <-obj._write_buffer:
+						obj._lock.Lock()
+						obj._write_buffer_bytes -= r_data.size
+						obj._lock.Unlock()
+						r_data.sstate.SetFinallyState(extra.DROPED)
+					default:
+						return
+					}
+				}
+			case r_data = <-obj._write_buffer:
+			}
+
+			// Die Größe des Eintrages wird vom Puffer abgezogen
+			obj._lock.Lock()
+			obj._write_buffer_bytes -= r_data.size
+			obj._lock.Unlock()
 
 			// Die Daten werden gesendet
-			if err := obj._write_ws_package(r_data.data, r_data.tpe); err != nil {
+			if err := obj._write_ws_package(r_data.data, r_data.tpe, r_data.is_control()); err != nil {
 				// Es wird Signalisiert dass die Daten nicht gesendet werden konnten
 				r_data.sstate.SetFinallyState(extra.DROPED)
 
@@ -578,30 +648,14 @@ func (obj *WebsocketKernelConnection) _start_thread_writer() error {
 			// Es wird Signalisiert dass das Paket erfolgreich gesendet wurde
 			r_data.sstate.SetFinallyState(extra.SEND)
 		}
-
-		// Es wird Signalisiert dass der Writer nicht mehr ausgeführt wird
-		obj._lock.Lock()
-		obj._total_writer_threads--
-		obj._lock.Unlock()
 	}()
 
-	// Es wird gewartet bis der Writer gestartet wurde
-	for {
-		obj._lock.Lock()
-		if was_started {
-			obj._lock.Unlock()
-			break
-		}
-		obj._lock.Unlock()
-		time.Sleep(1 * time.Millisecond)
-	}
-
 	// Der Vorgang wurde ohne Fehler gestartet
 	return nil
 }
 
-// Wird verwendet um ein Paket Abzusenden
-func (obj *WebsocketKernelConnection) _write_ws_package(data []byte, tpe TransportPackageType) error {
+// Wird verwendet um ein Paket Abzusenden, Steuerpakete werden sofern möglich getrennt übertragen
+func (obj *WebsocketKernelConnection) _write_ws_package(data []byte, tpe TransportPackageType, control bool) error {
 	// Das Transportpaket wird vorbereitet
 	transport_package := EncryptedTransportPackage{Type: tpe, Data: data}
 
@@ -638,8 +692,13 @@ func (obj *WebsocketKernelConnection) _write_ws_package(data []byte, tpe Transpo
 		return err
 	}
 
-	// Das fertige Paket wird übertragen, hierfür wird der IO-Lock verwendet
-	if err := obj._conn.WriteMessage(websocket.BinaryMessage, final_encrypted); err != nil {
+	// Das fertige Paket wird übertragen, Steuerpakete werden sofern möglich getrennt übertragen
+	if pconn, ok := obj._conn.(priority_packet_conn); ok && control {
+		err = pconn.WriteControlPacket(final_encrypted)
+	} else {
+		err = obj._conn.WritePacket(final_encrypted)
+	}
+	if err != nil {
 		return fmt.Errorf("_write_ws_package: " + err.Error())
 	}
 
@@ -649,34 +708,10 @@ func (obj *WebsocketKernelConnection) _write_ws_package(data []byte, tpe Transpo
 	obj._lock.Unlock()
 
 	// Der Vorgang wurde ohne Fehler erfolgreich druchgeführt
-	log.Println("WebsocketKernelConnection: bytes writed. connection =", obj._object_id, "size =", len(final_encrypted))
+	obj._kernel.Logger(logging.TRANSPORT).Debug("WebsocketKernelConnection: bytes writed", "connection", obj._object_id, "size", len(final_encrypted))
 	return nil
 }
 
-// Gibt an ob die Websocket Verbindung geschlossen wurde
-func (obj *WebsocketKernelConnection) _check_is_full_closed() bool {
-	// Der Threadlock wird verwendet
-	obj._lock.Lock()
-
-	// Es wird ermittelt ob der Socket verbunden ist
-	r := !obj._is_connected
-
-	// Es wird ermittelt wieviele Ping Prozesse vorhanden sind
-	p := len(obj._ping_processes) == 0
-
-	// Es wird ermittelt ob alle Reader geschlossen wurden
-	t := obj._total_reader_threads == 0
-
-	// Gibt an ob das Objekt zerstört wurde
-	x := obj._destroyed
-
-	// Der Threadlock wird freigegeben
-	obj._lock.Unlock()
-
-	// Das Ergebniss wird zurückgegeben
-	return r && p && t && x
-}
-
 // Stellt die Verbindung vollständig fertig
 func (obj *WebsocketKernelConnection) FinallyInit() error {
 	// Es wird geprüft ob bereits ein Reader gestartet wurde
@@ -706,19 +741,22 @@ func (obj *WebsocketKernelConnection) FinallyInit() error {
 
 	// Es wird Signalisiert dass das Ojekt vollständig Finallisiert wurde
 	obj._is_finally = true
-	obj._lock.Unlock()
 
 	// Der Ping Bandwith Thread wird gestartet, dieser ermittelt die Bandbreite sowie den Ping für diese Verbindung
-	go obj.__ping_auto_thread_pong()
+	if !obj._disconnected {
+		obj._threads.Add(1)
+		go obj.__ping_auto_thread_pong()
+	}
+	obj._lock.Unlock()
 
 	// Der Vorgang wurde ohne einen Fehler durchgeführt
-	log.Println("WebsocketKernelConnection: finally connection. connection =", obj._object_id)
+	obj._kernel.Logger(logging.TRANSPORT).Info("WebsocketKernelConnection: finally connection", "connection", obj._object_id)
 	return nil
 }
 
 // Gibt das verwendete Protokoll an
 func (obj *WebsocketKernelConnection) GetProtocol() string {
-	return "wstcp"
+	return obj._protocol
 }
 
 // Gibt an ob eine Verbindung aufgebaut wurde
@@ -732,6 +770,11 @@ func (obj *WebsocketKernelConnection) IsConnected() bool {
 	return r && t == 1 && !s && !x
 }
 
+// Gibt einen Kanal zurück, welcher geschlossen wird sobald die Verbindung getrennt wurde
+func (obj *WebsocketKernelConnection) Done() <-chan struct{} {
+	return obj._ctx.Done()
+}
+
 // Gibt die Aktuelle Objekt ID aus
 func (obj *WebsocketKernelConnection) GetObjectId() string {
 	return obj._object_id
@@ -742,20 +785,21 @@ func (obj *WebsocketKernelConnection) CloseByKernel() {
 	// Der Threadlock wird verwendet
 	obj._lock.Lock()
 
-	// Es wird geprüft ob bereits Signalisiert wurde ob die Verbindung geschlossen werden soll
-	if obj._signal_shutdown {
-		obj._lock.Unlock()
-		return
-	}
+	// Wird geschlossen sobald der Reader vollständig beendet wurde
+	closed := obj._closed
 
-	// Es wird geprüft ob die Verbindung bereits getrennt wurde
-	if !obj._is_connected {
+	// Es wird geprüft ob bereits Signalisiert wurde ob die Verbindung geschlossen werden soll oder die Verbindung bereits getrennt wurde,
+	// in diesem Fall wird nur gewartet bis der Reader beendet wurde
+	if obj._signal_shutdown || !obj._is_connected {
 		obj._lock.Unlock()
+		if closed != nil {
+			<-closed
+		}
 		return
 	}
 
 	// Log
-	log.Println("WebsocketKernelConnection: closing connection by kernel. connection =", obj._object_id)
+	obj._kernel.Logger(logging.TRANSPORT).Info("WebsocketKernelConnection: closing connection by kernel", "connection", obj._object_id)
 
 	// Es wird Signalisiert dass es sich um einen Schließvorgnag handelt
 	obj._signal_shutdown = true
@@ -767,12 +811,10 @@ func (obj *WebsocketKernelConnection) CloseByKernel() {
 	obj._lock.Unlock()
 
 	// Wartet solange bis die Schleife geschlossen wurde
-	for !obj._check_is_full_closed() {
-		time.Sleep(1 * time.Millisecond)
-	}
+	<-closed
 
 	// Log
-	log.Println("WebsocketKernelConnection: connection closed by kernel. connection =", obj._object_id)
+	obj._kernel.Logger(logging.TRANSPORT).Info("WebsocketKernelConnection: connection closed by kernel", "connection", obj._object_id)
 }
 
 // Gibt die Aktuelle Ping Zeit zurück
@@ -838,26 +880,63 @@ func (obj *WebsocketKernelConnection) EnterSendableData(data []byte) (*extra.Pac
 	revobj := extra.NewPackageSendState()
 
 	// Der Eintrag wird in dem Buffer zwischengespeichert
-	obj._write_buffer <- &writer_buffer_entry{data: data, sstate: revobj, size: uint64(len(data)), tpe: Data}
+	obj._enqueue_write_buffer(&writer_buffer_entry{data: data, sstate: revobj, size: uint64(len(data)), tpe: Data})
+
+	// Der Vorgang wurde ohne Fehler erfolreich fertigestellt
+	return revobj, nil
+}
+
+// Wird verwendet um Steuerpakete der Protokolle entgegenzunehmen, diese werden sofern möglich am Schreibpuffer vorbei gesendet
+func (obj *WebsocketKernelConnection) EnterSendableControlData(data []byte) (*extra.PackageSendState, error) {
+	// Das Rückgabe Objekt wird gebaut
+	revobj := extra.NewPackageSendState()
+
+	// Der Eintrag wird übertragen oder im Buffer zwischengespeichert
+	obj._enqueue_write_buffer(&writer_buffer_entry{data: data, sstate: revobj, size: uint64(len(data)), tpe: Data, control: true})
 
 	// Der Vorgang wurde ohne Fehler erfolreich fertigestellt
 	return revobj, nil
 }
 
+// Gibt an ob sich noch nicht gesendete Pakete im Schreibpuffer befinden
+func (obj *WebsocketKernelConnection) HasPendingWrites() bool {
+	obj._lock.Lock()
+	defer obj._lock.Unlock()
+	return len(obj._write_buffer) > 0 || obj._write_buffer_bytes > 0
+}
+
+// Gibt die Anzahl der Pakete und Bytes zurück, welche sich im Schreibpuffer befinden
+func (obj *WebsocketKernelConnection) GetWriteBufferUsage() (uint64, uint64) {
+	obj._lock.Lock()
+	defer obj._lock.Unlock()
+	return uint64(len(obj._write_buffer)), obj._write_buffer_bytes
+}
+
 // Gibt an ob der Buffer der Verbindung das Schreiben zu lässt
 func (obj *WebsocketKernelConnection) CannUseToWrite() bool {
 	// Der Threadlock wird ausgeführt
 	obj._lock.Lock()
 	defer obj._lock.Unlock()
 
-	// Der Vorgang wurde ohne Fehler erfolreich fertigestellt
-	return !(len(obj._write_buffer) >= 128)
+	// Es wird geprüft ob die Maximale Anzahl an Paketen erreicht wurde
+	if len(obj._write_buffer) >= int(static.LIMITS.WSMaxPackages) {
+		return false
+	}
+
+	// Es wird geprüft ob die Maximale Anzahl an Bytes erreicht wurde
+	return obj._write_buffer_bytes < static.LIMITS.WSMaxBytes
 }
 
 // Erstellt ein neues Kernel Sitzungs Objekt
-func createFinallyKernelConnection(conn *websocket.Conn, local_otk_key_pair_id string, relay_public_key *btcec.PublicKey, relay_otk_public_key *btcec.PublicKey, relay_otk_ecdh_key_id string, bandwith float64, ping_time uint64, io_type kernel.ConnectionIoType, local_socket *net.TCPAddr, remote_socket *net.TCPAddr) (*WebsocketKernelConnection, error) {
+func createFinallyKernelConnection(conn packet_conn, protocol string, local_otk_key_pair_id string, relay_public_key *btcec.PublicKey, relay_otk_public_key *btcec.PublicKey, relay_otk_ecdh_key_id string, bandwith float64, ping_time uint64, io_type kernel.ConnectionIoType, local_socket net.Addr, remote_socket net.Addr) (*WebsocketKernelConnection, error) {
+	// Der Kontext wird erzeugt, dieser wird abgebrochen sobald die Verbindung getrennt wurde
+	ctx, cancel := context.WithCancel(context.Background())
+
 	// Das Objekt wird erstellt
 	wkcobj := &WebsocketKernelConnection{
+		_ctx:                   ctx,
+		_cancel:                cancel,
+		_threads:               new(sync.WaitGroup),
 		_object_id:             utils.RandStringRunes(12),
 		_local_otk_key_pair:    local_otk_key_pair_id,
 		_dest_relay_public_key: relay_public_key,
@@ -866,16 +945,17 @@ func createFinallyKernelConnection(conn *websocket.Conn, local_otk_key_pair_id s
 		_otk_ecdh_key_id:       relay_otk_ecdh_key_id,
 		_ping:                  []uint64{ping_time},
 		_bandwith:              []float64{bandwith},
-		_write_buffer:          make(chan *writer_buffer_entry, 128),
+		_write_buffer:          make(chan *writer_buffer_entry, static.LIMITS.WSMaxPackages),
 		_io_type:               io_type,
 		_conn:                  conn,
+		_protocol:              protocol,
 		_signal_shutdown:       false,
 		_total_writer_threads:  0,
 		_total_reader_threads:  0,
 	}
 
 	// Log
-	log.Println(fmt.Sprintf("WebsocketKernelConnection: new finally connection. form = %s -> to local %s @ %s", remote_socket.String(), local_socket.String(), hex.EncodeToString(relay_public_key.SerializeCompressed())))
+	logging.Logger(logging.TRANSPORT).Info("WebsocketKernelConnection: new finally connection", "from", remote_socket.String(), "local", local_socket.String(), "relay", hex.EncodeToString(relay_public_key.SerializeCompressed()))
 
 	// Das Objekt wird zurückgegben
 	return wkcobj, nil
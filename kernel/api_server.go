@@ -1,13 +1,13 @@
 package kernel
 
 import (
+	"context"
 	"fmt"
-	"log"
+	"log/slog"
 	"net"
 	"net/rpc"
 	"os"
 	"sync"
-	"time"
 
 	"github.com/fluffelpuff/RoueX/static"
 	"github.com/fluffelpuff/RoueX/utils"
@@ -15,7 +15,7 @@ import (
 
 // Stellt die KernelAPI dar
 type KernelAPI struct {
-	_process_connections []*APIProcessConnectionWrapper
+	_process_connections map[string]*APIProcessConnectionWrapper
 	_socket              net.Listener
 	_lock                sync.Mutex
 	_socket_unix_path    string
@@ -23,6 +23,10 @@ type KernelAPI struct {
 	_is_running          bool
 	_signal_shutdown     bool
 	_object_id           string
+	_ctx                 context.Context
+	_cancel              context.CancelFunc
+	_threads             sync.WaitGroup
+	_log                 *slog.Logger
 }
 
 // Registriert den Kernel in der API
@@ -31,38 +35,15 @@ func (obj *KernelAPI) _register_kernel(kernel *Kernel) error {
 	obj._kernel = kernel
 	obj._lock.Unlock()
 
-	log.Println("KernelAPI: registered in kernel. id =", obj._object_id, ", kernel =", kernel.GetKernelID(), ", path =", obj._socket_unix_path)
+	obj._log.Info("KernelAPI: registered in kernel", "id", obj._object_id, "kernel", kernel.GetKernelID(), "path", obj._socket_unix_path)
 	return nil
 }
 
-// Gibt an ob die Acceptor Schleife ausgeführt werden soll
-func (obj *KernelAPI) _ral() bool {
-	obj._lock.Lock()
-	r := obj._signal_shutdown
-	obj._lock.Unlock()
-	return !r
-}
-
 // Gibt an ob die API noch ausgeführt wird
 func (obj *KernelAPI) _irn() bool {
-	// Es wird geprüft
 	obj._lock.Lock()
 	defer obj._lock.Unlock()
-
-	// Es wird geprüft ob ein Shutdown Signal eingegangen ist
-	if !obj._signal_shutdown {
-		return true
-	}
-
-	// Es wird geprüft ob die Verbindung vorhanden ist
-	for i := range obj._process_connections {
-		if obj._process_connections[i].IsConnected() {
-			return true
-		}
-	}
-
-	// Der Endgültüge Status wird zurückgegeben
-	return obj._is_running
+	return obj._is_running || len(obj._process_connections) > 0
 }
 
 // Handelt eine neue Verbindung
@@ -74,7 +55,7 @@ func (obj *KernelAPI) _handle_conn(conn net.Conn) {
 	process_id := utils.RandStringRunes(16)
 
 	// Das Wrapper Objekt wird erzeugt
-	wrapper_obj := &APIProcessConnectionWrapper{conn: conn, lock: new(sync.Mutex), isconn: true, id: process_id, service_map: make(map[string]APIConnectionLiveService)}
+	wrapper_obj := &APIProcessConnectionWrapper{conn: conn, lock: new(sync.Mutex), isconn: true, id: process_id, closed: make(chan struct{}), close_once: new(sync.Once), service_map: make(map[string]APIConnectionLiveService), log: obj._log}
 
 	// Die Funktionen werden bereitgestellt
 	pkf := &Kf{_kernel: obj._kernel, _process_id: process_id, _connection: wrapper_obj}
@@ -85,80 +66,93 @@ func (obj *KernelAPI) _handle_conn(conn net.Conn) {
 		panic(err)
 	}
 
-	// Log
-	log.Printf("KernelAPI: new api connection established. connection = %s\n", process_id)
-
-	// Der JSON RPC wird ausgeführt
-	go rpcServer.ServeConn(wrapper_obj)
+	// Die Verbindung wird registriert
+	obj._lock.Lock()
+	obj._process_connections[process_id] = wrapper_obj
+	obj._lock.Unlock()
 
-	// Die Schleife wird solange ausgeführt, solange die Verbindung verbunden ist
-	for wrapper_obj.IsConnected() {
-		time.Sleep(1 * time.Millisecond)
+	// Log
+	obj._log.Info("KernelAPI: new api connection established", "connection", process_id)
+
+	// Der RPC Server wird ausgeführt, er endet erst wenn alle laufenden Aufrufe beantwortet wurden
+	served := make(chan struct{})
+	go func() {
+		rpcServer.ServeConn(wrapper_obj)
+		close(served)
+	}()
+
+	// Es wird gewartet bis die Verbindung getrennt oder die API geschlossen wurde
+	select {
+	case <-wrapper_obj.Done():
+	case <-obj._ctx.Done():
+		wrapper_obj.Close()
 	}
 
 	// Log
-	log.Printf("KernelAPI: api connection closed. connection = %s\n", process_id)
+	obj._log.Info("KernelAPI: api connection closed", "connection", process_id)
 
-	// Dem API Prozess Handler wird Signalisiert dass alle Vorgänge verworfen werden sollen
+	// Dem API Prozess Handler wird Signalisiert dass alle Vorgänge verworfen werden sollen,
+	// so werden auch wartende Aufrufe beendet und der RPC Server kann geschlossen werden
 	wrapper_obj.Kill()
-}
+	<-served
 
-// Startet das API Interface
-func (obj *KernelAPI) _start_by_kernel() error {
-	// Der Lokale nicht Root CLI Socket wird erstellt
-	t := make(chan error)
-	go func(err chan error) {
-		// Es wird Signalisiert dass der Server ausgeführt wird
-		obj._lock.Lock()
-		obj._is_running = true
-		obj._lock.Unlock()
+	// Die Verbindung wird entfernt
+	obj._lock.Lock()
+	delete(obj._process_connections, process_id)
+	obj._lock.Unlock()
+}
 
-		// Diese Funktion prüft 10 MS ob der CLI Server gestartet wurde
-		go func(err chan error) {
-			for i := 1; i <= 10; i++ {
-				obj._lock.Lock()
-				t := obj._is_running
-				obj._lock.Unlock()
-				if !t {
-					err <- fmt.Errorf("rpc server not started")
-					break
-				}
-			}
-			err <- nil
-		}(err)
-
-		// Diese Schleife wird solange ausgeführt bis das Objekt geschlossen wurde
-		for obj._ral() {
-			// Nimmt neue Verbindungen entgegen
-			conn, err := obj._socket.Accept()
-			if err != nil {
-				obj._lock.Lock()
-				if obj._signal_shutdown {
-					obj._lock.Unlock()
-					break
-				}
-				obj._lock.Unlock()
-				fmt.Println("KernelAPI: error by accepting new api-connection. id =", obj._object_id)
+// Nimmt neue Verbindungen entgegen, bis die API geschlossen wurde
+func (obj *KernelAPI) _accept_loop() {
+	for {
+		// Nimmt neue Verbindungen entgegen
+		conn, err := obj._socket.Accept()
+		if err != nil {
+			obj._lock.Lock()
+			is_shutdown := obj._signal_shutdown
+			obj._lock.Unlock()
+			if is_shutdown {
+				break
 			}
-
-			// Startet die Serverseitige verwaltung der Verbindung
-			go obj._handle_conn(conn)
+			obj._log.Warn("KernelAPI: error by accepting new api-connection", "id", obj._object_id, "error", err.Error())
+			continue
 		}
 
-		// Es wird Signalisiert dass der Server nicht mehr ausgeführt wird
-		obj._lock.Lock()
-		obj._is_running = false
-		obj._lock.Unlock()
+		// Startet die Serverseitige verwaltung der Verbindung
+		obj._threads.Add(1)
+		go func() {
+			defer obj._threads.Done()
+			obj._handle_conn(conn)
+		}()
+	}
 
-	}(t)
+	// Es wird Signalisiert dass der Server nicht mehr ausgeführt wird
+	obj._lock.Lock()
+	obj._is_running = false
+	obj._lock.Unlock()
+}
 
-	// Es wird geprüft ob beim Starten des RPC Servers aufgetreten ist
-	if err := <-t; err != nil {
-		return fmt.Errorf("KernelAPI: internal error: " + err.Error())
+// Startet das API Interface
+func (obj *KernelAPI) _start_by_kernel() error {
+	// Der Context der API wird vom Kernel abgeleitet
+	obj._lock.Lock()
+	if obj._is_running {
+		obj._lock.Unlock()
+		return fmt.Errorf("KernelAPI: always running")
 	}
+	obj._ctx, obj._cancel = context.WithCancel(obj._kernel._ctx)
+	obj._is_running = true
+	obj._lock.Unlock()
+
+	// Die Verbindungen werden in einem eigenen Thread angenommen
+	obj._threads.Add(1)
+	go func() {
+		defer obj._threads.Done()
+		obj._accept_loop()
+	}()
 
 	// Log
-	log.Println("KernelAPI: started by kernel. id =", obj._object_id, ", path =", obj._socket_unix_path)
+	obj._log.Info("KernelAPI: started by kernel", "id", obj._object_id, "path", obj._socket_unix_path)
 
 	// Der Vorgang wurde ohne fehler beendet
 	return nil
@@ -166,31 +160,27 @@ func (obj *KernelAPI) _start_by_kernel() error {
 
 // Schließt die API durch den Kernel
 func (obj *KernelAPI) _close_by_kernel() {
-	// Der Threadlock wird verwendet
+	// Es wird Signalisiert dass die API geschlossen wird, der Socket wird geschlossen
 	obj._lock.Lock()
-
-	// Es wird Signalisiert dass die Verbindung getrennt wurde
 	obj._signal_shutdown = true
-
-	// Der Socket wird geschlossen
 	obj._socket.Close()
-
-	// Die einzelnen API Verbindungen werden geschlossen
+	cancel := obj._cancel
 	obj._lock.Unlock()
 
-	// Wartet bis alle Verbindungen geschlossen wurden
-	for range time.Tick(1 * time.Millisecond) {
-		if !obj._irn() {
-			break
-		}
+	// Alle API Verbindungen werden getrennt
+	if cancel != nil {
+		cancel()
 	}
 
+	// Es wird gewartet bis alle Verbindungen geschlossen wurden
+	obj._threads.Wait()
+
 	// Log
-	log.Println("KernelAPI: closed by kernel. id =", obj._object_id, ", rpc path =", obj._socket_unix_path)
+	obj._log.Info("KernelAPI: closed by kernel", "id", obj._object_id, "rpc_path", obj._socket_unix_path)
 }
 
 // Erstellt eine neue Kernel API
-func newKernelAPI() (*KernelAPI, error) {
+func newKernelAPI(logger *slog.Logger) (*KernelAPI, error) {
 	// Gibt die
 	_rpc_socket_path := static.GetFilePathFor(static.API_SOCKET)
 
@@ -216,9 +206,9 @@ func newKernelAPI() (*KernelAPI, error) {
 	obj_id := utils.RandStringRunes(12)
 
 	// Log
-	log.Println("KernelAPI: new api created. id =", obj_id, ", rpc path =", _rpc_socket_path)
+	logger.Info("KernelAPI: new api created", "id", obj_id, "rpc_path", _rpc_socket_path)
 
 	// Das Onjekt wird zurückgegeben
-	rewa := KernelAPI{_socket: l, _socket_unix_path: _rpc_socket_path, _lock: sync.Mutex{}, _object_id: obj_id}
+	rewa := KernelAPI{_socket: l, _socket_unix_path: _rpc_socket_path, _lock: sync.Mutex{}, _object_id: obj_id, _process_connections: make(map[string]*APIProcessConnectionWrapper), _log: logger}
 	return &rewa, nil
 }
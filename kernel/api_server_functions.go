@@ -2,9 +2,9 @@ package kernel
 
 import (
 	"fmt"
-	"log"
 
 	apiclient "github.com/fluffelpuff/RoueX/api_client"
+	"github.com/fluffelpuff/RoueX/logging"
 )
 
 // Stellt das Kernel API Interface dar
@@ -23,7 +23,7 @@ func (s *Kf) FetchRelays(_ apiclient.EmptyArg, reply *[]apiclient.ApiRelayEntry)
 	}
 
 	// Log
-	log.Printf("KernelAPI-Session: fetched relays. connection = %s, total = %d\n", s._process_id, len(result))
+	s._kernel.Logger(logging.API).Debug("KernelAPI-Session: fetched relays", "connection", s._process_id, "total", len(result))
 
 	// Die Daten werden zurückgegeben
 	*reply = result
@@ -72,3 +72,153 @@ func (s *Kf) FetchAllAddresses(_ apiclient.EmptyArg, reply *[]apiclient.ApiRelay
 	// Der Vorgang wurde ohne Fehler durchgeführt
 	return nil
 }
+
+// Fügt ein Vertrauenswürdiges Relay hinzu
+func (s *Kf) AddTrustedRelay(args apiclient.TrustedRelayArgs, reply *bool) error {
+	if err := s._kernel.APIAddTrustedRelay(args); err != nil {
+		return fmt.Errorf("AddTrustedRelay: " + err.Error())
+	}
+
+	// Log
+	s._kernel.Logger(logging.API).Info("KernelAPI-Session: added trusted relay", "connection", s._process_id)
+
+	// Der Vorgang wurde ohne Fehler durchgeführt
+	*reply = true
+	return nil
+}
+
+// Entfernt ein Vertrauenswürdiges Relay
+func (s *Kf) RemoveTrustedRelay(args apiclient.TrustedRelayArgs, reply *bool) error {
+	if err := s._kernel.APIRemoveTrustedRelay(args); err != nil {
+		return fmt.Errorf("RemoveTrustedRelay: " + err.Error())
+	}
+
+	// Log
+	s._kernel.Logger(logging.API).Info("KernelAPI-Session: removed trusted relay", "connection", s._process_id)
+
+	// Der Vorgang wurde ohne Fehler durchgeführt
+	*reply = true
+	return nil
+}
+
+// Aktiviert oder Deaktiviert ein Vertrauenswürdiges Relay
+func (s *Kf) SetTrustedRelayActive(args apiclient.TrustedRelayArgs, reply *bool) error {
+	if err := s._kernel.APISetTrustedRelayActive(args); err != nil {
+		return fmt.Errorf("SetTrustedRelayActive: " + err.Error())
+	}
+
+	// Log
+	s._kernel.Logger(logging.API).Info("KernelAPI-Session: changed trusted relay state", "connection", s._process_id)
+
+	// Der Vorgang wurde ohne Fehler durchgeführt
+	*reply = true
+	return nil
+}
+
+// Aktualisiert den Endpunkt und das Protokoll eines Vertrauenswürdigen Relays
+func (s *Kf) UpdateTrustedRelay(args apiclient.TrustedRelayArgs, reply *bool) error {
+	if err := s._kernel.APIUpdateTrustedRelay(args); err != nil {
+		return fmt.Errorf("UpdateTrustedRelay: " + err.Error())
+	}
+
+	// Log
+	s._kernel.Logger(logging.API).Info("KernelAPI-Session: updated trusted relay", "connection", s._process_id)
+
+	// Der Vorgang wurde ohne Fehler durchgeführt
+	*reply = true
+	return nil
+}
+
+// Markiert ein verbundenes nicht Vertrauenswürdiges Relay als Vertrauenswürdig, der Öffentliche Schlüssel des Relays wird zurückgegeben
+func (s *Kf) PromoteRelay(args apiclient.TrustedRelayArgs, reply *string) error {
+	public_key, err := s._kernel.APIPromoteRelay(args)
+	if err != nil {
+		return fmt.Errorf("PromoteRelay: " + err.Error())
+	}
+
+	// Log
+	s._kernel.Logger(logging.API).Info("KernelAPI-Session: promoted relay to trusted", "connection", s._process_id)
+
+	// Der Vorgang wurde ohne Fehler durchgeführt
+	*reply = public_key
+	return nil
+}
+
+// Lädt die Vertrauenswürdigen Relays erneut aus der Datenbank
+func (s *Kf) ReloadTrustedRelays(_ apiclient.TrustedRelayArgs, reply *bool) error {
+	if err := s._kernel.ReloadTrustedRelays(); err != nil {
+		return fmt.Errorf("ReloadTrustedRelays: " + err.Error())
+	}
+
+	// Log
+	s._kernel.Logger(logging.API).Info("KernelAPI-Session: reloaded trusted relays", "connection", s._process_id)
+
+	// Der Vorgang wurde ohne Fehler durchgeführt
+	*reply = true
+	return nil
+}
+
+// Fügt eine Firewall Regel hinzu, die ID der Regel wird zurückgegeben
+func (s *Kf) AddFirewallRule(args apiclient.FirewallRuleArgs, reply *int64) error {
+	rule_id, err := s._kernel.APIAddFirewallRule(args)
+	if err != nil {
+		return fmt.Errorf("AddFirewallRule: " + err.Error())
+	}
+
+	// Log
+	s._kernel.Logger(logging.API).Info("KernelAPI-Session: added firewall rule", "connection", s._process_id, "rule", rule_id)
+
+	// Der Vorgang wurde ohne Fehler durchgeführt
+	*reply = rule_id
+	return nil
+}
+
+// Entfernt eine Firewall Regel
+func (s *Kf) RemoveFirewallRule(args apiclient.FirewallRuleArgs, reply *bool) error {
+	if err := s._kernel.APIRemoveFirewallRule(args); err != nil {
+		return fmt.Errorf("RemoveFirewallRule: " + err.Error())
+	}
+
+	// Log
+	s._kernel.Logger(logging.API).Info("KernelAPI-Session: removed firewall rule", "connection", s._process_id, "rule", args.RuleId)
+
+	// Der Vorgang wurde ohne Fehler durchgeführt
+	*reply = true
+	return nil
+}
+
+// Gibt alle Firewall Regeln sowie die Anzahl der verworfenen Pakete zurück
+func (s *Kf) FetchFirewallState(_ apiclient.EmptyArg, reply *apiclient.ApiFirewallState) error {
+	result, err := s._kernel.APIFetchFirewallState()
+	if err != nil {
+		return fmt.Errorf("FetchFirewallState: " + err.Error())
+	}
+	*reply = *result
+	return nil
+}
+
+// Legt die Ausführlichkeit der Log Ausgabe eines Subsystems fest, bei einem leeren Namen wird der Standardwert geändert
+func (s *Kf) SetLogLevel(args apiclient.LogLevelArgs, reply *bool) error {
+	if err := s._kernel.SetLogLevel(args.Subsystem, args.Level); err != nil {
+		return fmt.Errorf("SetLogLevel: " + err.Error())
+	}
+
+	// Log
+	s._kernel.Logger(logging.API).Info("KernelAPI-Session: changed log level", "connection", s._process_id, "subsystem", args.Subsystem, "level", args.Level)
+
+	// Der Vorgang wurde ohne Fehler durchgeführt
+	*reply = true
+	return nil
+}
+
+// Gibt die Ausführlichkeit der Log Ausgabe aller Subsysteme zurück
+func (s *Kf) FetchLogLevels(_ apiclient.EmptyArg, reply *map[string]string) error {
+	*reply = s._kernel.GetLogLevels()
+	return nil
+}
+
+// Gibt die Beschreibungen aller Relays aus den Relay Verzeichnissen zurück
+func (s *Kf) FetchRelayDescriptors(_ apiclient.EmptyArg, reply *[]apiclient.ApiRelayDescriptor) error {
+	*reply = s._kernel.APIFetchRelayDescriptors()
+	return nil
+}
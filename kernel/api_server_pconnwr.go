@@ -1,9 +1,8 @@
 package kernel
 
 import (
-	"log"
+	"log/slog"
 	"net"
-	"strconv"
 	"sync"
 )
 
@@ -19,16 +18,32 @@ type APIProcessConnectionWrapper struct {
 	conn        net.Conn
 	lock        *sync.Mutex
 	isconn      bool
+	closed      chan struct{}
+	close_once  *sync.Once
 	service_map map[string]APIConnectionLiveService
+	log         *slog.Logger
+}
+
+// Signalisiert dass die Verbindung getrennt wurde
+func (c *APIProcessConnectionWrapper) _signal_disconnected() {
+	c.close_once.Do(func() {
+		c.lock.Lock()
+		c.isconn = false
+		c.lock.Unlock()
+		close(c.closed)
+	})
+}
+
+// Gibt einen Channel zurück, welcher geschlossen wird sobald die Verbindung getrennt wurde
+func (c *APIProcessConnectionWrapper) Done() <-chan struct{} {
+	return c.closed
 }
 
 // Ließt Daten aus der Verbindung
 func (c *APIProcessConnectionWrapper) Read(b []byte) (int, error) {
 	r, e := c.conn.Read(b)
 	if e != nil {
-		c.lock.Lock()
-		c.isconn = false
-		c.lock.Unlock()
+		c._signal_disconnected()
 		return r, e
 	}
 	return r, nil
@@ -41,7 +56,9 @@ func (c *APIProcessConnectionWrapper) Write(b []byte) (int, error) {
 
 // Schließt die Verbindung
 func (c *APIProcessConnectionWrapper) Close() error {
-	return c.conn.Close()
+	err := c.conn.Close()
+	c._signal_disconnected()
+	return err
 }
 
 // Gibt an ob die Verbindung aufgebaut ist
@@ -62,7 +79,7 @@ func (c *APIProcessConnectionWrapper) AddProcessInvigoratingService(new_lservice
 	c.service_map[new_lservice.GetId()] = new_lservice
 
 	// Log
-	log.Printf("APIProcessConnectionWrapper: add service. sid = %s, process = %s\n", new_lservice.GetId(), c.GetObjectId())
+	c.log.Debug("APIProcessConnectionWrapper: add service", "sid", new_lservice.GetId(), "process", c.GetObjectId())
 }
 
 // Entfernt einen "Service"
@@ -74,7 +91,7 @@ func (c *APIProcessConnectionWrapper) RemoveProcessInvigoratingService(new_lserv
 	// Es wird ermittet ob es einen passenden Dienst in dieser Verbindung gibt
 	_, found := c.service_map[new_lservice.GetId()]
 	if !found {
-		log.Printf("APIProcessConnectionWrapper: cant remove unkown service. sid = %s, process = %s\n", new_lservice.GetId(), c.GetObjectId())
+		c.log.Warn("APIProcessConnectionWrapper: cant remove unkown service", "sid", new_lservice.GetId(), "process", c.GetObjectId())
 		return
 	}
 
@@ -82,7 +99,7 @@ func (c *APIProcessConnectionWrapper) RemoveProcessInvigoratingService(new_lserv
 	delete(c.service_map, new_lservice.GetId())
 
 	// Log
-	log.Printf("APIProcessConnectionWrapper: remove service. sid = %s, process = %s\n", new_lservice.GetId(), c.GetObjectId())
+	c.log.Debug("APIProcessConnectionWrapper: remove service", "sid", new_lservice.GetId(), "process", c.GetObjectId())
 }
 
 // Gibt die Objekt ID aus
@@ -103,5 +120,5 @@ func (c *APIProcessConnectionWrapper) Kill() {
 	}
 
 	// Log
-	log.Println("APIProcessConnectionWrapper: process api connection closed. id = "+c.id, "total = "+strconv.Itoa(total))
+	c.log.Debug("APIProcessConnectionWrapper: process api connection closed", "id", c.id, "total", total)
 }
@@ -2,15 +2,16 @@ package kernel
 
 import (
 	"bytes"
+	"context"
 	"fmt"
-	"log"
-	"os"
+	"log/slog"
 	"sync"
 	"time"
 
 	"github.com/btcsuite/btcd/btcec/v2"
 	"github.com/decred/dcrd/dcrec/secp256k1/v4"
 	"github.com/fluffelpuff/RoueX/firewall"
+	"github.com/fluffelpuff/RoueX/logging"
 	routingmanager "github.com/fluffelpuff/RoueX/routing_manager"
 	"github.com/fluffelpuff/RoueX/static"
 	"github.com/fluffelpuff/RoueX/utils"
@@ -23,37 +24,83 @@ type Kernel struct {
 	_socket_path           string
 	_kernel_id             string
 	_is_running            bool
+	_stopping              bool
+	_draining              bool
+	_drain_timeout         time.Duration
+	_reload_handler        func() error
 	_lock                  *sync.Mutex
 	_routing_table         *routingmanager.RoutingManager
 	_trusted_relays        *TrustedRelays
 	_server_modules        []ServerModule
+	_stopped_modules       map[ServerModule]bool
 	_client_modules        []ClientModule
 	_connection_manager    RelayConnectionRoutingTable
 	_firewall              FirewallBaseStructure
 	_private_key           *btcec.PrivateKey
 	_api_interfaces        []*KernelAPI
 	_directory_services    []RelayDirectoryService
+	_descriptor_end_points []RelayDescriptorEndPoint
+	_descriptor_ttl        time.Duration
+	_relay_observers       []RelayStateObserver
 	_temp_key_pairs        map[string]*btcec.PrivateKey
 	_temp_ecdh_keys        map[string][]byte
 	_protocols             map[int]*KernelPackageProtocolEntry
 	_memory                kernel_package_buffer
-	_system_signal         chan os.Signal
-	_shutdown_signal       chan bool
-	_shutdown_complete     bool
+	_ctx                   context.Context
+	_cancel                context.CancelFunc
+	_threads               *sync.WaitGroup
+	_started               chan struct{}
+	_closed                chan struct{}
+	_invalid_signatures    uint64
+	_duplicate_packages    uint64
+	_expired_packages      uint64
+	_outdated_packages     uint64
+	_seen_packages         *seen_package_cache
+	_diagnostic_times      map[string]time.Time
+	_key_handovers         []KeyHandover
+	_outbound_relays       map[*Relay]*outbound_worker
+	_back_dial_policy      P2PBackDialPolicy
+	_back_dials            map[string]bool
+	_untrusted_policy      UntrustedRelayPolicy
+	_bootstrap_policy      BootstrapPolicy
+	_known_peers           map[string]*known_peer
+	_bootstrap_dials       map[string]bool
+	_reputations           map[string]*relay_reputation
+	_admission_lock        *sync.Mutex
+	_metrics               *kernel_metrics
+	_logs                  *logging.Manager
+	_log                   *slog.Logger
 }
 
-// Wird verwendet um zu Warten bis der Kernel läuft
+// Wartet maximal 'wms' Millisekunden, sollte der Kernel zuvor beendet werden, wird der Vorgang abgebrochen
 func (obj *Kernel) ServKernel(wms uint64) {
-	ticks := 0
-	for obj.IsRunning() {
-		if ticks >= int(wms) {
-			return
-		}
-		ticks++
-		time.Sleep(1 * time.Millisecond)
+	timer := time.NewTimer(time.Duration(wms) * time.Millisecond)
+	defer timer.Stop()
+	select {
+	case <-obj._ctx.Done():
+	case <-timer.C:
 	}
 }
 
+// Gibt den Context des Kernels zurück, dieser wird beim Herunterfahren des Kernels abgebrochen
+func (obj *Kernel) Context() context.Context {
+	return obj._ctx
+}
+
+// Gibt einen Channel zurück, welcher geschlossen wird sobald der Kernel gestartet wurde
+func (obj *Kernel) Started() <-chan struct{} {
+	return obj._started
+}
+
+// Führt eine Funktion als Thread des Kernels aus, beim Herunterfahren wird gewartet bis alle Threads beendet wurden
+func (obj *Kernel) _go(fnc func()) {
+	obj._threads.Add(1)
+	go func() {
+		defer obj._threads.Done()
+		fnc()
+	}()
+}
+
 // Gibt an ob der Kernel ausgeführt wird
 func (obj *Kernel) IsRunning() bool {
 	obj._lock.Lock()
@@ -62,6 +109,30 @@ func (obj *Kernel) IsRunning() bool {
 	return is_running
 }
 
+// Gibt die Anzahl der Pakete zurück, welche aufgrund einer ungültigen Signatur verworfen wurden
+func (obj *Kernel) GetTotalInvalidSignaturePackages() uint64 {
+	obj._lock.Lock()
+	total := obj._invalid_signatures
+	obj._lock.Unlock()
+	return total
+}
+
+// Gibt die Anzahl der Pakete zurück, welche bereits empfangen wurden und verworfen wurden
+func (obj *Kernel) GetTotalDuplicatePackages() uint64 {
+	obj._lock.Lock()
+	total := obj._duplicate_packages
+	obj._lock.Unlock()
+	return total
+}
+
+// Gibt die Anzahl der Pakete zurück, welche aufgrund eines abgelaufenen Hop Limits verworfen wurden
+func (obj *Kernel) GetTotalExpiredPackages() uint64 {
+	obj._lock.Lock()
+	total := obj._expired_packages
+	obj._lock.Unlock()
+	return total
+}
+
 // Registriert eine API Schnitstellt
 func (obj *Kernel) RegisterAPIInterface(api_interace *KernelAPI) error {
 	obj._lock.Lock()
@@ -83,40 +154,75 @@ func (obj *Kernel) IsLocallyAddress(pubkey btcec.PublicKey) bool {
 
 // Wird verwendet um die Aktuelle Uhrzeit von den NTP Servern abzurufen
 
-// Erstellt einen UNIX Kernel
-func CreateUnixKernel(priv_key *btcec.PrivateKey) (*Kernel, error) {
+// Gibt den Logger eines Subsystems zurück, sollte kein Kernel vorhanden sein wird die Standard Verwaltung verwendet,
+// so können auch Module welche noch nicht im Kernel registriert wurden den Logger verwenden
+func (obj *Kernel) Logger(subsystem string) *slog.Logger {
+	if obj == nil || obj._logs == nil {
+		return logging.Logger(subsystem)
+	}
+	return obj._logs.Logger(subsystem)
+}
+
+// Legt die Ausführlichkeit eines Subsystems fest, bei einem leeren Namen wird der Standardwert geändert
+func (obj *Kernel) SetLogLevel(subsystem string, level string) error {
+	// Es wird geprüft ob das Subsystem bekannt ist
+	if len(subsystem) > 0 && !logging.IsSubsystem(subsystem) {
+		return fmt.Errorf("SetLogLevel: unkown subsystem " + subsystem)
+	}
+
+	// Die Ausführlichkeit wird eingelesen
+	parsed, err := logging.ParseLevel(level)
+	if err != nil {
+		return fmt.Errorf("SetLogLevel: " + err.Error())
+	}
+
+	// Die Ausführlichkeit wird übernommen
+	obj._logs.SetLevel(subsystem, parsed)
+	obj._log.Info("Kernel: log level changed", "subsystem", subsystem, "level", parsed.String())
+	return nil
+}
+
+// Gibt die Ausführlichkeit aller Subsysteme zurück
+func (obj *Kernel) GetLogLevels() map[string]string {
+	return obj._logs.GetLevels()
+}
+
+// Erstellt einen UNIX Kernel, alle Programmteile des Kernels verwenden die angegebene Logging Verwaltung
+func CreateUnixKernel(priv_key *btcec.PrivateKey, logs *logging.Manager) (*Kernel, error) {
+	// Sollte keine Logging Verwaltung angegeben sein, wird die Standard Verwaltung verwendet
+	if logs == nil {
+		logs = logging.Default()
+	}
+
 	// Log
-	fmt.Println("Creating new RoueX UNIX Kernel...")
+	logs.Logger(logging.KERNEL).Info("Kernel: creating new unix kernel")
 
 	// Der Speichermanager wird erzeugt
-	memory_manager, err := new_kernel_package_buffer()
+	memory_manager, err := new_kernel_package_buffer(logs.Logger(logging.BUFFER))
 	if err != nil {
 		return nil, fmt.Errorf("CreateUnixKernel: " + err.Error())
 	}
 
 	// Es wird eine Liste mit allen Vertrauten Relays abgerufen
-	trusted_relays_obj, err := loadTrustedRelaysTable(static.GetFilePathFor(static.TRUSTED_RELAYS))
+	trusted_relays_obj, err := loadTrustedRelaysTable(static.GetFilePathFor(static.TRUSTED_RELAYS), logs.Logger(logging.KERNEL))
 	if err != nil {
-		log.Fatal("listen error:", err)
-		return nil, err
+		return nil, fmt.Errorf("CreateUnixKernel: " + err.Error())
 	}
 
 	// Es wird versucht die Routing Tabelle zu laden
 	routing_table_obj, err := routingmanager.LoadRoutingManager(static.GetFilePathFor(static.ROUTING_TABLE))
 	if err != nil {
-		log.Fatal("listen error:", err)
-		return nil, err
+		return nil, fmt.Errorf("CreateUnixKernel: " + err.Error())
 	}
 
 	// Es wird versucht die Firewall Tabelle zu ladne
 	firewall_table_obj, err := firewall.LoadFirewallTable(static.GetFilePathFor(static.FIREWALL_TABLE))
 	if err != nil {
-		log.Fatal("listen error:", err)
-		return nil, err
+		return nil, fmt.Errorf("CreateUnixKernel: " + err.Error())
 	}
 
 	// Die Kernel API wird gestartet
-	kernel_api, err := newKernelAPI()
+	kernel_api, err := newKernelAPI(logs.Logger(logging.API))
 	if err != nil {
 		panic(err)
 	}
@@ -124,8 +230,14 @@ func CreateUnixKernel(priv_key *btcec.PrivateKey) (*Kernel, error) {
 	// Die KernelID wird estellt
 	k_id := utils.RandStringRunes(16)
 
+	// Der Context des Kernels wird erstellt, er wird beim Herunterfahren abgebrochen
+	ctx, cancel := context.WithCancel(context.Background())
+
+	// Die Metriken des Kernels werden erstellt
+	metrics := new_kernel_metrics()
+
 	// Die Verbindungsverwaltung wird erstellt
-	conn_manager := newRelayConnectionRoutingTable()
+	conn_manager := newRelayConnectionRoutingTable(&routing_table_obj, metrics, logs.Logger(logging.ROUTING))
 
 	// Erstellt das Kernel Objekt
 	new_kernel := Kernel{
@@ -145,9 +257,36 @@ func CreateUnixKernel(priv_key *btcec.PrivateKey) (*Kernel, error) {
 		_socket_path:           static.GetFilePathFor(static.API_SOCKET),
 		_protocols:             make(map[int]*KernelPackageProtocolEntry),
 		_directory_services:    make([]RelayDirectoryService, 0),
-		_system_signal:         make(chan os.Signal, 1),
-		_shutdown_signal:       make(chan bool),
-		_shutdown_complete:     false,
+		_descriptor_end_points: make([]RelayDescriptorEndPoint, 0),
+		_descriptor_ttl:        DEFAULT_RELAY_DESCRIPTOR_TTL,
+		_relay_observers:       make([]RelayStateObserver, 0),
+		_seen_packages:         new_seen_package_cache(SEEN_PACKAGE_TTL, MAX_SEEN_PACKAGES),
+		_diagnostic_times:      make(map[string]time.Time),
+		_key_handovers:         make([]KeyHandover, 0),
+		_outbound_relays:       make(map[*Relay]*outbound_worker),
+		_stopped_modules:       make(map[ServerModule]bool),
+		_back_dial_policy:      DEFAULT_P2P_BACK_DIAL_POLICY,
+		_back_dials:            make(map[string]bool),
+		_untrusted_policy:      DEFAULT_UNTRUSTED_RELAY_POLICY,
+		_bootstrap_policy:      DEFAULT_BOOTSTRAP_POLICY,
+		_known_peers:           make(map[string]*known_peer),
+		_bootstrap_dials:       make(map[string]bool),
+		_reputations:           make(map[string]*relay_reputation),
+		_admission_lock:        new(sync.Mutex),
+		_ctx:                   ctx,
+		_cancel:                cancel,
+		_threads:               new(sync.WaitGroup),
+		_started:               make(chan struct{}),
+		_closed:                make(chan struct{}),
+		_drain_timeout:         DEFAULT_DRAIN_TIMEOUT,
+		_metrics:               metrics,
+		_logs:                  logs,
+		_log:                   logs.Logger(logging.KERNEL),
+	}
+
+	// Die Übergabekette des Relay Schlüssels wird geladen
+	if err := new_kernel._load_key_handover_chain(); err != nil {
+		return nil, fmt.Errorf("CreateUnixKernel: " + err.Error())
 	}
 
 	// Die API Schnitstelle wird im Kernel Registriert
@@ -156,6 +295,6 @@ func CreateUnixKernel(priv_key *btcec.PrivateKey) (*Kernel, error) {
 	}
 
 	// Gibt das Kernelobjekt ohne Fehler zurück
-	log.Println("Kernel: new unix kernel created. id =", k_id)
+	new_kernel._log.Info("Kernel: new unix kernel created", "id", k_id)
 	return &new_kernel, nil
 }
@@ -0,0 +1,437 @@
+package kernel
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"fmt"
+	"math/rand"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+)
+
+// Gibt an, in welchem Abstand geprüft wird ob ausreichend ausgehende Verbindungen bestehen
+const BOOTSTRAP_CHECK_INTERVAL = 15 * time.Second
+
+// Gibt an, wie viele über den Peer Austausch gelernte Relays maximal gespeichert werden
+const BOOTSTRAP_MAX_KNOWN_PEERS = 1024
+
+// Gibt an, wie viele Relays ein einzelner Nachbar über den Peer Austausch maximal beitragen darf
+const BOOTSTRAP_MAX_PEERS_PER_SOURCE = 64
+
+// Gibt an, nach wievielen fehlgeschlagenen Verbindungsversuchen ein über den Peer Austausch gelerntes Relay verworfen wird
+const BOOTSTRAP_MAX_FAILURES = 3
+
+// Gibt an, wie lange die Namensauflösung eines Endpunktes maximal dauern darf
+const BOOTSTRAP_RESOLVE_TIMEOUT = 10 * time.Second
+
+// Gibt die minimale und maximale Wartezeit nach einem fehlgeschlagenen Verbindungsversuch zu einem bekannten Relay an
+const (
+	BOOTSTRAP_BACKOFF_MIN = 1 * time.Minute
+	BOOTSTRAP_BACKOFF_MAX = 30 * time.Minute
+)
+
+// Gibt an, woher ein bekanntes Relay stammt
+const (
+	PEER_SOURCE_SEED      = "seed"
+	PEER_SOURCE_EXCHANGE  = "exchange"
+	PEER_SOURCE_DIRECTORY = "directory"
+)
+
+// Stellt ein Relay dar, zu welchem beim Bootstrappen eine ausgehende Verbindung aufgebaut werden kann,
+// 'Protocol' gibt den Namen des Client Moduls an
+type BootstrapPeer struct {
+	PublicKey *btcec.PublicKey
+	EndPoint  string
+	Protocol  string
+}
+
+// Stellt die Richtlinie für das Bootstrappen dar, es werden solange Verbindungen zu den Seeds und den bekannten
+// Relays aufgebaut, bis 'TargetOutbound' ausgehende Verbindungen bestehen, bei 0 ist das Bootstrappen deaktiviert,
+// ist 'AllowPrivateEndPoints' gesetzt, werden auch gelernte Endpunkte in privaten Netzen oder auf Systemports verwendet,
+// ist 'ShareTrustedPeers' gesetzt, werden beim Peer Austausch auch die Endpunkte der Vertrauenswürdigen Relays weitergegeben
+type BootstrapPolicy struct {
+	Seeds                 []BootstrapPeer
+	TargetOutbound        uint
+	AllowPrivateEndPoints bool
+	ShareTrustedPeers     bool
+}
+
+// Gibt die Standardrichtlinie an, ohne Seeds werden nur die über den Peer Austausch und die Verzeichnisse bekannten Relays verwendet
+var DEFAULT_BOOTSTRAP_POLICY = BootstrapPolicy{Seeds: make([]BootstrapPeer, 0), TargetOutbound: 8}
+
+// Stellt ein bekanntes Relay samt seiner fehlgeschlagenen Verbindungsversuche dar, 'contributor' gibt bei über den
+// Peer Austausch gelernten Relays den Nachbarn (Hex) an, von welchem das Relay stammt
+type known_peer struct {
+	peer         BootstrapPeer
+	source       string
+	contributor  string
+	failures     uint
+	next_attempt time.Time
+}
+
+// Gibt an ob eine IP Adresse öffentlich erreichbar ist
+func _is_public_ip(ip net.IP) bool {
+	return ip.IsGlobalUnicast() && !ip.IsPrivate() && !ip.IsLoopback() && !ip.IsLinkLocalUnicast()
+}
+
+// Prüft ob ein gelernter Endpunkt verwendet werden darf, Endpunkte mit einer nicht öffentlichen IP Adresse
+// oder einem Systemport (ausgenommen 80 und 443) werden abgelehnt, Hostnamen werden erst beim Verbindungsaufbau geprüft
+func _is_public_end_point(end_point string) bool {
+	// Der Endpunkt wird eingelesen
+	parsed, err := url.Parse(end_point)
+	if err != nil || parsed.Hostname() == "" {
+		return false
+	}
+
+	// Der Port wird geprüft
+	port, err := strconv.ParseUint(parsed.Port(), 10, 16)
+	if err != nil || port == 0 || (port < 1024 && port != 80 && port != 443) {
+		return false
+	}
+
+	// Die Adresse wird geprüft
+	host := strings.ToLower(parsed.Hostname())
+	if ip := net.ParseIP(host); ip != nil {
+		return _is_public_ip(ip)
+	}
+	return host != "localhost" && !strings.HasSuffix(host, ".localhost") && !strings.HasSuffix(host, ".local")
+}
+
+// Löst den Hostnamen eines gelernten Endpunktes auf, der Endpunkt wird abgelehnt sollte eine der Adressen nicht öffentlich sein
+func (obj *Kernel) _resolve_public_end_point(end_point string) error {
+	// Es wird geprüft ob der Endpunkt zulässig ist
+	if !_is_public_end_point(end_point) {
+		return fmt.Errorf("_resolve_public_end_point: 1: end point not public")
+	}
+
+	// Sollte es sich um eine IP Adresse handeln, muss nichts aufgelöst werden
+	parsed, _ := url.Parse(end_point)
+	if net.ParseIP(parsed.Hostname()) != nil {
+		return nil
+	}
+
+	// Der Hostname wird aufgelöst
+	ctx, cancel := context.WithTimeout(obj._ctx, BOOTSTRAP_RESOLVE_TIMEOUT)
+	defer cancel()
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, parsed.Hostname())
+	if err != nil {
+		return fmt.Errorf("_resolve_public_end_point: 2: " + err.Error())
+	}
+	for _, addr := range addrs {
+		if !_is_public_ip(addr.IP) {
+			return fmt.Errorf("_resolve_public_end_point: 3: end point resolves to a not public address")
+		}
+	}
+
+	// Der Endpunkt ist zulässig
+	return nil
+}
+
+// Legt die Richtlinie für das Bootstrappen fest, die Seeds werden als bekannte Relays übernommen
+func (obj *Kernel) SetBootstrapPolicy(policy BootstrapPolicy) {
+	obj._lock.Lock()
+	defer obj._lock.Unlock()
+	obj._bootstrap_policy = policy
+
+	// Nicht mehr vorhandene Seeds werden entfernt
+	for key, item := range obj._known_peers {
+		if item.source == PEER_SOURCE_SEED {
+			delete(obj._known_peers, key)
+		}
+	}
+
+	// Die Seeds werden übernommen, sie ersetzen über den Peer Austausch gelernte Einträge
+	for _, seed := range policy.Seeds {
+		obj._known_peers[hex.EncodeToString(seed.PublicKey.SerializeCompressed())] = &known_peer{peer: seed, source: PEER_SOURCE_SEED}
+	}
+}
+
+// Gibt die Richtlinie für das Bootstrappen zurück
+func (obj *Kernel) GetBootstrapPolicy() BootstrapPolicy {
+	obj._lock.Lock()
+	defer obj._lock.Unlock()
+	return obj._bootstrap_policy
+}
+
+// Gibt alle Relays zurück, mit denen eine Aktive ausgehende Verbindung besteht
+func (obj *Kernel) GetOutboundRelays() []*Relay {
+	return obj._connection_manager.GetOutboundRelays()
+}
+
+// Gibt an ob weniger ausgehende Verbindungen bestehen als durch die Richtlinie vorgegeben
+func (obj *Kernel) NeedsMorePeers() bool {
+	target := obj.GetBootstrapPolicy().TargetOutbound
+	return target > 0 && uint(len(obj.GetOutboundRelays())) < target
+}
+
+// Speichert ein über den Peer Austausch von einem Nachbarn gelerntes Relay, das eigene Relay, bereits bekannte Relays und nicht
+// öffentliche Endpunkte werden ignoriert, jeder Nachbar kann nur eine begrenzte Anzahl an Relays beitragen, ist die maximale Anzahl
+// erreicht wird das Relay mit den meisten fehlgeschlagenen Verbindungsversuchen ersetzt
+func (obj *Kernel) AddKnownPeer(peer BootstrapPeer, contributor *btcec.PublicKey) bool {
+	// Das eigene Relay wird nicht gespeichert
+	if peer.PublicKey.IsEqual(obj.GetPublicKey()) || len(peer.EndPoint) == 0 || len(peer.Protocol) == 0 {
+		return false
+	}
+
+	// Es wird geprüft ob das Relay bereits bekannt ist
+	key := hex.EncodeToString(peer.PublicKey.SerializeCompressed())
+	contributor_hex := hex.EncodeToString(contributor.SerializeCompressed())
+	obj._lock.Lock()
+	defer obj._lock.Unlock()
+	if _, found := obj._known_peers[key]; found {
+		return false
+	}
+
+	// Nicht öffentliche Endpunkte werden nur verwendet wenn dies durch die Richtlinie erlaubt wurde
+	if !obj._bootstrap_policy.AllowPrivateEndPoints && !_is_public_end_point(peer.EndPoint) {
+		return false
+	}
+
+	// Es wird geprüft ob der Nachbar bereits die maximale Anzahl an Relays beigetragen hat
+	exchanged, contributed := 0, 0
+	var worst_key string
+	var worst *known_peer
+	for item_key, item := range obj._known_peers {
+		if item.source != PEER_SOURCE_EXCHANGE {
+			continue
+		}
+		exchanged++
+		if item.contributor == contributor_hex {
+			contributed++
+		}
+		if item.failures > 0 && (worst == nil || item.failures > worst.failures) {
+			worst_key, worst = item_key, item
+		}
+	}
+	if contributed >= BOOTSTRAP_MAX_PEERS_PER_SOURCE {
+		return false
+	}
+
+	// Sollte die maximale Anzahl erreicht sein, wird das Relay mit den meisten fehlgeschlagenen Verbindungsversuchen ersetzt
+	if exchanged >= BOOTSTRAP_MAX_KNOWN_PEERS {
+		if worst == nil {
+			return false
+		}
+		delete(obj._known_peers, worst_key)
+	}
+
+	// Das Relay wird gespeichert
+	obj._known_peers[key] = &known_peer{peer: peer, source: PEER_SOURCE_EXCHANGE, contributor: contributor_hex}
+	return true
+}
+
+// Gibt an ob zu einem Relay beim Bootstrappen eine Verbindung aufgebaut werden soll, Vertrauenswürdige Relays werden
+// durch die Verwaltung der ausgehenden Verbindungen verbunden, Relays mit einem zu schlechten Ansehen werden übersprungen
+func (obj *Kernel) _is_bootstrap_candidate(pkey *btcec.PublicKey) bool {
+	if pkey.IsEqual(obj.GetPublicKey()) || obj._connection_manager.GetRelayByPublicKey(pkey) != nil {
+		return false
+	}
+	if trusted_relay, err := obj.GetTrustedRelayByPublicKey(pkey); err != nil || trusted_relay != nil {
+		return false
+	}
+	return obj.GetRelayReputation(pkey) > obj.GetUntrustedRelayPolicy().EvictScore
+}
+
+// Übernimmt die Relays aus den Verzeichnissen als bekannte Relays, es wird der erste Endpunkt verwendet für welchen ein
+// Client Modul vorhanden ist, Relays welche nicht mehr in den Verzeichnissen vorhanden sind werden entfernt
+func (obj *Kernel) _merge_directory_peers() {
+	// Die Relays aus den Verzeichnissen werden ermittelt, nicht öffentliche Endpunkte werden nur verwendet wenn dies erlaubt wurde
+	allow_private := obj.GetBootstrapPolicy().AllowPrivateEndPoints
+	directory_peers := make(map[string]BootstrapPeer)
+	for _, descriptor := range obj.GetRelayDescriptors() {
+		pkey, err := descriptor.GetPublicKey()
+		if err != nil {
+			continue
+		}
+		for _, end_point := range descriptor.EndPoints {
+			if !allow_private && !_is_public_end_point(end_point.EndPoint) {
+				continue
+			}
+			if obj._get_client_module(end_point.Module) != nil {
+				directory_peers[hex.EncodeToString(descriptor.PublicKey)] = BootstrapPeer{PublicKey: pkey, EndPoint: end_point.EndPoint, Protocol: end_point.Module}
+				break
+			}
+		}
+	}
+
+	// Die bekannten Relays werden abgeglichen, Seeds und über den Peer Austausch gelernte Relays bleiben erhalten
+	obj._lock.Lock()
+	defer obj._lock.Unlock()
+	for key, item := range obj._known_peers {
+		if _, found := directory_peers[key]; !found && item.source == PEER_SOURCE_DIRECTORY {
+			delete(obj._known_peers, key)
+		}
+	}
+	for key, peer := range directory_peers {
+		item, found := obj._known_peers[key]
+		if !found {
+			obj._known_peers[key] = &known_peer{peer: peer, source: PEER_SOURCE_DIRECTORY}
+		} else if item.source == PEER_SOURCE_DIRECTORY {
+			item.peer = peer
+		}
+	}
+}
+
+// Ermittelt die Relays, zu welchen beim Bootstrappen eine Verbindung aufgebaut werden kann, zuerst werden die Seeds
+// verwendet, danach die über den Peer Austausch und die Verzeichnisse bekannten Relays in zufälliger Reihenfolge
+func (obj *Kernel) _bootstrap_candidates() []BootstrapPeer {
+	// Die Relays aus den Verzeichnissen werden übernommen
+	obj._merge_directory_peers()
+
+	// Es werden alle bekannten Relays abgerufen, für welche aktuell keine Wartezeit gilt
+	now := time.Now()
+	seeds, others := make([]BootstrapPeer, 0), make([]BootstrapPeer, 0)
+	obj._lock.Lock()
+	for key, item := range obj._known_peers {
+		if now.Before(item.next_attempt) || obj._bootstrap_dials[key] {
+			continue
+		}
+		if item.source == PEER_SOURCE_SEED {
+			seeds = append(seeds, item.peer)
+		} else {
+			others = append(others, item.peer)
+		}
+	}
+	obj._lock.Unlock()
+
+	// Die übrigen Relays werden gemischt, so wird nicht immer zu den selben Relays eine Verbindung aufgebaut
+	rand.Shuffle(len(others), func(i, j int) { others[i], others[j] = others[j], others[i] })
+
+	// Es werden nur Relays zurückgegeben, zu welchen eine Verbindung aufgebaut werden soll
+	result := make([]BootstrapPeer, 0, len(seeds)+len(others))
+	for _, item := range append(seeds, others...) {
+		if obj._is_bootstrap_candidate(item.PublicKey) {
+			result = append(result, item)
+		}
+	}
+	return result
+}
+
+// Speichert das Ergebnis eines Verbindungsversuches zu einem bekannten Relay, nach einem Fehler wird die Wartezeit verdoppelt,
+// über den Peer Austausch gelernte Relays werden nach 'BOOTSTRAP_MAX_FAILURES' fehlgeschlagenen Versuchen verworfen
+func (obj *Kernel) _record_bootstrap_dial(key string, err error) {
+	obj._lock.Lock()
+	defer obj._lock.Unlock()
+	delete(obj._bootstrap_dials, key)
+	item, found := obj._known_peers[key]
+	if !found {
+		return
+	}
+	if err == nil {
+		item.failures, item.next_attempt = 0, time.Time{}
+		return
+	}
+	item.failures++
+	if item.source == PEER_SOURCE_EXCHANGE && item.failures >= BOOTSTRAP_MAX_FAILURES {
+		delete(obj._known_peers, key)
+		return
+	}
+	backoff := BOOTSTRAP_BACKOFF_MAX
+	if item.failures < 16 && BOOTSTRAP_BACKOFF_MIN<<(item.failures-1) < backoff {
+		backoff = BOOTSTRAP_BACKOFF_MIN << (item.failures - 1)
+	}
+	item.next_attempt = time.Now().Add(_outbound_jitter(backoff))
+}
+
+// Prüft vor dem Verbindungsaufbau ob der Endpunkt eines bekannten Relays verwendet werden darf, die Endpunkte der Seeds werden nicht geprüft
+func (obj *Kernel) _check_bootstrap_end_point(key string, peer BootstrapPeer) error {
+	obj._lock.Lock()
+	item, found := obj._known_peers[key]
+	is_seed := found && item.source == PEER_SOURCE_SEED
+	allow_private := obj._bootstrap_policy.AllowPrivateEndPoints
+	obj._lock.Unlock()
+	if is_seed || allow_private {
+		return nil
+	}
+	return obj._resolve_public_end_point(peer.EndPoint)
+}
+
+// Baut eine ausgehende Verbindung zu einem Relay auf, die Verbindung wird nur behalten, wenn der Handshake den Schlüssel des Relays ergibt
+func (obj *Kernel) DialBootstrapPeer(peer BootstrapPeer) error {
+	// Das Client Modul wird anhand des Protokolls ermittelt
+	client_module := obj._get_client_module(peer.Protocol)
+	if client_module == nil {
+		return fmt.Errorf("DialBootstrapPeer: 1: no client module for protocol " + peer.Protocol)
+	}
+
+	// Es wird eine ausgehende Verbindung aufgebaut
+	conn, err := client_module.ConnectTo(peer.EndPoint, peer.PublicKey, nil)
+	if err != nil {
+		return fmt.Errorf("DialBootstrapPeer: 2: " + err.Error())
+	}
+
+	// Es wird geprüft ob die Verbindung dem Relay zugeordnet wurde
+	relay, found, err := obj._connection_manager.GetRelayByConnection(conn)
+	if err != nil || !found || !bytes.Equal(relay.GetPublicKey().SerializeCompressed(), peer.PublicKey.SerializeCompressed()) {
+		conn.CloseByKernel()
+		return fmt.Errorf("DialBootstrapPeer: 3: handshake yields an other relay key")
+	}
+
+	// Der Vorgang wurde ohne Fehler durchgeführt
+	return nil
+}
+
+// Baut Verbindungen zu bekannten Relays auf, bis die durch die Richtlinie vorgegebene Anzahl an ausgehenden Verbindungen besteht
+func (obj *Kernel) _fill_outbound_peers() {
+	// Sollte der Kernel nicht ausgeführt werden oder beendet werden, wird der Vorgang abgebrochen
+	obj._lock.Lock()
+	can_dial := obj._is_running && !obj._stopping && !obj._draining
+	target, pending := obj._bootstrap_policy.TargetOutbound, uint(len(obj._bootstrap_dials))
+	obj._lock.Unlock()
+	if !can_dial || target == 0 {
+		return
+	}
+
+	// Es wird ermittelt wie viele Verbindungen fehlen, laufende Verbindungsversuche werden mitgezählt
+	current := uint(len(obj.GetOutboundRelays())) + pending
+	if current >= target {
+		return
+	}
+	missing := target - current
+
+	// Es werden Verbindungen zu den Relays aufgebaut
+	candidates := obj._bootstrap_candidates()
+	if len(candidates) == 0 {
+		obj._log.Debug("Kernel: no bootstrap candidates available", "outbound", current, "target", target)
+		return
+	}
+	for i := 0; i < len(candidates) && uint(i) < missing; i++ {
+		peer := candidates[i]
+		key := hex.EncodeToString(peer.PublicKey.SerializeCompressed())
+		obj._lock.Lock()
+		obj._bootstrap_dials[key] = true
+		obj._lock.Unlock()
+		obj._go(func() {
+			err := obj._check_bootstrap_end_point(key, peer)
+			if err == nil {
+				err = obj.DialBootstrapPeer(peer)
+			}
+			obj._record_bootstrap_dial(key, err)
+			if err != nil {
+				obj._log.Debug("Kernel: bootstrap connection failed", "relay", key, "endpoint", peer.EndPoint, "error", err.Error())
+				return
+			}
+			obj._log.Info("Kernel: bootstrap connection established", "relay", key, "protocol", peer.Protocol, "endpoint", peer.EndPoint)
+		})
+	}
+}
+
+// Prüft in regelmäßigen Abständen ob ausreichend ausgehende Verbindungen bestehen, bis der Kernel beendet wurde
+func (obj *Kernel) _maintain_outbound_peers() {
+	ticker := time.NewTicker(BOOTSTRAP_CHECK_INTERVAL)
+	defer ticker.Stop()
+	for {
+		obj._fill_outbound_peers()
+		select {
+		case <-obj._ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -2,12 +2,14 @@ package kernel
 
 import (
 	"bytes"
+	"encoding/hex"
 	"fmt"
-	"log"
+	"time"
 
 	"github.com/btcsuite/btcd/btcec/v2"
 	addresspackages "github.com/fluffelpuff/RoueX/address_packages"
 	"github.com/fluffelpuff/RoueX/kernel/extra"
+	"github.com/fluffelpuff/RoueX/rerror"
 	"github.com/fluffelpuff/RoueX/utils"
 )
 
@@ -28,6 +30,12 @@ func (obj *Kernel) RegisterNewKernelTypeProtocol(tpe uint8, pckgtf KernelTypePro
 		return fmt.Errorf("RegisterNewKernelTypeProtocol: 1: kernel is running, aborted")
 	}
 
+	// Der Typ für Diagnose Pakete ist für den Kernel reserviert
+	if tpe == DIAGNOSTIC_PROTOCOL_TYPE {
+		obj._lock.Unlock()
+		return fmt.Errorf("RegisterNewKernelTypeProtocol: 2: type is reserved by kernel")
+	}
+
 	// Es wird geprüft ob es bereits eine Eintrag für das Protokoll gibt
 	_, hfound := obj._protocols[int(tpe)]
 	if hfound {
@@ -48,12 +56,24 @@ func (obj *Kernel) RegisterNewKernelTypeProtocol(tpe uint8, pckgtf KernelTypePro
 	}
 
 	// Log
-	log.Println("Kernel: new package type handle function registrated. kernel =", obj.GetKernelID(), "type =", tpe, "name =", pckgtf.GetProtocolName(), "object-id =", pckgtf.GetObjectId())
+	obj._log.Info("Kernel: new package type handle function registrated", "kernel", obj.GetKernelID(), "type", tpe, "name", pckgtf.GetProtocolName(), "object_id", pckgtf.GetObjectId())
 
 	// Der Vorgang wurde ohne Fehler durchgeführt
 	return nil
 }
 
+// Gibt an ob die Pakete eines Protokolles als Steuerpakete übertragen werden
+func (obj *Kernel) _is_control_protocol(tpe uint8) bool {
+	obj._lock.Lock()
+	entry, found := obj._protocols[int(tpe)]
+	obj._lock.Unlock()
+	if !found {
+		return false
+	}
+	control, ok := entry.Ptf.(ControlTypeProtocol)
+	return ok && control.IsControlProtocol()
+}
+
 // Prüft ob es für den Typen einen bekannten Paket handler gibt
 func (obj *Kernel) GetRegisteredKernelTypeProtocol(tpe uint8) (KernelTypeProtocol, error) {
 	// Der Threadlock wird ausgeführt
@@ -77,6 +97,12 @@ func (obj *Kernel) EnterLocallyPackage(pckge *addresspackages.AddressLayerPackag
 		return fmt.Errorf("EnterLocallyPackage: 1: Invalid layer two package recived")
 	}
 
+	// Diagnose Pakete werden direkt vom Kernel verarbeitet
+	if pckge.Protocol == DIAGNOSTIC_PROTOCOL_TYPE {
+		obj._metrics.add_protocol_package(pckge.Protocol)
+		return obj._enter_diagnostic_package(pckge)
+	}
+
 	// Es wird geprüft ob es sich um eine Registrierte Paket Funktion handelt
 	register_package_type_handler, err := obj.GetRegisteredKernelTypeProtocol(pckge.Protocol)
 	if err != nil {
@@ -85,7 +111,8 @@ func (obj *Kernel) EnterLocallyPackage(pckge *addresspackages.AddressLayerPackag
 
 	// Sollte kein Paket Type handler vorhanden sein, wird das Paket verworfen
 	if register_package_type_handler == nil {
-		log.Println("Kernel: unkown package type, package droped. sender =", pckge.Sender, "reciver =", pckge.Reciver)
+		obj._log.Debug("Kernel: unkown package type, package droped", "sender", hex.EncodeToString(pckge.Sender.SerializeCompressed()), "reciver", hex.EncodeToString(pckge.Reciver.SerializeCompressed()), "protocol", pckge.Protocol)
+		obj._metrics.add_dropped_package(DROP_REASON_UNKOWN_PROTOCOL)
 		return nil
 	}
 
@@ -94,7 +121,13 @@ func (obj *Kernel) EnterLocallyPackage(pckge *addresspackages.AddressLayerPackag
 		return fmt.Errorf("EnterLocallyPackage: kernel is not running")
 	}
 
+	// Es wird geprüft ob das Paket durch die Firewall zugelassen wird
+	if !obj._firewall_check_locally_package(pckge) {
+		return nil
+	}
+
 	// Das Paket wird an den Handler übergeben
+	obj._metrics.add_protocol_package(pckge.Protocol)
 	err = register_package_type_handler.EnterRecivedPackage(pckge)
 	if err != nil {
 		return fmt.Errorf("EnterLocallyPackage: 5: " + err.Error())
@@ -114,12 +147,12 @@ func (obj *Kernel) EnterPlainPCIPackage(pckge *addresspackages.SendableAddressLa
 	// Es wird versucht die Inneren Daten einzulesen
 	inner_data, errr := addresspackages.ReadInnerFrameFromBytes(pckge.Data)
 	if errr != nil {
-		log.Println("")
+		obj._log.Debug("Kernel: invalid pci package droped", "error", errr.Error())
 		return
 	}
 
 	// Es wird geprüft um welches Protokoll es sich handelt
-	fmt.Println(inner_data)
+	obj._log.Debug("Kernel: pci package recived", "protocol", inner_data.Protocol)
 }
 
 // Nimmt ein nicht verschlüsseltes Lokales Paket entgegen
@@ -144,6 +177,11 @@ func (obj *Kernel) PlainLocallyPackageToBuffer(pckge *addresspackages.SendableAd
 		Data:     readed_inner.Data,
 	}
 
+	// Nur Pakete von Steuerprotokollen dürfen als Steuerpakete übertragen werden
+	if pckge.Control && !obj._is_control_protocol(internal_package.Protocol) {
+		return fmt.Errorf("DecryptLocallyPackageToBuffer: control flag set for non control protocol")
+	}
+
 	// Das Paket wird für Lokale Weiterverabeitung weitergereicht
 	if err := obj.EnterLocallyPackage(internal_package); err != nil {
 		return fmt.Errorf("DecryptLocallyPackageToBuffer: " + err.Error())
@@ -181,6 +219,11 @@ func (obj *Kernel) DecryptedLocallyPackageToBuffer(pckge *addresspackages.Sendab
 		Data:     readed_inner.Data,
 	}
 
+	// Nur Pakete von Steuerprotokollen dürfen als Steuerpakete übertragen werden
+	if pckge.Control && !obj._is_control_protocol(internal_package.Protocol) {
+		return fmt.Errorf("DecryptLocallyPackageToBuffer: control flag set for non control protocol")
+	}
+
 	// Das Paket wird für Lokale Weiterverabeitung weitergereicht
 	if err := obj.EnterLocallyPackage(internal_package); err != nil {
 		return fmt.Errorf("DecryptLocallyPackageToBuffer: " + err.Error())
@@ -197,6 +240,45 @@ func (obj *Kernel) EnterL2Package(pckge *addresspackages.SendableAddressLayerPac
 		return fmt.Errorf("EnterL2Package: kernel is not running")
 	}
 
+	// Es wird geprüft ob die Signatur des Paketes korrekt ist, wenn nicht wird das Paket verworfen
+	if !pckge.ValidateSignature() {
+		obj._lock.Lock()
+		obj._invalid_signatures++
+		obj._lock.Unlock()
+		obj.ReportInvalidPackage(conn)
+		obj._log.Debug("Kernel: package with invalid signature droped", "sender", hex.EncodeToString(pckge.Sender.SerializeCompressed()))
+		return nil
+	}
+
+	// Pakete deren Zeitstempel außerhalb des Zeitfensters des Caches liegen, werden verworfen,
+	// da sie nicht mehr als Duplikat erkannt werden könnten
+	package_time := time.Unix(pckge.Time, 0)
+	if !obj._seen_packages.in_window(package_time) {
+		obj._lock.Lock()
+		obj._outdated_packages++
+		obj._lock.Unlock()
+		obj._log.Debug("Kernel: package outside of time window droped", "sender", hex.EncodeToString(pckge.Sender.SerializeCompressed()), "time", pckge.Time)
+		return nil
+	}
+
+	// Es wird geprüft ob das Paket bereits empfangen wurde, wenn ja wird es verworfen
+	if obj._seen_packages.check_and_add(pckge.GetPackageHash(), package_time) {
+		obj._lock.Lock()
+		obj._duplicate_packages++
+		obj._lock.Unlock()
+		return nil
+	}
+
+	// Es wird geprüft ob das Paket durch die Firewall zugelassen wird
+	if !obj._firewall_check_inbound_l2_package(pckge, conn) {
+		return nil
+	}
+
+	// Es wird vermerkt dass der Absender über das Relay der Verbindung erreichbar ist
+	if !obj.IsLocallyAddress(pckge.Sender) {
+		obj._connection_manager.LearnRouteFromPackage(pckge, conn)
+	}
+
 	// Es wird geprüft ob es sich um eine Lokale Adresse handelt, wenn ja wird sie Lokal weiterverabeitet
 	if obj.IsLocallyAddress(pckge.Reciver) {
 		// Wenn es sich um ein Verschlüsseltes Paket handelt, wird versuch dieses zu Entschlüsseln,
@@ -230,6 +312,18 @@ func (obj *Kernel) EnterL2Package(pckge *addresspackages.SendableAddressLayerPac
 		return fmt.Errorf("EnterL2Package: kernel is not running")
 	}
 
+	// Das Hop Limit wird verringert, sollte es abgelaufen sein wird das Paket verworfen
+	// und der Absender wird darüber informiert
+	if pckge.HopLimit <= 1 {
+		obj._lock.Lock()
+		obj._expired_packages++
+		obj._lock.Unlock()
+		obj._log.Debug("Kernel: hop limit exceeded, package droped", "sender", hex.EncodeToString(pckge.Sender.SerializeCompressed()), "reciver", hex.EncodeToString(pckge.Reciver.SerializeCompressed()))
+		obj._send_hop_limit_exceeded(pckge)
+		return nil
+	}
+	pckge.HopLimit--
+
 	// Das Paket wird an das Netzwerk gesendet, sofern eine Route vorhanden ist, ansonsten wird das Paket verworfen
 	_, err := obj.WriteL2PackageByNetworkRoute(pckge)
 	if err != nil {
@@ -254,6 +348,11 @@ func (obj *Kernel) WriteL2PackageByNetworkRoute(pckge *addresspackages.SendableA
 
 // Verschlüsselt ein nicht Verschlüsseltes Layer 2 Paket, Signiert es und Sendet es ins Netzwerk
 func (obj *Kernel) EncryptPlainL2PackageAndWriteByNetworkRoute(pckge *addresspackages.AddressLayerPackage) (*extra.PackageSendState, error) {
+	// Es wird geprüft ob das Paket durch die Firewall zugelassen wird
+	if !obj._firewall_check_outbound_package(pckge) {
+		return nil, rerror.NewIOStateError(DROP_REASON_FIREWALL)
+	}
+
 	// Die Inneren Verschlüsselten Daten werden übertragen
 	internal_data := addresspackages.InnerFrame{
 		Protocol: pckge.Protocol,
@@ -273,34 +372,35 @@ func (obj *Kernel) EncryptPlainL2PackageAndWriteByNetworkRoute(pckge *addresspac
 		return nil, fmt.Errorf("EncryptPlainL2PackageAndWriteByNetworkRoute: 2: " + err.Error())
 	}
 
-	// Es wird ein Hash aus den Daten erstellt
-	sign_hash := utils.ComputeSha3256Hash(
-		pckge.Sender.SerializeCompressed(),
-		pckge.Reciver.SerializeCompressed(),
-		[]byte("cipher"),
-		encrypted_data,
-	)
-
-	// Der Paket Hash wird Signiert
-	package_signature, err := utils.Sign(obj._private_key, sign_hash)
+	// Es wird eine Zufallszahl für das Paket erstellt
+	nonce, err := addresspackages.NewPackageNonce()
 	if err != nil {
 		return nil, fmt.Errorf("EncryptPlainL2PackageAndWriteByNetworkRoute: 3: " + err.Error())
 	}
 
 	// Das Verschlüsselte Paket wird erstellt
 	builded_encrypted_package := addresspackages.SendableAddressLayerPackage{
-		Sender:  pckge.Sender,
-		Reciver: pckge.Reciver,
-		Data:    encrypted_data,
-		Sig:     package_signature,
-		Plain:   false,
-		PCI:     false,
+		Sender:   pckge.Sender,
+		Reciver:  pckge.Reciver,
+		Data:     encrypted_data,
+		Plain:    false,
+		PCI:      false,
+		HopLimit: addresspackages.DEFAULT_HOP_LIMIT,
+		Nonce:    nonce,
+		Control:  obj._is_control_protocol(pckge.Protocol),
+		Time:     time.Now().Unix(),
+	}
+
+	// Der Paket Hash wird Signiert
+	builded_encrypted_package.Sig, err = utils.Sign(obj._private_key, builded_encrypted_package.GetSignHash())
+	if err != nil {
+		return nil, fmt.Errorf("EncryptPlainL2PackageAndWriteByNetworkRoute: 4: " + err.Error())
 	}
 
 	// Das Paket wird an den Routing Manager übergebene
 	sstate, err := obj.WriteL2PackageByNetworkRoute(&builded_encrypted_package)
 	if err != nil {
-		return nil, fmt.Errorf("EncryptPlainL2PackageAndWriteByNetworkRoute: 4: " + err.Error())
+		return nil, fmt.Errorf("EncryptPlainL2PackageAndWriteByNetworkRoute: 5: " + err.Error())
 	}
 
 	// Der Vorgang wurde ohne Fehler durchgeführt
@@ -309,6 +409,11 @@ func (obj *Kernel) EncryptPlainL2PackageAndWriteByNetworkRoute(pckge *addresspac
 
 // Signiert ein Layer 2 Paket und sendet es unverschlüsselt an das Netzwerk
 func (obj *Kernel) PlainL2PackageAndWriteByNetworkRoute(pckge *addresspackages.AddressLayerPackage, please_check_instructions bool) (*extra.PackageSendState, error) {
+	// Es wird geprüft ob das Paket durch die Firewall zugelassen wird
+	if !obj._firewall_check_outbound_package(pckge) {
+		return nil, rerror.NewIOStateError(DROP_REASON_FIREWALL)
+	}
+
 	// Die Inneren Verschlüsselten Daten werden übertragen
 	internal_data := addresspackages.InnerFrame{
 		Protocol: pckge.Protocol,
@@ -322,28 +427,29 @@ func (obj *Kernel) PlainL2PackageAndWriteByNetworkRoute(pckge *addresspackages.A
 		return nil, fmt.Errorf("PlainL2PackageAndWriteByNetworkRoute: 1: " + err.Error())
 	}
 
-	// Es wird ein Hash aus den Daten erstellt
-	sign_hash := utils.ComputeSha3256Hash(
-		pckge.Sender.SerializeCompressed(),
-		pckge.Reciver.SerializeCompressed(),
-		[]byte("unciphered"),
-		byted_inner_data,
-	)
-
-	// Der Paket Hash wird Signiert
-	package_signature, err := utils.Sign(obj._private_key, sign_hash)
+	// Es wird eine Zufallszahl für das Paket erstellt
+	nonce, err := addresspackages.NewPackageNonce()
 	if err != nil {
-		return nil, fmt.Errorf("PlainL2PackageAndWriteByNetworkRoute: 3: " + err.Error())
+		return nil, fmt.Errorf("PlainL2PackageAndWriteByNetworkRoute: 2: " + err.Error())
 	}
 
 	// Das Verschlüsselte Paket wird erstellt
 	builded_encrypted_package := addresspackages.SendableAddressLayerPackage{
-		Sender:  pckge.Sender,
-		Reciver: pckge.Reciver,
-		Data:    byted_inner_data,
-		Sig:     package_signature,
-		Plain:   true,
-		PCI:     please_check_instructions,
+		Sender:   pckge.Sender,
+		Reciver:  pckge.Reciver,
+		Data:     byted_inner_data,
+		Plain:    true,
+		PCI:      please_check_instructions,
+		HopLimit: addresspackages.DEFAULT_HOP_LIMIT,
+		Nonce:    nonce,
+		Control:  obj._is_control_protocol(pckge.Protocol),
+		Time:     time.Now().Unix(),
+	}
+
+	// Der Paket Hash wird Signiert
+	builded_encrypted_package.Sig, err = utils.Sign(obj._private_key, builded_encrypted_package.GetSignHash())
+	if err != nil {
+		return nil, fmt.Errorf("PlainL2PackageAndWriteByNetworkRoute: 3: " + err.Error())
 	}
 
 	// Das Paket wird an den Routing Manager übergebene
@@ -1,21 +1,24 @@
 package kernel
 
 import (
+	"encoding/hex"
 	"fmt"
 
+	"github.com/btcsuite/btcd/btcec/v2"
 	apiclient "github.com/fluffelpuff/RoueX/api_client"
+	"github.com/fluffelpuff/RoueX/firewall"
 )
 
-// Ruft alle Relays ab
+// Ruft alle Relays ab, neben den Vertrauenswürdigen Relays werden auch alle verbundenen nicht Vertrauenswürdigen Relays abgerufen
 func (obj *Kernel) APIFetchAllRelays() ([]apiclient.ApiRelayEntry, error) {
-	// Es werden alle Trusted Relays abgerufen
-	trusted_relays := obj._trusted_relays.GetAllRelays()
+	// Es werden alle Trusted sowie alle verbundenen Untrusted Relays abgerufen
+	relays := append(obj._trusted_relays.GetAllRelays(), obj._connection_manager.GetUntrustedRelays()...)
 
 	// Die Rückgabe Liste wird erstellt
 	result_list := make([]apiclient.ApiRelayEntry, 0)
-	for i := range trusted_relays {
+	for i := range relays {
 		// Es wird versucht alle Meta Daten der Verbindung aus dem Verbindungs Manager abzurufen
-		meta_data, err := obj._connection_manager.GetAllMetaInformationsOfRelayConnections(trusted_relays[i])
+		meta_data, err := obj._connection_manager.GetAllMetaInformationsOfRelayConnections(relays[i])
 		if err != nil {
 			return nil, err
 		}
@@ -38,27 +41,35 @@ func (obj *Kernel) APIFetchAllRelays() ([]apiclient.ApiRelayEntry, error) {
 
 			// Die Daten werden hinzugefügt
 			result_list = append(result_list, apiclient.ApiRelayEntry{
-				Id:                trusted_relays[i]._hexed_id,
-				IsTrusted:         trusted_relays[i].IsTrusted(),
-				PublicKey:         trusted_relays[i].GetPublicKeyHexString(),
+				Id:                relays[i]._get_hexed_id(),
+				IsTrusted:         relays[i].IsTrusted(),
+				IsActive:          relays[i].IsActive(),
+				EndPoint:          relays[i].GetEndpoint(),
+				Protocol:          relays[i].GetProtocol(),
+				PublicKey:         relays[i].GetPublicKeyHexString(),
 				Connections:       recons,
 				IsConnected:       meta_data.IsConnected,
 				TotalConnections:  uint64(len(meta_data.Connections)),
 				TotalBytesSend:    meta_data.TotalWrited,
 				TotalBytesRecived: meta_data.TotalReaded,
 				PingMS:            meta_data.PingMS,
+				Reputation:        obj.GetRelayReputation(relays[i].GetPublicKey()),
 			})
 		} else {
 			result_list = append(result_list, apiclient.ApiRelayEntry{
-				Id:                trusted_relays[i]._hexed_id,
-				PublicKey:         trusted_relays[i].GetPublicKeyHexString(),
-				IsTrusted:         trusted_relays[i].IsTrusted(),
+				Id:                relays[i]._get_hexed_id(),
+				PublicKey:         relays[i].GetPublicKeyHexString(),
+				IsTrusted:         relays[i].IsTrusted(),
+				IsActive:          relays[i].IsActive(),
+				EndPoint:          relays[i].GetEndpoint(),
+				Protocol:          relays[i].GetProtocol(),
 				IsConnected:       false,
 				BandwithKBs:       0,
 				TotalConnections:  0,
 				TotalBytesSend:    0,
 				TotalBytesRecived: 0,
 				PingMS:            0,
+				Reputation:        obj.GetRelayReputation(relays[i].GetPublicKey()),
 			})
 		}
 	}
@@ -95,3 +106,183 @@ func (obj *Kernel) GetKernelProtocolById(protocol_type uint8) (KernelTypeProtoco
 	// Die Daten werden ohne Fehler zurückgegeben
 	return prot.Ptf, nil
 }
+
+// Liest den Öffentlichen Schlüssel eines Relays aus den API Argumenten ein
+func _read_api_relay_key(args apiclient.TrustedRelayArgs) (*btcec.PublicKey, error) {
+	pkey, err := btcec.ParsePubKey(args.PublicKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid public key")
+	}
+	return pkey, nil
+}
+
+// Fügt ein Vertrauenswürdiges Relay über die API hinzu
+func (obj *Kernel) APIAddTrustedRelay(args apiclient.TrustedRelayArgs) error {
+	pkey, err := _read_api_relay_key(args)
+	if err != nil {
+		return err
+	}
+	return obj.AddTrustedRelay(pkey, args.EndPoint, args.Protocol)
+}
+
+// Markiert ein verbundenes nicht Vertrauenswürdiges Relay über die API als Vertrauenswürdig,
+// das Relay wird anhand seines Öffentlichen Schlüssels oder der ID einer seiner Verbindungen ausgewählt
+func (obj *Kernel) APIPromoteRelay(args apiclient.TrustedRelayArgs) (string, error) {
+	// Der Öffentliche Schlüssel wird eingelesen, sofern er angegeben wurde
+	var pkey *btcec.PublicKey
+	if len(args.PublicKey) > 0 {
+		readed, err := _read_api_relay_key(args)
+		if err != nil {
+			return "", err
+		}
+		pkey = readed
+	} else if len(args.ConnectionId) == 0 {
+		return "", fmt.Errorf("no relay key or connection id")
+	}
+
+	// Das verbundene Relay wird abgerufen
+	relay := obj.GetConnectedRelayByKeyOrConnectionId(pkey, args.ConnectionId)
+	if relay == nil {
+		return "", fmt.Errorf("relay is not connected")
+	}
+
+	// Das Relay wird als Vertrauenswürdig markiert
+	if err := obj.PromoteRelay(relay, args.EndPoint, args.Protocol); err != nil {
+		return "", err
+	}
+
+	// Der Öffentliche Schlüssel des Relays wird zurückgegeben
+	return relay.GetPublicKeyHexString(), nil
+}
+
+// Entfernt ein Vertrauenswürdiges Relay über die API
+func (obj *Kernel) APIRemoveTrustedRelay(args apiclient.TrustedRelayArgs) error {
+	pkey, err := _read_api_relay_key(args)
+	if err != nil {
+		return err
+	}
+	return obj.RemoveTrustedRelay(pkey)
+}
+
+// Aktiviert oder Deaktiviert ein Vertrauenswürdiges Relay über die API
+func (obj *Kernel) APISetTrustedRelayActive(args apiclient.TrustedRelayArgs) error {
+	pkey, err := _read_api_relay_key(args)
+	if err != nil {
+		return err
+	}
+	return obj.SetTrustedRelayActive(pkey, args.Active)
+}
+
+// Aktualisiert den Endpunkt und das Protokoll eines Vertrauenswürdigen Relays über die API
+func (obj *Kernel) APIUpdateTrustedRelay(args apiclient.TrustedRelayArgs) error {
+	pkey, err := _read_api_relay_key(args)
+	if err != nil {
+		return err
+	}
+	return obj.UpdateTrustedRelay(pkey, args.EndPoint, args.Protocol)
+}
+
+// Gibt die Beschreibungen aller Relays aus den Relay Verzeichnissen über die API zurück
+func (obj *Kernel) APIFetchRelayDescriptors() []apiclient.ApiRelayDescriptor {
+	result := make([]apiclient.ApiRelayDescriptor, 0)
+	for _, descriptor := range obj.GetRelayDescriptors() {
+		end_points := make([]apiclient.ApiRelayDescriptorEndPoint, 0, len(descriptor.EndPoints))
+		for _, item := range descriptor.EndPoints {
+			end_points = append(end_points, apiclient.ApiRelayDescriptorEndPoint{Protocol: item.Protocol, Module: item.Module, EndPoint: item.EndPoint})
+		}
+		result = append(result, apiclient.ApiRelayDescriptor{
+			PublicKey: hex.EncodeToString(descriptor.PublicKey),
+			Version:   descriptor.Version,
+			Seq:       descriptor.Seq,
+			Expires:   descriptor.Expires,
+			EndPoints: end_points,
+		})
+	}
+	return result
+}
+
+// Liest einen optionalen Öffentlichen Schlüssel einer Firewall Regel ein, ein leerer Wert gilt für alle Schlüssel
+func _read_api_rule_key(value []byte) (*btcec.PublicKey, error) {
+	if len(value) == 0 {
+		return nil, nil
+	}
+	pkey, err := btcec.ParsePubKey(value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid public key")
+	}
+	return pkey, nil
+}
+
+// Wandelt einen optionalen Öffentlichen Schlüssel einer Firewall Regel in einen Hex String um
+func _api_rule_key_to_hex(pkey *btcec.PublicKey) string {
+	if pkey == nil {
+		return ""
+	}
+	return hex.EncodeToString(pkey.SerializeCompressed())
+}
+
+// Fügt eine Firewall Regel über die API hinzu
+func (obj *Kernel) APIAddFirewallRule(args apiclient.FirewallRuleArgs) (int64, error) {
+	// Die Öffentlichen Schlüssel werden eingelesen
+	sender_pkey, err := _read_api_rule_key(args.SenderPKey)
+	if err != nil {
+		return -1, err
+	}
+	reciver_pkey, err := _read_api_rule_key(args.ReciverPKey)
+	if err != nil {
+		return -1, err
+	}
+	relay_pkey, err := _read_api_rule_key(args.RelayPKey)
+	if err != nil {
+		return -1, err
+	}
+
+	// Die Regel wird hinzugefügt
+	return obj.AddFirewallRule(firewall.Rule{
+		Priority:    args.Priority,
+		Action:      firewall.Action(args.Action),
+		Direction:   firewall.Direction(args.Direction),
+		SenderPKey:  sender_pkey,
+		ReciverPKey: reciver_pkey,
+		RelayPKey:   relay_pkey,
+		Protocol:    args.Protocol,
+		Rate:        args.Rate,
+		Burst:       args.Burst,
+		Active:      args.Active,
+	})
+}
+
+// Entfernt eine Firewall Regel über die API
+func (obj *Kernel) APIRemoveFirewallRule(args apiclient.FirewallRuleArgs) error {
+	return obj.RemoveFirewallRule(args.RuleId)
+}
+
+// Gibt alle Firewall Regeln sowie die Anzahl der verworfenen Pakete über die API zurück
+func (obj *Kernel) APIFetchFirewallState() (*apiclient.ApiFirewallState, error) {
+	// Die Regeln werden abgerufen
+	rules, droped, err := obj.GetFirewallState()
+	if err != nil {
+		return nil, err
+	}
+
+	// Die Regeln werden umgewandelt
+	result := &apiclient.ApiFirewallState{TotalDropedPackages: droped, Rules: make([]apiclient.ApiFirewallRule, 0, len(rules))}
+	for _, rule := range rules {
+		result.Rules = append(result.Rules, apiclient.ApiFirewallRule{
+			Id:          rule.Id,
+			Priority:    rule.Priority,
+			Action:      string(rule.Action),
+			Direction:   string(rule.Direction),
+			SenderPKey:  _api_rule_key_to_hex(rule.SenderPKey),
+			ReciverPKey: _api_rule_key_to_hex(rule.ReciverPKey),
+			RelayPKey:   _api_rule_key_to_hex(rule.RelayPKey),
+			Protocol:    rule.Protocol,
+			Rate:        rule.Rate,
+			Burst:       rule.Burst,
+			Active:      rule.Active,
+		})
+	}
+
+	// Die Daten werden zurückgegeben
+	return result, nil
+}
@@ -1,17 +1,14 @@
 package kernel
 
 import (
+	"context"
 	"encoding/hex"
-	"fmt"
-	"log"
+	"log/slog"
 	"sync"
 
 	addresspackages "github.com/fluffelpuff/RoueX/address_packages"
 	"github.com/fluffelpuff/RoueX/kernel/extra"
-)
-
-const (
-	local_package_buffer_max uint = 1024 // Gibt an, wieviele Pakete der Kernel Paket Buffer zwischenspeichern darf
+	"github.com/fluffelpuff/RoueX/static"
 )
 
 // Stellt einen Eintrag dar
@@ -24,6 +21,7 @@ type kernel_package_buffer_entry struct {
 type kernel_package_buffer struct {
 	thrLock *sync.Mutex
 	buffer  chan *kernel_package_buffer_entry
+	_log    *slog.Logger
 }
 
 // Nimmt ein eintreffendes Paket entgegen
@@ -36,29 +34,43 @@ func (obj *kernel_package_buffer) AddL2Package(pckge *addresspackages.AddressLay
 	obj.buffer <- entry
 
 	// Log
-	log.Println("kernel_package_buffer: add package to buffer. phash = " + hex.EncodeToString(pckge.GetPackageHash()))
+	if obj._log.Enabled(context.Background(), slog.LevelDebug) {
+		obj._log.Debug("kernel_package_buffer: add package to buffer", "phash", hex.EncodeToString(pckge.GetPackageHash()))
+	}
 
 	// Der Vorgang wurde ohne Fehler durchgeführt
 	return new_sstate, nil
 }
 
-// Gibt das nächste Paket aus dem Buffer zurück
-func (obj *kernel_package_buffer) GetNextPackage() *addresspackages.AddressLayerPackage {
+// Gibt das nächste Paket aus dem Buffer zurück, sollte der Context abgebrochen werden, wird false zurückgegeben
+func (obj *kernel_package_buffer) GetNextPackage(ctx context.Context) (*addresspackages.AddressLayerPackage, bool) {
 	// Das nächste Paket aus dem Buffer wird abgerufen
-	retiv := <-obj.buffer
+	var retiv *kernel_package_buffer_entry
+	select {
+	case retiv = <-obj.buffer:
+	case <-ctx.Done():
+		return nil, false
+	}
 
 	// Log
-	log.Println("kernel_package_buffer: retrive package from buffer. phash = " + hex.EncodeToString(retiv.pckge.GetPackageHash()))
+	if obj._log.Enabled(ctx, slog.LevelDebug) {
+		obj._log.Debug("kernel_package_buffer: retrive package from buffer", "phash", hex.EncodeToString(retiv.pckge.GetPackageHash()))
+	}
 
 	// Die Daten werden zurückgegeben
-	return retiv.pckge
+	return retiv.pckge, true
+}
+
+// Gibt an ob sich noch Pakete im Buffer befinden
+func (obj *kernel_package_buffer) HasPendingPackages() bool {
+	return len(obj.buffer) > 0
 }
 
 // Erzeugt einen neuen Kernel
-func new_kernel_package_buffer() (*kernel_package_buffer, error) {
+func new_kernel_package_buffer(logger *slog.Logger) (*kernel_package_buffer, error) {
 	// Log:
-	fmt.Printf("New kernel package buffer created.\n")
+	logger.Debug("kernel_package_buffer: new kernel package buffer created", "capacity", static.LIMITS.KernelBufferMaxPackages)
 
 	// Der kernel_package_buffer wird zurückgegeben
-	return &kernel_package_buffer{new(sync.Mutex), make(chan *kernel_package_buffer_entry, local_package_buffer_max)}, nil
+	return &kernel_package_buffer{new(sync.Mutex), make(chan *kernel_package_buffer_entry, static.LIMITS.KernelBufferMaxPackages), logger}, nil
 }
@@ -0,0 +1,89 @@
+package kernel
+
+import (
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	addresspackages "github.com/fluffelpuff/RoueX/address_packages"
+	"github.com/fxamacker/cbor"
+)
+
+// Gibt den Protokolltypen für Diagnose Pakete an, diese werden direkt vom Kernel verarbeitet
+const DIAGNOSTIC_PROTOCOL_TYPE uint8 = 255
+
+// Gibt an, in welchem Abstand einem Absender maximal eine Diagnose gesendet wird
+const DIAGNOSTIC_MIN_INTERVAL = 1 * time.Second
+
+// Definiert alle verfügbaren Diagnose Typen
+const (
+	DIAGNOSTIC_HOP_LIMIT_EXCEEDED = uint8(0)
+)
+
+// Stellt ein Diagnose Paket dar
+type DiagnosticPackage struct {
+	Type        uint8  `cbor:"1,keyasint"`
+	PackageHash []byte `cbor:"2,keyasint"`
+	Reciver     []byte `cbor:"3,keyasint"`
+}
+
+// Sendet dem Absender eines Paketes die Information, dass das Hop Limit abgelaufen ist
+func (obj *Kernel) _send_hop_limit_exceeded(pckge *addresspackages.SendableAddressLayerPackage) {
+	// Sollte das Paket vom eigenen Relay stammen, wird keine Diagnose gesendet
+	if obj.IsLocallyAddress(pckge.Sender) {
+		return
+	}
+
+	// Es wird geprüft ob dem Absender vor kurzem bereits eine Diagnose gesendet wurde
+	sender_hex := hex.EncodeToString(pckge.Sender.SerializeCompressed())
+	obj._lock.Lock()
+	now := time.Now()
+	if last, found := obj._diagnostic_times[sender_hex]; found && now.Sub(last) < DIAGNOSTIC_MIN_INTERVAL {
+		obj._lock.Unlock()
+		return
+	}
+	obj._diagnostic_times[sender_hex] = now
+	for key, value := range obj._diagnostic_times {
+		if now.Sub(value) >= DIAGNOSTIC_MIN_INTERVAL {
+			delete(obj._diagnostic_times, key)
+		}
+	}
+	obj._lock.Unlock()
+
+	// Das Diagnose Paket wird gebaut
+	diag := DiagnosticPackage{
+		Type:        DIAGNOSTIC_HOP_LIMIT_EXCEEDED,
+		PackageHash: pckge.GetPackageHash(),
+		Reciver:     pckge.Reciver.SerializeCompressed(),
+	}
+	encoded, err := cbor.Marshal(diag, cbor.EncOptions{})
+	if err != nil {
+		obj._log.Warn("Kernel: error by building diagnostic package", "error", err.Error())
+		return
+	}
+
+	// Das Diagnose Paket wird an den Absender gesendet
+	if _, err := obj.EnterBytesEncryptAndSendL2PackageToNetwork(DIAGNOSTIC_PROTOCOL_TYPE, encoded, &pckge.Sender); err != nil {
+		obj._log.Warn("Kernel: error by sending diagnostic package", "error", err.Error())
+	}
+}
+
+// Nimmt eintreffende Diagnose Pakete entgegen
+func (obj *Kernel) _enter_diagnostic_package(pckge *addresspackages.AddressLayerPackage) error {
+	// Es wird versucht das Paket einzulesen
+	var diag DiagnosticPackage
+	if err := cbor.Unmarshal(pckge.Data, &diag); err != nil {
+		return fmt.Errorf("_enter_diagnostic_package: " + err.Error())
+	}
+
+	// Es wird geprüft um welche Diagnose es sich handelt
+	switch diag.Type {
+	case DIAGNOSTIC_HOP_LIMIT_EXCEEDED:
+		obj._log.Info("Kernel: hop limit of package exceeded", "relay", hex.EncodeToString(pckge.Sender.SerializeCompressed()), "reciver", hex.EncodeToString(diag.Reciver), "package", hex.EncodeToString(diag.PackageHash))
+	default:
+		obj._log.Info("Kernel: unkown diagnostic package recived", "relay", hex.EncodeToString(pckge.Sender.SerializeCompressed()), "type", diag.Type)
+	}
+
+	// Der Vorgang wurde ohne Fehler durchgeführt
+	return nil
+}
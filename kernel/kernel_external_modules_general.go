@@ -4,7 +4,6 @@ package kernel
 
 import (
 	"fmt"
-	"log"
 	"os"
 	"plugin"
 	"strings"
@@ -25,7 +24,7 @@ func (obj *Kernel) LoadExternalKernelModules() error {
 	}
 
 	// Log
-	log.Printf("Kernel: loading external Kernel Modules. id = %s path = %s\n", obj._kernel_id, obj._external_modules_path)
+	obj._log.Info("Kernel: loading external Kernel Modules", "id", obj._kernel_id, "path", obj._external_modules_path)
 
 	// Die Externen Module werden geladen
 	loaded_modules := []*ExternalModule{}
@@ -59,14 +58,14 @@ func (obj *Kernel) LoadExternalKernelModules() error {
 		}
 
 		// Log
-		log.Printf("Kernel: module loaded. id = %s, module = %s, name = %s\n", obj._kernel_id, obj._external_modules_path+obj._os_path_trimmer+file.Name(), extr_module.GetName())
+		obj._log.Info("Kernel: module loaded", "id", obj._kernel_id, "module", obj._external_modules_path+obj._os_path_trimmer+file.Name(), "name", extr_module.GetName())
 
 		// Das Module wird der Modules liste hinzugefügt
 		loaded_modules = append(loaded_modules, &extr_module)
 	}
 
 	// Log
-	log.Printf("Kernel: total modules loaded. id = %s, total = %d\n", obj._kernel_id, len(loaded_modules))
+	obj._log.Info("Kernel: total modules loaded", "id", obj._kernel_id, "total", len(loaded_modules))
 
 	// Der Vorgang wurde ohne Fehler abgeschlossen
 	return nil
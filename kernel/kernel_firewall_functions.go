@@ -0,0 +1,167 @@
+package kernel
+
+import (
+	"encoding/hex"
+	"fmt"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	addresspackages "github.com/fluffelpuff/RoueX/address_packages"
+	"github.com/fluffelpuff/RoueX/firewall"
+	"github.com/fluffelpuff/RoueX/logging"
+)
+
+// Gibt den Öffentlichen Schlüssel des Relays zurück, über welches eine Verbindung besteht
+func (obj *Kernel) _relay_pkey_by_connection(conn RelayConnection) *btcec.PublicKey {
+	// Sollte keine Verbindung vorhanden sein, wird kein Schlüssel zurückgegeben
+	if conn == nil {
+		return nil
+	}
+
+	// Das Relay wird aus dem Verbindungsmanager abgerufen
+	relay, found, err := obj._connection_manager.GetRelayByConnection(conn)
+	if err != nil || !found {
+		return nil
+	}
+
+	// Der Öffentliche Schlüssel wird zurückgegeben
+	return relay.GetPublicKey()
+}
+
+// Prüft ob ein über das Netzwerk eintreffendes Layer 2 Paket die Firewall passieren darf
+func (obj *Kernel) _firewall_check_inbound_l2_package(pckge *addresspackages.SendableAddressLayerPackage, conn RelayConnection) bool {
+	// Sollte keine Firewall vorhanden sein, wird das Paket zugelassen
+	if obj._firewall == nil {
+		return true
+	}
+
+	// Bei nicht Verschlüsselten Paketen ist das Protokoll bereits bekannt
+	protocol := firewall.UNKOWN_PROTOCOL
+	if pckge.Plain {
+		if inner, err := addresspackages.ReadInnerFrameFromBytes(pckge.Data); err == nil {
+			protocol = int16(inner.Protocol)
+		}
+	}
+
+	// Das Paket wird geprüft
+	ctx := &firewall.PackageContext{
+		Direction: firewall.INBOUND,
+		Sender:    &pckge.Sender,
+		Reciver:   &pckge.Reciver,
+		Relay:     obj._relay_pkey_by_connection(conn),
+		Protocol:  protocol,
+	}
+	if !obj._firewall.CheckPackage(ctx) {
+		obj.Logger(logging.FIREWALL).Debug("Kernel: inbound package droped by firewall", "sender", hex.EncodeToString(pckge.Sender.SerializeCompressed()), "reciver", hex.EncodeToString(pckge.Reciver.SerializeCompressed()))
+		obj._metrics.add_dropped_package(DROP_REASON_FIREWALL)
+		return false
+	}
+
+	// Das Paket darf passieren
+	return true
+}
+
+// Prüft ob ein Lokal zuzustellendes Paket die Firewall passieren darf
+func (obj *Kernel) _firewall_check_locally_package(pckge *addresspackages.AddressLayerPackage) bool {
+	// Sollte keine Firewall vorhanden sein, wird das Paket zugelassen
+	if obj._firewall == nil {
+		return true
+	}
+
+	// Das Paket wird geprüft
+	ctx := &firewall.PackageContext{
+		Direction: firewall.INBOUND,
+		Sender:    &pckge.Sender,
+		Reciver:   &pckge.Reciver,
+		Protocol:  int16(pckge.Protocol),
+	}
+	if !obj._firewall.CheckPackage(ctx) {
+		obj.Logger(logging.FIREWALL).Debug("Kernel: locally package droped by firewall", "sender", hex.EncodeToString(pckge.Sender.SerializeCompressed()), "protocol", pckge.Protocol)
+		obj._metrics.add_dropped_package(DROP_REASON_FIREWALL)
+		return false
+	}
+
+	// Das Paket darf passieren
+	return true
+}
+
+// Prüft ob ein Lokal erzeugtes Paket die Firewall in Richtung Netzwerk passieren darf
+func (obj *Kernel) _firewall_check_outbound_package(pckge *addresspackages.AddressLayerPackage) bool {
+	// Sollte keine Firewall vorhanden sein, wird das Paket zugelassen
+	if obj._firewall == nil {
+		return true
+	}
+
+	// Das Paket wird geprüft
+	ctx := &firewall.PackageContext{
+		Direction: firewall.OUTBOUND,
+		Sender:    &pckge.Sender,
+		Reciver:   &pckge.Reciver,
+		Protocol:  int16(pckge.Protocol),
+	}
+	if !obj._firewall.CheckPackage(ctx) {
+		obj.Logger(logging.FIREWALL).Debug("Kernel: outbound package droped by firewall", "reciver", hex.EncodeToString(pckge.Reciver.SerializeCompressed()), "protocol", pckge.Protocol)
+		obj._metrics.add_dropped_package(DROP_REASON_FIREWALL)
+		return false
+	}
+
+	// Das Paket darf passieren
+	return true
+}
+
+// Prüft ob ein Relay eine Eingehende Verbindung aufbauen darf
+func (obj *Kernel) FirewallAllowInboundRelay(relay_pkey *btcec.PublicKey) bool {
+	// Sollte keine Firewall vorhanden sein, wird die Verbindung zugelassen
+	if obj._firewall == nil {
+		return true
+	}
+
+	// Der Verbindungsaufbau wird geprüft
+	if !obj._firewall.CheckInboundHandshake(relay_pkey) {
+		obj.Logger(logging.FIREWALL).Info("Kernel: inbound connection rejected by firewall", "relay", hex.EncodeToString(relay_pkey.SerializeCompressed()))
+		return false
+	}
+
+	// Die Verbindung darf aufgebaut werden
+	return true
+}
+
+// Fügt der Firewall eine neue Regel hinzu, die ID der Regel wird zurückgegeben
+func (obj *Kernel) AddFirewallRule(rule firewall.Rule) (int64, error) {
+	// Es wird geprüft ob eine Firewall vorhanden ist
+	if obj._firewall == nil {
+		return -1, fmt.Errorf("AddFirewallRule: 1: no firewall loaded")
+	}
+
+	// Die Regel wird hinzugefügt
+	rule_id, err := obj._firewall.AddRule(rule)
+	if err != nil {
+		return -1, fmt.Errorf("AddFirewallRule: 2: " + err.Error())
+	}
+
+	// Die ID der Regel wird zurückgegeben
+	return rule_id, nil
+}
+
+// Entfernt eine Regel aus der Firewall
+func (obj *Kernel) RemoveFirewallRule(rule_id int64) error {
+	// Es wird geprüft ob eine Firewall vorhanden ist
+	if obj._firewall == nil {
+		return fmt.Errorf("RemoveFirewallRule: 1: no firewall loaded")
+	}
+
+	// Die Regel wird entfernt
+	if err := obj._firewall.RemoveRule(rule_id); err != nil {
+		return fmt.Errorf("RemoveFirewallRule: 2: " + err.Error())
+	}
+
+	// Der Vorgang wurde ohne Fehler durchgeführt
+	return nil
+}
+
+// Gibt alle Regeln der Firewall sowie die Anzahl der verworfenen Pakete zurück
+func (obj *Kernel) GetFirewallState() ([]*firewall.Rule, uint64, error) {
+	if obj._firewall == nil {
+		return nil, 0, fmt.Errorf("GetFirewallState: no firewall loaded")
+	}
+	return obj._firewall.GetAllRules(), obj._firewall.GetTotalDropedPackages(), nil
+}
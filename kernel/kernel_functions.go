@@ -4,9 +4,9 @@ import (
 	"bytes"
 	"encoding/hex"
 	"fmt"
-	"log"
 
 	"github.com/btcsuite/btcd/btcec/v2"
+	routingmanager "github.com/fluffelpuff/RoueX/routing_manager"
 	"github.com/fluffelpuff/RoueX/utils"
 )
 
@@ -15,7 +15,7 @@ func (obj *Kernel) RegisterServerModule(lcsep ServerModule) error {
 	if obj.IsRunning() {
 		return fmt.Errorf("can't add local ep than server is running")
 	}
-	log.Println("Kernel: register new server module.", "protocol =", lcsep.GetProtocol(), "id =", lcsep.GetObjectId())
+	obj._log.Info("Kernel: register new server module", "protocol", lcsep.GetProtocol(), "id", lcsep.GetObjectId())
 	if err := lcsep.RegisterKernel(obj); err != nil {
 		return err
 	}
@@ -28,7 +28,7 @@ func (obj *Kernel) RegisterClientModule(csep ClientModule) error {
 	if obj.IsRunning() {
 		return fmt.Errorf("can't add local ep than server is running")
 	}
-	log.Println("Kernel: register new client module.", "protocol =", csep.GetProtocol(), "id =", csep.GetObjectId())
+	obj._log.Info("Kernel: register new client module", "protocol", csep.GetProtocol(), "id", csep.GetObjectId())
 	if err := csep.RegisterKernel(obj); err != nil {
 		return err
 	}
@@ -48,6 +48,9 @@ func (obj *Kernel) GetTrustedRelayByPublicKey(pkey *btcec.PublicKey) (*Relay, er
 		return nil, err
 	}
 	for i := range relays {
+		if !relays[i].IsActive() {
+			continue
+		}
 		if bytes.Equal(relays[i]._public_key.SerializeCompressed(), pkey.SerializeCompressed()) {
 			return relays[i], nil
 		}
@@ -55,12 +58,31 @@ func (obj *Kernel) GetTrustedRelayByPublicKey(pkey *btcec.PublicKey) (*Relay, er
 	return nil, nil
 }
 
+// Gibt das Relay zurück, unter welchem bereits Verbindungen mit diesem Schlüssel bestehen,
+// so werden weitere Verbindungen eines nicht Vertrauenswürdigen Relays dem selben Eintrag zugeordnet
+func (obj *Kernel) GetConnectedRelayByPublicKey(pkey *btcec.PublicKey) *Relay {
+	return obj._connection_manager.GetRelayByPublicKey(pkey)
+}
+
 // Markiert einen Relay als Verbunden
 func (obj *Kernel) AddNewConnection(relay *Relay, conn RelayConnection) error {
+	// Die Zulassung wird gesperrt, so können nicht mehrere Relays gleichzeitig den selben freien Platz belegen
+	obj._admission_lock.Lock()
+
+	// Es wird geprüft ob ein nicht Vertrauenswürdiges Relay zugelassen wird
+	if !relay.IsTrusted() {
+		if err := obj._admit_untrusted_relay(relay); err != nil {
+			obj._admission_lock.Unlock()
+			return fmt.Errorf("AddNewConnection: " + err.Error())
+		}
+	}
+
 	// Sollte kein Relay vorhanden sein, wird die Verbindung als nicht Verifiziert gespeichert
 	if err := obj._connection_manager.RegisterNewRelayConnection(relay, conn); err != nil {
+		obj._admission_lock.Unlock()
 		return err
 	}
+	obj._admission_lock.Unlock()
 
 	// Der Kernel wird in der Verbindung registriert
 	if err := conn.RegisterKernel(obj); err != nil {
@@ -71,6 +93,9 @@ func (obj *Kernel) AddNewConnection(relay *Relay, conn RelayConnection) error {
 		return err
 	}
 
+	// Der erfolgreiche Handshake verbessert das Ansehen des Relays
+	obj._change_relay_reputation(relay.GetPublicKey(), REPUTATION_HANDSHAKE, "handshake")
+
 	// Der Vorgang wurde erfolgreich druchgeführt
 	return nil
 }
@@ -78,14 +103,58 @@ func (obj *Kernel) AddNewConnection(relay *Relay, conn RelayConnection) error {
 // Markiert einen Relay als nicht mehr Verbunden
 func (obj *Kernel) RemoveConnection(conn RelayConnection) error {
 	// Die Verbindung wird entfernt
-	if err := obj._connection_manager.RemoveConnectionFromRelay(conn); err != nil {
+	withdrawal, err := obj._connection_manager.RemoveConnectionFromRelay(conn)
+	if err != nil {
 		return fmt.Errorf("RemoveConnection: 1: " + err.Error())
 	}
 
+	// Sollte keine Verbindung mehr mit dem Relay bestehen, werden die Beobachter informiert
+	if withdrawal != nil {
+		for _, observer := range obj._get_relay_state_observers() {
+			go observer.RelayDisconnected(withdrawal.Relay, withdrawal.Destinations)
+		}
+	}
+
 	// Der Vorgang wurde erfolgreich druchgeführt
 	return nil
 }
 
+// Registriert einen Beobachter, welcher über neue und getrennte Relays informiert wird
+func (obj *Kernel) RegisterRelayStateObserver(observer RelayStateObserver) {
+	obj._lock.Lock()
+	obj._relay_observers = append(obj._relay_observers, observer)
+	obj._lock.Unlock()
+}
+
+// Gibt eine Kopie der Liste aller Beobachter zurück
+func (obj *Kernel) _get_relay_state_observers() []RelayStateObserver {
+	obj._lock.Lock()
+	result := make([]RelayStateObserver, len(obj._relay_observers))
+	copy(result, obj._relay_observers)
+	obj._lock.Unlock()
+	return result
+}
+
+// Gibt alle Relays zurück, mit denen eine Aktive Direkte Verbindung besteht
+func (obj *Kernel) GetConnectedRelays() []*Relay {
+	return obj._connection_manager.GetConnectedRelays()
+}
+
+// Gibt an ob mit einem Relay eine Aktive Direkte Verbindung besteht
+func (obj *Kernel) HasDirectRoute(pkey *btcec.PublicKey) bool {
+	return obj._connection_manager.HasDirectRoute(pkey)
+}
+
+// Gibt an ob mit einem Vertrauenswürdigen Relay eine Aktive Direkte Verbindung besteht
+func (obj *Kernel) HasTrustedDirectRoute(pkey *btcec.PublicKey) bool {
+	return obj._connection_manager.HasTrustedDirectRoute(pkey)
+}
+
+// Gibt den Routing Manager des Kernels zurück
+func (obj *Kernel) GetRoutingManager() *routingmanager.RoutingManager {
+	return obj._routing_table
+}
+
 // Gibt die KernelID aus
 func (obj *Kernel) GetKernelID() string {
 	return obj._kernel_id
@@ -96,14 +165,14 @@ func (obj *Kernel) ListOutboundTrustedAvaileRelays() ([]RelayOutboundPair, error
 	// Es werden alle Endpunkte abgerufen für welches das Protokoll bekannt ist
 	filtered_list := []RelayOutboundPair{}
 	for _, x := range obj._trusted_relays.GetAllRelays() {
-		if len(x._type) > 1 {
+		if len(x.GetProtocol()) > 1 && len(x.GetEndpoint()) > 0 && x.IsActive() {
 			if obj._connection_manager.RelayIsConnected(x) {
 				continue
 			}
 			recov_entry := RelayOutboundPair{_relay: x}
 			for _, r := range obj._client_modules {
 				vat := r
-				if vat.GetProtocol() == x._type {
+				if vat.GetProtocol() == x.GetProtocol() {
 					recov_entry._cl_module = &r
 					break
 				}
@@ -195,7 +264,7 @@ func (obj *Kernel) CreateOTKECDHKey(otk_id string, dest_pkey *btcec.PublicKey) (
 	obj._temp_ecdh_keys[rand_id] = shared_secret
 	obj._lock.Unlock()
 
-	log.Println("Kernel: new ecdh key computed. otk_id =", rand_id, " dh_hash =", hex.EncodeToString(utils.ComputeSha3256Hash(shared_secret)))
+	obj._log.Debug("Kernel: new ecdh key computed", "otk_id", rand_id, "dh_hash", hex.EncodeToString(utils.ComputeSha3256Hash(shared_secret)))
 	return rand_id, nil
 }
 
@@ -211,9 +280,7 @@ func (obj *Kernel) EncryptOTKECDHById(algo utils.EncryptionAlgo, otk_id string,
 
 	switch algo {
 	case utils.CHACHA_2020:
-		r, e := utils.EncryptWithChaCha(ecdh_key, data)
-		log.Println("Kernel: encrypting data with chacha20. otk_id =", otk_id, "data_size =", len(data))
-		return r, e
+		return utils.EncryptWithChaCha(ecdh_key, data)
 	default:
 		return nil, fmt.Errorf("unkown algo")
 	}
@@ -231,9 +298,7 @@ func (obj *Kernel) DecryptOTKECDHById(algo utils.EncryptionAlgo, otk_id string,
 
 	switch algo {
 	case utils.CHACHA_2020:
-		r, e := utils.DecryptWithChaCha(ecdh_key, data)
-		log.Println("Kernel: decrypting data with chacha20, otk_id =", otk_id, "data_size =", len(data))
-		return r, e
+		return utils.DecryptWithChaCha(ecdh_key, data)
 	default:
 		return nil, fmt.Errorf("unkown algo")
 	}
@@ -241,7 +306,6 @@ func (obj *Kernel) DecryptOTKECDHById(algo utils.EncryptionAlgo, otk_id string,
 
 // Wird verwendet um einen Verschlüsselten Datensatz mit dem Privaten Relay Schlüssel zu enschlüsseln
 func (obj *Kernel) DecryptWithPrivateRelayKey(cipher_data []byte) ([]byte, error) {
-	log.Println("Kernel: decrypting data with relay key. data_size =", len(cipher_data))
 	return utils.DecryptDataWithPrivateKey(obj._private_key, cipher_data)
 }
 
@@ -250,32 +314,32 @@ func (obj *Kernel) DumpsRoutesForRelayByConnection(conn RelayConnection) (bool,
 	// Es wird versucht das Relay anhand der Verbindung aus dem Verbindungsmanager abzurufen
 	relay, found, err := obj._connection_manager.GetRelayByConnection(conn)
 	if err != nil {
-		log.Println("Kernel: error by dumping routes for relays. error 1 =", err.Error(), "connection =", conn.GetObjectId())
+		obj._log.Warn("Kernel: error by dumping routes for relays", "error", err.Error(), "connection", conn.GetObjectId())
 		return false, false
 	}
 
 	// Sollte kein Relay vorhanden sein, wird der Vorgang abgebrochen
 	if !found {
-		log.Println("Kernel: dumping relay routes, no relay found.", "connection =", conn.GetObjectId())
+		obj._log.Debug("Kernel: dumping relay routes, no relay found", "connection", conn.GetObjectId())
 		return true, false
 	}
 
 	// Es wird geprüft ob die Verbindung noch besteht, wenn nicht wird der Vorgang abgebrochen
 	if !conn.IsConnected() {
-		log.Println("Kernel: error by dumping routes for relays, conenction was closed.", "connection =", conn.GetObjectId(), "relay =", hex.EncodeToString(relay.GetPublicKey().SerializeCompressed()))
+		obj._log.Warn("Kernel: error by dumping routes for relays, conenction was closed", "connection", conn.GetObjectId(), "relay", hex.EncodeToString(relay.GetPublicKey().SerializeCompressed()))
 		return false, false
 	}
 
 	// Es werden alle Routen für diesen Relay aus der Routing Datenbank abgerufen
 	routing_endpoints, err := obj._routing_table.FetchRoutesByRelay(relay)
 	if err != nil {
-		log.Println("Kernel: error by dumping routes for relays. error 2 =", err.Error(), "connection =", conn.GetObjectId(), "relay =", hex.EncodeToString(relay.GetPublicKey().SerializeCompressed()))
+		obj._log.Warn("Kernel: error by dumping routes for relays", "error", err.Error(), "connection", conn.GetObjectId(), "relay", hex.EncodeToString(relay.GetPublicKey().SerializeCompressed()))
 		return false, false
 	}
 
 	// Es wird geprüft ob die Verbindung noch besteht, wenn nicht wird der Vorgang abgebrochen
 	if !conn.IsConnected() {
-		log.Println("Kernel: error by dumping routes for relays, conenction was closed.", "connection =", conn.GetObjectId(), "relay =", hex.EncodeToString(relay.GetPublicKey().SerializeCompressed()))
+		obj._log.Warn("Kernel: error by dumping routes for relays, conenction was closed", "connection", conn.GetObjectId(), "relay", hex.EncodeToString(relay.GetPublicKey().SerializeCompressed()))
 		return false, false
 	}
 
@@ -285,9 +349,13 @@ func (obj *Kernel) DumpsRoutesForRelayByConnection(conn RelayConnection) (bool,
 		return true, false
 	}
 
-	// Log
+	// Sollten die Routen erstmals initalisiert worden sein, ist die Verbindung mit dem Relay vollständig aufgebaut,
+	// in diesem Fall werden die Beobachter informiert
 	if routes_was_inited {
-		log.Println("Kernel: dumping relay routes, done", "connection =", conn.GetObjectId(), "relay =", hex.EncodeToString(relay.GetPublicKey().SerializeCompressed()))
+		obj._log.Debug("Kernel: dumping relay routes, done", "connection", conn.GetObjectId(), "relay", hex.EncodeToString(relay.GetPublicKey().SerializeCompressed()))
+		for _, observer := range obj._get_relay_state_observers() {
+			go observer.RelayConnected(relay)
+		}
 	}
 
 	// Der Vorgang wurde erfolgreich durchgeführt
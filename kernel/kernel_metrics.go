@@ -0,0 +1,276 @@
+package kernel
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/fluffelpuff/RoueX/static"
+)
+
+// Gibt den Grund an, wenn ein Paket aufgrund einer fehlenden Route verworfen wurde
+const DROP_REASON_NO_ROUTE = "no route found"
+
+// Gibt den Grund an, wenn ein Paket durch die Firewall verworfen wurde
+const DROP_REASON_FIREWALL = "droped by firewall"
+
+// Gibt den Grund an, wenn ein Paket für ein unbekanntes Protokoll verworfen wurde
+const DROP_REASON_UNKOWN_PROTOCOL = "unkown protocol"
+
+// Stellt den Schlüssel eines fehlgeschlagenen Handshakes dar
+type handshake_failure_key struct {
+	io_type  ConnectionIoType
+	protocol string
+}
+
+// Stellt die übertragenen Daten der bereits geschlossenen Verbindungen eines Relays dar
+type relay_byte_totals struct {
+	tx uint64
+	rx uint64
+}
+
+// Stellt die Zähler für die Metriken des Kernels dar
+type kernel_metrics struct {
+	_lock               *sync.Mutex
+	_dropped_packages   map[string]uint64
+	_handshake_failures map[handshake_failure_key]uint64
+	_protocol_packages  map[uint8]uint64
+	_closed_relay_bytes map[string]*relay_byte_totals
+}
+
+// Vermerkt ein verworfenes Paket
+func (obj *kernel_metrics) add_dropped_package(reason string) {
+	obj._lock.Lock()
+	defer obj._lock.Unlock()
+	obj._dropped_packages[reason]++
+}
+
+// Vermerkt einen fehlgeschlagenen Handshake
+func (obj *kernel_metrics) add_handshake_failure(io_type ConnectionIoType, protocol string) {
+	obj._lock.Lock()
+	defer obj._lock.Unlock()
+	obj._handshake_failures[handshake_failure_key{io_type: io_type, protocol: protocol}]++
+}
+
+// Vermerkt ein Lokal zugestelltes Paket eines Protokolls
+func (obj *kernel_metrics) add_protocol_package(tpe uint8) {
+	obj._lock.Lock()
+	defer obj._lock.Unlock()
+	obj._protocol_packages[tpe]++
+}
+
+// Vermerkt die übertragenen Daten einer geschlossenen Verbindung, so bleiben die Zähler eines Relays fortlaufend
+func (obj *kernel_metrics) add_closed_connection(relay string, tx uint64, rx uint64) {
+	obj._lock.Lock()
+	defer obj._lock.Unlock()
+	totals, found := obj._closed_relay_bytes[relay]
+	if !found {
+		totals = &relay_byte_totals{}
+		obj._closed_relay_bytes[relay] = totals
+	}
+	totals.tx += tx
+	totals.rx += rx
+}
+
+// Erstellt ein neues Metriken Objekt
+func new_kernel_metrics() *kernel_metrics {
+	return &kernel_metrics{
+		_lock:               new(sync.Mutex),
+		_dropped_packages:   make(map[string]uint64),
+		_handshake_failures: make(map[handshake_failure_key]uint64),
+		_protocol_packages:  make(map[uint8]uint64),
+		_closed_relay_bytes: make(map[string]*relay_byte_totals),
+	}
+}
+
+// Stellt einen einzelnen Messwert einer Metrik dar
+type metric_sample struct {
+	labels []string
+	value  uint64
+}
+
+// Maskiert einen Label Wert für das Prometheus Textformat
+func escape_metric_label(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+}
+
+// Schreibt eine Metrik mitsamt ihrer Beschreibung im Prometheus Textformat,
+// die Labels der Messwerte werden als Name/Wert Paare angegeben
+func write_metric(buf *bytes.Buffer, name string, help string, tpe string, samples []metric_sample) {
+	fmt.Fprintf(buf, "# HELP %s %s\n", name, help)
+	fmt.Fprintf(buf, "# TYPE %s %s\n", name, tpe)
+	for _, sample := range samples {
+		// Sollten keine Labels vorhanden sein, wird nur der Wert geschrieben
+		if len(sample.labels) == 0 {
+			fmt.Fprintf(buf, "%s %d\n", name, sample.value)
+			continue
+		}
+
+		// Die Labels werden zusammengebaut
+		labels := make([]string, 0, len(sample.labels)/2)
+		for i := 0; i+1 < len(sample.labels); i += 2 {
+			labels = append(labels, fmt.Sprintf(`%s="%s"`, sample.labels[i], escape_metric_label(sample.labels[i+1])))
+		}
+		fmt.Fprintf(buf, "%s{%s} %d\n", name, strings.Join(labels, ","), sample.value)
+	}
+}
+
+// Gibt die Bezeichnung der Verbindungsrichtung zurück
+func io_type_label(io_type ConnectionIoType) string {
+	switch io_type {
+	case INBOUND:
+		return "inbound"
+	case OUTBOUND:
+		return "outbound"
+	default:
+		return "unkown"
+	}
+}
+
+// Vermerkt einen fehlgeschlagenen Handshake einer Relay Verbindung
+func (obj *Kernel) RecordHandshakeFailure(io_type ConnectionIoType, protocol string) {
+	obj._metrics.add_handshake_failure(io_type, protocol)
+}
+
+// Gibt den Namen eines Protokolls für die Metriken zurück
+func (obj *Kernel) _metric_protocol_name(tpe uint8) string {
+	// Diagnose Pakete werden direkt vom Kernel verarbeitet
+	if tpe == DIAGNOSTIC_PROTOCOL_TYPE {
+		return "diagnostic"
+	}
+
+	// Es wird geprüft ob ein Protokoll für diesen Typen registriert wurde
+	obj._lock.Lock()
+	defer obj._lock.Unlock()
+	entry, found := obj._protocols[int(tpe)]
+	if !found {
+		return "unkown"
+	}
+	return entry.Ptf.GetProtocolName()
+}
+
+// Schreibt alle Metriken des Kernels im Prometheus Textformat
+func (obj *Kernel) WriteMetrics(w io.Writer) error {
+	// Die Metriken werden zuerst zwischengespeichert, so wird keine unvollständige Antwort geschrieben
+	buf := new(bytes.Buffer)
+
+	// Die Metadaten aller Verbundenen Relays werden abgerufen
+	relay_tx, relay_rx, relay_ping := make([]metric_sample, 0), make([]metric_sample, 0), make([]metric_sample, 0)
+	conn_tx, conn_rx, conn_ping := make([]metric_sample, 0), make([]metric_sample, 0), make([]metric_sample, 0)
+	conn_buf_packages, conn_buf_bytes := make([]metric_sample, 0), make([]metric_sample, 0)
+	relays_meta_data, err := obj._connection_manager.GetAllRelaysMetaData()
+	if err != nil {
+		return fmt.Errorf("WriteMetrics: 1: " + err.Error())
+	}
+
+	// Die übertragenen Daten der geschlossenen Verbindungen werden abgerufen, sie werden den offenen Verbindungen hinzugerechnet
+	obj._metrics._lock.Lock()
+	closed_bytes := make(map[string]relay_byte_totals, len(obj._metrics._closed_relay_bytes))
+	for relay, totals := range obj._metrics._closed_relay_bytes {
+		closed_bytes[relay] = *totals
+	}
+	obj._metrics._lock.Unlock()
+
+	for _, meta_data := range relays_meta_data {
+		// Die Werte des Relays werden hinzugefügt
+		relay_labels := []string{"relay", meta_data.PublicKey}
+		closed := closed_bytes[meta_data.PublicKey]
+		delete(closed_bytes, meta_data.PublicKey)
+		relay_tx = append(relay_tx, metric_sample{relay_labels, closed.tx + meta_data.TotalWrited})
+		relay_rx = append(relay_rx, metric_sample{relay_labels, closed.rx + meta_data.TotalReaded})
+		relay_ping = append(relay_ping, metric_sample{relay_labels, meta_data.PingMS})
+
+		// Die Werte der einzelnen Verbindungen werden hinzugefügt
+		for _, conn := range meta_data.Connections {
+			conn_labels := []string{
+				"relay", meta_data.PublicKey,
+				"connection", conn.Id,
+				"protocol", conn.Protocol,
+				"direction", io_type_label(ConnectionIoType(conn.InboundOutbound)),
+			}
+			conn_tx = append(conn_tx, metric_sample{conn_labels, conn.TxBytes})
+			conn_rx = append(conn_rx, metric_sample{conn_labels, conn.RxBytes})
+			conn_ping = append(conn_ping, metric_sample{conn_labels, conn.Ping})
+			conn_buf_packages = append(conn_buf_packages, metric_sample{conn_labels, conn.BufferPackages})
+			conn_buf_bytes = append(conn_buf_bytes, metric_sample{conn_labels, conn.BufferBytes})
+		}
+	}
+
+	// Relays ohne offene Verbindung werden mit den Daten ihrer geschlossenen Verbindungen hinzugefügt
+	disconnected := make([]string, 0, len(closed_bytes))
+	for relay := range closed_bytes {
+		disconnected = append(disconnected, relay)
+	}
+	sort.Strings(disconnected)
+	for _, relay := range disconnected {
+		relay_labels := []string{"relay", relay}
+		relay_tx = append(relay_tx, metric_sample{relay_labels, closed_bytes[relay].tx})
+		relay_rx = append(relay_rx, metric_sample{relay_labels, closed_bytes[relay].rx})
+	}
+
+	// Die Relay und Verbindungs Metriken werden geschrieben
+	write_metric(buf, "rouex_relay_tx_bytes", "Total bytes sent to a relay over all connections, including closed ones.", "counter", relay_tx)
+	write_metric(buf, "rouex_relay_rx_bytes", "Total bytes received from a relay over all connections, including closed ones.", "counter", relay_rx)
+	write_metric(buf, "rouex_relay_ping_ms", "Average ping time of all connections to a relay in milliseconds.", "gauge", relay_ping)
+	write_metric(buf, "rouex_connection_tx_bytes", "Total bytes sent over a relay connection.", "counter", conn_tx)
+	write_metric(buf, "rouex_connection_rx_bytes", "Total bytes received over a relay connection.", "counter", conn_rx)
+	write_metric(buf, "rouex_connection_ping_ms", "Last ping time of a relay connection in milliseconds.", "gauge", conn_ping)
+	write_metric(buf, "rouex_connection_write_buffer_packages", "Packages waiting in the write buffer of a relay connection.", "gauge", conn_buf_packages)
+	write_metric(buf, "rouex_connection_write_buffer_bytes", "Bytes waiting in the write buffer of a relay connection.", "gauge", conn_buf_bytes)
+
+	// Die Belegung des Kernel Puffers wird geschrieben
+	write_metric(buf, "rouex_kernel_buffer_packages", "Packages waiting in the kernel package buffer.", "gauge", []metric_sample{{nil, uint64(len(obj._memory.buffer))}})
+	write_metric(buf, "rouex_kernel_buffer_capacity", "Capacity of the kernel package buffer.", "gauge", []metric_sample{{nil, uint64(static.LIMITS.KernelBufferMaxPackages)}})
+
+	// Die Zähler der verworfenen Pakete und der fehlgeschlagenen Handshakes werden abgerufen
+	obj._metrics._lock.Lock()
+	dropped := make([]metric_sample, 0, len(obj._metrics._dropped_packages))
+	for reason, value := range obj._metrics._dropped_packages {
+		dropped = append(dropped, metric_sample{[]string{"reason", reason}, value})
+	}
+	handshakes := make([]metric_sample, 0, len(obj._metrics._handshake_failures))
+	for key, value := range obj._metrics._handshake_failures {
+		handshakes = append(handshakes, metric_sample{[]string{"direction", io_type_label(key.io_type), "protocol", key.protocol}, value})
+	}
+	protocol_counts := make(map[uint8]uint64, len(obj._metrics._protocol_packages))
+	for tpe, value := range obj._metrics._protocol_packages {
+		protocol_counts[tpe] = value
+	}
+	obj._metrics._lock.Unlock()
+
+	// Die Protokollnamen werden außerhalb des Metriken Locks abgerufen
+	protocols := make([]metric_sample, 0, len(protocol_counts))
+	for tpe, value := range protocol_counts {
+		protocols = append(protocols, metric_sample{[]string{"type", fmt.Sprintf("%d", tpe), "name", obj._metric_protocol_name(tpe)}, value})
+	}
+
+	// Die Zähler werden sortiert, so bleibt die Ausgabe stabil
+	for _, samples := range [][]metric_sample{dropped, handshakes, protocols} {
+		sort.Slice(samples, func(i, j int) bool {
+			return strings.Join(samples[i].labels, "\x00") < strings.Join(samples[j].labels, "\x00")
+		})
+	}
+	write_metric(buf, "rouex_dropped_packages_total", "Packages dropped by the kernel by reason.", "counter", dropped)
+	write_metric(buf, "rouex_handshake_failures_total", "Failed relay connection handshakes.", "counter", handshakes)
+	write_metric(buf, "rouex_protocol_packages_total", "Packages delivered locally per protocol.", "counter", protocols)
+
+	// Die Zähler der Paketprüfung werden geschrieben
+	obj._lock.Lock()
+	invalid_signatures, duplicate_packages, expired_packages, outdated_packages := obj._invalid_signatures, obj._duplicate_packages, obj._expired_packages, obj._outdated_packages
+	obj._lock.Unlock()
+	write_metric(buf, "rouex_invalid_signatures_total", "Packages dropped because of an invalid signature.", "counter", []metric_sample{{nil, invalid_signatures}})
+	write_metric(buf, "rouex_duplicate_packages_total", "Packages dropped because they were already received.", "counter", []metric_sample{{nil, duplicate_packages}})
+	write_metric(buf, "rouex_expired_packages_total", "Packages dropped because their hop limit was exceeded.", "counter", []metric_sample{{nil, expired_packages}})
+	write_metric(buf, "rouex_outdated_packages_total", "Packages dropped because their timestamp is outside of the replay window.", "counter", []metric_sample{{nil, outdated_packages}})
+
+	// Die Metriken werden geschrieben
+	if _, err := w.Write(buf.Bytes()); err != nil {
+		return fmt.Errorf("WriteMetrics: 2: " + err.Error())
+	}
+
+	// Der Vorgang wurde ohne Fehler durchgeführt
+	return nil
+}
@@ -3,78 +3,19 @@
 package kernel
 
 import (
-	"log"
+	"fmt"
 	"time"
 )
 
-// Gibt an ob der Kernel erfolgreich heruntergefahren wurde
-func (obj *Kernel) _is_base_closed() bool {
-	// Es wird geprüft ob der Kernel noch ausgeführt wird
-	if obj.IsRunning() {
-		return false
-	}
-
-	// Der Threadlock wird verwendet
-	obj._lock.Lock()
-	defer obj._lock.Unlock()
-
-	// Gibt an wieviele API Interfaces ausgeführt wird
-	total_interface := 0
-
-	// Der Status der Interfaces wird ermittelt
-	for i := range obj._api_interfaces {
-		if obj._api_interfaces[i]._irn() {
-			total_interface++
-		}
-	}
-
-	// Gibt an wieviele Server Module ausgeführt wird
-	total_server_modules := 0
-
-	// Die Server Module werden gestartet
-	for i := range obj._server_modules {
-		if obj._server_modules[i].IsRunning() {
-			total_server_modules++
-		}
-	}
+// Gibt an, wie lange im Drain Modus maximal auf das Leeren der Puffer gewartet wird
+const DEFAULT_DRAIN_TIMEOUT = 10 * time.Second
 
-	// Es wird geprüft ob alle Interfaces geschlossen wurden
-	if total_interface != 0 || total_server_modules != 0 {
-		return false
-	}
-
-	// Die Daten werden zurückgegeben
-	return true
-}
-
-// Gibt an ob der Kernel erfolgreich heruntergefahren wurde
-func (obj *Kernel) _is_full_closed() bool {
-	// Es wird geprüft ob die basis beendet wurde
-	if !obj._is_base_closed() {
-		return false
-	}
-
-	// Der Threadlock wird verwendet
-	obj._lock.Lock()
-	defer obj._lock.Unlock()
-
-	// Es wird geprüft ob der Shutdown vorgang erfolgreich druchgeführt wurde
-	if !obj._shutdown_complete {
-		return false
-	}
-
-	// Die Daten werden zurückgegeben
-	return true
-}
+// Gibt an, in welchem Abstand im Drain Modus geprüft wird ob alle Puffer geleert wurden
+const DRAIN_CHECK_INTERVAL = 50 * time.Millisecond
 
 // Signalisiert das der Server vollständig heruntergefahren wurde
 func (obj *Kernel) _signal_shutdown_complete() {
-	// Wird verwendet um den Threadlock zu Sperren
-	obj._lock.Lock()
-	defer obj._lock.Unlock()
-
-	// Es wird Signalisiert dass der Shutdown vollständig war
-	obj._shutdown_complete = true
+	close(obj._closed)
 }
 
 // Wird ausgeführt wenn das Programm als Dienst ausgeführt wird
@@ -103,6 +44,7 @@ func (obj *Kernel) Start() error {
 
 	// Signalisiert das der Kernel ausgeführt wird
 	obj._is_running = true
+	close(obj._started)
 
 	// Der Threadlock wird freigegeben
 	obj._lock.Unlock()
@@ -111,88 +53,206 @@ func (obj *Kernel) Start() error {
 	return nil
 }
 
-// Wird ausgeführt um den Kernel zu beenden
-func (obj *Kernel) Shutdown() {
-	// Der Threadlock wird verwendet
+// Legt fest wie lange im Drain Modus maximal auf das Leeren der Puffer gewartet wird
+func (obj *Kernel) SetDrainTimeout(timeout time.Duration) {
+	obj._lock.Lock()
+	obj._drain_timeout = timeout
+	obj._lock.Unlock()
+}
+
+// Gibt an wie lange im Drain Modus maximal auf das Leeren der Puffer gewartet wird
+func (obj *Kernel) GetDrainTimeout() time.Duration {
+	obj._lock.Lock()
+	defer obj._lock.Unlock()
+	return obj._drain_timeout
+}
+
+// Legt die Funktion fest, welche beim Neuladen (SIGHUP) die Einstellungen neu einließt
+func (obj *Kernel) SetReloadHandler(handler func() error) {
+	obj._lock.Lock()
+	obj._reload_handler = handler
+	obj._lock.Unlock()
+}
+
+// Gibt an ob sich der Kernel im Drain Modus befindet
+func (obj *Kernel) IsDraining() bool {
 	obj._lock.Lock()
+	defer obj._lock.Unlock()
+	return obj._draining
+}
 
-	// Gibt an ob der Server ausgeführt wurde
-	server_was_runed := false
+// Ließt die Einstellungen sowie die Vertrauenswürdigen Relays neu ein
+func (obj *Kernel) Reload() error {
+	// Die Funktion zum Neuladen der Einstellungen wird abgerufen
+	obj._lock.Lock()
+	handler := obj._reload_handler
+	obj._lock.Unlock()
 
-	// Es wird geprüft ob der Kernelausgeführt wird
-	if obj._is_running {
-		// Log
-		log.Printf("Kernel: closing server modules. id = %s\n", obj.GetKernelID())
+	// Log
+	obj._log.Info("Kernel: reloading", "id", obj.GetKernelID())
 
-		// Die Internen Dienste werden beendet
-		var vat ServerModule
-		for _, item := range obj._server_modules {
-			// Es wird geprüft ob der Eintrag nicht null
-			if item == nil {
-				continue
-			}
+	// Die Einstellungen werden neu eingelesen
+	if handler != nil {
+		if err := handler(); err != nil {
+			return fmt.Errorf("Reload: 1: " + err.Error())
+		}
+	}
+
+	// Die Vertrauenswürdigen Relays werden neu geladen
+	if err := obj.ReloadTrustedRelays(); err != nil {
+		return fmt.Errorf("Reload: 2: " + err.Error())
+	}
+
+	// Der Vorgang wurde ohne Fehler durchgeführt
+	return nil
+}
 
-			// Das Element wird zwischengespeichert
-			vat = item
+// Gibt an ob noch Pakete im Kernel Puffer oder in den Schreibpuffern der Verbindungen vorhanden sind
+func (obj *Kernel) _has_pending_packages() bool {
+	return obj._memory.HasPendingPackages() || obj._connection_manager.HasPendingWrites()
+}
 
-			// Das Modul wird beendet
-			vat.Shutdown()
+// Beendet die Server Module, jedes Modul wird nur einmal beendet, auch wenn das Herunterfahren während des Drain Modus
+// ausgelöst wird, ist 'relay_listeners_only' gesetzt werden nur die Module beendet welche Relay Verbindungen annehmen
+func (obj *Kernel) _shutdown_server_modules(relay_listeners_only bool) {
+	// Es werden alle Module ermittelt, welche noch nicht beendet wurden
+	obj._lock.Lock()
+	server_modules := make([]ServerModule, 0, len(obj._server_modules))
+	for _, item := range obj._server_modules {
+		if item == nil || obj._stopped_modules[item] || (relay_listeners_only && !item.IsIpBasedServer()) {
+			continue
 		}
+		obj._stopped_modules[item] = true
+		server_modules = append(server_modules, item)
+	}
+	obj._lock.Unlock()
 
-		// Log
-		log.Printf("Kernel: server modules closed. id = %s\n", obj.GetKernelID())
+	// Die Module werden ohne Threadlock beendet da sie beim Schließen auf den Kernel zugreifen
+	for _, item := range server_modules {
+		item.Shutdown()
+	}
+}
 
-		// Der Threadlock wird entsperrt
+// Versetzt den Kernel in den Drain Modus, es werden keine neuen Verbindungen mehr angenommen, die Nachbarn werden
+// über das Verlassen informiert und es wird maximal 'timeout' lang gewartet bis alle Puffer geleert wurden,
+// anschließend wird der Kernel beendet
+func (obj *Kernel) Drain(timeout time.Duration) {
+	// Es wird geprüft ob der Kernel ausgeführt wird und nicht bereits beendet wird
+	obj._lock.Lock()
+	if !obj._is_running || obj._stopping || obj._draining {
 		obj._lock.Unlock()
+		return
+	}
+	obj._draining = true
 
-		// Es werden alle Verbindungen geschlossen
-		obj._connection_manager.ShutdownByKernel()
+	// Es werden keine neuen ausgehenden Verbindungen mehr aufgebaut
+	obj._cancel_outbound_connections()
 
-		// Der Threadlock wird verwendet
-		obj._lock.Lock()
+	// Die Protokolle welche über den Drain Modus informiert werden wollen, werden abgerufen
+	observers := make([]KernelDrainObserver, 0)
+	for _, item := range obj._protocols {
+		if observer, ok := item.Ptf.(KernelDrainObserver); ok {
+			observers = append(observers, observer)
+		}
+	}
+	obj._lock.Unlock()
+
+	// Log
+	obj._log.Info("Kernel: draining", "id", obj.GetKernelID(), "timeout", timeout)
+
+	// Die Relay Listener werden beendet, so werden keine neuen eingehenden Verbindungen mehr angenommen,
+	// die übrigen Server Module (z.b. Metriken, SOCKS5) bleiben bis zum Herunterfahren erreichbar
+	obj._shutdown_server_modules(true)
 
-		// Log
-		log.Printf("Kernel: closing api interfaces. id = %s\n", obj.GetKernelID())
+	// Die Protokolle werden informiert, diese können den Nachbarn das Verlassen mitteilen
+	for _, item := range observers {
+		item.KernelDraining()
+	}
 
-		// Die API Schnitstellen werden geschlossen
-		for i := range obj._api_interfaces {
-			// Das Interface wird beendet
-			obj._api_interfaces[i]._close_by_kernel()
+	// Es wird gewartet bis alle Puffer geleert wurden oder die Frist abgelaufen ist
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+	check := time.NewTicker(DRAIN_CHECK_INTERVAL)
+	defer check.Stop()
+	for drained := false; !drained && obj._has_pending_packages(); {
+		select {
+		case <-obj._ctx.Done():
+			return
+		case <-deadline.C:
+			obj._log.Info("Kernel: drain timeout reached, pending packages are dropped", "id", obj.GetKernelID())
+			drained = true
+		case <-check.C:
 		}
+	}
 
-		// Log
-		log.Printf("Kernel: api interfaces closed. id = %s\n", obj.GetKernelID())
+	// Log
+	obj._log.Info("Kernel: drained", "id", obj.GetKernelID())
 
-		// Es wird Signalisiert dass der Kernel nicht mehr läuft
-		obj._is_running = false
+	// Der Kernel wird beendet
+	obj.Shutdown()
+}
 
-		// Gibt an das der Server ausgeführt wird
-		server_was_runed = true
+// Wird ausgeführt um den Kernel zu beenden, es wird gewartet bis alle Dienste und Threads des Kernels beendet wurden
+func (obj *Kernel) Shutdown() {
+	// Es wird geprüft ob der Kernel ausgeführt wird und nicht bereits beendet wird
+	obj._lock.Lock()
+	if !obj._is_running || obj._stopping {
+		obj._lock.Unlock()
+		return
 	}
+	obj._stopping = true
 
-	// Der Threadlock wird entsperrt
+	// Die Verwaltung der ausgehenden Verbindungen wird beendet
+	obj._cancel_outbound_connections()
+
+	// Die API Schnittstellen werden abgerufen, sie werden ohne Threadlock beendet da sie beim Schließen auf den Kernel zugreifen
+	api_interfaces := make([]*KernelAPI, len(obj._api_interfaces))
+	copy(api_interfaces, obj._api_interfaces)
 	obj._lock.Unlock()
 
-	// Sollte der Server ausgeführt werden wird gewartet ob alle Dienste beendet wurden
-	if server_was_runed {
-		// Log
-		log.Printf("Kernel: wait of closing. id = %s\n", obj.GetKernelID())
+	// Log
+	obj._log.Info("Kernel: closing server modules", "id", obj.GetKernelID())
 
-		// Es wird gewartet bis alles geschlossen wurde
-		for range time.Tick(1 * time.Millisecond) {
-			if obj._is_base_closed() {
-				break
-			}
-		}
+	// Die Internen Dienste werden beendet, bereits im Drain Modus beendete Module werden übersprungen
+	obj._shutdown_server_modules(false)
 
-		// Log
-		log.Printf("Kernel: finally closed. id = %s\n", obj.GetKernelID())
+	// Log
+	obj._log.Info("Kernel: server modules closed", "id", obj.GetKernelID())
 
-		// Die Datenbanken werden geschlossen
-		obj._routing_table.Shutdown()
-		obj._trusted_relays.Shutdown()
+	// Es werden alle Verbindungen geschlossen
+	obj._connection_manager.ShutdownByKernel()
 
-		// Es wird Signalisiert dass der Kernel vollständig beendet wurde
-		obj._signal_shutdown_complete()
+	// Log
+	obj._log.Info("Kernel: closing api interfaces", "id", obj.GetKernelID())
+
+	// Die API Schnitstellen werden geschlossen
+	for _, item := range api_interfaces {
+		item._close_by_kernel()
 	}
+
+	// Log
+	obj._log.Info("Kernel: api interfaces closed", "id", obj.GetKernelID())
+
+	// Es wird Signalisiert dass der Kernel nicht mehr läuft, alle Threads des Kernels werden abgebrochen
+	obj._lock.Lock()
+	obj._is_running = false
+	obj._lock.Unlock()
+	obj._cancel()
+
+	// Log
+	obj._log.Info("Kernel: wait of closing", "id", obj.GetKernelID())
+
+	// Es wird gewartet bis alle Threads des Kernels beendet wurden
+	obj._threads.Wait()
+
+	// Log
+	obj._log.Info("Kernel: finally closed", "id", obj.GetKernelID())
+
+	// Die Datenbanken werden geschlossen
+	obj._routing_table.Shutdown()
+	obj._trusted_relays.Shutdown()
+	obj._firewall.Shutdown()
+
+	// Es wird Signalisiert dass der Kernel vollständig beendet wurde
+	obj._signal_shutdown_complete()
 }
@@ -0,0 +1,157 @@
+package kernel
+
+import (
+	"fmt"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+)
+
+// Gibt ein Vertrauenswürdiges Relay anhand seines Öffentlichen Schlüssels zurück, auch deaktivierte Relays werden berücksichtigt
+func (obj *Kernel) _get_trusted_relay(pkey *btcec.PublicKey) (*Relay, error) {
+	relay := obj._trusted_relays.GetRelayByPublicKey(pkey)
+	if relay == nil {
+		return nil, fmt.Errorf("unkown trusted relay")
+	}
+	return relay, nil
+}
+
+// Fügt ein neues Vertrauenswürdiges Relay hinzu, sofern ein Endpunkt angegeben wurde, wird eine Verbindung aufgebaut
+func (obj *Kernel) AddTrustedRelay(pkey *btcec.PublicKey, end_point string, protocol string) error {
+	// Es wird geprüft ob es sich um den eigenen Schlüssel handelt
+	if obj.IsLocallyAddress(*pkey) {
+		return fmt.Errorf("AddTrustedRelay: 1: can't add own relay key")
+	}
+
+	// Sollte das Relay bereits als nicht Vertrauenswürdiges Relay verbunden sein, wird es übernommen
+	if connected := obj._connection_manager.GetRelayByPublicKey(pkey); connected != nil && !connected.IsTrusted() {
+		if err := obj.PromoteRelay(connected, end_point, protocol); err != nil {
+			return fmt.Errorf("AddTrustedRelay: 2: " + err.Error())
+		}
+		return nil
+	}
+
+	// Das Relay wird hinzugefügt
+	relay, err := obj._trusted_relays.AddRelay(pkey, end_point, protocol)
+	if err != nil {
+		return fmt.Errorf("AddTrustedRelay: 3: " + err.Error())
+	}
+
+	// Log
+	obj._log.Info("Kernel: trusted relay added", "relay", relay.GetPublicKeyHexString(), "protocol", protocol, "end_point", end_point)
+
+	// Die ausgehende Verbindung wird gestartet
+	obj._reconcile_outbound_connections()
+
+	// Der Vorgang wurde ohne Fehler durchgeführt
+	return nil
+}
+
+// Gibt ein verbundenes Relay anhand seines Öffentlichen Schlüssels oder der ID einer seiner Verbindungen zurück
+func (obj *Kernel) GetConnectedRelayByKeyOrConnectionId(pkey *btcec.PublicKey, conn_id string) *Relay {
+	if pkey != nil {
+		return obj._connection_manager.GetRelayByPublicKey(pkey)
+	}
+	return obj._connection_manager.GetRelayByConnectionId(conn_id)
+}
+
+// Markiert ein verbundenes nicht Vertrauenswürdiges Relay als Vertrauenswürdig und speichert es in der Datenbank,
+// ohne Angabe werden der Endpunkt und das Protokoll der bestehenden Verbindung übernommen, die Verbindungen bleiben bestehen
+func (obj *Kernel) PromoteRelay(relay *Relay, end_point string, protocol string) error {
+	// Es wird geprüft ob dem Relay bereits vertraut wird
+	if relay.IsTrusted() {
+		return fmt.Errorf("PromoteRelay: 1: relay is already trusted")
+	}
+
+	// Die nicht angegebenen Werte werden von der Verbindung übernommen
+	if len(end_point) == 0 {
+		end_point = relay.GetEndpoint()
+	}
+	if len(protocol) == 0 {
+		protocol = relay.GetProtocol()
+	}
+
+	// Das Relay wird in der Datenbank gespeichert und als Vertrauenswürdig markiert
+	if err := obj._trusted_relays.AdoptRelay(relay, end_point, protocol); err != nil {
+		return fmt.Errorf("PromoteRelay: 2: " + err.Error())
+	}
+
+	// Der Eintrag der Verbindungen wird aktualisiert
+	obj._connection_manager.RefreshRelayLink(relay)
+
+	// Log
+	obj._log.Info("Kernel: relay promoted to trusted", "relay", relay.GetPublicKeyHexString(), "protocol", protocol, "end_point", end_point)
+
+	// Die Verwaltung der ausgehenden Verbindung wird gestartet, solange die Verbindung besteht wird keine weitere aufgebaut
+	obj._reconcile_outbound_connections()
+
+	// Der Vorgang wurde ohne Fehler durchgeführt
+	return nil
+}
+
+// Entfernt ein Vertrauenswürdiges Relay, alle Verbindungen mit dem Relay werden geschlossen
+func (obj *Kernel) RemoveTrustedRelay(pkey *btcec.PublicKey) error {
+	// Das Relay wird abgerufen
+	relay, err := obj._get_trusted_relay(pkey)
+	if err != nil {
+		return fmt.Errorf("RemoveTrustedRelay: 1: " + err.Error())
+	}
+
+	// Das Relay wird entfernt
+	if err := obj._trusted_relays.RemoveRelay(relay); err != nil {
+		return fmt.Errorf("RemoveTrustedRelay: 2: " + err.Error())
+	}
+
+	// Die ausgehende Verbindung wird beendet und alle Verbindungen werden geschlossen
+	obj._reconcile_outbound_connections()
+	obj._connection_manager.CloseRelayConnections(relay)
+
+	// Log
+	obj._log.Info("Kernel: trusted relay removed", "relay", relay.GetPublicKeyHexString())
+	return nil
+}
+
+// Aktiviert oder Deaktiviert ein Vertrauenswürdiges Relay, bei einem Deaktivierten Relay werden alle Verbindungen geschlossen
+func (obj *Kernel) SetTrustedRelayActive(pkey *btcec.PublicKey, active bool) error {
+	// Das Relay wird abgerufen
+	relay, err := obj._get_trusted_relay(pkey)
+	if err != nil {
+		return fmt.Errorf("SetTrustedRelayActive: 1: " + err.Error())
+	}
+
+	// Der Status wird aktualisiert
+	if err := obj._trusted_relays.SetRelayActive(relay, active); err != nil {
+		return fmt.Errorf("SetTrustedRelayActive: 2: " + err.Error())
+	}
+
+	// Die Verbindung wird aufgebaut bzw. geschlossen
+	obj._reconcile_outbound_connections()
+	if !active {
+		obj._connection_manager.CloseRelayConnections(relay)
+	}
+
+	// Log
+	obj._log.Info("Kernel: trusted relay state changed", "relay", relay.GetPublicKeyHexString(), "active", active)
+	return nil
+}
+
+// Aktualisiert den Endpunkt und das Protokoll eines Vertrauenswürdigen Relays, bestehende Verbindungen werden neu aufgebaut
+func (obj *Kernel) UpdateTrustedRelay(pkey *btcec.PublicKey, end_point string, protocol string) error {
+	// Das Relay wird abgerufen
+	relay, err := obj._get_trusted_relay(pkey)
+	if err != nil {
+		return fmt.Errorf("UpdateTrustedRelay: 1: " + err.Error())
+	}
+
+	// Der Endpunkt und das Protokoll werden aktualisiert
+	if err := obj._trusted_relays.UpdateRelayEndpoint(relay, end_point, protocol); err != nil {
+		return fmt.Errorf("UpdateTrustedRelay: 2: " + err.Error())
+	}
+
+	// Die bestehenden Verbindungen werden geschlossen, damit sie mit den neuen Werten aufgebaut werden
+	obj._connection_manager.CloseRelayConnections(relay)
+	obj._reconcile_outbound_connections(relay)
+
+	// Log
+	obj._log.Info("Kernel: trusted relay updated", "relay", relay.GetPublicKeyHexString(), "protocol", relay.GetProtocol(), "end_point", relay.GetEndpoint())
+	return nil
+}
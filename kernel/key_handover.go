@@ -0,0 +1,227 @@
+package kernel
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/fluffelpuff/RoueX/keystore"
+	"github.com/fluffelpuff/RoueX/utils"
+	"github.com/fxamacker/cbor"
+)
+
+// Stellt die Übergabe eines Relay Schlüssels an einen neuen Schlüssel dar, diese wird mit dem alten Schlüssel Signiert
+type KeyHandover struct {
+	OldKey    []byte `cbor:"1,keyasint"`
+	NewKey    []byte `cbor:"2,keyasint"`
+	Timestamp int64  `cbor:"3,keyasint"`
+	Sig       []byte `cbor:"4,keyasint"`
+}
+
+// Gibt den Hash zurück, welcher mit dem alten Schlüssel Signiert wird
+func (obj *KeyHandover) GetSignHash() []byte {
+	timestamp := make([]byte, 8)
+	binary.BigEndian.PutUint64(timestamp, uint64(obj.Timestamp))
+	return utils.ComputeSha3256Hash([]byte("key_handover"), obj.OldKey, obj.NewKey, timestamp)
+}
+
+// Prüft ob die Übergabe mit dem alten Schlüssel Signiert wurde
+func (obj *KeyHandover) ValidateSignature() bool {
+	old_key, err := btcec.ParsePubKey(obj.OldKey)
+	if err != nil {
+		return false
+	}
+	if _, err := btcec.ParsePubKey(obj.NewKey); err != nil {
+		return false
+	}
+	is_valid, err := utils.VerifyByBytes(old_key, obj.Sig, obj.GetSignHash())
+	if err != nil {
+		return false
+	}
+	return is_valid
+}
+
+// Wandelt eine Übergabekette in Bytes um
+func EncodeKeyHandoverChain(chain []KeyHandover) ([]byte, error) {
+	encoded, err := cbor.Marshal(chain, cbor.EncOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("EncodeKeyHandoverChain: " + err.Error())
+	}
+	return encoded, nil
+}
+
+// Liest eine Übergabekette ein und prüft ob alle Glieder korrekt miteinander verkettet und Signiert sind
+func DecodeKeyHandoverChain(data []byte) ([]KeyHandover, error) {
+	// Die Kette wird eingelesen
+	var chain []KeyHandover
+	if err := cbor.Unmarshal(data, &chain); err != nil {
+		return nil, fmt.Errorf("DecodeKeyHandoverChain: 1: " + err.Error())
+	}
+	if len(chain) == 0 {
+		return nil, fmt.Errorf("DecodeKeyHandoverChain: 2: empty handover chain")
+	}
+
+	// Die einzelnen Glieder werden geprüft
+	for i := range chain {
+		if !chain[i].ValidateSignature() {
+			return nil, fmt.Errorf("DecodeKeyHandoverChain: 3: invalid handover signature")
+		}
+		if i == 0 {
+			continue
+		}
+		if !bytes.Equal(chain[i-1].NewKey, chain[i].OldKey) {
+			return nil, fmt.Errorf("DecodeKeyHandoverChain: 4: broken handover chain")
+		}
+		if chain[i].Timestamp <= chain[i-1].Timestamp {
+			return nil, fmt.Errorf("DecodeKeyHandoverChain: 5: invalid handover order")
+		}
+	}
+
+	// Die Kette wird zurückgegeben
+	return chain, nil
+}
+
+// Lädt die Übergabekette des eigenen Relay Schlüssels aus dem Keystore
+func (obj *Kernel) _load_key_handover_chain() error {
+	// Die Kette wird abgerufen, sollte keine vorhanden sein, wird der Vorgang beendet
+	data, err := keystore.LoadKeyHandoverChain()
+	if err != nil {
+		return fmt.Errorf("_load_key_handover_chain: 1: " + err.Error())
+	}
+	if data == nil {
+		return nil
+	}
+
+	// Die Kette wird eingelesen
+	chain, err := DecodeKeyHandoverChain(data)
+	if err != nil {
+		return fmt.Errorf("_load_key_handover_chain: 2: " + err.Error())
+	}
+
+	// Die Kette muss beim aktuellen Schlüssel enden
+	if !bytes.Equal(chain[len(chain)-1].NewKey, obj.GetPublicKey().SerializeCompressed()) {
+		obj._log.Info("Kernel: stored key handover chain does not end with the current relay key, ignored")
+		return nil
+	}
+
+	// Die Kette wird zwischengespeichert
+	obj._lock.Lock()
+	obj._key_handovers = chain
+	obj._lock.Unlock()
+
+	// Log
+	obj._log.Info("Kernel: key handover chain loaded", "total", len(chain))
+	return nil
+}
+
+// Gibt eine Kopie der Übergabekette des eigenen Relay Schlüssels zurück
+func (obj *Kernel) GetKeyHandoverChain() []KeyHandover {
+	obj._lock.Lock()
+	result := make([]KeyHandover, len(obj._key_handovers))
+	copy(result, obj._key_handovers)
+	obj._lock.Unlock()
+	return result
+}
+
+// Gibt an ob eine Schlüsselrotation durchgeführt wurde, welche erst nach einem Neustart aktiv wird
+func (obj *Kernel) HasPendingKeyRotation() bool {
+	obj._lock.Lock()
+	defer obj._lock.Unlock()
+	if len(obj._key_handovers) == 0 {
+		return false
+	}
+	return !bytes.Equal(obj._key_handovers[len(obj._key_handovers)-1].NewKey, obj._private_key.PubKey().SerializeCompressed())
+}
+
+// Erzeugt einen neuen Relay Schlüssel, dieser wird im Keystore gespeichert und ist nach einem Neustart aktiv,
+// die Übergabe wird mit dem aktuellen Schlüssel Signiert
+func (obj *Kernel) RotateRelayKey() (*btcec.PublicKey, error) {
+	// Es darf nur eine Rotation pro Laufzeit durchgeführt werden
+	if obj.HasPendingKeyRotation() {
+		return nil, fmt.Errorf("RotateRelayKey: key rotation already pending, restart the relay to activate the new key")
+	}
+
+	// Der neue Schlüssel wird erzeugt
+	new_key, err := utils.GeneratePrivateKey()
+	if err != nil {
+		return nil, fmt.Errorf("RotateRelayKey: 1: " + err.Error())
+	}
+
+	// Die Übergabe wird erstellt und mit dem aktuellen Schlüssel Signiert
+	handover := KeyHandover{
+		OldKey:    obj.GetPublicKey().SerializeCompressed(),
+		NewKey:    new_key.PubKey().SerializeCompressed(),
+		Timestamp: time.Now().Unix(),
+	}
+	handover.Sig, err = obj.SignWithRelayKey(handover.GetSignHash())
+	if err != nil {
+		return nil, fmt.Errorf("RotateRelayKey: 2: " + err.Error())
+	}
+
+	// Die Übergabe wird an die bestehende Kette angehängt
+	chain := append(obj.GetKeyHandoverChain(), handover)
+	encoded, err := EncodeKeyHandoverChain(chain)
+	if err != nil {
+		return nil, fmt.Errorf("RotateRelayKey: 3: " + err.Error())
+	}
+
+	// Der neue Schlüssel und die Kette werden im Keystore gespeichert
+	if err := keystore.StoreRotatedPrivateKey(new_key, encoded); err != nil {
+		return nil, fmt.Errorf("RotateRelayKey: 4: " + err.Error())
+	}
+
+	// Die Kette wird zwischengespeichert
+	obj._lock.Lock()
+	obj._key_handovers = chain
+	obj._lock.Unlock()
+
+	// Log
+	obj._log.Info("Kernel: relay key rotated, restart the relay to activate the new key", "new_key", hex.EncodeToString(handover.NewKey))
+	return new_key.PubKey(), nil
+}
+
+// Aktualisiert den gepinnten Schlüssel eines Vertrauenswürdigen Relays anhand einer gültigen Übergabekette
+func (obj *Kernel) ApplyKeyHandoverChain(chain []KeyHandover) (bool, error) {
+	// Der finale Schlüssel der Kette wird eingelesen
+	final_key, err := btcec.ParsePubKey(chain[len(chain)-1].NewKey)
+	if err != nil {
+		return false, fmt.Errorf("ApplyKeyHandoverChain: 1: " + err.Error())
+	}
+
+	// Die Kette wird nach einem gepinnten Schlüssel durchsucht
+	for i := range chain {
+		old_key, err := btcec.ParsePubKey(chain[i].OldKey)
+		if err != nil {
+			return false, fmt.Errorf("ApplyKeyHandoverChain: 2: " + err.Error())
+		}
+		relay := obj._trusted_relays.GetRelayByPublicKey(old_key)
+		if relay == nil {
+			continue
+		}
+
+		// Der gepinnte Schlüssel wird aktualisiert, ist das Relay bereits mit dem neuen Schlüssel verbunden, wird es übernommen
+		updated, err := obj._trusted_relays.UpdatePinnedKey(relay, final_key, obj._connection_manager.GetRelayByPublicKey(final_key))
+		if err != nil {
+			return false, fmt.Errorf("ApplyKeyHandoverChain: 3: " + err.Error())
+		}
+		obj._connection_manager.RefreshRelayLink(updated)
+
+		// Die ausgehende Verbindung wird für das aktualisierte Relay gestartet, die bestehenden Verbindungen welche
+		// mit dem vorherigen Schlüssel aufgebaut wurden, bleiben erhalten bis sie geschlossen werden
+		for _, stopped := range obj._reconcile_outbound_workers() {
+			if stopped != relay {
+				obj._connection_manager.CloseRelayConnections(stopped)
+			}
+		}
+
+		// Log
+		obj._log.Info("Kernel: pinned key of trusted relay updated", "old_key", hex.EncodeToString(chain[i].OldKey), "new_key", hex.EncodeToString(chain[len(chain)-1].NewKey))
+		return true, nil
+	}
+
+	// Es wurde kein passendes Relay gefunden
+	return false, nil
+}
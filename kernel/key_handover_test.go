@@ -0,0 +1,141 @@
+package kernel
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/fluffelpuff/RoueX/utils"
+)
+
+// Erzeugt eine Signierte Übergabekette über die angegebenen Schlüssel
+func newTestHandoverChain(t *testing.T, keys []*btcec.PrivateKey) []KeyHandover {
+	t.Helper()
+	chain := make([]KeyHandover, 0, len(keys)-1)
+	timestamp := time.Now().Unix()
+	for i := 1; i < len(keys); i++ {
+		handover := KeyHandover{
+			OldKey:    keys[i-1].PubKey().SerializeCompressed(),
+			NewKey:    keys[i].PubKey().SerializeCompressed(),
+			Timestamp: timestamp + int64(i),
+		}
+		sig, err := utils.Sign(keys[i-1], handover.GetSignHash())
+		if err != nil {
+			t.Fatal(err)
+		}
+		handover.Sig = sig
+		chain = append(chain, handover)
+	}
+	return chain
+}
+
+// Erzeugt die angegebene Anzahl an Privaten Schlüsseln
+func newTestPrivateKeys(t *testing.T, total int) []*btcec.PrivateKey {
+	t.Helper()
+	keys := make([]*btcec.PrivateKey, total)
+	for i := range keys {
+		key, err := utils.GeneratePrivateKey()
+		if err != nil {
+			t.Fatal(err)
+		}
+		keys[i] = key
+	}
+	return keys
+}
+
+// Kodiert die Kette und prüft ob sie mit dem erwarteten Fehlercode abgelehnt wird
+func expectHandoverChainError(t *testing.T, name string, chain []KeyHandover, code string) {
+	t.Helper()
+	encoded, err := EncodeKeyHandoverChain(chain)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = DecodeKeyHandoverChain(encoded)
+	if err == nil {
+		t.Errorf("%s: chain accepted", name)
+		return
+	}
+	if !strings.HasPrefix(err.Error(), "DecodeKeyHandoverChain: "+code+":") {
+		t.Errorf("%s: error = %q, want code %s", name, err, code)
+	}
+}
+
+func TestDecodeKeyHandoverChain(t *testing.T) {
+	keys := newTestPrivateKeys(t, 4)
+	chain := newTestHandoverChain(t, keys)
+
+	// Die Kette wird kodiert und wieder eingelesen
+	encoded, err := EncodeKeyHandoverChain(chain)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := DecodeKeyHandoverChain(encoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(decoded) != 3 {
+		t.Fatalf("len(chain) = %d, want 3", len(decoded))
+	}
+	final_key, err := btcec.ParsePubKey(decoded[len(decoded)-1].NewKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !final_key.IsEqual(keys[3].PubKey()) {
+		t.Error("chain does not end with the last key")
+	}
+}
+
+func TestDecodeKeyHandoverChainRejects(t *testing.T) {
+	keys := newTestPrivateKeys(t, 4)
+
+	// Eine leere Kette wird abgelehnt
+	expectHandoverChainError(t, "empty", []KeyHandover{}, "2")
+
+	// Eine veränderte Übergabe wird abgelehnt
+	chain := newTestHandoverChain(t, keys)
+	chain[1].NewKey = keys[0].PubKey().SerializeCompressed()
+	expectHandoverChainError(t, "changed key", chain, "3")
+
+	// Eine mit einem fremden Schlüssel Signierte Übergabe wird abgelehnt
+	chain = newTestHandoverChain(t, keys)
+	chain[2].Sig, _ = utils.Sign(keys[3], chain[2].GetSignHash())
+	expectHandoverChainError(t, "foreign signature", chain, "3")
+
+	// Eine Kette mit einem fehlenden Glied wird abgelehnt
+	chain = newTestHandoverChain(t, keys)
+	expectHandoverChainError(t, "broken link", []KeyHandover{chain[0], chain[2]}, "4")
+
+	// Eine Kette in falscher Reihenfolge wird abgelehnt
+	chain = newTestHandoverChain(t, keys)
+	chain[1].Timestamp = chain[0].Timestamp
+	chain[1].Sig, _ = utils.Sign(keys[1], chain[1].GetSignHash())
+	expectHandoverChainError(t, "equal timestamp", chain, "5")
+
+	// Ungültige Daten werden abgelehnt
+	if _, err := DecodeKeyHandoverChain([]byte{0xff}); err == nil {
+		t.Error("invalid bytes accepted")
+	}
+}
+
+func TestKeyHandoverValidateSignature(t *testing.T) {
+	keys := newTestPrivateKeys(t, 2)
+	handover := newTestHandoverChain(t, keys)[0]
+	if !handover.ValidateSignature() {
+		t.Fatal("valid handover rejected")
+	}
+
+	// Der Zeitstempel ist Signiert
+	changed := handover
+	changed.Timestamp++
+	if changed.ValidateSignature() {
+		t.Error("handover with changed timestamp accepted")
+	}
+
+	// Ungültige Schlüssel werden abgelehnt
+	changed = handover
+	changed.NewKey = []byte{0x02}
+	if changed.ValidateSignature() {
+		t.Error("handover with invalid new key accepted")
+	}
+}
@@ -0,0 +1,152 @@
+package kernel
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+
+	"github.com/fluffelpuff/RoueX/logging"
+	"github.com/fluffelpuff/RoueX/utils"
+)
+
+// Stellt den HTTP Server dar, welcher die Metriken des Kernels im Prometheus Textformat bereitstellt
+type MetricsServerEP struct {
+	_kernel     *Kernel
+	_obj_id     string
+	_is_running bool
+	_server     *http.Server
+	_lock       *sync.Mutex
+	_ip_adr     string
+	_port       int
+	_closed     chan struct{}
+}
+
+// Gibt an ob der Server ausgeführt wird
+func (obj *MetricsServerEP) _is_rn() bool {
+	obj._lock.Lock()
+	defer obj._lock.Unlock()
+	return obj._is_running
+}
+
+// Registriert den Kernel im Module
+func (obj *MetricsServerEP) RegisterKernel(k *Kernel) error {
+	k.Logger(logging.KERNEL).Info("MetricsServerEP: registrated on kernel", "kernel", k.GetKernelID())
+	obj._kernel = k
+	return nil
+}
+
+// Beantwortet eine Anfrage auf den Metriken Pfad
+func (obj *MetricsServerEP) _serve_metrics(w http.ResponseWriter, r *http.Request) {
+	// Es werden nur GET Anfragen zugelassen
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Die Metriken werden geschrieben
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	if err := obj._kernel.WriteMetrics(w); err != nil {
+		obj._kernel.Logger(logging.KERNEL).Warn("MetricsServerEP: error by writing metrics", "id", obj._obj_id, "error", err.Error())
+	}
+}
+
+// Startet den eigentlichen Server
+func (obj *MetricsServerEP) Start() error {
+	// Der Server Socket wird erstellt, so wird ein Fehler beim Binden direkt zurückgegeben
+	listener, err := net.Listen("tcp", net.JoinHostPort(obj._ip_adr, strconv.Itoa(obj._port)))
+	if err != nil {
+		return fmt.Errorf("MetricsServerEP: " + err.Error())
+	}
+
+	// Der HTTP Server wird erstellt
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", obj._serve_metrics)
+	server := &http.Server{Addr: listener.Addr().String(), Handler: mux}
+
+	// Es wird Signalisiert dass der Server ausgeführt wird
+	obj._lock.Lock()
+	obj._server = server
+	obj._is_running = true
+	obj._closed = make(chan struct{})
+	obj._lock.Unlock()
+
+	// Der Server wird in einem eigenen Thread ausgeführt
+	go func() {
+		// Log
+		obj._kernel.Logger(logging.KERNEL).Info("MetricsServerEP: new metrics server started", "id", obj._obj_id, "endpoint", server.Addr)
+		if err := server.Serve(listener); err != nil && err != http.ErrServerClosed {
+			obj._kernel.Logger(logging.KERNEL).Warn("MetricsServerEP: server error", "id", obj._obj_id, "error", err.Error())
+		}
+
+		// Es wird Signalisiert dass der Server beendet wurde
+		obj._lock.Lock()
+		obj._is_running = false
+		close(obj._closed)
+		obj._lock.Unlock()
+
+		// Log
+		obj._kernel.Logger(logging.KERNEL).Info("MetricsServerEP: closed", "id", obj._obj_id, "endpoint", server.Addr)
+	}()
+
+	// Der Vorgang wurde ohne Fehler gestartet
+	return nil
+}
+
+// Wird verwendet um den Server herunterzufahren
+func (obj *MetricsServerEP) Shutdown() {
+	// Log
+	obj._kernel.Logger(logging.KERNEL).Info("MetricsServerEP: shutingdown", "id", obj._obj_id)
+
+	// Der HTTP Server wird geschlossen
+	obj._lock.Lock()
+	if obj._server != nil {
+		obj._server.Close()
+	}
+	closed := obj._closed
+	obj._lock.Unlock()
+
+	// Es wird gewartet bis der Server beendet wurde
+	if closed != nil {
+		<-closed
+	}
+
+	// Log
+	obj._kernel.Logger(logging.KERNEL).Info("MetricsServerEP: shutingdown complete", "id", obj._obj_id)
+}
+
+// Gibt das Aktuelle Protokoll aus
+func (obj *MetricsServerEP) GetProtocol() string {
+	return "metrics"
+}
+
+// Gibt die Aktuelle Objekt ID aus
+func (obj *MetricsServerEP) GetObjectId() string {
+	return obj._obj_id
+}
+
+// Gibt an ob der Server bereits gestartet wurde
+func (obj *MetricsServerEP) IsRunning() bool {
+	return obj._is_rn()
+}
+
+// Gibt den Verwendeten Port zurück
+func (obj *MetricsServerEP) GetLocalIPBasedPort() uint64 {
+	return uint64(obj._port)
+}
+
+// Der Metriken Server nimmt keine Relay Verbindungen an und wird daher nicht für P2P Verbindungen angeboten
+func (obj *MetricsServerEP) IsIpBasedServer() bool {
+	return false
+}
+
+// Erstellt einen neuen lokalen Metriken Server
+func CreateNewLocalMetricsServerEP(ip_adr string, port uint64) (*MetricsServerEP, error) {
+	// Das Objekt wird vorbereitet
+	result_obj := &MetricsServerEP{_obj_id: utils.RandStringRunes(16), _lock: new(sync.Mutex), _ip_adr: ip_adr, _port: int(port)}
+
+	// Log
+	logging.Logger(logging.KERNEL).Info("MetricsServerEP: new metrics server endpoint created", "address", ip_adr, "port", port)
+	return result_obj, nil
+}
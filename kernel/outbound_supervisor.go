@@ -0,0 +1,236 @@
+package kernel
+
+import (
+	"context"
+	"math/rand"
+	"time"
+)
+
+// Gibt die minimale und maximale Wartezeit zwischen zwei Verbindungsversuchen an
+const (
+	OUTBOUND_BACKOFF_MIN = 1 * time.Second
+	OUTBOUND_BACKOFF_MAX = 5 * time.Minute
+)
+
+// Gibt an, wie lange eine Verbindung bestehen muss, damit die Wartezeit wieder zurückgesetzt wird
+const OUTBOUND_BACKOFF_RESET = 30 * time.Second
+
+// Stellt die Verwaltung der ausgehenden Verbindung eines Relays dar, der Context wird vom Kernel abgeleitet
+type outbound_worker struct {
+	_relay  *Relay
+	_ctx    context.Context
+	_cancel context.CancelFunc
+}
+
+// Wartet die angegebene Zeit, sollte die Verwaltung zuvor beendet werden, wird false zurückgegeben
+func (obj *outbound_worker) _wait(d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-obj._ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+// Gibt an ob die Verwaltung beendet wurde
+func (obj *outbound_worker) _is_cancelled() bool {
+	return obj._ctx.Err() != nil
+}
+
+// Erstellt eine neue Verwaltung für ein Relay, sie wird spätestens mit dem Kernel beendet
+func (obj *Kernel) _new_outbound_worker(relay *Relay) *outbound_worker {
+	ctx, cancel := context.WithCancel(obj._ctx)
+	return &outbound_worker{_relay: relay, _ctx: ctx, _cancel: cancel}
+}
+
+// Gibt die nächste Wartezeit zurück, die Wartezeit wird verdoppelt bis das Maximum erreicht wurde
+func _next_outbound_backoff(current time.Duration) time.Duration {
+	next := current * 2
+	if next > OUTBOUND_BACKOFF_MAX {
+		return OUTBOUND_BACKOFF_MAX
+	}
+	return next
+}
+
+// Gibt eine zufällige Wartezeit zwischen der Hälfte und der vollen angegebenen Wartezeit zurück
+func _outbound_jitter(d time.Duration) time.Duration {
+	half := int64(d / 2)
+	return time.Duration(half + rand.Int63n(half+1))
+}
+
+// Verwaltet die ausgehende Verbindung eines Relays, bis die Verwaltung beendet wird
+func manageOutboundConnection(k *Kernel, worker *outbound_worker) {
+	// Sobald die Verwaltung beendet wurde, wird sie abgemeldet
+	defer k._outbound_worker_stopped(worker)
+
+	// Diese Schleife wird solange ausgeführt bis der Kernel beendet, oder die Verwaltung abgebrochen wurde
+	relay := worker._relay
+	backoff := OUTBOUND_BACKOFF_MIN
+	for !worker._is_cancelled() {
+		// Sollte bereits eine Verbindung bestehen, z.b. durch eine eingehende Verbindung, wird keine weitere aufgebaut
+		if k._connection_manager.RelayIsConnected(relay) {
+			if !worker._wait(_outbound_jitter(OUTBOUND_BACKOFF_MIN * 5)) {
+				return
+			}
+			continue
+		}
+
+		// Das Client Modul wird anhand des Aktuellen Protokolls des Relays ermittelt
+		client_conn := k._get_client_module(relay.GetProtocol())
+		if client_conn == nil {
+			k._log.Info("Outbound handler: no client module for protocol", "relay", relay.GetPublicKeyHexString(), "protocol", relay.GetProtocol())
+			if !worker._wait(_outbound_jitter(backoff)) {
+				return
+			}
+			backoff = _next_outbound_backoff(backoff)
+			continue
+		}
+
+		// Es wird eine ausgehende Verbindung estellt
+		conn, err := client_conn.ConnectTo(relay.GetEndpoint(), relay.GetPublicKey(), nil)
+		if err != nil {
+			delay := _outbound_jitter(backoff)
+			k._log.Warn("Outbound handler: connection failed", "relay", relay.GetPublicKeyHexString(), "error", err.Error(), "retry", delay.Round(time.Millisecond))
+			if !worker._wait(delay) {
+				return
+			}
+			backoff = _next_outbound_backoff(backoff)
+			continue
+		}
+
+		// Es wird gewartet bis die Verbindung geschlossen oder die Verwaltung beendet wurde
+		connected_at := time.Now()
+		select {
+		case <-worker._ctx.Done():
+			return
+		case <-conn.Done():
+		}
+
+		// Sollte die Verbindung lange genug bestanden haben, wird die Wartezeit zurückgesetzt
+		if time.Since(connected_at) >= OUTBOUND_BACKOFF_RESET {
+			backoff = OUTBOUND_BACKOFF_MIN
+		}
+
+		// Es wird vor dem erneuten Verbindungsaufbau gewartet
+		if !worker._wait(_outbound_jitter(backoff)) {
+			return
+		}
+		backoff = _next_outbound_backoff(backoff)
+	}
+}
+
+// Meldet eine beendete Verwaltung ab, sofern sie nicht bereits ersetzt wurde
+func (obj *Kernel) _outbound_worker_stopped(worker *outbound_worker) {
+	worker._cancel()
+	obj._lock.Lock()
+	defer obj._lock.Unlock()
+	if obj._outbound_relays[worker._relay] == worker {
+		delete(obj._outbound_relays, worker._relay)
+	}
+}
+
+// Gibt das Client Modul für ein bestimmtes Protokoll zurück
+func (obj *Kernel) _get_client_module(protocol string) ClientModule {
+	for _, item := range obj._client_modules {
+		if item.GetProtocol() == protocol {
+			return item
+		}
+	}
+	return nil
+}
+
+// Gleicht die laufenden ausgehenden Verbindungen mit den Vertrauenswürdigen Relays ab, Verbindungen zu entfernten
+// oder deaktivierten Relays werden beendet, für neue Relays werden Verbindungen aufgebaut. Die Verwaltung der
+// angegebenen Relays wird neu gestartet
+func (obj *Kernel) _reconcile_outbound_connections(restart ...*Relay) {
+	// Die Verbindungen zu den nicht mehr gewünschten Relays werden geschlossen
+	for _, relay := range obj._reconcile_outbound_workers(restart...) {
+		obj._connection_manager.CloseRelayConnections(relay)
+	}
+}
+
+// Gleicht die Verwaltungen der ausgehenden Verbindungen mit den Vertrauenswürdigen Relays ab, es werden alle Relays
+// zurückgegeben deren Verwaltung beendet wurde, ihre Verbindungen bleiben bestehen
+func (obj *Kernel) _reconcile_outbound_workers(restart ...*Relay) []*Relay {
+	obj._lock.Lock()
+
+	// Sollte der Kernel nicht ausgeführt werden oder beendet werden, wird der Vorgang abgebrochen
+	if !obj._is_running || obj._stopping || obj._draining {
+		obj._lock.Unlock()
+		return nil
+	}
+
+	// Es werden alle Relays ermittelt, mit welchen eine ausgehende Verbindung bestehen soll
+	desired := make(map[*Relay]bool)
+	for _, relay := range obj._trusted_relays.GetAllRelays() {
+		if relay.IsActive() && len(relay.GetEndpoint()) > 0 && len(relay.GetProtocol()) > 0 {
+			desired[relay] = true
+		}
+	}
+
+	// Die Verwaltungen welche neu gestartet werden sollen, werden beendet
+	for _, relay := range restart {
+		if worker, found := obj._outbound_relays[relay]; found {
+			worker._cancel()
+			delete(obj._outbound_relays, relay)
+		}
+	}
+
+	// Die Verwaltungen für nicht mehr gewünschte Relays werden beendet
+	stopped := make([]*Relay, 0)
+	for relay, worker := range obj._outbound_relays {
+		if desired[relay] {
+			continue
+		}
+		worker._cancel()
+		delete(obj._outbound_relays, relay)
+		stopped = append(stopped, relay)
+	}
+
+	// Für alle neuen Relays wird eine Verwaltung gestartet
+	started := 0
+	for relay := range desired {
+		if _, found := obj._outbound_relays[relay]; found {
+			continue
+		}
+		worker := obj._new_outbound_worker(relay)
+		obj._outbound_relays[relay] = worker
+		obj._go(func() { manageOutboundConnection(obj, worker) })
+		started++
+	}
+	obj._lock.Unlock()
+
+	// Log
+	if started > 0 || len(stopped) > 0 {
+		obj._log.Info("Kernel: outbound connections reconciled", "started", started, "stopped", len(stopped))
+	}
+
+	// Die beendeten Relays werden zurückgegeben
+	return stopped
+}
+
+// Beendet alle Verwaltungen der ausgehenden Verbindungen, der Threadlock muss bereits gesetzt sein
+func (obj *Kernel) _cancel_outbound_connections() {
+	for relay, worker := range obj._outbound_relays {
+		worker._cancel()
+		delete(obj._outbound_relays, relay)
+	}
+}
+
+// Lädt die Vertrauenswürdigen Relays erneut aus der Datenbank und gleicht die ausgehenden Verbindungen ab
+func (obj *Kernel) ReloadTrustedRelays() error {
+	// Die Relays werden neu geladen
+	changed, err := obj._trusted_relays.Reload()
+	if err != nil {
+		return err
+	}
+
+	// Die ausgehenden Verbindungen der geänderten Relays werden neu gestartet
+	obj._reconcile_outbound_connections(changed...)
+
+	// Log
+	obj._log.Info("Kernel: trusted relays reloaded", "total", len(obj._trusted_relays.GetAllRelays()), "changed", len(changed))
+	return nil
+}
@@ -0,0 +1,121 @@
+package kernel
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+)
+
+// Stellt die Richtlinie für Rückverbindungen dar, kündigt ein Relay über eine eingehende Verbindung einen eigenen
+// Server an, wird eine zweite, ausgehende Verbindung zu diesem Server aufgebaut
+type P2PBackDialPolicy struct {
+	Enabled        bool
+	AllowUntrusted bool
+}
+
+// Gibt die Standardrichtlinie an, Rückverbindungen werden nur zu Vertrauenswürdigen Relays aufgebaut
+var DEFAULT_P2P_BACK_DIAL_POLICY = P2PBackDialPolicy{Enabled: true, AllowUntrusted: false}
+
+// Legt die Richtlinie für Rückverbindungen fest
+func (obj *Kernel) SetP2PBackDialPolicy(policy P2PBackDialPolicy) {
+	obj._lock.Lock()
+	defer obj._lock.Unlock()
+	obj._back_dial_policy = policy
+}
+
+// Gibt die Richtlinie für Rückverbindungen zurück
+func (obj *Kernel) GetP2PBackDialPolicy() P2PBackDialPolicy {
+	obj._lock.Lock()
+	defer obj._lock.Unlock()
+	return obj._back_dial_policy
+}
+
+// Gibt an ob zu einem Relay eine Rückverbindung aufgebaut werden darf
+func (obj *Kernel) AllowP2PBackDial(pkey *btcec.PublicKey) error {
+	// Es wird geprüft ob der Kernel ausgeführt wird und ob Rückverbindungen zulässig sind
+	obj._lock.Lock()
+	policy, can_dial := obj._back_dial_policy, obj._is_running && !obj._stopping && !obj._draining
+	obj._lock.Unlock()
+	if !can_dial {
+		return fmt.Errorf("AllowP2PBackDial: kernel not running")
+	}
+	if !policy.Enabled {
+		return fmt.Errorf("AllowP2PBackDial: back dial disabled")
+	}
+
+	// Es wird geprüft ob dem Relay vertraut wird
+	if !policy.AllowUntrusted {
+		trusted_relay, err := obj.GetTrustedRelayByPublicKey(pkey)
+		if err != nil {
+			return fmt.Errorf("AllowP2PBackDial: " + err.Error())
+		}
+		if trusted_relay == nil {
+			return fmt.Errorf("AllowP2PBackDial: back dial disabled for untrusted relays")
+		}
+	}
+
+	// Es wird geprüft ob bereits eine ausgehende Verbindung mit dem Relay besteht
+	if obj._connection_manager.HasOutboundConnection(pkey) {
+		return fmt.Errorf("AllowP2PBackDial: outbound connection already established")
+	}
+
+	// Die Rückverbindung ist zulässig
+	return nil
+}
+
+// Baut eine Rückverbindung zu einem Relay auf, der Endpunkt muss im Format des Client Moduls angegeben werden,
+// die Verbindung wird nur behalten, wenn der Handshake den Schlüssel des angekündigten Relays ergibt
+func (obj *Kernel) BackDialRelay(pkey *btcec.PublicKey, protocol string, end_point string) error {
+	// Es wird geprüft ob die Rückverbindung zulässig ist
+	if err := obj.AllowP2PBackDial(pkey); err != nil {
+		return fmt.Errorf("BackDialRelay: 1: " + err.Error())
+	}
+
+	// Es wird geprüft ob bereits eine Rückverbindung zu diesem Relay aufgebaut wird
+	relay_hex := hex.EncodeToString(pkey.SerializeCompressed())
+	obj._lock.Lock()
+	if obj._back_dials[relay_hex] {
+		obj._lock.Unlock()
+		return fmt.Errorf("BackDialRelay: 2: back dial always in progress")
+	}
+	obj._back_dials[relay_hex] = true
+	obj._lock.Unlock()
+	defer func() {
+		obj._lock.Lock()
+		delete(obj._back_dials, relay_hex)
+		obj._lock.Unlock()
+	}()
+
+	// Das Client Modul wird anhand des angekündigten Protokolls ermittelt
+	client_module := obj._get_client_module(protocol)
+	if client_module == nil {
+		return fmt.Errorf("BackDialRelay: 3: no client module for protocol " + protocol)
+	}
+
+	// Es wird eine ausgehende Verbindung aufgebaut
+	conn, err := client_module.ConnectTo(end_point, pkey, nil)
+	if err != nil {
+		return fmt.Errorf("BackDialRelay: 4: " + err.Error())
+	}
+
+	// Es wird geprüft ob die Verbindung dem angekündigten Relay zugeordnet wurde
+	relay, found, err := obj._connection_manager.GetRelayByConnection(conn)
+	if err != nil || !found || !bytes.Equal(relay.GetPublicKey().SerializeCompressed(), pkey.SerializeCompressed()) {
+		conn.CloseByKernel()
+		return fmt.Errorf("BackDialRelay: 5: handshake yields an other relay key")
+	}
+
+	// Bei einem nicht Vertrauenswürdigen Relay wird der Endpunkt der eingehenden Verbindung durch den erreichbaren Server ersetzt,
+	// so wird dieser beim Übernehmen des Relays als Vertrauenswürdiges Relay gespeichert
+	if !relay.IsTrusted() {
+		obj._connection_manager.UpdateUntrustedRelayEndpoint(relay, end_point, protocol)
+	}
+
+	// Log
+	obj._log.Info("Kernel: back dial connection established", "relay", relay_hex, "protocol", protocol, "endpoint", end_point, "connection", conn.GetObjectId())
+
+	// Der Vorgang wurde ohne Fehler durchgeführt
+	return nil
+}
@@ -3,12 +3,15 @@ package kernel
 import (
 	"bytes"
 	"encoding/hex"
-	"log"
+	"sync"
 
 	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/fluffelpuff/RoueX/logging"
 )
 
+// Stellt ein Relay dar, der Öffentliche Schlüssel ist unveränderlich, alle übrigen Werte werden durch den Threadlock geschützt
 type Relay struct {
+	_lock       *sync.RWMutex
 	_db_id      int64
 	_hexed_id   string
 	_public_key *btcec.PublicKey
@@ -21,11 +24,15 @@ type Relay struct {
 
 // Gibt das verwendete Protkoll aus
 func (obj *Relay) GetProtocol() string {
+	obj._lock.RLock()
+	defer obj._lock.RUnlock()
 	return obj._type
 }
 
 // Gibt den Endpunkt der Gegenseite aus
 func (obj *Relay) GetEndpoint() string {
+	obj._lock.RLock()
+	defer obj._lock.RUnlock()
 	return obj._end_point
 }
 
@@ -39,11 +46,92 @@ func (obj *Relay) GetPublicKeyHexString() string {
 	return hex.EncodeToString(obj._public_key.SerializeCompressed())
 }
 
+// Gibt an ob das Relay aktiviert ist
+func (obj *Relay) IsActive() bool {
+	obj._lock.RLock()
+	defer obj._lock.RUnlock()
+	return obj._active
+}
+
 // Gibt an ob dem Relay vertraut wird
 func (obj *Relay) IsTrusted() bool {
+	obj._lock.RLock()
+	defer obj._lock.RUnlock()
 	return obj._trusted
 }
 
+// Gibt die Datenbank ID des Relays zurück
+func (obj *Relay) _get_db_id() int64 {
+	obj._lock.RLock()
+	defer obj._lock.RUnlock()
+	return obj._db_id
+}
+
+// Gibt die Hex ID des Relays zurück
+func (obj *Relay) _get_hexed_id() string {
+	obj._lock.RLock()
+	defer obj._lock.RUnlock()
+	return obj._hexed_id
+}
+
+// Legt den Endpunkt und das Protokoll des Relays fest
+func (obj *Relay) _set_end_point(end_point string, tpe string) {
+	obj._lock.Lock()
+	defer obj._lock.Unlock()
+	obj._end_point = end_point
+	obj._type = tpe
+}
+
+// Aktiviert oder Deaktiviert das Relay
+func (obj *Relay) _set_active(active bool) {
+	obj._lock.Lock()
+	defer obj._lock.Unlock()
+	obj._active = active
+}
+
+// Markiert das Relay als Vertrauenswürdig und übernimmt die Werte seines Datenbank Eintrages
+func (obj *Relay) _set_trusted(db_id int64, hexed_id string, end_point string, tpe string) {
+	obj._lock.Lock()
+	defer obj._lock.Unlock()
+	obj._db_id = db_id
+	obj._hexed_id = hexed_id
+	obj._end_point = end_point
+	obj._type = tpe
+	obj._active = true
+	obj._trusted = true
+}
+
+// Übernimmt die veränderlichen Werte eines anderen Relays, die Kopie bleibt dabei für andere Routinen gültig
+func (obj *Relay) _copy_from(other *Relay) {
+	values := other._snapshot()
+	obj._lock.Lock()
+	defer obj._lock.Unlock()
+	obj._db_id = values._db_id
+	obj._hexed_id = values._hexed_id
+	obj._last_used = values._last_used
+	obj._end_point = values._end_point
+	obj._active = values._active
+	obj._type = values._type
+	obj._trusted = values._trusted
+}
+
+// Erstellt eine Kopie des Relays mit einem eigenen Threadlock
+func (obj *Relay) _snapshot() Relay {
+	obj._lock.RLock()
+	defer obj._lock.RUnlock()
+	return Relay{
+		_lock:       new(sync.RWMutex),
+		_db_id:      obj._db_id,
+		_hexed_id:   obj._hexed_id,
+		_public_key: obj._public_key,
+		_last_used:  obj._last_used,
+		_end_point:  obj._end_point,
+		_active:     obj._active,
+		_type:       obj._type,
+		_trusted:    obj._trusted,
+	}
+}
+
 // Gibt an, ob es sich um die gleiche Verbindung handelt
 func (obj *Relay) Equal(p2 *Relay) bool {
 	return bytes.Equal(obj.GetPublicKey().SerializeCompressed(), p2.GetPublicKey().SerializeCompressed())
@@ -76,6 +164,6 @@ func (obj *RelayOutboundPair) GetClientConnModule() *ClientModule {
 
 // Erstellt ein nicht Vertrauenswürdiges Relay
 func NewUntrustedRelay(public_key *btcec.PublicKey, last_useed int64, end_point string, tpe string) *Relay {
-	log.Println("New temporary untrusted relay created", hex.EncodeToString(public_key.SerializeCompressed()))
-	return &Relay{_public_key: public_key, _last_used: uint64(last_useed), _type: tpe, _trusted: false, _end_point: end_point, _active: true, _hexed_id: "", _db_id: -1}
+	logging.Logger(logging.KERNEL).Debug("New temporary untrusted relay created", "relay", hex.EncodeToString(public_key.SerializeCompressed()))
+	return &Relay{_lock: new(sync.RWMutex), _public_key: public_key, _last_used: uint64(last_useed), _type: tpe, _trusted: false, _end_point: end_point, _active: true, _hexed_id: "", _db_id: -1}
 }
@@ -1,22 +1,33 @@
 package kernel
 
 import (
+	"bytes"
 	"encoding/hex"
 	"fmt"
-	"log"
+	"log/slog"
 	"sync"
-	"time"
 
+	"github.com/btcsuite/btcd/btcec/v2"
 	addresspackages "github.com/fluffelpuff/RoueX/address_packages"
 	"github.com/fluffelpuff/RoueX/kernel/extra"
+	"github.com/fluffelpuff/RoueX/rerror"
 	routingmanager "github.com/fluffelpuff/RoueX/routing_manager"
 )
 
+// Stellt ein Relay dar, zu welchem keine Verbindung mehr besteht samt der Ziele deren Routen entfernt wurden
+type RelayWithdrawal struct {
+	Relay        *Relay
+	Destinations []*btcec.PublicKey
+}
+
 // Stellt den Verbindungsmanager dar
 type RelayConnectionRoutingTable struct {
 	_connection_relay_map   map[string]*Relay
 	__direct_route_ro_relay map[string]*RelayConnectionEntry
 	_relays_map             map[*Relay]*RelayConnectionEntry
+	_routing_manager        *routingmanager.RoutingManager
+	_metrics                *kernel_metrics
+	_log                    *slog.Logger
 	_lock                   *sync.Mutex
 	_shutdow_cmd            bool
 	_is_closed              bool
@@ -66,6 +77,27 @@ func (obj *RelayConnectionRoutingTable) HasActiveConnections() bool {
 	return false
 }
 
+// Gibt an ob eine der Verbindungen noch Pakete im Schreibpuffer hat
+func (obj *RelayConnectionRoutingTable) HasPendingWrites() bool {
+	// Die Relay Einträge werden abgerufen
+	obj._lock.Lock()
+	entries := make([]*RelayConnectionEntry, 0, len(obj._relays_map))
+	for _, entry := range obj._relays_map {
+		entries = append(entries, entry)
+	}
+	obj._lock.Unlock()
+
+	// Es wird geprüft ob ein Relay noch Pakete zu senden hat
+	for _, entry := range entries {
+		if entry.HasPendingWrites() {
+			return true
+		}
+	}
+
+	// Es sind keine Pakete mehr vorhanden
+	return false
+}
+
 // Fügt eine neue AKtive Verbindung zum Manager hinzu
 func (obj *RelayConnectionRoutingTable) RegisterNewRelayConnection(relay *Relay, conn RelayConnection) error {
 	// Der Threadlock wird ausgeführt
@@ -82,12 +114,13 @@ func (obj *RelayConnectionRoutingTable) RegisterNewRelayConnection(relay *Relay,
 	if !found_relay_entry {
 		// Der Relay Eintrag wird erzeugt
 		relay_entry = &RelayConnectionEntry{
-			RelayLink:        *relay,
+			RelayLink:        relay._snapshot(),
 			Connections:      []RelayConnection{},
 			PingTime:         []uint64{},
 			_lock:            new(sync.Mutex),
 			_closed:          false,
 			_signal_shutdown: false,
+			_log:             obj._log,
 		}
 
 		// Der Eintrag wird abgespeichert
@@ -97,7 +130,7 @@ func (obj *RelayConnectionRoutingTable) RegisterNewRelayConnection(relay *Relay,
 		obj.__direct_route_ro_relay[hex.EncodeToString(relay._public_key.SerializeCompressed())] = relay_entry
 
 		// Log
-		log.Println("RelayConnectionRoutingTable: new relay and route added. relay =", relay.GetPublicKeyHexString())
+		obj._log.Info("RelayConnectionRoutingTable: new relay and route added", "relay", relay.GetPublicKeyHexString())
 	} else {
 		// Es wird geprüft ob die Verbindung dem Relay bereits zugewiesen wurde
 		if relay_entry.ConnectionIsKnown(conn) {
@@ -117,47 +150,181 @@ func (obj *RelayConnectionRoutingTable) RegisterNewRelayConnection(relay *Relay,
 	return nil
 }
 
-// Entfernt eine Verbindung
-func (obj *RelayConnectionRoutingTable) RemoveConnectionFromRelay(conn RelayConnection) error {
+// Entfernt eine Verbindung, sollte keine Verbindung mehr mit dem Relay bestehen werden alle Routen über dieses Relay Deaktiviert
+func (obj *RelayConnectionRoutingTable) RemoveConnectionFromRelay(conn RelayConnection) (*RelayWithdrawal, error) {
 	// Der Threadlock wird ausgeführt
 	obj._lock.Lock()
 	defer obj._lock.Unlock()
 
 	// Es wird geprüft ob die Routing Tabelle geschlossen wurde
 	if obj._is_closed {
-		return fmt.Errorf("RemoveConnectionFromRelay: 1: routing table are closed")
+		return nil, fmt.Errorf("RemoveConnectionFromRelay: 1: routing table are closed")
 	}
 
 	// Es wird geprüft ob es für diese Verbindung einen Eintrag gibt
 	relay_link, has_found := obj._connection_relay_map[conn.GetObjectId()]
 	if !has_found {
-		return fmt.Errorf("RemoveConnectionFromRelay: 1: the connection cant found")
+		return nil, fmt.Errorf("RemoveConnectionFromRelay: 1: the connection cant found")
 	}
 
 	// Der Relay eintrag wird ermittelt
 	relay_entry, has_found := obj._relays_map[relay_link]
 	if !has_found {
-		return fmt.Errorf("RemoveConnectionFromRelay: 2: the connection cant found")
+		return nil, fmt.Errorf("RemoveConnectionFromRelay: 2: the connection cant found")
 	}
 
 	// Die Verbindung wird aus dem Relay Eintrag entfernt
 	if err := relay_entry.RemoveConnection(conn); err != nil {
-		return fmt.Errorf("RemoveConnectionFromRelay: 3: " + err.Error())
+		return nil, fmt.Errorf("RemoveConnectionFromRelay: 3: " + err.Error())
 	}
 
 	// Die Verlinkung mit dem Relay wird entfernt
 	delete(obj._connection_relay_map, conn.GetObjectId())
 
+	// Die übertragenen Daten der Verbindung werden dem Relay fortlaufend zugerechnet
+	tx, rx := conn.GetTxRxBytes()
+	obj._metrics.add_closed_connection(relay_link.GetPublicKeyHexString(), tx, rx)
+
 	// Es wird geprüft ob dem Relay noch weitere Verbindungen zugeodnet sind
 	if len(relay_entry.Connections) < 1 {
 		// Log
-		log.Println("RelayConnectionRoutingTable: relay complete removed. relay =", relay_link.GetPublicKeyHexString())
+		obj._log.Info("RelayConnectionRoutingTable: relay complete removed", "relay", relay_link.GetPublicKeyHexString())
 
 		// Der Eintrag wird entfernt
 		delete(obj._relays_map, relay_link)
+
+		// Die Direkte Route zu diesem Relay wird entfernt, sollte über einen anderen Eintrag
+		// weiterhin eine Verbindung mit dem Relay bestehen, bleiben die Routen erhalten
+		relay_hex := hex.EncodeToString(relay_link._public_key.SerializeCompressed())
+		if obj.__direct_route_ro_relay[relay_hex] != relay_entry {
+			return nil, nil
+		}
+		delete(obj.__direct_route_ro_relay, relay_hex)
+
+		// Die Routen über dieses Relay werden Deaktiviert, sie bleiben in der Datenbank erhalten
+		withdrawal := &RelayWithdrawal{Relay: relay_link, Destinations: []*btcec.PublicKey{}}
+		if obj._routing_manager != nil {
+			withdrawal.Destinations = obj._routing_manager.DeactivateRoutesByRelay(relay_link.GetPublicKey())
+		}
+
+		// Die Zurückgezogenen Routen werden zurückgegeben
+		return withdrawal, nil
 	}
 
 	// Der Vorgang wurde ohne fehler erfolgreich durchgeführt
+	return nil, nil
+}
+
+// Gibt alle Relays zurück, mit denen eine Aktive Direkte Verbindung besteht
+func (obj *RelayConnectionRoutingTable) GetConnectedRelays() []*Relay {
+	// Der Threadlock wird ausgeführt
+	obj._lock.Lock()
+	defer obj._lock.Unlock()
+
+	// Es werden alle Direkten Routen mit einer Aktiven Verbindung herausgesucht
+	result := make([]*Relay, 0, len(obj.__direct_route_ro_relay))
+	for _, entry := range obj.__direct_route_ro_relay {
+		if entry.HasActiveConnection() {
+			result = append(result, &entry.RelayLink)
+		}
+	}
+
+	// Die Relays werden zurückgegeben
+	return result
+}
+
+// Gibt an ob mit einem Relay eine Aktive Direkte Verbindung besteht
+func (obj *RelayConnectionRoutingTable) HasDirectRoute(pkey *btcec.PublicKey) bool {
+	// Der Threadlock wird ausgeführt
+	obj._lock.Lock()
+	defer obj._lock.Unlock()
+
+	// Es wird geprüft ob eine Aktive Verbindung besteht
+	entry, found := obj.__direct_route_ro_relay[hex.EncodeToString(pkey.SerializeCompressed())]
+	if !found {
+		return false
+	}
+	return entry.HasActiveConnection()
+}
+
+// Gibt an ob mit einem Vertrauenswürdigen Relay eine Aktive Direkte Verbindung besteht
+func (obj *RelayConnectionRoutingTable) HasTrustedDirectRoute(pkey *btcec.PublicKey) bool {
+	// Der Threadlock wird ausgeführt
+	obj._lock.Lock()
+	defer obj._lock.Unlock()
+
+	// Es wird geprüft ob eine Aktive Verbindung mit einem Vertrauenswürdigen Relay besteht
+	entry, found := obj.__direct_route_ro_relay[hex.EncodeToString(pkey.SerializeCompressed())]
+	if !found {
+		return false
+	}
+	return entry.HasActiveConnection() && entry.IsTrustedConnection()
+}
+
+// Gibt an ob mit einem Relay eine Aktive ausgehende Verbindung besteht
+func (obj *RelayConnectionRoutingTable) HasOutboundConnection(pkey *btcec.PublicKey) bool {
+	// Der Threadlock wird ausgeführt
+	obj._lock.Lock()
+	defer obj._lock.Unlock()
+
+	// Es wird geprüft ob eine Aktive ausgehende Verbindung besteht
+	entry, found := obj.__direct_route_ro_relay[hex.EncodeToString(pkey.SerializeCompressed())]
+	if !found {
+		return false
+	}
+	return len(entry.GetOutboundConnections()) > 0
+}
+
+// Gibt alle Relays zurück, mit denen eine Aktive ausgehende Verbindung besteht
+func (obj *RelayConnectionRoutingTable) GetOutboundRelays() []*Relay {
+	// Der Threadlock wird ausgeführt
+	obj._lock.Lock()
+	defer obj._lock.Unlock()
+
+	// Es werden alle Relays mit einer ausgehenden Verbindung herausgesucht
+	result := make([]*Relay, 0)
+	for relay, entry := range obj._relays_map {
+		if len(entry.GetOutboundConnections()) > 0 {
+			result = append(result, relay)
+		}
+	}
+
+	// Die Relays werden zurückgegeben
+	return result
+}
+
+// Gibt alle nicht Vertrauenswürdigen Relays zurück, für welche ein Eintrag vorhanden ist
+func (obj *RelayConnectionRoutingTable) GetUntrustedRelays() []*Relay {
+	// Der Threadlock wird ausgeführt
+	obj._lock.Lock()
+	defer obj._lock.Unlock()
+
+	// Es werden alle nicht Vertrauenswürdigen Relays herausgesucht
+	result := make([]*Relay, 0)
+	for relay := range obj._relays_map {
+		if !relay.IsTrusted() {
+			result = append(result, relay)
+		}
+	}
+
+	// Die Relays werden zurückgegeben
+	return result
+}
+
+// Gibt das Relay Objekt zurück, unter welchem die Verbindungen eines Öffentlichen Schlüssels registriert wurden
+func (obj *RelayConnectionRoutingTable) GetRelayByPublicKey(pkey *btcec.PublicKey) *Relay {
+	// Der Threadlock wird ausgeführt
+	obj._lock.Lock()
+	defer obj._lock.Unlock()
+
+	// Es wird nach einem Relay mit diesem Schlüssel gesucht
+	for relay := range obj._relays_map {
+		if bytes.Equal(relay._public_key.SerializeCompressed(), pkey.SerializeCompressed()) {
+			return relay
+		}
+	}
+
+	// Es wurde kein Relay gefunden
 	return nil
 }
 
@@ -204,14 +371,40 @@ func (obj *RelayConnectionRoutingTable) GetAllMetaInformationsOfRelayConnections
 		return nil, fmt.Errorf("GetAllMetaInformationsOfRelayConnections: 1: routing table are closed")
 	}
 
-	// Es wird geprüft ob es einen Eintrag für diesen Relay gibt
+	// Es wird geprüft ob es einen Eintrag für diesen Relay gibt, sollte keiner vorhanden sein, besteht keine Verbindung
 	entry, found := obj._relays_map[relay]
 	if !found {
-		return nil, fmt.Errorf("GetAllMetaInformationsOfRelayConnections: 1: unkown relay")
+		return nil, nil
 	}
 
-	// Die Antwort wird gebaut
-	result := &RelayMetaData{
+	// Die Antwort wird gebaut und zurückgegeben
+	return _build_relay_meta_data(relay, entry), nil
+}
+
+// Ruft die MetaDaten aller Relays ab, für welche ein Eintrag vorhanden ist
+func (obj *RelayConnectionRoutingTable) GetAllRelaysMetaData() ([]*RelayMetaData, error) {
+	// Der Threadlock wird ausgeführt
+	obj._lock.Lock()
+	defer obj._lock.Unlock()
+
+	// Es wird geprüft ob die Routing Tabelle geschlossen wurde
+	if obj._is_closed {
+		return nil, fmt.Errorf("GetAllRelaysMetaData: 1: routing table are closed")
+	}
+
+	// Die MetaDaten werden für jeden Relay erstellt
+	result := make([]*RelayMetaData, 0, len(obj._relays_map))
+	for relay, entry := range obj._relays_map {
+		result = append(result, _build_relay_meta_data(relay, entry))
+	}
+
+	// Die Antwort wird zurückgegeben
+	return result, nil
+}
+
+// Erstellt die MetaDaten eines Relays aus seinem Eintrag
+func _build_relay_meta_data(relay *Relay, entry *RelayConnectionEntry) *RelayMetaData {
+	return &RelayMetaData{
 		Connections: entry.GetAllMetaInformationsOfRelayConnections(),
 		PublicKey:   relay.GetPublicKeyHexString(),
 		IsConnected: entry.HasActiveConnection(),
@@ -220,9 +413,6 @@ func (obj *RelayConnectionRoutingTable) GetAllMetaInformationsOfRelayConnections
 		PingMS:      entry.GetAveragePingTimeMS(),
 		IsTrusted:   entry.IsTrustedConnection(),
 	}
-
-	// Die Antwort wird zurückgegeben
-	return result, nil
 }
 
 // Ruft ein Relay anhand einer Verbindung ab
@@ -246,6 +436,55 @@ func (obj *RelayConnectionRoutingTable) GetRelayByConnection(conn RelayConnectio
 	return relay_link, true, nil
 }
 
+// Ruft ein Relay anhand der ID einer seiner Verbindungen ab
+func (obj *RelayConnectionRoutingTable) GetRelayByConnectionId(conn_id string) *Relay {
+	// Der Threadlock wird ausgeführt
+	obj._lock.Lock()
+	defer obj._lock.Unlock()
+
+	// Es wird geprüft ob es für diese Verbindung einen Eintrag gibt
+	relay_link, has_found := obj._connection_relay_map[conn_id]
+	if !has_found {
+		return nil
+	}
+
+	// Das Relay wird zurückgegeben
+	return relay_link
+}
+
+// Aktualisiert den Endpunkt und das Protokoll eines nicht Vertrauenswürdigen Relays
+func (obj *RelayConnectionRoutingTable) UpdateUntrustedRelayEndpoint(relay *Relay, end_point string, protocol string) {
+	// Der Threadlock wird ausgeführt
+	obj._lock.Lock()
+	defer obj._lock.Unlock()
+
+	// Es wird geprüft ob es einen Eintrag für diesen Relay gibt
+	relay_entry, found := obj._relays_map[relay]
+	if !found || relay.IsTrusted() {
+		return
+	}
+
+	// Die Werte werden im Relay und im Eintrag aktualisiert
+	relay._set_end_point(end_point, protocol)
+	relay_entry.RelayLink._copy_from(relay)
+}
+
+// Überträgt die veränderten Werte eines Relays in seinen Eintrag, z.b. nachdem es als Vertrauenswürdig markiert wurde
+func (obj *RelayConnectionRoutingTable) RefreshRelayLink(relay *Relay) {
+	// Der Threadlock wird ausgeführt
+	obj._lock.Lock()
+	defer obj._lock.Unlock()
+
+	// Es wird geprüft ob es einen Eintrag für diesen Relay gibt
+	relay_entry, found := obj._relays_map[relay]
+	if !found {
+		return
+	}
+
+	// Die Werte werden übernommen
+	relay_entry.RelayLink._copy_from(relay)
+}
+
 // Wird verwendet um alle Aktiven Routen für ein Relay zu Initalisieren
 func (obj *RelayConnectionRoutingTable) InitRoutesForRelay(rlay *Relay, routes *routingmanager.RelayRoutesList) (bool, bool) {
 	// Der Threadlock wird ausgeführt
@@ -278,23 +517,47 @@ func (obj *RelayConnectionRoutingTable) InitRoutesForRelay(rlay *Relay, routes *
 	}
 
 	// Log
-	log.Println("RelayConnectionRoutingTable: routes for relay inited. relay =", rlay.GetPublicKeyHexString())
+	obj._log.Info("RelayConnectionRoutingTable: routes for relay inited", "relay", rlay.GetPublicKeyHexString())
 
 	// Das Relay wird herausgesucht
 	return true, true
 }
 
-// Wird vom Kernel verwendet alle Verbindungen zu schließen
+// Schließt alle Verbindungen eines Relays
+func (obj *RelayConnectionRoutingTable) CloseRelayConnections(relay *Relay) {
+	// Der Threadlock wird ausgeführt
+	obj._lock.Lock()
+	relay_entry, found := obj._relays_map[relay]
+	obj._lock.Unlock()
+
+	// Sollte kein Eintrag vorhanden sein, wird der Vorgang abgebrochen
+	if !found {
+		return
+	}
+
+	// Log
+	obj._log.Info("RelayConnectionRoutingTable: closing all connections of relay", "relay", relay.GetPublicKeyHexString())
+
+	// Die Verbindungen werden geschlossen
+	go relay_entry.CloseByKernel()
+}
+
+// Wird vom Kernel verwendet alle Verbindungen zu schließen, es wird gewartet bis alle Verbindungen geschlossen wurden
 func (obj *RelayConnectionRoutingTable) ShutdownByKernel() {
 	// Log
-	log.Println("RelayConnectionRoutingTable: shutingdown.")
+	obj._log.Info("RelayConnectionRoutingTable: shutingdown")
 
 	// Der Threadlock wird ausgeführt
 	obj._lock.Lock()
 
 	// Es wird allen Relays Signalisiert dass sie all ihre Verbindungen schließen sollen
+	var closing sync.WaitGroup
 	for i := range obj._relays_map {
-		go obj._relays_map[i].CloseByKernel()
+		closing.Add(1)
+		go func(entry *RelayConnectionEntry) {
+			defer closing.Done()
+			entry.CloseByKernel()
+		}(obj._relays_map[i])
 	}
 
 	// Es wird Signalisiert dass das Objekt beendet werden soll
@@ -304,24 +567,22 @@ func (obj *RelayConnectionRoutingTable) ShutdownByKernel() {
 	obj._lock.Unlock()
 
 	// Es wird gewartet bis alle Verbindungen geschlossen wurden
-	for obj.HasActiveConnections() {
-		time.Sleep(1 * time.Millisecond)
-	}
-
-	// Wird solange gewartet bis das Objekt vollständig leer ist
-	for !obj._is_empty() {
-		time.Sleep(1 * time.Millisecond)
-	}
+	closing.Wait()
 
 	// Der Threadlock wird verwendet um zu Signalisieren dass das Objekt geschlossen wurde
 	obj._lock.Lock()
 	defer obj._lock.Unlock()
 
+	// Sollten noch Relays ohne fertiggestellte Verbindung vorhanden sein, werden diese verworfen
+	if len(obj._relays_map) > 0 {
+		obj._log.Info("RelayConnectionRoutingTable: relays without finally connection dropped", "total", len(obj._relays_map))
+	}
+
 	// Es wird Signalisiert dass das Objekt gehschlossen werden soll
 	obj._is_closed = true
 
 	// Log
-	log.Println("RelayConnectionRoutingTable: shutdown complete.")
+	obj._log.Info("RelayConnectionRoutingTable: shutdown complete")
 }
 
 // Nimmt Pakete entgegen und Routet diese zu dem Entsprechenden Host
@@ -335,35 +596,92 @@ func (obj *RelayConnectionRoutingTable) EnterPackageToRoutingManger(pckg *addres
 		return nil, fmt.Errorf("EnterPackageToRoutingManger: 1: routing table are closed")
 	}
 
-	// Das Passende Relay für diese Verbindung wird herausgefiltert
+	// Es wird geprüft ob eine Direkte Verbindung mit dem Empfänger besteht
 	route_ep, found_route := obj.__direct_route_ro_relay[hex.EncodeToString(pckg.Reciver.SerializeCompressed())]
-	if !found_route {
-		return nil, nil
+	if found_route && route_ep.HasActiveConnection() {
+		// Das Paket wird an die Verbindung übergeben
+		sstate, err := route_ep.BufferL2PackageAndWrite(pckg)
+		if err != nil {
+			obj._record_dropped_package(err)
+			return nil, fmt.Errorf("EnterPackageToRoutingManger: 2: " + err.Error())
+		}
+
+		// Das Paket wurde erfolgreich an die Verbindung gesendet
+		return sstate, nil
 	}
 
-	// Es wird geprüft ob eine Route samt Verbindung gefunden wurde
-	if !found_route {
+	// Sollte kein Routing Manager vorhanden sein, wird der Vorgang abgebrochen
+	if obj._routing_manager == nil {
 		return nil, nil
 	}
 
-	// Es wird geprüft ob mit der Gegenseite ein Verbindung besteht
-	if !route_ep.HasActiveConnection() {
-		return nil, nil
+	// Es werden alle bekannten Routen für den Empfänger abgerufen,
+	// die Routen werden der Reihe nach ausprobiert bis ein Relay mit einer Aktiven Verbindung gefunden wurde
+	for _, route := range obj._routing_manager.LookupRoutes(&pckg.Reciver) {
+		// Es wird geprüft ob eine Verbindung mit dem Relay besteht
+		relay_ep, found_relay := obj.__direct_route_ro_relay[route.GetRelayHexId()]
+		if !found_relay || !relay_ep.HasActiveConnection() {
+			continue
+		}
+
+		// Das Paket wird an die Verbindung übergeben
+		sstate, err := relay_ep.BufferL2PackageAndWrite(pckg)
+		if err != nil {
+			obj._record_dropped_package(err)
+			return nil, fmt.Errorf("EnterPackageToRoutingManger: 3: " + err.Error())
+		}
+
+		// Es wird Signalisiert dass die Route verwendet wurde
+		obj._routing_manager.MarkRouteUsed(route)
+
+		// Das Paket wurde erfolgreich an die Verbindung gesendet
+		return sstate, nil
 	}
 
-	// Das Paket wird an die Verbindung übergeben
-	sstate, err := route_ep.BufferL2PackageAndWrite(pckg)
-	if err != nil {
-		return nil, fmt.Errorf("EnterPackageToRoutingManger: 2: " + err.Error())
+	// Es wurde keine Route gefunden, das Paket wird verworfen
+	obj._metrics.add_dropped_package(DROP_REASON_NO_ROUTE)
+	return nil, nil
+}
+
+// Vermerkt ein Paket, welches aufgrund eines IO Zustandes verworfen wurde
+func (obj *RelayConnectionRoutingTable) _record_dropped_package(err error) {
+	if ioerr, ok := err.(*rerror.IOStateError); ok {
+		obj._metrics.add_dropped_package(ioerr.Error())
 	}
+}
 
-	// Das Paket wurde erfolgreich an die Verbindung gesendet
-	return sstate, nil
+// Lernt eine Route anhand eines Paketes, welches über ein anderes Relay eingetroffen ist
+func (obj *RelayConnectionRoutingTable) LearnRouteFromPackage(pckg *addresspackages.SendableAddressLayerPackage, conn RelayConnection) {
+	// Sollte kein Routing Manager oder keine Verbindung vorhanden sein, wird der Vorgang abgebrochen
+	if obj._routing_manager == nil || conn == nil {
+		return
+	}
+
+	// Das Relay der Verbindung wird ermittelt
+	obj._lock.Lock()
+	relay, found := obj._connection_relay_map[conn.GetObjectId()]
+	obj._lock.Unlock()
+	if !found {
+		return
+	}
+
+	// Sollte das Paket direkt vom Relay stammen, muss keine Route gelernt werden
+	if relay.GetPublicKey().IsEqual(&pckg.Sender) {
+		return
+	}
+
+	// Die Route wird im Routing Manager abgespeichert
+	if err := obj._routing_manager.LearnRoute(&pckg.Sender, relay.GetPublicKey()); err != nil {
+		obj._log.Warn("RelayConnectionRoutingTable: error by learning route", "error", err.Error())
+	}
 }
 
 // Erstellt einen neuen Verbindungs Manager
-func newRelayConnectionRoutingTable() RelayConnectionRoutingTable {
+func newRelayConnectionRoutingTable(routing_manager *routingmanager.RoutingManager, metrics *kernel_metrics, logger *slog.Logger) RelayConnectionRoutingTable {
 	return RelayConnectionRoutingTable{
+		_routing_manager:        routing_manager,
+		_metrics:                metrics,
+		_log:                    logger,
 		_connection_relay_map:   make(map[string]*Relay),
 		__direct_route_ro_relay: make(map[string]*RelayConnectionEntry),
 		_relays_map:             make(map[*Relay]*RelayConnectionEntry),
@@ -2,10 +2,10 @@ package kernel
 
 import (
 	"bytes"
+	"encoding/hex"
 	"fmt"
-	"log"
+	"log/slog"
 	"sync"
-	"time"
 
 	addresspackages "github.com/fluffelpuff/RoueX/address_packages"
 	"github.com/fluffelpuff/RoueX/kernel/extra"
@@ -19,6 +19,7 @@ type RelayConnectionEntry struct {
 	_route_list      *routingmanager.RelayRoutesList
 	_signal_shutdown bool
 	_closed          bool
+	_log             *slog.Logger
 	PingTime         []uint64
 	RelayLink        Relay
 	Connections      []RelayConnection
@@ -69,6 +70,23 @@ func (obj *RelayConnectionEntry) HasActiveConnection() bool {
 	return false
 }
 
+// Gibt an ob eine der Verbindungen noch Pakete im Schreibpuffer hat
+func (obj *RelayConnectionEntry) HasPendingWrites() bool {
+	// Der Threadlock wird ausgeführt
+	obj._lock.Lock()
+	defer obj._lock.Unlock()
+
+	// Es wird geprüft ob eine Verbindung noch Pakete zu senden hat
+	for i := range obj.Connections {
+		if obj.Connections[i].IsConnected() && obj.Connections[i].HasPendingWrites() {
+			return true
+		}
+	}
+
+	// Es sind keine Pakete mehr vorhanden
+	return false
+}
+
 // Gibt an ob es sich um eine bekannte Verbindung handelt
 func (obj *RelayConnectionEntry) ConnectionIsKnown(conn RelayConnection) bool {
 	// Der Threadlock wird ausgeführt
@@ -109,7 +127,7 @@ func (obj *RelayConnectionEntry) AddConnection(conn RelayConnection) error {
 	obj.Connections = append(obj.Connections, conn)
 
 	// Log
-	log.Println("RelayConnectionEntry: relay connection added. relay =", obj.RelayLink.GetPublicKeyHexString(), "connection =", conn.GetObjectId())
+	obj._log.Info("RelayConnectionEntry: relay connection added", "relay", obj.RelayLink.GetPublicKeyHexString(), "connection", conn.GetObjectId())
 	return nil
 }
 
@@ -134,7 +152,7 @@ func (obj *RelayConnectionEntry) RemoveConnection(conn RelayConnection) error {
 	}
 
 	// Log
-	log.Println("RelayConnectionEntry: relay connection removed. relay =", obj.RelayLink.GetPublicKeyHexString(), "connection =", conn.GetObjectId())
+	obj._log.Info("RelayConnectionEntry: relay connection removed", "relay", obj.RelayLink.GetPublicKeyHexString(), "connection", conn.GetObjectId())
 	return nil
 }
 
@@ -195,6 +213,11 @@ func (obj *RelayConnectionEntry) GetAveragePingTimeMS() uint64 {
 		c++
 	}
 
+	// Sollte keine Verbindung vorhanden sein, wird 0 zurückgegeben
+	if c == 0 {
+		return 0
+	}
+
 	// Die Durchschnittliche Zeit wird ermittelt
 	result := uint64(p_time / c)
 
@@ -258,9 +281,36 @@ func (obj *RelayConnectionEntry) GetAllMetaInformationsOfRelayConnections() []Re
 	obj._lock.Lock()
 	defer obj._lock.Unlock()
 
-	// Die Metadaten werden erstellt
+	// Die Metadaten werden für jede Verbindung erstellt
+	result := make([]RelayConnectionMetaData, 0)
+	for i := range obj.Connections {
+		// Der Sitzungsschlüssel wird abgerufen, sollte noch keiner vorhanden sein bleibt das Feld leer
+		session_pkey := ""
+		if pkey, err := obj.Connections[i].GetSessionPKey(); err == nil && pkey != nil {
+			session_pkey = hex.EncodeToString(pkey.SerializeCompressed())
+		}
 
-	return nil
+		// Die Übertragenen Daten sowie der Zustand des Schreibpuffers werden abgerufen
+		tx, rx := obj.Connections[i].GetTxRxBytes()
+		buffer_packages, buffer_bytes := obj.Connections[i].GetWriteBufferUsage()
+
+		// Der Eintrag wird hinzugefügt
+		result = append(result, RelayConnectionMetaData{
+			SessionPKey:     session_pkey,
+			Id:              obj.Connections[i].GetObjectId(),
+			IsConnected:     obj.Connections[i].IsConnected(),
+			Protocol:        obj.Connections[i].GetProtocol(),
+			InboundOutbound: uint8(obj.Connections[i].GetIOType()),
+			TxBytes:         tx,
+			RxBytes:         rx,
+			Ping:            obj.Connections[i].GetPingTime(),
+			BufferPackages:  buffer_packages,
+			BufferBytes:     buffer_bytes,
+		})
+	}
+
+	// Die Metadaten werden zurückgegeben
+	return result
 }
 
 // Wird ausgeführt wenn der Kernel Signalisiert dass die Verbindung getrennt werden soll
@@ -269,8 +319,13 @@ func (obj *RelayConnectionEntry) CloseByKernel() {
 	obj._lock.Lock()
 
 	// Die Verbindungen werden geschlossen
+	var closing sync.WaitGroup
 	for i := range obj.Connections {
-		go obj.Connections[i].CloseByKernel()
+		closing.Add(1)
+		go func(conn RelayConnection) {
+			defer closing.Done()
+			conn.CloseByKernel()
+		}(obj.Connections[i])
 	}
 
 	// Es wird Signalisiert dass das Objekt beendet werden soll
@@ -280,9 +335,7 @@ func (obj *RelayConnectionEntry) CloseByKernel() {
 	obj._lock.Unlock()
 
 	// Es wird gewartet bis alle Verbindungen geschlossen wurden
-	for obj.HasActiveConnection() {
-		time.Sleep(1 * time.Millisecond)
-	}
+	closing.Wait()
 
 	// Der Threadlock wird final angewendet
 	obj._lock.Lock()
@@ -385,15 +438,20 @@ func (obj *RelayConnectionEntry) BufferL2PackageAndWrite(pckg *addresspackages.S
 
 	// Log
 	if found_conn.GetIOType() == OUTBOUND {
-		log.Printf("RelayConnectionEntry: outbound connection selected. connection = %s\n", found_conn.GetObjectId())
+		obj._log.Debug("RelayConnectionEntry: outbound connection selected", "connection", found_conn.GetObjectId())
 	} else if found_conn.GetIOType() == INBOUND {
-		log.Printf("RelayConnectionEntry: inbound connection selected. connection = %s\n", found_conn.GetObjectId())
+		obj._log.Debug("RelayConnectionEntry: inbound connection selected", "connection", found_conn.GetObjectId())
 	} else {
 		panic("internal error, unkown connection io mode")
 	}
 
-	// Die Daten werden an die Verbindung übergeben
-	ste, err := found_conn.EnterSendableData(byted_pckge)
+	// Die Daten werden an die Verbindung übergeben, Steuerpakete werden bevorzugt übertragen
+	var ste *extra.PackageSendState
+	if pckg.Control {
+		ste, err = found_conn.EnterSendableControlData(byted_pckge)
+	} else {
+		ste, err = found_conn.EnterSendableData(byted_pckge)
+	}
 	if err != nil {
 		// Es wird geprüft ob ein Fehler aufgetreten ist
 		if err != nil {
@@ -0,0 +1,275 @@
+package kernel
+
+import (
+	"bytes"
+	"fmt"
+	"time"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/fluffelpuff/RoueX/utils"
+	"github.com/fxamacker/cbor"
+)
+
+// Gibt die Version der Relay Beschreibungen an
+const RELAY_DESCRIPTOR_VERSION = uint64(1)
+
+// Gibt an, wie lange eine Relay Beschreibung standardmäßig gültig ist
+const DEFAULT_RELAY_DESCRIPTOR_TTL = 1 * time.Hour
+
+// Gibt an, wie lange eine Relay Beschreibung mindestens gültig ist
+const MIN_RELAY_DESCRIPTOR_TTL = 2 * time.Minute
+
+// Gibt an, wie lange eine Relay Beschreibung maximal gültig ist
+const MAX_RELAY_DESCRIPTOR_TTL = 24 * time.Hour
+
+// Gibt die tolerierte Abweichung der Uhrzeit zwischen zwei Relays an
+const RELAY_DESCRIPTOR_CLOCK_SKEW = 5 * time.Minute
+
+// Stellt einen Endpunkt einer Relay Beschreibung dar, 'Protocol' ist ein ipoverlay.ClientServerP2PProtocol Wert,
+// 'Module' gibt das Client Modul an, mit welchem der Endpunkt erreicht wird
+type RelayDescriptorEndPoint struct {
+	Protocol uint8  `cbor:"1,keyasint"`
+	Module   string `cbor:"2,keyasint"`
+	EndPoint string `cbor:"3,keyasint"`
+}
+
+// Stellt die Beschreibung eines Relays dar, diese wird vom beschriebenen Relay Signiert,
+// ist 'Expires' 0 läuft die Beschreibung nicht ab (z.b. bei einem Statischen Verzeichnis)
+type RelayDescriptor struct {
+	PublicKey []byte                    `cbor:"1,keyasint"`
+	EndPoints []RelayDescriptorEndPoint `cbor:"2,keyasint"`
+	Version   uint64                    `cbor:"3,keyasint"`
+	Seq       uint64                    `cbor:"4,keyasint"`
+	Expires   int64                     `cbor:"5,keyasint"`
+	Sig       []byte                    `cbor:"6,keyasint"`
+}
+
+// Gibt den Hash zurück, welcher vom Relay Signiert wird
+func (obj *RelayDescriptor) GetSignHash() ([]byte, error) {
+	// Die Beschreibung wird ohne Signatur in Bytes umgewandelt
+	unsigned := RelayDescriptor{PublicKey: obj.PublicKey, EndPoints: obj.EndPoints, Version: obj.Version, Seq: obj.Seq, Expires: obj.Expires}
+	encoded, err := cbor.Marshal(unsigned, cbor.EncOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("GetSignHash: " + err.Error())
+	}
+
+	// Der Hash wird erstellt
+	return utils.ComputeSha3256Hash([]byte("relay_descriptor"), encoded), nil
+}
+
+// Gibt den Öffentlichen Schlüssel des beschriebenen Relays zurück
+func (obj *RelayDescriptor) GetPublicKey() (*btcec.PublicKey, error) {
+	return btcec.ParsePubKey(obj.PublicKey)
+}
+
+// Gibt an ob die Beschreibung abgelaufen ist
+func (obj *RelayDescriptor) IsExpired() bool {
+	return obj.Expires != 0 && time.Now().Unix() > obj.Expires
+}
+
+// Gibt an ob die Beschreibung neuer als eine andere Beschreibung des selben Relays ist
+func (obj *RelayDescriptor) IsNewerThan(other *RelayDescriptor) bool {
+	return other == nil || obj.Seq > other.Seq
+}
+
+// Prüft ob die Beschreibung gültig, nicht abgelaufen und vom beschriebenen Relay Signiert ist, Beschreibungen
+// welche länger als die maximale Gültigkeitsdauer gültig sind werden abgelehnt
+func (obj *RelayDescriptor) Verify() error {
+	// Es wird geprüft ob die Beschreibung abgelaufen ist
+	if obj.Expires == 0 || obj.IsExpired() {
+		return fmt.Errorf("Verify: 1: descriptor expired")
+	}
+
+	// Es wird geprüft ob die Gültigkeitsdauer zulässig ist
+	if obj.Expires > time.Now().Add(MAX_RELAY_DESCRIPTOR_TTL+RELAY_DESCRIPTOR_CLOCK_SKEW).Unix() {
+		return fmt.Errorf("Verify: 2: descriptor lifetime too long")
+	}
+
+	// Der Öffentliche Schlüssel wird eingelesen
+	pkey, err := obj.GetPublicKey()
+	if err != nil {
+		return fmt.Errorf("Verify: 3: " + err.Error())
+	}
+
+	// Die Signatur wird geprüft
+	sign_hash, err := obj.GetSignHash()
+	if err != nil {
+		return fmt.Errorf("Verify: 4: " + err.Error())
+	}
+	is_valid, err := utils.VerifyByBytes(pkey, obj.Sig, sign_hash)
+	if err != nil || !is_valid {
+		return fmt.Errorf("Verify: 5: invalid descriptor signature")
+	}
+
+	// Die Beschreibung ist gültig
+	return nil
+}
+
+// Registriert ein Relay Verzeichnis, in welchem die eigene Beschreibung veröffentlicht und nach anderen Relays gesucht wird
+func (obj *Kernel) RegisterDirectoryService(service RelayDirectoryService) {
+	obj._lock.Lock()
+	obj._directory_services = append(obj._directory_services, service)
+	obj._lock.Unlock()
+	obj._log.Info("Kernel: relay directory registered", "name", service.GetDirectoryName())
+}
+
+// Gibt eine Kopie der Liste aller Relay Verzeichnisse zurück
+func (obj *Kernel) GetDirectoryServices() []RelayDirectoryService {
+	obj._lock.Lock()
+	result := make([]RelayDirectoryService, len(obj._directory_services))
+	copy(result, obj._directory_services)
+	obj._lock.Unlock()
+	return result
+}
+
+// Legt die Endpunkte fest, unter welchen das eigene Relay in den Verzeichnissen angekündigt wird
+func (obj *Kernel) SetRelayDescriptorEndPoints(end_points []RelayDescriptorEndPoint, ttl time.Duration) {
+	if ttl < MIN_RELAY_DESCRIPTOR_TTL {
+		ttl = MIN_RELAY_DESCRIPTOR_TTL
+	}
+	if ttl > MAX_RELAY_DESCRIPTOR_TTL {
+		ttl = MAX_RELAY_DESCRIPTOR_TTL
+	}
+	obj._lock.Lock()
+	obj._descriptor_end_points = end_points
+	obj._descriptor_ttl = ttl
+	obj._lock.Unlock()
+}
+
+// Gibt die Endpunkte zurück, unter welchen das eigene Relay in den Verzeichnissen angekündigt wird
+func (obj *Kernel) GetRelayDescriptorEndPoints() []RelayDescriptorEndPoint {
+	obj._lock.Lock()
+	defer obj._lock.Unlock()
+	result := make([]RelayDescriptorEndPoint, len(obj._descriptor_end_points))
+	copy(result, obj._descriptor_end_points)
+	return result
+}
+
+// Erstellt und Signiert die Beschreibung des eigenen Relays
+func (obj *Kernel) CreateRelayDescriptor() (*RelayDescriptor, error) {
+	// Die Endpunkte und die Gültigkeitsdauer werden abgerufen
+	obj._lock.Lock()
+	end_points := make([]RelayDescriptorEndPoint, len(obj._descriptor_end_points))
+	copy(end_points, obj._descriptor_end_points)
+	ttl := obj._descriptor_ttl
+	obj._lock.Unlock()
+
+	// Die Beschreibung wird gebaut
+	descriptor := &RelayDescriptor{
+		PublicKey: obj.GetPublicKey().SerializeCompressed(),
+		EndPoints: end_points,
+		Version:   RELAY_DESCRIPTOR_VERSION,
+		Seq:       uint64(time.Now().UnixNano()),
+		Expires:   time.Now().Add(ttl).Unix(),
+	}
+
+	// Die Beschreibung wird Signiert
+	sign_hash, err := descriptor.GetSignHash()
+	if err != nil {
+		return nil, fmt.Errorf("CreateRelayDescriptor: 1: " + err.Error())
+	}
+	descriptor.Sig, err = obj.SignWithRelayKey(sign_hash)
+	if err != nil {
+		return nil, fmt.Errorf("CreateRelayDescriptor: 2: " + err.Error())
+	}
+
+	// Die Beschreibung wird zurückgegeben
+	return descriptor, nil
+}
+
+// Veröffentlicht die Beschreibung des eigenen Relays in allen Verzeichnissen, ohne Endpunkte wird keine Beschreibung veröffentlicht
+func (obj *Kernel) PublishRelayDescriptor() error {
+	// Es wird geprüft ob Endpunkte vorhanden sind
+	obj._lock.Lock()
+	has_end_points := len(obj._descriptor_end_points) > 0
+	obj._lock.Unlock()
+	if !has_end_points {
+		return nil
+	}
+
+	// Die Beschreibung wird erstellt
+	descriptor, err := obj.CreateRelayDescriptor()
+	if err != nil {
+		return fmt.Errorf("PublishRelayDescriptor: 1: " + err.Error())
+	}
+
+	// Die Beschreibung wird in allen Verzeichnissen veröffentlicht
+	for _, service := range obj.GetDirectoryServices() {
+		if err := service.PublishDescriptor(descriptor); err != nil {
+			return fmt.Errorf("PublishRelayDescriptor: 2: " + service.GetDirectoryName() + ": " + err.Error())
+		}
+	}
+
+	// Log
+	obj._log.Debug("Kernel: relay descriptor published", "end_points", len(descriptor.EndPoints), "expires", descriptor.Expires)
+
+	// Der Vorgang wurde ohne Fehler durchgeführt
+	return nil
+}
+
+// Sucht in allen Verzeichnissen nach der Beschreibung eines Relays, es wird die neueste nicht abgelaufene Beschreibung zurückgegeben
+func (obj *Kernel) LookupRelayDescriptor(pkey *btcec.PublicKey) *RelayDescriptor {
+	var result *RelayDescriptor
+	for _, service := range obj.GetDirectoryServices() {
+		descriptor := service.LookupDescriptor(pkey)
+		if descriptor == nil || descriptor.IsExpired() {
+			continue
+		}
+		if descriptor.IsNewerThan(result) {
+			result = descriptor
+		}
+	}
+	return result
+}
+
+// Gibt die Beschreibungen aller Relays aus allen Verzeichnissen zurück, für jedes Relay wird nur die neueste Beschreibung zurückgegeben
+func (obj *Kernel) GetRelayDescriptors() []*RelayDescriptor {
+	// Die Beschreibungen aller Verzeichnisse werden zusammengeführt
+	own_key := obj.GetPublicKey().SerializeCompressed()
+	merged := make(map[string]*RelayDescriptor)
+	order := make([]string, 0)
+	for _, service := range obj.GetDirectoryServices() {
+		for _, descriptor := range service.ListDescriptors() {
+			if descriptor.IsExpired() || bytes.Equal(descriptor.PublicKey, own_key) {
+				continue
+			}
+			key := string(descriptor.PublicKey)
+			current, found := merged[key]
+			if !found {
+				order = append(order, key)
+			}
+			if descriptor.IsNewerThan(current) {
+				merged[key] = descriptor
+			}
+		}
+	}
+
+	// Die Beschreibungen werden zurückgegeben
+	result := make([]*RelayDescriptor, 0, len(order))
+	for _, key := range order {
+		result = append(result, merged[key])
+	}
+	return result
+}
+
+// Veröffentlicht die eigene Beschreibung beim Start sowie nach Ablauf der Hälfte ihrer Gültigkeitsdauer erneut, bis der Kernel beendet wurde
+func (obj *Kernel) _publish_relay_descriptors() {
+	for {
+		// Die Beschreibung wird veröffentlicht
+		if err := obj.PublishRelayDescriptor(); err != nil {
+			obj._log.Warn("Kernel: error by publishing relay descriptor", "error", err.Error())
+		}
+
+		// Es wird gewartet bis die Beschreibung erneut veröffentlicht wird
+		obj._lock.Lock()
+		wait := obj._descriptor_ttl / 2
+		obj._lock.Unlock()
+		timer := time.NewTimer(wait)
+		select {
+		case <-obj._ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package kernel
+
+import (
+	"container/list"
+	"encoding/hex"
+	"sync"
+	"time"
+)
+
+// Gibt an, wie lange sich ein Paket im Cache befindet, Pakete deren Zeitstempel weiter als diese Zeit
+// von der aktuellen Zeit abweicht, werden nicht angenommen
+const SEEN_PACKAGE_TTL = 30 * time.Second
+
+// Gibt an, wieviele Pakete maximal im Cache gespeichert werden, bei Überschreitung wird das am längsten nicht gesehene Paket entfernt
+const MAX_SEEN_PACKAGES = 131072
+
+// Stellt einen Eintrag im Cache dar
+type seen_package_entry struct {
+	key     string
+	expires time.Time
+}
+
+// Speichert die Hashes von bereits empfangenen Paketen für kurze Zeit ab
+type seen_package_cache struct {
+	_lock        *sync.Mutex
+	_entries     map[string]*list.Element
+	_order       *list.List
+	_ttl         time.Duration
+	_max_entries int
+	_last_purge  time.Time
+}
+
+// Entfernt einen Eintrag, muss mit Threadlock aufgerufen werden
+func (obj *seen_package_cache) _remove(element *list.Element) {
+	obj._order.Remove(element)
+	delete(obj._entries, element.Value.(*seen_package_entry).key)
+}
+
+// Entfernt alle abgelaufenen Einträge, muss mit Threadlock aufgerufen werden
+func (obj *seen_package_cache) _purge(now time.Time) {
+	for element := obj._order.Back(); element != nil; {
+		prev := element.Prev()
+		if now.After(element.Value.(*seen_package_entry).expires) {
+			obj._remove(element)
+		}
+		element = prev
+	}
+	obj._last_purge = now
+}
+
+// Gibt an ob der Zeitstempel eines Paketes innerhalb des Zeitfensters liegt, in welchem Duplikate erkannt werden
+func (obj *seen_package_cache) in_window(package_time time.Time) bool {
+	diff := time.Since(package_time)
+	return diff <= obj._ttl && diff >= -obj._ttl
+}
+
+// Gibt an ob ein Paket bereits empfangen wurde, sollte dies nicht der Fall sein wird es hinzugefügt,
+// der Eintrag bleibt bis zum Ende des Zeitfensters des Paketes erhalten
+func (obj *seen_package_cache) check_and_add(package_hash []byte, package_time time.Time) bool {
+	// Der Threadlock wird verwendet
+	obj._lock.Lock()
+	defer obj._lock.Unlock()
+
+	// Abgelaufene Einträge werden in regelmäßigen Abständen entfernt
+	now := time.Now()
+	if now.Sub(obj._last_purge) >= obj._ttl {
+		obj._purge(now)
+	}
+
+	// Es wird geprüft ob das Paket bereits bekannt ist, wenn ja wird es als zuletzt gesehen markiert
+	key := hex.EncodeToString(package_hash)
+	if element, found := obj._entries[key]; found {
+		if now.Before(element.Value.(*seen_package_entry).expires) {
+			obj._order.MoveToFront(element)
+			return true
+		}
+		obj._remove(element)
+	}
+
+	// Sollte der Cache voll sein, wird das am längsten nicht gesehene Paket entfernt
+	for obj._order.Len() >= obj._max_entries {
+		obj._remove(obj._order.Back())
+	}
+
+	// Das Paket wird hinzugefügt
+	obj._entries[key] = obj._order.PushFront(&seen_package_entry{key: key, expires: package_time.Add(obj._ttl)})
+	return false
+}
+
+// Erstellt einen neuen Cache für empfangene Pakete
+func new_seen_package_cache(ttl time.Duration, max_entries int) *seen_package_cache {
+	return &seen_package_cache{
+		_lock:        new(sync.Mutex),
+		_entries:     make(map[string]*list.Element),
+		_order:       list.New(),
+		_ttl:         ttl,
+		_max_entries: max_entries,
+		_last_purge:  time.Now(),
+	}
+}
@@ -0,0 +1,70 @@
+package kernel
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSeenPackageCacheDuplicate(t *testing.T) {
+	cache := new_seen_package_cache(SEEN_PACKAGE_TTL, 16)
+	now := time.Now()
+	if cache.check_and_add([]byte{1}, now) {
+		t.Fatal("new package reported as seen")
+	}
+	if !cache.check_and_add([]byte{1}, now) {
+		t.Error("duplicate package not detected")
+	}
+	if cache.check_and_add([]byte{2}, now) {
+		t.Error("other package reported as seen")
+	}
+}
+
+func TestSeenPackageCacheWindow(t *testing.T) {
+	cache := new_seen_package_cache(SEEN_PACKAGE_TTL, 16)
+	now := time.Now()
+	if !cache.in_window(now) || !cache.in_window(now.Add(-SEEN_PACKAGE_TTL/2)) || !cache.in_window(now.Add(SEEN_PACKAGE_TTL/2)) {
+		t.Error("package inside of window rejected")
+	}
+	if cache.in_window(now.Add(-2*SEEN_PACKAGE_TTL)) || cache.in_window(now.Add(2*SEEN_PACKAGE_TTL)) {
+		t.Error("package outside of window accepted")
+	}
+}
+
+func TestSeenPackageCacheExpiry(t *testing.T) {
+	cache := new_seen_package_cache(SEEN_PACKAGE_TTL, 16)
+
+	// Der Eintrag läuft mit dem Zeitfenster des Paketes ab, nicht mit dem Zeitpunkt des Empfangs
+	old := time.Now().Add(-SEEN_PACKAGE_TTL - time.Second)
+	cache.check_and_add([]byte{1}, old)
+	if cache.check_and_add([]byte{1}, old) {
+		t.Error("expired entry reported as seen")
+	}
+
+	// Abgelaufene Einträge werden beim Aufräumen entfernt
+	cache._purge(time.Now())
+	if cache._order.Len() != 0 || len(cache._entries) != 0 {
+		t.Errorf("cache contains %d entries after purge", cache._order.Len())
+	}
+}
+
+func TestSeenPackageCacheLRU(t *testing.T) {
+	cache := new_seen_package_cache(SEEN_PACKAGE_TTL, 2)
+	now := time.Now()
+	cache.check_and_add([]byte{1}, now)
+	cache.check_and_add([]byte{2}, now)
+
+	// Das erste Paket wird erneut gesehen, damit wird das zweite Paket zum ältesten Eintrag
+	if !cache.check_and_add([]byte{1}, now) {
+		t.Fatal("duplicate package not detected")
+	}
+	cache.check_and_add([]byte{3}, now)
+	if cache._order.Len() != 2 || len(cache._entries) != 2 {
+		t.Fatalf("cache contains %d entries, want 2", cache._order.Len())
+	}
+	if !cache.check_and_add([]byte{1}, now) {
+		t.Error("recently seen package was evicted")
+	}
+	if cache.check_and_add([]byte{2}, now) {
+		t.Error("least recently seen package was not evicted")
+	}
+}
@@ -1,93 +1,77 @@
 package kernel
 
 import (
-	"fmt"
-	"log"
 	"os"
 	"os/signal"
 	"syscall"
-	"time"
 )
 
-// Wird ausgeführt um ausgehende Verbindungen zu verwalten
-func manageOutboundConnection(k *Kernel, o RelayOutboundPair) {
-	// Es wird ein neues Client Modul erstellt
-	client_conn := *o.GetClientConnModule()
-
-	// Diese Schleife wird solange ausgeführt bis der Kernel beendet wurde
-	for k.IsRunning() {
-		// Es wird eine ausgehende Verbindung estellt
-		err := client_conn.ConnectTo(o.GetRelay().GetEndpoint(), o.GetRelay().GetPublicKey(), nil)
-		if err != nil {
-			log.Println("Outbound handler: " + err.Error())
-			k.ServKernel(2500)
-			continue
-		}
-
-		// Wird solange ausgeführt, bis die Verbindung geschlossen wurde
-		client_conn.Serve()
-
-		// Es wird 2 Sekunden geartet
-		k.ServKernel(2000)
-	}
-}
-
-// Verwaltet ausgehende Verbindungen
+// Verwaltet ausgehende Verbindungen, Änderungen an den Vertrauenswürdigen Relays werden durch SIGHUP oder die API übernommen
 func outboundHandler(core *Kernel) {
-	// Es wird versucht alle Ausgehenden Relays abzurufen
-	rt, err := core.ListOutboundTrustedAvaileRelays()
-	if err != nil {
-		panic(err)
-	}
-
-	// Es wird versucht mit jedem Relay eine verbindung aufzubauen
-	for _, o := range rt {
-		go manageOutboundConnection(core, o)
-	}
+	// Es werden Verbindungen zu allen Vertrauenswürdigen Relays aufgebaut
+	core._reconcile_outbound_connections()
 }
 
-// Händelt die System Events
+// Händelt die System Events, bis ein Signal zum Beenden eingetroffen ist oder der Kernel beendet wurde
 func handleSystemEvents(core *Kernel) {
 	// Erstelle einen Kanal, um Signale zu empfangen
 	sigChan := make(chan os.Signal, 1)
 
-	// Erlaube dem Kanal, die SIGINT- und SIGTERM-Signale zu empfangen
-	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+	// Erlaube dem Kanal, die SIGINT-, SIGTERM- und SIGHUP-Signale zu empfangen
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
+	defer signal.Stop(sigChan)
 
 	// Blockiere, bis ein Signal empfangen wird
-	sig := <-sigChan
+	for {
+		var sig os.Signal
+		select {
+		case sig = <-sigChan:
+		case <-core._ctx.Done():
+			return
+		}
 
-	// Es wird geprüft um welches Signal es sich handelt
-	switch sig {
-	case syscall.SIGINT:
-		core.Shutdown()
-	case syscall.SIGTERM:
-		fmt.Println("TERM")
-		break
-	default:
-		break
+		// Es wird geprüft um welches Signal es sich handelt
+		switch sig {
+		case syscall.SIGINT:
+			core.Shutdown()
+			return
+		case syscall.SIGHUP:
+			// Die Einstellungen und die Vertrauenswürdigen Relays werden neu geladen
+			if err := core.Reload(); err != nil {
+				core._log.Warn("Kernel: error by reloading", "error", err.Error())
+			}
+		case syscall.SIGTERM:
+			// Der Kernel wird in den Drain Modus versetzt, ein weiteres SIGINT beendet den Kernel sofort
+			go core.Drain(core.GetDrainTimeout())
+		default:
+		}
 	}
 }
 
-// Hält den Mainthread am leben bis das Programm ein Close Event bekommt
+// Hält den Mainthread am leben bis der Kernel vollständig heruntergefahren wurde
 func (obj *Kernel) Serve() error {
 	// Der Kernel wird gestartet
 	if err := obj.Start(); err != nil {
 		return err
 	}
 
-	// Der Thread welcher System Events verarbeitet wird gestartet
+	// Der Thread welcher System Events verarbeitet wird gestartet, er ruft selbst das Herunterfahren auf und wird daher nicht abgewartet
 	go handleSystemEvents(obj)
 
 	// Dieser Thread wird ausgeführt um die Ausgehenden Verbindungen vorzubereiten
-	go outboundHandler(obj)
+	obj._go(func() { outboundHandler(obj) })
 
-	// Diese Schleife wird solange ausgefürth, solange der Kernel ausgeführt wird
-	for range time.Tick(1 * time.Millisecond) {
-		if obj._is_full_closed() {
-			break
-		}
-	}
+	// Dieser Thread prüft das Ansehen der verbundenen Relays
+	obj._go(func() { obj._watch_relay_reputations() })
+
+	// Dieser Thread veröffentlicht die eigene Relay Beschreibung in den Verzeichnissen
+	obj._go(func() { obj._publish_relay_descriptors() })
+
+	// Dieser Thread baut Verbindungen zu bekannten Relays auf, bis ausreichend ausgehende Verbindungen bestehen
+	obj._go(func() { obj._maintain_outbound_peers() })
+
+	// Es wird gewartet bis der Kernel vollständig heruntergefahren wurde
+	<-obj._closed
 
 	// Der Vorgang wurde ohne Fehler durchgeführt
 	return nil
@@ -4,9 +4,10 @@ import (
 	"database/sql"
 	"encoding/hex"
 	"fmt"
-	"log"
+	"log/slog"
 	"sync"
 
+	"github.com/btcsuite/btcd/btcec/v2"
 	"github.com/fluffelpuff/RoueX/utils"
 	_ "github.com/mattn/go-sqlite3"
 )
@@ -15,10 +16,11 @@ type TrustedRelays struct {
 	_lock   *sync.Mutex
 	_relays []*Relay
 	_db     *sql.DB
+	_log    *slog.Logger
 }
 
 func (obj *TrustedRelays) Shutdown() {
-	log.Println("Shutingdown Trusted Relays database")
+	obj._log.Info("TrustedRelays: shutingdown trusted relays database")
 	obj._lock.Lock()
 	obj._db.Close()
 	obj._lock.Unlock()
@@ -31,7 +33,226 @@ func (obj *TrustedRelays) GetAllRelays() []*Relay {
 	return safe_copy
 }
 
-func loadTrustedRelaysTable(path string) (TrustedRelays, error) {
+// Gibt ein Relay anhand seines Öffentlichen Schlüssels zurück, auch deaktivierte Relays werden berücksichtigt
+func (obj *TrustedRelays) GetRelayByPublicKey(pkey *btcec.PublicKey) *Relay {
+	obj._lock.Lock()
+	defer obj._lock.Unlock()
+	for i := range obj._relays {
+		if obj._relays[i]._public_key.IsEqual(pkey) {
+			return obj._relays[i]
+		}
+	}
+	return nil
+}
+
+// Gibt an ob ein Relay noch in der Liste enthalten und aktiviert ist
+func (obj *TrustedRelays) IsActiveRelay(relay *Relay) bool {
+	obj._lock.Lock()
+	defer obj._lock.Unlock()
+	for i := range obj._relays {
+		if obj._relays[i] == relay {
+			return relay.IsActive()
+		}
+	}
+	return false
+}
+
+// Schreibt ein neues Relay in die Datenbank, der Threadlock muss bereits gesetzt sein
+func (obj *TrustedRelays) _insert_relay(pkey *btcec.PublicKey, end_point string, tpe string) (int64, string, error) {
+	// Es wird geprüft ob bereits ein Relay mit diesem Schlüssel vorhanden ist
+	for i := range obj._relays {
+		if obj._relays[i]._public_key.IsEqual(pkey) {
+			return 0, "", fmt.Errorf("_insert_relay: 1: relay always known")
+		}
+	}
+
+	// Das Relay wird in die Datenbank geschrieben
+	hx_id := utils.RandStringRunes(16)
+	result, err := obj._db.Exec("INSERT INTO relays (hx_id, type, end_point, last_used, active, public_key) VALUES (?, ?, ?, -1, 1, ?)", hx_id, tpe, end_point, hex.EncodeToString(pkey.SerializeCompressed()))
+	if err != nil {
+		return 0, "", fmt.Errorf("_insert_relay: 2: " + err.Error())
+	}
+	db_id, err := result.LastInsertId()
+	if err != nil {
+		return 0, "", fmt.Errorf("_insert_relay: 3: " + err.Error())
+	}
+
+	// Die IDs werden zurückgegeben
+	return db_id, hx_id, nil
+}
+
+// Fügt ein neues Vertrauenswürdiges Relay hinzu
+func (obj *TrustedRelays) AddRelay(pkey *btcec.PublicKey, end_point string, tpe string) (*Relay, error) {
+	obj._lock.Lock()
+	defer obj._lock.Unlock()
+
+	// Das Relay wird in die Datenbank geschrieben
+	db_id, hx_id, err := obj._insert_relay(pkey, end_point, tpe)
+	if err != nil {
+		return nil, fmt.Errorf("AddRelay: " + err.Error())
+	}
+
+	// Das Relay wird zwischengespeichert
+	new_relay := &Relay{_lock: new(sync.RWMutex), _db_id: db_id, _hexed_id: hx_id, _type: tpe, _end_point: end_point, _last_used: 0, _active: true, _public_key: pkey, _trusted: true}
+	obj._relays = append(obj._relays, new_relay)
+
+	// Das Relay wird zurückgegeben
+	return new_relay, nil
+}
+
+// Übernimmt ein bestehendes nicht Vertrauenswürdiges Relay, das Objekt wird als Vertrauenswürdig markiert und weiterverwendet,
+// so bleiben die bestehenden Verbindungen des Relays erhalten
+func (obj *TrustedRelays) AdoptRelay(relay *Relay, end_point string, tpe string) error {
+	obj._lock.Lock()
+	defer obj._lock.Unlock()
+
+	// Das Relay wird in die Datenbank geschrieben
+	db_id, hx_id, err := obj._insert_relay(relay._public_key, end_point, tpe)
+	if err != nil {
+		return fmt.Errorf("AdoptRelay: " + err.Error())
+	}
+
+	// Die Werte werden im Relay aktualisiert und das Relay wird zwischengespeichert
+	relay._set_trusted(db_id, hx_id, end_point, tpe)
+	obj._relays = append(obj._relays, relay)
+
+	// Der Vorgang wurde ohne Fehler durchgeführt
+	return nil
+}
+
+// Entfernt ein Vertrauenswürdiges Relay
+func (obj *TrustedRelays) RemoveRelay(relay *Relay) error {
+	obj._lock.Lock()
+	defer obj._lock.Unlock()
+
+	// Das Relay wird aus der Datenbank entfernt
+	if _, err := obj._db.Exec("DELETE FROM relays WHERE rid = ?", relay._get_db_id()); err != nil {
+		return fmt.Errorf("RemoveRelay: 1: " + err.Error())
+	}
+
+	// Das Relay wird aus der Liste entfernt
+	updated := make([]*Relay, 0, len(obj._relays))
+	for i := range obj._relays {
+		if obj._relays[i] != relay {
+			updated = append(updated, obj._relays[i])
+		}
+	}
+	obj._relays = updated
+
+	// Der Vorgang wurde ohne Fehler durchgeführt
+	return nil
+}
+
+// Aktiviert oder Deaktiviert ein Vertrauenswürdiges Relay
+func (obj *TrustedRelays) SetRelayActive(relay *Relay, active bool) error {
+	obj._lock.Lock()
+	defer obj._lock.Unlock()
+
+	// Der Status wird in der Datenbank aktualisiert
+	db_active := 0
+	if active {
+		db_active = 1
+	}
+	if _, err := obj._db.Exec("UPDATE relays SET active = ? WHERE rid = ?", db_active, relay._get_db_id()); err != nil {
+		return fmt.Errorf("SetRelayActive: 1: " + err.Error())
+	}
+
+	// Der Status wird im Relay aktualisiert
+	relay._set_active(active)
+
+	// Der Vorgang wurde ohne Fehler durchgeführt
+	return nil
+}
+
+// Aktualisiert den Endpunkt und das Protokoll eines Vertrauenswürdigen Relays, leere Werte bleiben unverändert
+func (obj *TrustedRelays) UpdateRelayEndpoint(relay *Relay, end_point string, tpe string) error {
+	obj._lock.Lock()
+	defer obj._lock.Unlock()
+
+	// Die nicht angegebenen Werte werden übernommen
+	if len(end_point) == 0 {
+		end_point = relay.GetEndpoint()
+	}
+	if len(tpe) == 0 {
+		tpe = relay.GetProtocol()
+	}
+
+	// Die Werte werden in der Datenbank aktualisiert
+	if _, err := obj._db.Exec("UPDATE relays SET end_point = ?, type = ? WHERE rid = ?", end_point, tpe, relay._get_db_id()); err != nil {
+		return fmt.Errorf("UpdateRelayEndpoint: 1: " + err.Error())
+	}
+
+	// Die Werte werden im Relay aktualisiert
+	relay._set_end_point(end_point, tpe)
+
+	// Der Vorgang wurde ohne Fehler durchgeführt
+	return nil
+}
+
+// Liest alle Relays aus der Datenbank aus
+func _read_trusted_relays(db *sql.DB) ([]*Relay, error) {
+	re_relays := make([]*Relay, 0)
+
+	// Es werden alle Verfügabren Relays abgerufen
+	rows, err := db.Query("SELECT * FROM relays")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	// Die Einzelnen Relays werden abgerbeietet
+	for rows.Next() {
+		// Die Daten des Aktuellen Relays werden ausgelesen
+		var db_uid int64
+		var db_hex_id string
+		var db_type string
+		var end_point string
+		var last_used int64
+		var active int64
+		var public_key string
+		err := rows.Scan(&db_uid, &db_hex_id, &db_type, &end_point, &last_used, &active, &public_key)
+		if err != nil {
+			return nil, err
+		}
+
+		// Es wird geprüft ob die Verbindung aktiv ist
+		is_active := false
+		if active == 1 {
+			is_active = true
+		}
+
+		// Es wird versucht den Öffentlichen Schlüssel einzulesn
+		decoded_pkey, err := hex.DecodeString(public_key)
+		if err != nil {
+			return nil, err
+		}
+		pkey, err := utils.ReadPublicKeyFromByteSlice(decoded_pkey)
+		if err != nil {
+			return nil, err
+		}
+
+		// Das Objekt wird wieder hergestellt
+		retrived_relay := Relay{
+			_lock:       new(sync.RWMutex),
+			_db_id:      db_uid,
+			_hexed_id:   db_hex_id,
+			_type:       db_type,
+			_end_point:  end_point,
+			_last_used:  uint64(last_used),
+			_active:     is_active,
+			_public_key: pkey,
+			_trusted:    true,
+		}
+
+		// Die Verbindung wird zwischen gespeichert
+		re_relays = append(re_relays, &retrived_relay)
+	}
+
+	// Die Relays werden zurückgegeben
+	return re_relays, nil
+}
+
+func loadTrustedRelaysTable(path string, logger *slog.Logger) (TrustedRelays, error) {
 	// Es wird versucht die SQLite Datei zu laden
 	db, err := sql.Open("sqlite3", path)
 	if err != nil {
@@ -39,7 +260,7 @@ func loadTrustedRelaysTable(path string) (TrustedRelays, error) {
 	}
 
 	// Log
-	fmt.Printf("Loading Trusted Relays database from %s...\n", path)
+	logger.Info("TrustedRelays: loading trusted relays database", "path", path)
 
 	// Die Anzahl der Tabellen mit dem Namen relays wird abgerufen
 	var count int
@@ -70,64 +291,109 @@ func loadTrustedRelaysTable(path string) (TrustedRelays, error) {
 		if err != nil {
 			return TrustedRelays{}, err
 		}
-		fmt.Printf("New Trusted Relays Database created %s\n", path)
+		logger.Info("TrustedRelays: new trusted relays database created", "path", path)
 	} else {
 		// Es werden alle Verfügabren Relays abgerufen
-		rows, err := db.Query("SELECT * FROM relays WHERE active = 1")
+		re_relays, err = _read_trusted_relays(db)
 		if err != nil {
 			return TrustedRelays{}, err
 		}
 
-		// Die Einzelnen Relays werden abgerbeietet
-		for rows.Next() {
-			// Die Daten des Aktuellen Relays werden ausgelesen
-			var db_uid int64
-			var db_hex_id string
-			var db_type string
-			var end_point string
-			var last_used int64
-			var active int64
-			var public_key string
-			err := rows.Scan(&db_uid, &db_hex_id, &db_type, &end_point, &last_used, &active, &public_key)
-			if err != nil {
-				return TrustedRelays{}, err
-			}
-
-			// Es wird geprüft ob die Verbindung aktiv ist
-			is_active := false
-			if active == 1 {
-				is_active = true
-			}
-
-			// Es wird versucht den Öffentlichen Schlüssel einzulesn
-			decoded_pkey, err := hex.DecodeString(public_key)
-			if err != nil {
-				return TrustedRelays{}, err
-			}
-			pkey, err := utils.ReadPublicKeyFromByteSlice(decoded_pkey)
-			if err != nil {
-				return TrustedRelays{}, err
-			}
-
-			// Das Objekt wird wieder hergestellt
-			retrived_relay := Relay{
-				_db_id:      db_uid,
-				_hexed_id:   db_hex_id,
-				_type:       db_type,
-				_end_point:  end_point,
-				_last_used:  uint64(last_used),
-				_active:     is_active,
-				_public_key: pkey,
-				_trusted:    true,
-			}
-
-			// Die Verbindung wird zwischen gespeichert
-			re_relays = append(re_relays, &retrived_relay)
-		}
-
-		fmt.Printf("Trusted Relays from database loaded, total = %d, path = %s\n", len(re_relays), path)
+		logger.Info("TrustedRelays: trusted relays from database loaded", "total", len(re_relays), "path", path)
 	}
 
 	// Die Daten werden ohne Fehler zurückgegeben
-	return TrustedRelays{_relays: re_relays, _db: db, _lock: new(sync.Mutex)}, nil
+	return TrustedRelays{_relays: re_relays, _db: db, _lock: new(sync.Mutex), _log: logger}, nil
+}
+
+// Lädt alle Relays erneut aus der Datenbank, unveränderte Relays behalten ihr Objekt. Es werden alle Relays
+// zurückgegeben, deren Endpunkt, Protokoll oder Schlüssel verändert wurde
+func (obj *TrustedRelays) Reload() ([]*Relay, error) {
+	obj._lock.Lock()
+	defer obj._lock.Unlock()
+
+	// Die Relays werden aus der Datenbank gelesen
+	loaded, err := _read_trusted_relays(obj._db)
+	if err != nil {
+		return nil, fmt.Errorf("Reload: " + err.Error())
+	}
+
+	// Die bekannten Relays werden anhand ihrer Datenbank ID zugeordnet
+	known := make(map[int64]*Relay)
+	for i := range obj._relays {
+		known[obj._relays[i]._get_db_id()] = obj._relays[i]
+	}
+
+	// Die Relays werden abgeglichen
+	changed := make([]*Relay, 0)
+	updated := make([]*Relay, 0, len(loaded))
+	for _, item := range loaded {
+		// Sollte das Relay noch nicht bekannt sein, wird es hinzugefügt
+		current, found := known[item._db_id]
+		if !found {
+			updated = append(updated, item)
+			continue
+		}
+
+		// Sollte sich der Schlüssel geändert haben, wird das neue Objekt verwendet
+		if !current._public_key.IsEqual(item._public_key) {
+			updated = append(updated, item)
+			continue
+		}
+
+		// Die übrigen Werte werden übernommen
+		if current.GetEndpoint() != item._end_point || current.GetProtocol() != item._type {
+			changed = append(changed, current)
+		}
+		current._set_end_point(item._end_point, item._type)
+		current._set_active(item._active)
+		updated = append(updated, current)
+	}
+	obj._relays = updated
+
+	// Die Veränderten Relays werden zurückgegeben
+	return changed, nil
+}
+
+// Aktualisiert den gepinnten Öffentlichen Schlüssel eines Relays, bestehende Verbindungen behalten das alte Relay Objekt.
+// Sollte bereits ein nicht Vertrauenswürdiges Relay mit dem neuen Schlüssel verbunden sein, wird dieses übernommen,
+// so bleiben seine Verbindungen erhalten
+func (obj *TrustedRelays) UpdatePinnedKey(relay *Relay, new_key *btcec.PublicKey, connected *Relay) (*Relay, error) {
+	obj._lock.Lock()
+	defer obj._lock.Unlock()
+
+	// Es wird geprüft ob der neue Schlüssel bereits einem anderen Relay zugeordnet ist
+	for i := range obj._relays {
+		if obj._relays[i]._public_key.IsEqual(new_key) {
+			return nil, fmt.Errorf("UpdatePinnedKey: 1: new key already pinned to another relay")
+		}
+	}
+
+	// Der Schlüssel wird in der Datenbank aktualisiert
+	_, err := obj._db.Exec("UPDATE relays SET public_key = ? WHERE rid = ?", hex.EncodeToString(new_key.SerializeCompressed()), relay._get_db_id())
+	if err != nil {
+		return nil, fmt.Errorf("UpdatePinnedKey: 2: " + err.Error())
+	}
+
+	// Das Relay wird durch das verbundene Relay oder ein neues Objekt mit dem neuen Schlüssel ersetzt
+	var updated *Relay
+	if connected != nil && connected._public_key.IsEqual(new_key) && !connected.IsTrusted() {
+		values := relay._snapshot()
+		connected._set_trusted(values._db_id, values._hexed_id, values._end_point, values._type)
+		connected._set_active(values._active)
+		updated = connected
+	} else {
+		values := relay._snapshot()
+		values._public_key = new_key
+		updated = &values
+	}
+	for i := range obj._relays {
+		if obj._relays[i] == relay {
+			obj._relays[i] = updated
+			break
+		}
+	}
+
+	// Das neue Relay Objekt wird zurückgegeben
+	return updated, nil
 }
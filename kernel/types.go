@@ -3,6 +3,7 @@ package kernel
 import (
 	"github.com/btcsuite/btcd/btcec/v2"
 	addresspackages "github.com/fluffelpuff/RoueX/address_packages"
+	"github.com/fluffelpuff/RoueX/firewall"
 	"github.com/fluffelpuff/RoueX/kernel/extra"
 )
 
@@ -32,15 +33,15 @@ type ClientModule interface {
 	GetObjectId() string
 	RegisterKernel(kernel *Kernel) error
 	GetMetaDataInfo() ClientModuleMetaData
-	ConnectTo(string, *btcec.PublicKey, *ProxyConfig) error
+	ConnectTo(string, *btcec.PublicKey, *ProxyConfig) (RelayConnection, error)
 	GetProtocol() string
 	Shutdown()
-	Serve()
 }
 
 // Stellt eine Verbindung dar
 type RelayConnection interface {
 	EnterSendableData([]byte) (*extra.PackageSendState, error)
+	EnterSendableControlData([]byte) (*extra.PackageSendState, error)
 	GetSessionPKey() (*btcec.PublicKey, error)
 	RegisterKernel(kernel *Kernel) error
 	GetTxRxBytes() (uint64, uint64)
@@ -51,7 +52,10 @@ type RelayConnection interface {
 	GetObjectId() string
 	FinallyInit() error
 	IsConnected() bool
+	Done() <-chan struct{}
 	IsFinally() bool
+	HasPendingWrites() bool
+	GetWriteBufferUsage() (uint64, uint64)
 	CloseByKernel()
 }
 
@@ -70,13 +74,41 @@ type KernelTypeProtocol interface {
 	GetObjectId() string
 }
 
+// Stellt einen Beobachter dar, welcher über neue und getrennte Relays informiert wird
+type RelayStateObserver interface {
+	RelayConnected(*Relay)
+	RelayDisconnected(*Relay, []*btcec.PublicKey)
+}
+
+// Stellt ein Protokoll dar, welches informiert wird sobald der Kernel in den Drain Modus wechselt,
+// so können die Nachbarn über das Verlassen des Netzwerkes informiert werden
+type KernelDrainObserver interface {
+	KernelDraining()
+}
+
+// Stellt ein Protokoll dar, dessen Pakete als Steuerpakete getrennt von den Datenpaketen übertragen werden,
+// so müssen z.b. Routen Ankündigungen nicht hinter großen Datenpaketen warten
+type ControlTypeProtocol interface {
+	IsControlProtocol() bool
+}
+
 // Stellt die Basisfunktionen einer Firewall dar
 type FirewallBaseStructure interface {
+	CheckPackage(*firewall.PackageContext) bool
+	CheckInboundHandshake(*btcec.PublicKey) bool
+	AddRule(firewall.Rule) (int64, error)
+	RemoveRule(int64) error
+	GetAllRules() []*firewall.Rule
+	GetTotalDropedPackages() uint64
+	Shutdown()
 }
 
 // Stellt ein Relay Diretory Service bereit
 type RelayDirectoryService interface {
-	// Future
+	GetDirectoryName() string
+	PublishDescriptor(*RelayDescriptor) error
+	LookupDescriptor(*btcec.PublicKey) *RelayDescriptor
+	ListDescriptors() []*RelayDescriptor
 }
 
 // Stellt ein Möglichen P2P Server Port und Protokoll verfügbar
@@ -95,6 +127,8 @@ type RelayConnectionMetaData struct {
 	TxBytes         uint64
 	RxBytes         uint64
 	Ping            uint64
+	BufferPackages  uint64
+	BufferBytes     uint64
 }
 
 // Stellt die MetaDaten dar
@@ -0,0 +1,308 @@
+package kernel
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+)
+
+// Gibt an, wie nicht Vertrauenswürdige Relays zugelassen werden
+type UntrustedAdmissionMode uint8
+
+// Definiert die Zulassungsarten für nicht Vertrauenswürdige Relays
+const (
+	UNTRUSTED_ADMISSION_ALL       = UntrustedAdmissionMode(1)
+	UNTRUSTED_ADMISSION_ALLOWLIST = UntrustedAdmissionMode(2)
+	UNTRUSTED_ADMISSION_NONE      = UntrustedAdmissionMode(3)
+)
+
+// Gibt die Grenzwerte des Ansehens eines Relays an
+const (
+	REPUTATION_MIN = int64(-100)
+	REPUTATION_MAX = int64(100)
+)
+
+// Gibt an, wie stark sich die einzelnen Ereignisse auf das Ansehen eines Relays auswirken
+const (
+	REPUTATION_HANDSHAKE       = int64(5)
+	REPUTATION_PING_STABLE     = int64(1)
+	REPUTATION_PING_UNSTABLE   = int64(-2)
+	REPUTATION_PING_MISSING    = int64(-5)
+	REPUTATION_INVALID_PACKAGE = int64(-10)
+)
+
+// Gibt an, ab welcher Abweichung (ms) zum vorherigen Ping eine Verbindung als nicht stabil gilt, sofern die Hälfte des vorherigen Pings geringer ist
+const REPUTATION_PING_JITTER_MS = 50
+
+// Gibt an, in welchem Abstand das Ansehen der verbundenen Relays geprüft wird
+const REPUTATION_CHECK_INTERVAL = 30 * time.Second
+
+// Stellt die Richtlinie für die Zulassung nicht Vertrauenswürdiger Relays dar,
+// ist 'MaxSlots' 0 ist die Anzahl nicht begrenzt, Relays deren Ansehen 'EvictScore' erreicht werden getrennt
+type UntrustedRelayPolicy struct {
+	Mode       UntrustedAdmissionMode
+	Allowlist  []*btcec.PublicKey
+	MaxSlots   uint
+	EvictScore int64
+}
+
+// Gibt die Standard Richtlinie für nicht Vertrauenswürdige Relays an
+var DEFAULT_UNTRUSTED_RELAY_POLICY = UntrustedRelayPolicy{Mode: UNTRUSTED_ADMISSION_ALL, MaxSlots: 32, EvictScore: -50}
+
+// Stellt das Ansehen eines Relays dar
+type relay_reputation struct {
+	score     int64
+	last_ping uint64
+}
+
+// Ließt die Zulassungsart für nicht Vertrauenswürdige Relays ein
+func ParseUntrustedAdmissionMode(value string) (UntrustedAdmissionMode, error) {
+	switch strings.ToLower(value) {
+	case "all":
+		return UNTRUSTED_ADMISSION_ALL, nil
+	case "allowlist":
+		return UNTRUSTED_ADMISSION_ALLOWLIST, nil
+	case "none":
+		return UNTRUSTED_ADMISSION_NONE, nil
+	default:
+		return 0, fmt.Errorf("ParseUntrustedAdmissionMode: unkown admission mode " + value)
+	}
+}
+
+// Gibt die Zulassungsart als Text zurück
+func (obj UntrustedAdmissionMode) String() string {
+	switch obj {
+	case UNTRUSTED_ADMISSION_ALL:
+		return "all"
+	case UNTRUSTED_ADMISSION_ALLOWLIST:
+		return "allowlist"
+	case UNTRUSTED_ADMISSION_NONE:
+		return "none"
+	default:
+		return "unkown"
+	}
+}
+
+// Legt die Richtlinie für nicht Vertrauenswürdige Relays fest
+func (obj *Kernel) SetUntrustedRelayPolicy(policy UntrustedRelayPolicy) {
+	obj._lock.Lock()
+	obj._untrusted_policy = policy
+	obj._lock.Unlock()
+
+	// Log
+	obj._log.Info("Kernel: untrusted relay policy set", "mode", policy.Mode.String(), "allowlist", len(policy.Allowlist), "max_slots", policy.MaxSlots, "evict_score", policy.EvictScore)
+}
+
+// Gibt die Richtlinie für nicht Vertrauenswürdige Relays zurück
+func (obj *Kernel) GetUntrustedRelayPolicy() UntrustedRelayPolicy {
+	obj._lock.Lock()
+	defer obj._lock.Unlock()
+	return obj._untrusted_policy
+}
+
+// Gibt das Ansehen eines Relays zurück, unbekannte Relays haben ein Ansehen von 0
+func (obj *Kernel) GetRelayReputation(pkey *btcec.PublicKey) int64 {
+	obj._lock.Lock()
+	defer obj._lock.Unlock()
+	entry, found := obj._reputations[hex.EncodeToString(pkey.SerializeCompressed())]
+	if !found {
+		return 0
+	}
+	return entry.score
+}
+
+// Verändert das Ansehen eines Relays, der Wert wird auf die Grenzwerte beschränkt
+func (obj *Kernel) _change_relay_reputation(pkey *btcec.PublicKey, delta int64, reason string) int64 {
+	// Der Eintrag wird abgerufen oder erstellt
+	hexed_pkey := hex.EncodeToString(pkey.SerializeCompressed())
+	obj._lock.Lock()
+	entry, found := obj._reputations[hexed_pkey]
+	if !found {
+		entry = &relay_reputation{}
+		obj._reputations[hexed_pkey] = entry
+	}
+
+	// Der Wert wird angepasst
+	entry.score += delta
+	if entry.score > REPUTATION_MAX {
+		entry.score = REPUTATION_MAX
+	} else if entry.score < REPUTATION_MIN {
+		entry.score = REPUTATION_MIN
+	}
+	score := entry.score
+	obj._lock.Unlock()
+
+	// Log
+	obj._log.Debug("Kernel: relay reputation changed", "relay", hexed_pkey, "delta", delta, "score", score, "reason", reason)
+
+	// Der neue Wert wird zurückgegeben
+	return score
+}
+
+// Vermerkt ein ungültiges Paket, welches über eine Verbindung empfangen wurde
+func (obj *Kernel) ReportInvalidPackage(conn RelayConnection) {
+	// Das Relay der Verbindung wird ermittelt
+	relay, found, err := obj._connection_manager.GetRelayByConnection(conn)
+	if err != nil || !found {
+		return
+	}
+
+	// Das Ansehen des Relays wird verringert
+	obj._change_relay_reputation(relay.GetPublicKey(), REPUTATION_INVALID_PACKAGE, "invalid package")
+}
+
+// Prüft ob ein nicht Vertrauenswürdiges Relay zugelassen wird, sollten alle Plätze belegt sein,
+// wird das Relay mit dem schlechtesten Ansehen getrennt, sofern es schlechter als das neue Relay ist
+func (obj *Kernel) _admit_untrusted_relay(relay *Relay) error {
+	// Sollten bereits Verbindungen mit dem Relay bestehen, belegt es bereits einen Platz
+	if obj._connection_manager.GetRelayByPublicKey(relay.GetPublicKey()) != nil {
+		return nil
+	}
+
+	// Es wird geprüft ob das Relay durch die Zulassungsart erlaubt ist
+	policy := obj.GetUntrustedRelayPolicy()
+	switch policy.Mode {
+	case UNTRUSTED_ADMISSION_ALL:
+	case UNTRUSTED_ADMISSION_ALLOWLIST:
+		allowed := false
+		for i := range policy.Allowlist {
+			if bytes.Equal(policy.Allowlist[i].SerializeCompressed(), relay.GetPublicKey().SerializeCompressed()) {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return fmt.Errorf("_admit_untrusted_relay: 1: relay is not on the allowlist")
+		}
+	default:
+		return fmt.Errorf("_admit_untrusted_relay: 2: untrusted relays are not allowed")
+	}
+
+	// Es wird geprüft ob das Ansehen des Relays ausreichend ist
+	score := obj.GetRelayReputation(relay.GetPublicKey())
+	if score <= policy.EvictScore {
+		return fmt.Errorf("_admit_untrusted_relay: 3: relay reputation too low")
+	}
+
+	// Es wird geprüft ob noch ein Platz frei ist
+	untrusted_relays := obj._connection_manager.GetUntrustedRelays()
+	if policy.MaxSlots == 0 || uint(len(untrusted_relays)) < policy.MaxSlots {
+		return nil
+	}
+
+	// Das Relay mit dem schlechtesten Ansehen wird ermittelt
+	var worst_relay *Relay
+	worst_score := REPUTATION_MAX
+	for _, item := range untrusted_relays {
+		if item_score := obj.GetRelayReputation(item.GetPublicKey()); worst_relay == nil || item_score < worst_score {
+			worst_relay, worst_score = item, item_score
+		}
+	}
+
+	// Sollte das schlechteste Relay kein schlechteres Ansehen als das neue Relay haben, wird das neue Relay abgelehnt
+	if worst_relay == nil || worst_score >= score {
+		return fmt.Errorf("_admit_untrusted_relay: 4: no free untrusted relay slot")
+	}
+
+	// Das schlechteste Relay wird getrennt
+	obj._log.Info("Kernel: untrusted relay evicted", "relay", worst_relay.GetPublicKeyHexString(), "score", worst_score, "reason", "slot required")
+	obj._connection_manager.CloseRelayConnections(worst_relay)
+
+	// Das neue Relay wird zugelassen
+	return nil
+}
+
+// Bewertet die Ping Stabilität aller verbundenen Relays und trennt nicht Vertrauenswürdige Relays deren Ansehen zu gering ist
+func (obj *Kernel) _check_relay_reputations() {
+	// Die Metadaten aller Relays werden abgerufen
+	meta_datas, err := obj._connection_manager.GetAllRelaysMetaData()
+	if err != nil {
+		return
+	}
+
+	// Die Ping Stabilität der verbundenen Relays wird bewertet
+	connected := make(map[string]bool)
+	for _, meta_data := range meta_datas {
+		if !meta_data.IsConnected {
+			continue
+		}
+		connected[meta_data.PublicKey] = true
+
+		// Der vorherige Ping wird abgerufen und durch den aktuellen ersetzt
+		obj._lock.Lock()
+		entry, found := obj._reputations[meta_data.PublicKey]
+		if !found {
+			entry = &relay_reputation{}
+			obj._reputations[meta_data.PublicKey] = entry
+		}
+		last_ping := entry.last_ping
+		entry.last_ping = meta_data.PingMS
+		obj._lock.Unlock()
+
+		// Der Öffentliche Schlüssel wird eingelesen
+		decoded, err := hex.DecodeString(meta_data.PublicKey)
+		if err != nil {
+			continue
+		}
+		pkey, err := btcec.ParsePubKey(decoded)
+		if err != nil {
+			continue
+		}
+
+		// Die Stabilität wird anhand der Abweichung zum vorherigen Ping ermittelt
+		jitter := uint64(REPUTATION_PING_JITTER_MS)
+		if last_ping/2 > jitter {
+			jitter = last_ping / 2
+		}
+		switch {
+		case meta_data.PingMS == 0:
+			obj._change_relay_reputation(pkey, REPUTATION_PING_MISSING, "ping missing")
+		case last_ping != 0 && (meta_data.PingMS > last_ping+jitter || meta_data.PingMS+jitter < last_ping):
+			obj._change_relay_reputation(pkey, REPUTATION_PING_UNSTABLE, "ping unstable")
+		default:
+			obj._change_relay_reputation(pkey, REPUTATION_PING_STABLE, "ping stable")
+		}
+	}
+
+	// Nicht Vertrauenswürdige Relays deren Ansehen zu gering ist werden getrennt
+	policy := obj.GetUntrustedRelayPolicy()
+	for _, relay := range obj._connection_manager.GetUntrustedRelays() {
+		if score := obj.GetRelayReputation(relay.GetPublicKey()); score <= policy.EvictScore {
+			obj._log.Info("Kernel: untrusted relay evicted", "relay", relay.GetPublicKeyHexString(), "score", score, "reason", "reputation too low")
+			obj._connection_manager.CloseRelayConnections(relay)
+		}
+	}
+
+	// Das Ansehen nicht verbundener Relays wird entfernt, ein zu geringes Ansehen nähert sich langsam wieder dem Grenzwert an,
+	// so wird das Relay erst nach einiger Zeit wieder zugelassen
+	obj._lock.Lock()
+	for hexed_pkey, entry := range obj._reputations {
+		if connected[hexed_pkey] {
+			continue
+		}
+		if entry.score > policy.EvictScore {
+			delete(obj._reputations, hexed_pkey)
+		} else {
+			entry.score++
+		}
+	}
+	obj._lock.Unlock()
+}
+
+// Prüft in regelmäßigen Abständen das Ansehen der verbundenen Relays, bis der Kernel beendet wurde
+func (obj *Kernel) _watch_relay_reputations() {
+	ticker := time.NewTicker(REPUTATION_CHECK_INTERVAL)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-obj._ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		obj._check_relay_reputations()
+	}
+}
@@ -0,0 +1,189 @@
+package keystore
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/scrypt"
+)
+
+// Gibt die Version des Verschlüsselten Schlüsseldatei Formates an
+const KEYFILE_VERSION = 1
+
+// Gibt die Parameter an, welche für neue Schlüsseldateien verwendet werden
+const (
+	SCRYPT_N      = 1 << 15
+	SCRYPT_R      = 8
+	SCRYPT_P      = 1
+	SCRYPT_KEYLEN = 32
+	SALT_SIZE     = 32
+)
+
+// Gibt die maximalen Parameter an, welche beim Einlesen einer Schlüsseldatei akzeptiert werden,
+// so kann eine veränderte Datei keine unbegrenzte Rechenzeit oder Speichermenge (128 * N * R Bytes) anfordern
+const (
+	SCRYPT_MAX_N = 1 << 20
+	SCRYPT_MAX_R = 8
+	SCRYPT_MAX_P = 4
+)
+
+// Gibt die Dateiberechtigungen an, welche eine Schlüsseldatei haben muss
+const KEYFILE_MODE os.FileMode = 0600
+
+// Stellt eine Verschlüsselte Schlüsseldatei dar
+type encrypted_keyfile struct {
+	Version    int    `json:"version"`
+	KDF        string `json:"kdf"`
+	N          int    `json:"n"`
+	R          int    `json:"r"`
+	P          int    `json:"p"`
+	Salt       string `json:"salt"`
+	Cipher     string `json:"cipher"`
+	Nonce      string `json:"nonce"`
+	CipherText string `json:"ciphertext"`
+}
+
+// Gibt an ob es sich um eine alte, nicht Verschlüsselte Schlüsseldatei handelt
+func isPlainHexKeyfile(content []byte) bool {
+	trimmed := strings.TrimSpace(string(content))
+	if len(trimmed) != 64 {
+		return false
+	}
+	_, err := hex.DecodeString(trimmed)
+	return err == nil
+}
+
+// Verschlüsselt einen Privaten Schlüssel mit einer Passphrase
+func encryptKeyfile(priv_key []byte, passphrase []byte) ([]byte, error) {
+	// Das Salt wird erzeugt
+	salt := make([]byte, SALT_SIZE)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, fmt.Errorf("encryptKeyfile: 1: " + err.Error())
+	}
+
+	// Der Schlüssel wird aus der Passphrase abgeleitet
+	derived, err := scrypt.Key(passphrase, salt, SCRYPT_N, SCRYPT_R, SCRYPT_P, SCRYPT_KEYLEN)
+	if err != nil {
+		return nil, fmt.Errorf("encryptKeyfile: 2: " + err.Error())
+	}
+
+	// Der Private Schlüssel wird verschlüsselt
+	aead, err := chacha20poly1305.NewX(derived)
+	if err != nil {
+		return nil, fmt.Errorf("encryptKeyfile: 3: " + err.Error())
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("encryptKeyfile: 4: " + err.Error())
+	}
+	cipher_text := aead.Seal(nil, nonce, priv_key, []byte("rouex-keyfile"))
+
+	// Die Datei wird gebaut
+	keyfile := encrypted_keyfile{
+		Version:    KEYFILE_VERSION,
+		KDF:        "scrypt",
+		N:          SCRYPT_N,
+		R:          SCRYPT_R,
+		P:          SCRYPT_P,
+		Salt:       hex.EncodeToString(salt),
+		Cipher:     "xchacha20poly1305",
+		Nonce:      hex.EncodeToString(nonce),
+		CipherText: hex.EncodeToString(cipher_text),
+	}
+	return json.MarshalIndent(keyfile, "", "  ")
+}
+
+// Entschlüsselt eine Schlüsseldatei mit einer Passphrase
+func decryptKeyfile(content []byte, passphrase []byte) ([]byte, error) {
+	// Die Datei wird eingelesen
+	var keyfile encrypted_keyfile
+	if err := json.Unmarshal(content, &keyfile); err != nil {
+		return nil, fmt.Errorf("decryptKeyfile: 1: invalid keyfile")
+	}
+
+	// Es wird geprüft ob das Format unterstützt wird
+	if keyfile.Version != KEYFILE_VERSION || keyfile.KDF != "scrypt" || keyfile.Cipher != "xchacha20poly1305" {
+		return nil, fmt.Errorf("decryptKeyfile: 2: unsupported keyfile format")
+	}
+
+	// Es wird geprüft ob die Parameter der Schlüsselableitung zulässig sind
+	if keyfile.N < 2 || keyfile.N > SCRYPT_MAX_N || keyfile.R < 1 || keyfile.R > SCRYPT_MAX_R || keyfile.P < 1 || keyfile.P > SCRYPT_MAX_P {
+		return nil, fmt.Errorf("decryptKeyfile: 3: unsupported scrypt parameters")
+	}
+
+	// Die Werte werden eingelesen
+	salt, err := hex.DecodeString(keyfile.Salt)
+	if err != nil {
+		return nil, fmt.Errorf("decryptKeyfile: 4: " + err.Error())
+	}
+	nonce, err := hex.DecodeString(keyfile.Nonce)
+	if err != nil {
+		return nil, fmt.Errorf("decryptKeyfile: 5: " + err.Error())
+	}
+	cipher_text, err := hex.DecodeString(keyfile.CipherText)
+	if err != nil {
+		return nil, fmt.Errorf("decryptKeyfile: 6: " + err.Error())
+	}
+
+	// Der Schlüssel wird aus der Passphrase abgeleitet
+	derived, err := scrypt.Key(passphrase, salt, keyfile.N, keyfile.R, keyfile.P, SCRYPT_KEYLEN)
+	if err != nil {
+		return nil, fmt.Errorf("decryptKeyfile: 7: " + err.Error())
+	}
+
+	// Der Private Schlüssel wird entschlüsselt
+	aead, err := chacha20poly1305.NewX(derived)
+	if err != nil {
+		return nil, fmt.Errorf("decryptKeyfile: 8: " + err.Error())
+	}
+	if len(nonce) != aead.NonceSize() {
+		return nil, fmt.Errorf("decryptKeyfile: 9: invalid nonce")
+	}
+	priv_key, err := aead.Open(nil, nonce, cipher_text, []byte("rouex-keyfile"))
+	if err != nil {
+		return nil, fmt.Errorf("decryptKeyfile: 10: wrong passphrase or corrupted keyfile")
+	}
+
+	// Der Private Schlüssel wird zurückgegeben
+	return priv_key, nil
+}
+
+// Schreibt eine Schlüsseldatei mit den Berechtigungen 0600, die Datei wird erst nach dem vollständigen Schreiben ersetzt
+func writeKeyfile(path string, content []byte) error {
+	// Die Daten werden in eine Temporäre Datei geschrieben
+	tmp_file, err := os.CreateTemp(filepath.Dir(path), ".keyfile-*")
+	if err != nil {
+		return fmt.Errorf("writeKeyfile: 1: " + err.Error())
+	}
+	tmp_path := tmp_file.Name()
+	defer os.Remove(tmp_path)
+	if err := tmp_file.Chmod(KEYFILE_MODE); err != nil {
+		tmp_file.Close()
+		return fmt.Errorf("writeKeyfile: 2: " + err.Error())
+	}
+	if _, err := tmp_file.Write(content); err != nil {
+		tmp_file.Close()
+		return fmt.Errorf("writeKeyfile: 3: " + err.Error())
+	}
+	if err := tmp_file.Sync(); err != nil {
+		tmp_file.Close()
+		return fmt.Errorf("writeKeyfile: 4: " + err.Error())
+	}
+	if err := tmp_file.Close(); err != nil {
+		return fmt.Errorf("writeKeyfile: 5: " + err.Error())
+	}
+
+	// Die Temporäre Datei ersetzt die eigentliche Schlüsseldatei
+	if err := os.Rename(tmp_path, path); err != nil {
+		return fmt.Errorf("writeKeyfile: 6: " + err.Error())
+	}
+
+	// Der Vorgang wurde ohne Fehler durchgeführt
+	return nil
+}
@@ -0,0 +1,175 @@
+package keystore
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// Erzeugt eine Verschlüsselte Schlüsseldatei und gibt sie eingelesen zurück
+func newTestKeyfile(t *testing.T, priv_key []byte, passphrase []byte) encrypted_keyfile {
+	t.Helper()
+	content, err := encryptKeyfile(priv_key, passphrase)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var keyfile encrypted_keyfile
+	if err := json.Unmarshal(content, &keyfile); err != nil {
+		t.Fatal(err)
+	}
+	return keyfile
+}
+
+// Wandelt eine Schlüsseldatei wieder in Bytes um
+func encodeTestKeyfile(t *testing.T, keyfile encrypted_keyfile) []byte {
+	t.Helper()
+	content, err := json.Marshal(keyfile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return content
+}
+
+func TestKeyfileRoundTrip(t *testing.T) {
+	priv_key := bytes.Repeat([]byte{0x42}, 32)
+	content, err := encryptKeyfile(priv_key, []byte("secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Der Private Schlüssel darf nicht im Klartext enthalten sein
+	if bytes.Contains(content, []byte(hex.EncodeToString(priv_key))) {
+		t.Fatal("keyfile contains plain private key")
+	}
+
+	// Der Schlüssel wird mit der richtigen Passphrase entschlüsselt
+	decrypted, err := decryptKeyfile(content, []byte("secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(decrypted, priv_key) {
+		t.Error("decrypted key does not match")
+	}
+
+	// Mit einer falschen Passphrase schlägt die Entschlüsselung fehl
+	if _, err := decryptKeyfile(content, []byte("wrong")); err == nil {
+		t.Error("keyfile decrypted with wrong passphrase")
+	}
+}
+
+func TestKeyfileUniqueSalt(t *testing.T) {
+	priv_key := bytes.Repeat([]byte{0x01}, 32)
+	first := newTestKeyfile(t, priv_key, []byte("secret"))
+	second := newTestKeyfile(t, priv_key, []byte("secret"))
+	if first.Salt == second.Salt || first.Nonce == second.Nonce || first.CipherText == second.CipherText {
+		t.Error("keyfiles of the same key are equal")
+	}
+}
+
+func TestKeyfileRejectsScryptParameters(t *testing.T) {
+	keyfile := newTestKeyfile(t, bytes.Repeat([]byte{0x07}, 32), []byte("secret"))
+
+	tests := []struct {
+		name   string
+		change func(*encrypted_keyfile)
+	}{
+		{"n too large", func(k *encrypted_keyfile) { k.N = SCRYPT_MAX_N * 2 }},
+		{"n too small", func(k *encrypted_keyfile) { k.N = 1 }},
+		{"r too large", func(k *encrypted_keyfile) { k.R = SCRYPT_MAX_R + 1 }},
+		{"r zero", func(k *encrypted_keyfile) { k.R = 0 }},
+		{"p too large", func(k *encrypted_keyfile) { k.P = SCRYPT_MAX_P + 1 }},
+		{"p zero", func(k *encrypted_keyfile) { k.P = 0 }},
+	}
+	for _, tt := range tests {
+		changed := keyfile
+		tt.change(&changed)
+		if _, err := decryptKeyfile(encodeTestKeyfile(t, changed), []byte("secret")); err == nil {
+			t.Errorf("%s: keyfile accepted", tt.name)
+		}
+	}
+}
+
+func TestKeyfileRejectsInvalidContent(t *testing.T) {
+	keyfile := newTestKeyfile(t, bytes.Repeat([]byte{0x09}, 32), []byte("secret"))
+
+	tests := []struct {
+		name   string
+		change func(*encrypted_keyfile)
+	}{
+		{"version", func(k *encrypted_keyfile) { k.Version = KEYFILE_VERSION + 1 }},
+		{"kdf", func(k *encrypted_keyfile) { k.KDF = "pbkdf2" }},
+		{"cipher", func(k *encrypted_keyfile) { k.Cipher = "aes" }},
+		{"salt", func(k *encrypted_keyfile) { k.Salt = "zz" }},
+		{"nonce", func(k *encrypted_keyfile) { k.Nonce = k.Nonce[:len(k.Nonce)-2] }},
+		{"ciphertext", func(k *encrypted_keyfile) {
+			cipher_text, _ := hex.DecodeString(k.CipherText)
+			cipher_text[0] ^= 1
+			k.CipherText = hex.EncodeToString(cipher_text)
+		}},
+	}
+	for _, tt := range tests {
+		changed := keyfile
+		tt.change(&changed)
+		if _, err := decryptKeyfile(encodeTestKeyfile(t, changed), []byte("secret")); err == nil {
+			t.Errorf("%s: keyfile accepted", tt.name)
+		}
+	}
+	if _, err := decryptKeyfile([]byte("not json"), []byte("secret")); err == nil {
+		t.Error("invalid json accepted")
+	}
+}
+
+func TestIsPlainHexKeyfile(t *testing.T) {
+	plain := []byte(hex.EncodeToString(bytes.Repeat([]byte{0xab}, 32)) + "\n")
+	if !isPlainHexKeyfile(plain) {
+		t.Error("plain hex keyfile not detected")
+	}
+	if isPlainHexKeyfile([]byte("abcd")) || isPlainHexKeyfile(bytes.Repeat([]byte("z"), 64)) {
+		t.Error("invalid content detected as plain keyfile")
+	}
+	content, err := encryptKeyfile(bytes.Repeat([]byte{0xab}, 32), []byte("secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if isPlainHexKeyfile(content) {
+		t.Error("encrypted keyfile detected as plain keyfile")
+	}
+}
+
+func TestWriteKeyfile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "relay.key")
+	if err := writeKeyfile(path, []byte("first")); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeKeyfile(path, []byte("second")); err != nil {
+		t.Fatal(err)
+	}
+
+	// Die Datei wird ersetzt und besitzt die Berechtigungen 0600
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != "second" {
+		t.Errorf("content = %q, want %q", content, "second")
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != KEYFILE_MODE {
+		t.Errorf("mode = %v, want %v", info.Mode().Perm(), KEYFILE_MODE)
+	}
+
+	// Es bleiben keine Temporären Dateien zurück
+	entries, err := os.ReadDir(filepath.Dir(path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("directory contains %d files, want 1", len(entries))
+	}
+}
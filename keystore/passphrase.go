@@ -0,0 +1,78 @@
+package keystore
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+
+	"golang.org/x/term"
+)
+
+// Speichert den Pfad zu einer Datei ab, welche die Passphrase enthält
+var _passphrase_file string
+
+// Speichert die Passphrase ab, mit welcher der Schlüssel zuletzt entsperrt wurde, sie wird bei einer Schlüsselrotation erneut verwendet
+var _unlocked_passphrase []byte
+
+// Legt den Pfad zu einer Datei fest, aus welcher die Passphrase gelesen wird
+func SetPassphraseFile(path string) {
+	_passphrase_file = path
+}
+
+// Fragt die Passphrase im Terminal ab
+func promptPassphrase(prompt string, confirm bool) ([]byte, error) {
+	// Es wird geprüft ob ein Terminal verfügbar ist
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		return nil, fmt.Errorf("promptPassphrase: no keystore passphrase provided, set ROUEX_KEYSTORE_PASSPHRASE, a passphrase file or run in a terminal")
+	}
+
+	// Die Passphrase wird abgefragt
+	fmt.Fprint(os.Stderr, prompt)
+	passphrase, err := term.ReadPassword(int(os.Stdin.Fd()))
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return nil, fmt.Errorf("promptPassphrase: 1: " + err.Error())
+	}
+
+	// Sollte eine Bestätigung benötigt werden, wird die Passphrase erneut abgefragt
+	if confirm {
+		fmt.Fprint(os.Stderr, "Repeat passphrase: ")
+		repeated, err := term.ReadPassword(int(os.Stdin.Fd()))
+		fmt.Fprintln(os.Stderr)
+		if err != nil {
+			return nil, fmt.Errorf("promptPassphrase: 2: " + err.Error())
+		}
+		if !bytes.Equal(passphrase, repeated) {
+			return nil, fmt.Errorf("promptPassphrase: passphrases do not match")
+		}
+	}
+
+	// Die Passphrase wird zurückgegeben
+	return passphrase, nil
+}
+
+// Ermittelt die Passphrase des Schlüssels, zuerst wird die Umgebungsvariable, danach die Passphrase Datei
+// und zuletzt das Terminal verwendet
+func getPassphrase(confirm bool) ([]byte, error) {
+	// Es wird geprüft ob die Passphrase als Umgebungsvariable angegeben wurde
+	if value, found := os.LookupEnv("ROUEX_KEYSTORE_PASSPHRASE"); found && len(value) > 0 {
+		return []byte(value), nil
+	}
+
+	// Es wird geprüft ob eine Passphrase Datei angegeben wurde
+	if len(_passphrase_file) > 0 {
+		content, err := os.ReadFile(_passphrase_file)
+		if err != nil {
+			return nil, fmt.Errorf("getPassphrase: " + err.Error())
+		}
+		passphrase := strings.TrimRight(string(content), "\r\n")
+		if len(passphrase) == 0 {
+			return nil, fmt.Errorf("getPassphrase: passphrase file is empty")
+		}
+		return []byte(passphrase), nil
+	}
+
+	// Die Passphrase wird im Terminal abgefragt
+	return promptPassphrase("Keystore passphrase: ", confirm)
+}
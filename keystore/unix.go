@@ -6,37 +6,76 @@ import (
 	"encoding/hex"
 	"fmt"
 	"os"
+	"strings"
+	"time"
 
 	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/fluffelpuff/RoueX/logging"
 	"github.com/fluffelpuff/RoueX/static"
 )
 
 func createNewPrivateKe() (*btcec.PublicKey, *btcec.PrivateKey, error) {
+	// Die Passphrase für den neuen Schlüssel wird abgerufen
+	passphrase, err := getPassphrase(true)
+	if err != nil {
+		return nil, nil, err
+	}
+
 	// Es wird ein neuer Privater Schlüssel erstellt
 	pr, err := btcec.NewPrivateKey()
 	if err != nil {
 		return nil, nil, err
 	}
 
-	// Der Private Schlüssel wird in Hex umgewadelt
-	hexed_priv_key := hex.EncodeToString(pr.Serialize())
+	// Der Private Schlüssel wird verschlüsselt
+	encrypted, err := encryptKeyfile(pr.Serialize(), passphrase)
+	if err != nil {
+		return nil, nil, err
+	}
 
 	// Der Private Schlüssel wird geschrieben
-	file, err := os.Create(static.GetFilePathFor(static.PRIVATE_KEY_FILE))
+	if err := writeKeyfile(static.GetFilePathFor(static.PRIVATE_KEY_FILE), encrypted); err != nil {
+		return nil, nil, err
+	}
+
+	// Der Öffentliche und Private Schlüssel wird zurückgeben
+	_unlocked_passphrase = passphrase
+	logging.Logger(logging.KEYSTORE).Info("Keystore: new private key created", "path", static.GetFilePathFor(static.PRIVATE_KEY_FILE))
+	return pr.PubKey(), pr, nil
+}
+
+// Wandelt eine alte, nicht Verschlüsselte Schlüsseldatei in eine Verschlüsselte Schlüsseldatei um
+func migratePlainKeyfile(content []byte) (*btcec.PublicKey, *btcec.PrivateKey, error) {
+	// Der Private Schlüssel wird eingelesen
+	decoded, err := hex.DecodeString(strings.TrimSpace(string(content)))
 	if err != nil {
 		return nil, nil, err
 	}
-	_, err = file.WriteString(hexed_priv_key)
+	if len(decoded) != 32 {
+		return nil, nil, fmt.Errorf("invalid private key loaded, panic aborted")
+	}
+
+	// Die Passphrase für die Verschlüsselung wird abgerufen
+	logging.Logger(logging.KEYSTORE).Warn("Keystore: unencrypted private key found, migrating to encrypted keystore", "path", static.GetFilePathFor(static.PRIVATE_KEY_FILE))
+	passphrase, err := getPassphrase(true)
 	if err != nil {
 		return nil, nil, err
 	}
 
-	// Die Datei wird geschlossen
-	file.Close()
+	// Der Private Schlüssel wird verschlüsselt und die alte Datei wird ersetzt
+	encrypted, err := encryptKeyfile(decoded, passphrase)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := writeKeyfile(static.GetFilePathFor(static.PRIVATE_KEY_FILE), encrypted); err != nil {
+		return nil, nil, err
+	}
 
-	// Der Öffentliche und Private Schlüssel wird zurückgeben
-	fmt.Println("New Private key created to file", static.GetFilePathFor(static.PRIVATE_KEY_FILE))
-	return pr.PubKey(), pr, nil
+	// Der Private Schlüssel wird zurückgegeben
+	_unlocked_passphrase = passphrase
+	logging.Logger(logging.KEYSTORE).Info("Keystore: private key migrated to encrypted keystore", "path", static.GetFilePathFor(static.PRIVATE_KEY_FILE))
+	privk, pubk := btcec.PrivKeyFromBytes(decoded)
+	return pubk, privk, nil
 }
 
 func LoadPrivateKeyFromKeyStore() (*btcec.PublicKey, *btcec.PrivateKey, error) {
@@ -46,10 +85,8 @@ func LoadPrivateKeyFromKeyStore() (*btcec.PublicKey, *btcec.PrivateKey, error) {
 		// Es wird ein neuer Privater und öffentlicher Schlüssel erstellt
 		return createNewPrivateKe()
 	}
-
-	// Überprüfen, ob der aktuelle Benutzer die Datei lesen darf
-	if fileinfo.Mode().Perm()&(1<<2) == 0 {
-		return nil, nil, fmt.Errorf("no permissions, key file 1")
+	if err != nil {
+		return nil, nil, fmt.Errorf("error by reading the key file: " + err.Error())
 	}
 
 	// Es wird geprüft ob die Keydatei vorhanden ist
@@ -58,16 +95,24 @@ func LoadPrivateKeyFromKeyStore() (*btcec.PublicKey, *btcec.PrivateKey, error) {
 		return nil, nil, fmt.Errorf("error by reading the key file")
 	}
 
-	// Es wird versucht die Daten in einen String umzuwandeln
-	converted_data := string(content)
+	// Sollte es sich um eine alte, nicht Verschlüsselte Datei handeln, wird diese umgewandelt
+	if isPlainHexKeyfile(content) {
+		return migratePlainKeyfile(content)
+	}
 
-	// Es wird geprüft ob es sich um einen 64 Zeichen Langen String handelt
-	if len(converted_data) != 64 {
-		return nil, nil, fmt.Errorf("invalid private key loaded, panic aborted")
+	// Es wird geprüft ob nur der Besitzer Zugriff auf die Datei hat
+	if fileinfo.Mode().Perm()&^KEYFILE_MODE != 0 {
+		return nil, nil, fmt.Errorf("key file permissions %#o are too open, expected %#o", fileinfo.Mode().Perm(), KEYFILE_MODE)
+	}
+
+	// Die Passphrase wird abgerufen
+	passphrase, err := getPassphrase(false)
+	if err != nil {
+		return nil, nil, err
 	}
 
-	// Es wird versucht den Privaten Schlüssel einzulesen
-	decoded, err := hex.DecodeString(converted_data)
+	// Der Private Schlüssel wird entschlüsselt
+	decoded, err := decryptKeyfile(content, passphrase)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -81,6 +126,71 @@ func LoadPrivateKeyFromKeyStore() (*btcec.PublicKey, *btcec.PrivateKey, error) {
 	privk, pubk := btcec.PrivKeyFromBytes(decoded)
 
 	// Der Private Schlüssel wird zurückgegeben
-	fmt.Println("Private key loaded from", static.GetFilePathFor(static.PRIVATE_KEY_FILE))
+	_unlocked_passphrase = passphrase
+	logging.Logger(logging.KEYSTORE).Info("Keystore: private key loaded", "path", static.GetFilePathFor(static.PRIVATE_KEY_FILE))
 	return pubk, privk, nil
 }
+
+// Gibt den Pfad der Datei zurück, in welcher die Übergabekette des Relay Schlüssels gespeichert wird
+func getKeyHandoverFilePath() string {
+	return static.GetFilePathFor(static.PRIVATE_KEY_FILE) + ".handover"
+}
+
+// Speichert einen neuen Privaten Schlüssel nach einer Schlüsselrotation ab, der alte Schlüssel wird archiviert
+// und die Übergabekette wird neben dem Schlüssel gespeichert
+func StoreRotatedPrivateKey(new_key *btcec.PrivateKey, handover_chain []byte) error {
+	// Es wird die Passphrase verwendet, mit welcher der aktuelle Schlüssel entsperrt wurde
+	passphrase := _unlocked_passphrase
+	if len(passphrase) == 0 {
+		var err error
+		passphrase, err = getPassphrase(true)
+		if err != nil {
+			return fmt.Errorf("StoreRotatedPrivateKey: 1: " + err.Error())
+		}
+	}
+
+	// Der neue Private Schlüssel wird verschlüsselt
+	encrypted, err := encryptKeyfile(new_key.Serialize(), passphrase)
+	if err != nil {
+		return fmt.Errorf("StoreRotatedPrivateKey: 2: " + err.Error())
+	}
+
+	// Die alte Schlüsseldatei wird archiviert
+	key_path := static.GetFilePathFor(static.PRIVATE_KEY_FILE)
+	old_content, err := os.ReadFile(key_path)
+	if err != nil {
+		return fmt.Errorf("StoreRotatedPrivateKey: 3: " + err.Error())
+	}
+	archive_path := fmt.Sprintf("%s.%d.old", key_path, time.Now().Unix())
+	if err := writeKeyfile(archive_path, old_content); err != nil {
+		return fmt.Errorf("StoreRotatedPrivateKey: 4: " + err.Error())
+	}
+
+	// Die Übergabekette wird vor dem neuen Schlüssel geschrieben, so geht die Kette bei einem Abbruch nicht verloren
+	if err := writeKeyfile(getKeyHandoverFilePath(), handover_chain); err != nil {
+		return fmt.Errorf("StoreRotatedPrivateKey: 5: " + err.Error())
+	}
+
+	// Der neue Schlüssel wird geschrieben
+	if err := writeKeyfile(key_path, encrypted); err != nil {
+		return fmt.Errorf("StoreRotatedPrivateKey: 6: " + err.Error())
+	}
+
+	// Log
+	logging.Logger(logging.KEYSTORE).Info("Keystore: rotated private key written", "path", key_path, "archive", archive_path)
+
+	// Der Vorgang wurde ohne Fehler durchgeführt
+	return nil
+}
+
+// Lädt die gespeicherte Übergabekette des Relay Schlüssels, sollte keine vorhanden sein, wird nil zurückgegeben
+func LoadKeyHandoverChain() ([]byte, error) {
+	content, err := os.ReadFile(getKeyHandoverFilePath())
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("LoadKeyHandoverChain: " + err.Error())
+	}
+	return content, nil
+}
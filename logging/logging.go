@@ -0,0 +1,272 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+)
+
+// Gibt die Subsysteme an, für welche die Ausführlichkeit getrennt festgelegt werden kann
+const (
+	MAIN      = "main"
+	KERNEL    = "kernel"
+	API       = "api"
+	BUFFER    = "buffer"
+	ROUTING   = "routing"
+	FIREWALL  = "firewall"
+	KEYSTORE  = "keystore"
+	TRANSPORT = "transport"
+	PROTOCOL  = "protocol"
+)
+
+// Gibt alle bekannten Subsysteme an
+var SUBSYSTEMS = []string{MAIN, KERNEL, API, BUFFER, ROUTING, FIREWALL, KEYSTORE, TRANSPORT, PROTOCOL}
+
+// Gibt an ob es sich um ein bekanntes Subsystem handelt
+func IsSubsystem(name string) bool {
+	for _, item := range SUBSYSTEMS {
+		if item == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Gibt an welche Attribute nicht im Klartext ausgegeben werden, hierzu gehören Schlüsselmaterial und ECDH Hashes
+var REDACTED_ATTRIBUTES = map[string]bool{
+	"private_key":   true,
+	"shared_secret": true,
+	"ecdh_key":      true,
+	"ecdh_hash":     true,
+	"dh_hash":       true,
+	"otk_key":       true,
+	"session_key":   true,
+	"passphrase":    true,
+}
+
+// Gibt den Wert an, welcher anstelle eines geschützten Attributes ausgegeben wird
+const REDACTED_VALUE = "[redacted]"
+
+// Stellt die Einstellungen des Loggers dar
+type Options struct {
+	Writer io.Writer
+	JSON   bool
+	Level  slog.Level
+	Levels map[string]slog.Level
+}
+
+// Stellt die Logging Verwaltung dar, sie stellt für jedes Subsystem einen eigenen Logger bereit,
+// die Ausführlichkeit der Subsysteme kann zur Laufzeit geändert werden
+type Manager struct {
+	_lock    *sync.Mutex
+	_handler slog.Handler
+	_default *slog.LevelVar
+	_levels  map[string]*slog.LevelVar
+	_loggers map[string]*slog.Logger
+}
+
+// Ersetzt die Werte von geschützten Attributen
+func redact_attribute(_ []string, attr slog.Attr) slog.Attr {
+	if REDACTED_ATTRIBUTES[attr.Key] {
+		return slog.String(attr.Key, REDACTED_VALUE)
+	}
+	return attr
+}
+
+// Gibt den LevelVar eines Subsystems zurück, sollte keiner festgelegt worden sein, wird der Standardwert verwendet
+func (obj *Manager) _level_for(subsystem string) *slog.LevelVar {
+	obj._lock.Lock()
+	defer obj._lock.Unlock()
+	if level, found := obj._levels[subsystem]; found {
+		return level
+	}
+	return obj._default
+}
+
+// Gibt den Logger eines Subsystems zurück
+func (obj *Manager) Logger(subsystem string) *slog.Logger {
+	obj._lock.Lock()
+	defer obj._lock.Unlock()
+
+	// Es wird geprüft ob der Logger bereits erstellt wurde
+	if logger, found := obj._loggers[subsystem]; found {
+		return logger
+	}
+
+	// Der Logger wird erstellt und zwischengespeichert
+	handler := &subsystem_handler{_manager: obj, _subsystem: subsystem, _handler: obj._handler.WithAttrs([]slog.Attr{slog.String("subsystem", subsystem)})}
+	logger := slog.New(handler)
+	obj._loggers[subsystem] = logger
+	return logger
+}
+
+// Legt die Ausführlichkeit eines Subsystems fest, bei einem leeren Namen wird der Standardwert geändert
+func (obj *Manager) SetLevel(subsystem string, level slog.Level) {
+	// Sollte kein Subsystem angegeben sein, wird der Standardwert geändert
+	if len(subsystem) == 0 {
+		obj._default.Set(level)
+		return
+	}
+
+	// Der Wert des Subsystems wird geändert
+	obj._lock.Lock()
+	defer obj._lock.Unlock()
+	if current, found := obj._levels[subsystem]; found {
+		current.Set(level)
+		return
+	}
+	new_level := new(slog.LevelVar)
+	new_level.Set(level)
+	obj._levels[subsystem] = new_level
+}
+
+// Ersetzt die Ausführlichkeit aller Subsysteme, nicht angegebene Subsysteme verwenden danach wieder den Standardwert
+func (obj *Manager) ResetLevels(level slog.Level, levels map[string]slog.Level) {
+	obj._default.Set(level)
+	obj._lock.Lock()
+	defer obj._lock.Unlock()
+	for subsystem := range obj._levels {
+		if _, found := levels[subsystem]; !found {
+			delete(obj._levels, subsystem)
+		}
+	}
+	for subsystem, value := range levels {
+		if current, found := obj._levels[subsystem]; found {
+			current.Set(value)
+			continue
+		}
+		new_level := new(slog.LevelVar)
+		new_level.Set(value)
+		obj._levels[subsystem] = new_level
+	}
+}
+
+// Gibt die Ausführlichkeit aller Subsysteme zurück, der Standardwert wird unter einem leeren Namen zurückgegeben
+func (obj *Manager) GetLevels() map[string]string {
+	result := map[string]string{"": obj._default.Level().String()}
+	for _, subsystem := range SUBSYSTEMS {
+		result[subsystem] = obj._level_for(subsystem).Level().String()
+	}
+	obj._lock.Lock()
+	defer obj._lock.Unlock()
+	for subsystem, level := range obj._levels {
+		result[subsystem] = level.Level().String()
+	}
+	return result
+}
+
+// Erstellt eine neue Logging Verwaltung
+func NewManager(opts Options) *Manager {
+	// Der Standardwert wird festgelegt
+	default_level := new(slog.LevelVar)
+	default_level.Set(opts.Level)
+
+	// Der Basis Handler wird erstellt, er lässt alle Einträge zu, die Ausführlichkeit wird durch die Subsysteme geprüft
+	handler_opts := &slog.HandlerOptions{Level: slog.LevelDebug, ReplaceAttr: redact_attribute}
+	var handler slog.Handler
+	if opts.JSON {
+		handler = slog.NewJSONHandler(opts.Writer, handler_opts)
+	} else {
+		handler = slog.NewTextHandler(opts.Writer, handler_opts)
+	}
+
+	// Das Objekt wird erstellt
+	result := &Manager{_lock: new(sync.Mutex), _handler: handler, _default: default_level, _levels: make(map[string]*slog.LevelVar), _loggers: make(map[string]*slog.Logger)}
+
+	// Die Ausführlichkeit der einzelnen Subsysteme wird übernommen
+	for subsystem, level := range opts.Levels {
+		result.SetLevel(subsystem, level)
+	}
+
+	// Das Objekt wird zurückgegeben
+	return result
+}
+
+// Stellt den Handler eines Subsystems dar
+type subsystem_handler struct {
+	_manager   *Manager
+	_subsystem string
+	_handler   slog.Handler
+}
+
+// Gibt an ob ein Eintrag mit dieser Ausführlichkeit ausgegeben wird
+func (obj *subsystem_handler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= obj._manager._level_for(obj._subsystem).Level()
+}
+
+// Gibt einen Eintrag aus
+func (obj *subsystem_handler) Handle(ctx context.Context, record slog.Record) error {
+	return obj._handler.Handle(ctx, record)
+}
+
+// Gibt einen neuen Handler mit zusätzlichen Attributen zurück
+func (obj *subsystem_handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &subsystem_handler{_manager: obj._manager, _subsystem: obj._subsystem, _handler: obj._handler.WithAttrs(attrs)}
+}
+
+// Gibt einen neuen Handler mit einer Attributgruppe zurück
+func (obj *subsystem_handler) WithGroup(name string) slog.Handler {
+	return &subsystem_handler{_manager: obj._manager, _subsystem: obj._subsystem, _handler: obj._handler.WithGroup(name)}
+}
+
+// Liest eine Ausführlichkeit aus einem String ein (debug, info, warn, error)
+func ParseLevel(value string) (slog.Level, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(strings.TrimSpace(value))); err != nil {
+		return 0, fmt.Errorf("ParseLevel: unkown log level " + value)
+	}
+	return level, nil
+}
+
+// Liest eine Liste von Subsystem Ausführlichkeiten ein (subsystem=level, durch Kommas getrennt)
+func ParseLevels(value string) (map[string]slog.Level, error) {
+	result := make(map[string]slog.Level)
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if len(item) == 0 {
+			continue
+		}
+		splited := strings.SplitN(item, "=", 2)
+		if len(splited) != 2 {
+			return nil, fmt.Errorf("ParseLevels: invalid entry " + item)
+		}
+		subsystem := strings.TrimSpace(splited[0])
+		if !IsSubsystem(subsystem) {
+			return nil, fmt.Errorf("ParseLevels: unkown subsystem " + subsystem)
+		}
+		level, err := ParseLevel(splited[1])
+		if err != nil {
+			return nil, fmt.Errorf("ParseLevels: " + err.Error())
+		}
+		result[subsystem] = level
+	}
+	return result, nil
+}
+
+// Die Standard Verwaltung wird von allen Programmteilen verwendet, welche keinen Zugriff auf den Kernel haben
+var _default_manager_lock = new(sync.Mutex)
+var _default_manager = NewManager(Options{Writer: os.Stderr, Level: slog.LevelInfo})
+
+// Legt die Standard Verwaltung fest
+func SetDefault(manager *Manager) {
+	_default_manager_lock.Lock()
+	defer _default_manager_lock.Unlock()
+	_default_manager = manager
+	slog.SetDefault(manager.Logger(MAIN))
+}
+
+// Gibt die Standard Verwaltung zurück
+func Default() *Manager {
+	_default_manager_lock.Lock()
+	defer _default_manager_lock.Unlock()
+	return _default_manager
+}
+
+// Gibt den Logger eines Subsystems aus der Standard Verwaltung zurück
+func Logger(subsystem string) *slog.Logger {
+	return Default().Logger(subsystem)
+}
@@ -6,22 +6,156 @@ import (
 	"encoding/hex"
 	"fmt"
 
+	"github.com/fluffelpuff/RoueX/directory"
 	"github.com/fluffelpuff/RoueX/ipoverlay"
 	"github.com/fluffelpuff/RoueX/kernel"
 	"github.com/fluffelpuff/RoueX/keystore"
+	"github.com/fluffelpuff/RoueX/logging"
 	protocols "github.com/fluffelpuff/RoueX/protocols"
 	"github.com/fluffelpuff/RoueX/static"
+	"github.com/fluffelpuff/RoueX/utils"
 )
 
+// Erzeugt ein Layer 2 Protokoll anhand seines Namens aus den Einstellungen, es wird zusammen mit seinem festen Protokolltypen zurückgegeben
+func newKernelTypeProtocolByName(name string, config *Config) (uint8, kernel.KernelTypeProtocol, error) {
+	switch name {
+	case "pingpong":
+		return protocols.PING_PONG_PROTOCOL_TYPE, protocols.NEW_ROUEX_PING_PONG_PROTOCOL_HANDLER(), nil
+	case "routeadv":
+		return protocols.ROUTE_ADVERTISEMENT_PROTOCOL_TYPE, protocols.NEW_ROUEX_ROUTE_ADVERTISEMENT_PROTOCOL_HANDLER(), nil
+	case "keyhandover":
+		return protocols.KEY_HANDOVER_PROTOCOL_TYPE, protocols.NEW_ROUEX_KEY_HANDOVER_PROTOCOL_HANDLER(), nil
+	case "stream":
+		return protocols.STREAM_PROTOCOL_TYPE, protocols.NEW_ROUEX_STREAM_PROTOCOL_HANDLER(), nil
+	case "datagram":
+		return protocols.DATAGRAM_PROTOCOL_TYPE, protocols.NEW_ROUEX_DATAGRAM_PROTOCOL_HANDLER(), nil
+	case "tun":
+		return protocols.TUN_PROTOCOL_TYPE, protocols.NEW_ROUEX_TUN_PROTOCOL_HANDLER(config.Tun.Name, config.Tun.MTU), nil
+	case "directory":
+		return protocols.DIRECTORY_PROTOCOL_TYPE, protocols.NEW_ROUEX_DIRECTORY_PROTOCOL_HANDLER(), nil
+	case "peerexchange":
+		return protocols.PEER_EXCHANGE_PROTOCOL_TYPE, protocols.NEW_ROUEX_PEER_EXCHANGE_PROTOCOL_HANDLER(), nil
+	case "exit":
+		policy, err := protocols.ParseExitPolicy(config.Exit.Allow, config.Exit.Clients)
+		if err != nil {
+			return 0, nil, fmt.Errorf("newKernelTypeProtocolByName: " + err.Error())
+		}
+		return protocols.EXIT_PROTOCOL_TYPE, protocols.NEW_ROUEX_EXIT_PROTOCOL_HANDLER(policy), nil
+	default:
+		return 0, nil, fmt.Errorf("newKernelTypeProtocolByName: unkown protocol " + name)
+	}
+}
+
+// Ließt die Einstellungen neu ein und übernimmt die Werte, welche zur Laufzeit geändert werden können,
+// geänderte Server Endpunkte, Protokolle, Pfade und Grenzwerte werden erst nach einem Neustart übernommen
+func reloadConfigs(kernel_object *kernel.Kernel, logs *logging.Manager, config *Config) error {
+	// Die Einstellungen werden neu eingelesen
+	reloaded, err := readConfigs()
+	if err != nil {
+		return fmt.Errorf("reloadConfigs: 1: " + err.Error())
+	}
+
+	// Die Freigaberichtlinie wird vor dem Übernehmen geprüft, so bleibt bei einem Fehler die alte Richtlinie erhalten
+	policy, err := protocols.ParseExitPolicy(reloaded.Exit.Allow, reloaded.Exit.Clients)
+	if err != nil {
+		return fmt.Errorf("reloadConfigs: 2: " + err.Error())
+	}
+
+	// Die Ausführlichkeit der Log Ausgabe wird vor dem Übernehmen geprüft
+	log_level, log_levels, err := reloaded.getLogLevels()
+	if err != nil {
+		return fmt.Errorf("reloadConfigs: 3: " + err.Error())
+	}
+
+	// Die Richtlinie für nicht Vertrauenswürdige Relays wird vor dem Übernehmen geprüft
+	untrusted_policy, err := reloaded.getUntrustedRelayPolicy()
+	if err != nil {
+		return fmt.Errorf("reloadConfigs: 4: " + err.Error())
+	}
+
+	// Die im Verzeichnis angekündigten Endpunkte werden vor dem Übernehmen geprüft
+	descriptor_end_points, err := reloaded.getRelayDescriptorEndPoints()
+	if err != nil {
+		return fmt.Errorf("reloadConfigs: 5: " + err.Error())
+	}
+
+	// Die Richtlinie für das Bootstrappen wird vor dem Übernehmen geprüft
+	bootstrap_policy, err := reloaded.getBootstrapPolicy()
+	if err != nil {
+		return fmt.Errorf("reloadConfigs: 6: " + err.Error())
+	}
+
+	// Die Frist für das Herunterfahren, die Ausführlichkeit der Log Ausgabe sowie die Richtlinien für Rückverbindungen, nicht Vertrauenswürdige Relays und das Bootstrappen werden übernommen
+	kernel_object.SetDrainTimeout(reloaded.getDrainTimeout())
+	logs.ResetLevels(log_level, log_levels)
+	kernel_object.SetP2PBackDialPolicy(reloaded.getP2PBackDialPolicy())
+	kernel_object.SetUntrustedRelayPolicy(untrusted_policy)
+	kernel_object.SetBootstrapPolicy(bootstrap_policy)
+
+	// Die eigene Relay Beschreibung wird mit den neuen Endpunkten erneut veröffentlicht
+	kernel_object.SetRelayDescriptorEndPoints(descriptor_end_points, reloaded.getRelayDescriptorTTL())
+	if err := kernel_object.PublishRelayDescriptor(); err != nil {
+		return fmt.Errorf("reloadConfigs: 7: " + err.Error())
+	}
+
+	// Die Statischen Relay Verzeichnisse werden neu eingelesen, ein geänderter Dateipfad wird erst nach einem Neustart übernommen
+	for _, service := range kernel_object.GetDirectoryServices() {
+		if static_directory, ok := service.(*directory.StaticRelayDirectory); ok {
+			if err := static_directory.Reload(); err != nil {
+				return fmt.Errorf("reloadConfigs: 8: " + err.Error())
+			}
+		}
+	}
+
+	// Die Freigaberichtlinie des Exit Protokolls wird übernommen, sofern es beim Start registriert wurde
+	for _, item := range config.Protocols {
+		if item.Name != "exit" {
+			continue
+		}
+		protocol, err := kernel_object.GetKernelProtocolById(protocols.EXIT_PROTOCOL_TYPE)
+		if err != nil {
+			return fmt.Errorf("reloadConfigs: 9: " + err.Error())
+		}
+		if exit_protocol, ok := protocol.(*protocols.ROUEX_EXIT_PROTOCOL); ok {
+			exit_protocol.SetPolicy(policy)
+		}
+	}
+
+	// Der Vorgang wurde ohne Fehler durchgeführt
+	return nil
+}
+
+// Erzeugt ein Client Modul anhand seines Protokollnamens aus den Einstellungen
+func newClientModuleByName(name string) (kernel.ClientModule, error) {
+	switch name {
+	case "wstcp":
+		return ipoverlay.NewWebsocketClient(), nil
+	case "tcp":
+		return ipoverlay.NewTcpClient(), nil
+	case "quic":
+		return ipoverlay.NewQuicClient(), nil
+	default:
+		return nil, fmt.Errorf("newClientModuleByName: unkown client module " + name)
+	}
+}
+
 func main() {
 	// Der Banner wird angezeigt
 	fmt.Print(static.WELCOME_BANNER)
 
 	// Die Einstellungen werden geladen
-	if err := loadConfigs(); err != nil {
+	config, err := loadConfigs()
+	if err != nil {
 		panic(err)
 	}
 
+	// Die Logging Verwaltung wird erstellt und von allen Programmteilen verwendet
+	logs, err := config.newLogManager()
+	if err != nil {
+		panic(err)
+	}
+	logging.SetDefault(logs)
+
 	// Es wird versucht den Privaten Schlüssel zu laden
 	pub_key, priv_key, err := keystore.LoadPrivateKeyFromKeyStore()
 	if err != nil {
@@ -29,49 +163,142 @@ func main() {
 	}
 
 	// Log
-	fmt.Println("Public relay key:", hex.EncodeToString(pub_key.SerializeCompressed()))
+	logs.Logger(logging.MAIN).Info("Public relay key loaded", "public_key", hex.EncodeToString(pub_key.SerializeCompressed()))
 
 	// Das Passende Systemkernel wird erstellt
-	kernel_object, err := kernel.CreateUnixKernel(priv_key)
+	kernel_object, err := kernel.CreateUnixKernel(priv_key, logs)
 	if err != nil {
 		panic(err)
 	}
 
-	// Das Ping Pong Layer 2 Protkoll wird Registriert
-	layer_two_ping_pong := protocols.NEW_ROUEX_PING_PONG_PROTOCOL_HANDLER()
-	if err := kernel_object.RegisterNewKernelTypeProtocol(0, layer_two_ping_pong); err != nil {
+	// Die Frist für das Herunterfahren sowie das Neuladen der Einstellungen (SIGHUP) werden festgelegt
+	kernel_object.SetDrainTimeout(config.getDrainTimeout())
+	kernel_object.SetP2PBackDialPolicy(config.getP2PBackDialPolicy())
+	kernel_object.SetReloadHandler(func() error { return reloadConfigs(kernel_object, logs, config) })
+
+	// Die Richtlinie für die Zulassung nicht Vertrauenswürdiger Relays wird festgelegt
+	untrusted_policy, err := config.getUntrustedRelayPolicy()
+	if err != nil {
 		panic(err)
 	}
+	kernel_object.SetUntrustedRelayPolicy(untrusted_policy)
 
-	// Es wird ein Lokaler Websocket Server erezugt
-	local_ws, err := ipoverlay.CreateNewLocalWebsocketServerEP("", static.WS_PORT)
+	// Die Endpunkte, unter welchen das eigene Relay in den Verzeichnissen angekündigt wird, werden festgelegt
+	descriptor_end_points, err := config.getRelayDescriptorEndPoints()
 	if err != nil {
 		panic(err)
 	}
+	kernel_object.SetRelayDescriptorEndPoints(descriptor_end_points, config.getRelayDescriptorTTL())
 
-	// Der Websocket Client Hadnler wird ersteltlt
-	ws_client_module := ipoverlay.NewWebsocketClient()
-
-	// Der Lokale Websocket Server Endpunkt wird hinzugefügt
-	if err := kernel_object.RegisterServerModule(local_ws); err != nil {
+	// Die Seeds und die Anzahl der ausgehenden Verbindungen für das Bootstrappen werden festgelegt
+	bootstrap_policy, err := config.getBootstrapPolicy()
+	if err != nil {
 		panic(err)
 	}
+	kernel_object.SetBootstrapPolicy(bootstrap_policy)
 
-	// Das Websocket Client Protokoll wird registriert
-	if err := kernel_object.RegisterClientModule(ws_client_module); err != nil {
-		panic(err)
+	// Sofern angegeben, wird das Statische Relay Verzeichnis geladen
+	if len(config.Directory.StaticFile) > 0 {
+		static_directory, err := directory.LoadStaticRelayDirectory(config.Directory.StaticFile)
+		if err != nil {
+			panic(err)
+		}
+		kernel_object.RegisterDirectoryService(static_directory)
+	}
+
+	// Die in den Einstellungen angegebenen Layer 2 Protokolle werden Registriert
+	for _, item := range config.Protocols {
+		tpe, protocol, err := newKernelTypeProtocolByName(item.Name, config)
+		if err != nil {
+			panic(err)
+		}
+		if err := kernel_object.RegisterNewKernelTypeProtocol(tpe, protocol); err != nil {
+			panic(err)
+		}
+	}
+
+	// Es werden alle Lokalen Websocket Server Endpunkte erzeugt und hinzugefügt
+	for _, item := range config.WebsocketServers {
+		local_ws, err := ipoverlay.CreateNewLocalWebsocketServerEP(item.Address, item.Port)
+		if err != nil {
+			panic(err)
+		}
+		if err := kernel_object.RegisterServerModule(local_ws); err != nil {
+			panic(err)
+		}
+	}
+
+	// Es werden alle Lokalen TCP Server Endpunkte erzeugt und hinzugefügt
+	for _, item := range config.TcpServers {
+		local_tcp, err := ipoverlay.CreateNewLocalTcpServerEP(item.Address, item.Port)
+		if err != nil {
+			panic(err)
+		}
+		if err := kernel_object.RegisterServerModule(local_tcp); err != nil {
+			panic(err)
+		}
+	}
+
+	// Es werden alle Lokalen QUIC Server Endpunkte erzeugt und hinzugefügt
+	for _, item := range config.QuicServers {
+		local_quic, err := ipoverlay.CreateNewLocalQuicServerEP(item.Address, item.Port)
+		if err != nil {
+			panic(err)
+		}
+		if err := kernel_object.RegisterServerModule(local_quic); err != nil {
+			panic(err)
+		}
+	}
+
+	// Es werden alle Lokalen Metriken Server erzeugt und hinzugefügt
+	for _, item := range config.MetricsServers {
+		local_metrics, err := kernel.CreateNewLocalMetricsServerEP(item.Address, item.Port)
+		if err != nil {
+			panic(err)
+		}
+		if err := kernel_object.RegisterServerModule(local_metrics); err != nil {
+			panic(err)
+		}
+	}
+
+	// Es werden alle Lokalen SOCKS5 Server erzeugt und hinzugefügt
+	for _, item := range config.Socks5Servers {
+		exit_key, err := utils.ConvertAddressToPublicKey(item.Exit)
+		if err != nil {
+			panic(fmt.Errorf("invalid socks5 exit relay address: " + err.Error()))
+		}
+		local_socks, err := protocols.CreateNewLocalSocks5ServerEP(item.Address, item.Port, exit_key, item.Username, item.Password)
+		if err != nil {
+			panic(err)
+		}
+		if err := kernel_object.RegisterServerModule(local_socks); err != nil {
+			panic(err)
+		}
+	}
+
+	// Die in den Einstellungen angegebenen Client Module werden registriert
+	for _, item := range config.ClientModules {
+		client_module, err := newClientModuleByName(item)
+		if err != nil {
+			panic(err)
+		}
+		if err := kernel_object.RegisterClientModule(client_module); err != nil {
+			panic(err)
+		}
 	}
 
 	// Es wird nach allen Sub Modulen gesucht
-	if err := kernel_object.LoadExternalKernelModules(); err != nil {
-		panic(err)
+	if config.LoadExternalModules {
+		if err := kernel_object.LoadExternalKernelModules(); err != nil {
+			panic(err)
+		}
 	}
 
 	// Der Kernel wird ausgeführt
 	if err := kernel_object.Serve(); err != nil {
-		fmt.Println(err)
+		logs.Logger(logging.MAIN).Error("Kernel stopped with error", "error", err.Error())
 	}
 
 	// Gibt den God by text aus
-	fmt.Println("God by")
+	logs.Logger(logging.MAIN).Info("God by")
 }
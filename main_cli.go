@@ -3,15 +3,21 @@
 package main
 
 import (
+	"bufio"
+	"context"
 	"encoding/hex"
 	"flag"
 	"fmt"
+	"io"
 	"os"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/btcsuite/btcd/btcec/v2"
 	apiclient "github.com/fluffelpuff/RoueX/api_client"
+	"github.com/fluffelpuff/RoueX/ipoverlay"
 	"github.com/fluffelpuff/RoueX/kernel"
 	"github.com/fluffelpuff/RoueX/utils"
 )
@@ -41,6 +47,11 @@ func listRelays(list_all_relays bool) error {
 
 	// Print interface details
 	for _, iface := range result {
+		// Deaktivierte Relays werden nur angezeigt wenn alle Relays abgerufen werden sollen
+		if !iface.IsActive && !list_all_relays {
+			continue
+		}
+
 		// Speichert alle Optionen ab
 		var options []string
 
@@ -58,12 +69,26 @@ func listRelays(list_all_relays bool) error {
 			options = append(options, "UNTRUSTED")
 		}
 
+		// Prüft ob das Relay aktiviert ist
+		if !iface.IsActive {
+			options = append(options, "DISABLED")
+		}
+
 		// Erstellt den Ausagbe String aus den Optionen
 		joinedFlags := strings.Join(options, ",")
 
+		// Nicht Vertrauenswürdige Relays besitzen keine Datenbank ID
+		relay_id := iface.Id
+		if len(relay_id) == 0 {
+			relay_id = "-"
+		}
+
 		// Erzeugt die ausgabe
-		fmt.Printf("%s: <%s>\n", iface.Id, joinedFlags)
+		fmt.Printf("%s: <%s>\n", relay_id, joinedFlags)
 		fmt.Printf("\trealy pkey: %s\n", utils.ConvertHexStringToAddress(iface.PublicKey))
+		if len(iface.EndPoint) > 0 {
+			fmt.Printf("\tend point: %s, protocol = %s\n", iface.EndPoint, iface.Protocol)
+		}
 		for _, connection := range iface.Connections {
 			if kernel.ConnectionIoType(connection.InboundOutbound) == kernel.INBOUND {
 				fmt.Printf("\tin: spkey = %s, protocol = %s, ping = %d ms, tx = %d bytes, rx = %d bytes\n", connection.Id, connection.Protocol, connection.Ping, connection.TxBytes, connection.RxBytes)
@@ -77,6 +102,47 @@ func listRelays(list_all_relays bool) error {
 		fmt.Printf("\ttotal bytes send: %d\n", iface.TotalBytesSend)
 		fmt.Printf("\ttotal connections: %d\n", iface.TotalConnections)
 		fmt.Printf("\tping (ms): %d\n", iface.PingMS)
+		fmt.Printf("\treputation: %d\n", iface.Reputation)
+	}
+
+	// Der Vorgang wurde ohne fehler durchgeführt
+	return nil
+}
+
+// Gibt die Beschreibungen aller Relays aus den Relay Verzeichnissen aus
+func listDirectory() error {
+	// Die API Verbindung wird aufgebaut
+	api, err := apiclient.LoadAPI()
+	if err != nil {
+		return err
+	}
+
+	// Schließt die Verbindug am ende
+	defer api.Close()
+
+	// Die Beschreibungen werden abgerufen
+	result, err := api.FetchRelayDescriptors()
+	if err != nil {
+		return err
+	}
+
+	// Sollten keine Beschreibungen vorhanden sein, wird eine Warnung ausgegeben
+	if len(result) < 1 {
+		fmt.Printf("No relays in directory.\n")
+		return nil
+	}
+
+	// Die Beschreibungen werden ausgegeben
+	for _, descriptor := range result {
+		fmt.Printf("%s:\n", utils.ConvertHexStringToAddress(descriptor.PublicKey))
+		if descriptor.Expires == 0 {
+			fmt.Printf("\tversion: %d, static\n", descriptor.Version)
+		} else {
+			fmt.Printf("\tversion: %d, expires: %s\n", descriptor.Version, time.Unix(descriptor.Expires, 0).Format(time.RFC3339))
+		}
+		for _, end_point := range descriptor.EndPoints {
+			fmt.Printf("\tend point: %s, protocol = %s (%s)\n", end_point.EndPoint, end_point.Module, ipoverlay.ClientServerP2PProtocol(end_point.Protocol).String())
+		}
 	}
 
 	// Der Vorgang wurde ohne fehler durchgeführt
@@ -137,35 +203,632 @@ func convertoToAddress(address_hx_str string) error {
 	return nil
 }
 
+// Liest einen Relay Schlüssel ein, dieser kann als Adresse oder als Hex String angegeben werden
+func readRelayKey(value string) (*btcec.PublicKey, error) {
+	if pkey, err := utils.ConvertAddressToPublicKey(value); err == nil {
+		return pkey, nil
+	}
+	decoded, err := hex.DecodeString(value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid relay key or address")
+	}
+	return btcec.ParsePubKey(decoded)
+}
+
+// Verwaltet die Vertrauenswürdigen Relays
+func manageTrustedRelay(action string, relay string, end_point string, protocol string) error {
+	// Der Schlüssel des Relays wird eingelesen
+	pkey, err := readRelayKey(relay)
+	if err != nil {
+		return err
+	}
+
+	// Die API Verbindung wird aufgebaut
+	api, err := apiclient.LoadAPI()
+	if err != nil {
+		return err
+	}
+
+	// Schließt die Verbindug am ende
+	defer api.Close()
+
+	// Die Aktion wird ausgeführt
+	switch action {
+	case "add":
+		err = api.AddTrustedRelay(pkey, end_point, protocol)
+	case "remove":
+		err = api.RemoveTrustedRelay(pkey)
+	case "enable":
+		err = api.SetTrustedRelayActive(pkey, true)
+	case "disable":
+		err = api.SetTrustedRelayActive(pkey, false)
+	case "edit":
+		err = api.UpdateTrustedRelay(pkey, end_point, protocol)
+	default:
+		err = fmt.Errorf("unkown action")
+	}
+	if err != nil {
+		return err
+	}
+
+	// Die Ausgabe wird erzeugt
+	fmt.Printf("Trusted relay %s: %s\n", action, utils.ConvertPublicKeyToAddress(pkey))
+
+	// Der Vorgang wurde ohne fehler durchgeführt
+	return nil
+}
+
+// Markiert ein verbundenes nicht Vertrauenswürdiges Relay als Vertrauenswürdig, das Relay wird anhand seiner Adresse oder einer Verbindungs ID ausgewählt
+func promoteRelay(relay string, end_point string, protocol string) error {
+	// Sollte es sich nicht um eine Adresse handeln, wird der Wert als Verbindungs ID verwendet
+	connection_id := ""
+	pkey, err := readRelayKey(relay)
+	if err != nil {
+		pkey, connection_id = nil, relay
+	}
+
+	// Die API Verbindung wird aufgebaut
+	api, err := apiclient.LoadAPI()
+	if err != nil {
+		return err
+	}
+
+	// Schließt die Verbindug am ende
+	defer api.Close()
+
+	// Das Relay wird als Vertrauenswürdig markiert
+	public_key, err := api.PromoteRelay(pkey, connection_id, end_point, protocol)
+	if err != nil {
+		return err
+	}
+
+	// Die Ausgabe wird erzeugt
+	fmt.Printf("Trusted relay promoted: %s\n", utils.ConvertHexStringToAddress(public_key))
+
+	// Der Vorgang wurde ohne fehler durchgeführt
+	return nil
+}
+
+// Lädt die Vertrauenswürdigen Relays im Kernel neu
+func reloadTrustedRelays() error {
+	// Die API Verbindung wird aufgebaut
+	api, err := apiclient.LoadAPI()
+	if err != nil {
+		return err
+	}
+
+	// Schließt die Verbindug am ende
+	defer api.Close()
+
+	// Die Relays werden neu geladen
+	if err := api.ReloadTrustedRelays(); err != nil {
+		return err
+	}
+	fmt.Println("Trusted relays reloaded")
+
+	// Der Vorgang wurde ohne fehler durchgeführt
+	return nil
+}
+
+// Liest einen optionalen Schlüssel für eine Firewall Regel ein, ein leerer Wert gilt für alle Relays
+func readRuleKey(value string) ([]byte, error) {
+	if len(value) == 0 {
+		return nil, nil
+	}
+	pkey, err := readRelayKey(value)
+	if err != nil {
+		return nil, err
+	}
+	return pkey.SerializeCompressed(), nil
+}
+
+// Gibt alle Firewall Regeln sowie die Anzahl der verworfenen Pakete aus
+func listFirewallRules() error {
+	// Die API Verbindung wird aufgebaut
+	api, err := apiclient.LoadAPI()
+	if err != nil {
+		return err
+	}
+
+	// Schließt die Verbindug am ende
+	defer api.Close()
+
+	// Die Regeln werden abgerufen
+	state, err := api.FetchFirewallState()
+	if err != nil {
+		return err
+	}
+
+	// Die Regeln werden ausgegeben
+	fmt.Printf("Total droped packages: %d\n", state.TotalDropedPackages)
+	for _, rule := range state.Rules {
+		options := []string{strings.ToUpper(rule.Action), strings.ToUpper(rule.Direction)}
+		if !rule.Active {
+			options = append(options, "DISABLED")
+		}
+		fmt.Printf("%d: <%s>\n", rule.Id, strings.Join(options, ","))
+		fmt.Printf("\tpriority: %d\n", rule.Priority)
+		if len(rule.SenderPKey) > 0 {
+			fmt.Printf("\tsender: %s\n", utils.ConvertHexStringToAddress(rule.SenderPKey))
+		}
+		if len(rule.ReciverPKey) > 0 {
+			fmt.Printf("\treciver: %s\n", utils.ConvertHexStringToAddress(rule.ReciverPKey))
+		}
+		if len(rule.RelayPKey) > 0 {
+			fmt.Printf("\trelay: %s\n", utils.ConvertHexStringToAddress(rule.RelayPKey))
+		}
+		if rule.Protocol >= 0 {
+			fmt.Printf("\tprotocol: %d\n", rule.Protocol)
+		}
+		if rule.Action == "rate_limit" {
+			fmt.Printf("\trate: %d/s, burst = %d\n", rule.Rate, rule.Burst)
+		}
+	}
+
+	// Der Vorgang wurde ohne fehler durchgeführt
+	return nil
+}
+
+// Fügt eine Firewall Regel hinzu, leere Schlüssel gelten für alle Relays
+func addFirewallRule(rule apiclient.FirewallRuleArgs, sender string, reciver string, relay string) error {
+	// Die Schlüssel werden eingelesen
+	var err error
+	if rule.SenderPKey, err = readRuleKey(sender); err != nil {
+		return err
+	}
+	if rule.ReciverPKey, err = readRuleKey(reciver); err != nil {
+		return err
+	}
+	if rule.RelayPKey, err = readRuleKey(relay); err != nil {
+		return err
+	}
+
+	// Die API Verbindung wird aufgebaut
+	api, err := apiclient.LoadAPI()
+	if err != nil {
+		return err
+	}
+
+	// Schließt die Verbindug am ende
+	defer api.Close()
+
+	// Die Regel wird hinzugefügt
+	rule_id, err := api.AddFirewallRule(rule)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Firewall rule added: %d\n", rule_id)
+
+	// Der Vorgang wurde ohne fehler durchgeführt
+	return nil
+}
+
+// Entfernt eine Firewall Regel
+func removeFirewallRule(value string) error {
+	// Die ID der Regel wird eingelesen
+	rule_id, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid rule id")
+	}
+
+	// Die API Verbindung wird aufgebaut
+	api, err := apiclient.LoadAPI()
+	if err != nil {
+		return err
+	}
+
+	// Schließt die Verbindug am ende
+	defer api.Close()
+
+	// Die Regel wird entfernt
+	if err := api.RemoveFirewallRule(rule_id); err != nil {
+		return err
+	}
+	fmt.Printf("Firewall rule removed: %d\n", rule_id)
+
+	// Der Vorgang wurde ohne fehler durchgeführt
+	return nil
+}
+
+// Gibt die Ausführlichkeit der Log Ausgabe aller Subsysteme aus
+func showLogLevels() error {
+	// Die API Verbindung wird aufgebaut
+	api, err := apiclient.LoadAPI()
+	if err != nil {
+		return err
+	}
+
+	// Schließt die Verbindug am ende
+	defer api.Close()
+
+	// Die Werte werden abgerufen
+	levels, err := api.FetchLogLevels()
+	if err != nil {
+		return err
+	}
+
+	// Die Subsysteme werden sortiert ausgegeben, der Standardwert wird zuerst ausgegeben
+	fmt.Printf("default: %s\n", levels[""])
+	delete(levels, "")
+	subsystems := make([]string, 0, len(levels))
+	for subsystem := range levels {
+		subsystems = append(subsystems, subsystem)
+	}
+	sort.Strings(subsystems)
+	for _, subsystem := range subsystems {
+		fmt.Printf("%s: %s\n", subsystem, levels[subsystem])
+	}
+
+	// Der Vorgang wurde ohne fehler durchgeführt
+	return nil
+}
+
+// Legt die Ausführlichkeit der Log Ausgabe fest (subsystem=level oder level für den Standardwert)
+func setLogLevel(value string) error {
+	// Das Subsystem und die Ausführlichkeit werden getrennt
+	subsystem, level := "", value
+	if splited := strings.SplitN(value, "=", 2); len(splited) == 2 {
+		subsystem, level = splited[0], splited[1]
+	}
+
+	// Die API Verbindung wird aufgebaut
+	api, err := apiclient.LoadAPI()
+	if err != nil {
+		return err
+	}
+
+	// Schließt die Verbindug am ende
+	defer api.Close()
+
+	// Die Ausführlichkeit wird übernommen
+	if err := api.SetLogLevel(subsystem, level); err != nil {
+		return err
+	}
+	if len(subsystem) == 0 {
+		fmt.Printf("Default log level set to %s\n", level)
+	} else {
+		fmt.Printf("Log level of %s set to %s\n", subsystem, level)
+	}
+
+	// Der Vorgang wurde ohne fehler durchgeführt
+	return nil
+}
+
+// Rotiert den Relay Schlüssel
+func rotateRelayKey() error {
+	// Die API Verbindung wird aufgebaut
+	api, err := apiclient.LoadAPI()
+	if err != nil {
+		return err
+	}
+
+	// Schließt die Verbindug am ende
+	defer api.Close()
+
+	// Der Schlüssel wird rotiert
+	public_key, err := api.RotateRelayKey()
+	if err != nil {
+		return err
+	}
+
+	// Die Ausgabe wird erzeugt
+	fmt.Printf("New relay key: %s\n", public_key)
+	fmt.Printf("New relay address: %s\n", utils.ConvertHexStringToAddress(public_key))
+	fmt.Println("Restart the relay to activate the new key, the handover is sent to every relay after the restart.")
+
+	// Der Vorgang wurde ohne fehler durchgeführt
+	return nil
+}
+
+// Überträgt die Standardeingabe über den Stream und gibt die empfangenen Daten aus
+func pipeStream(stream *apiclient.Stream) error {
+	// Die Standardeingabe wird in einem eigenen Thread übertragen, am Ende wird die Sendeseite geschlossen
+	write_done := make(chan struct{})
+	go func() {
+		io.Copy(stream, os.Stdin)
+		stream.CloseWrite()
+		close(write_done)
+	}()
+
+	// Die Empfangenen Daten werden ausgegeben bis die Gegenseite den Stream geschlossen hat
+	_, err := io.Copy(os.Stdout, stream)
+
+	// Es wird gewartet bis alle Daten übertragen wurden, anschließend wird der Stream geschlossen
+	<-write_done
+	stream.Close()
+	return err
+}
+
+// Lauscht auf einem Stream Port und verbindet den ersten eingehenden Stream mit der Standardein- und Ausgabe
+func listenStream(port uint) error {
+	// Die API Verbindung wird aufgebaut
+	api, err := apiclient.LoadAPI()
+	if err != nil {
+		return err
+	}
+
+	// Schließt die Verbindug am ende
+	defer api.Close()
+
+	// Es wird auf dem Port gelauscht
+	listener, err := api.ListenStream(uint16(port))
+	if err != nil {
+		return err
+	}
+
+	// Es wird auf einen eingehenden Stream gewartet
+	stream, err := listener.Accept()
+	if err != nil {
+		return err
+	}
+	listener.Close()
+	if remote, err := readRelayKey(stream.RemoteAddress()); err == nil {
+		fmt.Fprintf(os.Stderr, "Stream accepted from %s\n", utils.ConvertPublicKeyToAddress(remote))
+	}
+
+	// Die Daten werden übertragen
+	return pipeStream(stream)
+}
+
+// Baut einen Stream zu einem Relay auf und verbindet ihn mit der Standardein- und Ausgabe
+func connectStream(relay_address string, port uint) error {
+	// Es wird versucht die Adresse einzulesen
+	pkey, err := readRelayKey(relay_address)
+	if err != nil {
+		return err
+	}
+
+	// Die API Verbindung wird aufgebaut
+	api, err := apiclient.LoadAPI()
+	if err != nil {
+		return err
+	}
+
+	// Schließt die Verbindug am ende
+	defer api.Close()
+
+	// Der Stream wird aufgebaut
+	stream, err := api.ConnectStream(pkey, uint16(port))
+	if err != nil {
+		return err
+	}
+
+	// Die Daten werden übertragen
+	return pipeStream(stream)
+}
+
+// Bindet einen Datagram Socket und gibt alle eintreffenden Datagramme aus
+func listenDatagrams(port uint) error {
+	// Die API Verbindung wird aufgebaut
+	api, err := apiclient.LoadAPI()
+	if err != nil {
+		return err
+	}
+
+	// Schließt die Verbindug am ende
+	defer api.Close()
+
+	// Der Socket wird gebunden
+	socket, err := api.BindDatagram(uint16(port))
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Listening for datagrams on port %d\n", socket.LocalPort())
+
+	// Die Datagramme werden ausgegeben, bis der Socket geschlossen wurde
+	for datagram := range socket.Events(context.Background()) {
+		fmt.Printf("%s:%d: %s\n", datagram.Address, datagram.Port, string(datagram.Data))
+	}
+	return nil
+}
+
+// Sendet jede Zeile der Standardeingabe als Datagram an eine Adresse
+func sendDatagrams(relay_address string, port uint) error {
+	// Die API Verbindung wird aufgebaut
+	api, err := apiclient.LoadAPI()
+	if err != nil {
+		return err
+	}
+
+	// Schließt die Verbindug am ende
+	defer api.Close()
+
+	// Es wird ein Socket mit einem freien Port gebunden
+	socket, err := api.BindDatagram(0)
+	if err != nil {
+		return err
+	}
+	defer socket.Close()
+
+	// Die Zeilen werden übermittelt
+	scanner := bufio.NewScanner(os.Stdin)
+	for scanner.Scan() {
+		if err := socket.WriteTo(relay_address, uint16(port), scanner.Bytes()); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
 func main() {
 	// Definiert alle Verwendeten werte
 	var convertPublicKeyToAddress string
 	var list_relays bool
 	var pingArg string
+	var rotate_key bool
+	var reload_relays bool
+	var show_log_levels, list_directory bool
+	var set_log_level string
+	var add_relay, remove_relay, enable_relay, disable_relay, edit_relay, promote_relay string
+	var relay_end_point, relay_protocol string
+	var stream_connect, datagram_send string
+	var stream_listen, datagram_listen, stream_port uint
+	var list_firewall bool
+	var add_firewall_rule, remove_firewall_rule string
+	var rule_direction, rule_sender, rule_reciver, rule_relay string
+	var rule_priority int64
+	var rule_protocol int
+	var rule_rate, rule_burst uint64
 	list_offline_relays := true
 
 	// Definiert alle Parameter
 	flag.BoolVar(&list_relays, "list-relays", false, "")
+	flag.BoolVar(&list_directory, "list-directory", false, "")
 	flag.StringVar(&pingArg, "ping", "", "description of ping flag")
 	flag.BoolVar(&list_offline_relays, "all", false, "A boolean flag")
+	flag.BoolVar(&rotate_key, "rotate-key", false, "")
+	flag.BoolVar(&reload_relays, "reload-relays", false, "")
+	flag.BoolVar(&show_log_levels, "show-log-levels", false, "")
+	flag.StringVar(&set_log_level, "set-log-level", "", "")
+	flag.StringVar(&add_relay, "add-relay", "", "")
+	flag.StringVar(&remove_relay, "remove-relay", "", "")
+	flag.StringVar(&enable_relay, "enable-relay", "", "")
+	flag.StringVar(&disable_relay, "disable-relay", "", "")
+	flag.StringVar(&edit_relay, "edit-relay", "", "")
+	flag.StringVar(&promote_relay, "promote-relay", "", "")
+	flag.StringVar(&relay_end_point, "endpoint", "", "")
+	flag.StringVar(&relay_protocol, "protocol", "", "")
+	flag.StringVar(&stream_connect, "stream-connect", "", "")
+	flag.UintVar(&stream_listen, "stream-listen", 0, "")
+	flag.UintVar(&stream_port, "port", 0, "")
+	flag.StringVar(&datagram_send, "datagram-send", "", "")
+	flag.UintVar(&datagram_listen, "datagram-listen", 0, "")
+	flag.BoolVar(&list_firewall, "list-firewall", false, "")
+	flag.StringVar(&add_firewall_rule, "add-firewall-rule", "", "")
+	flag.StringVar(&remove_firewall_rule, "remove-firewall-rule", "", "")
+	flag.StringVar(&rule_direction, "direction", "any", "")
+	flag.StringVar(&rule_sender, "sender", "", "")
+	flag.StringVar(&rule_reciver, "reciver", "", "")
+	flag.StringVar(&rule_relay, "relay", "", "")
+	flag.Int64Var(&rule_priority, "priority", 100, "")
+	flag.IntVar(&rule_protocol, "rule-protocol", -1, "")
+	flag.Uint64Var(&rule_rate, "rate", 0, "")
+	flag.Uint64Var(&rule_burst, "burst", 0, "")
 	flag.StringVar(&convertPublicKeyToAddress, "convert-to-address", "", "description of ping flag")
 
 	flag.Usage = func() {
 		fmt.Fprintf(os.Stderr, "Usage of %s:\n", os.Args[0])
 		fmt.Fprintf(os.Stderr, "\t-list-relays: Liste Relays auf\n")
 		fmt.Fprintf(os.Stderr, "\t-list-connections: Liste Verbindungen auf\n")
+		fmt.Fprintf(os.Stderr, "\t-list-directory: Liste die Relays aus den Relay Verzeichnissen auf\n")
+		fmt.Fprintf(os.Stderr, "\t-rotate-key: Erzeugt einen neuen Relay Schlüssel\n")
+		fmt.Fprintf(os.Stderr, "\t-add-relay <key> -endpoint <url> [-protocol wstcp|tcp|quic]: Fügt ein Vertrauenswürdiges Relay hinzu\n")
+		fmt.Fprintf(os.Stderr, "\t-remove-relay <key>: Entfernt ein Vertrauenswürdiges Relay\n")
+		fmt.Fprintf(os.Stderr, "\t-enable-relay <key>: Aktiviert ein Vertrauenswürdiges Relay\n")
+		fmt.Fprintf(os.Stderr, "\t-disable-relay <key>: Deaktiviert ein Vertrauenswürdiges Relay\n")
+		fmt.Fprintf(os.Stderr, "\t-reload-relays: Lädt die Vertrauenswürdigen Relays neu\n")
+		fmt.Fprintf(os.Stderr, "\t-edit-relay <key> [-endpoint <url>] [-protocol <name>]: Ändert den Endpunkt eines Vertrauenswürdigen Relays\n")
+		fmt.Fprintf(os.Stderr, "\t-promote-relay <key|connection id> [-endpoint <url>] [-protocol <name>]: Markiert ein verbundenes Relay als Vertrauenswürdig\n")
+		fmt.Fprintf(os.Stderr, "\t-list-firewall: Liste die Firewall Regeln und die Anzahl der verworfenen Pakete auf\n")
+		fmt.Fprintf(os.Stderr, "\t-add-firewall-rule allow|deny|rate_limit [-direction any|in|out] [-sender <key>] [-reciver <key>] [-relay <key>] [-rule-protocol <type>] [-priority <n>] [-rate <n> -burst <n>]: Fügt eine Firewall Regel hinzu\n")
+		fmt.Fprintf(os.Stderr, "\t-remove-firewall-rule <id>: Entfernt eine Firewall Regel\n")
+		fmt.Fprintf(os.Stderr, "\t-show-log-levels: Zeigt die Ausführlichkeit der Log Ausgabe aller Subsysteme an\n")
+		fmt.Fprintf(os.Stderr, "\t-set-log-level [<subsystem>=]<level>: Ändert die Ausführlichkeit der Log Ausgabe (debug, info, warn, error)\n")
+		fmt.Fprintf(os.Stderr, "\t-stream-listen <port>: Nimmt einen Stream an und verbindet ihn mit der Standardein- und Ausgabe\n")
+		fmt.Fprintf(os.Stderr, "\t-stream-connect <address> -port <port>: Baut einen Stream auf und verbindet ihn mit der Standardein- und Ausgabe\n")
+		fmt.Fprintf(os.Stderr, "\t-datagram-listen <port>: Gibt alle Datagramme aus welche auf dem Port eintreffen\n")
+		fmt.Fprintf(os.Stderr, "\t-datagram-send <address> -port <port>: Sendet jede Zeile der Standardeingabe als Datagram\n")
 	}
 
 	// Parst alle Parameter
 	flag.Parse()
 
+	// Die Einstellungen werden geladen, damit der Pfad des API Sockets bekannt ist
+	if _, err := loadConfigs(); err != nil {
+		panic(err)
+	}
+
 	// Es wird geprüft welche Option aktiviert wurde
 	if list_relays {
 		if err := listRelays(list_offline_relays); err != nil {
 			panic(err)
 		}
+	} else if list_directory {
+		if err := listDirectory(); err != nil {
+			panic(err)
+		}
 	} else if len(pingArg) != 0 {
 		pingRelayAddress(pingArg)
+	} else if len(add_relay) != 0 {
+		if len(relay_protocol) == 0 {
+			relay_protocol = "wstcp"
+		}
+		if err := manageTrustedRelay("add", add_relay, relay_end_point, relay_protocol); err != nil {
+			panic(err)
+		}
+	} else if len(remove_relay) != 0 {
+		if err := manageTrustedRelay("remove", remove_relay, "", ""); err != nil {
+			panic(err)
+		}
+	} else if len(enable_relay) != 0 {
+		if err := manageTrustedRelay("enable", enable_relay, "", ""); err != nil {
+			panic(err)
+		}
+	} else if len(disable_relay) != 0 {
+		if err := manageTrustedRelay("disable", disable_relay, "", ""); err != nil {
+			panic(err)
+		}
+	} else if len(edit_relay) != 0 {
+		if err := manageTrustedRelay("edit", edit_relay, relay_end_point, relay_protocol); err != nil {
+			panic(err)
+		}
+	} else if len(promote_relay) != 0 {
+		if err := promoteRelay(promote_relay, relay_end_point, relay_protocol); err != nil {
+			panic(err)
+		}
+	} else if reload_relays {
+		if err := reloadTrustedRelays(); err != nil {
+			panic(err)
+		}
+	} else if list_firewall {
+		if err := listFirewallRules(); err != nil {
+			panic(err)
+		}
+	} else if len(add_firewall_rule) != 0 {
+		rule := apiclient.FirewallRuleArgs{
+			Priority:  rule_priority,
+			Action:    add_firewall_rule,
+			Direction: rule_direction,
+			Protocol:  int16(rule_protocol),
+			Rate:      rule_rate,
+			Burst:     rule_burst,
+			Active:    true,
+		}
+		if err := addFirewallRule(rule, rule_sender, rule_reciver, rule_relay); err != nil {
+			panic(err)
+		}
+	} else if len(remove_firewall_rule) != 0 {
+		if err := removeFirewallRule(remove_firewall_rule); err != nil {
+			panic(err)
+		}
+	} else if show_log_levels {
+		if err := showLogLevels(); err != nil {
+			panic(err)
+		}
+	} else if len(set_log_level) != 0 {
+		if err := setLogLevel(set_log_level); err != nil {
+			panic(err)
+		}
+	} else if rotate_key {
+		if err := rotateRelayKey(); err != nil {
+			panic(err)
+		}
+	} else if stream_listen != 0 {
+		if err := listenStream(stream_listen); err != nil {
+			panic(err)
+		}
+	} else if len(stream_connect) != 0 {
+		if err := connectStream(stream_connect, stream_port); err != nil {
+			panic(err)
+		}
+	} else if datagram_listen != 0 {
+		if err := listenDatagrams(datagram_listen); err != nil {
+			panic(err)
+		}
+	} else if len(datagram_send) != 0 {
+		if err := sendDatagrams(datagram_send, stream_port); err != nil {
+			panic(err)
+		}
 	} else if len(convertPublicKeyToAddress) != 0 {
 		if err := convertoToAddress(convertPublicKeyToAddress); err != nil {
 			panic(err)
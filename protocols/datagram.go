@@ -0,0 +1,309 @@
+package protocols
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"sync"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	addresspackages "github.com/fluffelpuff/RoueX/address_packages"
+	"github.com/fluffelpuff/RoueX/kernel"
+	"github.com/fluffelpuff/RoueX/logging"
+	"github.com/fluffelpuff/RoueX/utils"
+	"github.com/fxamacker/cbor"
+)
+
+// Gibt den Protokolltypen des Datagram Protokolls an
+const DATAGRAM_PROTOCOL_TYPE uint8 = 4
+
+// Gibt die Grenzwerte der Datagram Sockets an
+const (
+	DATAGRAM_MAX_SIZE        = 64 * 1024
+	DATAGRAM_QUEUE_SIZE      = 256
+	DATAGRAM_EPHEMERAL_START = 49152
+)
+
+// Stellt ein Datagram Paket dar
+type DatagramPackage struct {
+	SrcPort uint16
+	DstPort uint16
+	Data    []byte
+}
+
+// Stellt ein empfangenes Datagram dar
+type received_datagram struct {
+	source *btcec.PublicKey
+	port   uint16
+	data   []byte
+}
+
+// Stellt einen gebundenen Datagram Socket dar
+type datagram_socket struct {
+	_id         string
+	_port       uint16
+	_protocol   *ROUEX_DATAGRAM_PROTOCOL
+	_api_conn   *kernel.APIProcessConnectionWrapper
+	_queue      chan *received_datagram
+	_closed     chan struct{}
+	_close_once *sync.Once
+}
+
+// Gibt die ID des Sockets zurück
+func (obj *datagram_socket) GetId() string {
+	return obj._id
+}
+
+// Schließt den Socket, wird auch aufgerufen wenn der API Prozess getrennt wurde
+func (obj *datagram_socket) Close() {
+	obj._close_once.Do(func() {
+		close(obj._closed)
+		obj._protocol._remove_socket(obj)
+	})
+}
+
+// Stellt das Datagram Protokoll dar
+type ROUEX_DATAGRAM_PROTOCOL struct {
+	_objid   string
+	_kernel  *kernel.Kernel
+	_lock    *sync.Mutex
+	_ports   map[uint16]*datagram_socket
+	_sockets map[string]*datagram_socket
+}
+
+// Entfernt einen Socket
+func (obj *ROUEX_DATAGRAM_PROTOCOL) _remove_socket(socket *datagram_socket) {
+	obj._lock.Lock()
+	if obj._ports[socket._port] == socket {
+		delete(obj._ports, socket._port)
+	}
+	delete(obj._sockets, socket._id)
+	obj._lock.Unlock()
+	obj._kernel.Logger(logging.PROTOCOL).Info("ROUEX_DATAGRAM_PROTOCOL: socket closed", "sid", socket._id, "port", socket._port)
+}
+
+// Gibt den Socket eines API Prozesses zurück
+func (obj *ROUEX_DATAGRAM_PROTOCOL) _get_socket(id string, process_api_conn *kernel.APIProcessConnectionWrapper) (*datagram_socket, error) {
+	obj._lock.Lock()
+	defer obj._lock.Unlock()
+	socket, found := obj._sockets[id]
+	if !found || socket._api_conn != process_api_conn {
+		return nil, fmt.Errorf("unkown socket")
+	}
+	return socket, nil
+}
+
+// Ermittelt einen freien Port aus dem dynamischen Bereich, der Threadlock muss gehalten werden
+func (obj *ROUEX_DATAGRAM_PROTOCOL) _free_ephemeral_port() (uint16, error) {
+	// Es wird ein zufälliger Startpunkt gewählt
+	rand_bytes := make([]byte, 2)
+	if _, err := rand.Read(rand_bytes); err != nil {
+		return 0, fmt.Errorf("_free_ephemeral_port: 1: " + err.Error())
+	}
+	total := uint32(65536 - DATAGRAM_EPHEMERAL_START)
+	start := uint32(binary.BigEndian.Uint16(rand_bytes)) % total
+
+	// Es wird der erste freie Port gesucht
+	for i := uint32(0); i < total; i++ {
+		port := uint16(DATAGRAM_EPHEMERAL_START + (start+i)%total)
+		if _, found := obj._ports[port]; !found {
+			return port, nil
+		}
+	}
+	return 0, fmt.Errorf("_free_ephemeral_port: 2: no free port available")
+}
+
+// Bindet einen neuen Socket an einen Port, bei Port 0 wird ein freier Port gewählt
+func (obj *ROUEX_DATAGRAM_PROTOCOL) _bind(port uint16, process_api_conn *kernel.APIProcessConnectionWrapper) (map[string]interface{}, error) {
+	// Es wird geprüft ob der Port verfügbar ist
+	obj._lock.Lock()
+	if port == 0 {
+		free_port, err := obj._free_ephemeral_port()
+		if err != nil {
+			obj._lock.Unlock()
+			return nil, fmt.Errorf("_bind: " + err.Error())
+		}
+		port = free_port
+	} else if _, found := obj._ports[port]; found {
+		obj._lock.Unlock()
+		return nil, fmt.Errorf("port always in use")
+	}
+
+	// Der Socket wird erstellt und registriert
+	socket := &datagram_socket{
+		_id:         utils.RandStringRunes(16),
+		_port:       port,
+		_protocol:   obj,
+		_api_conn:   process_api_conn,
+		_queue:      make(chan *received_datagram, DATAGRAM_QUEUE_SIZE),
+		_closed:     make(chan struct{}),
+		_close_once: new(sync.Once),
+	}
+	obj._ports[port] = socket
+	obj._sockets[socket._id] = socket
+	obj._lock.Unlock()
+
+	// Der Socket wird geschlossen sobald der Prozess getrennt wurde
+	if process_api_conn != nil {
+		process_api_conn.AddProcessInvigoratingService(socket)
+	}
+
+	// Log
+	obj._kernel.Logger(logging.PROTOCOL).Info("ROUEX_DATAGRAM_PROTOCOL: socket bound", "sid", socket._id, "port", port)
+
+	// Die Daten des Sockets werden zurückgegeben
+	reval := make(map[string]interface{})
+	reval["socket"] = socket._id
+	reval["port"] = uint64(port)
+	return reval, nil
+}
+
+// Wartet auf das nächste Datagram eines Sockets
+func (obj *ROUEX_DATAGRAM_PROTOCOL) _receive(socket *datagram_socket) (map[string]interface{}, error) {
+	// Es wird auf ein Datagram gewartet
+	var datagram *received_datagram
+	select {
+	case datagram = <-socket._queue:
+	case <-socket._closed:
+		return nil, fmt.Errorf("socket closed")
+	}
+
+	// Das Datagram wird zurückgegeben
+	reval := make(map[string]interface{})
+	reval["address"] = hex.EncodeToString(datagram.source.SerializeCompressed())
+	reval["port"] = uint64(datagram.port)
+	reval["data"] = datagram.data
+	return reval, nil
+}
+
+// Sendet ein Datagram an ein Relay
+func (obj *ROUEX_DATAGRAM_PROTOCOL) _send(socket *datagram_socket, dest *btcec.PublicKey, port uint16, data []byte) (map[string]interface{}, error) {
+	// Es wird geprüft ob die Größe zulässig ist
+	if len(data) > DATAGRAM_MAX_SIZE {
+		return nil, fmt.Errorf("datagram too large")
+	}
+
+	// Das Paket wird in Bytes umgewandelt
+	encoded, err := cbor.Marshal(DatagramPackage{SrcPort: socket._port, DstPort: port, Data: data}, cbor.EncOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("_send: 1: " + err.Error())
+	}
+
+	// Das Paket wird übermittelt
+	if _, err := obj._kernel.EnterBytesEncryptAndSendL2PackageToNetwork(DATAGRAM_PROTOCOL_TYPE, encoded, dest); err != nil {
+		return nil, fmt.Errorf("_send: 2: " + err.Error())
+	}
+
+	// Der Vorgang wurde ohne Fehler durchgeführt
+	reval := make(map[string]interface{})
+	reval["sent"] = uint64(len(data))
+	return reval, nil
+}
+
+// Nimmt eingetroffene Pakete aus dem Netzwerk Entgegen
+func (obj *ROUEX_DATAGRAM_PROTOCOL) EnterRecivedPackage(pckage *addresspackages.AddressLayerPackage) error {
+	// Es wird versucht das Paket einzulesen
+	var dgp DatagramPackage
+	if err := cbor.Unmarshal(pckage.Data, &dgp); err != nil {
+		return fmt.Errorf("error: invalid_package: " + err.Error())
+	}
+
+	// Der Socket wird ermittelt
+	obj._lock.Lock()
+	socket, found := obj._ports[dgp.DstPort]
+	obj._lock.Unlock()
+	if !found {
+		return nil
+	}
+
+	// Das Datagram wird in die Warteschlange gelegt, sollte diese voll sein, wird es verworfen
+	source := pckage.Sender
+	select {
+	case socket._queue <- &received_datagram{source: &source, port: dgp.SrcPort, data: dgp.Data}:
+	default:
+		obj._kernel.Logger(logging.PROTOCOL).Warn("ROUEX_DATAGRAM_PROTOCOL: socket queue full, datagram dropped", "sid", socket._id, "port", socket._port)
+	}
+
+	// Der Vorgang wurde ohne Fehler durchgeführt
+	return nil
+}
+
+// Nimmt eintreffende Steuer Befehele entgegen
+func (obj *ROUEX_DATAGRAM_PROTOCOL) EnterCommandData(command string, arguments [][]byte, process_api_conn *kernel.APIProcessConnectionWrapper) (map[string]interface{}, error) {
+	// Der Port wird für das Binden eingelesen
+	if command == "bind" {
+		if len(arguments) < 1 || len(arguments[0]) != 2 {
+			return nil, fmt.Errorf("invalid bind command, port required")
+		}
+		return obj._bind(binary.BigEndian.Uint16(arguments[0]), process_api_conn)
+	}
+
+	// Alle weiteren Befehle beziehen sich auf einen Socket
+	if len(arguments) < 1 {
+		return nil, fmt.Errorf("invalid command, socket required")
+	}
+	socket, err := obj._get_socket(string(arguments[0]), process_api_conn)
+	if err != nil {
+		return nil, err
+	}
+
+	// Der Befehl wird ausgeführt
+	switch command {
+	case "receive":
+		return obj._receive(socket)
+	case "send":
+		// Die Adresse, der Port und die Daten werden eingelesen
+		if len(arguments) < 4 || len(arguments[2]) != 2 {
+			return nil, fmt.Errorf("invalid send command, address, port and data required")
+		}
+		pkey, err := btcec.ParsePubKey(arguments[1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid public key")
+		}
+		return obj._send(socket, pkey, binary.BigEndian.Uint16(arguments[2]), arguments[3])
+	case "close":
+		socket.Close()
+		if process_api_conn != nil {
+			process_api_conn.RemoveProcessInvigoratingService(socket)
+		}
+		reval := make(map[string]interface{})
+		reval["closed"] = true
+		return reval, nil
+	default:
+		return nil, fmt.Errorf("invalid command")
+	}
+}
+
+// Registriert den Kernel im Protokoll
+func (obj *ROUEX_DATAGRAM_PROTOCOL) RegisterKernel(kernel *kernel.Kernel) error {
+	obj._lock.Lock()
+	if obj._kernel != nil {
+		obj._lock.Unlock()
+		return fmt.Errorf("kernel always registered")
+	}
+	obj._kernel = kernel
+	obj._lock.Unlock()
+	obj._kernel.Logger(logging.PROTOCOL).Info("ROUEX_DATAGRAM_PROTOCOL: kernel registrated", "id", kernel.GetKernelID(), "object_id", obj._objid)
+	return nil
+}
+
+// Gibt den Namen des Protokolles zurück
+func (obj *ROUEX_DATAGRAM_PROTOCOL) GetProtocolName() string {
+	return "ROUEX_DATAGRAM_PROTOCOL"
+}
+
+// Gibt die ObjektID des Protokolls zurück
+func (obj *ROUEX_DATAGRAM_PROTOCOL) GetObjectId() string {
+	return obj._objid
+}
+
+// Erzeugt ein neues Datagram Protokoll
+func NEW_ROUEX_DATAGRAM_PROTOCOL_HANDLER() *ROUEX_DATAGRAM_PROTOCOL {
+	return &ROUEX_DATAGRAM_PROTOCOL{
+		_lock:    &sync.Mutex{},
+		_objid:   utils.RandStringRunes(12),
+		_ports:   make(map[uint16]*datagram_socket),
+		_sockets: make(map[string]*datagram_socket),
+	}
+}
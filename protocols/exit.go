@@ -0,0 +1,495 @@
+package protocols
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	addresspackages "github.com/fluffelpuff/RoueX/address_packages"
+	"github.com/fluffelpuff/RoueX/kernel"
+	"github.com/fluffelpuff/RoueX/logging"
+	"github.com/fluffelpuff/RoueX/utils"
+	"github.com/fxamacker/cbor"
+)
+
+// Gibt den Protokolltypen des Exit Protokolls an
+const EXIT_PROTOCOL_TYPE uint8 = 6
+
+// Gibt die Grenzwerte des Exit Protokolls an
+const (
+	EXIT_STREAM_PORT      = 1080
+	EXIT_MAX_REQUEST_SIZE = 512
+	EXIT_DIAL_TIMEOUT     = 10 * time.Second
+	EXIT_REQUEST_TIMEOUT  = 30 * time.Second
+)
+
+// Gibt die Antworten auf eine Verbindungsanfrage an
+const (
+	EXIT_STATUS_OK          uint8 = 0
+	EXIT_STATUS_NOT_ALLOWED uint8 = 1
+	EXIT_STATUS_UNREACHABLE uint8 = 2
+	EXIT_STATUS_REFUSED     uint8 = 3
+	EXIT_STATUS_INVALID     uint8 = 4
+)
+
+// Stellt eine Verbindungsanfrage an ein Exit Relay dar
+type ExitRequest struct {
+	Host string
+	Port uint16
+}
+
+// Schreibt eine Verbindungsanfrage in einen Stream
+func writeExitRequest(w io.Writer, req *ExitRequest) error {
+	// Die Anfrage wird in Bytes umgewandelt
+	encoded, err := cbor.Marshal(req, cbor.EncOptions{})
+	if err != nil {
+		return fmt.Errorf("writeExitRequest: 1: " + err.Error())
+	}
+	if len(encoded) > EXIT_MAX_REQUEST_SIZE {
+		return fmt.Errorf("writeExitRequest: 2: request too large")
+	}
+
+	// Der Anfrage wird ihre Länge vorangestellt
+	frame := make([]byte, 2, 2+len(encoded))
+	binary.BigEndian.PutUint16(frame, uint16(len(encoded)))
+	frame = append(frame, encoded...)
+	if _, err := w.Write(frame); err != nil {
+		return fmt.Errorf("writeExitRequest: 3: " + err.Error())
+	}
+	return nil
+}
+
+// Ließt eine Verbindungsanfrage aus einem Stream
+func readExitRequest(r io.Reader) (*ExitRequest, error) {
+	// Die Länge der Anfrage wird eingelesen
+	size_bytes := make([]byte, 2)
+	if _, err := io.ReadFull(r, size_bytes); err != nil {
+		return nil, fmt.Errorf("readExitRequest: 1: " + err.Error())
+	}
+	size := binary.BigEndian.Uint16(size_bytes)
+	if size == 0 || size > EXIT_MAX_REQUEST_SIZE {
+		return nil, fmt.Errorf("readExitRequest: 2: invalid request size")
+	}
+
+	// Die Anfrage wird eingelesen
+	encoded := make([]byte, size)
+	if _, err := io.ReadFull(r, encoded); err != nil {
+		return nil, fmt.Errorf("readExitRequest: 3: " + err.Error())
+	}
+	var req ExitRequest
+	if err := cbor.Unmarshal(encoded, &req); err != nil {
+		return nil, fmt.Errorf("readExitRequest: 4: " + err.Error())
+	}
+	if len(req.Host) == 0 || req.Port == 0 {
+		return nil, fmt.Errorf("readExitRequest: 5: invalid host or port")
+	}
+	return &req, nil
+}
+
+// Stellt eine Verbindung dar, deren Sendeseite einzeln beendet werden kann
+type half_close_conn interface {
+	io.ReadWriteCloser
+	CloseWrite() error
+}
+
+// Überträgt die Daten zweier Verbindungen in beide Richtungen, bis beide Seiten beendet wurden
+func pipeConnections(a half_close_conn, b half_close_conn) {
+	// Es wird in beide Richtungen übertragen, bei einem Fehler werden beide Verbindungen geschlossen
+	var wait sync.WaitGroup
+	transfer := func(dst half_close_conn, src half_close_conn) {
+		defer wait.Done()
+		if _, err := io.Copy(dst, src); err != nil {
+			a.Close()
+			b.Close()
+			return
+		}
+		dst.CloseWrite()
+	}
+	wait.Add(2)
+	go transfer(a, b)
+	go transfer(b, a)
+
+	// Es wird gewartet bis beide Richtungen beendet wurden
+	wait.Wait()
+	a.Close()
+	b.Close()
+}
+
+// Gibt das Stream Protokoll des Kernels zurück
+func getKernelStreamProtocol(k *kernel.Kernel) (*ROUEX_STREAM_PROTOCOL, error) {
+	protocol, err := k.GetKernelProtocolById(STREAM_PROTOCOL_TYPE)
+	if err != nil {
+		return nil, fmt.Errorf("getKernelStreamProtocol: stream protocol not registered")
+	}
+	stream_protocol, ok := protocol.(*ROUEX_STREAM_PROTOCOL)
+	if !ok {
+		return nil, fmt.Errorf("getKernelStreamProtocol: invalid stream protocol")
+	}
+	return stream_protocol, nil
+}
+
+// Stellt eine Regel der Freigabeliste dar
+type exit_rule struct {
+	any_host bool
+	suffix   string
+	host     string
+	network  *net.IPNet
+	port     uint16
+}
+
+// Gibt an ob die Regel auf ein Ziel zutrifft
+func (obj *exit_rule) matches(host string, ip net.IP, port uint16) bool {
+	if obj.port != 0 && obj.port != port {
+		return false
+	}
+	if obj.any_host {
+		return true
+	}
+	if obj.network != nil {
+		return obj.network.Contains(ip)
+	}
+	if len(obj.suffix) > 0 {
+		return strings.HasSuffix(host, obj.suffix)
+	}
+	return host == obj.host
+}
+
+// Stellt die Freigaberichtlinie eines Exit Relays dar, es sind nur Ziele und Relays erlaubt welche explizit freigegeben wurden
+type ExitPolicy struct {
+	_rules      []*exit_rule
+	_clients    map[string]bool
+	_any_client bool
+}
+
+// Gibt an ob ein Relay das Exit Relay verwenden darf, ohne Angabe von Relays ist kein Relay zugelassen
+func (obj *ExitPolicy) IsClientAllowed(pkey *btcec.PublicKey) bool {
+	if obj._any_client {
+		return true
+	}
+	return obj._clients[hex.EncodeToString(pkey.SerializeCompressed())]
+}
+
+// Gibt an ob es sich um eine öffentliche IP Adresse handelt
+func _is_public_exit_ip(ip net.IP) bool {
+	return ip.IsGlobalUnicast() && !ip.IsPrivate() && !ip.IsLoopback() && !ip.IsLinkLocalUnicast()
+}
+
+// Gibt an ob eine Verbindung zu einer aufgelösten Adresse erlaubt ist, Regeln ohne Netzwerk (Hostnamen und '*')
+// erlauben nur öffentliche Adressen, nicht öffentliche Adressen müssen durch eine Netzwerk Regel freigegeben sein
+func (obj *ExitPolicy) IsAllowed(host string, ip net.IP, port uint16) bool {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	is_public := _is_public_exit_ip(ip)
+	for _, rule := range obj._rules {
+		if rule.network == nil && !is_public {
+			continue
+		}
+		if rule.matches(host, ip, port) {
+			return true
+		}
+	}
+	return false
+}
+
+// Ließt eine Regel im Format host:port ein, als Host sind '*', '*.domain', Netzwerke in CIDR Schreibweise,
+// IP Adressen und Hostnamen zulässig, als Port eine Nummer oder '*'
+func parseExitRule(value string) (*exit_rule, error) {
+	// Der Host und der Port werden getrennt
+	host, port_str, err := net.SplitHostPort(strings.TrimSpace(value))
+	if err != nil {
+		return nil, fmt.Errorf("parseExitRule: 1: " + err.Error())
+	}
+
+	// Der Port wird eingelesen
+	rule := &exit_rule{}
+	if port_str != "*" {
+		port, err := strconv.ParseUint(port_str, 10, 16)
+		if err != nil || port == 0 {
+			return nil, fmt.Errorf("parseExitRule: 2: invalid port " + port_str)
+		}
+		rule.port = uint16(port)
+	}
+
+	// Der Host wird eingelesen
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	if host == "*" {
+		rule.any_host = true
+	} else if strings.HasPrefix(host, "*.") {
+		rule.suffix = host[1:]
+	} else if _, network, err := net.ParseCIDR(host); err == nil {
+		rule.network = network
+	} else if ip := net.ParseIP(host); ip != nil {
+		bits := 8 * net.IPv6len
+		if ip.To4() != nil {
+			ip = ip.To4()
+			bits = 8 * net.IPv4len
+		}
+		rule.network = &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}
+	} else if len(host) > 0 {
+		rule.host = host
+	} else {
+		return nil, fmt.Errorf("parseExitRule: 3: empty host")
+	}
+
+	// Die Regel wird zurückgegeben
+	return rule, nil
+}
+
+// Erstellt eine neue Freigaberichtlinie aus den erlaubten Zielen und den zugelassenen Relays, mit '*' sind alle Relays zugelassen
+func ParseExitPolicy(allow []string, clients []string) (*ExitPolicy, error) {
+	// Die Regeln werden eingelesen
+	policy := &ExitPolicy{_clients: make(map[string]bool)}
+	for _, item := range allow {
+		rule, err := parseExitRule(item)
+		if err != nil {
+			return nil, fmt.Errorf("ParseExitPolicy: " + err.Error())
+		}
+		policy._rules = append(policy._rules, rule)
+	}
+
+	// Die zugelassenen Relays werden eingelesen
+	for _, item := range clients {
+		if strings.TrimSpace(item) == "*" {
+			policy._any_client = true
+			continue
+		}
+		pkey, err := utils.ConvertAddressToPublicKey(strings.TrimSpace(item))
+		if err != nil {
+			return nil, fmt.Errorf("ParseExitPolicy: invalid client address " + item)
+		}
+		policy._clients[hex.EncodeToString(pkey.SerializeCompressed())] = true
+	}
+
+	// Die Richtlinie wird zurückgegeben
+	return policy, nil
+}
+
+// Stellt das Exit Protokoll dar, eingehende Streams werden gemäß der Freigaberichtlinie mit TCP Diensten verbunden
+type ROUEX_EXIT_PROTOCOL struct {
+	_objid    string
+	_kernel   *kernel.Kernel
+	_lock     *sync.Mutex
+	_policy   *ExitPolicy
+	_active   uint64
+	_total    uint64
+	_rejected uint64
+}
+
+// Gibt die Aktuelle Freigaberichtlinie zurück
+func (obj *ROUEX_EXIT_PROTOCOL) _get_policy() *ExitPolicy {
+	obj._lock.Lock()
+	defer obj._lock.Unlock()
+	return obj._policy
+}
+
+// Ersetzt die Freigaberichtlinie, bestehende Verbindungen bleiben erhalten
+func (obj *ROUEX_EXIT_PROTOCOL) SetPolicy(policy *ExitPolicy) {
+	obj._lock.Lock()
+	obj._policy = policy
+	obj._lock.Unlock()
+	obj._kernel.Logger(logging.PROTOCOL).Info("ROUEX_EXIT_PROTOCOL: policy updated", "rules", len(policy._rules))
+}
+
+// Baut die Verbindung zu einem Ziel auf, sofern es von der Richtlinie erlaubt ist
+func (obj *ROUEX_EXIT_PROTOCOL) _dial(req *ExitRequest) (*net.TCPConn, uint8) {
+	// Die Adressen des Ziels werden ermittelt
+	var ips []net.IP
+	if ip := net.ParseIP(req.Host); ip != nil {
+		ips = []net.IP{ip}
+	} else {
+		resolved, err := net.LookupIP(req.Host)
+		if err != nil {
+			return nil, EXIT_STATUS_UNREACHABLE
+		}
+		ips = resolved
+	}
+
+	// Es wird die erste erlaubte Adresse verwendet, so wird die geprüfte Adresse auch verbunden
+	policy := obj._get_policy()
+	for _, ip := range ips {
+		if !policy.IsAllowed(req.Host, ip, req.Port) {
+			continue
+		}
+		conn, err := net.DialTimeout("tcp", net.JoinHostPort(ip.String(), strconv.Itoa(int(req.Port))), EXIT_DIAL_TIMEOUT)
+		if err != nil {
+			if errors.Is(err, syscall.ECONNREFUSED) {
+				return nil, EXIT_STATUS_REFUSED
+			}
+			return nil, EXIT_STATUS_UNREACHABLE
+		}
+		return conn.(*net.TCPConn), EXIT_STATUS_OK
+	}
+	return nil, EXIT_STATUS_NOT_ALLOWED
+}
+
+// Verarbeitet einen eingehenden Stream
+func (obj *ROUEX_EXIT_PROTOCOL) _handle_stream(stream *StreamConn) {
+	// Es wird geprüft ob das Relay das Exit Relay verwenden darf
+	client := hex.EncodeToString(stream.RemotePublicKey().SerializeCompressed())
+	if !obj._get_policy().IsClientAllowed(stream.RemotePublicKey()) {
+		obj._kernel.Logger(logging.PROTOCOL).Info("ROUEX_EXIT_PROTOCOL: relay not allowed", "relay", client)
+		obj._reject(stream, EXIT_STATUS_NOT_ALLOWED)
+		return
+	}
+
+	// Die Anfrage wird mit einem Zeitlimit eingelesen, nach Ablauf wird der Stream geschlossen
+	deadline := time.AfterFunc(EXIT_REQUEST_TIMEOUT, func() { stream.Close() })
+	req, err := readExitRequest(stream)
+	deadline.Stop()
+	if err != nil {
+		obj._kernel.Logger(logging.PROTOCOL).Warn("ROUEX_EXIT_PROTOCOL: invalid request", "relay", client, "error", err.Error())
+		obj._reject(stream, EXIT_STATUS_INVALID)
+		return
+	}
+
+	// Die Verbindung zum Ziel wird aufgebaut
+	target := net.JoinHostPort(req.Host, strconv.Itoa(int(req.Port)))
+	conn, status := obj._dial(req)
+	if status != EXIT_STATUS_OK {
+		obj._kernel.Logger(logging.PROTOCOL).Info("ROUEX_EXIT_PROTOCOL: connection rejected", "relay", client, "target", target, "status", status)
+		obj._reject(stream, status)
+		return
+	}
+
+	// Die Gegenseite wird über den Verbindungsaufbau informiert
+	if _, err := stream.Write([]byte{EXIT_STATUS_OK}); err != nil {
+		conn.Close()
+		stream.Close()
+		return
+	}
+
+	// Log
+	obj._kernel.Logger(logging.PROTOCOL).Info("ROUEX_EXIT_PROTOCOL: connection opened", "relay", client, "target", target)
+	obj._lock.Lock()
+	obj._active++
+	obj._total++
+	obj._lock.Unlock()
+
+	// Die Daten werden übertragen bis beide Seiten beendet wurden
+	pipeConnections(stream, conn)
+
+	// Log
+	obj._lock.Lock()
+	obj._active--
+	obj._lock.Unlock()
+	obj._kernel.Logger(logging.PROTOCOL).Info("ROUEX_EXIT_PROTOCOL: connection closed", "relay", client, "target", target)
+}
+
+// Lehnt einen Stream mit einem Status ab
+func (obj *ROUEX_EXIT_PROTOCOL) _reject(stream *StreamConn, status uint8) {
+	obj._lock.Lock()
+	obj._rejected++
+	obj._lock.Unlock()
+	stream.Write([]byte{status})
+	stream.Close()
+}
+
+// Nimmt die Streams auf dem Exit Port entgegen, solange der Kernel ausgeführt wird
+func (obj *ROUEX_EXIT_PROTOCOL) _serve() {
+	// Es wird gewartet bis der Kernel ausgeführt wird, erst dann sind alle Protokolle registriert
+	select {
+	case <-obj._kernel.Started():
+	case <-obj._kernel.Context().Done():
+		return
+	}
+
+	// Auf dem Exit Port wird gelauscht
+	stream_protocol, err := getKernelStreamProtocol(obj._kernel)
+	if err != nil {
+		obj._kernel.Logger(logging.PROTOCOL).Warn("ROUEX_EXIT_PROTOCOL: can't start exit", "error", err.Error())
+		return
+	}
+	listener, err := stream_protocol.ListenStream(EXIT_STREAM_PORT)
+	if err != nil {
+		obj._kernel.Logger(logging.PROTOCOL).Warn("ROUEX_EXIT_PROTOCOL: can't start exit", "error", err.Error())
+		return
+	}
+
+	// Der Listener wird geschlossen sobald der Kernel beendet wurde
+	go func() {
+		<-obj._kernel.Context().Done()
+		listener.Close()
+	}()
+
+	// Log
+	obj._kernel.Logger(logging.PROTOCOL).Info("ROUEX_EXIT_PROTOCOL: exit started", "port", EXIT_STREAM_PORT, "rules", len(obj._get_policy()._rules))
+
+	// Die Streams werden in eigenen Threads verarbeitet
+	for {
+		stream, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		go obj._handle_stream(stream)
+	}
+}
+
+// Das Exit Protokoll empfängt keine eigenen Pakete, die Daten werden über das Stream Protokoll übertragen
+func (obj *ROUEX_EXIT_PROTOCOL) EnterRecivedPackage(pckage *addresspackages.AddressLayerPackage) error {
+	return fmt.Errorf("error: invalid_package: exit protocol uses streams")
+}
+
+// Nimmt eintreffende Steuer Befehele entgegen
+func (obj *ROUEX_EXIT_PROTOCOL) EnterCommandData(command string, arguments [][]byte, process_api_conn *kernel.APIProcessConnectionWrapper) (map[string]interface{}, error) {
+	if command == "info" {
+		obj._lock.Lock()
+		defer obj._lock.Unlock()
+		reval := make(map[string]interface{})
+		reval["port"] = uint64(EXIT_STREAM_PORT)
+		reval["rules"] = uint64(len(obj._policy._rules))
+		reval["active"] = obj._active
+		reval["total"] = obj._total
+		reval["rejected"] = obj._rejected
+		return reval, nil
+	} else {
+		return nil, fmt.Errorf("invalid command")
+	}
+}
+
+// Registriert den Kernel im Protokoll
+func (obj *ROUEX_EXIT_PROTOCOL) RegisterKernel(kernel *kernel.Kernel) error {
+	obj._lock.Lock()
+	if obj._kernel != nil {
+		obj._lock.Unlock()
+		return fmt.Errorf("kernel always registered")
+	}
+	obj._kernel = kernel
+	obj._lock.Unlock()
+
+	// Der Exit Port wird geöffnet sobald der Kernel ausgeführt wird
+	go obj._serve()
+
+	// Log
+	obj._kernel.Logger(logging.PROTOCOL).Info("ROUEX_EXIT_PROTOCOL: kernel registrated", "id", kernel.GetKernelID(), "object_id", obj._objid)
+	return nil
+}
+
+// Gibt den Namen des Protokolles zurück
+func (obj *ROUEX_EXIT_PROTOCOL) GetProtocolName() string {
+	return "ROUEX_EXIT_PROTOCOL"
+}
+
+// Gibt die ObjektID des Protokolls zurück
+func (obj *ROUEX_EXIT_PROTOCOL) GetObjectId() string {
+	return obj._objid
+}
+
+// Erzeugt ein neues Exit Protokoll mit einer Freigaberichtlinie
+func NEW_ROUEX_EXIT_PROTOCOL_HANDLER(policy *ExitPolicy) *ROUEX_EXIT_PROTOCOL {
+	if policy == nil {
+		policy = &ExitPolicy{_clients: make(map[string]bool)}
+	}
+	return &ROUEX_EXIT_PROTOCOL{
+		_lock:   &sync.Mutex{},
+		_objid:  utils.RandStringRunes(12),
+		_policy: policy,
+	}
+}
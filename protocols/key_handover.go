@@ -0,0 +1,164 @@
+package protocols
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"sync"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	addresspackages "github.com/fluffelpuff/RoueX/address_packages"
+	"github.com/fluffelpuff/RoueX/kernel"
+	"github.com/fluffelpuff/RoueX/logging"
+	"github.com/fluffelpuff/RoueX/utils"
+)
+
+// Gibt den Protokolltypen des Schlüsselübergabe Protokolls an
+const KEY_HANDOVER_PROTOCOL_TYPE uint8 = 2
+
+// Stellt das Schlüsselübergabe Protokoll dar
+type ROUEX_KEY_HANDOVER_PROTOCOL struct {
+	_objid  string
+	_kernel *kernel.Kernel
+	_lock   *sync.Mutex
+}
+
+// Gibt an ob ein Öffentlicher Schlüssel Teil einer Übergabekette ist
+func _chain_contains_key(chain []kernel.KeyHandover, pkey *btcec.PublicKey) bool {
+	serialized := pkey.SerializeCompressed()
+	for i := range chain {
+		if bytes.Equal(chain[i].OldKey, serialized) || bytes.Equal(chain[i].NewKey, serialized) {
+			return true
+		}
+	}
+	return false
+}
+
+// Sendet die Übergabekette des eigenen Schlüssels an ein Relay, die Kette wird erst gesendet wenn der neue Schlüssel aktiv ist
+func (obj *ROUEX_KEY_HANDOVER_PROTOCOL) _send_chain(dest *btcec.PublicKey) error {
+	// Sollte keine Kette vorhanden sein, wird der Vorgang abgebrochen
+	chain := obj._kernel.GetKeyHandoverChain()
+	if len(chain) == 0 {
+		return nil
+	}
+
+	// Solange der neue Schlüssel erst nach einem Neustart aktiv ist, wird die Kette nicht gesendet,
+	// die Gegenseite würde sonst einen Schlüssel erwarten, welcher noch nicht verwendet wird
+	if obj._kernel.HasPendingKeyRotation() {
+		return nil
+	}
+
+	// Die Kette wird in Bytes umgewandelt
+	encoded, err := kernel.EncodeKeyHandoverChain(chain)
+	if err != nil {
+		return fmt.Errorf("_send_chain: 1: " + err.Error())
+	}
+
+	// Die Kette wird an das Relay übermittelt
+	if _, err := obj._kernel.EnterBytesAndSendL2PackageToNetwork(KEY_HANDOVER_PROTOCOL_TYPE, encoded, dest, false); err != nil {
+		return fmt.Errorf("_send_chain: 2: " + err.Error())
+	}
+
+	// Der Vorgang wurde ohne Fehler durchgeführt
+	return nil
+}
+
+// Rotiert den Relay Schlüssel, die Übergabe wird nach dem Neustart beim Verbindungsaufbau an jedes Relay gesendet
+func (obj *ROUEX_KEY_HANDOVER_PROTOCOL) _rotate_key() (map[string]interface{}, error) {
+	// Der Schlüssel wird rotiert
+	new_key, err := obj._kernel.RotateRelayKey()
+	if err != nil {
+		return nil, err
+	}
+
+	// Log
+	obj._kernel.Logger(logging.PROTOCOL).Info("ROUEX_KEY_HANDOVER_PROTOCOL: key handover stored, distributed after restart", "public_key", hex.EncodeToString(new_key.SerializeCompressed()))
+
+	// Der neue Schlüssel wird zurückgegeben
+	return map[string]interface{}{"public_key": hex.EncodeToString(new_key.SerializeCompressed())}, nil
+}
+
+// Nimmt eingetroffene Pakete aus dem Netzwerk Entgegen
+func (obj *ROUEX_KEY_HANDOVER_PROTOCOL) EnterRecivedPackage(pckage *addresspackages.AddressLayerPackage) error {
+	// Übergaben werden nur von Direkt verbundenen Relays angenommen
+	if !obj._kernel.HasDirectRoute(&pckage.Sender) {
+		return fmt.Errorf("error: key handover from not connected relay")
+	}
+
+	// Es wird versucht die Kette einzulesen, dabei werden alle Signaturen geprüft
+	chain, err := kernel.DecodeKeyHandoverChain(pckage.Data)
+	if err != nil {
+		return fmt.Errorf("error: invalid_package: " + err.Error())
+	}
+
+	// Die Kette muss vom Besitzer eines der enthaltenen Schlüssel stammen
+	if !_chain_contains_key(chain, &pckage.Sender) {
+		return fmt.Errorf("error: key handover from foreign relay")
+	}
+
+	// Der gepinnte Schlüssel wird aktualisiert, sofern das Relay vertraut wird
+	updated, err := obj._kernel.ApplyKeyHandoverChain(chain)
+	if err != nil {
+		return fmt.Errorf("error: " + err.Error())
+	}
+	if updated {
+		obj._kernel.Logger(logging.PROTOCOL).Info("ROUEX_KEY_HANDOVER_PROTOCOL: key handover accepted", "relay", hex.EncodeToString(pckage.Sender.SerializeCompressed()))
+	}
+
+	// Der Vorgang wurde ohne Fehler durchgeführt
+	return nil
+}
+
+// Wird aufgerufen wenn eine Verbindung mit einem neuen Relay aufgebaut wurde
+func (obj *ROUEX_KEY_HANDOVER_PROTOCOL) RelayConnected(relay *kernel.Relay) {
+	if err := obj._send_chain(relay.GetPublicKey()); err != nil {
+		obj._kernel.Logger(logging.PROTOCOL).Warn("ROUEX_KEY_HANDOVER_PROTOCOL: error by sending key handover", "relay", relay.GetPublicKeyHexString(), "error", err.Error())
+	}
+}
+
+// Wird aufgerufen wenn keine Verbindung mehr mit einem Relay besteht
+func (obj *ROUEX_KEY_HANDOVER_PROTOCOL) RelayDisconnected(relay *kernel.Relay, dests []*btcec.PublicKey) {
+}
+
+// Nimmt eintreffende Steuer Befehele entgegen
+func (obj *ROUEX_KEY_HANDOVER_PROTOCOL) EnterCommandData(command string, arguments [][]byte, process_api_conn *kernel.APIProcessConnectionWrapper) (map[string]interface{}, error) {
+	if command == "rotate_key" {
+		return obj._rotate_key()
+	} else {
+		return nil, fmt.Errorf("invalid command")
+	}
+}
+
+// Registriert den Kernel im Protokoll
+func (obj *ROUEX_KEY_HANDOVER_PROTOCOL) RegisterKernel(kernel *kernel.Kernel) error {
+	obj._lock.Lock()
+	if obj._kernel != nil {
+		obj._lock.Unlock()
+		return fmt.Errorf("kernel always registered")
+	}
+	obj._kernel = kernel
+	obj._lock.Unlock()
+	kernel.RegisterRelayStateObserver(obj)
+	obj._kernel.Logger(logging.PROTOCOL).Info("ROUEX_KEY_HANDOVER_PROTOCOL: kernel registrated", "id", kernel.GetKernelID(), "object_id", obj._objid)
+	return nil
+}
+
+// Die Pakete des Protokolles werden als Steuerpakete übertragen
+func (obj *ROUEX_KEY_HANDOVER_PROTOCOL) IsControlProtocol() bool {
+	return true
+}
+
+// Gibt den Namen des Protokolles zurück
+func (obj *ROUEX_KEY_HANDOVER_PROTOCOL) GetProtocolName() string {
+	return "ROUEX_KEY_HANDOVER_PROTOCOL"
+}
+
+// Gibt die ObjektID des Protokolls zurück
+func (obj *ROUEX_KEY_HANDOVER_PROTOCOL) GetObjectId() string {
+	return obj._objid
+}
+
+// Erzeugt ein neues Schlüsselübergabe Protokoll
+func NEW_ROUEX_KEY_HANDOVER_PROTOCOL_HANDLER() *ROUEX_KEY_HANDOVER_PROTOCOL {
+	return &ROUEX_KEY_HANDOVER_PROTOCOL{_lock: &sync.Mutex{}, _objid: utils.RandStringRunes(12)}
+}
@@ -0,0 +1,304 @@
+package protocols
+
+import (
+	"encoding/hex"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	addresspackages "github.com/fluffelpuff/RoueX/address_packages"
+	"github.com/fluffelpuff/RoueX/kernel"
+	"github.com/fluffelpuff/RoueX/logging"
+	"github.com/fluffelpuff/RoueX/utils"
+	"github.com/fxamacker/cbor"
+)
+
+// Gibt den Protokolltypen des Peer Austausch Protokolls an
+const PEER_EXCHANGE_PROTOCOL_TYPE uint8 = 8
+
+// Gibt an, in welchem Abstand bekannte Relays bei den Nachbarn abgefragt werden, solange zu wenige ausgehende Verbindungen bestehen
+const PEER_EXCHANGE_INTERVAL = 1 * time.Minute
+
+// Gibt an, wie lange auf eine Antwort gewartet wird und wie oft ein Nachbar maximal eine Anfrage senden darf
+const PEER_EXCHANGE_TIMEOUT = 30 * time.Second
+
+// Gibt an, wie viele Relays maximal in einer Antwort übertragen werden
+const PEER_EXCHANGE_MAX_PEERS = 64
+
+// Definiert die Typen einer Peer Austausch Nachricht
+const (
+	PEER_EXCHANGE_REQUEST  = uint8(0)
+	PEER_EXCHANGE_RESPONSE = uint8(1)
+)
+
+// Stellt ein ausgetauschtes Relay dar, 'Protocol' gibt den Namen des Client Moduls an
+type ExchangedPeer struct {
+	PublicKey []byte `cbor:"1,keyasint"`
+	EndPoint  string `cbor:"2,keyasint"`
+	Protocol  string `cbor:"3,keyasint"`
+}
+
+// Stellt eine Peer Austausch Nachricht dar
+type PeerExchangeMessage struct {
+	Type  uint8           `cbor:"1,keyasint"`
+	Peers []ExchangedPeer `cbor:"2,keyasint"`
+}
+
+// Stellt das Peer Austausch Protokoll dar, es fragt verbundene Relays nach Endpunkten weiterer Relays
+type ROUEX_PEER_EXCHANGE_PROTOCOL struct {
+	_objid        string
+	_kernel       *kernel.Kernel
+	_lock         *sync.Mutex
+	_requested    map[string]time.Time
+	_answered     map[string]time.Time
+	_loop_running bool
+}
+
+// Versendet eine Peer Austausch Nachricht an ein Relay
+func (obj *ROUEX_PEER_EXCHANGE_PROTOCOL) _send_message(msg *PeerExchangeMessage, dest *btcec.PublicKey) error {
+	// Die Nachricht wird in Bytes umgewandelt
+	encoded, err := cbor.Marshal(msg, cbor.EncOptions{})
+	if err != nil {
+		return fmt.Errorf("_send_message: 1: " + err.Error())
+	}
+
+	// Die Nachricht wird an das Relay übermittelt
+	if _, err := obj._kernel.EnterBytesAndSendL2PackageToNetwork(PEER_EXCHANGE_PROTOCOL_TYPE, encoded, dest, false); err != nil {
+		return fmt.Errorf("_send_message: 2: " + err.Error())
+	}
+
+	// Der Vorgang wurde ohne Fehler durchgeführt
+	return nil
+}
+
+// Fragt ein Relay nach den ihm bekannten Relays, solange eine Anfrage offen ist wird keine weitere gesendet
+func (obj *ROUEX_PEER_EXCHANGE_PROTOCOL) _request_peers(dest *btcec.PublicKey) {
+	// Es wird geprüft ob bereits eine Anfrage offen ist
+	dest_hex := hex.EncodeToString(dest.SerializeCompressed())
+	obj._lock.Lock()
+	if requested_at, found := obj._requested[dest_hex]; found && time.Since(requested_at) < PEER_EXCHANGE_TIMEOUT {
+		obj._lock.Unlock()
+		return
+	}
+	obj._requested[dest_hex] = time.Now()
+	obj._lock.Unlock()
+
+	// Die Anfrage wird gesendet
+	if err := obj._send_message(&PeerExchangeMessage{Type: PEER_EXCHANGE_REQUEST}, dest); err != nil {
+		obj._kernel.Logger(logging.PROTOCOL).Warn("ROUEX_PEER_EXCHANGE_PROTOCOL: error by sending request", "relay", dest_hex, "error", err.Error())
+	}
+}
+
+// Erstellt die Liste der Relays, welche einem Nachbarn mitgeteilt werden, es werden nur die eigenen angekündigten
+// Endpunkte sowie die Endpunkte von Relays übermittelt, zu welchen eine ausgehende Verbindung besteht, Vertrauenswürdige
+// Relays werden nur weitergegeben wenn dies durch die Richtlinie erlaubt wurde
+func (obj *ROUEX_PEER_EXCHANGE_PROTOCOL) _build_peers_for(neighbor *btcec.PublicKey) []ExchangedPeer {
+	// Die eigenen Endpunkte werden hinzugefügt
+	own_key := obj._kernel.GetPublicKey().SerializeCompressed()
+	result := make([]ExchangedPeer, 0)
+	for _, item := range obj._kernel.GetRelayDescriptorEndPoints() {
+		result = append(result, ExchangedPeer{PublicKey: own_key, EndPoint: item.EndPoint, Protocol: item.Module})
+	}
+
+	// Die Relays mit einer ausgehenden Verbindung werden hinzugefügt, deren Endpunkte sind nachweislich erreichbar
+	share_trusted := obj._kernel.GetBootstrapPolicy().ShareTrustedPeers
+	for _, relay := range obj._kernel.GetOutboundRelays() {
+		if len(result) >= PEER_EXCHANGE_MAX_PEERS {
+			break
+		}
+		if relay.GetPublicKey().IsEqual(neighbor) || len(relay.GetEndpoint()) == 0 || len(relay.GetProtocol()) == 0 {
+			continue
+		}
+		if !share_trusted && obj._is_trusted(relay) {
+			continue
+		}
+		result = append(result, ExchangedPeer{PublicKey: relay.GetPublicKey().SerializeCompressed(), EndPoint: relay.GetEndpoint(), Protocol: relay.GetProtocol()})
+	}
+
+	// Die Liste wird zurückgegeben
+	return result
+}
+
+// Gibt an ob es sich um ein Vertrauenswürdiges Relay handelt, im Zweifel wird das Relay als Vertrauenswürdig betrachtet
+func (obj *ROUEX_PEER_EXCHANGE_PROTOCOL) _is_trusted(relay *kernel.Relay) bool {
+	if relay.IsTrusted() {
+		return true
+	}
+	trusted_relay, err := obj._kernel.GetTrustedRelayByPublicKey(relay.GetPublicKey())
+	return err != nil || trusted_relay != nil
+}
+
+// Beantwortet eine Anfrage eines Nachbarn, jeder Nachbar erhält innerhalb der Wartezeit höchstens eine Antwort,
+// die Sperre bleibt auch bei einem erneuten Verbindungsaufbau des Nachbarn bestehen
+func (obj *ROUEX_PEER_EXCHANGE_PROTOCOL) _enter_request(neighbor *btcec.PublicKey) error {
+	// Abgelaufene Sperren werden entfernt
+	neighbor_hex := hex.EncodeToString(neighbor.SerializeCompressed())
+	obj._lock.Lock()
+	for item_hex, answered_at := range obj._answered {
+		if time.Since(answered_at) >= PEER_EXCHANGE_TIMEOUT {
+			delete(obj._answered, item_hex)
+		}
+	}
+
+	// Es wird geprüft ob der Nachbar bereits eine Antwort erhalten hat
+	if answered_at, found := obj._answered[neighbor_hex]; found && time.Since(answered_at) < PEER_EXCHANGE_TIMEOUT {
+		obj._lock.Unlock()
+		return fmt.Errorf("error: peer exchange request rate limited")
+	}
+	obj._answered[neighbor_hex] = time.Now()
+	obj._lock.Unlock()
+
+	// Die Antwort wird gesendet
+	response := &PeerExchangeMessage{Type: PEER_EXCHANGE_RESPONSE, Peers: obj._build_peers_for(neighbor)}
+	if err := obj._send_message(response, neighbor); err != nil {
+		return fmt.Errorf("error: " + err.Error())
+	}
+
+	// Der Vorgang wurde ohne Fehler durchgeführt
+	return nil
+}
+
+// Verarbeitet die Antwort eines Nachbarn, es werden nur Antworten auf eigene Anfragen angenommen
+func (obj *ROUEX_PEER_EXCHANGE_PROTOCOL) _enter_response(msg *PeerExchangeMessage, neighbor *btcec.PublicKey) error {
+	// Es wird geprüft ob eine Anfrage an den Nachbarn gesendet wurde
+	neighbor_hex := hex.EncodeToString(neighbor.SerializeCompressed())
+	obj._lock.Lock()
+	requested_at, found := obj._requested[neighbor_hex]
+	delete(obj._requested, neighbor_hex)
+	obj._lock.Unlock()
+	if !found || time.Since(requested_at) >= PEER_EXCHANGE_TIMEOUT {
+		return fmt.Errorf("error: unsolicited peer exchange response")
+	}
+	if len(msg.Peers) > PEER_EXCHANGE_MAX_PEERS {
+		return fmt.Errorf("error: too many peers in peer exchange response")
+	}
+
+	// Die Relays werden als bekannte Relays gespeichert, ob der Endpunkt zum Schlüssel gehört wird beim Verbindungsaufbau geprüft,
+	// nicht öffentliche Endpunkte und Relays über dem Kontingent des Nachbarn werden verworfen
+	added := 0
+	for _, item := range msg.Peers {
+		pkey, err := btcec.ParsePubKey(item.PublicKey)
+		if err != nil {
+			return fmt.Errorf("error: " + err.Error())
+		}
+		if obj._kernel.AddKnownPeer(kernel.BootstrapPeer{PublicKey: pkey, EndPoint: item.EndPoint, Protocol: item.Protocol}, neighbor) {
+			added++
+		}
+	}
+
+	// Log
+	obj._kernel.Logger(logging.PROTOCOL).Info("ROUEX_PEER_EXCHANGE_PROTOCOL: peers recived", "relay", neighbor_hex, "total", len(msg.Peers), "new", added)
+
+	// Der Vorgang wurde ohne Fehler durchgeführt
+	return nil
+}
+
+// Fragt in regelmäßigen Abständen alle Nachbarn nach bekannten Relays, solange zu wenige ausgehende Verbindungen bestehen
+func (obj *ROUEX_PEER_EXCHANGE_PROTOCOL) _exchange_loop() {
+	ticker := time.NewTicker(PEER_EXCHANGE_INTERVAL)
+	defer ticker.Stop()
+	for done := false; !done; {
+		select {
+		case <-obj._kernel.Context().Done():
+			done = true
+		case <-ticker.C:
+			if obj._kernel.IsDraining() || !obj._kernel.NeedsMorePeers() {
+				continue
+			}
+			for _, relay := range obj._kernel.GetConnectedRelays() {
+				obj._request_peers(relay.GetPublicKey())
+			}
+		}
+	}
+
+	// Es wird Signalisiert dass die Schleife beendet wurde
+	obj._lock.Lock()
+	obj._loop_running = false
+	obj._lock.Unlock()
+}
+
+// Nimmt eingetroffene Pakete aus dem Netzwerk Entgegen
+func (obj *ROUEX_PEER_EXCHANGE_PROTOCOL) EnterRecivedPackage(pckage *addresspackages.AddressLayerPackage) error {
+	// Peer Austausch Nachrichten werden nur von Direkt verbundenen Relays angenommen
+	if !obj._kernel.HasDirectRoute(&pckage.Sender) {
+		return fmt.Errorf("error: peer exchange message from not connected relay")
+	}
+
+	// Es wird versucht das Paket einzulesen
+	var msg PeerExchangeMessage
+	if err := cbor.Unmarshal(pckage.Data, &msg); err != nil {
+		return fmt.Errorf("error: invalid_package: " + err.Error())
+	}
+
+	// Es wird geprüft ob es sich um eine Anfrage oder um eine Antwort handelt
+	switch msg.Type {
+	case PEER_EXCHANGE_REQUEST:
+		return obj._enter_request(&pckage.Sender)
+	case PEER_EXCHANGE_RESPONSE:
+		return obj._enter_response(&msg, &pckage.Sender)
+	default:
+		return fmt.Errorf("error: invalid package type")
+	}
+}
+
+// Wird aufgerufen wenn eine Verbindung mit einem neuen Relay aufgebaut wurde, sollten zu wenige ausgehende
+// Verbindungen bestehen, wird das Relay nach bekannten Relays gefragt
+func (obj *ROUEX_PEER_EXCHANGE_PROTOCOL) RelayConnected(relay *kernel.Relay) {
+	// Die Schleife für die regelmäßigen Anfragen wird gestartet, sofern sie noch nicht ausgeführt wird
+	obj._lock.Lock()
+	if !obj._loop_running {
+		obj._loop_running = true
+		go obj._exchange_loop()
+	}
+	obj._lock.Unlock()
+
+	// Während der Kernel beendet wird oder wenn ausreichend Verbindungen bestehen, wird keine Anfrage gesendet
+	if obj._kernel.IsDraining() || !obj._kernel.NeedsMorePeers() {
+		return
+	}
+
+	// Das Relay wird nach bekannten Relays gefragt
+	obj._request_peers(relay.GetPublicKey())
+}
+
+// Wird aufgerufen wenn keine Verbindung mehr mit einem Relay besteht, offene Anfragen werden verworfen, die Sperren bleiben bis zu ihrem Ablauf bestehen
+func (obj *ROUEX_PEER_EXCHANGE_PROTOCOL) RelayDisconnected(relay *kernel.Relay, dests []*btcec.PublicKey) {
+	obj._lock.Lock()
+	delete(obj._requested, relay.GetPublicKeyHexString())
+	obj._lock.Unlock()
+}
+
+// Nimmt eintreffende Steuer Befehele entgegen
+func (obj *ROUEX_PEER_EXCHANGE_PROTOCOL) EnterCommandData(command string, arguments [][]byte, process_api_conn *kernel.APIProcessConnectionWrapper) (map[string]interface{}, error) {
+	return nil, fmt.Errorf("invalid command")
+}
+
+// Registriert den Kernel im Protokoll
+func (obj *ROUEX_PEER_EXCHANGE_PROTOCOL) RegisterKernel(kernel *kernel.Kernel) error {
+	obj._lock.Lock()
+	if obj._kernel != nil {
+		obj._lock.Unlock()
+		return fmt.Errorf("kernel always registered")
+	}
+	obj._kernel = kernel
+	obj._lock.Unlock()
+	kernel.RegisterRelayStateObserver(obj)
+	obj._kernel.Logger(logging.PROTOCOL).Info("ROUEX_PEER_EXCHANGE_PROTOCOL: kernel registrated", "id", kernel.GetKernelID(), "object_id", obj._objid)
+	return nil
+}
+
+// Gibt den Namen des Protokolles zurück
+func (obj *ROUEX_PEER_EXCHANGE_PROTOCOL) GetProtocolName() string {
+	return "ROUEX_PEER_EXCHANGE_PROTOCOL"
+}
+
+// Gibt die ObjektID des Protokolls zurück
+func (obj *ROUEX_PEER_EXCHANGE_PROTOCOL) GetObjectId() string {
+	return obj._objid
+}
+
+// Erzeugt ein neues Peer Austausch Protokoll
+func NEW_ROUEX_PEER_EXCHANGE_PROTOCOL_HANDLER() *ROUEX_PEER_EXCHANGE_PROTOCOL {
+	return &ROUEX_PEER_EXCHANGE_PROTOCOL{_lock: &sync.Mutex{}, _objid: utils.RandStringRunes(12), _requested: make(map[string]time.Time), _answered: make(map[string]time.Time)}
+}
@@ -3,7 +3,6 @@ package protocols
 import (
 	"encoding/hex"
 	"fmt"
-	"log"
 	"math"
 	"sync"
 	"time"
@@ -12,10 +11,14 @@ import (
 	addresspackages "github.com/fluffelpuff/RoueX/address_packages"
 	"github.com/fluffelpuff/RoueX/kernel"
 	"github.com/fluffelpuff/RoueX/kernel/extra"
+	"github.com/fluffelpuff/RoueX/logging"
 	"github.com/fluffelpuff/RoueX/utils"
 	"github.com/fxamacker/cbor"
 )
 
+// Gibt den Protokolltypen des Ping Pong Protokolls an
+const PING_PONG_PROTOCOL_TYPE uint8 = 0
+
 // Stellt ein Ping Paket dar
 type PingPongPackage struct {
 	Type uint8
@@ -264,7 +267,7 @@ func (obj *ROUEX_PING_PONG_PROTOCOL) _add_ping_process(ping_proc *rouex_entry, p
 	}
 
 	// Log
-	log.Printf("ROUEX_PING_PONG_PROTOCOL: register new ping process. pid = %s\n", ping_proc.GetId())
+	obj._kernel.Logger(logging.PROTOCOL).Debug("ROUEX_PING_PONG_PROTOCOL: register new ping process", "pid", ping_proc.GetId())
 
 	// Der Vorgang wurde ohne Fehler durchgeführt
 	return nil
@@ -293,7 +296,7 @@ func (obj *ROUEX_PING_PONG_PROTOCOL) _remove_ping_process(ping_proc *rouex_entry
 	}
 
 	// Log
-	log.Printf("ROUEX_PING_PONG_PROTOCOL: ping process removed. pid = %s\n", ping_proc.GetId())
+	obj._kernel.Logger(logging.PROTOCOL).Debug("ROUEX_PING_PONG_PROTOCOL: ping process removed", "pid", ping_proc.GetId())
 }
 
 // Führt einen Ping Prozess durch
@@ -331,7 +334,7 @@ func (obj *ROUEX_PING_PONG_PROTOCOL) _start_ping_pong_process(pkey *btcec.Public
 	}
 
 	// Das Ping Paket wird über das Netzwerk übermittelt
-	sstate, err := obj._kernel.EnterBytesEncryptAndSendL2PackageToNetwork(0, encoded_ping_package, pkey)
+	sstate, err := obj._kernel.EnterBytesEncryptAndSendL2PackageToNetwork(PING_PONG_PROTOCOL_TYPE, encoded_ping_package, pkey)
 	if err != nil {
 		// Der Ping Prozess wird wieder entfernt
 		obj._remove_ping_process(rx_entry, process_api_conn)
@@ -349,7 +352,7 @@ func (obj *ROUEX_PING_PONG_PROTOCOL) _start_ping_pong_process(pkey *btcec.Public
 	// Es wird geprüft ob das Paket übermittelt wurde
 	switch sstate.GetState() {
 	case extra.DROPED:
-		log.Printf("ROUEX_PING_PONG_PROTOCOL: ping process droped. pid = %s\n", proc_id)
+		obj._kernel.Logger(logging.PROTOCOL).Debug("ROUEX_PING_PONG_PROTOCOL: ping process droped", "pid", proc_id)
 		obj._remove_ping_process(rx_entry, process_api_conn)
 		reval["state"] = uint8(extra.DROPED)
 		return reval, nil
@@ -357,7 +360,7 @@ func (obj *ROUEX_PING_PONG_PROTOCOL) _start_ping_pong_process(pkey *btcec.Public
 
 	// Es wird geprüft ob der Vorgang abgebrochen
 	if rx_entry.isaborted() {
-		log.Printf("ROUEX_PING_PONG_PROTOCOL: ping process aborted. pid = %s\n", proc_id)
+		obj._kernel.Logger(logging.PROTOCOL).Debug("ROUEX_PING_PONG_PROTOCOL: ping process aborted", "pid", proc_id)
 		obj._remove_ping_process(rx_entry, process_api_conn)
 		reval["state"] = uint8(ABORTED)
 		return reval, nil
@@ -367,7 +370,7 @@ func (obj *ROUEX_PING_PONG_PROTOCOL) _start_ping_pong_process(pkey *btcec.Public
 	rx_entry.snctime()
 
 	// Log
-	log.Printf("ROUEX_PING_PONG_PROTOCOL: ping package transmitted. pid = %s, total = %d ms\n", proc_id, utils.TimeToMS(time.Now())-utils.TimeToMS(s_ti))
+	obj._kernel.Logger(logging.PROTOCOL).Debug("ROUEX_PING_PONG_PROTOCOL: ping package transmitted", "pid", proc_id, "total", utils.TimeToMS(time.Now())-utils.TimeToMS(s_ti))
 
 	// Es wird auf die Antwort wird gewartet
 	state, err := rx_entry.waitfnc()
@@ -382,29 +385,29 @@ func (obj *ROUEX_PING_PONG_PROTOCOL) _start_ping_pong_process(pkey *btcec.Public
 	// Es wird geprüft das der Vorgang mit einem Response beantwortet wurde
 	switch state {
 	case ABORTED:
-		log.Printf("ROUEX_PING_PONG_PROTOCOL: ping process aborted. pid = %s\n", proc_id)
+		obj._kernel.Logger(logging.PROTOCOL).Debug("ROUEX_PING_PONG_PROTOCOL: ping process aborted", "pid", proc_id)
 		obj._remove_ping_process(rx_entry, process_api_conn)
 		reval["state"] = uint8(ABORTED)
 		return reval, nil
 	case RESPONDED:
-		log.Printf("ROUEX_PING_PONG_PROTOCOL: ping process responded. pid = %s, total = %d ms\n", proc_id, rx_entry.gtimems())
+		obj._kernel.Logger(logging.PROTOCOL).Debug("ROUEX_PING_PONG_PROTOCOL: ping process responded", "pid", proc_id, "total", rx_entry.gtimems())
 		obj._remove_ping_process(rx_entry, process_api_conn)
 		reval["ttime"] = rx_entry.gtimems()
 		reval["state"] = uint8(RESPONDED)
 		return reval, nil
 	case CLOSED_BY_KERNEL:
-		log.Printf("ROUEX_PING_PONG_PROTOCOL: ping process closed by kernel. pid = %s\n", proc_id)
+		obj._kernel.Logger(logging.PROTOCOL).Debug("ROUEX_PING_PONG_PROTOCOL: ping process closed by kernel", "pid", proc_id)
 		obj._remove_ping_process(rx_entry, process_api_conn)
 		reval["state"] = uint8(CLOSED_BY_KERNEL)
 		return reval, nil
 	case TIMEOUT:
-		log.Printf("ROUEX_PING_PONG_PROTOCOL: ping process time out. pid = %s\n", proc_id)
+		obj._kernel.Logger(logging.PROTOCOL).Debug("ROUEX_PING_PONG_PROTOCOL: ping process time out", "pid", proc_id)
 		obj._remove_ping_process(rx_entry, process_api_conn)
 		reval["state"] = uint8(TIMEOUT)
 		return reval, nil
 	default:
 		obj._remove_ping_process(rx_entry, process_api_conn)
-		log.Println("Error by handling connection", state)
+		obj._kernel.Logger(logging.PROTOCOL).Warn("ROUEX_PING_PONG_PROTOCOL: error by handling connection", "state", state)
 		return nil, fmt.Errorf("unkown state")
 	}
 }
@@ -421,10 +424,10 @@ func (obj *ROUEX_PING_PONG_PROTOCOL) _enter_incomming_ping_package(ppp PingPongP
 	}
 
 	// Log
-	log.Println("ROUEX_PING_PONG_PROTOCOL: ping package recived. id = "+ppp.Id, "source = "+hex.EncodeToString(source.SerializeCompressed()))
+	obj._kernel.Logger(logging.PROTOCOL).Debug("ROUEX_PING_PONG_PROTOCOL: ping package recived", "id", ppp.Id, "source", hex.EncodeToString(source.SerializeCompressed()))
 
 	// Das Ping Paket wird über das Netzwerk übermittelt
-	_, err = obj._kernel.EnterBytesEncryptAndSendL2PackageToNetwork(0, encoded_pong_package, source)
+	_, err = obj._kernel.EnterBytesEncryptAndSendL2PackageToNetwork(PING_PONG_PROTOCOL_TYPE, encoded_pong_package, source)
 	if err != nil {
 		return fmt.Errorf("sending error: " + err.Error())
 	}
@@ -511,7 +514,7 @@ func (obj *ROUEX_PING_PONG_PROTOCOL) RegisterKernel(kernel *kernel.Kernel) error
 	}
 	obj._kernel = kernel
 	obj._lock.Unlock()
-	log.Println("ROUEX_PING_PONG_PROTOCOL: kernel registrated. id =", kernel.GetKernelID(), "object-id =", obj._objid)
+	obj._kernel.Logger(logging.PROTOCOL).Info("ROUEX_PING_PONG_PROTOCOL: kernel registrated", "id", kernel.GetKernelID(), "object_id", obj._objid)
 	return nil
 }
 
@@ -0,0 +1,315 @@
+package protocols
+
+import (
+	"encoding/hex"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	addresspackages "github.com/fluffelpuff/RoueX/address_packages"
+	"github.com/fluffelpuff/RoueX/kernel"
+	"github.com/fluffelpuff/RoueX/logging"
+	"github.com/fluffelpuff/RoueX/utils"
+	"github.com/fxamacker/cbor"
+)
+
+// Gibt den Protokolltypen des Verzeichnis Protokolls an
+const DIRECTORY_PROTOCOL_TYPE uint8 = 7
+
+// Gibt an, wie viele Relay Beschreibungen maximal gespeichert werden
+const DIRECTORY_MAX_DESCRIPTORS = 4096
+
+// Gibt an, wie viele Relay Beschreibungen maximal von einem einzelnen Nachbarn stammen dürfen
+const DIRECTORY_MAX_DESCRIPTORS_PER_NEIGHBOR = 512
+
+// Gibt an, wie viele Relay Beschreibungen maximal in einer Ankündigung übertragen werden
+const DIRECTORY_DESCRIPTORS_PER_ANNOUNCEMENT = 64
+
+// Stellt eine Ankündigung von Relay Beschreibungen dar, jede Beschreibung ist vom beschriebenen Relay Signiert
+type DirectoryAnnouncement struct {
+	Descriptors []kernel.RelayDescriptor `cbor:"1,keyasint"`
+}
+
+// Stellt eine gespeicherte Relay Beschreibung dar, 'source' gibt den Nachbarn (Hex) an von welchem die Beschreibung
+// stammt, bei der eigenen Beschreibung ist 'source' leer
+type directory_entry struct {
+	descriptor *kernel.RelayDescriptor
+	source     string
+	last_seen  time.Time
+}
+
+// Stellt das Verzeichnis Protokoll dar, Relay Beschreibungen werden über die bestehenden Verbindungen an alle Nachbarn weitergegeben
+type ROUEX_DIRECTORY_PROTOCOL struct {
+	_objid         string
+	_kernel        *kernel.Kernel
+	_lock          *sync.Mutex
+	_descriptors   map[string]*directory_entry
+	_source_counts map[string]int
+}
+
+// Sendet Relay Beschreibungen an ein Relay, die Beschreibungen werden dabei auf mehrere Ankündigungen aufgeteilt
+func (obj *ROUEX_DIRECTORY_PROTOCOL) _send_descriptors(descriptors []*kernel.RelayDescriptor, dest *btcec.PublicKey) error {
+	for start := 0; start < len(descriptors); start += DIRECTORY_DESCRIPTORS_PER_ANNOUNCEMENT {
+		// Die Ankündigung wird gebaut
+		end := start + DIRECTORY_DESCRIPTORS_PER_ANNOUNCEMENT
+		if end > len(descriptors) {
+			end = len(descriptors)
+		}
+		announcement := DirectoryAnnouncement{Descriptors: make([]kernel.RelayDescriptor, 0, end-start)}
+		for _, item := range descriptors[start:end] {
+			announcement.Descriptors = append(announcement.Descriptors, *item)
+		}
+
+		// Die Ankündigung wird in Bytes umgewandelt
+		encoded, err := cbor.Marshal(announcement, cbor.EncOptions{})
+		if err != nil {
+			return fmt.Errorf("_send_descriptors: 1: " + err.Error())
+		}
+
+		// Die Ankündigung wird an das Relay übermittelt
+		if _, err := obj._kernel.EnterBytesAndSendL2PackageToNetwork(DIRECTORY_PROTOCOL_TYPE, encoded, dest, false); err != nil {
+			return fmt.Errorf("_send_descriptors: 2: " + err.Error())
+		}
+	}
+
+	// Der Vorgang wurde ohne Fehler durchgeführt
+	return nil
+}
+
+// Sendet Relay Beschreibungen an alle Nachbarn, ausgenommen des Relays von welchem die Beschreibungen stammen
+func (obj *ROUEX_DIRECTORY_PROTOCOL) _broadcast_descriptors(descriptors []*kernel.RelayDescriptor, except *btcec.PublicKey) {
+	if len(descriptors) == 0 || obj._kernel.IsDraining() {
+		return
+	}
+	for _, relay := range obj._kernel.GetConnectedRelays() {
+		if except != nil && relay.GetPublicKey().IsEqual(except) {
+			continue
+		}
+		if err := obj._send_descriptors(descriptors, relay.GetPublicKey()); err != nil {
+			obj._kernel.Logger(logging.PROTOCOL).Warn("ROUEX_DIRECTORY_PROTOCOL: error by sending descriptors", "relay", relay.GetPublicKeyHexString(), "error", err.Error())
+		}
+	}
+}
+
+// Entfernt eine gespeicherte Beschreibung, der Threadlock muss gehalten werden
+func (obj *ROUEX_DIRECTORY_PROTOCOL) _remove_entry(key string) {
+	entry, found := obj._descriptors[key]
+	if !found {
+		return
+	}
+	delete(obj._descriptors, key)
+	if entry.source == "" {
+		return
+	}
+	if obj._source_counts[entry.source]--; obj._source_counts[entry.source] <= 0 {
+		delete(obj._source_counts, entry.source)
+	}
+}
+
+// Entfernt die am längsten nicht mehr gesehene Beschreibung, ist 'source' angegeben werden nur Beschreibungen dieses Nachbarn
+// berücksichtigt, die eigene Beschreibung wird nie entfernt, der Threadlock muss gehalten werden
+func (obj *ROUEX_DIRECTORY_PROTOCOL) _evict_least_recently_seen(source string) {
+	oldest_key, oldest_seen := "", time.Time{}
+	for key, entry := range obj._descriptors {
+		if entry.source == "" || (source != "" && entry.source != source) {
+			continue
+		}
+		if oldest_key == "" || entry.last_seen.Before(oldest_seen) {
+			oldest_key, oldest_seen = key, entry.last_seen
+		}
+	}
+	if oldest_key != "" {
+		obj._remove_entry(oldest_key)
+	}
+}
+
+// Speichert eine Relay Beschreibung sofern sie neuer als die bekannte ist, 'source' gibt den Nachbarn an von welchem die Beschreibung
+// stammt, ist das Verzeichnis oder das Kontingent des Nachbarn erschöpft, wird die am längsten nicht mehr gesehene Beschreibung entfernt
+func (obj *ROUEX_DIRECTORY_PROTOCOL) _store_descriptor(descriptor *kernel.RelayDescriptor, source string) bool {
+	key := hex.EncodeToString(descriptor.PublicKey)
+	now := time.Now()
+	obj._lock.Lock()
+	defer obj._lock.Unlock()
+
+	// Es wird geprüft ob die Beschreibung neuer als die bekannte ist, eine erneut erhaltene Beschreibung gilt als gesehen
+	if current, found := obj._descriptors[key]; found {
+		if !descriptor.IsNewerThan(current.descriptor) {
+			if descriptor.Seq == current.descriptor.Seq {
+				current.last_seen = now
+			}
+			return false
+		}
+		obj._remove_entry(key)
+	}
+
+	// Sollte das Kontingent des Nachbarn erschöpft sein, wird seine am längsten nicht mehr gesehene Beschreibung entfernt
+	if source != "" && obj._source_counts[source] >= DIRECTORY_MAX_DESCRIPTORS_PER_NEIGHBOR {
+		obj._evict_least_recently_seen(source)
+	}
+
+	// Sollte das Verzeichnis voll sein, werden zuerst die abgelaufenen Beschreibungen entfernt,
+	// danach die am längsten nicht mehr gesehene Beschreibung
+	if len(obj._descriptors) >= DIRECTORY_MAX_DESCRIPTORS {
+		for item_key, item := range obj._descriptors {
+			if item.descriptor.IsExpired() {
+				obj._remove_entry(item_key)
+			}
+		}
+		if len(obj._descriptors) >= DIRECTORY_MAX_DESCRIPTORS {
+			obj._evict_least_recently_seen("")
+		}
+	}
+
+	// Die Beschreibung wird gespeichert
+	obj._descriptors[key] = &directory_entry{descriptor: descriptor, source: source, last_seen: now}
+	if source != "" {
+		obj._source_counts[source]++
+	}
+	return true
+}
+
+// Gibt den Namen des Verzeichnisses zurück
+func (obj *ROUEX_DIRECTORY_PROTOCOL) GetDirectoryName() string {
+	return "gossip"
+}
+
+// Speichert die eigene Relay Beschreibung und sendet sie an alle Nachbarn
+func (obj *ROUEX_DIRECTORY_PROTOCOL) PublishDescriptor(descriptor *kernel.RelayDescriptor) error {
+	if !obj._store_descriptor(descriptor, "") {
+		return fmt.Errorf("PublishDescriptor: outdated descriptor")
+	}
+	obj._broadcast_descriptors([]*kernel.RelayDescriptor{descriptor}, nil)
+	return nil
+}
+
+// Gibt die bekannte Beschreibung eines Relays zurück
+func (obj *ROUEX_DIRECTORY_PROTOCOL) LookupDescriptor(pkey *btcec.PublicKey) *kernel.RelayDescriptor {
+	obj._lock.Lock()
+	defer obj._lock.Unlock()
+	entry, found := obj._descriptors[hex.EncodeToString(pkey.SerializeCompressed())]
+	if !found || entry.descriptor.IsExpired() {
+		return nil
+	}
+	return entry.descriptor
+}
+
+// Gibt alle bekannten, nicht abgelaufenen Relay Beschreibungen zurück
+func (obj *ROUEX_DIRECTORY_PROTOCOL) ListDescriptors() []*kernel.RelayDescriptor {
+	obj._lock.Lock()
+	defer obj._lock.Unlock()
+	result := make([]*kernel.RelayDescriptor, 0, len(obj._descriptors))
+	for _, entry := range obj._descriptors {
+		if !entry.descriptor.IsExpired() {
+			result = append(result, entry.descriptor)
+		}
+	}
+	return result
+}
+
+// Nimmt eingetroffene Pakete aus dem Netzwerk Entgegen
+func (obj *ROUEX_DIRECTORY_PROTOCOL) EnterRecivedPackage(pckage *addresspackages.AddressLayerPackage) error {
+	// Verzeichnis Ankündigungen werden nur von Direkt verbundenen Relays angenommen
+	if !obj._kernel.HasDirectRoute(&pckage.Sender) {
+		return fmt.Errorf("error: directory announcement from not connected relay")
+	}
+
+	// Es wird versucht das Paket einzulesen
+	var announcement DirectoryAnnouncement
+	if err := cbor.Unmarshal(pckage.Data, &announcement); err != nil {
+		return fmt.Errorf("error: invalid_package: " + err.Error())
+	}
+	if len(announcement.Descriptors) > DIRECTORY_DESCRIPTORS_PER_ANNOUNCEMENT {
+		return fmt.Errorf("error: too many descriptors in directory announcement")
+	}
+
+	// Die Beschreibungen werden geprüft und gespeichert, ungültige oder abgelaufene Beschreibungen werden verworfen
+	fresh := make([]*kernel.RelayDescriptor, 0)
+	own_key := obj._kernel.GetPublicKey()
+	source := hex.EncodeToString(pckage.Sender.SerializeCompressed())
+	for i := range announcement.Descriptors {
+		descriptor := &announcement.Descriptors[i]
+		if err := descriptor.Verify(); err != nil {
+			obj._kernel.Logger(logging.PROTOCOL).Debug("ROUEX_DIRECTORY_PROTOCOL: descriptor droped", "sender", source, "error", err.Error())
+			continue
+		}
+		if pkey, err := descriptor.GetPublicKey(); err != nil || pkey.IsEqual(own_key) {
+			continue
+		}
+		if obj._store_descriptor(descriptor, source) {
+			fresh = append(fresh, descriptor)
+		}
+	}
+
+	// Neue Beschreibungen werden an die übrigen Nachbarn weitergegeben
+	obj._broadcast_descriptors(fresh, &pckage.Sender)
+
+	// Der Vorgang wurde ohne Fehler durchgeführt
+	return nil
+}
+
+// Wird aufgerufen wenn eine Verbindung mit einem neuen Relay aufgebaut wurde, dem Relay werden alle bekannten Beschreibungen übermittelt
+func (obj *ROUEX_DIRECTORY_PROTOCOL) RelayConnected(relay *kernel.Relay) {
+	// Während der Kernel beendet wird, werden keine Beschreibungen mehr übermittelt
+	if obj._kernel.IsDraining() {
+		return
+	}
+
+	// Sollten keine Beschreibungen vorhanden sein, wird der Vorgang abgebrochen
+	descriptors := obj.ListDescriptors()
+	if len(descriptors) == 0 {
+		return
+	}
+
+	// Log
+	obj._kernel.Logger(logging.PROTOCOL).Info("ROUEX_DIRECTORY_PROTOCOL: sending directory to new relay", "relay", relay.GetPublicKeyHexString(), "total", len(descriptors))
+
+	// Die Beschreibungen werden übermittelt
+	if err := obj._send_descriptors(descriptors, relay.GetPublicKey()); err != nil {
+		obj._kernel.Logger(logging.PROTOCOL).Warn("ROUEX_DIRECTORY_PROTOCOL: error by sending directory", "error", err.Error())
+	}
+}
+
+// Wird aufgerufen wenn keine Verbindung mehr mit einem Relay besteht, die Beschreibungen bleiben bis zu ihrem Ablauf erhalten
+func (obj *ROUEX_DIRECTORY_PROTOCOL) RelayDisconnected(relay *kernel.Relay, dests []*btcec.PublicKey) {
+}
+
+// Nimmt eintreffende Steuer Befehele entgegen
+func (obj *ROUEX_DIRECTORY_PROTOCOL) EnterCommandData(command string, arguments [][]byte, process_api_conn *kernel.APIProcessConnectionWrapper) (map[string]interface{}, error) {
+	return nil, fmt.Errorf("invalid command")
+}
+
+// Registriert den Kernel im Protokoll, das Protokoll wird dabei auch als Relay Verzeichnis registriert
+func (obj *ROUEX_DIRECTORY_PROTOCOL) RegisterKernel(kernel *kernel.Kernel) error {
+	obj._lock.Lock()
+	if obj._kernel != nil {
+		obj._lock.Unlock()
+		return fmt.Errorf("kernel always registered")
+	}
+	obj._kernel = kernel
+	obj._lock.Unlock()
+	kernel.RegisterRelayStateObserver(obj)
+	kernel.RegisterDirectoryService(obj)
+	obj._kernel.Logger(logging.PROTOCOL).Info("ROUEX_DIRECTORY_PROTOCOL: kernel registrated", "id", kernel.GetKernelID(), "object_id", obj._objid)
+	return nil
+}
+
+// Die Pakete des Protokolles werden als Steuerpakete übertragen
+func (obj *ROUEX_DIRECTORY_PROTOCOL) IsControlProtocol() bool {
+	return true
+}
+
+// Gibt den Namen des Protokolles zurück
+func (obj *ROUEX_DIRECTORY_PROTOCOL) GetProtocolName() string {
+	return "ROUEX_DIRECTORY_PROTOCOL"
+}
+
+// Gibt die ObjektID des Protokolls zurück
+func (obj *ROUEX_DIRECTORY_PROTOCOL) GetObjectId() string {
+	return obj._objid
+}
+
+// Erzeugt ein neues Verzeichnis Protokoll
+func NEW_ROUEX_DIRECTORY_PROTOCOL_HANDLER() *ROUEX_DIRECTORY_PROTOCOL {
+	return &ROUEX_DIRECTORY_PROTOCOL{_lock: &sync.Mutex{}, _objid: utils.RandStringRunes(12), _descriptors: make(map[string]*directory_entry), _source_counts: make(map[string]int)}
+}
@@ -0,0 +1,545 @@
+package protocols
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	addresspackages "github.com/fluffelpuff/RoueX/address_packages"
+	"github.com/fluffelpuff/RoueX/kernel"
+	"github.com/fluffelpuff/RoueX/logging"
+	routingmanager "github.com/fluffelpuff/RoueX/routing_manager"
+	"github.com/fluffelpuff/RoueX/utils"
+	"github.com/fxamacker/cbor"
+)
+
+// Gibt den Protokolltypen des Routen Protokolls an
+const ROUTE_ADVERTISEMENT_PROTOCOL_TYPE uint8 = 1
+
+// Gibt an, in welchem Abstand die vollständige Routing Tabelle an alle Nachbarn gesendet wird
+const ROUTE_ADVERTISEMENT_INTERVAL = 30 * time.Second
+
+// Gibt an, wieviele Routen eine Ankündigung maximal enthalten darf
+const MAX_ADVERTISED_ROUTES = 1024
+
+// Gibt an, wieviele Relays der Pfad einer angekündigten Route maximal enthalten darf
+const MAX_ADVERTISED_PATH_LENGTH = int(routingmanager.ROUTE_METRIC_INFINITY)
+
+// Definiert die Typen einer Routen Ankündigung
+const (
+	ROUTE_UPDATE   = uint8(0)
+	ROUTE_WITHDRAW = uint8(1)
+)
+
+// Stellt eine Angekündigte Route dar
+type AdvertisedRoute struct {
+	Destination []byte   `cbor:"1,keyasint"`
+	Metric      uint64   `cbor:"2,keyasint"`
+	Path        [][]byte `cbor:"3,keyasint"`
+}
+
+// Stellt eine Routen Ankündigung dar, diese wird vom Ankündigenden Relay Signiert
+type RouteAdvertisement struct {
+	Type   uint8             `cbor:"1,keyasint"`
+	Origin []byte            `cbor:"2,keyasint"`
+	Seq    uint64            `cbor:"3,keyasint"`
+	Routes []AdvertisedRoute `cbor:"4,keyasint"`
+	Sig    []byte            `cbor:"5,keyasint"`
+}
+
+// Gibt den Hash zurück, welcher vom Relay Signiert wird
+func (obj *RouteAdvertisement) GetSignHash() ([]byte, error) {
+	// Das Paket wird ohne Signatur in Bytes umgewandelt
+	unsigned := RouteAdvertisement{Type: obj.Type, Origin: obj.Origin, Seq: obj.Seq, Routes: obj.Routes}
+	encoded, err := cbor.Marshal(unsigned, cbor.EncOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("GetSignHash: " + err.Error())
+	}
+
+	// Der Hash wird erstellt
+	return utils.ComputeSha3256Hash([]byte("route_advertisement"), encoded), nil
+}
+
+// Stellt eine geprüfte angekündigte Route dar
+type checked_route struct {
+	dest   *btcec.PublicKey
+	metric uint64
+	path   []*btcec.PublicKey
+	ignore bool
+}
+
+// Stellt das Routen Ankündigungs Protokoll dar
+type ROUEX_ROUTE_ADVERTISEMENT_PROTOCOL struct {
+	_objid        string
+	_kernel       *kernel.Kernel
+	_lock         *sync.Mutex
+	_last_seq     map[string]uint64
+	_loop_running bool
+}
+
+// Gibt an ob ein Öffentlicher Schlüssel in einem Pfad enthalten ist
+func _path_contains_key(path [][]byte, pkey *btcec.PublicKey) bool {
+	serialized := pkey.SerializeCompressed()
+	for i := range path {
+		if bytes.Equal(path[i], serialized) {
+			return true
+		}
+	}
+	return false
+}
+
+// Erstellt, Signiert und versendet eine Routen Ankündigung an ein Relay
+func (obj *ROUEX_ROUTE_ADVERTISEMENT_PROTOCOL) _send_advertisement(tpe uint8, routes []AdvertisedRoute, dest *btcec.PublicKey) error {
+	// Die Ankündigung wird gebaut
+	adv := RouteAdvertisement{
+		Type:   tpe,
+		Origin: obj._kernel.GetPublicKey().SerializeCompressed(),
+		Seq:    uint64(time.Now().UnixNano()),
+		Routes: routes,
+	}
+
+	// Die Ankündigung wird Signiert
+	sign_hash, err := adv.GetSignHash()
+	if err != nil {
+		return fmt.Errorf("_send_advertisement: 1: " + err.Error())
+	}
+	adv.Sig, err = obj._kernel.SignWithRelayKey(sign_hash)
+	if err != nil {
+		return fmt.Errorf("_send_advertisement: 2: " + err.Error())
+	}
+
+	// Die Ankündigung wird in Bytes umgewandelt
+	encoded, err := cbor.Marshal(adv, cbor.EncOptions{})
+	if err != nil {
+		return fmt.Errorf("_send_advertisement: 3: " + err.Error())
+	}
+
+	// Die Ankündigung wird an das Relay übermittelt
+	if _, err := obj._kernel.EnterBytesAndSendL2PackageToNetwork(ROUTE_ADVERTISEMENT_PROTOCOL_TYPE, encoded, dest, false); err != nil {
+		return fmt.Errorf("_send_advertisement: 4: " + err.Error())
+	}
+
+	// Der Vorgang wurde ohne Fehler durchgeführt
+	return nil
+}
+
+// Erstellt die Routing Tabelle, welche einem Nachbarn angekündigt wird
+func (obj *ROUEX_ROUTE_ADVERTISEMENT_PROTOCOL) _build_table_for(neighbor *btcec.PublicKey) []AdvertisedRoute {
+	own_key := obj._kernel.GetPublicKey().SerializeCompressed()
+	neighbor_hex := hex.EncodeToString(neighbor.SerializeCompressed())
+
+	// Das eigene Relay wird immer mit der Metrik 0 angekündigt
+	result := []AdvertisedRoute{{Destination: own_key, Metric: 0, Path: [][]byte{own_key}}}
+	known := map[string]bool{hex.EncodeToString(own_key): true, neighbor_hex: true}
+
+	// Alle Direkt verbundenen Relays werden mit der Metrik 1 angekündigt
+	for _, relay := range obj._kernel.GetConnectedRelays() {
+		relay_hex := relay.GetPublicKeyHexString()
+		if known[relay_hex] || len(result) >= MAX_ADVERTISED_ROUTES {
+			continue
+		}
+		known[relay_hex] = true
+		result = append(result, AdvertisedRoute{Destination: relay.GetPublicKey().SerializeCompressed(), Metric: 1, Path: [][]byte{own_key}})
+	}
+
+	// Die besten angekündigten Routen aus dem Routing Manager werden weitergegeben, Routen welche über den Nachbarn
+	// selbst laufen werden nicht angekündigt (Split Horizon), aus Paketen gelernte Routen werden nie angekündigt
+	for _, route := range obj._kernel.GetRoutingManager().GetBestAdvertisedRoutes() {
+		if len(result) >= MAX_ADVERTISED_ROUTES {
+			break
+		}
+		dest_hex := hex.EncodeToString(route.GetDestination().SerializeCompressed())
+		if known[dest_hex] || route.GetRelayHexId() == neighbor_hex {
+			continue
+		}
+		if route.GetMetric()+1 >= routingmanager.ROUTE_METRIC_INFINITY || len(route.GetPath())+1 > MAX_ADVERTISED_PATH_LENGTH {
+			continue
+		}
+
+		// Der Pfad wird gebaut, das eigene Relay wird vorangestellt
+		path := [][]byte{own_key}
+		is_valid := true
+		for _, item := range route.GetPath() {
+			decoded, err := hex.DecodeString(item)
+			if err != nil {
+				is_valid = false
+				break
+			}
+			path = append(path, decoded)
+		}
+		if !is_valid {
+			continue
+		}
+
+		// Die Route wird hinzugefügt
+		known[dest_hex] = true
+		result = append(result, AdvertisedRoute{Destination: route.GetDestination().SerializeCompressed(), Metric: route.GetMetric() + 1, Path: path})
+	}
+
+	// Die Tabelle wird zurückgegeben
+	return result
+}
+
+// Sendet die vollständige Routing Tabelle an einen Nachbarn
+func (obj *ROUEX_ROUTE_ADVERTISEMENT_PROTOCOL) _send_full_table(neighbor *btcec.PublicKey) {
+	if err := obj._send_advertisement(ROUTE_UPDATE, obj._build_table_for(neighbor), neighbor); err != nil {
+		obj._kernel.Logger(logging.PROTOCOL).Warn("ROUEX_ROUTE_ADVERTISEMENT_PROTOCOL: error by sending routing table", "error", err.Error())
+	}
+}
+
+// Sendet das Zurückziehen von Zielen an alle Nachbarn, ausgenommen des Relays von welchem der Rückzug stammt
+func (obj *ROUEX_ROUTE_ADVERTISEMENT_PROTOCOL) _broadcast_withdraw(dests []*btcec.PublicKey, except *btcec.PublicKey) {
+	// Es werden nur Ziele zurückgezogen, welche nicht mehr erreichbar sind
+	routes := make([]AdvertisedRoute, 0)
+	for _, dest := range dests {
+		if obj._kernel.HasDirectRoute(dest) || len(obj._kernel.GetRoutingManager().LookupRoutes(dest)) > 0 {
+			continue
+		}
+		routes = append(routes, AdvertisedRoute{Destination: dest.SerializeCompressed(), Metric: routingmanager.ROUTE_METRIC_INFINITY})
+	}
+
+	// Sollten keine Ziele vorhanden sein, wird der Vorgang abgebrochen
+	if len(routes) == 0 {
+		return
+	}
+
+	// Der Rückzug wird an alle Nachbarn gesendet
+	for _, relay := range obj._kernel.GetConnectedRelays() {
+		if except != nil && relay.GetPublicKey().IsEqual(except) {
+			continue
+		}
+		if err := obj._send_advertisement(ROUTE_WITHDRAW, routes, relay.GetPublicKey()); err != nil {
+			obj._kernel.Logger(logging.PROTOCOL).Warn("ROUEX_ROUTE_ADVERTISEMENT_PROTOCOL: error by sending withdraw", "error", err.Error())
+		}
+	}
+}
+
+// Sendet in regelmäßigen Abständen die vollständige Routing Tabelle an alle Nachbarn
+func (obj *ROUEX_ROUTE_ADVERTISEMENT_PROTOCOL) _advertisement_loop() {
+	ticker := time.NewTicker(ROUTE_ADVERTISEMENT_INTERVAL)
+	defer ticker.Stop()
+	for done := false; !done; {
+		select {
+		case <-obj._kernel.Context().Done():
+			done = true
+		case <-ticker.C:
+			if obj._kernel.IsDraining() {
+				continue
+			}
+			if err := obj._kernel.GetRoutingManager().ExpireRoutes(); err != nil {
+				obj._kernel.Logger(logging.PROTOCOL).Warn("ROUEX_ROUTE_ADVERTISEMENT_PROTOCOL: error by expiring routes", "error", err.Error())
+			}
+			for _, relay := range obj._kernel.GetConnectedRelays() {
+				obj._send_full_table(relay.GetPublicKey())
+			}
+		}
+	}
+
+	// Es wird Signalisiert dass die Schleife beendet wurde
+	obj._lock.Lock()
+	obj._loop_running = false
+	obj._lock.Unlock()
+}
+
+// Prüft alle Routen einer Ankündigung, sollte eine Route ungültig sein, wird die gesamte Ankündigung verworfen.
+// Routen zum eigenen Relay, zum Nachbarn selbst sowie Routen mit einer Schleife werden als zu ignorieren markiert
+func (obj *ROUEX_ROUTE_ADVERTISEMENT_PROTOCOL) _check_routes(adv *RouteAdvertisement, neighbor *btcec.PublicKey) ([]checked_route, error) {
+	own_key := obj._kernel.GetPublicKey()
+
+	// Es wird geprüft ob die Anzahl der Routen zulässig ist
+	if len(adv.Routes) > MAX_ADVERTISED_ROUTES {
+		return nil, fmt.Errorf("_check_routes: 1: too many routes")
+	}
+
+	// Die einzelnen Routen werden geprüft
+	result := make([]checked_route, 0, len(adv.Routes))
+	seen := make(map[string]bool)
+	for _, route := range adv.Routes {
+		// Das Ziel wird eingelesen, jedes Ziel darf nur einmal enthalten sein
+		dest, err := btcec.ParsePubKey(route.Destination)
+		if err != nil {
+			return nil, fmt.Errorf("_check_routes: 2: " + err.Error())
+		}
+		dest_hex := hex.EncodeToString(dest.SerializeCompressed())
+		if seen[dest_hex] {
+			return nil, fmt.Errorf("_check_routes: 3: duplicate destination")
+		}
+		seen[dest_hex] = true
+		checked := checked_route{dest: dest, metric: route.Metric, ignore: dest.IsEqual(own_key) || dest.IsEqual(neighbor)}
+
+		// Bei einem Rückzug oder einem nicht erreichbaren Ziel wird der Pfad nicht berücksichtigt
+		if adv.Type == ROUTE_WITHDRAW || route.Metric >= routingmanager.ROUTE_METRIC_INFINITY {
+			result = append(result, checked)
+			continue
+		}
+
+		// Nur der Nachbar selbst darf mit der Metrik 0 angekündigt werden
+		if route.Metric == 0 && !dest.IsEqual(neighbor) {
+			return nil, fmt.Errorf("_check_routes: 4: invalid route metric")
+		}
+
+		// Der Pfad muss mit dem Nachbarn beginnen und darf die maximale Länge nicht überschreiten
+		if len(route.Path) == 0 || len(route.Path) > MAX_ADVERTISED_PATH_LENGTH {
+			return nil, fmt.Errorf("_check_routes: 5: invalid route path length")
+		}
+		if !bytes.Equal(route.Path[0], neighbor.SerializeCompressed()) {
+			return nil, fmt.Errorf("_check_routes: 6: route path does not start with the advertising relay")
+		}
+
+		// Der Pfad wird eingelesen, jedes Relay darf nur einmal enthalten sein
+		path_seen := make(map[string]bool)
+		for i := range route.Path {
+			item, err := btcec.ParsePubKey(route.Path[i])
+			if err != nil {
+				return nil, fmt.Errorf("_check_routes: 7: " + err.Error())
+			}
+			item_hex := hex.EncodeToString(item.SerializeCompressed())
+			if path_seen[item_hex] {
+				return nil, fmt.Errorf("_check_routes: 8: duplicate relay in route path")
+			}
+			path_seen[item_hex] = true
+			checked.path = append(checked.path, item)
+		}
+
+		// Sollte das eigene Relay im Pfad enthalten sein, handelt es sich um eine Schleife
+		if _path_contains_key(route.Path, own_key) {
+			checked.ignore = true
+		}
+		result = append(result, checked)
+	}
+
+	// Die geprüften Routen werden zurückgegeben
+	return result, nil
+}
+
+// Verarbeitet eine eingetroffene Routen Ankündigung eines Nachbarn, die Ankündigung wurde bereits vollständig geprüft
+func (obj *ROUEX_ROUTE_ADVERTISEMENT_PROTOCOL) _enter_update(routes []checked_route, neighbor *btcec.PublicKey) error {
+	routing_manager := obj._kernel.GetRoutingManager()
+
+	// Die Angekündigten Routen werden abgearbeitet
+	announced := make(map[string]bool)
+	withdrawn := make([]*btcec.PublicKey, 0)
+	for _, route := range routes {
+		// Routen zum eigenen Relay, zum Nachbarn selbst oder mit einer Schleife werden nicht gespeichert
+		if route.ignore {
+			continue
+		}
+
+		// Sollte das Ziel nicht mehr erreichbar sein, wird die Route entfernt
+		metric := route.metric + 1
+		if metric >= routingmanager.ROUTE_METRIC_INFINITY {
+			if err := routing_manager.RemoveRoute(route.dest, neighbor); err != nil {
+				return fmt.Errorf("_enter_update: 1: " + err.Error())
+			}
+			withdrawn = append(withdrawn, route.dest)
+			continue
+		}
+
+		// Die Route wird gespeichert
+		if err := routing_manager.AddOrUpdateRoute(route.dest, neighbor, metric, route.path); err != nil {
+			return fmt.Errorf("_enter_update: 2: " + err.Error())
+		}
+		announced[hex.EncodeToString(route.dest.SerializeCompressed())] = true
+	}
+
+	// Es handelt sich um eine vollständige Tabelle, alle Angekündigten Routen über diesen Nachbarn
+	// welche nicht mehr enthalten sind, werden entfernt, aus Paketen gelernte Routen bleiben erhalten
+	known_routes, err := routing_manager.FetchRoutesByRelayKey(neighbor)
+	if err != nil {
+		return fmt.Errorf("_enter_update: 3: " + err.Error())
+	}
+	for _, route := range known_routes.GetRoutes() {
+		if route.IsLearned() || announced[hex.EncodeToString(route.GetDestination().SerializeCompressed())] {
+			continue
+		}
+		if err := routing_manager.RemoveRoute(route.GetDestination(), neighbor); err != nil {
+			return fmt.Errorf("_enter_update: 4: " + err.Error())
+		}
+		withdrawn = append(withdrawn, route.GetDestination())
+	}
+
+	// Nicht mehr erreichbare Ziele werden an die übrigen Nachbarn weitergegeben
+	obj._broadcast_withdraw(withdrawn, neighbor)
+
+	// Der Vorgang wurde ohne Fehler durchgeführt
+	return nil
+}
+
+// Verarbeitet einen eingetroffenen Rückzug von Routen eines Nachbarn, der Rückzug wurde bereits vollständig geprüft
+func (obj *ROUEX_ROUTE_ADVERTISEMENT_PROTOCOL) _enter_withdraw(routes []checked_route, neighbor *btcec.PublicKey) error {
+	// Die Zurückgezogenen Routen werden entfernt
+	withdrawn := make([]*btcec.PublicKey, 0)
+	for _, route := range routes {
+		if err := obj._kernel.GetRoutingManager().RemoveRoute(route.dest, neighbor); err != nil {
+			return fmt.Errorf("_enter_withdraw: " + err.Error())
+		}
+		withdrawn = append(withdrawn, route.dest)
+	}
+
+	// Nicht mehr erreichbare Ziele werden an die übrigen Nachbarn weitergegeben
+	obj._broadcast_withdraw(withdrawn, neighbor)
+
+	// Der Vorgang wurde ohne Fehler durchgeführt
+	return nil
+}
+
+// Nimmt eingetroffene Pakete aus dem Netzwerk Entgegen
+func (obj *ROUEX_ROUTE_ADVERTISEMENT_PROTOCOL) EnterRecivedPackage(pckage *addresspackages.AddressLayerPackage) error {
+	// Routen Ankündigungen werden nur von Direkt verbundenen Relays angenommen
+	if !obj._kernel.HasDirectRoute(&pckage.Sender) {
+		return fmt.Errorf("error: route advertisement from not connected relay")
+	}
+
+	// Ankündigungen von nicht Vertrauenswürdigen Relays werden ignoriert, diese könnten sonst beliebige Ziele an sich ziehen
+	if !obj._kernel.HasTrustedDirectRoute(&pckage.Sender) {
+		obj._kernel.Logger(logging.PROTOCOL).Debug("ROUEX_ROUTE_ADVERTISEMENT_PROTOCOL: route advertisement from untrusted relay ignored", "relay", hex.EncodeToString(pckage.Sender.SerializeCompressed()))
+		return nil
+	}
+
+	// Es wird versucht das Paket einzulesen
+	var adv RouteAdvertisement
+	if err := cbor.Unmarshal(pckage.Data, &adv); err != nil {
+		return fmt.Errorf("error: invalid_package: " + err.Error())
+	}
+
+	// Die Ankündigung muss vom Absender des Paketes stammen
+	if !bytes.Equal(adv.Origin, pckage.Sender.SerializeCompressed()) {
+		return fmt.Errorf("error: invalid route advertisement origin")
+	}
+
+	// Die Signatur der Ankündigung wird geprüft
+	sign_hash, err := adv.GetSignHash()
+	if err != nil {
+		return fmt.Errorf("error: " + err.Error())
+	}
+	is_valid, err := utils.VerifyByBytes(&pckage.Sender, adv.Sig, sign_hash)
+	if err != nil || !is_valid {
+		return fmt.Errorf("error: invalid route advertisement signature")
+	}
+
+	// Die Routen der Ankündigung werden vollständig geprüft bevor eine davon übernommen wird
+	routes, err := obj._check_routes(&adv, &pckage.Sender)
+	if err != nil {
+		return fmt.Errorf("error: invalid_package: " + err.Error())
+	}
+
+	// Es wird geprüft ob die Ankündigung neuer als die zuletzt empfangene ist
+	origin_hex := hex.EncodeToString(adv.Origin)
+	obj._lock.Lock()
+	if adv.Seq <= obj._last_seq[origin_hex] {
+		obj._lock.Unlock()
+		return nil
+	}
+	obj._last_seq[origin_hex] = adv.Seq
+	obj._lock.Unlock()
+
+	// Es wird geprüft ob es sich um eine Ankündigung oder um einen Rückzug handelt
+	switch adv.Type {
+	case ROUTE_UPDATE:
+		return obj._enter_update(routes, &pckage.Sender)
+	case ROUTE_WITHDRAW:
+		return obj._enter_withdraw(routes, &pckage.Sender)
+	default:
+		return fmt.Errorf("error: invalid package type")
+	}
+}
+
+// Wird aufgerufen wenn eine Verbindung mit einem neuen Relay aufgebaut wurde
+func (obj *ROUEX_ROUTE_ADVERTISEMENT_PROTOCOL) RelayConnected(relay *kernel.Relay) {
+	// Die Schleife für die regelmäßigen Ankündigungen wird gestartet, sofern sie noch nicht ausgeführt wird
+	obj._lock.Lock()
+	if !obj._loop_running {
+		obj._loop_running = true
+		go obj._advertisement_loop()
+	}
+	obj._lock.Unlock()
+
+	// Während der Kernel beendet wird, werden keine Routen mehr angekündigt
+	if obj._kernel.IsDraining() {
+		return
+	}
+
+	// Log
+	obj._kernel.Logger(logging.PROTOCOL).Info("ROUEX_ROUTE_ADVERTISEMENT_PROTOCOL: sending routing table to new relay", "relay", relay.GetPublicKeyHexString())
+
+	// Die vollständige Routing Tabelle wird an das neue Relay sowie an alle übrigen Nachbarn gesendet,
+	// so wird das neue Relay ohne Verzögerung im Netzwerk bekannt
+	obj._send_full_table(relay.GetPublicKey())
+	for _, item := range obj._kernel.GetConnectedRelays() {
+		if !item.GetPublicKey().IsEqual(relay.GetPublicKey()) {
+			obj._send_full_table(item.GetPublicKey())
+		}
+	}
+}
+
+// Wird aufgerufen wenn der Kernel in den Drain Modus wechselt, allen Nachbarn wird mitgeteilt dass
+// das eigene Relay sowie alle über dieses Relay erreichbaren Ziele nicht mehr verfügbar sind
+func (obj *ROUEX_ROUTE_ADVERTISEMENT_PROTOCOL) KernelDraining() {
+	for _, relay := range obj._kernel.GetConnectedRelays() {
+		// Alle bisher angekündigten Routen werden zurückgezogen
+		routes := obj._build_table_for(relay.GetPublicKey())
+		for i := range routes {
+			routes[i].Metric = routingmanager.ROUTE_METRIC_INFINITY
+			routes[i].Path = nil
+		}
+
+		// Log
+		obj._kernel.Logger(logging.PROTOCOL).Info("ROUEX_ROUTE_ADVERTISEMENT_PROTOCOL: announce departure", "relay", relay.GetPublicKeyHexString(), "total", len(routes))
+
+		// Der Rückzug wird an den Nachbarn gesendet
+		if err := obj._send_advertisement(ROUTE_WITHDRAW, routes, relay.GetPublicKey()); err != nil {
+			obj._kernel.Logger(logging.PROTOCOL).Warn("ROUEX_ROUTE_ADVERTISEMENT_PROTOCOL: error by sending departure", "error", err.Error())
+		}
+	}
+}
+
+// Wird aufgerufen wenn keine Verbindung mehr mit einem Relay besteht
+func (obj *ROUEX_ROUTE_ADVERTISEMENT_PROTOCOL) RelayDisconnected(relay *kernel.Relay, dests []*btcec.PublicKey) {
+	// Log
+	obj._kernel.Logger(logging.PROTOCOL).Info("ROUEX_ROUTE_ADVERTISEMENT_PROTOCOL: withdrawing routes of relay", "relay", relay.GetPublicKeyHexString(), "total", len(dests))
+
+	// Das Relay selbst sowie alle Ziele welche über das Relay erreichbar waren, werden zurückgezogen
+	obj._broadcast_withdraw(append([]*btcec.PublicKey{relay.GetPublicKey()}, dests...), relay.GetPublicKey())
+}
+
+// Nimmt eintreffende Steuer Befehele entgegen
+func (obj *ROUEX_ROUTE_ADVERTISEMENT_PROTOCOL) EnterCommandData(command string, arguments [][]byte, process_api_conn *kernel.APIProcessConnectionWrapper) (map[string]interface{}, error) {
+	return nil, fmt.Errorf("invalid command")
+}
+
+// Registriert den Kernel im Protokoll
+func (obj *ROUEX_ROUTE_ADVERTISEMENT_PROTOCOL) RegisterKernel(kernel *kernel.Kernel) error {
+	obj._lock.Lock()
+	if obj._kernel != nil {
+		obj._lock.Unlock()
+		return fmt.Errorf("kernel always registered")
+	}
+	obj._kernel = kernel
+	obj._lock.Unlock()
+	kernel.RegisterRelayStateObserver(obj)
+	obj._kernel.Logger(logging.PROTOCOL).Info("ROUEX_ROUTE_ADVERTISEMENT_PROTOCOL: kernel registrated", "id", kernel.GetKernelID(), "object_id", obj._objid)
+	return nil
+}
+
+// Die Pakete des Protokolles werden als Steuerpakete übertragen
+func (obj *ROUEX_ROUTE_ADVERTISEMENT_PROTOCOL) IsControlProtocol() bool {
+	return true
+}
+
+// Gibt den Namen des Protokolles zurück
+func (obj *ROUEX_ROUTE_ADVERTISEMENT_PROTOCOL) GetProtocolName() string {
+	return "ROUEX_ROUTE_ADVERTISEMENT_PROTOCOL"
+}
+
+// Gibt die ObjektID des Protokolls zurück
+func (obj *ROUEX_ROUTE_ADVERTISEMENT_PROTOCOL) GetObjectId() string {
+	return obj._objid
+}
+
+// Erzeugt ein neues Routen Ankündigungs Protokoll
+func NEW_ROUEX_ROUTE_ADVERTISEMENT_PROTOCOL_HANDLER() *ROUEX_ROUTE_ADVERTISEMENT_PROTOCOL {
+	return &ROUEX_ROUTE_ADVERTISEMENT_PROTOCOL{_lock: &sync.Mutex{}, _objid: utils.RandStringRunes(12), _last_seq: make(map[string]uint64)}
+}
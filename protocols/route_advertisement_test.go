@@ -0,0 +1,294 @@
+package protocols
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/fluffelpuff/RoueX/kernel"
+	routingmanager "github.com/fluffelpuff/RoueX/routing_manager"
+	"github.com/fluffelpuff/RoueX/static"
+	"github.com/fluffelpuff/RoueX/utils"
+)
+
+// Erzeugt einen neuen Öffentlichen Schlüssel für die Tests
+func newTestKey(t *testing.T) *btcec.PublicKey {
+	t.Helper()
+	priv, err := utils.GeneratePrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return priv.PubKey()
+}
+
+// Erstellt einen Kernel, dessen Dateien in einem Temporären Verzeichnis abgelegt werden, der Kernel wird nicht gestartet
+func newTestKernel(t *testing.T) *kernel.Kernel {
+	t.Helper()
+	dir := t.TempDir()
+	files := map[static.File]string{
+		static.API_SOCKET:       "api.sock",
+		static.TRUSTED_RELAYS:   "trusted_relays.db",
+		static.ROUTING_TABLE:    "routes.db",
+		static.FIREWALL_TABLE:   "firewall.db",
+		static.EXTERNAL_MODULES: "modules",
+		static.PRIVATE_KEY_FILE: "relay.key",
+	}
+	for file, name := range files {
+		static.SetFilePathFor(file, filepath.Join(dir, name))
+	}
+	t.Cleanup(func() {
+		for file := range files {
+			static.SetFilePathFor(file, "")
+		}
+	})
+
+	// Der Kernel wird mit einem neuen Schlüssel erstellt
+	priv, err := utils.GeneratePrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	k, err := kernel.CreateUnixKernel(priv, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return k
+}
+
+// Erstellt ein Routen Protokoll mit einem Test Kernel
+func newTestRouteProtocol(t *testing.T) *ROUEX_ROUTE_ADVERTISEMENT_PROTOCOL {
+	t.Helper()
+	protocol := NEW_ROUEX_ROUTE_ADVERTISEMENT_PROTOCOL_HANDLER()
+	protocol._kernel = newTestKernel(t)
+	return protocol
+}
+
+// Erstellt eine Route mit einem Pfad aus den angegebenen Schlüsseln
+func newAdvertisedRoute(dest *btcec.PublicKey, metric uint64, path ...*btcec.PublicKey) AdvertisedRoute {
+	route := AdvertisedRoute{Destination: dest.SerializeCompressed(), Metric: metric}
+	for _, item := range path {
+		route.Path = append(route.Path, item.SerializeCompressed())
+	}
+	return route
+}
+
+func TestCheckRoutesValid(t *testing.T) {
+	protocol := newTestRouteProtocol(t)
+	own_key := protocol._kernel.GetPublicKey()
+	neighbor, dest, hop := newTestKey(t), newTestKey(t), newTestKey(t)
+
+	adv := &RouteAdvertisement{Type: ROUTE_UPDATE, Routes: []AdvertisedRoute{
+		newAdvertisedRoute(neighbor, 0, neighbor),
+		newAdvertisedRoute(dest, 2, neighbor, hop),
+		newAdvertisedRoute(own_key, 1, neighbor),
+		newAdvertisedRoute(hop, 2, neighbor, own_key),
+	}}
+	routes, err := protocol._check_routes(adv, neighbor)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(routes) != 4 {
+		t.Fatalf("len(routes) = %d, want 4", len(routes))
+	}
+
+	// Der Nachbar selbst, das eigene Relay sowie Routen mit einer Schleife werden ignoriert
+	want_ignore := []bool{true, false, true, true}
+	for i := range routes {
+		if routes[i].ignore != want_ignore[i] {
+			t.Errorf("route %d: ignore = %v, want %v", i, routes[i].ignore, want_ignore[i])
+		}
+	}
+	if len(routes[1].path) != 2 || !routes[1].path[0].IsEqual(neighbor) || !routes[1].path[1].IsEqual(hop) {
+		t.Error("route path was not parsed")
+	}
+}
+
+func TestCheckRoutesInvalid(t *testing.T) {
+	protocol := newTestRouteProtocol(t)
+	neighbor, dest, hop := newTestKey(t), newTestKey(t), newTestKey(t)
+
+	// Es wird ein zu langer Pfad erstellt
+	long_path := []*btcec.PublicKey{neighbor}
+	for len(long_path) <= MAX_ADVERTISED_PATH_LENGTH {
+		long_path = append(long_path, newTestKey(t))
+	}
+
+	// Es werden zu viele Routen erstellt
+	too_many := make([]AdvertisedRoute, MAX_ADVERTISED_ROUTES+1)
+
+	tests := []struct {
+		name   string
+		routes []AdvertisedRoute
+	}{
+		{"too many routes", too_many},
+		{"invalid destination", []AdvertisedRoute{{Destination: []byte{1, 2, 3}, Metric: 1, Path: [][]byte{neighbor.SerializeCompressed()}}}},
+		{"duplicate destination", []AdvertisedRoute{newAdvertisedRoute(dest, 1, neighbor), newAdvertisedRoute(dest, 2, neighbor, hop)}},
+		{"metric zero", []AdvertisedRoute{newAdvertisedRoute(dest, 0, neighbor)}},
+		{"empty path", []AdvertisedRoute{newAdvertisedRoute(dest, 1)}},
+		{"path too long", []AdvertisedRoute{newAdvertisedRoute(dest, 3, long_path...)}},
+		{"foreign path", []AdvertisedRoute{newAdvertisedRoute(dest, 1, hop)}},
+		{"invalid path key", []AdvertisedRoute{{Destination: dest.SerializeCompressed(), Metric: 1, Path: [][]byte{neighbor.SerializeCompressed(), {1, 2, 3}}}}},
+		{"duplicate path key", []AdvertisedRoute{newAdvertisedRoute(dest, 3, neighbor, hop, hop)}},
+	}
+	for _, tt := range tests {
+		if _, err := protocol._check_routes(&RouteAdvertisement{Type: ROUTE_UPDATE, Routes: tt.routes}, neighbor); err == nil {
+			t.Errorf("%s: advertisement accepted", tt.name)
+		}
+	}
+}
+
+func TestCheckRoutesWithdraw(t *testing.T) {
+	protocol := newTestRouteProtocol(t)
+	neighbor, dest := newTestKey(t), newTestKey(t)
+
+	// Bei einem Rückzug wird der Pfad nicht geprüft
+	adv := &RouteAdvertisement{Type: ROUTE_WITHDRAW, Routes: []AdvertisedRoute{newAdvertisedRoute(dest, routingmanager.ROUTE_METRIC_INFINITY)}}
+	routes, err := protocol._check_routes(adv, neighbor)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(routes) != 1 || !routes[0].dest.IsEqual(dest) {
+		t.Errorf("withdraw routes = %v", routes)
+	}
+}
+
+func TestEnterUpdate(t *testing.T) {
+	protocol := newTestRouteProtocol(t)
+	routing_manager := protocol._kernel.GetRoutingManager()
+	neighbor, dest, stale, learned, hop := newTestKey(t), newTestKey(t), newTestKey(t), newTestKey(t), newTestKey(t)
+
+	// Es bestehen bereits eine angekündigte und eine gelernte Route über den Nachbarn
+	if err := routing_manager.AddOrUpdateRoute(stale, neighbor, 2, []*btcec.PublicKey{neighbor}); err != nil {
+		t.Fatal(err)
+	}
+	if err := routing_manager.LearnRoute(learned, neighbor); err != nil {
+		t.Fatal(err)
+	}
+
+	// Die neue Tabelle des Nachbarn wird übernommen
+	adv := &RouteAdvertisement{Type: ROUTE_UPDATE, Routes: []AdvertisedRoute{
+		newAdvertisedRoute(neighbor, 0, neighbor),
+		newAdvertisedRoute(dest, 1, neighbor, hop),
+	}}
+	routes, err := protocol._check_routes(adv, neighbor)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := protocol._enter_update(routes, neighbor); err != nil {
+		t.Fatal(err)
+	}
+
+	// Die Metrik der neuen Route wird um eins erhöht
+	found := routing_manager.LookupRoutes(dest)
+	if len(found) != 1 || found[0].GetMetric() != 2 || len(found[0].GetPath()) != 2 {
+		t.Fatalf("LookupRoutes(dest) = %v", found)
+	}
+
+	// Die nicht mehr angekündigte Route wird entfernt, die gelernte Route bleibt erhalten
+	if len(routing_manager.LookupRoutes(stale)) != 0 {
+		t.Error("stale advertised route was not removed")
+	}
+	if len(routing_manager.LookupRoutes(learned)) != 1 {
+		t.Error("learned route was removed")
+	}
+
+	// Der Nachbar selbst wird nicht als Route gespeichert
+	if len(routing_manager.LookupRoutes(neighbor)) != 0 {
+		t.Error("route to neighbor was stored")
+	}
+}
+
+func TestEnterUpdateUnreachable(t *testing.T) {
+	protocol := newTestRouteProtocol(t)
+	routing_manager := protocol._kernel.GetRoutingManager()
+	neighbor, dest := newTestKey(t), newTestKey(t)
+	if err := routing_manager.AddOrUpdateRoute(dest, neighbor, 2, []*btcec.PublicKey{neighbor}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Ein Ziel mit unendlicher Metrik wird entfernt
+	routes, err := protocol._check_routes(&RouteAdvertisement{Type: ROUTE_UPDATE, Routes: []AdvertisedRoute{newAdvertisedRoute(dest, routingmanager.ROUTE_METRIC_INFINITY-1, neighbor)}}, neighbor)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := protocol._enter_update(routes, neighbor); err != nil {
+		t.Fatal(err)
+	}
+	if len(routing_manager.LookupRoutes(dest)) != 0 {
+		t.Error("unreachable route was not removed")
+	}
+}
+
+func TestEnterWithdraw(t *testing.T) {
+	protocol := newTestRouteProtocol(t)
+	routing_manager := protocol._kernel.GetRoutingManager()
+	neighbor, other, dest := newTestKey(t), newTestKey(t), newTestKey(t)
+	if err := routing_manager.AddOrUpdateRoute(dest, neighbor, 2, []*btcec.PublicKey{neighbor}); err != nil {
+		t.Fatal(err)
+	}
+	if err := routing_manager.AddOrUpdateRoute(dest, other, 3, []*btcec.PublicKey{other}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Nur die Route über den Nachbarn wird zurückgezogen
+	if err := protocol._enter_withdraw([]checked_route{{dest: dest, metric: routingmanager.ROUTE_METRIC_INFINITY}}, neighbor); err != nil {
+		t.Fatal(err)
+	}
+	found := routing_manager.LookupRoutes(dest)
+	if len(found) != 1 || !found[0].GetRelayPublicKey().IsEqual(other) {
+		t.Errorf("LookupRoutes(dest) = %v", found)
+	}
+}
+
+func TestBuildTableSplitHorizon(t *testing.T) {
+	protocol := newTestRouteProtocol(t)
+	routing_manager := protocol._kernel.GetRoutingManager()
+	own_key := protocol._kernel.GetPublicKey()
+	neighbor, other := newTestKey(t), newTestKey(t)
+	via_neighbor, via_other, learned, far := newTestKey(t), newTestKey(t), newTestKey(t), newTestKey(t)
+
+	// Es werden Routen über beide Nachbarn angelegt
+	if err := routing_manager.AddOrUpdateRoute(via_neighbor, neighbor, 2, []*btcec.PublicKey{neighbor}); err != nil {
+		t.Fatal(err)
+	}
+	if err := routing_manager.AddOrUpdateRoute(via_other, other, 2, []*btcec.PublicKey{other}); err != nil {
+		t.Fatal(err)
+	}
+	if err := routing_manager.AddOrUpdateRoute(far, other, routingmanager.ROUTE_METRIC_INFINITY-1, []*btcec.PublicKey{other}); err != nil {
+		t.Fatal(err)
+	}
+	if err := routing_manager.LearnRoute(learned, other); err != nil {
+		t.Fatal(err)
+	}
+
+	// Die Tabelle für den Nachbarn wird erstellt
+	table := protocol._build_table_for(neighbor)
+	by_dest := make(map[string]AdvertisedRoute)
+	for _, route := range table {
+		by_dest[string(route.Destination)] = route
+	}
+
+	// Das eigene Relay wird mit der Metrik 0 angekündigt
+	if route, found := by_dest[string(own_key.SerializeCompressed())]; !found || route.Metric != 0 {
+		t.Error("own relay is not advertised with metric 0")
+	}
+
+	// Routen über den anderen Nachbarn werden mit dem eigenen Relay am Anfang des Pfades angekündigt
+	route, found := by_dest[string(via_other.SerializeCompressed())]
+	if !found {
+		t.Fatal("route via other neighbor is not advertised")
+	}
+	if route.Metric != 3 || len(route.Path) != 2 || string(route.Path[0]) != string(own_key.SerializeCompressed()) || string(route.Path[1]) != string(other.SerializeCompressed()) {
+		t.Errorf("route via other neighbor = %+v", route)
+	}
+
+	// Routen über den Nachbarn selbst, gelernte Routen und nicht erreichbare Ziele werden nicht angekündigt
+	if _, found := by_dest[string(via_neighbor.SerializeCompressed())]; found {
+		t.Error("route via neighbor is advertised back to the neighbor")
+	}
+	if _, found := by_dest[string(learned.SerializeCompressed())]; found {
+		t.Error("learned route is advertised")
+	}
+	if _, found := by_dest[string(far.SerializeCompressed())]; found {
+		t.Error("unreachable route is advertised")
+	}
+}
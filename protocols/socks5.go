@@ -0,0 +1,448 @@
+package protocols
+
+import (
+	"crypto/subtle"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/fluffelpuff/RoueX/kernel"
+	"github.com/fluffelpuff/RoueX/logging"
+	"github.com/fluffelpuff/RoueX/utils"
+)
+
+// Gibt die Werte des SOCKS5 Protokolls an
+const (
+	SOCKS5_VERSION           = 0x05
+	SOCKS5_AUTH_VERSION      = 0x01
+	SOCKS5_HANDSHAKE_TIMEOUT = 30 * time.Second
+	SOCKS5_DEFAULT_ADDRESS   = "127.0.0.1"
+)
+
+// Gibt die Befehle, Adresstypen und Antworten des SOCKS5 Protokolls an
+const (
+	socks5_method_no_auth       = 0x00
+	socks5_method_password      = 0x02
+	socks5_method_none          = 0xff
+	socks5_auth_success         = 0x00
+	socks5_auth_failure         = 0x01
+	socks5_cmd_connect          = 0x01
+	socks5_atyp_ipv4            = 0x01
+	socks5_atyp_domain          = 0x03
+	socks5_atyp_ipv6            = 0x04
+	socks5_rep_success          = 0x00
+	socks5_rep_failure          = 0x01
+	socks5_rep_not_allowed      = 0x02
+	socks5_rep_net_unreachable  = 0x03
+	socks5_rep_host_unreachable = 0x04
+	socks5_rep_refused          = 0x05
+	socks5_rep_cmd_unsupported  = 0x07
+	socks5_rep_atyp_unsupported = 0x08
+)
+
+// Stellt einen lokalen SOCKS5 Server dar, die Verbindungen werden über Streams an ein Exit Relay weitergeleitet
+type Socks5ServerEP struct {
+	_kernel          *kernel.Kernel
+	_obj_id          string
+	_shutdown_signal bool
+	_is_running      bool
+	_listener        net.Listener
+	_lock            *sync.Mutex
+	_ip_adr          string
+	_port            int
+	_exit            *btcec.PublicKey
+	_username        string
+	_password        string
+	_closed          chan struct{}
+}
+
+// Registriert den Kernel im Module
+func (obj *Socks5ServerEP) RegisterKernel(k *kernel.Kernel) error {
+	k.Logger(logging.PROTOCOL).Info("Socks5ServerEP: registrated on kernel", "kernel", k.GetKernelID())
+	obj._kernel = k
+	return nil
+}
+
+// Gibt an ob der Server ausgeführt wird
+func (obj *Socks5ServerEP) _is_rn() bool {
+	obj._lock.Lock()
+	defer obj._lock.Unlock()
+	return obj._is_running
+}
+
+// Wird verwendet um den Serversocket herunterzufahren
+func (obj *Socks5ServerEP) Shutdown() {
+	// Log
+	obj._kernel.Logger(logging.PROTOCOL).Info("Socks5ServerEP: shutingdown", "id", obj._obj_id)
+
+	// Es wird signalisiert dass der Server heruntergefahren werden soll
+	obj._lock.Lock()
+	obj._shutdown_signal = true
+	if obj._listener != nil {
+		obj._listener.Close()
+	}
+	closed := obj._closed
+	obj._lock.Unlock()
+
+	// Es wird gewartet bis der Server beendet wurde
+	if closed != nil {
+		<-closed
+	}
+
+	// Log
+	obj._kernel.Logger(logging.PROTOCOL).Info("Socks5ServerEP: shutingdown complete", "id", obj._obj_id)
+}
+
+// Nimmt eingehende Verbindungen entgegen, bis der Server geschlossen wurde
+func (obj *Socks5ServerEP) _accept_loop() {
+	for {
+		// Es wird auf eine neue Verbindung gewartet
+		conn, err := obj._listener.Accept()
+		if err != nil {
+			obj._lock.Lock()
+			is_shutdown := obj._shutdown_signal
+			obj._lock.Unlock()
+			if !is_shutdown {
+				obj._kernel.Logger(logging.PROTOCOL).Warn("Socks5ServerEP: error by accepting connection", "id", obj._obj_id, "error", err.Error())
+			}
+			break
+		}
+
+		// Die Verbindung wird in einem eigenen Thread verarbeitet
+		go obj._handle_connection(conn.(*net.TCPConn))
+	}
+
+	// Es wird Signalisiert dass der Server beendet wurde
+	obj._lock.Lock()
+	obj._is_running = false
+	close(obj._closed)
+	obj._lock.Unlock()
+
+	// Log
+	obj._kernel.Logger(logging.PROTOCOL).Info("Socks5ServerEP: closed", "id", obj._obj_id, "endpoint", obj._listener.Addr().String())
+}
+
+// Sendet eine Antwort auf eine SOCKS5 Anfrage
+func _socks5_reply(conn net.Conn, rep uint8) error {
+	_, err := conn.Write([]byte{SOCKS5_VERSION, rep, 0x00, socks5_atyp_ipv4, 0, 0, 0, 0, 0, 0})
+	return err
+}
+
+// Wandelt den Status eines Exit Relays in eine SOCKS5 Antwort um
+func _socks5_reply_by_exit_status(status uint8) uint8 {
+	switch status {
+	case EXIT_STATUS_OK:
+		return socks5_rep_success
+	case EXIT_STATUS_NOT_ALLOWED:
+		return socks5_rep_not_allowed
+	case EXIT_STATUS_UNREACHABLE:
+		return socks5_rep_host_unreachable
+	case EXIT_STATUS_REFUSED:
+		return socks5_rep_refused
+	default:
+		return socks5_rep_failure
+	}
+}
+
+// Ließt einen Wert mit vorangestellter Länge ein
+func _socks5_read_value(conn net.Conn) ([]byte, error) {
+	size := make([]byte, 1)
+	if _, err := io.ReadFull(conn, size); err != nil {
+		return nil, err
+	}
+	value := make([]byte, size[0])
+	if _, err := io.ReadFull(conn, value); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+// Führt die Anmeldung mit Benutzername und Passwort durch (RFC 1929)
+func _socks5_authenticate(conn net.Conn, username string, password string) error {
+	// Die Version der Anmeldung wird eingelesen
+	version := make([]byte, 1)
+	if _, err := io.ReadFull(conn, version); err != nil {
+		return fmt.Errorf("_socks5_authenticate: 1: " + err.Error())
+	}
+	if version[0] != SOCKS5_AUTH_VERSION {
+		return fmt.Errorf("_socks5_authenticate: 2: unsupported auth version")
+	}
+
+	// Der Benutzername und das Passwort werden eingelesen
+	recived_username, err := _socks5_read_value(conn)
+	if err != nil {
+		return fmt.Errorf("_socks5_authenticate: 3: " + err.Error())
+	}
+	recived_password, err := _socks5_read_value(conn)
+	if err != nil {
+		return fmt.Errorf("_socks5_authenticate: 4: " + err.Error())
+	}
+
+	// Die Angaben werden geprüft, beide Vergleiche werden immer durchgeführt
+	username_ok := subtle.ConstantTimeCompare(recived_username, []byte(username)) == 1
+	password_ok := subtle.ConstantTimeCompare(recived_password, []byte(password)) == 1
+	if !username_ok || !password_ok {
+		conn.Write([]byte{SOCKS5_AUTH_VERSION, socks5_auth_failure})
+		return fmt.Errorf("_socks5_authenticate: 5: invalid username or password")
+	}
+	if _, err := conn.Write([]byte{SOCKS5_AUTH_VERSION, socks5_auth_success}); err != nil {
+		return fmt.Errorf("_socks5_authenticate: 6: " + err.Error())
+	}
+	return nil
+}
+
+// Führt den SOCKS5 Handshake durch und gibt das angefragte Ziel zurück, ist ein Benutzername angegeben, muss sich der Client anmelden
+func _socks5_handshake(conn net.Conn, username string, password string) (*ExitRequest, error) {
+	// Die Authentifizierungsmethoden werden eingelesen
+	header := make([]byte, 2)
+	if _, err := io.ReadFull(conn, header); err != nil {
+		return nil, fmt.Errorf("_socks5_handshake: 1: " + err.Error())
+	}
+	if header[0] != SOCKS5_VERSION {
+		return nil, fmt.Errorf("_socks5_handshake: 2: unsupported version")
+	}
+	methods := make([]byte, header[1])
+	if _, err := io.ReadFull(conn, methods); err != nil {
+		return nil, fmt.Errorf("_socks5_handshake: 3: " + err.Error())
+	}
+
+	// Ohne Benutzername wird der Zugriff ohne Authentifizierung verwendet, der Server lauscht dann nur lokal
+	required_method := byte(socks5_method_no_auth)
+	if len(username) > 0 {
+		required_method = socks5_method_password
+	}
+	has_method := false
+	for _, method := range methods {
+		if method == required_method {
+			has_method = true
+		}
+	}
+	if !has_method {
+		conn.Write([]byte{SOCKS5_VERSION, socks5_method_none})
+		return nil, fmt.Errorf("_socks5_handshake: 4: no supported auth method")
+	}
+	if _, err := conn.Write([]byte{SOCKS5_VERSION, required_method}); err != nil {
+		return nil, fmt.Errorf("_socks5_handshake: 5: " + err.Error())
+	}
+
+	// Sofern erforderlich, meldet sich der Client an
+	if required_method == socks5_method_password {
+		if err := _socks5_authenticate(conn, username, password); err != nil {
+			return nil, fmt.Errorf("_socks5_handshake: 6: " + err.Error())
+		}
+	}
+
+	// Die Anfrage wird eingelesen
+	request := make([]byte, 4)
+	if _, err := io.ReadFull(conn, request); err != nil {
+		return nil, fmt.Errorf("_socks5_handshake: 7: " + err.Error())
+	}
+	if request[0] != SOCKS5_VERSION {
+		return nil, fmt.Errorf("_socks5_handshake: 8: unsupported version")
+	}
+	if request[1] != socks5_cmd_connect {
+		_socks5_reply(conn, socks5_rep_cmd_unsupported)
+		return nil, fmt.Errorf("_socks5_handshake: 9: unsupported command")
+	}
+
+	// Die Zieladresse wird eingelesen
+	var host string
+	switch request[3] {
+	case socks5_atyp_ipv4, socks5_atyp_ipv6:
+		size := net.IPv4len
+		if request[3] == socks5_atyp_ipv6 {
+			size = net.IPv6len
+		}
+		ip := make([]byte, size)
+		if _, err := io.ReadFull(conn, ip); err != nil {
+			return nil, fmt.Errorf("_socks5_handshake: 10: " + err.Error())
+		}
+		host = net.IP(ip).String()
+	case socks5_atyp_domain:
+		size := make([]byte, 1)
+		if _, err := io.ReadFull(conn, size); err != nil {
+			return nil, fmt.Errorf("_socks5_handshake: 11: " + err.Error())
+		}
+		domain := make([]byte, size[0])
+		if _, err := io.ReadFull(conn, domain); err != nil {
+			return nil, fmt.Errorf("_socks5_handshake: 12: " + err.Error())
+		}
+		host = string(domain)
+	default:
+		_socks5_reply(conn, socks5_rep_atyp_unsupported)
+		return nil, fmt.Errorf("_socks5_handshake: 13: unsupported address type")
+	}
+
+	// Der Port wird eingelesen
+	port := make([]byte, 2)
+	if _, err := io.ReadFull(conn, port); err != nil {
+		return nil, fmt.Errorf("_socks5_handshake: 14: " + err.Error())
+	}
+	return &ExitRequest{Host: host, Port: binary.BigEndian.Uint16(port)}, nil
+}
+
+// Öffnet einen Stream zum Exit Relay und übermittelt die Anfrage, gibt den Status des Exit Relays zurück
+func (obj *Socks5ServerEP) _open_exit(req *ExitRequest) (*StreamConn, uint8, error) {
+	// Der Stream zum Exit Relay wird aufgebaut
+	stream_protocol, err := getKernelStreamProtocol(obj._kernel)
+	if err != nil {
+		return nil, 0, fmt.Errorf("_open_exit: 1: " + err.Error())
+	}
+	stream, err := stream_protocol.OpenStream(obj._exit, EXIT_STREAM_PORT)
+	if err != nil {
+		return nil, 0, fmt.Errorf("_open_exit: 2: " + err.Error())
+	}
+
+	// Die Anfrage wird übermittelt und die Antwort wird eingelesen
+	if err := writeExitRequest(stream, req); err != nil {
+		stream.Close()
+		return nil, 0, fmt.Errorf("_open_exit: 3: " + err.Error())
+	}
+	status := make([]byte, 1)
+	if _, err := io.ReadFull(stream, status); err != nil {
+		stream.Close()
+		return nil, 0, fmt.Errorf("_open_exit: 4: " + err.Error())
+	}
+	if status[0] != EXIT_STATUS_OK {
+		stream.Close()
+	}
+	return stream, status[0], nil
+}
+
+// Verarbeitet eine eingehende SOCKS5 Verbindung
+func (obj *Socks5ServerEP) _handle_connection(conn *net.TCPConn) {
+	// Der Handshake wird mit einem Zeitlimit durchgeführt
+	conn.SetDeadline(time.Now().Add(SOCKS5_HANDSHAKE_TIMEOUT))
+	req, err := _socks5_handshake(conn, obj._username, obj._password)
+	if err != nil {
+		obj._kernel.Logger(logging.PROTOCOL).Warn("Socks5ServerEP: handshake failed", "from", conn.RemoteAddr().String(), "error", err.Error())
+		conn.Close()
+		return
+	}
+
+	// Die Verbindung zum Ziel wird über das Exit Relay aufgebaut
+	target := net.JoinHostPort(req.Host, strconv.Itoa(int(req.Port)))
+	stream, status, err := obj._open_exit(req)
+	if err != nil {
+		obj._kernel.Logger(logging.PROTOCOL).Warn("Socks5ServerEP: exit relay not reachable", "target", target, "error", err.Error())
+		_socks5_reply(conn, socks5_rep_net_unreachable)
+		conn.Close()
+		return
+	}
+	if status != EXIT_STATUS_OK {
+		obj._kernel.Logger(logging.PROTOCOL).Info("Socks5ServerEP: connection rejected by exit relay", "target", target, "status", status)
+		_socks5_reply(conn, _socks5_reply_by_exit_status(status))
+		conn.Close()
+		return
+	}
+
+	// Der Verbindungsaufbau wird bestätigt
+	if err := _socks5_reply(conn, socks5_rep_success); err != nil {
+		stream.Close()
+		conn.Close()
+		return
+	}
+	conn.SetDeadline(time.Time{})
+
+	// Log
+	obj._kernel.Logger(logging.PROTOCOL).Info("Socks5ServerEP: connection opened", "target", target, "exit", utils.ConvertPublicKeyToAddress(obj._exit))
+
+	// Die Daten werden übertragen bis beide Seiten beendet wurden
+	pipeConnections(conn, stream)
+
+	// Log
+	obj._kernel.Logger(logging.PROTOCOL).Info("Socks5ServerEP: connection closed", "target", target)
+}
+
+// Startet den eigentlichen Server
+func (obj *Socks5ServerEP) Start() error {
+	// Der Server Socket wird erstellt
+	listener, err := net.Listen("tcp", net.JoinHostPort(obj._ip_adr, strconv.Itoa(obj._port)))
+	if err != nil {
+		return fmt.Errorf("Socks5ServerEP: " + err.Error())
+	}
+
+	// Es wird Signalisiert dass der Server ausgeführt wird
+	obj._lock.Lock()
+	obj._listener = listener
+	obj._is_running = true
+	obj._closed = make(chan struct{})
+	obj._lock.Unlock()
+
+	// Die Verbindungen werden in einem eigenen Thread angenommen
+	go obj._accept_loop()
+
+	// Log
+	obj._kernel.Logger(logging.PROTOCOL).Info("Socks5ServerEP: new socks5 server started", "id", obj._obj_id, "endpoint", listener.Addr().String())
+	return nil
+}
+
+// Gibt das Aktuelle Protokoll aus
+func (obj *Socks5ServerEP) GetProtocol() string {
+	return "socks5"
+}
+
+// Gibt die Aktuelle Objekt ID aus
+func (obj *Socks5ServerEP) GetObjectId() string {
+	return obj._obj_id
+}
+
+// Gibt an ob der Server bereits gestartet wurde
+func (obj *Socks5ServerEP) IsRunning() bool {
+	return obj._is_rn()
+}
+
+// Gibt den Verwendeten Port zurück
+func (obj *Socks5ServerEP) GetLocalIPBasedPort() uint64 {
+	return uint64(obj._port)
+}
+
+// Der SOCKS5 Server nimmt keine Relay Verbindungen an und wird daher nicht für P2P Verbindungen angeboten
+func (obj *Socks5ServerEP) IsIpBasedServer() bool {
+	return false
+}
+
+// Gibt an ob es sich um eine lokale Adresse handelt
+func _is_loopback_address(ip_adr string) bool {
+	if ip_adr == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(ip_adr)
+	return ip != nil && ip.IsLoopback()
+}
+
+// Erstellt einen neuen lokalen SOCKS5 Server, welcher alle Verbindungen über das angegebene Exit Relay aufbaut,
+// ohne Adresse wird nur lokal gelauscht, auf anderen Adressen wird nur mit Benutzername und Passwort gelauscht
+func CreateNewLocalSocks5ServerEP(ip_adr string, port uint64, exit *btcec.PublicKey, username string, password string) (*Socks5ServerEP, error) {
+	// Es muss ein Exit Relay angegeben werden
+	if exit == nil {
+		return nil, fmt.Errorf("CreateNewLocalSocks5ServerEP: 1: exit relay required")
+	}
+
+	// Sollte keine Adresse angegeben wurden sein, wird nur lokal gelauscht
+	if len(ip_adr) == 0 {
+		ip_adr = SOCKS5_DEFAULT_ADDRESS
+	}
+
+	// Die Anmeldedaten werden geprüft, sie dürfen maximal 255 Bytes lang sein
+	if len(username) > 255 || len(password) > 255 || (len(username) == 0) != (len(password) == 0) {
+		return nil, fmt.Errorf("CreateNewLocalSocks5ServerEP: 2: invalid username or password")
+	}
+
+	// Ohne Anmeldung darf nur auf einer lokalen Adresse gelauscht werden
+	if len(username) == 0 && !_is_loopback_address(ip_adr) {
+		return nil, fmt.Errorf("CreateNewLocalSocks5ServerEP: 3: non loopback address " + ip_adr + " requires username and password")
+	}
+
+	// Das Objekt wird vorbereitet
+	result_obj := &Socks5ServerEP{_obj_id: utils.RandStringRunes(16), _lock: new(sync.Mutex), _ip_adr: ip_adr, _port: int(port), _exit: exit, _username: username, _password: password}
+
+	// Log
+	logging.Logger(logging.PROTOCOL).Info("Socks5ServerEP: new socks5 server endpoint created", "address", ip_adr, "port", port, "auth", len(username) > 0)
+	return result_obj, nil
+}
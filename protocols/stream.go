@@ -0,0 +1,1308 @@
+package protocols
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	addresspackages "github.com/fluffelpuff/RoueX/address_packages"
+	"github.com/fluffelpuff/RoueX/kernel"
+	"github.com/fluffelpuff/RoueX/logging"
+	"github.com/fluffelpuff/RoueX/utils"
+	"github.com/fxamacker/cbor"
+)
+
+// Gibt den Protokolltypen des Stream Protokolls an
+const STREAM_PROTOCOL_TYPE uint8 = 3
+
+// Gibt die Grenzwerte der Stream Sitzungen an
+const (
+	STREAM_MAX_SEGMENT_SIZE = 4096
+	STREAM_RECEIVE_WINDOW   = 256 * 1024
+	STREAM_SEND_BUFFER      = 256 * 1024
+	STREAM_ACCEPT_BACKLOG   = 16
+	STREAM_MAX_RETRIES      = 8
+	STREAM_RTO_MIN          = 250 * time.Millisecond
+	STREAM_RTO_MAX          = 30 * time.Second
+	STREAM_LINGER_TIME      = 5 * time.Second
+	STREAM_IDLE_TIMEOUT     = 2 * time.Minute
+)
+
+// Definiert alle verfügbaren Segment Typen
+const (
+	STREAM_SYN     = uint8(0)
+	STREAM_SYN_ACK = uint8(1)
+	STREAM_DATA    = uint8(2)
+	STREAM_ACK     = uint8(3)
+	STREAM_FIN     = uint8(4)
+	STREAM_RST     = uint8(5)
+)
+
+// Stellt ein Stream Segment dar, Seq und Ack beziehen sich auf die übertragenen Bytes, das FIN Segment belegt ein Byte
+type StreamSegment struct {
+	Type      uint8
+	Id        uint64
+	Initiator bool
+	Port      uint16
+	Seq       uint64
+	Ack       uint64
+	Window    uint64
+	Data      []byte
+}
+
+// Gibt den Status einer Stream Sitzung an
+type stream_state uint8
+
+// Definiert alle verfügbaren Stream Zustände
+const (
+	STREAM_CONNECTING  = stream_state(0)
+	STREAM_ESTABLISHED = stream_state(1)
+	STREAM_CLOSED      = stream_state(2)
+)
+
+// Stellt ein gesendetes aber noch nicht bestätigtes Segment dar
+type stream_unacked_segment struct {
+	seq           uint64
+	data          []byte
+	fin           bool
+	sent_at       time.Time
+	retransmitted bool
+}
+
+// Gibt das Ende des Segmentes im Sequenzraum an
+func (obj *stream_unacked_segment) end() uint64 {
+	if obj.fin {
+		return obj.seq + 1
+	}
+	return obj.seq + uint64(len(obj.data))
+}
+
+// Stellt eine Stream Sitzung dar
+type rouex_stream struct {
+	_id         string
+	_wire_id    uint64
+	_initiator  bool
+	_remote     *btcec.PublicKey
+	_port       uint16
+	_protocol   *ROUEX_STREAM_PROTOCOL
+	_api_conn   *kernel.APIProcessConnectionWrapper
+	_lock       *sync.Mutex
+	_cond       *sync.Cond
+	_state      stream_state
+	_err        error
+	_api_closed bool
+	_killed     bool
+
+	// Gibt an ob keine weiteren Daten mehr geschrieben werden
+	_write_closed bool
+
+	// Sendeseite
+	_send_buffer []byte
+	_next_seq    uint64
+	_acked       uint64
+	_unacked     []*stream_unacked_segment
+	_peer_window uint64
+	_fin_queued  bool
+	_retries     uint8
+	_srtt        time.Duration
+	_rto         time.Duration
+	_timer_start time.Time
+
+	// Abfrage des geschlossenen Fensters der Gegenseite
+	_persist_at      time.Time
+	_persist_backoff time.Duration
+
+	// Zeitpunkt des letzten Segmentes der Gegenseite, wird für die Zeitüberschreitung verwendet
+	_last_activity time.Time
+
+	// Weckt die Zeitsteuerung auf, sobald neue zeitgesteuerte Vorgänge anstehen können
+	_wake chan struct{}
+
+	// Empfangsseite
+	_recv_next          uint64
+	_recv_buffer        []byte
+	_out_of_order       map[uint64][]byte
+	_out_of_order_size  uint64
+	_remote_fin         bool
+	_remote_fin_seq     uint64
+	_has_remote_fin_seq bool
+	_advertised_window  uint64
+	_finished_at        time.Time
+}
+
+// Gibt die ID des Streams zurück
+func (obj *rouex_stream) GetId() string {
+	return obj._id
+}
+
+// Wird aufgerufen wenn der API Prozess getrennt wurde, der Stream wird zurückgesetzt
+func (obj *rouex_stream) Close() {
+	// Der Stream wird als geschlossen markiert
+	obj._lock.Lock()
+	obj._killed = true
+	obj._api_closed = true
+	obj._write_closed = true
+	var out []*StreamSegment
+	if obj._state != STREAM_CLOSED {
+		out = append(out, obj._make_segment(STREAM_RST, obj._next_seq, nil))
+		obj._fail(fmt.Errorf("closed by api process"))
+	}
+	obj._lock.Unlock()
+
+	// Die Gegenseite wird informiert und der Stream wird entfernt
+	obj._transmit(out)
+	obj._protocol._release_stream(obj)
+	obj._kick()
+}
+
+// Weckt die Zeitsteuerung des Streams auf
+func (obj *rouex_stream) _kick() {
+	select {
+	case obj._wake <- struct{}{}:
+	default:
+	}
+}
+
+// Gibt die Größe des freien Empfangsfensters an
+func (obj *rouex_stream) _free_window() uint64 {
+	used := uint64(len(obj._recv_buffer)) + obj._out_of_order_size
+	if used >= STREAM_RECEIVE_WINDOW {
+		return 0
+	}
+	return STREAM_RECEIVE_WINDOW - used
+}
+
+// Erstellt ein neues Segment mit dem aktuellen Bestätigungs- und Fensterwert, der Threadlock muss gehalten werden
+func (obj *rouex_stream) _make_segment(tpe uint8, seq uint64, data []byte) *StreamSegment {
+	obj._advertised_window = obj._free_window()
+	return &StreamSegment{
+		Type:      tpe,
+		Id:        obj._wire_id,
+		Initiator: obj._initiator,
+		Port:      obj._port,
+		Seq:       seq,
+		Ack:       obj._recv_next,
+		Window:    obj._advertised_window,
+		Data:      data,
+	}
+}
+
+// Beendet den Stream mit einem Fehler, der Threadlock muss gehalten werden
+func (obj *rouex_stream) _fail(err error) {
+	if obj._state == STREAM_CLOSED {
+		return
+	}
+	obj._state = STREAM_CLOSED
+	obj._err = err
+	obj._cond.Broadcast()
+}
+
+// Übermittelt die Segmente an die Gegenseite
+func (obj *rouex_stream) _transmit(segments []*StreamSegment) {
+	for _, seg := range segments {
+		if err := obj._protocol._send_segment(obj._remote, seg); err != nil {
+			obj._protocol._kernel.Logger(logging.PROTOCOL).Warn("ROUEX_STREAM_PROTOCOL: error by sending segment", "stream", obj._id, "error", err.Error())
+		}
+	}
+}
+
+// Überträgt so viele Daten aus dem Sendepuffer wie das Fenster der Gegenseite zulässt, der Threadlock muss gehalten werden
+func (obj *rouex_stream) _fill_window() []*StreamSegment {
+	// Es wird geprüft ob die Verbindung aufgebaut ist
+	if obj._state != STREAM_ESTABLISHED {
+		return nil
+	}
+
+	// Die Daten werden in Segmente aufgeteilt
+	out := []*StreamSegment{}
+	now := time.Now()
+	for len(obj._send_buffer) > 0 {
+		// Es wird geprüft ob das Fenster der Gegenseite noch Platz hat
+		in_flight := obj._next_seq - obj._acked
+		if in_flight >= obj._peer_window {
+			break
+		}
+
+		// Die Größe des Segmentes wird ermittelt
+		size := uint64(len(obj._send_buffer))
+		if size > STREAM_MAX_SEGMENT_SIZE {
+			size = STREAM_MAX_SEGMENT_SIZE
+		}
+		if size > obj._peer_window-in_flight {
+			size = obj._peer_window - in_flight
+		}
+
+		// Das Segment wird erstellt und als unbestätigt gespeichert
+		data := make([]byte, size)
+		copy(data, obj._send_buffer[:size])
+		obj._send_buffer = obj._send_buffer[size:]
+		if len(obj._unacked) == 0 {
+			obj._timer_start = now
+		}
+		obj._unacked = append(obj._unacked, &stream_unacked_segment{seq: obj._next_seq, data: data, sent_at: now})
+		out = append(out, obj._make_segment(STREAM_DATA, obj._next_seq, data))
+		obj._next_seq += size
+	}
+
+	// Sollte der Stream geschlossen werden und alle Daten übertragen sein, wird das FIN Segment gesendet
+	if obj._write_closed && len(obj._send_buffer) == 0 && !obj._fin_queued {
+		if len(obj._unacked) == 0 {
+			obj._timer_start = now
+		}
+		obj._fin_queued = true
+		obj._unacked = append(obj._unacked, &stream_unacked_segment{seq: obj._next_seq, fin: true, sent_at: now})
+		out = append(out, obj._make_segment(STREAM_FIN, obj._next_seq, nil))
+		obj._next_seq++
+	}
+
+	// Die Segmente werden zurückgegeben
+	return out
+}
+
+// Verarbeitet die Bestätigung und das Fenster eines Segmentes, der Threadlock muss gehalten werden
+func (obj *rouex_stream) _enter_ack(seg *StreamSegment) {
+	// Bestätigungen außerhalb des gesendeten Bereiches werden ignoriert
+	if seg.Ack < obj._acked || seg.Ack > obj._next_seq {
+		return
+	}
+
+	// Es wird geprüft ob neue Daten bestätigt wurden
+	if seg.Ack > obj._acked {
+		// Die bestätigten Segmente werden entfernt, die Umlaufzeit wird anhand nicht wiederholter Segmente gemessen
+		now := time.Now()
+		remaining := obj._unacked[:0]
+		for _, entry := range obj._unacked {
+			if entry.end() > seg.Ack {
+				remaining = append(remaining, entry)
+				continue
+			}
+			if !entry.retransmitted {
+				obj._update_rtt(now.Sub(entry.sent_at))
+			}
+		}
+		obj._unacked = remaining
+		obj._acked = seg.Ack
+		obj._timer_start = now
+		obj._cond.Broadcast()
+	}
+
+	// Jede gültige Bestätigung zeigt dass die Gegenseite erreichbar ist, das Fenster der Gegenseite wird übernommen
+	obj._retries = 0
+	obj._peer_window = seg.Window
+}
+
+// Aktualisiert die geglättete Umlaufzeit und das Wiederholungsintervall, der Threadlock muss gehalten werden
+func (obj *rouex_stream) _update_rtt(sample time.Duration) {
+	if obj._srtt == 0 {
+		obj._srtt = sample
+	} else {
+		obj._srtt = (7*obj._srtt + sample) / 8
+	}
+	obj._rto = 2 * obj._srtt
+	if obj._rto < STREAM_RTO_MIN {
+		obj._rto = STREAM_RTO_MIN
+	}
+	if obj._rto > STREAM_RTO_MAX {
+		obj._rto = STREAM_RTO_MAX
+	}
+}
+
+// Verarbeitet ein Daten oder FIN Segment und gibt die Bestätigung zurück, der Threadlock muss gehalten werden
+func (obj *rouex_stream) _enter_data(seg *StreamSegment) *StreamSegment {
+	// Die Daten werden abhängig von ihrer Position im Sequenzraum übernommen
+	if seg.Type == STREAM_FIN {
+		obj._remote_fin_seq = seg.Seq
+		obj._has_remote_fin_seq = true
+	} else if len(seg.Data) > 0 {
+		size := uint64(len(seg.Data))
+		switch {
+		case seg.Seq == obj._recv_next:
+			if uint64(len(obj._recv_buffer))+size <= STREAM_RECEIVE_WINDOW {
+				obj._recv_buffer = append(obj._recv_buffer, seg.Data...)
+				obj._recv_next += size
+			}
+		case seg.Seq > obj._recv_next && seg.Seq+size <= obj._recv_next+STREAM_RECEIVE_WINDOW:
+			if _, found := obj._out_of_order[seg.Seq]; !found {
+				obj._out_of_order[seg.Seq] = seg.Data
+				obj._out_of_order_size += size
+			}
+		}
+	}
+
+	// Die zwischengespeicherten Segmente welche nun an der Reihe sind werden übernommen
+	for {
+		data, found := obj._out_of_order[obj._recv_next]
+		if !found {
+			break
+		}
+		delete(obj._out_of_order, obj._recv_next)
+		obj._out_of_order_size -= uint64(len(data))
+		obj._recv_buffer = append(obj._recv_buffer, data...)
+		obj._recv_next += uint64(len(data))
+	}
+
+	// Es wird geprüft ob das Ende des Streams erreicht wurde
+	if obj._has_remote_fin_seq && !obj._remote_fin && obj._recv_next == obj._remote_fin_seq {
+		obj._remote_fin = true
+		obj._recv_next++
+	}
+
+	// Sollte der Stream lokal geschlossen worden sein, werden die Daten verworfen
+	if obj._api_closed {
+		obj._recv_buffer = nil
+	}
+
+	// Die Bestätigung wird erstellt
+	obj._cond.Broadcast()
+	return obj._make_segment(STREAM_ACK, obj._next_seq, nil)
+}
+
+// Verarbeitet ein eingetroffenes Segment
+func (obj *rouex_stream) _enter_segment(seg *StreamSegment) {
+	// Der Threadlock wird verwendet
+	obj._lock.Lock()
+
+	// Sollte der Stream bereits geschlossen sein, wird das Segment verworfen
+	if obj._state == STREAM_CLOSED {
+		obj._lock.Unlock()
+		return
+	}
+
+	// Das Segment wird anhand seines Types verarbeitet
+	var out []*StreamSegment
+	obj._last_activity = time.Now()
+	switch seg.Type {
+	case STREAM_RST:
+		obj._fail(fmt.Errorf("connection reset by peer"))
+	case STREAM_SYN_ACK, STREAM_DATA, STREAM_FIN, STREAM_ACK:
+		// Jedes Segment der Gegenseite bestätigt den Verbindungsaufbau
+		if obj._state == STREAM_CONNECTING {
+			if obj._retries == 0 {
+				obj._update_rtt(time.Since(obj._timer_start))
+			}
+			obj._state = STREAM_ESTABLISHED
+			obj._retries = 0
+			obj._cond.Broadcast()
+		}
+
+		// Die Bestätigung und die Daten werden übernommen
+		obj._enter_ack(seg)
+		if seg.Type == STREAM_DATA || seg.Type == STREAM_FIN {
+			out = append(out, obj._enter_data(seg))
+		}
+		out = append(out, obj._fill_window()...)
+	}
+
+	// Der Threadlock wird freigegeben
+	obj._lock.Unlock()
+
+	// Die Antworten werden übermittelt, die Zeitsteuerung wird aufgeweckt
+	obj._transmit(out)
+	obj._kick()
+}
+
+// Gibt an, wie lange bis zum nächsten zeitgesteuerten Vorgang gewartet wird, bei 0 steht kein Vorgang an, der Threadlock muss gehalten werden
+func (obj *rouex_stream) _next_wait(now time.Time) time.Duration {
+	// Es wird der früheste Zeitpunkt aller anstehenden Vorgänge ermittelt
+	var deadline time.Time
+	consider := func(t time.Time) {
+		if deadline.IsZero() || t.Before(deadline) {
+			deadline = t
+		}
+	}
+	if obj._state == STREAM_CLOSED {
+		return 0
+	}
+	if obj._state == STREAM_CONNECTING || len(obj._unacked) > 0 {
+		consider(obj._timer_start.Add(obj._rto))
+	}
+	if !obj._persist_at.IsZero() {
+		consider(obj._persist_at)
+		consider(obj._last_activity.Add(STREAM_IDLE_TIMEOUT))
+	}
+	if obj._fin_queued && obj._acked == obj._next_seq {
+		if obj._remote_fin && !obj._finished_at.IsZero() {
+			consider(obj._finished_at.Add(STREAM_LINGER_TIME))
+		} else if !obj._remote_fin {
+			consider(obj._last_activity.Add(STREAM_IDLE_TIMEOUT))
+		}
+	}
+
+	// Sollte kein Vorgang anstehen, wird nicht gewartet
+	if deadline.IsZero() {
+		return 0
+	}
+	if wait := deadline.Sub(now); wait > 0 {
+		return wait
+	}
+	return time.Millisecond
+}
+
+// Setzt den Stream aufgrund einer Zeitüberschreitung zurück, der Threadlock muss gehalten werden
+func (obj *rouex_stream) _timeout(reason string) []*StreamSegment {
+	rst := obj._make_segment(STREAM_RST, obj._next_seq, nil)
+	obj._fail(fmt.Errorf(reason))
+	return []*StreamSegment{rst}
+}
+
+// Führt die zeitgesteuerten Vorgänge durch, gibt an ob der Stream entfernt werden kann und wie lange bis zum nächsten Vorgang gewartet wird
+func (obj *rouex_stream) _tick() ([]*StreamSegment, bool, time.Duration) {
+	// Der Threadlock wird verwendet
+	obj._lock.Lock()
+	defer obj._lock.Unlock()
+
+	// Es wird geprüft ob der Stream bereits geschlossen wurde
+	now := time.Now()
+	switch obj._state {
+	case STREAM_CLOSED:
+		return nil, true, 0
+	case STREAM_CONNECTING:
+		// Das SYN Segment wird wiederholt, sollte keine Antwort eintreffen
+		if now.Sub(obj._timer_start) < obj._rto {
+			return nil, false, obj._next_wait(now)
+		}
+		obj._retries++
+		if obj._retries > STREAM_MAX_RETRIES {
+			obj._fail(fmt.Errorf("connection timeout"))
+			return nil, true, 0
+		}
+		obj._timer_start = now
+		obj._rto = _min_duration(2*obj._rto, STREAM_RTO_MAX)
+		return []*StreamSegment{obj._make_segment(STREAM_SYN, 0, nil)}, false, obj._next_wait(now)
+	}
+
+	// Sollte der Timer abgelaufen sein, wird das älteste unbestätigte Segment wiederholt
+	out := []*StreamSegment{}
+	if len(obj._unacked) > 0 && now.Sub(obj._timer_start) >= obj._rto {
+		obj._retries++
+		if obj._retries > STREAM_MAX_RETRIES {
+			return obj._timeout("connection timeout"), true, 0
+		}
+		entry := obj._unacked[0]
+		entry.retransmitted = true
+		entry.sent_at = now
+		obj._timer_start = now
+		obj._rto = _min_duration(2*obj._rto, STREAM_RTO_MAX)
+		if entry.fin {
+			out = append(out, obj._make_segment(STREAM_FIN, entry.seq, nil))
+		} else {
+			out = append(out, obj._make_segment(STREAM_DATA, entry.seq, entry.data))
+		}
+	}
+
+	// Sollte das Fenster der Gegenseite geschlossen sein, wird es mit einem eigenen, exponentiell wachsenden Intervall abgefragt,
+	// die Abfragen zählen nicht zu den Wiederholungen, antwortet die Gegenseite nicht mehr wird der Stream zurückgesetzt
+	if len(obj._unacked) == 0 && len(obj._send_buffer) > 0 && obj._peer_window == 0 {
+		if now.Sub(obj._last_activity) >= STREAM_IDLE_TIMEOUT {
+			return obj._timeout("connection timeout"), true, 0
+		}
+		if obj._persist_at.IsZero() {
+			obj._persist_backoff = obj._rto
+			obj._persist_at = now.Add(obj._persist_backoff)
+		} else if !now.Before(obj._persist_at) {
+			obj._persist_backoff = _min_duration(2*obj._persist_backoff, STREAM_RTO_MAX)
+			obj._persist_at = now.Add(obj._persist_backoff)
+			out = append(out, obj._make_segment(STREAM_DATA, obj._next_seq, nil))
+		}
+	} else {
+		obj._persist_at = time.Time{}
+	}
+
+	// Es wird geprüft ob der Stream lokal geschlossen und alle Daten bestätigt wurden
+	if obj._fin_queued && obj._acked == obj._next_seq {
+		if obj._remote_fin {
+			// Beide Seiten haben den Stream geschlossen, der Stream wird noch eine Zeit lang gehalten
+			// damit wiederholte FIN Segmente der Gegenseite bestätigt werden können
+			if obj._finished_at.IsZero() {
+				obj._finished_at = now
+			} else if now.Sub(obj._finished_at) >= STREAM_LINGER_TIME {
+				obj._state = STREAM_CLOSED
+				obj._cond.Broadcast()
+				return nil, true, 0
+			}
+		} else if now.Sub(obj._last_activity) >= STREAM_IDLE_TIMEOUT {
+			// Die Gegenseite hat den Stream nicht rechtzeitig geschlossen
+			return obj._timeout("half-close timeout"), true, 0
+		}
+	}
+
+	// Der Stream bleibt bestehen
+	return out, false, obj._next_wait(now)
+}
+
+// Führt die Zeitsteuerung des Streams durch, bis dieser geschlossen wurde, der Timer wird nur gestellt
+// solange zeitgesteuerte Vorgänge anstehen, ansonsten wird gewartet bis der Stream aufgeweckt wird
+func (obj *rouex_stream) _run() {
+	for {
+		// Die anstehenden Vorgänge werden durchgeführt
+		out, done, wait := obj._tick()
+		obj._transmit(out)
+		if done {
+			obj._protocol._release_stream(obj)
+			return
+		}
+
+		// Es wird auf den nächsten Vorgang, das Aufwecken oder das Beenden des Kernels gewartet
+		var timer *time.Timer
+		var timer_c <-chan time.Time
+		if wait > 0 {
+			timer = time.NewTimer(wait)
+			timer_c = timer.C
+		}
+		select {
+		case <-obj._protocol._kernel.Context().Done():
+			obj._lock.Lock()
+			obj._fail(fmt.Errorf("closed by kernel"))
+			obj._lock.Unlock()
+		case <-obj._wake:
+		case <-timer_c:
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+	}
+}
+
+// Wartet bis der Verbindungsaufbau abgeschlossen wurde
+func (obj *rouex_stream) _wait_established() error {
+	obj._lock.Lock()
+	defer obj._lock.Unlock()
+	for obj._state == STREAM_CONNECTING {
+		obj._cond.Wait()
+	}
+	if obj._state != STREAM_ESTABLISHED {
+		return obj._err
+	}
+	return nil
+}
+
+// Ließt maximal 'max_size' Bytes aus dem Stream, gibt an ob das Ende des Streams erreicht wurde
+func (obj *rouex_stream) _read(max_size uint64) ([]byte, bool, error) {
+	// Der Threadlock wird verwendet
+	obj._lock.Lock()
+
+	// Es wird gewartet bis Daten vorhanden sind oder der Stream beendet wurde
+	for len(obj._recv_buffer) == 0 && !obj._remote_fin && obj._state != STREAM_CLOSED && !obj._api_closed {
+		obj._cond.Wait()
+	}
+
+	// Es wird geprüft ob Daten vorhanden sind
+	if len(obj._recv_buffer) == 0 {
+		defer obj._lock.Unlock()
+		if obj._remote_fin {
+			return nil, true, nil
+		}
+		if obj._err != nil {
+			return nil, false, obj._err
+		}
+		return nil, false, fmt.Errorf("stream closed")
+	}
+
+	// Die Daten werden aus dem Puffer entnommen
+	size := uint64(len(obj._recv_buffer))
+	if size > max_size {
+		size = max_size
+	}
+	data := make([]byte, size)
+	copy(data, obj._recv_buffer[:size])
+	obj._recv_buffer = obj._recv_buffer[size:]
+
+	// Sollte sich das Empfangsfenster deutlich vergrößert haben, wird die Gegenseite informiert
+	var out []*StreamSegment
+	if obj._state == STREAM_ESTABLISHED && obj._free_window() >= obj._advertised_window+STREAM_MAX_SEGMENT_SIZE {
+		out = append(out, obj._make_segment(STREAM_ACK, obj._next_seq, nil))
+	}
+
+	// Der Threadlock wird freigegeben
+	obj._lock.Unlock()
+
+	// Das Fenster wird übermittelt und die Daten werden zurückgegeben
+	obj._transmit(out)
+	return data, false, nil
+}
+
+// Schreibt Daten in den Stream, es wird gewartet bis die Daten im Sendepuffer Platz haben
+func (obj *rouex_stream) _write(data []byte) (uint64, error) {
+	// Der Threadlock wird verwendet
+	obj._lock.Lock()
+
+	// Die Daten werden Stückweise in den Sendepuffer übernommen
+	total := uint64(0)
+	for len(data) > 0 {
+		// Es wird gewartet bis im Sendepuffer Platz ist
+		for obj._state == STREAM_ESTABLISHED && !obj._write_closed && len(obj._send_buffer) >= STREAM_SEND_BUFFER {
+			obj._cond.Wait()
+		}
+
+		// Es wird geprüft ob der Stream noch beschrieben werden kann
+		if obj._write_closed {
+			obj._lock.Unlock()
+			return total, fmt.Errorf("stream closed")
+		}
+		if obj._state != STREAM_ESTABLISHED {
+			err := obj._err
+			obj._lock.Unlock()
+			return total, err
+		}
+
+		// Die Daten werden in den Puffer geschrieben
+		size := STREAM_SEND_BUFFER - len(obj._send_buffer)
+		if size > len(data) {
+			size = len(data)
+		}
+		obj._send_buffer = append(obj._send_buffer, data[:size]...)
+		data = data[size:]
+		total += uint64(size)
+
+		// Die Daten werden übertragen
+		out := obj._fill_window()
+		obj._lock.Unlock()
+		obj._transmit(out)
+		obj._kick()
+		obj._lock.Lock()
+	}
+
+	// Der Threadlock wird freigegeben
+	obj._lock.Unlock()
+
+	// Die Anzahl der geschriebenen Bytes wird zurückgegeben
+	return total, nil
+}
+
+// Beendet die Sendeseite des Streams, die verbleibenden Daten werden noch übertragen, es kann weiterhin gelesen werden
+func (obj *rouex_stream) _close_write() {
+	obj._lock.Lock()
+	obj._write_closed = true
+	out := obj._fill_window()
+	obj._cond.Broadcast()
+	obj._lock.Unlock()
+	obj._transmit(out)
+	obj._kick()
+}
+
+// Schließt den Stream, die verbleibenden Daten werden noch übertragen
+func (obj *rouex_stream) _close() {
+	// Der Stream wird als geschlossen markiert
+	obj._lock.Lock()
+	obj._api_closed = true
+	obj._write_closed = true
+	obj._recv_buffer = nil
+	is_closed := obj._state == STREAM_CLOSED
+	out := obj._fill_window()
+	obj._cond.Broadcast()
+	obj._lock.Unlock()
+
+	// Die Daten werden übermittelt, sollte der Stream bereits beendet sein, wird er entfernt
+	obj._transmit(out)
+	obj._kick()
+	if is_closed {
+		obj._protocol._release_stream(obj)
+	}
+}
+
+// Stellt einen Lauschenden Port dar
+type stream_listener struct {
+	_id         string
+	_port       uint16
+	_protocol   *ROUEX_STREAM_PROTOCOL
+	_api_conn   *kernel.APIProcessConnectionWrapper
+	_backlog    chan *rouex_stream
+	_closed     chan struct{}
+	_close_once *sync.Once
+}
+
+// Gibt die ID des Listeners zurück
+func (obj *stream_listener) GetId() string {
+	return obj._id
+}
+
+// Schließt den Listener, alle noch nicht angenommenen Streams werden zurückgesetzt
+func (obj *stream_listener) Close() {
+	obj._close_once.Do(func() {
+		// Der Listener wird entfernt
+		close(obj._closed)
+		obj._protocol._remove_listener(obj)
+
+		// Die Wartenden Streams werden zurückgesetzt
+		for {
+			select {
+			case stream := <-obj._backlog:
+				stream.Close()
+			default:
+				return
+			}
+		}
+	})
+}
+
+// Stellt einen Stream für andere Protokolle des Kernels dar
+type StreamConn struct {
+	_stream *rouex_stream
+}
+
+// Ließt Daten aus dem Stream, am Ende des Streams wird io.EOF zurückgegeben
+func (obj *StreamConn) Read(b []byte) (int, error) {
+	if len(b) == 0 {
+		return 0, nil
+	}
+	data, eof, err := obj._stream._read(uint64(len(b)))
+	if err != nil {
+		return 0, err
+	}
+	if eof {
+		return 0, io.EOF
+	}
+	return copy(b, data), nil
+}
+
+// Schreibt Daten in den Stream
+func (obj *StreamConn) Write(b []byte) (int, error) {
+	written, err := obj._stream._write(b)
+	return int(written), err
+}
+
+// Beendet die Sendeseite des Streams
+func (obj *StreamConn) CloseWrite() error {
+	obj._stream._close_write()
+	return nil
+}
+
+// Schließt den Stream
+func (obj *StreamConn) Close() error {
+	obj._stream._close()
+	return nil
+}
+
+// Gibt den Öffentlichen Schlüssel der Gegenseite zurück
+func (obj *StreamConn) RemotePublicKey() *btcec.PublicKey {
+	return obj._stream._remote
+}
+
+// Gibt den Port des Streams zurück
+func (obj *StreamConn) Port() uint16 {
+	return obj._stream._port
+}
+
+// Stellt einen Lauschenden Port für andere Protokolle des Kernels dar
+type StreamListener struct {
+	_listener *stream_listener
+}
+
+// Wartet auf einen eingehenden Stream
+func (obj *StreamListener) Accept() (*StreamConn, error) {
+	stream, err := obj._listener._protocol._accept_stream(obj._listener)
+	if err != nil {
+		return nil, fmt.Errorf("Accept: " + err.Error())
+	}
+	return &StreamConn{_stream: stream}, nil
+}
+
+// Schließt den Listener
+func (obj *StreamListener) Close() {
+	obj._listener.Close()
+}
+
+// Stellt das Stream Protokoll dar
+type ROUEX_STREAM_PROTOCOL struct {
+	_objid            string
+	_kernel           *kernel.Kernel
+	_lock             *sync.Mutex
+	_streams          map[string]*rouex_stream
+	_handles          map[string]*rouex_stream
+	_listeners        map[uint16]*stream_listener
+	_listener_handles map[string]*stream_listener
+}
+
+// Gibt den kleineren der beiden Werte zurück
+func _min_duration(a, b time.Duration) time.Duration {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// Erzeugt den Schlüssel unter welchem ein Stream gespeichert wird
+func _stream_key(remote *btcec.PublicKey, wire_id uint64, local_initiator bool) string {
+	return fmt.Sprintf("%s:%d:%t", hex.EncodeToString(remote.SerializeCompressed()), wire_id, local_initiator)
+}
+
+// Sendet ein Segment an ein Relay
+func (obj *ROUEX_STREAM_PROTOCOL) _send_segment(dest *btcec.PublicKey, seg *StreamSegment) error {
+	// Das Segment wird in Bytes umgewandelt
+	encoded, err := cbor.Marshal(seg, cbor.EncOptions{})
+	if err != nil {
+		return fmt.Errorf("_send_segment: 1: " + err.Error())
+	}
+
+	// Das Segment wird übermittelt, verlorene Segmente werden durch den Stream wiederholt
+	if _, err := obj._kernel.EnterBytesEncryptAndSendL2PackageToNetwork(STREAM_PROTOCOL_TYPE, encoded, dest); err != nil {
+		return fmt.Errorf("_send_segment: 2: " + err.Error())
+	}
+
+	// Der Vorgang wurde ohne Fehler durchgeführt
+	return nil
+}
+
+// Erstellt einen neuen Stream
+func (obj *ROUEX_STREAM_PROTOCOL) _new_stream(remote *btcec.PublicKey, wire_id uint64, port uint16, initiator bool) *rouex_stream {
+	lock := new(sync.Mutex)
+	state := STREAM_ESTABLISHED
+	if initiator {
+		state = STREAM_CONNECTING
+	}
+	return &rouex_stream{
+		_id:            utils.RandStringRunes(16),
+		_wire_id:       wire_id,
+		_initiator:     initiator,
+		_remote:        remote,
+		_port:          port,
+		_protocol:      obj,
+		_lock:          lock,
+		_cond:          sync.NewCond(lock),
+		_state:         state,
+		_rto:           time.Second,
+		_timer_start:   time.Now(),
+		_last_activity: time.Now(),
+		_wake:          make(chan struct{}, 1),
+		_out_of_order:  make(map[uint64][]byte),
+	}
+}
+
+// Entfernt einen Stream, der API Eintrag bleibt bestehen bis der Stream vom Prozess geschlossen wurde
+func (obj *ROUEX_STREAM_PROTOCOL) _release_stream(stream *rouex_stream) {
+	// Die Daten des Streams werden abgerufen
+	stream._lock.Lock()
+	remove_handle := stream._api_closed
+	killed := stream._killed
+	api_conn := stream._api_conn
+	stream._lock.Unlock()
+
+	// Der Stream wird entfernt
+	obj._lock.Lock()
+	key := _stream_key(stream._remote, stream._wire_id, stream._initiator)
+	_, has_stream := obj._streams[key]
+	if obj._streams[key] == stream {
+		delete(obj._streams, key)
+	}
+	_, has_handle := obj._handles[stream._id]
+	if remove_handle && has_handle {
+		delete(obj._handles, stream._id)
+	}
+	obj._lock.Unlock()
+
+	// Sollte der Stream nicht durch das Beenden des Prozesses geschlossen worden sein, wird er aus der Verbindung entfernt
+	if remove_handle && has_handle && !killed && api_conn != nil {
+		api_conn.RemoveProcessInvigoratingService(stream)
+	}
+
+	// Log
+	if has_stream {
+		obj._kernel.Logger(logging.PROTOCOL).Info("ROUEX_STREAM_PROTOCOL: stream closed", "sid", stream._id, "port", stream._port)
+	}
+}
+
+// Entfernt einen Listener
+func (obj *ROUEX_STREAM_PROTOCOL) _remove_listener(listener *stream_listener) {
+	obj._lock.Lock()
+	if obj._listeners[listener._port] == listener {
+		delete(obj._listeners, listener._port)
+	}
+	delete(obj._listener_handles, listener._id)
+	obj._lock.Unlock()
+	obj._kernel.Logger(logging.PROTOCOL).Info("ROUEX_STREAM_PROTOCOL: listener closed", "lid", listener._id, "port", listener._port)
+}
+
+// Gibt den Stream eines API Prozesses zurück
+func (obj *ROUEX_STREAM_PROTOCOL) _get_handle(id string, process_api_conn *kernel.APIProcessConnectionWrapper) (*rouex_stream, error) {
+	obj._lock.Lock()
+	defer obj._lock.Unlock()
+	stream, found := obj._handles[id]
+	if !found || stream._api_conn != process_api_conn {
+		return nil, fmt.Errorf("unkown stream")
+	}
+	return stream, nil
+}
+
+// Öffnet einen Lauschenden Port
+func (obj *ROUEX_STREAM_PROTOCOL) _open_listener(port uint16, process_api_conn *kernel.APIProcessConnectionWrapper) (*stream_listener, error) {
+	// Der Listener wird erstellt
+	listener := &stream_listener{
+		_id:         utils.RandStringRunes(16),
+		_port:       port,
+		_protocol:   obj,
+		_api_conn:   process_api_conn,
+		_backlog:    make(chan *rouex_stream, STREAM_ACCEPT_BACKLOG),
+		_closed:     make(chan struct{}),
+		_close_once: new(sync.Once),
+	}
+
+	// Es wird geprüft ob der Port bereits verwendet wird
+	obj._lock.Lock()
+	if _, found := obj._listeners[port]; found {
+		obj._lock.Unlock()
+		return nil, fmt.Errorf("port always in use")
+	}
+	obj._listeners[port] = listener
+	obj._listener_handles[listener._id] = listener
+	obj._lock.Unlock()
+
+	// Der Listener wird geschlossen sobald der Prozess getrennt wurde
+	if process_api_conn != nil {
+		process_api_conn.AddProcessInvigoratingService(listener)
+	}
+
+	// Log
+	obj._kernel.Logger(logging.PROTOCOL).Info("ROUEX_STREAM_PROTOCOL: listening on port", "lid", listener._id, "port", port)
+
+	// Der Listener wird zurückgegeben
+	return listener, nil
+}
+
+// Öffnet einen Lauschenden Port für einen API Prozess
+func (obj *ROUEX_STREAM_PROTOCOL) _listen(port uint16, process_api_conn *kernel.APIProcessConnectionWrapper) (map[string]interface{}, error) {
+	listener, err := obj._open_listener(port, process_api_conn)
+	if err != nil {
+		return nil, err
+	}
+	reval := make(map[string]interface{})
+	reval["listener"] = listener._id
+	return reval, nil
+}
+
+// Wartet auf einen eingehenden Stream eines Listeners
+func (obj *ROUEX_STREAM_PROTOCOL) _accept_stream(listener *stream_listener) (*rouex_stream, error) {
+	select {
+	case stream := <-listener._backlog:
+		return stream, nil
+	case <-listener._closed:
+		return nil, fmt.Errorf("listener closed")
+	}
+}
+
+// Wartet auf einen eingehenden Stream für einen API Prozess
+func (obj *ROUEX_STREAM_PROTOCOL) _accept(listener_id string, process_api_conn *kernel.APIProcessConnectionWrapper) (map[string]interface{}, error) {
+	// Der Listener wird abgerufen
+	obj._lock.Lock()
+	listener, found := obj._listener_handles[listener_id]
+	obj._lock.Unlock()
+	if !found || listener._api_conn != process_api_conn {
+		return nil, fmt.Errorf("unkown listener")
+	}
+
+	// Es wird auf einen neuen Stream gewartet
+	stream, err := obj._accept_stream(listener)
+	if err != nil {
+		return nil, err
+	}
+
+	// Der Stream wird dem Prozess zugeordnet
+	stream._lock.Lock()
+	stream._api_conn = process_api_conn
+	stream._lock.Unlock()
+	obj._lock.Lock()
+	obj._handles[stream._id] = stream
+	obj._lock.Unlock()
+	if process_api_conn != nil {
+		process_api_conn.AddProcessInvigoratingService(stream)
+	}
+
+	// Die Daten des Streams werden zurückgegeben
+	reval := make(map[string]interface{})
+	reval["stream"] = stream._id
+	reval["address"] = hex.EncodeToString(stream._remote.SerializeCompressed())
+	reval["port"] = uint64(stream._port)
+	return reval, nil
+}
+
+// Baut einen neuen Stream zu einem Relay auf, Streams ohne API Prozess erhalten keinen API Eintrag
+func (obj *ROUEX_STREAM_PROTOCOL) _open_stream(dest *btcec.PublicKey, port uint16, process_api_conn *kernel.APIProcessConnectionWrapper) (*rouex_stream, error) {
+	// Die ID des Streams wird zufällig erzeugt
+	id_bytes := make([]byte, 8)
+	if _, err := rand.Read(id_bytes); err != nil {
+		return nil, fmt.Errorf("_open_stream: 1: " + err.Error())
+	}
+
+	// Der Stream wird erstellt und registriert
+	stream := obj._new_stream(dest, binary.BigEndian.Uint64(id_bytes), port, true)
+	stream._api_conn = process_api_conn
+	obj._lock.Lock()
+	obj._streams[_stream_key(dest, stream._wire_id, true)] = stream
+	if process_api_conn != nil {
+		obj._handles[stream._id] = stream
+	}
+	obj._lock.Unlock()
+	if process_api_conn != nil {
+		process_api_conn.AddProcessInvigoratingService(stream)
+	}
+
+	// Das SYN Segment wird übermittelt
+	stream._lock.Lock()
+	syn := stream._make_segment(STREAM_SYN, 0, nil)
+	stream._lock.Unlock()
+	stream._transmit([]*StreamSegment{syn})
+	go stream._run()
+
+	// Es wird gewartet bis die Verbindung aufgebaut wurde
+	if err := stream._wait_established(); err != nil {
+		stream._close()
+		return nil, fmt.Errorf("_open_stream: 2: " + err.Error())
+	}
+
+	// Log
+	obj._kernel.Logger(logging.PROTOCOL).Info("ROUEX_STREAM_PROTOCOL: stream established", "sid", stream._id, "dest", hex.EncodeToString(dest.SerializeCompressed()), "port", port)
+
+	// Der Stream wird zurückgegeben
+	return stream, nil
+}
+
+// Baut einen neuen Stream für einen API Prozess auf
+func (obj *ROUEX_STREAM_PROTOCOL) _connect(dest *btcec.PublicKey, port uint16, process_api_conn *kernel.APIProcessConnectionWrapper) (map[string]interface{}, error) {
+	stream, err := obj._open_stream(dest, port, process_api_conn)
+	if err != nil {
+		return nil, fmt.Errorf("_connect: " + err.Error())
+	}
+	reval := make(map[string]interface{})
+	reval["stream"] = stream._id
+	return reval, nil
+}
+
+// Öffnet einen Lauschenden Port für andere Protokolle des Kernels
+func (obj *ROUEX_STREAM_PROTOCOL) ListenStream(port uint16) (*StreamListener, error) {
+	listener, err := obj._open_listener(port, nil)
+	if err != nil {
+		return nil, fmt.Errorf("ListenStream: " + err.Error())
+	}
+	return &StreamListener{_listener: listener}, nil
+}
+
+// Baut einen Stream für andere Protokolle des Kernels auf
+func (obj *ROUEX_STREAM_PROTOCOL) OpenStream(dest *btcec.PublicKey, port uint16) (*StreamConn, error) {
+	stream, err := obj._open_stream(dest, port, nil)
+	if err != nil {
+		return nil, fmt.Errorf("OpenStream: " + err.Error())
+	}
+	return &StreamConn{_stream: stream}, nil
+}
+
+// Nimmt ein eingehendes SYN Segment entgegen
+func (obj *ROUEX_STREAM_PROTOCOL) _enter_syn(seg *StreamSegment, source *btcec.PublicKey) error {
+	// Es wird geprüft ob der Stream bereits vorhanden ist, in diesem Fall wurde die Antwort nicht empfangen
+	key := _stream_key(source, seg.Id, false)
+	obj._lock.Lock()
+	if stream, found := obj._streams[key]; found {
+		obj._lock.Unlock()
+		stream._lock.Lock()
+		syn_ack := stream._make_segment(STREAM_SYN_ACK, 0, nil)
+		stream._lock.Unlock()
+		stream._transmit([]*StreamSegment{syn_ack})
+		return nil
+	}
+
+	// Es wird geprüft ob auf dem Port gelauscht wird
+	listener, found := obj._listeners[seg.Port]
+	if !found {
+		obj._lock.Unlock()
+		return obj._send_segment(source, &StreamSegment{Type: STREAM_RST, Id: seg.Id, Initiator: false, Port: seg.Port})
+	}
+
+	// Der Stream wird erstellt und registriert
+	stream := obj._new_stream(source, seg.Id, seg.Port, false)
+	stream._peer_window = seg.Window
+	stream._api_conn = listener._api_conn
+	obj._streams[key] = stream
+	obj._lock.Unlock()
+
+	// Der Stream wird an den Listener übergeben, sollte dieser ausgelastet sein, wird der Stream abgelehnt
+	select {
+	case listener._backlog <- stream:
+	default:
+		obj._kernel.Logger(logging.PROTOCOL).Warn("ROUEX_STREAM_PROTOCOL: accept backlog full, stream rejected", "port", seg.Port)
+		stream.Close()
+		return nil
+	}
+
+	// Der Verbindungsaufbau wird bestätigt
+	stream._lock.Lock()
+	syn_ack := stream._make_segment(STREAM_SYN_ACK, 0, nil)
+	stream._lock.Unlock()
+	stream._transmit([]*StreamSegment{syn_ack})
+	go stream._run()
+
+	// Der Vorgang wurde ohne Fehler durchgeführt
+	return nil
+}
+
+// Nimmt eingetroffene Pakete aus dem Netzwerk Entgegen
+func (obj *ROUEX_STREAM_PROTOCOL) EnterRecivedPackage(pckage *addresspackages.AddressLayerPackage) error {
+	// Es wird versucht das Segment einzulesen
+	var seg StreamSegment
+	if err := cbor.Unmarshal(pckage.Data, &seg); err != nil {
+		return fmt.Errorf("error: invalid_package: " + err.Error())
+	}
+
+	// Ein SYN Segment eröffnet einen neuen Stream
+	if seg.Type == STREAM_SYN {
+		return obj._enter_syn(&seg, &pckage.Sender)
+	}
+
+	// Der Stream wird ermittelt, die Rolle der lokalen Seite ist das Gegenteil der Rolle des Absenders
+	obj._lock.Lock()
+	stream, found := obj._streams[_stream_key(&pckage.Sender, seg.Id, !seg.Initiator)]
+	obj._lock.Unlock()
+
+	// Sollte der Stream nicht bekannt sein, wird die Gegenseite zurückgesetzt
+	if !found {
+		if seg.Type == STREAM_RST {
+			return nil
+		}
+		return obj._send_segment(&pckage.Sender, &StreamSegment{Type: STREAM_RST, Id: seg.Id, Initiator: !seg.Initiator, Port: seg.Port})
+	}
+
+	// Das Segment wird an den Stream übergeben
+	stream._enter_segment(&seg)
+	return nil
+}
+
+// Nimmt eintreffende Steuer Befehele entgegen
+func (obj *ROUEX_STREAM_PROTOCOL) EnterCommandData(command string, arguments [][]byte, process_api_conn *kernel.APIProcessConnectionWrapper) (map[string]interface{}, error) {
+	switch command {
+	case "listen":
+		// Der Port wird eingelesen
+		if len(arguments) < 1 || len(arguments[0]) != 2 {
+			return nil, fmt.Errorf("invalid listen command, port required")
+		}
+		return obj._listen(binary.BigEndian.Uint16(arguments[0]), process_api_conn)
+	case "accept":
+		// Die ID des Listeners wird eingelesen
+		if len(arguments) < 1 {
+			return nil, fmt.Errorf("invalid accept command, listener required")
+		}
+		return obj._accept(string(arguments[0]), process_api_conn)
+	case "connect":
+		// Die Adresse und der Port werden eingelesen
+		if len(arguments) < 2 || len(arguments[1]) != 2 {
+			return nil, fmt.Errorf("invalid connect command, address and port required")
+		}
+		pkey, err := btcec.ParsePubKey(arguments[0])
+		if err != nil {
+			return nil, fmt.Errorf("invalid public key")
+		}
+		return obj._connect(pkey, binary.BigEndian.Uint16(arguments[1]), process_api_conn)
+	case "read":
+		// Der Stream und die maximale Größe werden eingelesen
+		if len(arguments) < 2 || len(arguments[1]) != 4 {
+			return nil, fmt.Errorf("invalid read command, stream and size required")
+		}
+		stream, err := obj._get_handle(string(arguments[0]), process_api_conn)
+		if err != nil {
+			return nil, err
+		}
+		max_size := uint64(binary.BigEndian.Uint32(arguments[1]))
+		if max_size == 0 || max_size > STREAM_RECEIVE_WINDOW {
+			max_size = STREAM_RECEIVE_WINDOW
+		}
+		data, eof, err := stream._read(max_size)
+		if err != nil {
+			return nil, err
+		}
+		reval := make(map[string]interface{})
+		reval["data"] = data
+		reval["eof"] = eof
+		return reval, nil
+	case "write":
+		// Der Stream und die Daten werden eingelesen
+		if len(arguments) < 2 {
+			return nil, fmt.Errorf("invalid write command, stream and data required")
+		}
+		stream, err := obj._get_handle(string(arguments[0]), process_api_conn)
+		if err != nil {
+			return nil, err
+		}
+		written, err := stream._write(arguments[1])
+		if err != nil {
+			return nil, err
+		}
+		reval := make(map[string]interface{})
+		reval["written"] = written
+		return reval, nil
+	case "close_write":
+		// Der Stream wird eingelesen
+		if len(arguments) < 1 {
+			return nil, fmt.Errorf("invalid close_write command, stream required")
+		}
+		stream, err := obj._get_handle(string(arguments[0]), process_api_conn)
+		if err != nil {
+			return nil, err
+		}
+		stream._close_write()
+		reval := make(map[string]interface{})
+		reval["closed"] = true
+		return reval, nil
+	case "close":
+		// Es wird geprüft ob es sich um einen Stream oder einen Listener handelt
+		if len(arguments) < 1 {
+			return nil, fmt.Errorf("invalid close command, stream or listener required")
+		}
+		id := string(arguments[0])
+		obj._lock.Lock()
+		listener, is_listener := obj._listener_handles[id]
+		obj._lock.Unlock()
+		if is_listener && listener._api_conn == process_api_conn {
+			listener.Close()
+			if process_api_conn != nil {
+				process_api_conn.RemoveProcessInvigoratingService(listener)
+			}
+		} else {
+			stream, err := obj._get_handle(id, process_api_conn)
+			if err != nil {
+				return nil, err
+			}
+			stream._close()
+		}
+		reval := make(map[string]interface{})
+		reval["closed"] = true
+		return reval, nil
+	default:
+		return nil, fmt.Errorf("invalid command")
+	}
+}
+
+// Registriert den Kernel im Protokoll
+func (obj *ROUEX_STREAM_PROTOCOL) RegisterKernel(kernel *kernel.Kernel) error {
+	obj._lock.Lock()
+	if obj._kernel != nil {
+		obj._lock.Unlock()
+		return fmt.Errorf("kernel always registered")
+	}
+	obj._kernel = kernel
+	obj._lock.Unlock()
+	obj._kernel.Logger(logging.PROTOCOL).Info("ROUEX_STREAM_PROTOCOL: kernel registrated", "id", kernel.GetKernelID(), "object_id", obj._objid)
+	return nil
+}
+
+// Gibt den Namen des Protokolles zurück
+func (obj *ROUEX_STREAM_PROTOCOL) GetProtocolName() string {
+	return "ROUEX_STREAM_PROTOCOL"
+}
+
+// Gibt die ObjektID des Protokolls zurück
+func (obj *ROUEX_STREAM_PROTOCOL) GetObjectId() string {
+	return obj._objid
+}
+
+// Erzeugt ein neues Stream Protokoll
+func NEW_ROUEX_STREAM_PROTOCOL_HANDLER() *ROUEX_STREAM_PROTOCOL {
+	return &ROUEX_STREAM_PROTOCOL{
+		_lock:             &sync.Mutex{},
+		_objid:            utils.RandStringRunes(12),
+		_streams:          make(map[string]*rouex_stream),
+		_handles:          make(map[string]*rouex_stream),
+		_listeners:        make(map[uint16]*stream_listener),
+		_listener_handles: make(map[string]*stream_listener),
+	}
+}
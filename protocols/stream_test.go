@@ -0,0 +1,271 @@
+package protocols
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+// Erstellt einen aufgebauten Stream, die Segmente werden nicht übertragen
+func newTestStream(t *testing.T) *rouex_stream {
+	t.Helper()
+	stream := NEW_ROUEX_STREAM_PROTOCOL_HANDLER()._new_stream(newTestKey(t), 1, 80, false)
+	stream._peer_window = STREAM_RECEIVE_WINDOW
+	return stream
+}
+
+// Erzeugt ein Daten Segment der Gegenseite
+func newDataSegment(seq uint64, data string) *StreamSegment {
+	return &StreamSegment{Type: STREAM_DATA, Id: 1, Initiator: true, Port: 80, Seq: seq, Window: STREAM_RECEIVE_WINDOW, Data: []byte(data)}
+}
+
+func TestStreamReassembly(t *testing.T) {
+	stream := newTestStream(t)
+
+	// Die Segmente treffen in falscher Reihenfolge ein und werden zwischengespeichert
+	ack := stream._enter_data(newDataSegment(5, "world"))
+	if ack.Ack != 0 || len(stream._recv_buffer) != 0 || stream._out_of_order_size != 5 {
+		t.Fatalf("out of order segment: ack = %d, buffered = %d", ack.Ack, len(stream._recv_buffer))
+	}
+	if ack.Window != STREAM_RECEIVE_WINDOW-5 {
+		t.Errorf("window = %d, want %d", ack.Window, STREAM_RECEIVE_WINDOW-5)
+	}
+
+	// Ein doppeltes Segment wird nur einmal gespeichert
+	stream._enter_data(newDataSegment(5, "world"))
+	if stream._out_of_order_size != 5 {
+		t.Errorf("duplicate segment stored, out of order size = %d", stream._out_of_order_size)
+	}
+
+	// Sobald die Lücke geschlossen ist, werden die Segmente zusammengeführt
+	ack = stream._enter_data(newDataSegment(0, "hello"))
+	if ack.Type != STREAM_ACK || ack.Ack != 10 {
+		t.Fatalf("ack = %d, want 10", ack.Ack)
+	}
+	if string(stream._recv_buffer) != "helloworld" || len(stream._out_of_order) != 0 || stream._out_of_order_size != 0 {
+		t.Errorf("recv buffer = %q, out of order = %d", stream._recv_buffer, len(stream._out_of_order))
+	}
+
+	// Bereits empfangene Daten werden nicht erneut übernommen
+	ack = stream._enter_data(newDataSegment(0, "hello"))
+	if ack.Ack != 10 || string(stream._recv_buffer) != "helloworld" {
+		t.Errorf("retransmitted segment changed stream: ack = %d, buffer = %q", ack.Ack, stream._recv_buffer)
+	}
+
+	// Segmente außerhalb des Empfangsfensters werden verworfen
+	stream._enter_data(newDataSegment(10+STREAM_RECEIVE_WINDOW, "x"))
+	if len(stream._out_of_order) != 0 {
+		t.Error("segment outside of receive window stored")
+	}
+}
+
+func TestStreamRemoteFin(t *testing.T) {
+	stream := newTestStream(t)
+
+	// Das FIN Segment trifft vor den letzten Daten ein, der Stream ist erst danach beendet
+	ack := stream._enter_data(&StreamSegment{Type: STREAM_FIN, Seq: 3})
+	if stream._remote_fin || ack.Ack != 0 {
+		t.Fatal("stream finished before all data was received")
+	}
+	ack = stream._enter_data(newDataSegment(0, "end"))
+	if !stream._remote_fin {
+		t.Fatal("stream not finished after all data was received")
+	}
+
+	// Das FIN Segment belegt ein Byte im Sequenzraum
+	if ack.Ack != 4 {
+		t.Errorf("ack = %d, want 4", ack.Ack)
+	}
+
+	// Die Daten können noch gelesen werden, danach wird das Ende gemeldet
+	data, eof, err := stream._read(16)
+	if err != nil || eof || string(data) != "end" {
+		t.Fatalf("_read() = %q, %v, %v", data, eof, err)
+	}
+	if _, eof, err = stream._read(16); err != nil || !eof {
+		t.Errorf("_read() at end = %v, %v, want eof", eof, err)
+	}
+}
+
+func TestStreamFillWindow(t *testing.T) {
+	stream := newTestStream(t)
+	stream._peer_window = STREAM_MAX_SEGMENT_SIZE + 100
+	stream._send_buffer = make([]byte, 3*STREAM_MAX_SEGMENT_SIZE)
+
+	// Es werden nur so viele Daten gesendet, wie das Fenster der Gegenseite zulässt
+	out := stream._fill_window()
+	if len(out) != 2 || len(out[0].Data) != STREAM_MAX_SEGMENT_SIZE || len(out[1].Data) != 100 {
+		t.Fatalf("_fill_window() = %d segments", len(out))
+	}
+	if out[1].Seq != STREAM_MAX_SEGMENT_SIZE || stream._next_seq != STREAM_MAX_SEGMENT_SIZE+100 {
+		t.Errorf("seq = %d, next seq = %d", out[1].Seq, stream._next_seq)
+	}
+	if len(stream._unacked) != 2 || len(stream._send_buffer) != 2*STREAM_MAX_SEGMENT_SIZE-100 {
+		t.Errorf("unacked = %d, send buffer = %d", len(stream._unacked), len(stream._send_buffer))
+	}
+	if out := stream._fill_window(); len(out) != 0 {
+		t.Errorf("%d segments sent with full window", len(out))
+	}
+
+	// Ein Stream im Verbindungsaufbau sendet keine Daten
+	connecting := newTestStream(t)
+	connecting._state = STREAM_CONNECTING
+	connecting._send_buffer = []byte("data")
+	if out := connecting._fill_window(); len(out) != 0 {
+		t.Error("data sent before stream was established")
+	}
+}
+
+func TestStreamAck(t *testing.T) {
+	stream := newTestStream(t)
+	stream._send_buffer = []byte("hello world")
+	stream._write_closed = true
+	out := stream._fill_window()
+
+	// Nach den Daten wird das FIN Segment gesendet, es belegt ein Byte
+	if len(out) != 2 || out[1].Type != STREAM_FIN || stream._next_seq != 12 {
+		t.Fatalf("_fill_window() = %d segments, next seq = %d", len(out), stream._next_seq)
+	}
+
+	// Bestätigungen außerhalb des gesendeten Bereiches werden ignoriert
+	stream._retries = 3
+	stream._enter_ack(&StreamSegment{Ack: 13, Window: 1})
+	if stream._acked != 0 || stream._peer_window != STREAM_RECEIVE_WINDOW || stream._retries != 3 {
+		t.Error("ack beyond next seq accepted")
+	}
+
+	// Die Daten werden bestätigt, das FIN Segment bleibt unbestätigt
+	stream._enter_ack(&StreamSegment{Ack: 11, Window: 100})
+	if stream._acked != 11 || len(stream._unacked) != 1 || !stream._unacked[0].fin {
+		t.Fatalf("acked = %d, unacked = %d", stream._acked, len(stream._unacked))
+	}
+	if stream._peer_window != 100 || stream._retries != 0 || stream._srtt == 0 {
+		t.Errorf("peer window = %d, retries = %d, srtt = %v", stream._peer_window, stream._retries, stream._srtt)
+	}
+
+	// Eine ältere Bestätigung wird ignoriert
+	stream._enter_ack(&StreamSegment{Ack: 5, Window: 1})
+	if stream._acked != 11 || stream._peer_window != 100 {
+		t.Error("old ack accepted")
+	}
+
+	// Das FIN Segment wird bestätigt
+	stream._enter_ack(&StreamSegment{Ack: 12, Window: 100})
+	if stream._acked != stream._next_seq || len(stream._unacked) != 0 {
+		t.Errorf("acked = %d, unacked = %d", stream._acked, len(stream._unacked))
+	}
+}
+
+func TestStreamEstablish(t *testing.T) {
+	stream := newTestStream(t)
+	stream._state = STREAM_CONNECTING
+
+	// Die Antwort der Gegenseite schließt den Verbindungsaufbau ab
+	stream._enter_segment(&StreamSegment{Type: STREAM_SYN_ACK, Window: 1024})
+	if stream._state != STREAM_ESTABLISHED || stream._peer_window != 1024 {
+		t.Fatalf("state = %d, peer window = %d", stream._state, stream._peer_window)
+	}
+	if err := stream._wait_established(); err != nil {
+		t.Error(err)
+	}
+
+	// Ein RST Segment beendet den Stream
+	stream._enter_segment(&StreamSegment{Type: STREAM_RST})
+	if stream._state != STREAM_CLOSED || stream._err == nil {
+		t.Errorf("state = %d, err = %v", stream._state, stream._err)
+	}
+	if _, err := stream._write([]byte("data")); err == nil {
+		t.Error("write on reset stream accepted")
+	}
+}
+
+func TestStreamRetransmission(t *testing.T) {
+	stream := newTestStream(t)
+	stream._send_buffer = []byte("hello")
+	stream._fill_window()
+
+	// Vor Ablauf des Timers wird nichts wiederholt
+	if out, done, _ := stream._tick(); len(out) != 0 || done {
+		t.Fatalf("_tick() = %d segments, done = %v", len(out), done)
+	}
+
+	// Nach Ablauf des Timers wird das älteste Segment wiederholt und das Intervall verdoppelt
+	rto := stream._rto
+	stream._timer_start = time.Now().Add(-rto)
+	out, done, wait := stream._tick()
+	if done || len(out) != 1 || out[0].Type != STREAM_DATA || out[0].Seq != 0 || !bytes.Equal(out[0].Data, []byte("hello")) {
+		t.Fatalf("_tick() = %d segments, done = %v", len(out), done)
+	}
+	if stream._rto != 2*rto || stream._retries != 1 || !stream._unacked[0].retransmitted || wait <= 0 {
+		t.Errorf("rto = %v, retries = %d, wait = %v", stream._rto, stream._retries, wait)
+	}
+
+	// Die Bestätigung eines wiederholten Segmentes wird nicht für die Umlaufzeit verwendet
+	stream._enter_ack(&StreamSegment{Ack: 5, Window: STREAM_RECEIVE_WINDOW})
+	if stream._srtt != 0 || stream._retries != 0 {
+		t.Errorf("srtt = %v, retries = %d", stream._srtt, stream._retries)
+	}
+
+	// Nach zu vielen Wiederholungen wird der Stream zurückgesetzt
+	stream._send_buffer = []byte("again")
+	stream._fill_window()
+	stream._retries = STREAM_MAX_RETRIES
+	stream._timer_start = time.Now().Add(-stream._rto)
+	out, done, _ = stream._tick()
+	if !done || len(out) != 1 || out[0].Type != STREAM_RST || stream._state != STREAM_CLOSED {
+		t.Errorf("_tick() = %d segments, done = %v, state = %d", len(out), done, stream._state)
+	}
+}
+
+func TestStreamConnectTimeout(t *testing.T) {
+	stream := newTestStream(t)
+	stream._state = STREAM_CONNECTING
+
+	// Das SYN Segment wird wiederholt
+	stream._timer_start = time.Now().Add(-stream._rto)
+	out, done, _ := stream._tick()
+	if done || len(out) != 1 || out[0].Type != STREAM_SYN {
+		t.Fatalf("_tick() = %d segments, done = %v", len(out), done)
+	}
+
+	// Nach zu vielen Wiederholungen schlägt der Verbindungsaufbau fehl
+	stream._retries = STREAM_MAX_RETRIES
+	stream._timer_start = time.Now().Add(-stream._rto)
+	if _, done, _ := stream._tick(); !done {
+		t.Fatal("stream not closed after connect timeout")
+	}
+	if err := stream._wait_established(); err == nil {
+		t.Error("connect timeout not reported")
+	}
+}
+
+func TestStreamLinger(t *testing.T) {
+	stream := newTestStream(t)
+	stream._write_closed = true
+	stream._fill_window()
+	stream._enter_ack(&StreamSegment{Ack: 1, Window: STREAM_RECEIVE_WINDOW})
+	stream._enter_data(&StreamSegment{Type: STREAM_FIN, Seq: 0})
+
+	// Beide Seiten haben den Stream geschlossen, der Stream wird noch eine Zeit lang gehalten
+	if _, done, wait := stream._tick(); done || wait <= 0 || stream._finished_at.IsZero() {
+		t.Fatalf("_tick() done = %v, wait = %v", done, wait)
+	}
+	stream._finished_at = time.Now().Add(-STREAM_LINGER_TIME)
+	if _, done, _ := stream._tick(); !done || stream._state != STREAM_CLOSED {
+		t.Errorf("stream not closed after linger time, state = %d", stream._state)
+	}
+}
+
+func TestStreamHalfCloseTimeout(t *testing.T) {
+	stream := newTestStream(t)
+	stream._write_closed = true
+	stream._fill_window()
+	stream._enter_ack(&StreamSegment{Ack: 1, Window: STREAM_RECEIVE_WINDOW})
+
+	// Die Gegenseite schließt den Stream nicht, nach der Zeitüberschreitung wird er zurückgesetzt
+	stream._last_activity = time.Now().Add(-STREAM_IDLE_TIMEOUT)
+	out, done, _ := stream._tick()
+	if !done || len(out) != 1 || out[0].Type != STREAM_RST {
+		t.Errorf("_tick() = %d segments, done = %v", len(out), done)
+	}
+}
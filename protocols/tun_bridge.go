@@ -0,0 +1,312 @@
+package protocols
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	addresspackages "github.com/fluffelpuff/RoueX/address_packages"
+	"github.com/fluffelpuff/RoueX/kernel"
+	"github.com/fluffelpuff/RoueX/logging"
+	"github.com/fluffelpuff/RoueX/utils"
+)
+
+// Gibt den Protokolltypen des TUN Protokolls an
+const TUN_PROTOCOL_TYPE uint8 = 5
+
+// Gibt die Standardwerte des TUN Gerätes an
+const (
+	TUN_DEFAULT_NAME          = "rouex0"
+	TUN_DEFAULT_MTU           = 1280
+	TUN_PEER_REFRESH_INTERVAL = time.Second
+	TUN_PEER_TTL              = 30 * time.Minute
+	TUN_MAX_PEERS             = 4096
+)
+
+// Gibt die Größe des IPv6 Headers an
+const ipv6_header_size = 40
+
+// Stellt ein TUN Gerät dar
+type tun_device interface {
+	Read([]byte) (int, error)
+	Write([]byte) (int, error)
+	Close() error
+}
+
+// Stellt ein Relay dar, dessen Overlay Adresse bekannt ist
+type tun_peer struct {
+	pkey      *btcec.PublicKey
+	last_seen time.Time
+}
+
+// Stellt das TUN Protokoll dar, IPv6 Pakete des TUN Gerätes werden an das Relay übermittelt,
+// dessen Overlay Adresse der Zieladresse des Paketes entspricht
+type ROUEX_TUN_PROTOCOL struct {
+	_objid        string
+	_kernel       *kernel.Kernel
+	_lock         *sync.Mutex
+	_name         string
+	_mtu          uint32
+	_device       tun_device
+	_local_ip     net.IP
+	_peers        map[[16]byte]*tun_peer
+	_last_refresh time.Time
+}
+
+// Gibt an ob ein Relay innerhalb der Gültigkeitsdauer gesehen wurde, die eigene Adresse läuft nicht ab
+func (obj *ROUEX_TUN_PROTOCOL) _is_peer_alive(key [16]byte, peer *tun_peer, now time.Time) bool {
+	return now.Sub(peer.last_seen) <= TUN_PEER_TTL || bytes.Equal(key[:], obj._local_ip)
+}
+
+// Entfernt alle Relays welche länger als die Gültigkeitsdauer nicht mehr gesehen wurden, der Threadlock muss gehalten werden
+func (obj *ROUEX_TUN_PROTOCOL) _expire_peers(now time.Time) {
+	for key, peer := range obj._peers {
+		if !obj._is_peer_alive(key, peer, now) {
+			delete(obj._peers, key)
+		}
+	}
+}
+
+// Entfernt das am längsten nicht mehr gesehene Relay, die eigene Adresse bleibt erhalten, der Threadlock muss gehalten werden
+func (obj *ROUEX_TUN_PROTOCOL) _evict_least_recently_seen() {
+	var oldest_key [16]byte
+	var oldest *tun_peer
+	for key, peer := range obj._peers {
+		if bytes.Equal(key[:], obj._local_ip) {
+			continue
+		}
+		if oldest == nil || peer.last_seen.Before(oldest.last_seen) {
+			oldest_key, oldest = key, peer
+		}
+	}
+	if oldest != nil {
+		delete(obj._peers, oldest_key)
+	}
+}
+
+// Speichert die Overlay Adresse eines Relays ab, sollte die maximale Anzahl erreicht sein werden zuerst abgelaufene Einträge
+// und anschließend das am längsten nicht mehr gesehene Relay entfernt, der Threadlock muss gehalten werden
+func (obj *ROUEX_TUN_PROTOCOL) _add_peer(pkey *btcec.PublicKey) {
+	var key [16]byte
+	copy(key[:], utils.ConvertPublicKeyToOverlayIP(pkey))
+	now := time.Now()
+	if peer, found := obj._peers[key]; found {
+		peer.pkey, peer.last_seen = pkey, now
+		return
+	}
+	if len(obj._peers) >= TUN_MAX_PEERS {
+		obj._expire_peers(now)
+	}
+	if len(obj._peers) >= TUN_MAX_PEERS {
+		obj._evict_least_recently_seen()
+	}
+	obj._peers[key] = &tun_peer{pkey: pkey, last_seen: now}
+}
+
+// Übernimmt alle verbundenen Relays und alle bekannten Routen, der Threadlock muss gehalten werden
+func (obj *ROUEX_TUN_PROTOCOL) _refresh_peers() {
+	obj._expire_peers(time.Now())
+	for _, relay := range obj._kernel.GetConnectedRelays() {
+		obj._add_peer(relay.GetPublicKey())
+	}
+	for _, route := range obj._kernel.GetRoutingManager().GetBestRoutes() {
+		obj._add_peer(route.GetDestination())
+	}
+	obj._last_refresh = time.Now()
+}
+
+// Ermittelt den Öffentlichen Schlüssel zu einer Overlay Adresse
+func (obj *ROUEX_TUN_PROTOCOL) _lookup_peer(ip []byte) *btcec.PublicKey {
+	// Der Threadlock wird verwendet
+	obj._lock.Lock()
+	defer obj._lock.Unlock()
+
+	// Es wird geprüft ob die Adresse bereits bekannt und nicht abgelaufen ist
+	var key [16]byte
+	copy(key[:], ip)
+	if peer, found := obj._peers[key]; found && obj._is_peer_alive(key, peer, time.Now()) {
+		return peer.pkey
+	}
+
+	// Sollte die Adresse nicht bekannt sein, werden die bekannten Relays neu eingelesen
+	if time.Since(obj._last_refresh) < TUN_PEER_REFRESH_INTERVAL {
+		return nil
+	}
+	obj._refresh_peers()
+	if peer, found := obj._peers[key]; found && obj._is_peer_alive(key, peer, time.Now()) {
+		return peer.pkey
+	}
+	return nil
+}
+
+// Gibt an ob es sich um ein gültiges IPv6 Paket handelt
+func _is_ipv6_packet(packet []byte) bool {
+	return len(packet) >= ipv6_header_size && packet[0]>>4 == 6
+}
+
+// Ließt die Pakete des TUN Gerätes ein und übermittelt sie an die Relays
+func (obj *ROUEX_TUN_PROTOCOL) _read_loop() {
+	buffer := make([]byte, obj._mtu)
+	for {
+		// Das nächste Paket wird eingelesen
+		n, err := obj._device.Read(buffer)
+		if err != nil {
+			if obj._kernel.IsRunning() {
+				obj._kernel.Logger(logging.PROTOCOL).Warn("ROUEX_TUN_PROTOCOL: error by reading from tun device", "error", err.Error())
+			}
+			return
+		}
+
+		// Solange der Kernel nicht ausgeführt wird, werden die Pakete verworfen
+		if !obj._kernel.IsRunning() {
+			continue
+		}
+
+		// Es werden nur IPv6 Pakete in das Overlay übertragen
+		packet := buffer[:n]
+		if !_is_ipv6_packet(packet) {
+			continue
+		}
+
+		// Das Relay wird anhand der Zieladresse ermittelt
+		dest := obj._lookup_peer(packet[24:40])
+		if dest == nil {
+			continue
+		}
+
+		// Das Paket wird übermittelt
+		data := make([]byte, n)
+		copy(data, packet)
+		if _, err := obj._kernel.EnterBytesEncryptAndSendL2PackageToNetwork(TUN_PROTOCOL_TYPE, data, dest); err != nil {
+			obj._kernel.Logger(logging.PROTOCOL).Warn("ROUEX_TUN_PROTOCOL: error by sending packet", "error", err.Error())
+		}
+	}
+}
+
+// Nimmt eingetroffene Pakete aus dem Netzwerk Entgegen und schreibt sie in das TUN Gerät
+func (obj *ROUEX_TUN_PROTOCOL) EnterRecivedPackage(pckage *addresspackages.AddressLayerPackage) error {
+	// Es wird geprüft ob es sich um ein IPv6 Paket handelt
+	packet := pckage.Data
+	if !_is_ipv6_packet(packet) {
+		return fmt.Errorf("error: invalid_package: no ipv6 packet")
+	}
+
+	// Die Absenderadresse muss der Overlay Adresse des Absenders entsprechen, die Zieladresse der eigenen Adresse
+	if !bytes.Equal(packet[8:24], utils.ConvertPublicKeyToOverlayIP(&pckage.Sender)) {
+		return fmt.Errorf("error: invalid_package: source address does not match sender")
+	}
+	if !bytes.Equal(packet[24:40], obj._local_ip) {
+		return fmt.Errorf("error: invalid_package: destination address does not match")
+	}
+
+	// Der Absender wird gespeichert, so können Antworten ohne Route zugestellt werden
+	sender := pckage.Sender
+	obj._lock.Lock()
+	obj._add_peer(&sender)
+	obj._lock.Unlock()
+
+	// Das Paket wird in das TUN Gerät geschrieben
+	if _, err := obj._device.Write(packet); err != nil {
+		return fmt.Errorf("EnterRecivedPackage: " + err.Error())
+	}
+
+	// Der Vorgang wurde ohne Fehler durchgeführt
+	return nil
+}
+
+// Wird aufgerufen wenn ein Relay verbunden wurde
+func (obj *ROUEX_TUN_PROTOCOL) RelayConnected(relay *kernel.Relay) {
+	obj._lock.Lock()
+	obj._add_peer(relay.GetPublicKey())
+	obj._lock.Unlock()
+}
+
+// Wird aufgerufen wenn ein Relay getrennt wurde, die Overlay Adressen bleiben bekannt
+func (obj *ROUEX_TUN_PROTOCOL) RelayDisconnected(relay *kernel.Relay, dests []*btcec.PublicKey) {
+}
+
+// Nimmt eintreffende Steuer Befehele entgegen
+func (obj *ROUEX_TUN_PROTOCOL) EnterCommandData(command string, arguments [][]byte, process_api_conn *kernel.APIProcessConnectionWrapper) (map[string]interface{}, error) {
+	if command == "info" {
+		obj._lock.Lock()
+		defer obj._lock.Unlock()
+		reval := make(map[string]interface{})
+		reval["name"] = obj._name
+		reval["address"] = obj._local_ip.String()
+		reval["mtu"] = uint64(obj._mtu)
+		reval["peers"] = uint64(len(obj._peers))
+		return reval, nil
+	} else {
+		return nil, fmt.Errorf("invalid command")
+	}
+}
+
+// Registriert den Kernel im Protokoll, das TUN Gerät wird erstellt
+func (obj *ROUEX_TUN_PROTOCOL) RegisterKernel(kernel *kernel.Kernel) error {
+	// Es wird geprüft ob bereits ein Kernel registriert wurde
+	obj._lock.Lock()
+	if obj._kernel != nil {
+		obj._lock.Unlock()
+		return fmt.Errorf("kernel always registered")
+	}
+	obj._kernel = kernel
+	obj._local_ip = utils.ConvertPublicKeyToOverlayIP(kernel.GetPublicKey())
+	obj._add_peer(kernel.GetPublicKey())
+	obj._lock.Unlock()
+
+	// Das TUN Gerät wird erstellt
+	device, device_name, err := openTunDevice(obj._name, obj._mtu, obj._local_ip, utils.OVERLAY_IP_PREFIX_LEN)
+	if err != nil {
+		return fmt.Errorf("RegisterKernel: " + err.Error())
+	}
+	obj._lock.Lock()
+	obj._device = device
+	obj._name = device_name
+	obj._lock.Unlock()
+
+	// Das Protokoll wird über neue Relays informiert
+	kernel.RegisterRelayStateObserver(obj)
+
+	// Die Pakete des Gerätes werden in einem eigenen Thread eingelesen
+	go obj._read_loop()
+
+	// Das Gerät wird geschlossen sobald der Kernel beendet wurde, dadurch wird auch der Lesende Thread beendet
+	go func() {
+		<-kernel.Context().Done()
+		device.Close()
+	}()
+
+	// Log
+	obj._kernel.Logger(logging.PROTOCOL).Info("ROUEX_TUN_PROTOCOL: kernel registrated", "id", kernel.GetKernelID(), "object_id", obj._objid, "device", device_name, "address", obj._local_ip.String())
+	return nil
+}
+
+// Gibt den Namen des Protokolles zurück
+func (obj *ROUEX_TUN_PROTOCOL) GetProtocolName() string {
+	return "ROUEX_TUN_PROTOCOL"
+}
+
+// Gibt die ObjektID des Protokolls zurück
+func (obj *ROUEX_TUN_PROTOCOL) GetObjectId() string {
+	return obj._objid
+}
+
+// Erzeugt ein neues TUN Protokoll, das Gerät wird beim Registrieren des Kernels erstellt
+func NEW_ROUEX_TUN_PROTOCOL_HANDLER(name string, mtu uint32) *ROUEX_TUN_PROTOCOL {
+	if len(name) == 0 {
+		name = TUN_DEFAULT_NAME
+	}
+	if mtu < TUN_DEFAULT_MTU {
+		mtu = TUN_DEFAULT_MTU
+	}
+	return &ROUEX_TUN_PROTOCOL{
+		_lock:  &sync.Mutex{},
+		_objid: utils.RandStringRunes(12),
+		_name:  name,
+		_mtu:   mtu,
+		_peers: make(map[[16]byte]*tun_peer),
+	}
+}
@@ -0,0 +1,101 @@
+//go:build linux
+
+package protocols
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// Stellt die Kernel Struktur 'in6_ifreq' dar, welche zum Setzen einer IPv6 Adresse verwendet wird
+type tun_in6_ifreq struct {
+	addr      [16]byte
+	prefixlen uint32
+	ifindex   int32
+}
+
+// Erstellt ein neues TUN Gerät, setzt die MTU und die IPv6 Adresse und aktiviert es
+func openTunDevice(name string, mtu uint32, ip net.IP, prefix_len uint32) (tun_device, string, error) {
+	// Das TUN Gerät wird geöffnet
+	fd, err := unix.Open("/dev/net/tun", unix.O_RDWR|unix.O_CLOEXEC, 0)
+	if err != nil {
+		return nil, "", fmt.Errorf("openTunDevice: 1: " + err.Error())
+	}
+
+	// Das Gerät wird ohne Paketinformationen erstellt, es werden nur reine IP Pakete übertragen
+	ifr, err := unix.NewIfreq(name)
+	if err != nil {
+		unix.Close(fd)
+		return nil, "", fmt.Errorf("openTunDevice: 2: " + err.Error())
+	}
+	ifr.SetUint16(unix.IFF_TUN | unix.IFF_NO_PI)
+	if err := unix.IoctlIfreq(fd, unix.TUNSETIFF, ifr); err != nil {
+		unix.Close(fd)
+		return nil, "", fmt.Errorf("openTunDevice: 3: " + err.Error())
+	}
+	device_name := ifr.Name()
+
+	// Das Gerät wird nicht blockierend verwendet, so kann ein wartender Lesevorgang durch Close beendet werden
+	if err := unix.SetNonblock(fd, true); err != nil {
+		unix.Close(fd)
+		return nil, "", fmt.Errorf("openTunDevice: 4: " + err.Error())
+	}
+	device := os.NewFile(uintptr(fd), "/dev/net/tun")
+
+	// Das Gerät wird eingerichtet
+	if err := configureTunDevice(device_name, mtu, ip, prefix_len); err != nil {
+		device.Close()
+		return nil, "", fmt.Errorf("openTunDevice: 5: " + err.Error())
+	}
+
+	// Das Gerät wird zurückgegeben
+	return device, device_name, nil
+}
+
+// Setzt die MTU und die IPv6 Adresse eines Gerätes und aktiviert es
+func configureTunDevice(name string, mtu uint32, ip net.IP, prefix_len uint32) error {
+	// Die Einstellungen werden über einen IPv6 Socket vorgenommen
+	sock, err := unix.Socket(unix.AF_INET6, unix.SOCK_DGRAM|unix.SOCK_CLOEXEC, 0)
+	if err != nil {
+		return fmt.Errorf("configureTunDevice: 1: " + err.Error())
+	}
+	defer unix.Close(sock)
+
+	// Die MTU wird gesetzt
+	ifr, err := unix.NewIfreq(name)
+	if err != nil {
+		return fmt.Errorf("configureTunDevice: 2: " + err.Error())
+	}
+	ifr.SetUint32(mtu)
+	if err := unix.IoctlIfreq(sock, unix.SIOCSIFMTU, ifr); err != nil {
+		return fmt.Errorf("configureTunDevice: 3: " + err.Error())
+	}
+
+	// Das Gerät wird aktiviert
+	if err := unix.IoctlIfreq(sock, unix.SIOCGIFFLAGS, ifr); err != nil {
+		return fmt.Errorf("configureTunDevice: 4: " + err.Error())
+	}
+	ifr.SetUint16(ifr.Uint16() | unix.IFF_UP | unix.IFF_RUNNING)
+	if err := unix.IoctlIfreq(sock, unix.SIOCSIFFLAGS, ifr); err != nil {
+		return fmt.Errorf("configureTunDevice: 5: " + err.Error())
+	}
+
+	// Der Index des Gerätes wird ermittelt
+	if err := unix.IoctlIfreq(sock, unix.SIOCGIFINDEX, ifr); err != nil {
+		return fmt.Errorf("configureTunDevice: 6: " + err.Error())
+	}
+
+	// Die IPv6 Adresse wird gesetzt
+	req := tun_in6_ifreq{prefixlen: prefix_len, ifindex: int32(ifr.Uint32())}
+	copy(req.addr[:], ip.To16())
+	if _, _, errno := unix.Syscall(unix.SYS_IOCTL, uintptr(sock), uintptr(unix.SIOCSIFADDR), uintptr(unsafe.Pointer(&req))); errno != 0 {
+		return fmt.Errorf("configureTunDevice: 7: " + errno.Error())
+	}
+
+	// Der Vorgang wurde ohne Fehler durchgeführt
+	return nil
+}
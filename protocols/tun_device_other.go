@@ -0,0 +1,13 @@
+//go:build !linux
+
+package protocols
+
+import (
+	"fmt"
+	"net"
+)
+
+// TUN Geräte werden derzeit nur unter Linux unterstützt
+func openTunDevice(name string, mtu uint32, ip net.IP, prefix_len uint32) (tun_device, string, error) {
+	return nil, "", fmt.Errorf("openTunDevice: tun devices are only supported on linux")
+}
@@ -2,33 +2,109 @@ package routingmanager
 
 import (
 	"database/sql"
+	"encoding/hex"
 	"fmt"
-	"log"
+	"sort"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/fluffelpuff/RoueX/logging"
+	"github.com/fluffelpuff/RoueX/utils"
 )
 
+// Gibt die Metrik an, welche für Routen verwendet wird die aus eintreffenden Paketen gelernt wurden
+const LEARNED_ROUTE_METRIC uint64 = 1
+
+// Gibt die Metrik an, ab welcher ein Ziel als nicht erreichbar gilt
+const ROUTE_METRIC_INFINITY uint64 = 16
+
+// Gibt an, nach wievielen Sekunden der Zeitpunkt der letzten Verwendung erneut in die Datenbank geschrieben wird
+const LAST_USED_PERSIST_INTERVAL int64 = 60
+
+// Gibt an, wie lange eine Inaktive Route ohne Verwendung erhalten bleibt bevor sie entfernt wird
+const INACTIVE_ROUTE_EXPIRY = 7 * 24 * time.Hour
+
+// Gibt an, wie lange eine aus Paketen gelernte Route ohne Verwendung erhalten bleibt bevor sie entfernt wird
+const LEARNED_ROUTE_EXPIRY = 15 * time.Minute
+
+// Gibt an, wieviele aus Paketen gelernte Routen pro Relay maximal gespeichert werden
+const MAX_LEARNED_ROUTES_PER_RELAY = 256
+
+// Stellt ein Relay dar, über welches Routen erreichbar sind
 type RelayInterface interface {
+	GetPublicKey() *btcec.PublicKey
 }
 
 // Stellt einen Routing Eintrag dar
 type RoutingManagerEntry struct {
-	_db_id        int64
-	_route_hex_id string
-	_relay_hex_id string
-	_last_used    int64
-	_active       bool
-	_public_key   *btcec.PublicKey
+	_db_id          int64
+	_route_hex_id   string
+	_relay_hex_id   string
+	_dest_hex_id    string
+	_last_used      int64
+	_last_persisted int64
+	_metric         uint64
+	_path           []string
+	_active         bool
+	_public_key     *btcec.PublicKey
+	_relay_key      *btcec.PublicKey
+}
+
+// Gibt den Öffentlichen Schlüssel des Ziels zurück
+func (obj *RoutingManagerEntry) GetDestination() *btcec.PublicKey {
+	return obj._public_key
+}
+
+// Gibt den Öffentlichen Schlüssel des Relays zurück, über welches das Ziel erreichbar ist
+func (obj *RoutingManagerEntry) GetRelayPublicKey() *btcec.PublicKey {
+	return obj._relay_key
+}
+
+// Gibt den Öffentlichen Schlüssel des Relays als Hex String zurück
+func (obj *RoutingManagerEntry) GetRelayHexId() string {
+	return obj._relay_hex_id
+}
+
+// Gibt die Metrik der Route zurück
+func (obj *RoutingManagerEntry) GetMetric() uint64 {
+	return obj._metric
+}
+
+// Gibt die Relays (Hex) zurück, über welche die Route läuft, bei gelernten Routen ist der Pfad leer
+func (obj *RoutingManagerEntry) GetPath() []string {
+	return obj._path
+}
+
+// Gibt an ob die Route aus einem eintreffenden Paket gelernt wurde, gelernte Routen besitzen keinen Pfad
+func (obj *RoutingManagerEntry) IsLearned() bool {
+	return len(obj._path) == 0
+}
+
+// Gibt den Zeitpunkt der letzten Verwendung zurück
+func (obj *RoutingManagerEntry) GetLastUsed() int64 {
+	return obj._last_used
+}
+
+// Gibt an ob die Route Aktiv ist
+func (obj *RoutingManagerEntry) IsActive() bool {
+	return obj._active
 }
 
 // Stellt einen Routen Link dar
 type RelayRoutesList struct {
+	_routes []*RoutingManagerEntry
 }
 
 // Gibt die Anzahl alle Verbindungen aus
 func (o *RelayRoutesList) GetTotalConnections() uint64 {
-	return 0
+	return uint64(len(o._routes))
+}
+
+// Gibt alle Routen der Liste zurück
+func (o *RelayRoutesList) GetRoutes() []*RoutingManagerEntry {
+	return o._routes
 }
 
 // Stellt einen Routing Manager dar
@@ -38,22 +114,453 @@ type RoutingManager struct {
 	_db     *sql.DB
 }
 
+// Sucht eine Route anhand des Ziels und des Relays, muss mit Threadlock aufgerufen werden
+func (obj *RoutingManager) _find_route(dest_hex string, relay_hex string) *RoutingManagerEntry {
+	for i := range obj._routes {
+		if obj._routes[i]._dest_hex_id == dest_hex && obj._routes[i]._relay_hex_id == relay_hex {
+			return obj._routes[i]
+		}
+	}
+	return nil
+}
+
+// Wandelt einen Pfad in eine Liste von Hex Strings um
+func _path_to_hex(path []*btcec.PublicKey) []string {
+	result := make([]string, 0, len(path))
+	for i := range path {
+		result = append(result, hex.EncodeToString(path[i].SerializeCompressed()))
+	}
+	return result
+}
+
+// Gibt an ob zwei Pfade identisch sind
+func _path_equal(p1 []string, p2 []string) bool {
+	if len(p1) != len(p2) {
+		return false
+	}
+	for i := range p1 {
+		if p1[i] != p2[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// Entfernt Routen aus dem Speicher, muss mit Threadlock aufgerufen werden
+func (obj *RoutingManager) _remove_from_memory(routes []*RoutingManagerEntry) {
+	n_routes := make([]*RoutingManagerEntry, 0, len(obj._routes))
+	for i := range obj._routes {
+		is_removed := false
+		for x := range routes {
+			if obj._routes[i] == routes[x] {
+				is_removed = true
+				break
+			}
+		}
+		if !is_removed {
+			n_routes = append(n_routes, obj._routes[i])
+		}
+	}
+	obj._routes = n_routes
+}
+
+// Entfernt Routen aus der Datenbank und dem Speicher, muss mit Threadlock aufgerufen werden
+func (obj *RoutingManager) _delete_routes(routes []*RoutingManagerEntry) error {
+	for i := range routes {
+		if _, err := obj._db.Exec("DELETE FROM routes WHERE route_id = ?", routes[i]._db_id); err != nil {
+			return err
+		}
+	}
+	obj._remove_from_memory(routes)
+	return nil
+}
+
+// Entfernt die am längsten nicht verwendete gelernte Route eines Relays, sofern das Maximum erreicht wurde, muss mit Threadlock aufgerufen werden
+func (obj *RoutingManager) _evict_learned_route(relay_hex string) error {
+	// Die gelernten Routen des Relays werden gezählt, dabei wird die am längsten nicht verwendete Route ermittelt
+	var oldest *RoutingManagerEntry
+	total := 0
+	for i := range obj._routes {
+		if obj._routes[i]._relay_hex_id != relay_hex || !obj._routes[i].IsLearned() {
+			continue
+		}
+		total++
+		if oldest == nil || obj._routes[i]._last_used < oldest._last_used {
+			oldest = obj._routes[i]
+		}
+	}
+
+	// Sollte das Maximum nicht erreicht sein, wird keine Route entfernt
+	if total < MAX_LEARNED_ROUTES_PER_RELAY || oldest == nil {
+		return nil
+	}
+
+	// Die Route wird entfernt
+	return obj._delete_routes([]*RoutingManagerEntry{oldest})
+}
+
 // Wird verwendet um den Routing Manager herunterzufahren
 func (obj *RoutingManager) Shutdown() {
 	// Log
-	log.Println("RoutingManager: shutingdown routing table manager...")
+	logging.Logger(logging.ROUTING).Info("RoutingManager: shutingdown routing table manager")
 
 	// Der Threadlock wird verwendet
 	obj._lock.Lock()
 	defer obj._lock.Unlock()
 
+	// Die Zeitpunkte der letzten Verwendung werden abgespeichert
+	for i := range obj._routes {
+		if obj._routes[i]._last_used != obj._routes[i]._last_persisted {
+			if _, err := obj._db.Exec("UPDATE routes SET last_used = ? WHERE route_id = ?", obj._routes[i]._last_used, obj._routes[i]._db_id); err != nil {
+				logging.Logger(logging.ROUTING).Warn("RoutingManager: error by saving route", "error", err.Error())
+			}
+		}
+	}
+
 	// Die Datenbank wird geschlossen
 	obj._db.Close()
 }
 
 // Gibt die Routen für einen Spiziellen Relay aus
 func (obj *RoutingManager) FetchRoutesByRelay(relay RelayInterface) (*RelayRoutesList, error) {
-	return &RelayRoutesList{}, nil
+	// Es wird geprüft ob ein Relay angegeben wurde
+	if relay == nil || relay.GetPublicKey() == nil {
+		return nil, fmt.Errorf("FetchRoutesByRelay: invalid relay")
+	}
+
+	// Die Routen werden anhand des Öffentlichen Schlüssels abgerufen
+	return obj.FetchRoutesByRelayKey(relay.GetPublicKey())
+}
+
+// Gibt die Routen für einen Spiziellen Relay anhand seines Öffentlichen Schlüssels aus
+func (obj *RoutingManager) FetchRoutesByRelayKey(relay_pkey *btcec.PublicKey) (*RelayRoutesList, error) {
+	// Der Threadlock wird verwendet
+	obj._lock.Lock()
+	defer obj._lock.Unlock()
+
+	// Es werden alle Aktiven Routen für dieses Relay herausgesucht
+	relay_hex := hex.EncodeToString(relay_pkey.SerializeCompressed())
+	result := &RelayRoutesList{_routes: make([]*RoutingManagerEntry, 0)}
+	for i := range obj._routes {
+		if obj._routes[i]._relay_hex_id == relay_hex && obj._routes[i]._active {
+			result._routes = append(result._routes, obj._routes[i])
+		}
+	}
+
+	// Die Routen werden zurückgegeben
+	return result, nil
+}
+
+// Gibt alle Aktiven Routen zu einem Ziel zurück, die Routen sind nach Metrik und letzter Verwendung sortiert
+func (obj *RoutingManager) LookupRoutes(dest *btcec.PublicKey) []*RoutingManagerEntry {
+	// Der Threadlock wird verwendet
+	obj._lock.Lock()
+	defer obj._lock.Unlock()
+
+	// Es werden alle Aktiven Routen für dieses Ziel herausgesucht
+	dest_hex := hex.EncodeToString(dest.SerializeCompressed())
+	result := make([]*RoutingManagerEntry, 0)
+	for i := range obj._routes {
+		if obj._routes[i]._dest_hex_id == dest_hex && obj._routes[i]._active {
+			result = append(result, obj._routes[i])
+		}
+	}
+
+	// Die Routen werden Sortiert, die Route mit der geringsten Metrik steht an erster Stelle
+	sort.SliceStable(result, func(i, j int) bool {
+		if result[i]._metric != result[j]._metric {
+			return result[i]._metric < result[j]._metric
+		}
+		return result[i]._last_used > result[j]._last_used
+	})
+
+	// Die Routen werden zurückgegeben
+	return result
+}
+
+// Fügt eine neue Route hinzu oder aktualisiert eine bereits bekannte Route
+func (obj *RoutingManager) AddOrUpdateRoute(dest *btcec.PublicKey, relay *btcec.PublicKey, metric uint64, path []*btcec.PublicKey) error {
+	// Es wird geprüft ob die Schlüssel angegeben wurden
+	if dest == nil || relay == nil {
+		return fmt.Errorf("AddOrUpdateRoute: 1: invalid public key")
+	}
+
+	// Der Threadlock wird verwendet
+	obj._lock.Lock()
+	defer obj._lock.Unlock()
+
+	// Es wird geprüft ob die Route bereits bekannt ist
+	dest_hex := hex.EncodeToString(dest.SerializeCompressed())
+	relay_hex := hex.EncodeToString(relay.SerializeCompressed())
+	hex_path := _path_to_hex(path)
+	c_time := time.Now().Unix()
+	if route := obj._find_route(dest_hex, relay_hex); route != nil {
+		// Sollte sich nichts geändert haben, wird der Vorgang abgebrochen
+		if route._metric == metric && route._active && _path_equal(route._path, hex_path) {
+			return nil
+		}
+
+		// Die Route wird in der Datenbank aktualisiert
+		if _, err := obj._db.Exec("UPDATE routes SET metric = ?, path = ?, active = 1, last_used = ? WHERE route_id = ?", metric, strings.Join(hex_path, ","), c_time, route._db_id); err != nil {
+			return fmt.Errorf("AddOrUpdateRoute: 2: " + err.Error())
+		}
+
+		// Die Route wird im Speicher aktualisiert
+		route._metric = metric
+		route._path = hex_path
+		route._active = true
+		route._last_used = c_time
+		route._last_persisted = c_time
+
+		// Log
+		logging.Logger(logging.ROUTING).Info("RoutingManager: route updated", "destination", dest_hex, "relay", relay_hex, "metric", metric)
+		return nil
+	}
+
+	// Die Route wird in der Datenbank abgespeichert
+	route_hex_id := utils.RandStringRunes(16)
+	res, err := obj._db.Exec("INSERT INTO routes (route_hex_id, relay_hex_id, last_used, active, public_key, metric, path) VALUES (?, ?, ?, 1, ?, ?, ?)",
+		route_hex_id, relay_hex, c_time, dest_hex, metric, strings.Join(hex_path, ","))
+	if err != nil {
+		return fmt.Errorf("AddOrUpdateRoute: 3: " + err.Error())
+	}
+
+	// Die ID des Eintrages wird abgerufen
+	db_id, err := res.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("AddOrUpdateRoute: 4: " + err.Error())
+	}
+
+	// Die Route wird zwischengespeichert
+	obj._routes = append(obj._routes, &RoutingManagerEntry{
+		_db_id:          db_id,
+		_route_hex_id:   route_hex_id,
+		_dest_hex_id:    dest_hex,
+		_relay_hex_id:   relay_hex,
+		_last_used:      c_time,
+		_last_persisted: c_time,
+		_metric:         metric,
+		_path:           hex_path,
+		_active:         true,
+		_public_key:     dest,
+		_relay_key:      relay,
+	})
+
+	// Log
+	logging.Logger(logging.ROUTING).Info("RoutingManager: new route added", "destination", dest_hex, "relay", relay_hex, "metric", metric)
+	return nil
+}
+
+// Entfernt eine Route
+func (obj *RoutingManager) RemoveRoute(dest *btcec.PublicKey, relay *btcec.PublicKey) error {
+	// Der Threadlock wird verwendet
+	obj._lock.Lock()
+	defer obj._lock.Unlock()
+
+	// Die Route wird herausgesucht
+	dest_hex := hex.EncodeToString(dest.SerializeCompressed())
+	relay_hex := hex.EncodeToString(relay.SerializeCompressed())
+	route := obj._find_route(dest_hex, relay_hex)
+	if route == nil {
+		return nil
+	}
+
+	// Die Route wird aus der Datenbank entfernt
+	if _, err := obj._db.Exec("DELETE FROM routes WHERE route_id = ?", route._db_id); err != nil {
+		return fmt.Errorf("RemoveRoute: " + err.Error())
+	}
+
+	// Die Route wird aus dem Speicher entfernt
+	obj._remove_from_memory([]*RoutingManagerEntry{route})
+
+	// Log
+	logging.Logger(logging.ROUTING).Info("RoutingManager: route removed", "destination", dest_hex, "relay", relay_hex)
+	return nil
+}
+
+// Markiert alle Aktiven Routen welche über ein bestimmtes Relay laufen als Inaktiv, es werden die Ziele der betroffenen Routen zurückgegeben,
+// die Routen bleiben in der Datenbank als Inaktiv erhalten und werden wieder Aktiv sobald sie erneut angekündigt oder gelernt werden
+func (obj *RoutingManager) DeactivateRoutesByRelay(relay *btcec.PublicKey) []*btcec.PublicKey {
+	// Der Threadlock wird verwendet
+	obj._lock.Lock()
+	defer obj._lock.Unlock()
+
+	// Die Aktiven Routen über dieses Relay werden im Speicher als Inaktiv markiert
+	relay_hex := hex.EncodeToString(relay.SerializeCompressed())
+	result := make([]*btcec.PublicKey, 0)
+	for i := range obj._routes {
+		if obj._routes[i]._relay_hex_id == relay_hex && obj._routes[i]._active {
+			obj._routes[i]._active = false
+			result = append(result, obj._routes[i]._public_key)
+		}
+	}
+	if len(result) == 0 {
+		return result
+	}
+
+	// Die Routen werden in der Datenbank als Inaktiv markiert, so bleiben sie nach einem Neustart Inaktiv
+	if _, err := obj._db.Exec("UPDATE routes SET active = 0 WHERE relay_hex_id = ? AND active = 1", relay_hex); err != nil {
+		logging.Logger(logging.ROUTING).Warn("RoutingManager: error by saving deactivated routes", "relay", relay_hex, "error", err.Error())
+	}
+
+	// Log
+	logging.Logger(logging.ROUTING).Info("RoutingManager: routes by relay deactivated", "relay", relay_hex, "total", len(result))
+
+	// Die Ziele werden zurückgegeben
+	return result
+}
+
+// Entfernt alle Inaktiven Routen, welche länger als 'INACTIVE_ROUTE_EXPIRY' nicht mehr verwendet wurden,
+// sowie alle gelernten Routen welche länger als 'LEARNED_ROUTE_EXPIRY' nicht mehr verwendet wurden
+func (obj *RoutingManager) ExpireRoutes() error {
+	// Der Threadlock wird verwendet
+	obj._lock.Lock()
+	defer obj._lock.Unlock()
+
+	// Es werden alle abgelaufenen Routen herausgesucht
+	now := time.Now()
+	inactive_deadline := now.Add(-INACTIVE_ROUTE_EXPIRY).Unix()
+	learned_deadline := now.Add(-LEARNED_ROUTE_EXPIRY).Unix()
+	expired := make([]*RoutingManagerEntry, 0)
+	for i := range obj._routes {
+		if !obj._routes[i]._active && obj._routes[i]._last_used < inactive_deadline {
+			expired = append(expired, obj._routes[i])
+		} else if obj._routes[i].IsLearned() && obj._routes[i]._last_used < learned_deadline {
+			expired = append(expired, obj._routes[i])
+		}
+	}
+	if len(expired) == 0 {
+		return nil
+	}
+
+	// Die Routen werden entfernt
+	if err := obj._delete_routes(expired); err != nil {
+		return fmt.Errorf("ExpireRoutes: " + err.Error())
+	}
+
+	// Log
+	logging.Logger(logging.ROUTING).Info("RoutingManager: routes expired", "total", len(expired))
+	return nil
+}
+
+// Speichert eine aus einem Paket gelernte Route ab, sofern über dieses Relay noch keine Aktive angekündigte Route zum Ziel bekannt ist,
+// sollte das Maximum an gelernten Routen für das Relay erreicht sein, wird die am längsten nicht verwendete gelernte Route entfernt
+func (obj *RoutingManager) LearnRoute(dest *btcec.PublicKey, relay *btcec.PublicKey) error {
+	// Der Threadlock wird verwendet
+	obj._lock.Lock()
+
+	// Es wird geprüft ob die Route bereits bekannt ist
+	relay_hex := hex.EncodeToString(relay.SerializeCompressed())
+	route := obj._find_route(hex.EncodeToString(dest.SerializeCompressed()), relay_hex)
+	if route != nil && route._active {
+		// Bei einer gelernten Route wird der Zeitpunkt der letzten Verwendung aktualisiert
+		obj._lock.Unlock()
+		if route.IsLearned() {
+			obj.MarkRouteUsed(route)
+		}
+		return nil
+	}
+
+	// Für eine neue Route wird sofern nötig Platz geschaffen, eine bekannte Inaktive Route wird überschrieben
+	if route == nil {
+		if err := obj._evict_learned_route(relay_hex); err != nil {
+			obj._lock.Unlock()
+			return fmt.Errorf("LearnRoute: " + err.Error())
+		}
+	}
+	obj._lock.Unlock()
+
+	// Die Route wird hinzugefügt oder wieder Aktiviert
+	return obj.AddOrUpdateRoute(dest, relay, LEARNED_ROUTE_METRIC, nil)
+}
+
+// Gibt für jedes bekannte Ziel die Route mit der geringsten Metrik zurück, muss mit Threadlock aufgerufen werden
+func (obj *RoutingManager) _best_routes(include_learned bool) []*RoutingManagerEntry {
+	// Die besten Routen werden ermittelt
+	best := make(map[string]*RoutingManagerEntry)
+	order := make([]string, 0)
+	for i := range obj._routes {
+		route := obj._routes[i]
+		if !route._active || (!include_learned && route.IsLearned()) {
+			continue
+		}
+		current, found := best[route._dest_hex_id]
+		if !found {
+			order = append(order, route._dest_hex_id)
+			best[route._dest_hex_id] = route
+		} else if route._metric < current._metric {
+			best[route._dest_hex_id] = route
+		}
+	}
+
+	// Die Routen werden zurückgegeben
+	result := make([]*RoutingManagerEntry, 0, len(order))
+	for i := range order {
+		result = append(result, best[order[i]])
+	}
+	return result
+}
+
+// Gibt für jedes bekannte Ziel die Route mit der geringsten Metrik zurück
+func (obj *RoutingManager) GetBestRoutes() []*RoutingManagerEntry {
+	// Der Threadlock wird verwendet
+	obj._lock.Lock()
+	defer obj._lock.Unlock()
+
+	// Die besten Routen werden zurückgegeben
+	return obj._best_routes(true)
+}
+
+// Gibt für jedes bekannte Ziel die angekündigte Route mit der geringsten Metrik zurück, aus Paketen gelernte Routen werden nicht berücksichtigt
+func (obj *RoutingManager) GetBestAdvertisedRoutes() []*RoutingManagerEntry {
+	// Der Threadlock wird verwendet
+	obj._lock.Lock()
+	defer obj._lock.Unlock()
+
+	// Die besten angekündigten Routen werden zurückgegeben
+	return obj._best_routes(false)
+}
+
+// Signalisiert dass eine Route verwendet wurde
+func (obj *RoutingManager) MarkRouteUsed(route *RoutingManagerEntry) {
+	// Der Threadlock wird verwendet
+	obj._lock.Lock()
+	defer obj._lock.Unlock()
+
+	// Der Zeitpunkt wird aktualisiert
+	route._last_used = time.Now().Unix()
+
+	// Sollte der Zeitpunkt lange genug nicht gespeichert worden sein, wird er in die Datenbank geschrieben
+	if route._last_used-route._last_persisted < LAST_USED_PERSIST_INTERVAL {
+		return
+	}
+	if _, err := obj._db.Exec("UPDATE routes SET last_used = ? WHERE route_id = ?", route._last_used, route._db_id); err != nil {
+		logging.Logger(logging.ROUTING).Warn("RoutingManager: error by saving route", "error", err.Error())
+		return
+	}
+	route._last_persisted = route._last_used
+}
+
+// Wandelt einen Hex String in einen Öffentlichen Schlüssel um
+func _pkey_from_hex(value string) (*btcec.PublicKey, error) {
+	decoded, err := hex.DecodeString(value)
+	if err != nil {
+		return nil, err
+	}
+	return btcec.ParsePubKey(decoded)
+}
+
+// Fügt eine Spalte zur Routing Tabelle hinzu, sofern sie noch nicht vorhanden ist
+func _ensure_column(db *sql.DB, name string, definition string) error {
+	var count int
+	if err := db.QueryRow("SELECT COUNT(*) FROM pragma_table_info('routes') WHERE name=?", name).Scan(&count); err != nil {
+		return err
+	}
+	if count > 0 {
+		return nil
+	}
+	_, err := db.Exec(`ALTER TABLE "routes" ADD COLUMN "` + name + `" ` + definition)
+	return err
 }
 
 // Wird verwendet um den Routing Manager zu laden
@@ -65,7 +572,7 @@ func LoadRoutingManager(path string) (RoutingManager, error) {
 	}
 
 	// Log
-	fmt.Printf("Loading Routing database from %s...\n", path)
+	logging.Logger(logging.ROUTING).Info("RoutingManager: loading routing database", "path", path)
 
 	// Die Anzahl der Tabellen mit dem Namen relays wird abgerufen
 	var count int
@@ -90,28 +597,60 @@ func LoadRoutingManager(path string) (RoutingManager, error) {
 			"last_used"	INTEGER DEFAULT -1,
 			"active"	INTEGER DEFAULT 1,
 			"public_key"	TEXT,
+			"metric"	INTEGER DEFAULT 1,
+			"path"	TEXT DEFAULT '',
 			PRIMARY KEY("route_id" AUTOINCREMENT)
 		);`)
 		if err != nil {
 			return RoutingManager{}, err
 		}
-		fmt.Printf("New routing table database created %s\n", path)
+		logging.Logger(logging.ROUTING).Info("RoutingManager: new routing table database created", "path", path)
 	} else {
-		// Es werden alle Verfügabren Relays abgerufen
-		rows, err := db.Query("SELECT * FROM routes")
+		// Ältere Tabellen besitzen keine Metrik und keine Pfad Spalte, diese werden nachträglich hinzugefügt
+		if err := _ensure_column(db, "metric", `INTEGER DEFAULT 1`); err != nil {
+			return RoutingManager{}, err
+		}
+		if err := _ensure_column(db, "path", `TEXT DEFAULT ''`); err != nil {
+			return RoutingManager{}, err
+		}
+
+		// Es werden alle Verfügabren Routen abgerufen
+		rows, err := db.Query("SELECT route_id, route_hex_id, relay_hex_id, last_used, active, public_key, metric, path FROM routes")
 		if err != nil {
 			return RoutingManager{}, err
 		}
+		defer rows.Close()
 
-		// Die Einzelnen Relays werden abgerbeietet
+		// Die Einzelnen Routen werden abgerbeietet
 		for rows.Next() {
-			// Die Daten des Aktuellen Relays werden ausgelesen
+			// Die Daten der Aktuellen Route werden ausgelesen
 			var pre_result RoutingManagerEntry
 			var is_active_res int64
-			err := rows.Scan(&pre_result._db_id, &pre_result._route_hex_id, &pre_result._relay_hex_id, &pre_result._last_used, &is_active_res, &pre_result._public_key)
+			var public_key, path string
+			err := rows.Scan(&pre_result._db_id, &pre_result._route_hex_id, &pre_result._relay_hex_id, &pre_result._last_used, &is_active_res, &public_key, &pre_result._metric, &path)
+			if err != nil {
+				return RoutingManager{}, err
+			}
+
+			// Die Öffentlichen Schlüssel werden eingelesen
+			pre_result._public_key, err = _pkey_from_hex(public_key)
 			if err != nil {
 				return RoutingManager{}, err
 			}
+			pre_result._relay_key, err = _pkey_from_hex(pre_result._relay_hex_id)
+			if err != nil {
+				return RoutingManager{}, err
+			}
+
+			// Das Ziel wird als Hex String zwischengespeichert
+			pre_result._dest_hex_id = hex.EncodeToString(pre_result._public_key.SerializeCompressed())
+			pre_result._last_persisted = pre_result._last_used
+
+			// Der Pfad der Route wird eingelesen
+			pre_result._path = make([]string, 0)
+			if len(path) > 0 {
+				pre_result._path = strings.Split(path, ",")
+			}
 
 			// Es wird geprüft ob die Verbindung aktiv ist
 			if is_active_res == 1 {
@@ -125,7 +664,7 @@ func LoadRoutingManager(path string) (RoutingManager, error) {
 		}
 
 		// Log
-		fmt.Printf("Routes from routing table loaded, total = %d, path = %s\n", len(route_entrys), path)
+		logging.Logger(logging.ROUTING).Info("RoutingManager: routes from routing table loaded", "total", len(route_entrys), "path", path)
 	}
 
 	// Es wird ein neues Routing Table objekt erstetllt
@@ -0,0 +1,202 @@
+package routingmanager
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// Erzeugt einen neuen Öffentlichen Schlüssel für die Tests
+func newTestKey(t *testing.T) *btcec.PublicKey {
+	t.Helper()
+	priv, err := btcec.NewPrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return priv.PubKey()
+}
+
+// Lädt einen leeren Routing Manager aus einem Temporären Verzeichnis
+func newTestRoutingManager(t *testing.T, path string) *RoutingManager {
+	t.Helper()
+	manager, err := LoadRoutingManager(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &manager
+}
+
+func TestLearnRoute(t *testing.T) {
+	manager := newTestRoutingManager(t, filepath.Join(t.TempDir(), "routes.db"))
+	defer manager.Shutdown()
+	dest, relay := newTestKey(t), newTestKey(t)
+
+	if err := manager.LearnRoute(dest, relay); err != nil {
+		t.Fatal(err)
+	}
+	routes := manager.LookupRoutes(dest)
+	if len(routes) != 1 || !routes[0].IsLearned() || routes[0].GetMetric() != LEARNED_ROUTE_METRIC {
+		t.Fatalf("LookupRoutes() = %v", routes)
+	}
+
+	// Eine angekündigte Route wird durch ein eintreffendes Paket nicht überschrieben
+	if err := manager.AddOrUpdateRoute(dest, relay, 3, []*btcec.PublicKey{relay}); err != nil {
+		t.Fatal(err)
+	}
+	if err := manager.LearnRoute(dest, relay); err != nil {
+		t.Fatal(err)
+	}
+	routes = manager.LookupRoutes(dest)
+	if len(routes) != 1 || routes[0].IsLearned() || routes[0].GetMetric() != 3 {
+		t.Errorf("advertised route was overwritten: learned = %v, metric = %d", routes[0].IsLearned(), routes[0].GetMetric())
+	}
+}
+
+func TestLearnRouteLimit(t *testing.T) {
+	manager := newTestRoutingManager(t, filepath.Join(t.TempDir(), "routes.db"))
+	defer manager.Shutdown()
+	relay := newTestKey(t)
+
+	// Es werden so viele Routen gelernt, wie für ein Relay zulässig sind
+	first := newTestKey(t)
+	if err := manager.LearnRoute(first, relay); err != nil {
+		t.Fatal(err)
+	}
+	manager.LookupRoutes(first)[0]._last_used -= 100
+	for i := 1; i < MAX_LEARNED_ROUTES_PER_RELAY; i++ {
+		if err := manager.LearnRoute(newTestKey(t), relay); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Die am längsten nicht verwendete Route wird für eine neue Route entfernt
+	if err := manager.LearnRoute(newTestKey(t), relay); err != nil {
+		t.Fatal(err)
+	}
+	routes, err := manager.FetchRoutesByRelayKey(relay)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if routes.GetTotalConnections() != uint64(MAX_LEARNED_ROUTES_PER_RELAY) {
+		t.Errorf("total routes = %d, want %d", routes.GetTotalConnections(), MAX_LEARNED_ROUTES_PER_RELAY)
+	}
+	if len(manager.LookupRoutes(first)) != 0 {
+		t.Error("least recently used route was not evicted")
+	}
+
+	// Angekündigte Routen zählen nicht zum Maximum der gelernten Routen
+	if err := manager.AddOrUpdateRoute(newTestKey(t), relay, 2, []*btcec.PublicKey{relay}); err != nil {
+		t.Fatal(err)
+	}
+	if routes, _ := manager.FetchRoutesByRelayKey(relay); routes.GetTotalConnections() != uint64(MAX_LEARNED_ROUTES_PER_RELAY)+1 {
+		t.Errorf("total routes = %d, want %d", routes.GetTotalConnections(), MAX_LEARNED_ROUTES_PER_RELAY+1)
+	}
+}
+
+func TestExpireRoutes(t *testing.T) {
+	manager := newTestRoutingManager(t, filepath.Join(t.TempDir(), "routes.db"))
+	defer manager.Shutdown()
+	relay := newTestKey(t)
+	learned, fresh, advertised, inactive := newTestKey(t), newTestKey(t), newTestKey(t), newTestKey(t)
+
+	// Die Routen werden angelegt
+	for _, dest := range []*btcec.PublicKey{learned, fresh} {
+		if err := manager.LearnRoute(dest, relay); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, dest := range []*btcec.PublicKey{advertised, inactive} {
+		if err := manager.AddOrUpdateRoute(dest, relay, 2, []*btcec.PublicKey{relay}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Die Zeitpunkte der letzten Verwendung werden in die Vergangenheit verschoben
+	manager.LookupRoutes(learned)[0]._last_used -= int64((LEARNED_ROUTE_EXPIRY + time.Minute).Seconds())
+	manager.LookupRoutes(advertised)[0]._last_used -= int64((LEARNED_ROUTE_EXPIRY + time.Minute).Seconds())
+	inactive_route := manager.LookupRoutes(inactive)[0]
+	inactive_route._last_used -= int64((INACTIVE_ROUTE_EXPIRY + time.Minute).Seconds())
+	inactive_route._active = false
+
+	// Nur die abgelaufene gelernte und die abgelaufene Inaktive Route werden entfernt
+	if err := manager.ExpireRoutes(); err != nil {
+		t.Fatal(err)
+	}
+	if len(manager.LookupRoutes(learned)) != 0 {
+		t.Error("expired learned route was not removed")
+	}
+	if len(manager.LookupRoutes(fresh)) != 1 {
+		t.Error("fresh learned route was removed")
+	}
+	if len(manager.LookupRoutes(advertised)) != 1 {
+		t.Error("active advertised route was removed")
+	}
+	if manager._find_route(inactive_route._dest_hex_id, inactive_route._relay_hex_id) != nil {
+		t.Error("expired inactive route was not removed")
+	}
+}
+
+func TestDeactivateRoutesByRelayPersisted(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "routes.db")
+	manager := newTestRoutingManager(t, path)
+	relay, other := newTestKey(t), newTestKey(t)
+	dest, other_dest := newTestKey(t), newTestKey(t)
+	if err := manager.AddOrUpdateRoute(dest, relay, 2, []*btcec.PublicKey{relay}); err != nil {
+		t.Fatal(err)
+	}
+	if err := manager.AddOrUpdateRoute(other_dest, other, 2, []*btcec.PublicKey{other}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Nur die Routen über das Relay werden deaktiviert
+	deactivated := manager.DeactivateRoutesByRelay(relay)
+	if len(deactivated) != 1 || !deactivated[0].IsEqual(dest) {
+		t.Fatalf("DeactivateRoutesByRelay() = %v", deactivated)
+	}
+	if len(manager.LookupRoutes(dest)) != 0 || len(manager.LookupRoutes(other_dest)) != 1 {
+		t.Fatal("wrong routes deactivated")
+	}
+	manager.Shutdown()
+
+	// Nach dem erneuten Laden bleibt die Route Inaktiv
+	reloaded := newTestRoutingManager(t, path)
+	defer reloaded.Shutdown()
+	if len(reloaded.LookupRoutes(dest)) != 0 {
+		t.Error("deactivated route is active after reload")
+	}
+	if len(reloaded.LookupRoutes(other_dest)) != 1 {
+		t.Error("active route is missing after reload")
+	}
+}
+
+func TestGetBestAdvertisedRoutes(t *testing.T) {
+	manager := newTestRoutingManager(t, filepath.Join(t.TempDir(), "routes.db"))
+	defer manager.Shutdown()
+	relay, other := newTestKey(t), newTestKey(t)
+	learned, advertised := newTestKey(t), newTestKey(t)
+
+	if err := manager.LearnRoute(learned, relay); err != nil {
+		t.Fatal(err)
+	}
+	if err := manager.AddOrUpdateRoute(advertised, relay, 4, []*btcec.PublicKey{relay}); err != nil {
+		t.Fatal(err)
+	}
+	if err := manager.AddOrUpdateRoute(advertised, other, 2, []*btcec.PublicKey{other}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Gelernte Routen werden nur bei den besten Routen berücksichtigt
+	if got := len(manager.GetBestRoutes()); got != 2 {
+		t.Errorf("len(GetBestRoutes()) = %d, want 2", got)
+	}
+	best := manager.GetBestAdvertisedRoutes()
+	if len(best) != 1 {
+		t.Fatalf("len(GetBestAdvertisedRoutes()) = %d, want 1", len(best))
+	}
+	if !best[0].GetDestination().IsEqual(advertised) || !best[0].GetRelayPublicKey().IsEqual(other) || best[0].GetMetric() != 2 {
+		t.Errorf("best advertised route is not the route with the lowest metric")
+	}
+}
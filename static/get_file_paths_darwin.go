@@ -4,6 +4,12 @@ package static
 
 // Gibt den PATH für eine bestimmte Datei aus
 func GetFilePathFor(fp File) string {
+	// Es wird geprüft ob der Pfad zur Laufzeit überschrieben wurde
+	if path, found := getFilePathOverride(fp); found {
+		return path
+	}
+
+	// Der Standardpfad wird zurückgegeben
 	switch fp {
 	case BASE_CONFIG:
 		return OSX_BASE_CONFIG_PATH
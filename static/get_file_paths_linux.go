@@ -4,11 +4,17 @@ package static
 
 // Gibt den PATH für eine bestimmte Datei aus
 func GetFilePathFor(fp File) string {
+	// Es wird geprüft ob der Pfad zur Laufzeit überschrieben wurde
+	if path, found := getFilePathOverride(fp); found {
+		return path
+	}
+
+	// Der Standardpfad wird zurückgegeben
 	switch fp {
 	case BASE_CONFIG:
 		return DEBIAN_BASE_CONFIG_PATH
 	case API_SOCKET:
-		return DEBIAN_FIREWALL_TABLE_PATH
+		return DEBIAN_NO_ROOT_API_SOCKET
 	case TRUSTED_RELAYS:
 		return DEBIAN_TRUSTED_RELAYS_PATH
 	case ROUTING_TABLE:
@@ -0,0 +1,43 @@
+package static
+
+// Stellt alle Grenzwerte dar, welche zur Laufzeit durch die Einstellungen überschrieben werden können
+type RuntimeLimits struct {
+	// Gibt an, wieviele Pakete eine Websocket Verbindung zwischenspeichern kann
+	WSMaxPackages uint32
+
+	// Gibt an, wieviele Bytes eine Websocket Verbindung zwischenspeichern kann
+	WSMaxBytes uint64
+
+	// Gibt an, wieviele Pakete der Kernel Paket Buffer zwischenspeichern darf
+	KernelBufferMaxPackages uint
+}
+
+// Speichert die Aktuell verwendeten Grenzwerte ab
+var (
+	LIMITS RuntimeLimits = RuntimeLimits{
+		WSMaxPackages:           WS_MAX_PACKAGES,
+		WSMaxBytes:              WS_MAX_BYTES,
+		KernelBufferMaxPackages: 1024,
+	}
+)
+
+// Speichert alle Dateipfade ab, welche zur Laufzeit überschrieben wurden
+var _file_path_overrides = make(map[File]string)
+
+// Überschreibt den Standardpfad einer bestimmten Datei
+func SetFilePathFor(fp File, path string) {
+	// Sollte kein Pfad angegeben sein, wird der Standardpfad verwendet
+	if len(path) == 0 {
+		delete(_file_path_overrides, fp)
+		return
+	}
+
+	// Der Pfad wird zwischengespeichert
+	_file_path_overrides[fp] = path
+}
+
+// Gibt den überschriebenen Pfad einer Datei zurück, sofern vorhanden
+func getFilePathOverride(fp File) (string, bool) {
+	path, found := _file_path_overrides[fp]
+	return path, found
+}
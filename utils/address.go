@@ -5,6 +5,8 @@ import (
 	b32 "encoding/base32"
 	"encoding/hex"
 	"fmt"
+	"net"
+	"strconv"
 	"strings"
 
 	"github.com/btcsuite/btcd/btcec/v2"
@@ -72,7 +74,7 @@ func ConvertAddressToPublicKey(address_str string) (*btcec.PublicKey, error) {
 
 	// Es wird geprüft ob die Länge des Öffentlichen Schlüssels zulässig ist
 	if len(decoded) != 33 {
-		return nil, fmt.Errorf("ConvertAddressToPublicKey: invalid public key length: " + string(len(decoded)))
+		return nil, fmt.Errorf("ConvertAddressToPublicKey: invalid public key length: " + strconv.Itoa(len(decoded)))
 	}
 
 	// Es wird versucht den Öffentlichen Schlüssel einzulesen
@@ -84,3 +86,18 @@ func ConvertAddressToPublicKey(address_str string) (*btcec.PublicKey, error) {
 	// Der Öffentliche Schlüssel wird zurückgegeben
 	return readed_pub_key, nil
 }
+
+// Gibt das Präfix der Overlay IPv6 Adressen an (fd72:6f75:6578::/48, Unique Local Address)
+var OVERLAY_IP_PREFIX = [6]byte{0xfd, 0x72, 0x6f, 0x75, 0x65, 0x78}
+
+// Gibt die Länge des Overlay IPv6 Präfixes in Bits an
+const OVERLAY_IP_PREFIX_LEN = 48
+
+// Leitet die Overlay IPv6 Adresse eines Öffentlichen Schlüssels ab, auf das Präfix folgen die ersten 80 Bit des SHA3-256 Hashes des Schlüssels
+func ConvertPublicKeyToOverlayIP(pubk *btcec.PublicKey) net.IP {
+	hash := ComputeSha3256Hash(pubk.SerializeCompressed())
+	result := make(net.IP, net.IPv6len)
+	copy(result, OVERLAY_IP_PREFIX[:])
+	copy(result[len(OVERLAY_IP_PREFIX):], hash[:net.IPv6len-len(OVERLAY_IP_PREFIX)])
+	return result
+}
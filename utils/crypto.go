@@ -147,7 +147,7 @@ func EncryptWithChaCha(ecdh_key []byte, data []byte) ([]byte, error) {
 
 	m_data, err := cbor.Marshal(aes_paket, cbor.EncOptions{})
 	if err != nil {
-		fmt.Println("error:", err)
+		return nil, err
 	}
 
 	return m_data, nil